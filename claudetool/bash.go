@@ -18,6 +18,8 @@ import (
 	"time"
 
 	"github.com/tgruben-circuit/percy/claudetool/bashkit"
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/llm"
 )
 
@@ -39,6 +41,12 @@ type BashTool struct {
 	// ConversationID is the ID of the conversation this tool belongs to.
 	// It is exposed to invoked commands via PERCY_CONVERSATION_ID.
 	ConversationID string
+	// Sandbox, if enabled, isolates commands (see package sandbox).
+	Sandbox *sandbox.Config
+	// Checkpoints, if set, saves the files commands change, as found by
+	// Scanner.
+	Checkpoints checkpoint.Saver
+	Scanner     *checkpoint.Scanner
 }
 
 const (
@@ -73,9 +81,13 @@ func (t *Timeouts) slow() time.Duration {
 
 // Tool returns an llm.Tool based on b.
 func (b *BashTool) Tool() *llm.Tool {
+	description := strings.TrimSpace(bashDescription)
+	if b.Sandbox.Enabled() {
+		description += "\n\n" + b.Sandbox.Description()
+	}
 	return &llm.Tool{
 		Name:        bashName,
-		Description: strings.TrimSpace(bashDescription),
+		Description: description,
 		InputSchema: llm.MustSchema(bashInputSchema),
 		Run:         b.Run,
 	}
@@ -100,7 +112,7 @@ const (
 	bashDescription = `Executes shell commands via bash --login -c, returning combined stdout/stderr.
 Bash state changes (working dir, variables, aliases) don't persist between calls.
 
-For long-running processes (servers, watch modes), use tmux instead.
+For long-running processes (servers, watch modes), use the process tool instead.
 Do NOT use &, nohup, or disown — the bash tool kills its process group on exit.
 
 MUST set slow_ok=true for potentially slow commands: builds, downloads,
@@ -210,7 +222,7 @@ const (
 	maxLineLength        = 200 // truncate displayed lines to this length
 )
 
-func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.Writer) *exec.Cmd {
+func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.Writer) (*exec.Cmd, func(), error) {
 	cmd := exec.CommandContext(ctx, "bash", "--login", "-c", command)
 	// Use shared WorkingDir if available, then context, then Pwd fallback
 	cmd.Dir = b.getWorkingDir()
@@ -228,17 +240,27 @@ func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.W
 		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) // kill entire process group
 	}
 	cmd.WaitDelay = 15 * time.Second // prevent indefinite hangs when child processes keep pipes open
+	cmd.Env = commandEnv(b.ConversationID)
+	cmd.Env = append(cmd.Env, `GIT_SEQUENCE_EDITOR=echo "To do an interactive rebase, run it in a tmux session." && exit 1`)
+	release, err := b.Sandbox.Wrap(cmd, sandbox.Workspace(cmd.Dir))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sandbox command: %w", err)
+	}
+	return cmd, release, nil
+}
+
+// commandEnv returns the environment for commands run on behalf of the agent.
+func commandEnv(conversationID string) []string {
 	// Remove PERCY_CONVERSATION_ID so we control it explicitly below.
 	env := slices.DeleteFunc(os.Environ(), func(s string) bool {
 		return strings.HasPrefix(s, "PERCY_CONVERSATION_ID=")
 	})
 	env = append(env, "SKETCH=1")          // signal that this has been run by Sketch, sometimes useful for scripts
 	env = append(env, "EDITOR=/bin/false") // interactive editors won't work
-	if b.ConversationID != "" {
-		env = append(env, "PERCY_CONVERSATION_ID="+b.ConversationID)
+	if conversationID != "" {
+		env = append(env, "PERCY_CONVERSATION_ID="+conversationID)
 	}
-	cmd.Env = env
-	return cmd
+	return env
 }
 
 func cmdWait(cmd *exec.Cmd) error {
@@ -255,13 +277,18 @@ func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.
 	defer cancel()
 
 	output := new(bytes.Buffer)
-	cmd := b.makeBashCommand(execCtx, req.Command, output)
-	cmd.Env = append(cmd.Env, `GIT_SEQUENCE_EDITOR=echo "To do an interactive rebase, run it in a tmux session." && exit 1`)
+	cmd, release, err := b.makeBashCommand(execCtx, req.Command, output)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	snapshot := b.snapshotWorkspace(ctx, cmd.Dir)
 	if err := cmd.Start(); err != nil {
 		return "", fmt.Errorf("command failed: %w", err)
 	}
 
-	err := cmdWait(cmd)
+	err = cmdWait(cmd)
+	b.checkpointChanges(ctx, snapshot)
 
 	out, formatErr := formatForegroundBashOutput(output.String())
 	if formatErr != nil {
@@ -278,6 +305,56 @@ func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.
 	return out, nil
 }
 
+// checkpointScanTimeout bounds each scan of the workspace for changed files.
+const checkpointScanTimeout = 30 * time.Second
+
+// snapshotWorkspace records the workspace of a command run in dir before
+// it runs, so that the files it changes can be checkpointed. The workspace
+// is the root of the repository containing dir, as for the sandbox
+// (sandbox.Workspace); commands outside a repository aren't checkpointed.
+func (b *BashTool) snapshotWorkspace(ctx context.Context, dir string) *checkpoint.Snapshot {
+	if b.Checkpoints == nil || b.Scanner == nil {
+		return nil
+	}
+	workspace := sandbox.Workspace(dir)
+	if _, err := os.Stat(filepath.Join(workspace, ".git")); err != nil {
+		return nil
+	}
+	// Never scan the whole filesystem or the home directory. A workspace
+	// that contains the scanner's own repositories would snapshot them too.
+	if home, _ := os.UserHomeDir(); workspace == "/" || workspace == home {
+		return nil
+	}
+	if rel, err := filepath.Rel(workspace, b.Scanner.Dir()); err == nil && !strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkpointScanTimeout)
+	defer cancel()
+	snapshot, err := b.Scanner.Snapshot(ctx, workspace)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to snapshot workspace for checkpoints", "workspace", workspace, "error", err)
+		return nil
+	}
+	return snapshot
+}
+
+// checkpointChanges saves the files that changed since snapshot.
+func (b *BashTool) checkpointChanges(ctx context.Context, snapshot *checkpoint.Snapshot) {
+	if snapshot == nil {
+		return
+	}
+	// Save changes even if the command was cancelled.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), checkpointScanTimeout)
+	defer cancel()
+	files, err := b.Scanner.Changes(ctx, snapshot)
+	if err == nil && len(files) > 0 {
+		err = b.Checkpoints.Save(ctx, files)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "failed to checkpoint files changed by command", "error", err)
+	}
+}
+
 // formatForegroundBashOutput formats the output of a foreground bash command for display to the agent.
 // If output exceeds largeOutputThreshold, it saves to a file and returns a summary.
 func formatForegroundBashOutput(out string) (string, error) {
@@ -3,10 +3,15 @@ package claudetool
 import (
 	"context"
 	"encoding/json"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
 )
 
 func TestBashSlowOk(t *testing.T) {
@@ -274,6 +279,107 @@ func TestExecuteBash(t *testing.T) {
 	})
 }
 
+// recordingSaver is a checkpoint.Saver that remembers what it saves.
+type recordingSaver struct {
+	mu    sync.Mutex
+	files map[string]checkpoint.File // by base name
+}
+
+func (r *recordingSaver) Save(ctx context.Context, files []checkpoint.File) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.files == nil {
+		r.files = map[string]checkpoint.File{}
+	}
+	for _, f := range files {
+		r.files[filepath.Base(f.Path)] = f
+	}
+	return nil
+}
+
+func TestBashCheckpoints(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saver := &recordingSaver{}
+	bashTool := &BashTool{
+		WorkingDir:  NewMutableWorkingDir(dir),
+		Checkpoints: saver,
+		Scanner:     checkpoint.NewScanner(t.TempDir()),
+	}
+	req := bashInput{Command: "echo new > edit.txt && echo hi > added.txt"}
+	if _, err := bashTool.executeBash(context.Background(), req, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := saver.files["edit.txt"]; !ok || !f.Exists || string(f.Content) != "old" {
+		t.Errorf("edit.txt checkpoint = %+v", f)
+	}
+	if f, ok := saver.files["added.txt"]; !ok || f.Exists {
+		t.Errorf("added.txt checkpoint = %+v", f)
+	}
+
+	// A workspace that contains the scanner's repositories is not scanned.
+	bashTool.Scanner = checkpoint.NewScanner(filepath.Join(dir, "checkpoints"))
+	saver.files = nil
+	req = bashInput{Command: "echo newer > edit.txt"}
+	if _, err := bashTool.executeBash(context.Background(), req, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(saver.files) != 0 {
+		t.Errorf("checkpoints = %+v, want none", saver.files)
+	}
+}
+
+func TestBashCheckpointsNestedDir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"worktree", "add", "-q", filepath.Join(repo, "wt")},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	// Commands in a subdirectory of a repository, and in a worktree, whose
+	// .git is a file, checkpoint the repository's files.
+	for _, dir := range []string{filepath.Join(repo, "sub", "dir"), filepath.Join(repo, "wt", "sub")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "..", "top.txt"), []byte("old"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		saver := &recordingSaver{}
+		bashTool := &BashTool{
+			WorkingDir:  NewMutableWorkingDir(dir),
+			Checkpoints: saver,
+			Scanner:     checkpoint.NewScanner(t.TempDir()),
+		}
+		req := bashInput{Command: "echo new > ../top.txt && echo hi > here.txt"}
+		if _, err := bashTool.executeBash(context.Background(), req, 30*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if f, ok := saver.files["top.txt"]; !ok || string(f.Content) != "old" {
+			t.Errorf("%s: top.txt checkpoint = %+v", dir, f)
+		}
+		if f, ok := saver.files["here.txt"]; !ok || f.Exists {
+			t.Errorf("%s: here.txt checkpoint = %+v", dir, f)
+		}
+	}
+}
+
 func TestBashTimeout(t *testing.T) {
 	// Test default timeout values
 	t.Run("Default Timeout Values", func(t *testing.T) {
@@ -234,25 +234,9 @@ func (b *BrowseTools) accessibilityQuery(name, role string) llm.ToolOut {
 
 	var nodes []*accessibility.Node
 	err = chromedp.Run(browserCtx, chromedp.ActionFunc(func(ctx context.Context) error {
-		// Get the document root node ID
-		doc, err := dom.GetDocument().WithDepth(0).Do(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get document: %w", err)
-		}
-
-		params := accessibility.QueryAXTree().WithNodeID(doc.NodeID)
-		if name != "" {
-			params = params.WithAccessibleName(name)
-		}
-		if role != "" {
-			params = params.WithRole(role)
-		}
-		result, err := params.Do(ctx)
-		if err != nil {
-			return err
-		}
+		result, err := queryAXTree(ctx, name, role)
 		nodes = result
-		return nil
+		return err
 	}))
 	if err != nil {
 		return llm.ErrorfToolOut("failed to query accessibility tree: %w", err)
@@ -290,6 +274,25 @@ func (b *BrowseTools) accessibilityQuery(name, role string) llm.ToolOut {
 	return b.maybeWriteToFile(result, "ax_query")
 }
 
+// queryAXTree returns the accessibility nodes of the document that match
+// the accessible name and/or role. ctx must be a chromedp executor context.
+func queryAXTree(ctx context.Context, name, role string) ([]*accessibility.Node, error) {
+	// Get the document root node ID
+	doc, err := dom.GetDocument().WithDepth(0).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	params := accessibility.QueryAXTree().WithNodeID(doc.NodeID)
+	if name != "" {
+		params = params.WithAccessibleName(name)
+	}
+	if role != "" {
+		params = params.WithRole(role)
+	}
+	return params.Do(ctx)
+}
+
 func (b *BrowseTools) accessibilityNode(selector string) llm.ToolOut {
 	if selector == "" {
 		return llm.ErrorfToolOut("'selector' parameter is required for the node action")
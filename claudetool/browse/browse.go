@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/fetch"
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/cdproto/tracing"
@@ -79,6 +80,11 @@ type BrowseTools struct {
 	networkRequests    []*NetworkRequest
 	networkMutex       sync.Mutex
 	maxNetworkRequests int
+	// Request interception
+	interceptRules []*interceptRule
+	nextRuleID     int
+	replay         *harReplay
+	interceptMutex sync.Mutex
 	// Profiling state
 	profilingActive bool
 	tracingActive   bool
@@ -179,7 +185,10 @@ func (b *BrowseTools) GetBrowserContext() (context.Context, error) {
 			b.networkMutex.Unlock()
 			if enabled {
 				b.captureNetworkFinished(e)
+				go b.captureResponseBody(browserCtx, e.RequestID, e.EncodedDataLength)
 			}
+		case *fetch.EventRequestPaused:
+			go b.handleRequestPaused(browserCtx, e)
 		case *tracing.EventDataCollected:
 			b.traceMutex.Lock()
 			if b.tracingActive {
@@ -224,6 +233,15 @@ func (b *BrowseTools) GetBrowserContext() (context.Context, error) {
 		return nil, fmt.Errorf("failed to configure download behavior: %w", err)
 	}
 
+	// Restore request interception after a restart
+	if b.interceptionActive() {
+		if err := b.syncFetch(browserCtx); err != nil {
+			browserCancel()
+			allocCancel()
+			return nil, fmt.Errorf("failed to restore request interception: %w", err)
+		}
+	}
+
 	b.allocCtx = allocCtx
 	b.allocCancel = allocCancel
 	b.browserCtx = browserCtx
@@ -445,7 +463,7 @@ func (b *BrowseTools) NewEvalTool() *llm.Tool {
 	return &llm.Tool{
 		Name: "browser_eval",
 		Description: `Evaluate JavaScript in the browser context.
-Your go-to tool for interacting with content: clicking buttons, typing, getting content, scrolling, resizing, waiting for content/selector to be ready, etc.`,
+Use it to read content, scroll, and anything the interaction tools can't do; to click, type, select options, press keys or wait for content, prefer browser_click, browser_type, browser_select, browser_press_key and browser_wait_for.`,
 		InputSchema: json.RawMessage(`{
 			"type": "object",
 			"properties": {
@@ -642,6 +660,12 @@ func (b *BrowseTools) GetTools(includeScreenshotTools bool) []*llm.Tool {
 	tools := []*llm.Tool{
 		b.NewNavigateTool(),
 		b.NewEvalTool(),
+		b.NewClickTool(),
+		b.NewTypeTool(),
+		b.NewSelectTool(),
+		b.NewPressKeyTool(),
+		b.NewWaitForTool(),
+		b.NewUploadFileTool(),
 		b.NewResizeTool(),
 		b.NewRecentConsoleLogsTool(),
 		b.NewClearConsoleLogsTool(),
@@ -742,6 +766,9 @@ func (b *BrowseTools) readImageRun(ctx context.Context, m json.RawMessage) llm.T
 	}
 
 	detectedType := http.DetectContentType(imageData)
+	if detectedType == llm.MediaTypePDF {
+		return llm.ErrorfToolOut("file is a PDF document; use read_file to read it")
+	}
 	if !strings.HasPrefix(detectedType, "image/") {
 		return llm.ErrorfToolOut("file is not an image: %s", detectedType)
 	}
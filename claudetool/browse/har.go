@@ -0,0 +1,279 @@
+package browse
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+)
+
+// The HAR 1.2 format (http://www.softwareishard.com/blog/har-12-spec/), as
+// far as exporting the network log and replaying it need.
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int64          `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// buildHAR converts captured requests to a HAR file.
+func buildHAR(requests []*NetworkRequest) *harFile {
+	har := &harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "percy", Version: "1"},
+		Entries: []harEntry{},
+	}}
+	for _, req := range requests {
+		if strings.HasPrefix(req.URL, "data:") {
+			continue
+		}
+		e := harEntry{
+			StartedDateTime: req.wallTime.UTC().Format(time.RFC3339Nano),
+			Request: harRequest{
+				Method:      req.Method,
+				URL:         req.URL,
+				HTTPVersion: harHTTPVersion(req.protocol),
+				Headers:     harHeaders(req.requestHeaders),
+				QueryString: harQuery(req.URL),
+				Cookies:     []harNameValue{},
+				HeadersSize: -1,
+				BodySize:    len(req.postData),
+			},
+			Response: harResponse{
+				Status:      req.Status,
+				StatusText:  req.StatusText,
+				HTTPVersion: harHTTPVersion(req.protocol),
+				Headers:     harHeaders(req.responseHeaders),
+				Cookies:     []harNameValue{},
+				Content:     harContent{Size: len(req.body), MimeType: req.MimeType},
+				RedirectURL: headerValue(req.responseHeaders, "Location"),
+				HeadersSize: -1,
+				BodySize:    int(req.Size),
+			},
+		}
+		if req.postData != "" {
+			e.Request.PostData = &harPostData{MimeType: headerValue(req.requestHeaders, "Content-Type"), Text: req.postData}
+		}
+		if req.body != nil {
+			if utf8.Valid(req.body) {
+				e.Response.Content.Text = string(req.body)
+			} else {
+				e.Response.Content.Text = base64.StdEncoding.EncodeToString(req.body)
+				e.Response.Content.Encoding = "base64"
+			}
+		}
+		if req.EndTime > 0 {
+			e.Time = (req.EndTime - req.StartTime) * 1000
+			e.Timings.Wait = e.Time
+		}
+		har.Log.Entries = append(har.Log.Entries, e)
+	}
+	return har
+}
+
+func harHTTPVersion(protocol string) string {
+	switch protocol {
+	case "":
+		return "HTTP/1.1"
+	case "h2":
+		return "HTTP/2"
+	case "h3":
+		return "HTTP/3"
+	default:
+		return strings.ToUpper(protocol)
+	}
+}
+
+func harHeaders(h network.Headers) []harNameValue {
+	headers := []harNameValue{}
+	for name, v := range h {
+		// Repeated headers are joined with newlines.
+		for _, value := range strings.Split(fmt.Sprint(v), "\n") {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+func harQuery(rawURL string) []harNameValue {
+	query := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return query
+	}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			query = append(query, harNameValue{Name: name, Value: v})
+		}
+	}
+	sort.Slice(query, func(i, j int) bool { return query[i].Name < query[j].Name })
+	return query
+}
+
+func headerValue(h network.Headers, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+// harReplay serves requests from the entries of a HAR file.
+type harReplay struct {
+	path    string
+	offline bool // fail requests the HAR has no entry for
+
+	mu      sync.Mutex
+	entries map[string][]*harEntry // by replayKeys
+	served  map[string]int
+	hits    int
+	misses  int
+}
+
+// loadHARReplay reads a HAR file for replay.
+func loadHARReplay(path string, offline bool) (*harReplay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+	r := &harReplay{path: path, offline: offline, entries: map[string][]*harEntry{}, served: map[string]int{}}
+	for i := range har.Log.Entries {
+		e := &har.Log.Entries[i]
+		for _, key := range replayKeys(e.Request.Method, e.Request.URL) {
+			r.entries[key] = append(r.entries[key], e)
+		}
+	}
+	if len(har.Log.Entries) == 0 {
+		return nil, fmt.Errorf("HAR file %s has no entries", path)
+	}
+	return r, nil
+}
+
+// replayKeys returns the keys a request is matched by, most specific first:
+// method and URL, then method and URL without the query string.
+func replayKeys(method, rawURL string) []string {
+	rawURL, _, _ = strings.Cut(rawURL, "#")
+	base, _, _ := strings.Cut(rawURL, "?")
+	return []string{method + " " + rawURL, method + " ?" + base}
+}
+
+// match returns the HAR entry for a request. Repeated requests get the
+// entries recorded for the same request in order, then the last one again.
+func (r *harReplay) match(method, rawURL string) *harEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range replayKeys(method, rawURL) {
+		if entries := r.entries[key]; len(entries) > 0 {
+			i := min(r.served[key], len(entries)-1)
+			r.served[key]++
+			r.hits++
+			return entries[i]
+		}
+	}
+	r.misses++
+	return nil
+}
+
+// fulfillFromHAR returns the command that answers a paused request with a
+// recorded response.
+func fulfillFromHAR(id fetch.RequestID, e *harEntry) (*fetch.FulfillRequestParams, error) {
+	body := []byte(e.Response.Content.Text)
+	if e.Response.Content.Encoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(e.Response.Content.Text); err != nil {
+			return nil, fmt.Errorf("invalid base64 body for %s: %w", e.Request.URL, err)
+		}
+	}
+	var headers []*fetch.HeaderEntry
+	for _, h := range e.Response.Headers {
+		switch strings.ToLower(h.Name) {
+		case "content-encoding", "content-length", "transfer-encoding":
+			// The recorded body is decoded and complete.
+			continue
+		}
+		headers = append(headers, &fetch.HeaderEntry{Name: h.Name, Value: h.Value})
+	}
+	return fetch.FulfillRequest(id, e.Response.Status).
+		WithResponseHeaders(headers).
+		WithBody(base64.StdEncoding.EncodeToString(body)), nil
+}
@@ -0,0 +1,761 @@
+package browse
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// The interaction tools act on one element, found either by CSS selector or
+// by accessible name and/or role as in browser_accessibility's query action.
+// They use real input events, so pages see the same events as for a user,
+// and wait (up to the timeout) for the element to appear.
+
+// elementTarget identifies the element an interaction tool acts on.
+type elementTarget struct {
+	Selector string `json:"selector,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+}
+
+func (t elementTarget) empty() bool {
+	return t.Selector == "" && t.Name == "" && t.Role == ""
+}
+
+func (t elementTarget) String() string {
+	if t.Selector != "" {
+		return fmt.Sprintf("%q", t.Selector)
+	}
+	var parts []string
+	if t.Role != "" {
+		parts = append(parts, "["+t.Role+"]")
+	}
+	if t.Name != "" {
+		parts = append(parts, fmt.Sprintf("%q", t.Name))
+	}
+	return strings.Join(parts, " ")
+}
+
+// targetProperties are the schema properties of elementTarget.
+const targetProperties = `
+				"selector": {
+					"type": "string",
+					"description": "CSS selector of the element"
+				},
+				"name": {
+					"type": "string",
+					"description": "Accessible name of the element, instead of a selector"
+				},
+				"role": {
+					"type": "string",
+					"description": "ARIA role of the element (e.g. button, textbox, link), instead of or with name"
+				},
+				"timeout": {
+					"type": "string",
+					"description": "How long to wait for the element, as a Go duration string (default: 15s)"
+				}`
+
+// elementPollInterval is how often a missing element is looked for again.
+const elementPollInterval = 100 * time.Millisecond
+
+// findElement waits for the element identified by t and returns its backend
+// node ID. ctx must be a chromedp executor context.
+func findElement(ctx context.Context, t elementTarget) (cdp.BackendNodeID, error) {
+	if t.empty() {
+		return 0, errors.New("one of selector, name or role is required")
+	}
+	if t.Selector != "" {
+		var nodes []*cdp.Node
+		if err := chromedp.Nodes(t.Selector, &nodes, chromedp.ByQuery).Do(ctx); err != nil {
+			if ctx.Err() != nil {
+				return 0, fmt.Errorf("no element matches %s", t)
+			}
+			return 0, err
+		}
+		return nodes[0].BackendNodeID, nil
+	}
+	for {
+		nodes, err := queryAXTree(ctx, t.Name, t.Role)
+		if err != nil && ctx.Err() == nil {
+			return 0, err
+		}
+		for _, n := range nodes {
+			if !n.Ignored && n.BackendDOMNodeID != 0 {
+				return n.BackendDOMNodeID, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("no accessible element matches %s", t)
+		case <-time.After(elementPollInterval):
+		}
+	}
+}
+
+// callOnElement calls the JavaScript function fn with the element as this
+// and returns its result.
+func callOnElement(ctx context.Context, id cdp.BackendNodeID, fn string, args ...any) (json.RawMessage, error) {
+	obj, err := dom.ResolveNode().WithBackendNodeID(id).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve element: %w", err)
+	}
+	defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+
+	var callArgs []*runtime.CallArgument
+	for _, arg := range args {
+		v, err := json.Marshal(arg)
+		if err != nil {
+			return nil, err
+		}
+		callArgs = append(callArgs, &runtime.CallArgument{Value: v})
+	}
+	res, exc, err := runtime.CallFunctionOn(fn).
+		WithObjectID(obj.ObjectID).
+		WithArguments(callArgs).
+		WithReturnByValue(true).
+		WithAwaitPromise(true).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exc != nil {
+		if exc.Exception != nil && exc.Exception.Description != "" {
+			return nil, errors.New(exc.Exception.Description)
+		}
+		return nil, errors.New(exc.Text)
+	}
+	return json.RawMessage(res.Value), nil
+}
+
+// runOnElement finds the element identified by t and runs fn on it, with
+// the timeout of t.
+func (b *BrowseTools) runOnElement(t elementTarget, fn func(ctx context.Context, id cdp.BackendNodeID) error) error {
+	browserCtx, err := b.GetBrowserContext()
+	if err != nil {
+		return err
+	}
+	timeoutCtx, cancel := context.WithTimeout(browserCtx, parseTimeout(t.Timeout))
+	defer cancel()
+	return chromedp.Run(timeoutCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+		id, err := findElement(ctx, t)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, id)
+	}))
+}
+
+// ClickTool definition
+type clickInput struct {
+	elementTarget
+	Button      string `json:"button,omitempty"`
+	DoubleClick bool   `json:"double_click,omitempty"`
+}
+
+// NewClickTool creates a tool for clicking elements
+func (b *BrowseTools) NewClickTool() *llm.Tool {
+	return &llm.Tool{
+		Name:        "browser_click",
+		Description: "Click an element, found by CSS selector or by accessible name/role, with real mouse events. Scrolls the element into view and waits for it to appear.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {` + targetProperties + `,
+				"button": {
+					"type": "string",
+					"enum": ["left", "right", "middle"],
+					"description": "Mouse button (default: left)"
+				},
+				"double_click": {
+					"type": "boolean",
+					"description": "Double-click instead of clicking once"
+				}
+			}
+		}`),
+		Run: b.clickRun,
+	}
+}
+
+func (b *BrowseTools) clickRun(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var in clickInput
+	if err := json.Unmarshal(m, &in); err != nil {
+		return llm.ErrorfToolOut("invalid input: %w", err)
+	}
+	button := input.Left
+	switch in.Button {
+	case "", "left":
+	case "right":
+		button = input.Right
+	case "middle":
+		button = input.Middle
+	default:
+		return llm.ErrorfToolOut("unknown button %q (use left, right or middle)", in.Button)
+	}
+	clicks := 1
+	if in.DoubleClick {
+		clicks = 2
+	}
+
+	err := b.runOnElement(in.elementTarget, func(ctx context.Context, id cdp.BackendNodeID) error {
+		if err := dom.ScrollIntoViewIfNeeded().WithBackendNodeID(id).Do(ctx); err != nil {
+			return fmt.Errorf("failed to scroll element into view: %w", err)
+		}
+		quads, err := dom.GetContentQuads().WithBackendNodeID(id).Do(ctx)
+		if err != nil || len(quads) == 0 {
+			return fmt.Errorf("element %s is not visible", in.elementTarget)
+		}
+		x, y := quadCenter(quads[0])
+		return chromedp.MouseClickXY(x, y, chromedp.ButtonType(button), chromedp.ClickCount(clicks)).Do(ctx)
+	})
+	if err != nil {
+		return llm.ErrorfToolOut("failed to click %s: %w", in.elementTarget, err)
+	}
+	return b.toolOutWithDownloads(fmt.Sprintf("clicked %s", in.elementTarget))
+}
+
+// quadCenter returns the center of a quad of four x, y points.
+func quadCenter(q dom.Quad) (x, y float64) {
+	for i := 0; i+1 < len(q); i += 2 {
+		x += q[i]
+		y += q[i+1]
+	}
+	n := float64(len(q) / 2)
+	return x / n, y / n
+}
+
+// TypeTool definition
+type typeInput struct {
+	elementTarget
+	Text   string `json:"text"`
+	Clear  *bool  `json:"clear,omitempty"`
+	Submit bool   `json:"submit,omitempty"`
+}
+
+// NewTypeTool creates a tool for typing into elements
+func (b *BrowseTools) NewTypeTool() *llm.Tool {
+	return &llm.Tool{
+		Name:        "browser_type",
+		Description: "Type text into an input, textarea or contenteditable element, found by CSS selector or by accessible name/role. Sends real key events, so it works with React and other framework-controlled inputs.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {` + targetProperties + `,
+				"text": {
+					"type": "string",
+					"description": "Text to type"
+				},
+				"clear": {
+					"type": "boolean",
+					"description": "Replace the element's current content (default: true)"
+				},
+				"submit": {
+					"type": "boolean",
+					"description": "Press Enter after typing"
+				}
+			},
+			"required": ["text"]
+		}`),
+		Run: b.typeRun,
+	}
+}
+
+// selectContentsJS selects an editable element's content, so that typing
+// replaces it.
+const selectContentsJS = `function() {
+	if (typeof this.select === "function") {
+		this.select();
+	} else {
+		window.getSelection().selectAllChildren(this);
+	}
+}`
+
+func (b *BrowseTools) typeRun(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var in typeInput
+	if err := json.Unmarshal(m, &in); err != nil {
+		return llm.ErrorfToolOut("invalid input: %w", err)
+	}
+	clear := in.Clear == nil || *in.Clear
+
+	err := b.runOnElement(in.elementTarget, func(ctx context.Context, id cdp.BackendNodeID) error {
+		if err := dom.ScrollIntoViewIfNeeded().WithBackendNodeID(id).Do(ctx); err != nil {
+			return fmt.Errorf("failed to scroll element into view: %w", err)
+		}
+		if err := dom.Focus().WithBackendNodeID(id).Do(ctx); err != nil {
+			return fmt.Errorf("element cannot be focused: %w", err)
+		}
+		if clear {
+			if _, err := callOnElement(ctx, id, selectContentsJS); err != nil {
+				return err
+			}
+			if err := chromedp.KeyEvent(kb.Backspace).Do(ctx); err != nil {
+				return err
+			}
+		}
+		if err := chromedp.KeyEvent(in.Text).Do(ctx); err != nil {
+			return err
+		}
+		if in.Submit {
+			return chromedp.KeyEvent(kb.Enter).Do(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return llm.ErrorfToolOut("failed to type into %s: %w", in.elementTarget, err)
+	}
+	msg := fmt.Sprintf("typed %d characters into %s", len([]rune(in.Text)), in.elementTarget)
+	if in.Submit {
+		msg += " and pressed Enter"
+	}
+	return b.toolOutWithDownloads(msg)
+}
+
+// SelectTool definition
+type selectInput struct {
+	elementTarget
+	Values []string `json:"values"`
+}
+
+// NewSelectTool creates a tool for choosing options of select elements
+func (b *BrowseTools) NewSelectTool() *llm.Tool {
+	return &llm.Tool{
+		Name:        "browser_select",
+		Description: "Choose options of a <select> element, found by CSS selector or by accessible name/role, by value or visible label. Fires input and change events.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {` + targetProperties + `,
+				"values": {
+					"type": "array",
+					"items": {"type": "string"},
+					"description": "Values or labels of the options to choose; more than one only for multiple selects"
+				}
+			},
+			"required": ["values"]
+		}`),
+		Run: b.selectRun,
+	}
+}
+
+// selectOptionsJS chooses the options matching values by value or label and
+// returns the labels of the chosen options.
+const selectOptionsJS = `function(values) {
+	if (this.tagName !== "SELECT") {
+		throw new Error("element is a <" + this.tagName.toLowerCase() + ">, not a <select>");
+	}
+	if (values.length > 1 && !this.multiple) {
+		throw new Error("cannot choose " + values.length + " options of a single select");
+	}
+	const options = Array.from(this.options);
+	const chosen = values.map(v => {
+		const o = options.find(o => o.value === v) || options.find(o => o.label.trim() === v.trim());
+		if (!o) {
+			throw new Error("no option " + JSON.stringify(v) + "; options are " + JSON.stringify(options.map(o => o.label)));
+		}
+		return o;
+	});
+	for (const o of options) {
+		o.selected = chosen.includes(o);
+	}
+	this.dispatchEvent(new Event("input", {bubbles: true}));
+	this.dispatchEvent(new Event("change", {bubbles: true}));
+	return chosen.map(o => o.label);
+}`
+
+func (b *BrowseTools) selectRun(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var in selectInput
+	if err := json.Unmarshal(m, &in); err != nil {
+		return llm.ErrorfToolOut("invalid input: %w", err)
+	}
+	if len(in.Values) == 0 {
+		return llm.ErrorfToolOut("values is required")
+	}
+
+	var labels []string
+	err := b.runOnElement(in.elementTarget, func(ctx context.Context, id cdp.BackendNodeID) error {
+		res, err := callOnElement(ctx, id, selectOptionsJS, in.Values)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(res, &labels)
+	})
+	if err != nil {
+		return llm.ErrorfToolOut("failed to select in %s: %w", in.elementTarget, err)
+	}
+	return b.toolOutWithDownloads(fmt.Sprintf("selected %s in %s", strings.Join(quoteAll(labels), ", "), in.elementTarget))
+}
+
+func quoteAll(ss []string) []string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return quoted
+}
+
+// PressKeyTool definition
+type pressKeyInput struct {
+	elementTarget
+	Key string `json:"key"`
+}
+
+// NewPressKeyTool creates a tool for pressing keys
+func (b *BrowseTools) NewPressKeyTool() *llm.Tool {
+	return &llm.Tool{
+		Name:        "browser_press_key",
+		Description: `Press a key or key combination, such as "Enter", "Escape", "ArrowDown", "Tab" or "Control+a", in the focused element or, if a target is given, after focusing it.`,
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"key": {
+					"type": "string",
+					"description": "Key name (Enter, Tab, Escape, Backspace, Delete, ArrowUp, ArrowDown, ArrowLeft, ArrowRight, Home, End, PageUp, PageDown, Space, F1-F12) or character, with optional modifiers joined by +: Control, Shift, Alt, Meta"
+				},` + targetProperties + `
+			},
+			"required": ["key"]
+		}`),
+		Run: b.pressKeyRun,
+	}
+}
+
+// keyNames maps DOM key names, such as "Enter" and "ArrowDown", to the
+// runes the kb package encodes them as.
+var keyNames = sync.OnceValue(func() map[string]rune {
+	names := map[string]rune{"Space": ' '}
+	for r, k := range kb.Keys {
+		if _, ok := names[k.Key]; !ok && k.Key != "" && r != '\n' {
+			names[k.Key] = r
+		}
+	}
+	return names
+})
+
+var keyModifiers = map[string]input.Modifier{
+	"control": input.ModifierCtrl,
+	"ctrl":    input.ModifierCtrl,
+	"shift":   input.ModifierShift,
+	"alt":     input.ModifierAlt,
+	"option":  input.ModifierAlt,
+	"meta":    input.ModifierMeta,
+	"cmd":     input.ModifierMeta,
+	"command": input.ModifierMeta,
+}
+
+// parseKey parses a key combination such as "Control+Shift+K".
+func parseKey(combo string) (rune, input.Modifier, error) {
+	parts := strings.Split(combo, "+")
+	if n := len(parts); n > 1 && parts[n-1] == "" {
+		// "Control++" presses the + key.
+		parts = append(parts[:n-2], "+")
+	}
+	var mods input.Modifier
+	for _, p := range parts[:len(parts)-1] {
+		mod, ok := keyModifiers[strings.ToLower(p)]
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown modifier %q (use Control, Shift, Alt or Meta)", p)
+		}
+		mods |= mod
+	}
+	key := parts[len(parts)-1]
+	if r, ok := keyNames()[key]; ok {
+		return r, mods, nil
+	}
+	if rs := []rune(key); len(rs) == 1 {
+		return rs[0], mods, nil
+	}
+	return 0, 0, fmt.Errorf("unknown key %q", key)
+}
+
+func (b *BrowseTools) pressKeyRun(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var in pressKeyInput
+	if err := json.Unmarshal(m, &in); err != nil {
+		return llm.ErrorfToolOut("invalid input: %w", err)
+	}
+	r, mods, err := parseKey(in.Key)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	press := func(ctx context.Context) error {
+		for _, ev := range kb.Encode(r) {
+			if ev.Type == input.KeyChar && mods&^input.ModifierShift != 0 {
+				// Shortcuts such as Control+a don't insert text.
+				continue
+			}
+			ev.Modifiers |= mods
+			if err := ev.Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if in.empty() {
+		browserCtx, err := b.GetBrowserContext()
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		timeoutCtx, cancel := context.WithTimeout(browserCtx, parseTimeout(in.Timeout))
+		defer cancel()
+		err = chromedp.Run(timeoutCtx, chromedp.ActionFunc(press))
+	} else {
+		err = b.runOnElement(in.elementTarget, func(ctx context.Context, id cdp.BackendNodeID) error {
+			if err := dom.Focus().WithBackendNodeID(id).Do(ctx); err != nil {
+				return fmt.Errorf("element cannot be focused: %w", err)
+			}
+			return press(ctx)
+		})
+	}
+	if err != nil {
+		return llm.ErrorfToolOut("failed to press %s: %w", in.Key, err)
+	}
+	return b.toolOutWithDownloads(fmt.Sprintf("pressed %s", in.Key))
+}
+
+// WaitForTool definition
+type waitForInput struct {
+	Selector    string `json:"selector,omitempty"`
+	State       string `json:"state,omitempty"`
+	Text        string `json:"text,omitempty"`
+	NetworkIdle bool   `json:"network_idle,omitempty"`
+	Timeout     string `json:"timeout,omitempty"`
+}
+
+// networkIdleTime is how long without network requests counts as idle.
+const networkIdleTime = 500 * time.Millisecond
+
+// NewWaitForTool creates a tool for waiting for page conditions
+func (b *BrowseTools) NewWaitForTool() *llm.Tool {
+	return &llm.Tool{
+		Name:        "browser_wait_for",
+		Description: "Wait until an element matching a CSS selector appears (or disappears), text appears on the page, or the network is idle. Give exactly one condition.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"selector": {
+					"type": "string",
+					"description": "CSS selector of the element to wait for"
+				},
+				"state": {
+					"type": "string",
+					"enum": ["visible", "attached", "hidden"],
+					"description": "For selector: wait until the element is visible (default), in the DOM, or gone or hidden"
+				},
+				"text": {
+					"type": "string",
+					"description": "Text to wait for in the page's visible text"
+				},
+				"network_idle": {
+					"type": "boolean",
+					"description": "Wait until no network requests are in flight for 500ms"
+				},
+				"timeout": {
+					"type": "string",
+					"description": "Timeout as a Go duration string (default: 15s)"
+				}
+			}
+		}`),
+		Run: b.waitForRun,
+	}
+}
+
+func (b *BrowseTools) waitForRun(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var in waitForInput
+	if err := json.Unmarshal(m, &in); err != nil {
+		return llm.ErrorfToolOut("invalid input: %w", err)
+	}
+	conditions := 0
+	for _, set := range []bool{in.Selector != "", in.Text != "", in.NetworkIdle} {
+		if set {
+			conditions++
+		}
+	}
+	if conditions != 1 {
+		return llm.ErrorfToolOut("give exactly one of selector, text or network_idle")
+	}
+
+	browserCtx, err := b.GetBrowserContext()
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	timeoutCtx, cancel := context.WithTimeout(browserCtx, parseTimeout(in.Timeout))
+	defer cancel()
+
+	var action chromedp.Action
+	var what string
+	switch {
+	case in.Selector != "":
+		what = fmt.Sprintf("%q to be %s", in.Selector, cmp.Or(in.State, "visible"))
+		switch in.State {
+		case "", "visible":
+			action = chromedp.WaitVisible(in.Selector, chromedp.ByQuery)
+		case "attached":
+			action = chromedp.WaitReady(in.Selector, chromedp.ByQuery)
+		case "hidden":
+			action = waitHidden(in.Selector)
+		default:
+			return llm.ErrorfToolOut("unknown state %q (use visible, attached or hidden)", in.State)
+		}
+	case in.Text != "":
+		what = fmt.Sprintf("text %q", in.Text)
+		action = waitForText(in.Text)
+	default:
+		what = "network idle"
+		action = waitNetworkIdle(timeoutCtx)
+	}
+
+	start := time.Now()
+	if err := chromedp.Run(timeoutCtx, action); err != nil {
+		if timeoutCtx.Err() != nil {
+			return llm.ErrorfToolOut("timed out waiting for %s", what)
+		}
+		return llm.ErrorfToolOut("failed waiting for %s: %w", what, err)
+	}
+	return b.toolOutWithDownloads(fmt.Sprintf("waited %s for %s", time.Since(start).Round(time.Millisecond), what))
+}
+
+// pollJS evaluates a boolean JavaScript expression until it is true.
+func pollJS(expr string) chromedp.ActionFunc {
+	return func(ctx context.Context) error {
+		for {
+			var ok bool
+			if err := chromedp.Evaluate(expr, &ok).Do(ctx); err != nil && ctx.Err() == nil {
+				return err
+			}
+			if ok {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(elementPollInterval):
+			}
+		}
+	}
+}
+
+func waitForText(text string) chromedp.Action {
+	quoted, _ := json.Marshal(text)
+	return pollJS(fmt.Sprintf(`!!document.body && document.body.innerText.includes(%s)`, quoted))
+}
+
+func waitHidden(selector string) chromedp.Action {
+	quoted, _ := json.Marshal(selector)
+	return pollJS(fmt.Sprintf(`(() => {
+		const el = document.querySelector(%s);
+		if (!el) return true;
+		const style = getComputedStyle(el);
+		return style.display === "none" || style.visibility === "hidden" || el.getClientRects().length === 0;
+	})()`, quoted))
+}
+
+// waitNetworkIdle waits until no requests have been in flight for
+// networkIdleTime. Requests sent before the wait started are not counted.
+func waitNetworkIdle(browserCtx context.Context) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := network.Enable().Do(ctx); err != nil {
+			return err
+		}
+
+		var mu sync.Mutex
+		inflight := make(map[network.RequestID]bool)
+		lastActivity := time.Now()
+		listenCtx, cancel := context.WithCancel(browserCtx)
+		defer cancel()
+		chromedp.ListenTarget(listenCtx, func(ev any) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch e := ev.(type) {
+			case *network.EventRequestWillBeSent:
+				inflight[e.RequestID] = true
+			case *network.EventLoadingFinished:
+				delete(inflight, e.RequestID)
+			case *network.EventLoadingFailed:
+				delete(inflight, e.RequestID)
+			default:
+				return
+			}
+			lastActivity = time.Now()
+		})
+
+		for {
+			mu.Lock()
+			idle := len(inflight) == 0 && time.Since(lastActivity) >= networkIdleTime
+			mu.Unlock()
+			if idle {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(elementPollInterval):
+			}
+		}
+	})
+}
+
+// UploadFileTool definition
+type uploadFileInput struct {
+	elementTarget
+	Paths []string `json:"paths"`
+}
+
+// NewUploadFileTool creates a tool for setting the files of file inputs
+func (b *BrowseTools) NewUploadFileTool() *llm.Tool {
+	return &llm.Tool{
+		Name:        "browser_upload_file",
+		Description: `Set the files of an <input type="file"> element, found by CSS selector or by accessible name/role, as if the user had chosen them.`,
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {` + targetProperties + `,
+				"paths": {
+					"type": "array",
+					"items": {"type": "string"},
+					"description": "Absolute paths of the files to upload"
+				}
+			},
+			"required": ["paths"]
+		}`),
+		Run: b.uploadFileRun,
+	}
+}
+
+func (b *BrowseTools) uploadFileRun(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var in uploadFileInput
+	if err := json.Unmarshal(m, &in); err != nil {
+		return llm.ErrorfToolOut("invalid input: %w", err)
+	}
+	if len(in.Paths) == 0 {
+		return llm.ErrorfToolOut("paths is required")
+	}
+	for _, p := range in.Paths {
+		if !filepath.IsAbs(p) {
+			return llm.ErrorfToolOut("path %q is not absolute", p)
+		}
+		if info, err := os.Stat(p); err != nil {
+			return llm.ErrorToolOut(err)
+		} else if info.IsDir() {
+			return llm.ErrorfToolOut("%s is a directory", p)
+		}
+	}
+
+	err := b.runOnElement(in.elementTarget, func(ctx context.Context, id cdp.BackendNodeID) error {
+		return dom.SetFileInputFiles(in.Paths).WithBackendNodeID(id).Do(ctx)
+	})
+	if err != nil {
+		return llm.ErrorfToolOut("failed to upload to %s: %w", in.elementTarget, err)
+	}
+	return b.toolOutWithDownloads(fmt.Sprintf("set %d file(s) on %s", len(in.Paths), in.elementTarget))
+}
@@ -0,0 +1,162 @@
+package browse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/chromedp/kb"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		combo string
+		key   string
+		mods  input.Modifier
+	}{
+		{"Enter", kb.Enter, 0},
+		{"ArrowDown", kb.ArrowDown, 0},
+		{"Space", " ", 0},
+		{"a", "a", 0},
+		{"Control+a", "a", input.ModifierCtrl},
+		{"ctrl+Shift+K", "K", input.ModifierCtrl | input.ModifierShift},
+		{"Meta++", "+", input.ModifierMeta},
+	}
+	for _, tt := range tests {
+		key, mods, err := parseKey(tt.combo)
+		if err != nil {
+			t.Errorf("parseKey(%q): %v", tt.combo, err)
+			continue
+		}
+		if string(key) != tt.key || mods != tt.mods {
+			t.Errorf("parseKey(%q) = %q, %v; want %q, %v", tt.combo, key, mods, tt.key, tt.mods)
+		}
+	}
+	for _, combo := range []string{"Hyper+a", "NotAKey"} {
+		if _, _, err := parseKey(combo); err == nil {
+			t.Errorf("parseKey(%q) succeeded", combo)
+		}
+	}
+}
+
+func TestQuadCenter(t *testing.T) {
+	x, y := quadCenter(dom.Quad{10, 20, 30, 20, 30, 40, 10, 40})
+	if x != 20 || y != 30 {
+		t.Errorf("quadCenter() = %v, %v; want 20, 30", x, y)
+	}
+}
+
+func TestInteractionInputErrors(t *testing.T) {
+	tools := NewBrowseTools(context.Background(), 0, 0)
+	t.Cleanup(tools.Close)
+
+	tests := []struct {
+		tool  *llm.Tool
+		input string
+		want  string
+	}{
+		{tools.NewClickTool(), `{"selector": "#a", "button": "fourth"}`, "unknown button"},
+		{tools.NewSelectTool(), `{"selector": "#a", "values": []}`, "values is required"},
+		{tools.NewPressKeyTool(), `{"key": "Hyper+a"}`, "unknown modifier"},
+		{tools.NewWaitForTool(), `{"selector": "#a", "text": "hi"}`, "exactly one"},
+		{tools.NewWaitForTool(), `{}`, "exactly one"},
+		{tools.NewUploadFileTool(), `{"selector": "#a", "paths": ["relative.txt"]}`, "not absolute"},
+	}
+	for _, tt := range tests {
+		out := tt.tool.Run(context.Background(), json.RawMessage(tt.input))
+		if out.Error == nil || !strings.Contains(out.Error.Error(), tt.want) {
+			t.Errorf("%s %s: error = %v, want one containing %q", tt.tool.Name, tt.input, out.Error, tt.want)
+		}
+	}
+}
+
+const interactPage = `<!DOCTYPE html>
+<html><body>
+<label for="name">Your name</label>
+<input id="name" value="placeholder">
+<select id="color"><option value="r">Red</option><option value="g">Green</option></select>
+<input id="file" type="file">
+<button id="go" onclick="go()">Save</button>
+<div id="out"></div>
+<script>
+document.getElementById("name").addEventListener("keydown", e => {
+	if (e.key === "Enter") document.getElementById("out").dataset.enter = "yes";
+});
+function go() {
+	setTimeout(() => {
+		const f = document.getElementById("file").files[0];
+		document.getElementById("out").textContent = "Saved " +
+			document.getElementById("name").value + " " +
+			document.getElementById("color").value + " " + (f ? f.name : "") + " " +
+			(document.getElementById("out").dataset.enter || "");
+	}, 200);
+}
+</script>
+</body></html>`
+
+func TestInteractionTools(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping browser interaction test in short mode")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, interactPage)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	tools := NewBrowseTools(ctx, 0, 0)
+	t.Cleanup(tools.Close)
+
+	out := tools.NewNavigateTool().Run(ctx, json.RawMessage(fmt.Sprintf(`{"url": %q}`, server.URL)))
+	if out.Error != nil {
+		if strings.Contains(out.Error.Error(), "failed to start browser") {
+			t.Skip("Browser automation not available in this environment")
+		}
+		t.Fatal(out.Error)
+	}
+
+	upload := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(upload, []byte("notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		tool  *llm.Tool
+		input string
+	}{
+		{tools.NewTypeTool(), `{"role": "textbox", "name": "Your name", "text": "Ada"}`},
+		{tools.NewPressKeyTool(), `{"selector": "#name", "key": "Enter"}`},
+		{tools.NewSelectTool(), `{"selector": "#color", "values": ["Green"]}`},
+		{tools.NewUploadFileTool(), fmt.Sprintf(`{"selector": "#file", "paths": [%q]}`, upload)},
+		{tools.NewClickTool(), `{"role": "button", "name": "Save"}`},
+		{tools.NewWaitForTool(), `{"text": "Saved"}`},
+	}
+	for _, step := range steps {
+		if out := step.tool.Run(ctx, json.RawMessage(step.input)); out.Error != nil {
+			t.Fatalf("%s %s: %v", step.tool.Name, step.input, out.Error)
+		}
+	}
+
+	out = tools.NewEvalTool().Run(ctx, json.RawMessage(`{"expression": "document.getElementById('out').textContent"}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if got := out.LLMContent[0].Text; !strings.Contains(got, "Saved Ada g notes.txt yes") {
+		t.Errorf("page state = %s", got)
+	}
+
+	out = tools.NewWaitForTool().Run(ctx, json.RawMessage(`{"selector": "#missing", "timeout": "300ms"}`))
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "timed out") {
+		t.Errorf("waiting for a missing element: %v", out.Error)
+	}
+}
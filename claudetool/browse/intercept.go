@@ -0,0 +1,240 @@
+package browse
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// interceptRule answers requests matching a URL pattern with a fixed
+// response, delays them, or aborts them.
+type interceptRule struct {
+	ID         int               `json:"id"`
+	URLPattern string            `json:"url_pattern"`
+	Method     string            `json:"method,omitempty"`
+	Status     int64             `json:"status,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	BodyFile   string            `json:"body_file,omitempty"`
+	Delay      string            `json:"delay,omitempty"`
+	Abort      bool              `json:"abort,omitempty"`
+	Hits       int               `json:"hits"`
+
+	re    *regexp.Regexp
+	delay time.Duration
+	body  []byte
+	mock  bool // fulfill rather than continue
+}
+
+// globRegexp compiles a URL pattern in which * matches any characters and
+// ? matches one.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// newInterceptRule validates input and loads the rule's body.
+func newInterceptRule(input networkInput) (*interceptRule, error) {
+	if input.URLPattern == "" {
+		return nil, fmt.Errorf("url_pattern is required")
+	}
+	re, err := globRegexp(input.URLPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url_pattern: %w", err)
+	}
+	rule := &interceptRule{
+		URLPattern: input.URLPattern,
+		Method:     strings.ToUpper(input.Method),
+		Status:     input.Status,
+		Headers:    input.Headers,
+		BodyFile:   input.BodyFile,
+		Delay:      input.Delay,
+		Abort:      input.Abort,
+		re:         re,
+	}
+	if input.Delay != "" {
+		if rule.delay, err = time.ParseDuration(input.Delay); err != nil || rule.delay < 0 {
+			return nil, fmt.Errorf("invalid delay %q", input.Delay)
+		}
+	}
+	if input.Body != "" && input.BodyFile != "" {
+		return nil, fmt.Errorf("give body or body_file, not both")
+	}
+	rule.mock = input.Status != 0 || input.Body != "" || input.BodyFile != "" || len(input.Headers) > 0
+	if rule.Abort && rule.mock {
+		return nil, fmt.Errorf("abort cannot be combined with a response")
+	}
+	if !rule.Abort && !rule.mock && rule.delay == 0 {
+		return nil, fmt.Errorf("a rule needs a response (status, headers, body or body_file), a delay, or abort")
+	}
+	if rule.mock && rule.Status == 0 {
+		rule.Status = http.StatusOK
+	}
+	if rule.Status != 0 && (rule.Status < 100 || rule.Status > 599) {
+		return nil, fmt.Errorf("invalid status %d", rule.Status)
+	}
+
+	rule.body = []byte(input.Body)
+	if input.BodyFile != "" {
+		if rule.body, err = os.ReadFile(input.BodyFile); err != nil {
+			return nil, fmt.Errorf("failed to read body_file: %w", err)
+		}
+	}
+	if rule.mock && !hasHeader(rule.Headers, "Content-Type") && len(rule.body) > 0 {
+		contentType := mime.TypeByExtension(filepath.Ext(input.BodyFile))
+		if contentType == "" {
+			contentType = http.DetectContentType(rule.body)
+		}
+		rule.Headers = withHeader(rule.Headers, "Content-Type", contentType)
+	}
+	return rule, nil
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func withHeader(headers map[string]string, name, value string) map[string]string {
+	h := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		h[k] = v
+	}
+	h[name] = value
+	return h
+}
+
+func (r *interceptRule) matches(method, rawURL string) bool {
+	if r.Method != "" && r.Method != method {
+		return false
+	}
+	rawURL, _, _ = strings.Cut(rawURL, "#")
+	return r.re.MatchString(rawURL)
+}
+
+func (r *interceptRule) String() string {
+	var action string
+	switch {
+	case r.Abort:
+		action = "abort"
+	case r.mock:
+		action = fmt.Sprintf("respond %d", r.Status)
+		if r.BodyFile != "" {
+			action += " with " + r.BodyFile
+		} else if len(r.body) > 0 {
+			action += fmt.Sprintf(" with %d-byte body", len(r.body))
+		}
+	default:
+		action = "continue"
+	}
+	if r.delay > 0 {
+		action = fmt.Sprintf("after %s, %s", r.delay, action)
+	}
+	method := r.Method
+	if method == "" {
+		method = "*"
+	}
+	return fmt.Sprintf("#%d %s %s: %s (%d hits)", r.ID, method, r.URLPattern, action, r.Hits)
+}
+
+// interceptionActive reports whether any requests need to be paused.
+func (b *BrowseTools) interceptionActive() bool {
+	b.interceptMutex.Lock()
+	defer b.interceptMutex.Unlock()
+	return len(b.interceptRules) > 0 || b.replay != nil
+}
+
+// syncFetch enables the Fetch domain, which pauses every request until it
+// is handled by handleRequestPaused, while there are rules or a replay, and
+// disables it otherwise.
+func (b *BrowseTools) syncFetch(browserCtx context.Context) error {
+	if b.interceptionActive() {
+		return chromedp.Run(browserCtx, fetch.Enable().WithPatterns([]*fetch.RequestPattern{{URLPattern: "*"}}))
+	}
+	return chromedp.Run(browserCtx, fetch.Disable())
+}
+
+// handleRequestPaused answers a request paused by the Fetch domain: from
+// the most recently added matching rule, then from the HAR being replayed,
+// and otherwise by letting it continue (or failing it in offline replay).
+func (b *BrowseTools) handleRequestPaused(browserCtx context.Context, e *fetch.EventRequestPaused) {
+	method, rawURL := e.Request.Method, e.Request.URL
+
+	b.interceptMutex.Lock()
+	var rule *interceptRule
+	for i := len(b.interceptRules) - 1; i >= 0; i-- {
+		if b.interceptRules[i].matches(method, rawURL) {
+			rule = b.interceptRules[i]
+			rule.Hits++
+			break
+		}
+	}
+	replay := b.replay
+	b.interceptMutex.Unlock()
+
+	var action chromedp.Action = fetch.ContinueRequest(e.RequestID)
+	switch {
+	case rule != nil:
+		if rule.delay > 0 {
+			select {
+			case <-time.After(rule.delay):
+			case <-browserCtx.Done():
+				return
+			}
+		}
+		if rule.Abort {
+			action = fetch.FailRequest(e.RequestID, network.ErrorReasonFailed)
+		} else if rule.mock {
+			var headers []*fetch.HeaderEntry
+			for name, value := range rule.Headers {
+				headers = append(headers, &fetch.HeaderEntry{Name: name, Value: value})
+			}
+			action = fetch.FulfillRequest(e.RequestID, rule.Status).
+				WithResponseHeaders(headers).
+				WithBody(base64.StdEncoding.EncodeToString(rule.body))
+		}
+	case replay != nil:
+		if entry := replay.match(method, rawURL); entry != nil {
+			if entry.Response.Status == 0 {
+				action = fetch.FailRequest(e.RequestID, network.ErrorReasonFailed)
+			} else if fulfill, err := fulfillFromHAR(e.RequestID, entry); err != nil {
+				log.Printf("HAR replay: %v", err)
+				action = fetch.FailRequest(e.RequestID, network.ErrorReasonFailed)
+			} else {
+				action = fulfill
+			}
+		} else if replay.offline && !strings.HasPrefix(rawURL, "data:") {
+			action = fetch.FailRequest(e.RequestID, network.ErrorReasonInternetDisconnected)
+		}
+	}
+	if err := chromedp.Run(browserCtx, action); err != nil && browserCtx.Err() == nil {
+		log.Printf("Failed to handle intercepted request %s: %v", rawURL, err)
+	}
+}
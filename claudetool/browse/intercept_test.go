@@ -0,0 +1,216 @@
+package browse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern, url string
+		want         bool
+	}{
+		{"*/api/users*", "http://localhost:3000/api/users?page=2", true},
+		{"*/api/users*", "http://localhost:3000/api/items", false},
+		{"http://example.com/a.js", "http://example.com/a.js", true},
+		{"http://example.com/a.js", "http://example.com/a_js", false},
+		{"*/v?/items", "https://x.dev/v2/items", true},
+	}
+	for _, tt := range tests {
+		re, err := globRegexp(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := re.MatchString(tt.url); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.pattern, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestNewInterceptRule(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(bodyFile, []byte(`[{"name": "Ada"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rule, err := newInterceptRule(networkInput{URLPattern: "*/api/users", Method: "get", BodyFile: bodyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Status != 200 || rule.Headers["Content-Type"] != "application/json" || string(rule.body) != `[{"name": "Ada"}]` {
+		t.Errorf("rule = %+v", rule)
+	}
+	if !rule.matches("GET", "http://localhost/api/users#top") || rule.matches("POST", "http://localhost/api/users") {
+		t.Error("rule matches the wrong requests")
+	}
+
+	for _, input := range []networkInput{
+		{},
+		{URLPattern: "*"},
+		{URLPattern: "*", Abort: true, Status: 500},
+		{URLPattern: "*", Body: "x", BodyFile: bodyFile},
+		{URLPattern: "*", Delay: "soon"},
+		{URLPattern: "*", Status: 42},
+		{URLPattern: "*", BodyFile: "/no/such/file"},
+	} {
+		if _, err := newInterceptRule(input); err == nil {
+			t.Errorf("newInterceptRule(%+v) succeeded", input)
+		}
+	}
+}
+
+func TestHARRoundTrip(t *testing.T) {
+	requests := []*NetworkRequest{
+		{
+			URL: "http://localhost/api/items?page=1", Method: "GET", Status: 200, StatusText: "OK", MimeType: "application/json",
+			StartTime: 1, EndTime: 1.25,
+			wallTime:        time.Unix(1700000000, 0),
+			responseHeaders: network.Headers{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+			body:            []byte(`{"items": [1]}`),
+		},
+		{
+			URL: "http://localhost/api/items?page=2", Method: "GET", Status: 200,
+			body: []byte(`{"items": [2]}`),
+		},
+		{URL: "http://localhost/logo.png", Method: "GET", Status: 200, body: []byte{0x89, 'P', 'N', 'G', 0xff}},
+		{URL: "data:text/plain,hi", Method: "GET"},
+	}
+	har := buildHAR(requests)
+	if len(har.Log.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3 (data: URLs are skipped)", len(har.Log.Entries))
+	}
+	if e := har.Log.Entries[0]; e.Time != 250 || e.StartedDateTime != "2023-11-14T22:13:20Z" || len(e.Request.QueryString) != 1 {
+		t.Errorf("entry = %+v", e)
+	}
+	if c := har.Log.Entries[2].Response.Content; c.Encoding != "base64" {
+		t.Errorf("binary content = %+v", c)
+	}
+
+	path := filepath.Join(t.TempDir(), "log.har")
+	data, err := json.Marshal(har)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := loadHARReplay(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := func(url string) string {
+		e := replay.match("GET", url)
+		if e == nil {
+			return ""
+		}
+		return e.Response.Content.Text
+	}
+	if got := match("http://localhost/api/items?page=2"); got != `{"items": [2]}` {
+		t.Errorf("exact match = %q", got)
+	}
+	// Without an exact match, entries for the URL are served in order.
+	for _, want := range []string{`{"items": [1]}`, `{"items": [2]}`, `{"items": [2]}`} {
+		if got := match("http://localhost/api/items?page=9"); got != want {
+			t.Errorf("query-less match = %q, want %q", got, want)
+		}
+	}
+	if match("http://localhost/other") != "" || replay.match("POST", "http://localhost/logo.png") != nil {
+		t.Error("unexpected match")
+	}
+
+	fulfill, err := fulfillFromHAR("r1", replay.match("GET", "http://localhost/api/items?page=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range fulfill.ResponseHeaders {
+		if h.Name == "Content-Encoding" {
+			t.Error("recorded Content-Encoding replayed for a decoded body")
+		}
+	}
+}
+
+func TestNetworkInterception(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping browser interception test in short mode")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><script>
+				fetch("/api/items").then(r => r.text()).then(t => document.title = t, () => document.title = "failed");
+			</script></body></html>`)
+		case "/api/items":
+			fmt.Fprint(w, "real")
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	tools := NewBrowseTools(ctx, 0, 0)
+	t.Cleanup(tools.Close)
+	netTool := tools.NetworkTool()
+	run := func(input string) string {
+		t.Helper()
+		out := netTool.Run(ctx, json.RawMessage(input))
+		if out.Error != nil {
+			if strings.Contains(out.Error.Error(), "failed to start browser") {
+				t.Skip("Browser automation not available in this environment")
+			}
+			t.Fatalf("%s: %v", input, out.Error)
+		}
+		return out.LLMContent[0].Text
+	}
+	title := func() string {
+		t.Helper()
+		if out := tools.NewNavigateTool().Run(ctx, json.RawMessage(fmt.Sprintf(`{"url": %q}`, server.URL))); out.Error != nil {
+			t.Fatal(out.Error)
+		}
+		if out := tools.NewWaitForTool().Run(ctx, json.RawMessage(`{"network_idle": true}`)); out.Error != nil {
+			t.Fatal(out.Error)
+		}
+		var title string
+		out := tools.NewEvalTool().Run(ctx, json.RawMessage(`{"expression": "document.title"}`))
+		if out.Error != nil {
+			t.Fatal(out.Error)
+		}
+		json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(out.LLMContent[0].Text, "<javascript_result>"), "</javascript_result>")), &title)
+		return title
+	}
+
+	run(`{"action": "enable"}`)
+	if got := title(); got != "real" {
+		t.Fatalf("title = %q before interception", got)
+	}
+	harPath := filepath.Join(t.TempDir(), "site.har")
+	run(fmt.Sprintf(`{"action": "export_har", "path": %q}`, harPath))
+
+	run(`{"action": "intercept", "url_pattern": "*/api/items", "body": "mocked"}`)
+	if got := title(); got != "mocked" {
+		t.Errorf("title = %q with a mock", got)
+	}
+	run(`{"action": "intercept", "url_pattern": "*/api/*", "abort": true}`)
+	if got := title(); got != "failed" {
+		t.Errorf("title = %q with an abort rule", got)
+	}
+	run(`{"action": "remove_intercept"}`)
+
+	server.Close()
+	run(fmt.Sprintf(`{"action": "replay_har", "path": %q, "offline": true}`, harPath))
+	if got := title(); got != "real" {
+		t.Errorf("title = %q replaying offline", got)
+	}
+	if got := run(`{"action": "stop_replay"}`); !strings.Contains(got, "requests served") {
+		t.Errorf("stop_replay = %q", got)
+	}
+}
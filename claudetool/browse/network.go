@@ -2,10 +2,12 @@ package browse
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -27,8 +29,19 @@ type NetworkRequest struct {
 	StartTime  float64 `json:"start_time"`
 	EndTime    float64 `json:"end_time,omitempty"`
 	Size       float64 `json:"encoded_size,omitempty"`
+
+	// Details kept for HAR export
+	wallTime        time.Time
+	protocol        string
+	requestHeaders  network.Headers
+	postData        string
+	responseHeaders network.Headers
+	body            []byte
 }
 
+// maxCapturedBodySize is the largest response body kept for HAR export.
+const maxCapturedBodySize = 1 << 20
+
 // timeToSeconds converts a *cdp.MonotonicTime to a float64 seconds value.
 func timeToSeconds(t time.Time) float64 {
 	return float64(t.UnixMilli()) / 1000.0
@@ -45,6 +58,12 @@ func (b *BrowseTools) captureNetworkRequest(e *network.EventRequestWillBeSent) {
 		Method:    e.Request.Method,
 		Type:      e.Type.String(),
 		StartTime: timeToSeconds(e.Timestamp.Time()),
+
+		requestHeaders: e.Request.Headers,
+		postData:       postData(e.Request),
+	}
+	if e.WallTime != nil {
+		req.wallTime = e.WallTime.Time()
 	}
 	b.networkRequests = append(b.networkRequests, req)
 
@@ -69,6 +88,8 @@ func (b *BrowseTools) captureNetworkResponse(e *network.EventResponseReceived) {
 			b.networkRequests[i].StatusText = e.Response.StatusText
 			b.networkRequests[i].MimeType = e.Response.MimeType
 			b.networkRequests[i].Type = e.Type.String()
+			b.networkRequests[i].protocol = e.Response.Protocol
+			b.networkRequests[i].responseHeaders = e.Response.Headers
 			break
 		}
 	}
@@ -89,16 +110,71 @@ func (b *BrowseTools) captureNetworkFinished(e *network.EventLoadingFinished) {
 	}
 }
 
+// postData returns the body of a request.
+func postData(r *network.Request) string {
+	var sb strings.Builder
+	for _, entry := range r.PostDataEntries {
+		data, err := base64.StdEncoding.DecodeString(entry.Bytes)
+		if err != nil {
+			continue
+		}
+		sb.Write(data)
+	}
+	return sb.String()
+}
+
+// captureResponseBody fetches the body of a finished request for HAR export.
+// It runs in its own goroutine, as CDP commands cannot be sent from event
+// listeners.
+func (b *BrowseTools) captureResponseBody(browserCtx context.Context, id network.RequestID, size float64) {
+	if size > maxCapturedBodySize {
+		return
+	}
+	var body []byte
+	err := chromedp.Run(browserCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		body, err = network.GetResponseBody(id).Do(ctx)
+		return err
+	}))
+	if err != nil || len(body) > maxCapturedBodySize {
+		// Redirects, failed requests and evicted resources have no body.
+		return
+	}
+
+	b.networkMutex.Lock()
+	defer b.networkMutex.Unlock()
+	for i := len(b.networkRequests) - 1; i >= 0; i-- {
+		if b.networkRequests[i].RequestID == string(id) {
+			b.networkRequests[i].body = body
+			break
+		}
+	}
+}
+
 // networkInput is the input schema for the browser_network tool.
 type networkInput struct {
 	Action string `json:"action"`
 	Limit  int    `json:"limit,omitempty"`
 	Filter string `json:"filter,omitempty"`
+	// intercept
+	URLPattern string            `json:"url_pattern,omitempty"`
+	Method     string            `json:"method,omitempty"`
+	Status     int64             `json:"status,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	BodyFile   string            `json:"body_file,omitempty"`
+	Delay      string            `json:"delay,omitempty"`
+	Abort      bool              `json:"abort,omitempty"`
+	// remove_intercept
+	ID int `json:"id,omitempty"`
+	// export_har, replay_har
+	Path    string `json:"path,omitempty"`
+	Offline bool   `json:"offline,omitempty"`
 }
 
 // NetworkTool returns the browser_network tool for monitoring network requests.
 func (b *BrowseTools) NetworkTool() *llm.Tool {
-	description := `Network monitoring and inspection. Actions: help, enable, disable, get_log, clear, cookies.`
+	description := `Network monitoring, request interception and HAR record/replay. Actions: help, enable, disable, get_log, clear, cookies, intercept, remove_intercept, list_intercepts, export_har, replay_har, stop_replay.`
 
 	schema := `{
 		"type": "object",
@@ -106,7 +182,7 @@ func (b *BrowseTools) NetworkTool() *llm.Tool {
 			"action": {
 				"type": "string",
 				"description": "The network action to perform",
-				"enum": ["help", "enable", "disable", "get_log", "clear", "cookies"]
+				"enum": ["help", "enable", "disable", "get_log", "clear", "cookies", "intercept", "remove_intercept", "list_intercepts", "export_har", "replay_har", "stop_replay"]
 			},
 			"limit": {
 				"type": "integer",
@@ -114,7 +190,52 @@ func (b *BrowseTools) NetworkTool() *llm.Tool {
 			},
 			"filter": {
 				"type": "string",
-				"description": "Filter requests by URL substring (get_log action)"
+				"description": "Filter requests by URL substring (get_log and export_har actions)"
+			},
+			"url_pattern": {
+				"type": "string",
+				"description": "URL pattern to intercept; * matches any characters, ? one (intercept action)"
+			},
+			"method": {
+				"type": "string",
+				"description": "Only intercept this HTTP method (intercept action)"
+			},
+			"status": {
+				"type": "integer",
+				"description": "Status code to respond with (intercept action, default 200 when mocking)"
+			},
+			"headers": {
+				"type": "object",
+				"additionalProperties": {"type": "string"},
+				"description": "Response headers (intercept action)"
+			},
+			"body": {
+				"type": "string",
+				"description": "Response body (intercept action)"
+			},
+			"body_file": {
+				"type": "string",
+				"description": "File to read the response body from (intercept action)"
+			},
+			"delay": {
+				"type": "string",
+				"description": "Go duration to hold matching requests before answering or continuing them (intercept action)"
+			},
+			"abort": {
+				"type": "boolean",
+				"description": "Fail matching requests with a network error (intercept action)"
+			},
+			"id": {
+				"type": "integer",
+				"description": "Rule to remove; omit to remove all (remove_intercept action)"
+			},
+			"path": {
+				"type": "string",
+				"description": "HAR file to write (export_har) or read (replay_har)"
+			},
+			"offline": {
+				"type": "boolean",
+				"description": "Fail requests that are not in the HAR (replay_har action)"
 			}
 		},
 		"required": ["action"]
@@ -147,6 +268,18 @@ func (b *BrowseTools) networkRun(ctx context.Context, m json.RawMessage) llm.Too
 		return b.networkClearRun()
 	case "cookies":
 		return b.networkCookiesRun()
+	case "intercept":
+		return b.networkInterceptRun(input)
+	case "remove_intercept":
+		return b.networkRemoveInterceptRun(input.ID)
+	case "list_intercepts":
+		return b.networkListInterceptsRun()
+	case "export_har":
+		return b.networkExportHARRun(input.Path, input.Filter)
+	case "replay_har":
+		return b.networkReplayHARRun(input.Path, input.Offline)
+	case "stop_replay":
+		return b.networkStopReplayRun()
 	default:
 		return llm.ErrorfToolOut("unknown action: %q — use \"help\" to see available actions", input.Action)
 	}
@@ -174,11 +307,48 @@ Actions:
 
   cookies   — Return all browser cookies as JSON.
 
+  intercept — Add a rule for requests whose URL matches url_pattern
+              (* matches any characters, ? one; the whole URL must match,
+              e.g. "*/api/users*"). The most recently added matching rule
+              applies. Parameters:
+                method    (string)  — only this HTTP method
+                status    (int)     — respond with this status (default 200)
+                headers   (object)  — response headers
+                body      (string)  — response body
+                body_file (string)  — read the response body from this file
+                delay     (string)  — hold the request this long first, e.g. "2s"
+                abort     (bool)    — fail the request with a network error
+              A rule with only a delay lets the request continue after it.
+              Content-Type defaults from body_file's extension or the body.
+
+  remove_intercept — Remove the rule with the given id, or all rules.
+
+  list_intercepts  — List rules with how many requests each matched.
+
+  export_har — Write the captured log, with headers and response bodies
+              up to 1MB, as a HAR file. Parameters:
+                path   (string) — file to write (default: a temp file)
+                filter (string) — only include URLs containing this
+
+  replay_har — Answer requests from a HAR file's recorded responses, by
+              method and URL (ignoring the query string if no exact
+              match). Rules take precedence. Parameters:
+                path    (string, required) — the HAR file
+                offline (bool) — fail requests the HAR has no entry for,
+                                 so the page runs fully offline
+
+  stop_replay — Stop replaying the HAR file.
+
 Typical workflow:
   1. enable
   2. navigate to a page with the browser tool
   3. get_log to inspect requests
-  4. disable when done`
+  4. disable when done
+
+Mocking an API:
+  1. intercept {"url_pattern": "*/api/items*", "status": 500}
+  2. navigate and check how the page handles the error
+  3. remove_intercept`
 
 	return llm.ToolOut{LLMContent: llm.TextContent(help)}
 }
@@ -352,3 +522,157 @@ func (b *BrowseTools) networkCookiesRun() llm.ToolOut {
 
 	return llm.ToolOut{LLMContent: llm.TextContent(sb.String())}
 }
+
+func (b *BrowseTools) networkInterceptRun(input networkInput) llm.ToolOut {
+	rule, err := newInterceptRule(input)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	browserCtx, err := b.GetBrowserContext()
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	b.interceptMutex.Lock()
+	b.nextRuleID++
+	rule.ID = b.nextRuleID
+	b.interceptRules = append(b.interceptRules, rule)
+	b.interceptMutex.Unlock()
+
+	if err := b.syncFetch(browserCtx); err != nil {
+		b.removeInterceptRules(rule.ID)
+		return llm.ErrorfToolOut("failed to enable request interception: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Added rule %s", rule))}
+}
+
+// removeInterceptRules removes the rule with the given ID, or all rules if
+// id is 0, and returns how many were removed.
+func (b *BrowseTools) removeInterceptRules(id int) int {
+	b.interceptMutex.Lock()
+	defer b.interceptMutex.Unlock()
+	n := len(b.interceptRules)
+	b.interceptRules = slices.DeleteFunc(b.interceptRules, func(r *interceptRule) bool {
+		return id == 0 || r.ID == id
+	})
+	return n - len(b.interceptRules)
+}
+
+func (b *BrowseTools) networkRemoveInterceptRun(id int) llm.ToolOut {
+	browserCtx, err := b.GetBrowserContext()
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	removed := b.removeInterceptRules(id)
+	if id != 0 && removed == 0 {
+		return llm.ErrorfToolOut("no rule with id %d", id)
+	}
+	if err := b.syncFetch(browserCtx); err != nil {
+		return llm.ErrorfToolOut("failed to update request interception: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Removed %d rule(s).", removed))}
+}
+
+func (b *BrowseTools) networkListInterceptsRun() llm.ToolOut {
+	b.interceptMutex.Lock()
+	defer b.interceptMutex.Unlock()
+
+	var sb strings.Builder
+	if len(b.interceptRules) == 0 {
+		sb.WriteString("No interception rules.")
+	}
+	for _, r := range b.interceptRules {
+		sb.WriteString(r.String())
+		sb.WriteByte('\n')
+	}
+	if b.replay != nil {
+		b.replay.mu.Lock()
+		fmt.Fprintf(&sb, "\nReplaying %s (offline: %v): %d requests served, %d not found.", b.replay.path, b.replay.offline, b.replay.hits, b.replay.misses)
+		b.replay.mu.Unlock()
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(strings.TrimSpace(sb.String()))}
+}
+
+func (b *BrowseTools) networkExportHARRun(path, filter string) llm.ToolOut {
+	b.networkMutex.Lock()
+	var requests []*NetworkRequest
+	for _, req := range b.networkRequests {
+		if filter == "" || strings.Contains(req.URL, filter) {
+			requests = append(requests, req)
+		}
+	}
+	enabled := b.networkEnabled
+	b.networkMutex.Unlock()
+
+	if len(requests) == 0 {
+		msg := "No network requests captured."
+		if !enabled {
+			msg += ` (Network monitoring is disabled — use "enable" first.)`
+		}
+		return llm.ErrorfToolOut("%s", msg)
+	}
+
+	data, err := json.MarshalIndent(buildHAR(requests), "", "  ")
+	if err != nil {
+		return llm.ErrorfToolOut("failed to serialize HAR: %w", err)
+	}
+	if path == "" {
+		path = filepath.Join(ConsoleLogsDir, fmt.Sprintf("network_%s.har", uuid.New().String()[:8]))
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return llm.ErrorfToolOut("failed to write HAR file: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Wrote %d requests (%d bytes) to %s", len(requests), len(data), path))}
+}
+
+func (b *BrowseTools) networkReplayHARRun(path string, offline bool) llm.ToolOut {
+	if path == "" {
+		return llm.ErrorfToolOut("path is required")
+	}
+	replay, err := loadHARReplay(path, offline)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	browserCtx, err := b.GetBrowserContext()
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	b.interceptMutex.Lock()
+	b.replay = replay
+	b.interceptMutex.Unlock()
+
+	if err := b.syncFetch(browserCtx); err != nil {
+		b.interceptMutex.Lock()
+		b.replay = nil
+		b.interceptMutex.Unlock()
+		return llm.ErrorfToolOut("failed to enable request interception: %w", err)
+	}
+	mode := "other requests go to the network"
+	if offline {
+		mode = "other requests fail"
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Replaying %s; %s.", path, mode))}
+}
+
+func (b *BrowseTools) networkStopReplayRun() llm.ToolOut {
+	browserCtx, err := b.GetBrowserContext()
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	b.interceptMutex.Lock()
+	replay := b.replay
+	b.replay = nil
+	b.interceptMutex.Unlock()
+
+	if replay == nil {
+		return llm.ToolOut{LLMContent: llm.TextContent("No HAR replay is active.")}
+	}
+	if err := b.syncFetch(browserCtx); err != nil {
+		return llm.ErrorfToolOut("failed to update request interception: %w", err)
+	}
+	replay.mu.Lock()
+	defer replay.mu.Unlock()
+	return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Stopped replaying %s: %d requests served, %d not found.", replay.path, replay.hits, replay.misses))}
+}
@@ -0,0 +1,88 @@
+// Package checkpoint saves files before the agent changes them, so that a
+// conversation's changes can be rewound.
+//
+// Tools that edit files save each file's contents before writing it. For
+// shell commands, which can change anything, a Scanner compares the
+// workspace before and after the command and recovers the previous contents
+// of the files that changed.
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// MaxFileSize is the largest file that is checkpointed.
+const MaxFileSize = 10 << 20
+
+// File is a file's contents at some point in time.
+type File struct {
+	Path    string      `json:"path"`
+	Exists  bool        `json:"exists"` // false if the file didn't exist
+	Mode    fs.FileMode `json:"mode,omitempty"`
+	Content []byte      `json:"-"`
+}
+
+// Saver stores files' contents before the agent changes them.
+type Saver interface {
+	Save(ctx context.Context, files []File) error
+}
+
+// Read returns the current contents of path. A file that doesn't exist is
+// returned with Exists false.
+func Read(path string) (File, error) {
+	f := File{Path: path}
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	if !info.Mode().IsRegular() {
+		return f, fmt.Errorf("%s is not a regular file", path)
+	}
+	if info.Size() > MaxFileSize {
+		return f, fmt.Errorf("%s is larger than %d bytes", path, MaxFileSize)
+	}
+	if f.Content, err = os.ReadFile(path); err != nil {
+		return f, err
+	}
+	f.Exists = true
+	f.Mode = info.Mode().Perm()
+	return f, nil
+}
+
+// Restore writes files back to disk. Files that didn't exist are removed.
+func Restore(files []File) error {
+	var errs []error
+	for _, f := range files {
+		if !f.Exists {
+			if err := os.Remove(f.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		mode := f.Mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.WriteFile(f.Path, f.Content, mode); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// WriteFile only applies mode to new files.
+		if err := os.Chmod(f.Path, mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
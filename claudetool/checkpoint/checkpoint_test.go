@@ -0,0 +1,269 @@
+package checkpoint
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("before"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := Read(filepath.Join(dir, "sub", "new.txt"))
+	if err != nil || missing.Exists {
+		t.Fatalf("Read of a missing file = %+v, %v", missing, err)
+	}
+
+	if err := os.WriteFile(path, []byte("after"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(missing.Path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(missing.Path, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore([]File{saved, missing}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "before" {
+		t.Errorf("restored contents = %q, %v", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("restored mode = %v, %v", info.Mode(), err)
+	}
+	if _, err := os.Stat(missing.Path); !os.IsNotExist(err) {
+		t.Errorf("a file that didn't exist was not removed: %v", err)
+	}
+}
+
+// gitWorkspace returns a git repository with an initial commit of files.
+func gitWorkspace(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ws, err := filepath.EvalSymlinks(t.TempDir()) // git reports resolved paths
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		writeFile(t, ws, name, content)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.email=t@example.com", "-c", "user.name=t", "commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", ws}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return ws
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// byName returns files by path relative to ws.
+func byName(t *testing.T, ws string, files []File) map[string]File {
+	t.Helper()
+	got := map[string]File{}
+	for _, f := range files {
+		rel, err := filepath.Rel(ws, f.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[rel] = f
+	}
+	return got
+}
+
+func TestScanner(t *testing.T) {
+	ctx := context.Background()
+	ws := gitWorkspace(t, map[string]string{
+		".gitignore": "*.log\n",
+		"keep.txt":   "keep",
+		"edit.txt":   "old",
+		"gone.txt":   "gone",
+		"dirty.txt":  "committed",
+	})
+	writeFile(t, ws, "dirty.txt", "uncommitted")
+	writeFile(t, ws, "scratch.txt", "untracked")
+
+	s := NewScanner(t.TempDir())
+	snap, err := s.Snapshot(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files, err := s.Changes(ctx, snap); err != nil || len(files) != 0 {
+		t.Fatalf("Changes without changes = %v, %v", files, err)
+	}
+
+	snap, err = s.Snapshot(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "edit.txt", "new")
+	writeFile(t, ws, "dirty.txt", "changed again")
+	writeFile(t, ws, "scratch.txt", "edited")
+	writeFile(t, ws, "added.txt", "added")
+	writeFile(t, ws, "build.log", "ignored")
+	if err := os.Remove(filepath.Join(ws, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := s.Changes(ctx, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byName(t, ws, files)
+	if len(got) != 5 {
+		t.Fatalf("changed files = %v", files)
+	}
+	if f := got["added.txt"]; f.Exists {
+		t.Errorf("added.txt existed before: %+v", f)
+	}
+	for name, want := range map[string]string{"edit.txt": "old", "gone.txt": "gone", "dirty.txt": "uncommitted", "scratch.txt": "untracked"} {
+		if f := got[name]; !f.Exists || string(f.Content) != want {
+			t.Errorf("%s before = %q, want %q", name, f.Content, want)
+		}
+	}
+
+	if err := Restore(files); err != nil {
+		t.Fatal(err)
+	}
+	snap, err = s.Snapshot(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files, err := s.Changes(ctx, snap); err != nil || len(files) != 0 {
+		t.Errorf("Changes after Restore = %v, %v", files, err)
+	}
+}
+
+func TestScannerCommit(t *testing.T) {
+	ctx := context.Background()
+	ws := gitWorkspace(t, map[string]string{"a.txt": "one"})
+	s := NewScanner(t.TempDir())
+
+	// Files changed and committed by the command are clean afterwards.
+	snap, err := s.Snapshot(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "a.txt", "two")
+	if out, err := exec.Command("git", "-C", ws, "-c", "user.email=t@example.com", "-c", "user.name=t", "commit", "-q", "-am", "two").CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, out)
+	}
+	files, err := s.Changes(ctx, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := byName(t, ws, files)["a.txt"]; len(files) != 1 || string(f.Content) != "one" {
+		t.Errorf("changed files = %+v", files)
+	}
+}
+
+func TestScannerSubdirectory(t *testing.T) {
+	ctx := context.Background()
+	ws := gitWorkspace(t, map[string]string{"sub/a.txt": "a", "b.txt": "b"})
+	s := NewScanner(t.TempDir())
+
+	snap, err := s.Snapshot(ctx, filepath.Join(ws, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "sub/a.txt", "changed")
+	writeFile(t, ws, "b.txt", "outside the workspace")
+	files, err := s.Changes(ctx, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := byName(t, ws, files); len(got) != 1 || string(got[filepath.Join("sub", "a.txt")].Content) != "a" {
+		t.Errorf("changed files = %+v", files)
+	}
+}
+
+func TestScannerOverlappingCommands(t *testing.T) {
+	ctx := context.Background()
+	ws := gitWorkspace(t, map[string]string{"a.txt": "a", "b.txt": "b"})
+	s := NewScanner(t.TempDir())
+
+	// A starts, then B; B changes b.txt and finishes before A, which
+	// changes a.txt. Each is blamed only for its own change.
+	snapA, err := s.Snapshot(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapB, err := s.Snapshot(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "b.txt", "changed by B")
+	filesB, err := s.Changes(ctx, snapB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "a.txt", "changed by A")
+	filesA, err := s.Changes(ctx, snapA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := byName(t, ws, filesB); len(got) != 1 || string(got["b.txt"].Content) != "b" {
+		t.Errorf("B changed %+v", filesB)
+	}
+	if got := byName(t, ws, filesA); len(got) != 1 || string(got["a.txt"].Content) != "a" {
+		t.Errorf("A changed %+v", filesA)
+	}
+}
+
+func TestScannerNoCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ctx := context.Background()
+	ws, err := filepath.EvalSymlinks(t.TempDir()) // git reports resolved paths
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "init", "-q", ws).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	writeFile(t, ws, "a.txt", "a")
+	s := NewScanner(t.TempDir())
+
+	snap, err := s.Snapshot(ctx, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "a.txt", "changed")
+	writeFile(t, ws, "b.txt", "new")
+	files, err := s.Changes(ctx, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byName(t, ws, files)
+	if len(got) != 2 || string(got["a.txt"].Content) != "a" || got["b.txt"].Exists {
+		t.Errorf("changed files = %+v", files)
+	}
+}
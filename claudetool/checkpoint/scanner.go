@@ -0,0 +1,429 @@
+package checkpoint
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scanner finds the files a command changes in a git workspace. Before and
+// after the command it asks git which files differ from the workspace's
+// HEAD, so unchanged files and files ignored by .gitignore are never read.
+// The previous contents of the files that differ are kept in a private
+// repository per workspace, which is pruned as they expire.
+//
+// One Scanner is meant to be shared by all conversations. When commands in
+// a workspace overlap, a change is attributed to the first command that
+// finishes after it, so that rewinding one conversation does not undo
+// another's changes.
+type Scanner struct {
+	dir string // parent directory of the private repositories
+
+	mu         sync.Mutex // serializes use of the repositories
+	workspaces map[string]*workspaceState
+}
+
+// workspaceState tracks the open snapshots of a workspace and the changes
+// found while they were open.
+type workspaceState struct {
+	seq    uint64              // sequence number of the latest snapshot
+	open   map[uint64]struct{} // snapshots whose Changes have not been found
+	claims []claim
+	pruned time.Time
+}
+
+// claim records the files a command was found to change, when seq was
+// the latest snapshot.
+type claim struct {
+	seq   uint64
+	paths []string
+}
+
+// Snapshot is the state of a workspace before a command.
+type Snapshot struct {
+	root     string // top-level directory of the workspace's repository
+	pathspec string // the workspace, relative to root
+	seq      uint64
+	head     string            // HEAD commit, "" before the first commit
+	files    map[string]object // files that differ from head
+}
+
+// object is a file's contents in a repository. sha is "" for a file that
+// doesn't exist; skip is set for symlinks, submodules and large files.
+type object struct {
+	mode string
+	sha  string
+	skip bool
+	head bool // sha is in the workspace's repository, not the private one
+}
+
+const (
+	// pruneInterval is how often a workspace's private repository is pruned.
+	pruneInterval = 10 * time.Minute
+	// pruneExpiry is how long saved contents are kept. It must outlast any
+	// command, since the store may be shared with other processes.
+	pruneExpiry = "2.hours.ago"
+)
+
+// NewScanner returns a Scanner that keeps its repositories in dir.
+func NewScanner(dir string) *Scanner {
+	return &Scanner{dir: dir, workspaces: make(map[string]*workspaceState)}
+}
+
+// Dir returns the directory the Scanner keeps its repositories in.
+func (s *Scanner) Dir() string {
+	return s.dir
+}
+
+// Snapshot records the current state of workspace, which must be in a git
+// repository.
+func (s *Scanner) Snapshot(ctx context.Context, workspace string) (*Snapshot, error) {
+	out, err := run(ctx, workspace, nil, nil, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	root, prefix, _ := strings.Cut(strings.TrimSuffix(string(out), "\n"), "\n")
+	snap := &Snapshot{root: root, pathspec: cmp.Or(prefix, ".")}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.initRepo(ctx, root); err != nil {
+		return nil, err
+	}
+	if snap.head, err = s.head(ctx, root); err != nil {
+		return nil, err
+	}
+	paths, err := s.status(ctx, root, snap.pathspec)
+	if err != nil {
+		return nil, err
+	}
+	if snap.files, err = s.hash(ctx, root, paths, true); err != nil {
+		return nil, err
+	}
+	ws := s.workspace(root)
+	ws.seq++
+	ws.open[ws.seq] = struct{}{}
+	snap.seq = ws.seq
+	return snap, nil
+}
+
+// Changes returns the files that changed since the snapshot, with their
+// contents at the time of the snapshot. Symlinks, submodules and files
+// larger than MaxFileSize are skipped, as are files another command was
+// already found to change since the snapshot.
+func (s *Scanner) Changes(ctx context.Context, snap *Snapshot) ([]File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root := snap.root
+	ws := s.workspace(root)
+	defer s.close(ctx, root, ws, snap.seq)
+
+	head, err := s.head(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := s.status(ctx, root, snap.pathspec)
+	if err != nil {
+		return nil, err
+	}
+	// Files that are clean now but not at the same commit also changed,
+	// for example after a checkout or reset.
+	if head != snap.head && snap.head != "" && head != "" {
+		out, err := s.workspaceGit(ctx, root, "diff", "--name-only", "-z", "--no-renames", snap.head, head, "--", snap.pathspec)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, splitNUL(out)...)
+	}
+	paths = append(paths, slices.Collect(maps.Keys(snap.files))...)
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	claimed := make(map[string]bool)
+	for _, c := range ws.claims {
+		if c.seq >= snap.seq {
+			for _, p := range c.paths {
+				claimed[p] = true
+			}
+		}
+	}
+	paths = slices.DeleteFunc(paths, func(p string) bool { return claimed[p] })
+
+	before, err := s.before(ctx, snap, paths)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.hash(ctx, root, paths, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	var changed []string
+	for _, p := range paths {
+		old, cur := before[p], after[p]
+		if old.sha == cur.sha && (old.sha == "" || old.mode == cur.mode) {
+			continue
+		}
+		changed = append(changed, p)
+		if old.skip || cur.skip {
+			continue
+		}
+		f := File{Path: filepath.Join(root, p)}
+		if old.sha != "" {
+			content, err := s.content(ctx, root, old)
+			if err != nil {
+				return nil, err
+			}
+			if content == nil {
+				continue // too large
+			}
+			f.Exists = true
+			f.Content = content
+			f.Mode = 0o644
+			if old.mode == "100755" {
+				f.Mode = 0o755
+			}
+		}
+		files = append(files, f)
+	}
+	if len(changed) > 0 {
+		ws.claims = append(ws.claims, claim{seq: ws.seq, paths: changed})
+	}
+	return files, nil
+}
+
+// workspace returns the state of the repository at root. s.mu must be held.
+func (s *Scanner) workspace(root string) *workspaceState {
+	ws := s.workspaces[root]
+	if ws == nil {
+		ws = &workspaceState{open: make(map[uint64]struct{}), pruned: time.Now()}
+		s.workspaces[root] = ws
+	}
+	return ws
+}
+
+// close ends snapshot seq, drops the claims no open snapshot needs, and
+// prunes the private repository when it is due. s.mu must be held.
+func (s *Scanner) close(ctx context.Context, root string, ws *workspaceState, seq uint64) {
+	delete(ws.open, seq)
+	oldest := ws.seq + 1
+	for open := range ws.open {
+		oldest = min(oldest, open)
+	}
+	ws.claims = slices.DeleteFunc(ws.claims, func(c claim) bool { return c.seq < oldest })
+	if time.Since(ws.pruned) >= pruneInterval {
+		ws.pruned = time.Now()
+		s.git(ctx, root, nil, "prune", "--expire="+pruneExpiry)
+	}
+}
+
+// head returns the HEAD commit of the repository at root, or "" if it has
+// none yet.
+func (s *Scanner) head(ctx context.Context, root string) (string, error) {
+	out, err := s.workspaceGit(ctx, root, "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		return "", nil
+	}
+	return strings.TrimSpace(string(out)), err
+}
+
+// status returns the files matching pathspec that differ from HEAD,
+// including untracked files that are not ignored, relative to root.
+func (s *Scanner) status(ctx context.Context, root, pathspec string) ([]string, error) {
+	out, err := s.workspaceGit(ctx, root, "status", "--porcelain=v1", "-z", "--untracked-files=all", "--no-renames", "--ignore-submodules=all", "--", pathspec)
+	if err != nil {
+		return nil, err
+	}
+	// Each entry is "XY path".
+	var paths []string
+	for _, e := range splitNUL(out) {
+		if len(e) > 3 {
+			paths = append(paths, e[3:])
+		}
+	}
+	return paths, nil
+}
+
+// hash returns the current contents of paths, relative to root. If write
+// is set, the contents are saved in the private repository.
+func (s *Scanner) hash(ctx context.Context, root string, paths []string, write bool) (map[string]object, error) {
+	objects := make(map[string]object, len(paths))
+	var regular []string
+	for _, p := range paths {
+		info, err := os.Lstat(filepath.Join(root, p))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			objects[p] = object{}
+		case err != nil:
+			return nil, err
+		case !info.Mode().IsRegular() || info.Size() > MaxFileSize || strings.Contains(p, "\n"):
+			objects[p] = object{skip: true, sha: "skipped"}
+		default:
+			mode := "100644"
+			if info.Mode()&0o111 != 0 {
+				mode = "100755"
+			}
+			objects[p] = object{mode: mode}
+			regular = append(regular, p)
+		}
+	}
+	if len(regular) == 0 {
+		return objects, nil
+	}
+	args := []string{"hash-object", "--no-filters", "--stdin-paths"}
+	if write {
+		args = append(args, "-w")
+	}
+	out, err := s.git(ctx, root, []byte(strings.Join(regular, "\n")+"\n"), args...)
+	if err != nil {
+		return nil, err
+	}
+	shas := strings.Fields(string(out))
+	if len(shas) != len(regular) {
+		return nil, fmt.Errorf("git hash-object returned %d hashes for %d files", len(shas), len(regular))
+	}
+	for i, p := range regular {
+		o := objects[p]
+		o.sha = shas[i]
+		objects[p] = o
+	}
+	return objects, nil
+}
+
+// before returns the contents of paths at the time of snap: those that
+// differed from HEAD were saved by Snapshot, the rest are as in HEAD.
+func (s *Scanner) before(ctx context.Context, snap *Snapshot, paths []string) (map[string]object, error) {
+	objects := make(map[string]object, len(paths))
+	var fromHead []string
+	for _, p := range paths {
+		if o, ok := snap.files[p]; ok {
+			objects[p] = o
+		} else {
+			objects[p] = object{}
+			fromHead = append(fromHead, p)
+		}
+	}
+	if len(fromHead) == 0 || snap.head == "" {
+		return objects, nil
+	}
+	out, err := s.workspaceGit(ctx, snap.root, append([]string{"ls-tree", "-z", "--full-tree", snap.head, "--"}, fromHead...)...)
+	if err != nil {
+		return nil, err
+	}
+	// Each entry is "mode type sha\tpath".
+	for _, e := range splitNUL(out) {
+		meta, path, ok := strings.Cut(e, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected ls-tree output %q", e)
+		}
+		o := object{mode: fields[0], sha: fields[2], head: true}
+		o.skip = o.mode != "100644" && o.mode != "100755"
+		objects[path] = o
+	}
+	return objects, nil
+}
+
+// content returns the contents of o, or nil if it is larger than
+// MaxFileSize.
+func (s *Scanner) content(ctx context.Context, root string, o object) ([]byte, error) {
+	git := func(args ...string) ([]byte, error) { return s.git(ctx, root, nil, args...) }
+	if o.head {
+		git = func(args ...string) ([]byte, error) { return s.workspaceGit(ctx, root, args...) }
+	}
+	out, err := git("cat-file", "-s", o.sha)
+	if err != nil {
+		return nil, err
+	}
+	if size, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err != nil || size > MaxFileSize {
+		return nil, nil
+	}
+	content, err := git("cat-file", "blob", o.sha)
+	if content == nil && err == nil {
+		content = []byte{}
+	}
+	return content, err
+}
+
+// initRepo creates the private repository for root if it doesn't exist.
+func (s *Scanner) initRepo(ctx context.Context, root string) error {
+	gitDir := s.gitDir(root)
+	if _, err := os.Stat(gitDir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	if out, err := exec.CommandContext(ctx, "git", "init", "-q", "--bare", gitDir).CombinedOutput(); err != nil {
+		return fmt.Errorf("git init: %v: %s", err, out)
+	}
+	return nil
+}
+
+// gitDir returns the private repository for root.
+func (s *Scanner) gitDir(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:8])+".git")
+}
+
+// git runs a git command in root on its private repository.
+func (s *Scanner) git(ctx context.Context, root string, stdin []byte, args ...string) ([]byte, error) {
+	return run(ctx, root, stdin, []string{"--git-dir=" + s.gitDir(root), "-c", "gc.auto=0"}, args...)
+}
+
+// workspaceGit runs a read-only git command on the repository at root.
+func (s *Scanner) workspaceGit(ctx context.Context, root string, args ...string) ([]byte, error) {
+	return run(ctx, root, nil, []string{"--no-optional-locks", "-c", "core.fsmonitor=false"}, args...)
+}
+
+// run runs git with options, then args, in dir.
+func run(ctx context.Context, dir string, stdin []byte, options []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append(options, args...)...)
+	cmd.Dir = dir
+	cmd.Env = gitEnv()
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// splitNUL splits NUL-terminated git output.
+func splitNUL(out []byte) []string {
+	if len(out) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+}
+
+// gitEnv returns the environment without variables that would redirect git.
+func gitEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GIT_") {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
@@ -0,0 +1,203 @@
+package lsp
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+)
+
+// workspaceEditFiles returns the files a workspace edit changes, in the
+// states the edit leaves them in. Files removed by the edit don't exist in
+// their states. Nothing is written.
+func workspaceEditFiles(edit WorkspaceEdit) ([]checkpoint.File, error) {
+	states := make(map[string]*checkpoint.File)
+	var order []string
+	state := func(path string) (*checkpoint.File, error) {
+		if f, ok := states[path]; ok {
+			return f, nil
+		}
+		f := &checkpoint.File{Path: path}
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && info.IsDir():
+			return nil, fmt.Errorf("%s is a directory", path)
+		case err == nil:
+			if f.Content, err = os.ReadFile(path); err != nil {
+				return nil, err
+			}
+			f.Exists, f.Mode = true, info.Mode().Perm()
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+		states[path] = f
+		order = append(order, path)
+		return f, nil
+	}
+	editFile := func(uri string, edits []TextEdit) error {
+		path := filePathFromURI(uri)
+		f, err := state(path)
+		if err != nil {
+			return err
+		}
+		if !f.Exists {
+			return fmt.Errorf("%s: edited file does not exist", path)
+		}
+		if f.Content, err = applyTextEdits(f.Content, edits); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+
+	uris := make([]string, 0, len(edit.Changes))
+	for uri := range edit.Changes {
+		uris = append(uris, uri)
+	}
+	slices.Sort(uris)
+	for _, uri := range uris {
+		if err := editFile(uri, edit.Changes[uri]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, change := range edit.DocumentChanges {
+		opts := cmp.Or(change.Options, &ResourceOperationOptions{})
+		switch change.Kind {
+		case "":
+			if change.TextDocument == nil {
+				return nil, fmt.Errorf("document change without a document")
+			}
+			if err := editFile(change.TextDocument.URI, change.Edits); err != nil {
+				return nil, err
+			}
+		case "create":
+			f, err := state(filePathFromURI(change.URI))
+			if err != nil {
+				return nil, err
+			}
+			if f.Exists && !opts.Overwrite {
+				if opts.IgnoreIfExists {
+					continue
+				}
+				return nil, fmt.Errorf("%s: created file already exists", f.Path)
+			}
+			f.Exists, f.Content = true, nil
+		case "rename":
+			from, err := state(filePathFromURI(change.OldURI))
+			if err != nil {
+				return nil, err
+			}
+			to, err := state(filePathFromURI(change.NewURI))
+			if err != nil {
+				return nil, err
+			}
+			if !from.Exists {
+				return nil, fmt.Errorf("%s: renamed file does not exist", from.Path)
+			}
+			if to.Exists && !opts.Overwrite {
+				if opts.IgnoreIfExists {
+					continue
+				}
+				return nil, fmt.Errorf("%s: file already exists", to.Path)
+			}
+			to.Exists, to.Mode, to.Content = true, from.Mode, from.Content
+			from.Exists, from.Content = false, nil
+		case "delete":
+			f, err := state(filePathFromURI(change.URI))
+			if err != nil {
+				return nil, err
+			}
+			if !f.Exists && !opts.IgnoreIfNotExists {
+				return nil, fmt.Errorf("%s: deleted file does not exist", f.Path)
+			}
+			f.Exists, f.Content = false, nil
+		default:
+			return nil, fmt.Errorf("unsupported document change %q", change.Kind)
+		}
+	}
+
+	files := make([]checkpoint.File, len(order))
+	for i, path := range order {
+		files[i] = *states[path]
+	}
+	return files, nil
+}
+
+// applyTextEdits applies edits to content. All edits refer to positions in
+// the original content, and must not overlap.
+func applyTextEdits(content []byte, edits []TextEdit) ([]byte, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, len(edits))
+	for i, e := range edits {
+		start, err := positionOffset(content, e.Range.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := positionOffset(content, e.Range.End)
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, fmt.Errorf("edit range ends before it starts at line %d", e.Range.Start.Line+1)
+		}
+		spans[i] = span{start, end, e.NewText}
+	}
+	// Edits at the same position apply in the order given.
+	slices.SortStableFunc(spans, func(a, b span) int { return cmp.Compare(a.start, b.start) })
+
+	var out strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			return nil, fmt.Errorf("overlapping edits")
+		}
+		out.Write(content[last:s.start])
+		out.WriteString(s.text)
+		last = s.end
+	}
+	out.Write(content[last:])
+	return []byte(out.String()), nil
+}
+
+// positionOffset converts an LSP position, whose character offset counts
+// UTF-16 code units, to a byte offset in content. Positions past the end of
+// a line or of the content are clamped to it.
+func positionOffset(content []byte, pos Position) (int, error) {
+	if pos.Line < 0 || pos.Character < 0 {
+		return 0, fmt.Errorf("invalid position %d:%d", pos.Line, pos.Character)
+	}
+	off := 0
+	for range pos.Line {
+		i := bytes.IndexByte(content[off:], '\n')
+		if i < 0 {
+			return len(content), nil
+		}
+		off += i + 1
+	}
+	end := len(content)
+	if i := bytes.IndexByte(content[off:], '\n'); i >= 0 {
+		end = off + i
+	}
+	for units := 0; off < end && units < pos.Character; {
+		r, size := utf8.DecodeRune(content[off:end])
+		units += utf16Len(r)
+		off += size
+	}
+	return off, nil
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,114 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyTextEdits(t *testing.T) {
+	content := []byte("one two\nthree\n")
+	edit := func(l1, c1, l2, c2 int, text string) TextEdit {
+		return TextEdit{Range: Range{Start: Position{Line: l1, Character: c1}, End: Position{Line: l2, Character: c2}}, NewText: text}
+	}
+	tests := []struct {
+		name    string
+		edits   []TextEdit
+		want    string
+		wantErr bool
+	}{
+		{"none", nil, "one two\nthree\n", false},
+		{"replace", []TextEdit{edit(0, 4, 0, 7, "2")}, "one 2\nthree\n", false},
+		{"out of order", []TextEdit{edit(1, 0, 1, 5, "3"), edit(0, 0, 0, 3, "1")}, "1 two\n3\n", false},
+		{"inserts at one position keep their order", []TextEdit{edit(0, 0, 0, 0, "a"), edit(0, 0, 0, 0, "b")}, "abone two\nthree\n", false},
+		{"across lines", []TextEdit{edit(0, 3, 1, 0, " ")}, "one three\n", false},
+		{"past the end of a line", []TextEdit{edit(0, 7, 0, 99, "!")}, "one two!\nthree\n", false},
+		{"append", []TextEdit{edit(2, 0, 2, 0, "four\n")}, "one two\nthree\nfour\n", false},
+		{"overlapping", []TextEdit{edit(0, 0, 0, 5, ""), edit(0, 4, 0, 7, "")}, "", true},
+		{"backwards", []TextEdit{edit(0, 5, 0, 1, "")}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyTextEdits(content, tt.edits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPositionOffset(t *testing.T) {
+	// "é" is one UTF-16 unit in two bytes; "😀" is two units in four bytes.
+	content := []byte("aé😀b\nx")
+	tests := []struct {
+		pos  Position
+		want int
+	}{
+		{Position{0, 0}, 0},
+		{Position{0, 1}, 1},
+		{Position{0, 2}, 3},
+		{Position{0, 4}, 7},
+		{Position{0, 5}, 8},
+		{Position{0, 50}, 8},
+		{Position{1, 1}, 10},
+		{Position{5, 0}, 10},
+	}
+	for _, tt := range tests {
+		got, err := positionOffset(content, tt.pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("positionOffset(%v) = %d, want %d", tt.pos, got, tt.want)
+		}
+	}
+	if _, err := positionOffset(content, Position{-1, 0}); err == nil {
+		t.Error("negative position accepted")
+	}
+}
+
+func TestWorkspaceEditFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("hello\n"), 0o640)
+	insert := []TextEdit{{NewText: "// "}}
+
+	files, err := workspaceEditFiles(WorkspaceEdit{DocumentChanges: []DocumentChange{
+		{TextDocument: &TextDocumentIdentifier{URI: fileURI(a)}, Edits: insert},
+		{Kind: "rename", OldURI: fileURI(a), NewURI: fileURI(b)},
+		{Kind: "create", URI: fileURI(filepath.Join(dir, "c.txt"))},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("got %d files, want 3", len(files))
+	}
+	if files[0].Path != a || files[0].Exists {
+		t.Errorf("a.txt = %+v, want removed", files[0])
+	}
+	if files[1].Path != b || !files[1].Exists || string(files[1].Content) != "// hello\n" || files[1].Mode != 0o640 {
+		t.Errorf("b.txt = %+v %q", files[1], files[1].Content)
+	}
+	if !files[2].Exists || len(files[2].Content) != 0 {
+		t.Errorf("c.txt = %+v", files[2])
+	}
+	if content, _ := os.ReadFile(a); string(content) != "hello\n" {
+		t.Errorf("a.txt was written: %q", content)
+	}
+
+	for name, edit := range map[string]WorkspaceEdit{
+		"edit missing file":   {Changes: map[string][]TextEdit{fileURI(b): insert}},
+		"create existing":     {DocumentChanges: []DocumentChange{{Kind: "create", URI: fileURI(a)}}},
+		"delete missing":      {DocumentChanges: []DocumentChange{{Kind: "delete", URI: fileURI(b)}}},
+		"unknown change kind": {DocumentChanges: []DocumentChange{{Kind: "copy", URI: fileURI(a)}}},
+	} {
+		if _, err := workspaceEditFiles(edit); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -38,8 +38,14 @@ func formatDefinition(locations []Location, wd string) string {
 
 // formatReferences formats reference locations for display, grouped by file.
 func formatReferences(locations []Location, wd string) string {
+	return formatLocationList(locations, wd, "reference")
+}
+
+// formatLocationList formats locations of the given kind (such as
+// "reference") for display, grouped by file.
+func formatLocationList(locations []Location, wd, noun string) string {
 	if len(locations) == 0 {
-		return "No references found."
+		return fmt.Sprintf("No %ss found.", noun)
 	}
 
 	// Group by file
@@ -57,9 +63,9 @@ func formatReferences(locations []Location, wd string) string {
 	total := len(locations)
 	truncated := total > maxReferences
 	if truncated {
-		sb.WriteString(fmt.Sprintf("Found %d references (showing first %d):\n\n", total, maxReferences))
+		sb.WriteString(fmt.Sprintf("Found %d %ss (showing first %d):\n\n", total, noun, maxReferences))
 	} else {
-		sb.WriteString(fmt.Sprintf("Found %d reference(s):\n\n", total))
+		sb.WriteString(fmt.Sprintf("Found %d %s(s):\n\n", total, noun))
 	}
 
 	shown := 0
@@ -88,6 +94,76 @@ func formatReferences(locations []Location, wd string) string {
 	return strings.TrimRight(sb.String(), "\n")
 }
 
+// A hierarchyCall is a caller or callee of a call hierarchy item, with the
+// ranges of the calls in rangesURI.
+type hierarchyCall struct {
+	item      CallHierarchyItem
+	ranges    []Range
+	rangesURI string
+}
+
+// formatCallHierarchy formats the incoming or outgoing calls of item for display.
+func formatCallHierarchy(item CallHierarchyItem, direction string, calls []hierarchyCall, wd string) string {
+	var sb strings.Builder
+	itemPath := relativePath(filePathFromURI(item.URI), wd)
+	sb.WriteString(fmt.Sprintf("%s (%s) — %s:%d\n", item.Name, SymbolKindName(item.Kind), itemPath, item.SelectionRange.Start.Line+1))
+	if len(calls) == 0 {
+		if direction == "incoming" {
+			sb.WriteString("No callers found.")
+		} else {
+			sb.WriteString("No calls found.")
+		}
+		return sb.String()
+	}
+	if direction == "incoming" {
+		sb.WriteString(fmt.Sprintf("Called from %d function(s):\n", len(calls)))
+	} else {
+		sb.WriteString(fmt.Sprintf("Calls %d function(s):\n", len(calls)))
+	}
+	for i, call := range calls {
+		if i == maxReferences {
+			sb.WriteString(fmt.Sprintf("... and %d more\n", len(calls)-maxReferences))
+			break
+		}
+		path := relativePath(filePathFromURI(call.item.URI), wd)
+		sb.WriteString(fmt.Sprintf("- %s (%s) — %s:%d\n", call.item.Name, SymbolKindName(call.item.Kind), path, call.item.SelectionRange.Start.Line+1))
+		callsPath := filePathFromURI(call.rangesURI)
+		for _, r := range call.ranges {
+			line := r.Start.Line + 1
+			if context := readSourceLine(callsPath, r.Start.Line); context != "" {
+				sb.WriteString(fmt.Sprintf("    L%d: %s\n", line, strings.TrimSpace(context)))
+			} else {
+				sb.WriteString(fmt.Sprintf("    L%d\n", line))
+			}
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// formatCodeActions formats the available code actions for display.
+func formatCodeActions(actions []CodeAction) string {
+	if len(actions) == 0 {
+		return "No code actions available."
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d code action(s); apply one by passing its title as action:\n\n", len(actions)))
+	for i, a := range actions {
+		kind := ""
+		if a.Kind != "" {
+			kind = fmt.Sprintf(" [%s]", a.Kind)
+		}
+		preferred := ""
+		if a.IsPreferred {
+			preferred = " (preferred)"
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s%s%s\n", i+1, a.Title, kind, preferred))
+		if a.Disabled != nil {
+			sb.WriteString(fmt.Sprintf("   disabled: %s\n", a.Disabled.Reason))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
 // formatHover formats hover information for display.
 func formatHover(hover *Hover) string {
 	if hover == nil || hover.Contents.Value == "" {
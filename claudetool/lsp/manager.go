@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -20,9 +21,18 @@ type Manager struct {
 	servers map[string]*Server // config.Name -> running server
 }
 
-// NewManager creates a new LSP server manager.
-func NewManager(workingDirFn func() string) *Manager {
+// NewManager creates a new LSP server manager for the default servers and
+// any extra ones. An extra server replaces a default one with the same name,
+// and takes over the extensions it lists from the defaults.
+func NewManager(workingDirFn func() string, extra ...ServerConfig) *Manager {
 	configs := DefaultServers()
+	for _, cfg := range extra {
+		i := slices.IndexFunc(configs, func(c ServerConfig) bool { return c.Name == cfg.Name })
+		if i >= 0 {
+			configs = slices.Delete(configs, i, i+1)
+		}
+		configs = append(configs, cfg)
+	}
 	extToConfig := make(map[string]*ServerConfig)
 	for i := range configs {
 		for _, ext := range configs[i].Extensions {
@@ -29,6 +29,10 @@ type Client struct {
 
 	// OnDiagnostics is called when the server publishes diagnostics for a URI.
 	OnDiagnostics func(uri string, diags []Diagnostic)
+	// OnRequest is called when the server sends a request, such as
+	// workspace/applyEdit, and returns the result to reply with.
+	// Requests are answered with a null result if it is nil.
+	OnRequest func(method string, params json.RawMessage) (any, error)
 }
 
 type rpcRequest struct {
@@ -51,6 +55,14 @@ type rpcResponse struct {
 	Error   *rpcError        `json:"error,omitempty"`
 }
 
+// rpcReply is the client's response to a server request.
+type rpcReply struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
 type rpcError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -248,13 +260,15 @@ func (c *Client) readLoop() {
 			continue
 		}
 
+		var notif struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		_ = json.Unmarshal(body, &notif)
+
 		// If no ID, it's a server notification — check for diagnostics
 		if resp.ID == nil {
-			var notif struct {
-				Method string          `json:"method"`
-				Params json.RawMessage `json:"params"`
-			}
-			if json.Unmarshal(body, &notif) == nil && notif.Method == "textDocument/publishDiagnostics" {
+			if notif.Method == "textDocument/publishDiagnostics" {
 				var params PublishDiagnosticsParams
 				if json.Unmarshal(notif.Params, &params) == nil && c.OnDiagnostics != nil {
 					c.OnDiagnostics(params.URI, params.Diagnostics)
@@ -263,6 +277,13 @@ func (c *Client) readLoop() {
 			continue
 		}
 
+		// An ID and a method make a request from the server. Answer it
+		// asynchronously: handling it may wait for responses to our own calls.
+		if notif.Method != "" {
+			go c.reply(*resp.ID, notif.Method, notif.Params)
+			continue
+		}
+
 		var id int64
 		if err := json.Unmarshal(*resp.ID, &id); err != nil {
 			slog.Debug("lsp: failed to unmarshal response ID", "err", err)
@@ -278,6 +299,27 @@ func (c *Client) readLoop() {
 	}
 }
 
+// reply answers a request from the server.
+func (c *Client) reply(id json.RawMessage, method string, params json.RawMessage) {
+	var result any
+	var err error
+	if c.OnRequest != nil {
+		result, err = c.OnRequest(method, params)
+	}
+	r := rpcReply{JSONRPC: "2.0", ID: id}
+	switch {
+	case err != nil:
+		r.Error = &rpcError{Code: -32603, Message: err.Error()}
+	case result == nil:
+		r.Result = json.RawMessage("null")
+	default:
+		r.Result = result
+	}
+	if err := c.send(r); err != nil {
+		slog.Debug("lsp: failed to reply to server request", "method", method, "err", err)
+	}
+}
+
 // EncodeHeader returns the Content-Length header for a JSON-RPC message.
 // Exported for testing.
 func EncodeHeader(bodyLen int) string {
@@ -2,13 +2,14 @@ package lsp
 
 import "github.com/tgruben-circuit/percy/llm"
 
-// RegisterLSPTools creates the LSP code intelligence tools and returns them with a cleanup function.
-// The cleanup function shuts down all running LSP servers.
-func RegisterLSPTools(workingDirFn func() string) ([]*llm.Tool, func()) {
-	manager := NewManager(workingDirFn)
+// RegisterLSPTools creates the LSP code intelligence tools, which use manager's servers.
+// Renames and code actions change files with writeFiles; if it is nil they
+// are unavailable. The caller is responsible for closing manager.
+func RegisterLSPTools(manager *Manager, workingDirFn func() string, writeFiles WriteFilesFunc) []*llm.Tool {
 	tool := &CodeIntelTool{
 		manager:    manager,
 		workingDir: workingDirFn,
+		writeFiles: writeFiles,
 	}
-	return []*llm.Tool{tool.Tool()}, manager.Close
+	return []*llm.Tool{tool.Tool()}
 }
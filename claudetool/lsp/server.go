@@ -1,24 +1,48 @@
 package lsp
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
 // ServerConfig describes how to start an LSP server for a given language.
+// Servers beyond the defaults are configured in the "language_servers" list
+// in percy.json.
 type ServerConfig struct {
-	Name        string   // e.g., "gopls", "typescript-language-server"
-	Command     string   // binary name
-	Args        []string // command-line arguments
-	Extensions  []string // file extensions this server handles (e.g., ".go", ".ts")
-	InstallHint string   // message shown if the binary is not found
+	Name        string   `json:"name"`                   // e.g., "gopls", "typescript-language-server"
+	Command     string   `json:"command"`                // binary name
+	Args        []string `json:"args,omitempty"`         // command-line arguments
+	Extensions  []string `json:"extensions"`             // file extensions this server handles (e.g., ".go", ".ts")
+	InstallHint string   `json:"install_hint,omitempty"` // message shown if the binary is not found
+	LanguageID  string   `json:"language_id,omitempty"`  // LSP language ID for extensions languageID doesn't know
+}
+
+// Validate checks c.
+func (c *ServerConfig) Validate() error {
+	if c.Name == "" || c.Command == "" {
+		return fmt.Errorf("language server needs a name and a command")
+	}
+	if len(c.Extensions) == 0 {
+		return fmt.Errorf("language server %q needs extensions", c.Name)
+	}
+	for _, ext := range c.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("language server %q: extension %q must start with a dot", c.Name, ext)
+		}
+	}
+	return nil
 }
 
 // DefaultServers returns built-in server configurations.
@@ -61,12 +85,19 @@ type Server struct {
 	config  ServerConfig
 	rootURI string
 
+	capabilities ServerCapabilities
+
 	mu        sync.Mutex
-	openFiles map[string]int // URI -> version
+	openFiles map[string]int               // URI -> version
+	openSums  map[string][sha256.Size]byte // URI -> hash of the content sent
 
 	diagMu     sync.RWMutex
 	diagStore  map[string][]Diagnostic  // URI -> latest diagnostics
 	diagNotify map[string]chan struct{} // URI -> signal channel
+
+	cmdMu     sync.Mutex // serializes ExecuteCommand
+	applyMu   sync.Mutex
+	applyEdit func(WorkspaceEdit) error // applies the running command's edits
 }
 
 // NewServer starts an LSP server and initializes it with the given root URI.
@@ -83,6 +114,7 @@ func NewServer(ctx context.Context, config ServerConfig, rootURI string) (*Serve
 		config:     config,
 		rootURI:    rootURI,
 		openFiles:  make(map[string]int),
+		openSums:   make(map[string][sha256.Size]byte),
 		diagStore:  make(map[string][]Diagnostic),
 		diagNotify: make(map[string]chan struct{}),
 	}
@@ -101,6 +133,8 @@ func NewServer(ctx context.Context, config ServerConfig, rootURI string) (*Serve
 		}
 	}
 
+	client.OnRequest = s.handleRequest
+
 	if err := s.initialize(ctx); err != nil {
 		client.Close()
 		return nil, fmt.Errorf("initialize %s: %w", config.Name, err)
@@ -114,8 +148,25 @@ func (s *Server) initialize(ctx context.Context) error {
 		ProcessID: os.Getpid(),
 		RootURI:   s.rootURI,
 		Capabilities: ClientCapabilities{
+			Workspace: &WorkspaceClientCapabilities{
+				ApplyEdit: true,
+				WorkspaceEdit: &WorkspaceEditCapabilities{
+					DocumentChanges:    true,
+					ResourceOperations: []string{"create", "rename", "delete"},
+				},
+				Configuration: true,
+			},
 			TextDocument: &TextDocumentClientCapabilities{
-				Definition: &DefinitionClientCapabilities{},
+				Definition:     &DefinitionClientCapabilities{},
+				TypeDefinition: &DefinitionClientCapabilities{},
+				Implementation: &DefinitionClientCapabilities{},
+				Rename:         &DefinitionClientCapabilities{},
+				CallHierarchy:  &DefinitionClientCapabilities{},
+				CodeAction: &CodeActionClientCapabilities{
+					CodeActionLiteralSupport: codeActionLiteralSupport(),
+					DataSupport:              true,
+					ResolveSupport:           &CodeActionResolveSupport{Properties: []string{"edit"}},
+				},
 			},
 		},
 	}
@@ -124,6 +175,7 @@ func (s *Server) initialize(ctx context.Context) error {
 	if err := s.client.Call(ctx, "initialize", params, &result); err != nil {
 		return err
 	}
+	s.capabilities = result.Capabilities
 
 	slog.Debug("lsp: initialized", "server", s.config.Name, "rootURI", s.rootURI)
 	return s.client.Notify("initialized", struct{}{})
@@ -138,8 +190,12 @@ func (s *Server) OpenFile(ctx context.Context, filePath string) error {
 	}
 
 	lang := languageID(filePath)
+	if lang == "plaintext" && s.config.LanguageID != "" {
+		lang = s.config.LanguageID
+	}
 
 	s.mu.Lock()
+	s.openSums[uri] = sha256.Sum256(content)
 	version, isOpen := s.openFiles[uri]
 	if isOpen {
 		// File already open — send didChange with incremented version
@@ -166,10 +222,39 @@ func (s *Server) OpenFile(ctx context.Context, filePath string) error {
 	})
 }
 
+// RefreshOpenFiles sends the server the files it has open that changed on
+// disk since they were last sent, and closes those that were removed, so
+// that its view of the workspace matches edits made by other tools.
+func (s *Server) RefreshOpenFiles(ctx context.Context) error {
+	s.mu.Lock()
+	sums := make(map[string][sha256.Size]byte, len(s.openSums))
+	for uri, sum := range s.openSums {
+		sums[uri] = sum
+	}
+	s.mu.Unlock()
+
+	for uri, sum := range sums {
+		path := filePathFromURI(uri)
+		content, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			s.CloseFile(uri)
+			continue
+		}
+		if err != nil || sha256.Sum256(content) == sum {
+			continue
+		}
+		if err := s.OpenFile(ctx, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CloseFile closes a file in the LSP server.
 func (s *Server) CloseFile(uri string) {
 	s.mu.Lock()
 	delete(s.openFiles, uri)
+	delete(s.openSums, uri)
 	s.mu.Unlock()
 	_ = s.client.Notify("textDocument/didClose", DidCloseTextDocumentParams{
 		TextDocument: TextDocumentIdentifier{URI: uri},
@@ -205,6 +290,215 @@ func (s *Server) References(ctx context.Context, uri string, pos Position) ([]Lo
 	return locations, nil
 }
 
+// TypeDefinition returns the location(s) of the type of the symbol at the given position.
+func (s *Server) TypeDefinition(ctx context.Context, uri string, pos Position) ([]Location, error) {
+	return s.locations(ctx, "textDocument/typeDefinition", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     pos,
+	})
+}
+
+// Implementations returns the implementations of the interface or method at the given position.
+func (s *Server) Implementations(ctx context.Context, uri string, pos Position) ([]Location, error) {
+	return s.locations(ctx, "textDocument/implementation", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     pos,
+	})
+}
+
+// locations calls a method whose result is Location | Location[] | LocationLink[] | null.
+func (s *Server) locations(ctx context.Context, method string, params any) ([]Location, error) {
+	var raw json.RawMessage
+	if err := s.client.Call(ctx, method, params, &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
+
+// parseLocations parses a Location, a list of Locations or a list of LocationLinks.
+func parseLocations(raw json.RawMessage) ([]Location, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	if raw[0] == '{' {
+		raw = append(append([]byte("["), raw...), ']')
+	}
+	var items []struct {
+		Location
+		LocationLink
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	locations := make([]Location, len(items))
+	for i, item := range items {
+		locations[i] = item.Location
+		if item.TargetURI != "" {
+			locations[i] = Location{URI: item.TargetURI, Range: item.TargetSelectionRange}
+		}
+	}
+	return locations, nil
+}
+
+// Rename returns the workspace edit that renames the symbol at the given position.
+// It returns nil if there is nothing to rename.
+func (s *Server) Rename(ctx context.Context, uri string, pos Position, newName string) (*WorkspaceEdit, error) {
+	params := RenameParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     pos,
+		NewName:      newName,
+	}
+	var edit *WorkspaceEdit
+	if err := s.client.Call(ctx, "textDocument/rename", params, &edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// CodeActions returns the code actions for a range, given the diagnostics in it.
+// Commands returned in place of code actions become code actions with that command.
+func (s *Server) CodeActions(ctx context.Context, uri string, rng Range, diags []Diagnostic) ([]CodeAction, error) {
+	params := CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Range:        rng,
+		Context:      CodeActionContext{Diagnostics: diags},
+	}
+	if params.Context.Diagnostics == nil {
+		params.Context.Diagnostics = []Diagnostic{}
+	}
+	var items []json.RawMessage
+	if err := s.client.Call(ctx, "textDocument/codeAction", params, &items); err != nil {
+		return nil, err
+	}
+	actions := make([]CodeAction, 0, len(items))
+	for _, item := range items {
+		// A Command's "command" is a string; a CodeAction's is an object.
+		var probe struct {
+			Command json.RawMessage `json:"command"`
+		}
+		if err := json.Unmarshal(item, &probe); err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(probe.Command), []byte(`"`)) {
+			var cmd Command
+			if err := json.Unmarshal(item, &cmd); err != nil {
+				return nil, err
+			}
+			actions = append(actions, CodeAction{Title: cmd.Title, Command: &cmd})
+			continue
+		}
+		var action CodeAction
+		if err := json.Unmarshal(item, &action); err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// ResolveCodeAction fills in a code action's edit, for servers that compute
+// it lazily.
+func (s *Server) ResolveCodeAction(ctx context.Context, action CodeAction) (CodeAction, error) {
+	var resolved CodeAction
+	if err := s.client.Call(ctx, "codeAction/resolve", action, &resolved); err != nil {
+		return action, err
+	}
+	return resolved, nil
+}
+
+// ExecuteCommand runs a command on the server. The server applies the
+// command's changes by sending workspace edits, which are passed to apply.
+func (s *Server) ExecuteCommand(ctx context.Context, cmd Command, apply func(WorkspaceEdit) error) error {
+	s.cmdMu.Lock()
+	defer s.cmdMu.Unlock()
+
+	s.applyMu.Lock()
+	s.applyEdit = apply
+	s.applyMu.Unlock()
+	defer func() {
+		s.applyMu.Lock()
+		s.applyEdit = nil
+		s.applyMu.Unlock()
+	}()
+
+	params := ExecuteCommandParams{Command: cmd.Command, Arguments: cmd.Arguments}
+	return s.client.Call(ctx, "workspace/executeCommand", params, nil)
+}
+
+// PrepareCallHierarchy returns the call hierarchy items at the given position.
+func (s *Server) PrepareCallHierarchy(ctx context.Context, uri string, pos Position) ([]CallHierarchyItem, error) {
+	params := TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     pos,
+	}
+	var items []CallHierarchyItem
+	if err := s.client.Call(ctx, "textDocument/prepareCallHierarchy", params, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// IncomingCalls returns the calls to a call hierarchy item.
+func (s *Server) IncomingCalls(ctx context.Context, item CallHierarchyItem) ([]CallHierarchyIncomingCall, error) {
+	var calls []CallHierarchyIncomingCall
+	if err := s.client.Call(ctx, "callHierarchy/incomingCalls", CallHierarchyParams{Item: item}, &calls); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+// OutgoingCalls returns the calls a call hierarchy item makes.
+func (s *Server) OutgoingCalls(ctx context.Context, item CallHierarchyItem) ([]CallHierarchyOutgoingCall, error) {
+	var calls []CallHierarchyOutgoingCall
+	if err := s.client.Call(ctx, "callHierarchy/outgoingCalls", CallHierarchyParams{Item: item}, &calls); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+// handleRequest answers requests from the server.
+func (s *Server) handleRequest(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "workspace/applyEdit":
+		var p ApplyWorkspaceEditParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		s.applyMu.Lock()
+		apply := s.applyEdit
+		s.applyMu.Unlock()
+		if apply == nil {
+			return ApplyWorkspaceEditResult{FailureReason: "edits are only applied while a command runs"}, nil
+		}
+		if err := apply(p.Edit); err != nil {
+			return ApplyWorkspaceEditResult{FailureReason: err.Error()}, nil
+		}
+		return ApplyWorkspaceEditResult{Applied: true}, nil
+	case "workspace/configuration":
+		// No settings: servers use their defaults.
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(params, &p)
+		return make([]any, len(p.Items)), nil
+	}
+	// Acknowledge the rest, such as client/registerCapability and
+	// window/workDoneProgress/create.
+	return nil, nil
+}
+
+// codeActionLiteralSupport declares every code action kind, so servers
+// return code actions rather than bare commands.
+func codeActionLiteralSupport() *CodeActionLiteralSupport {
+	var support CodeActionLiteralSupport
+	support.CodeActionKind.ValueSet = []string{
+		"quickfix", "refactor", "refactor.extract", "refactor.inline", "refactor.rewrite",
+		"source", "source.organizeImports", "source.fixAll",
+	}
+	return &support
+}
+
 // HoverResult returns hover information for the symbol at the given position.
 func (s *Server) HoverResult(ctx context.Context, uri string, pos Position) (*Hover, error) {
 	params := TextDocumentPositionParams{
@@ -262,25 +556,51 @@ func (s *Server) GetDiagnostics(uri string) []Diagnostic {
 // returning early when diagnostics arrive. Returns whatever is stored after
 // the timeout, which may be empty if no diagnostics were published.
 func (s *Server) WaitForDiagnostics(uri string, timeout time.Duration) []Diagnostic {
-	// Create a notification channel for this URI
+	ch, stop := s.watchDiagnostics(uri)
+	defer stop()
+
+	// Wait for notification or timeout
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+	}
+
+	return s.GetDiagnostics(uri)
+}
+
+// FileDiagnostics opens or refreshes a file and waits up to timeout for the
+// server to publish its diagnostics. Unlike calling OpenFile and then
+// WaitForDiagnostics, it cannot miss diagnostics published in between.
+func (s *Server) FileDiagnostics(ctx context.Context, filePath string, timeout time.Duration) ([]Diagnostic, error) {
+	uri := fileURI(filePath)
+	ch, stop := s.watchDiagnostics(uri)
+	defer stop()
+
+	if err := s.OpenFile(ctx, filePath); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return s.GetDiagnostics(uri), nil
+}
+
+// watchDiagnostics returns a channel that is signaled when diagnostics are
+// published for uri, and a function to stop watching.
+func (s *Server) watchDiagnostics(uri string) (<-chan struct{}, func()) {
 	s.diagMu.Lock()
 	ch := make(chan struct{}, 1)
 	s.diagNotify[uri] = ch
 	s.diagMu.Unlock()
 
-	defer func() {
+	return ch, func() {
 		s.diagMu.Lock()
 		delete(s.diagNotify, uri)
 		s.diagMu.Unlock()
-	}()
-
-	// Wait for notification or timeout
-	select {
-	case <-ch:
-	case <-time.After(timeout):
 	}
-
-	return s.GetDiagnostics(uri)
 }
 
 // fileURI converts an absolute file path to a file:// URI.
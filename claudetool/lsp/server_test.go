@@ -0,0 +1,236 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeServerEnv makes the test binary run fakeServer instead of the tests,
+// so that it can be started as a language server for ".fake" files.
+const fakeServerEnv = "PERCY_LSP_FAKE_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnv) != "" {
+		fakeServer(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServerConfig configures fakeServer as the language server for ".fake" files.
+func fakeServerConfig(t *testing.T) ServerConfig {
+	t.Setenv(fakeServerEnv, "1")
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ServerConfig{Name: "fake", Command: exe, Extensions: []string{".fake"}, LanguageID: "fake"}
+}
+
+// fakeServer is a language server for a toy language in which lines
+// containing "bad" are errors. It renames words in all .fake files in the
+// document's directory, and offers code actions that fix bad lines with an
+// edit and with a command.
+func fakeServer(r io.Reader, w io.Writer) {
+	in := bufio.NewReader(r)
+	send := func(msg any) {
+		body, _ := json.Marshal(msg)
+		fmt.Fprint(w, EncodeHeader(len(body)))
+		w.Write(body)
+	}
+	docs := make(map[string]string)
+	publish := func(uri string) {
+		diags := []Diagnostic{}
+		for i, line := range strings.Split(docs[uri], "\n") {
+			if c := strings.Index(line, "bad"); c >= 0 {
+				diags = append(diags, Diagnostic{
+					Range:    Range{Start: Position{Line: i, Character: c}, End: Position{Line: i, Character: c + 3}},
+					Severity: DiagnosticSeverityError,
+					Source:   "fake",
+					Message:  "bad line",
+				})
+			}
+		}
+		send(map[string]any{"jsonrpc": "2.0", "method": "textDocument/publishDiagnostics",
+			"params": PublishDiagnosticsParams{URI: uri, Diagnostics: diags}})
+	}
+	fixEdit := func(uri string) WorkspaceEdit {
+		var edits []TextEdit
+		for i, line := range strings.Split(docs[uri], "\n") {
+			if c := strings.Index(line, "bad"); c >= 0 {
+				edits = append(edits, TextEdit{
+					Range:   Range{Start: Position{Line: i, Character: c}, End: Position{Line: i, Character: c + 3}},
+					NewText: "good",
+				})
+			}
+		}
+		return WorkspaceEdit{Changes: map[string][]TextEdit{uri: edits}}
+	}
+	item := func(name, uri string, line int) CallHierarchyItem {
+		rng := Range{Start: Position{Line: line}, End: Position{Line: line, Character: len(name)}}
+		return CallHierarchyItem{Name: name, Kind: 12, URI: uri, Range: rng, SelectionRange: rng}
+	}
+	var pendingCommand json.RawMessage
+
+	for {
+		length := 0
+		for {
+			line, err := in.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if n, ok := DecodeHeader(line); ok {
+				length = n
+			}
+			if line == "\r\n" {
+				break
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(in, body); err != nil {
+			return
+		}
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		var p struct {
+			TextDocument   TextDocumentItem `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+			Position  Position          `json:"position"`
+			NewName   string            `json:"newName"`
+			Context   CodeActionContext `json:"context"`
+			Arguments []string          `json:"arguments"`
+			Item      CallHierarchyItem `json:"item"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		uri := p.TextDocument.URI
+
+		var result any
+		switch msg.Method {
+		case "":
+			// The response to our workspace/applyEdit: finish the command.
+			var applied ApplyWorkspaceEditResult
+			json.Unmarshal(msg.Result, &applied)
+			if applied.Applied {
+				send(map[string]any{"jsonrpc": "2.0", "id": pendingCommand, "result": nil})
+			} else {
+				send(map[string]any{"jsonrpc": "2.0", "id": pendingCommand,
+					"error": map[string]any{"code": 1, "message": "edit not applied: " + applied.FailureReason}})
+			}
+			continue
+		case "initialize":
+			result = map[string]any{"capabilities": map[string]any{}}
+		case "textDocument/didOpen":
+			docs[uri] = p.TextDocument.Text
+			publish(uri)
+		case "textDocument/didChange":
+			docs[uri] = p.ContentChanges[0].Text
+			publish(uri)
+		case "textDocument/rename":
+			lines := strings.Split(docs[uri], "\n")
+			line := lines[p.Position.Line]
+			start, end := p.Position.Character, p.Position.Character
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			for end < len(line) && line[end] != ' ' {
+				end++
+			}
+			word := line[start:end]
+			files, _ := filepath.Glob(filepath.Join(filepath.Dir(filePathFromURI(uri)), "*.fake"))
+			edit := WorkspaceEdit{Changes: make(map[string][]TextEdit)}
+			for _, f := range files {
+				content, _ := os.ReadFile(f)
+				for i, l := range strings.Split(string(content), "\n") {
+					for c := 0; ; c += len(word) {
+						j := strings.Index(l[c:], word)
+						if j < 0 {
+							break
+						}
+						c += j
+						edit.Changes[fileURI(f)] = append(edit.Changes[fileURI(f)], TextEdit{
+							Range:   Range{Start: Position{Line: i, Character: c}, End: Position{Line: i, Character: c + len(word)}},
+							NewText: p.NewName,
+						})
+					}
+				}
+			}
+			result = edit
+		case "textDocument/codeAction":
+			actions := []any{}
+			if len(p.Context.Diagnostics) > 0 {
+				edit := fixEdit(uri)
+				actions = append(actions,
+					CodeAction{Title: "Fix bad lines", Kind: "quickfix", Edit: &edit},
+					Command{Title: "Fix bad lines later", Command: "fake.fix", Arguments: []json.RawMessage{json.RawMessage(fmt.Sprintf("%q", uri))}},
+					map[string]any{"title": "Explain", "disabled": map[string]string{"reason": "not implemented"}},
+				)
+			}
+			result = actions
+		case "workspace/executeCommand":
+			pendingCommand = msg.ID
+			send(map[string]any{"jsonrpc": "2.0", "id": "apply", "method": "workspace/applyEdit",
+				"params": ApplyWorkspaceEditParams{Edit: fixEdit(p.Arguments[0])}})
+			continue
+		case "textDocument/implementation":
+			rng := Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 4}}
+			result = []LocationLink{{TargetURI: uri, TargetRange: rng, TargetSelectionRange: rng}}
+		case "textDocument/prepareCallHierarchy":
+			result = []CallHierarchyItem{item("target", uri, p.Position.Line)}
+		case "callHierarchy/incomingCalls":
+			result = []CallHierarchyIncomingCall{{
+				From:       item("caller", p.Item.URI, 0),
+				FromRanges: []Range{{Start: Position{Line: 1}, End: Position{Line: 1, Character: 6}}},
+			}}
+		case "callHierarchy/outgoingCalls":
+			result = []CallHierarchyOutgoingCall{}
+		case "exit":
+			return
+		}
+		if msg.ID != nil {
+			send(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+		}
+	}
+}
+
+func TestParseLocations(t *testing.T) {
+	rng := `{"start":{"line":3,"character":1},"end":{"line":3,"character":5}}`
+	tests := []struct {
+		name string
+		raw  string
+		want int
+	}{
+		{"null", `null`, 0},
+		{"location", `{"uri":"file:///a.go","range":` + rng + `}`, 1},
+		{"locations", `[{"uri":"file:///a.go","range":` + rng + `},{"uri":"file:///b.go","range":` + rng + `}]`, 2},
+		{"links", `[{"targetUri":"file:///a.go","targetRange":` + rng + `,"targetSelectionRange":` + rng + `}]`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locations, err := parseLocations(json.RawMessage(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(locations) != tt.want {
+				t.Fatalf("got %d locations, want %d", len(locations), tt.want)
+			}
+			if tt.want > 0 && (locations[0].URI != "file:///a.go" || locations[0].Range.Start.Line != 3) {
+				t.Errorf("locations[0] = %+v", locations[0])
+			}
+		})
+	}
+}
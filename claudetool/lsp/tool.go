@@ -3,10 +3,15 @@ package lsp
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
 	"github.com/tgruben-circuit/percy/llm"
 )
 
@@ -16,12 +21,18 @@ const (
 
 Operations:
 - definition: Go to the definition of a symbol at a given file position
+- type_definition: Go to the definition of the type of the symbol at a given file position
 - references: Find all references to a symbol at a given file position
+- implementations: Find the implementations of the interface or method at a given file position
 - hover: Get type information and documentation for a symbol at a given file position
+- call_hierarchy: List the calls to (direction "incoming") or from ("outgoing") the function at a given file position
 - symbols: Search for symbols (functions, types, variables) across the workspace
 - diagnostics: Get compiler errors and warnings for a file
+- rename: Rename the symbol at a given file position to new_name everywhere it is used, editing every affected file at once
+- code_actions: List the quick fixes and refactorings available at a file position (or from line/column to end_line/end_column); pass the title of one as action to apply it
 
-Use this for precise, semantic code navigation. For text-based search, use keyword_search instead.
+Use this for precise, semantic code navigation and refactoring. For text-based search, use keyword_search instead.
+Prefer rename over editing each use by hand. rename and code_actions write files like the patch tool does.
 Requires an LSP server installed for the file's language (e.g., gopls for Go, typescript-language-server for TypeScript, pyright for Python, rust-analyzer for Rust).
 
 Note: The first call for a language may be slow while the LSP server starts and indexes the workspace.
@@ -32,24 +43,45 @@ Note: The first call for a language may be slow while the LSP server starts and
   "properties": {
     "operation": {
       "type": "string",
-      "enum": ["definition", "references", "hover", "symbols", "diagnostics"],
+      "enum": ["definition", "type_definition", "references", "implementations", "hover", "call_hierarchy", "symbols", "diagnostics", "rename", "code_actions"],
       "description": "The code intelligence operation to perform"
     },
     "file": {
       "type": "string",
-      "description": "File path (absolute or relative to working directory). Required for definition, references, hover."
+      "description": "File path (absolute or relative to working directory). Required for all operations except symbols."
     },
     "line": {
       "type": "integer",
-      "description": "Line number (1-based). Required for definition, references, hover."
+      "description": "Line number (1-based). Required for all operations except symbols and diagnostics."
     },
     "column": {
       "type": "integer",
-      "description": "Column number (1-based). Required for definition, references, hover."
+      "description": "Column number (1-based). Required for all operations except symbols and diagnostics."
+    },
+    "end_line": {
+      "type": "integer",
+      "description": "End line number (1-based) of the range to get code actions for. Defaults to line."
+    },
+    "end_column": {
+      "type": "integer",
+      "description": "End column number (1-based) of the range to get code actions for. Defaults to column."
     },
     "query": {
       "type": "string",
       "description": "Symbol name to search for. Required for symbols operation."
+    },
+    "new_name": {
+      "type": "string",
+      "description": "The new name. Required for rename operation."
+    },
+    "direction": {
+      "type": "string",
+      "enum": ["incoming", "outgoing"],
+      "description": "For call_hierarchy: incoming lists callers, outgoing lists callees. Defaults to incoming."
+    },
+    "action": {
+      "type": "string",
+      "description": "For code_actions: the title of the code action to apply, as listed by code_actions without action."
     }
   }
 }`
@@ -60,13 +92,24 @@ type codeIntelInput struct {
 	File      string `json:"file"`
 	Line      int    `json:"line"`
 	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line"`
+	EndColumn int    `json:"end_column"`
 	Query     string `json:"query"`
+	NewName   string `json:"new_name"`
+	Direction string `json:"direction"`
+	Action    string `json:"action"`
 }
 
+// WriteFilesFunc changes files to the given states (removing those that
+// don't exist in them) and reports the result. The patch tool's WriteFiles
+// is one.
+type WriteFilesFunc func(ctx context.Context, files []checkpoint.File) llm.ToolOut
+
 // CodeIntelTool provides LSP-based code intelligence.
 type CodeIntelTool struct {
 	manager    *Manager
 	workingDir func() string
+	writeFiles WriteFilesFunc // nil disables rename and applying code actions
 }
 
 // Tool returns the llm.Tool definition for code intelligence.
@@ -87,14 +130,14 @@ func (c *CodeIntelTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut
 	}
 
 	switch input.Operation {
-	case "definition", "references", "hover":
+	case "definition", "type_definition", "references", "implementations", "hover", "call_hierarchy", "rename", "code_actions":
 		return c.runPositionOp(ctx, input)
 	case "symbols":
 		return c.runSymbols(ctx, input)
 	case "diagnostics":
 		return c.runDiagnostics(ctx, input)
 	default:
-		return llm.ErrorfToolOut("unknown operation %q: must be one of definition, references, hover, symbols, diagnostics", input.Operation)
+		return llm.ErrorfToolOut("unknown operation %q: must be one of definition, type_definition, references, implementations, hover, call_hierarchy, symbols, diagnostics, rename, code_actions", input.Operation)
 	}
 }
 
@@ -122,8 +165,20 @@ func (c *CodeIntelTool) runPositionOp(ctx context.Context, input codeIntelInput)
 		return llm.ErrorfToolOut("%s", err)
 	}
 
-	// Open/refresh file in LSP
-	if err := srv.OpenFile(ctx, filePath); err != nil {
+	// Other tools may have changed files the server has open.
+	if err := srv.RefreshOpenFiles(ctx); err != nil {
+		return llm.ErrorfToolOut("failed to refresh files in LSP: %s", err)
+	}
+
+	// Open/refresh file in LSP. Code actions are offered for the
+	// diagnostics in the range, so wait for those.
+	var diags []Diagnostic
+	if input.Operation == "code_actions" {
+		diags, err = srv.FileDiagnostics(ctx, filePath, 2*time.Second)
+	} else {
+		err = srv.OpenFile(ctx, filePath)
+	}
+	if err != nil {
 		return llm.ErrorfToolOut("failed to open file in LSP: %s", err)
 	}
 
@@ -151,12 +206,41 @@ func (c *CodeIntelTool) runPositionOp(ctx context.Context, input codeIntelInput)
 		}
 		return llm.ToolOut{LLMContent: llm.TextContent(formatReferences(locations, wd))}
 
+	case "type_definition":
+		locations, err := srv.TypeDefinition(ctx, uri, pos)
+		if err != nil {
+			return llm.ErrorfToolOut("type definition failed: %s", err)
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent(formatDefinition(locations, wd))}
+
+	case "implementations":
+		locations, err := srv.Implementations(ctx, uri, pos)
+		if err != nil {
+			return llm.ErrorfToolOut("implementations failed: %s", err)
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent(formatLocationList(locations, wd, "implementation"))}
+
 	case "hover":
 		hover, err := srv.HoverResult(ctx, uri, pos)
 		if err != nil {
 			return llm.ErrorfToolOut("hover failed: %s", err)
 		}
 		return llm.ToolOut{LLMContent: llm.TextContent(formatHover(hover))}
+
+	case "call_hierarchy":
+		return c.runCallHierarchy(ctx, srv, uri, pos, input.Direction)
+
+	case "rename":
+		return c.runRename(ctx, srv, uri, pos, input.NewName)
+
+	case "code_actions":
+		end := pos
+		if input.EndLine > 0 {
+			end = Position{Line: input.EndLine - 1, Character: max(input.EndColumn-1, 0)}
+		} else if input.EndColumn > 0 {
+			end.Character = input.EndColumn - 1
+		}
+		return c.runCodeActions(ctx, srv, uri, Range{Start: pos, End: end}, diags, input.Action)
 	}
 
 	return llm.ErrorfToolOut("unreachable")
@@ -208,13 +292,181 @@ func (c *CodeIntelTool) runDiagnostics(ctx context.Context, input codeIntelInput
 		return llm.ErrorfToolOut("%s", err)
 	}
 
-	if err := srv.OpenFile(ctx, filePath); err != nil {
+	diags, err := srv.FileDiagnostics(ctx, filePath, 2*time.Second)
+	if err != nil {
 		return llm.ErrorfToolOut("failed to open file in LSP: %s", err)
 	}
 
-	uri := fileURI(filePath)
-	diags := srv.WaitForDiagnostics(uri, 2*time.Second)
-
 	wd := c.workingDir()
 	return llm.ToolOut{LLMContent: llm.TextContent(formatDiagnostics(diags, filePath, wd))}
 }
+
+func (c *CodeIntelTool) runCallHierarchy(ctx context.Context, srv *Server, uri string, pos Position, direction string) llm.ToolOut {
+	if direction == "" {
+		direction = "incoming"
+	}
+	if direction != "incoming" && direction != "outgoing" {
+		return llm.ErrorfToolOut("direction must be incoming or outgoing, not %q", direction)
+	}
+	items, err := srv.PrepareCallHierarchy(ctx, uri, pos)
+	if err != nil {
+		return llm.ErrorfToolOut("call hierarchy failed: %s", err)
+	}
+	if len(items) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("No function or method found at that position.")}
+	}
+
+	wd := c.workingDir()
+	var sections []string
+	for _, item := range items {
+		var calls []hierarchyCall
+		if direction == "incoming" {
+			incoming, err := srv.IncomingCalls(ctx, item)
+			if err != nil {
+				return llm.ErrorfToolOut("incoming calls failed: %s", err)
+			}
+			for _, call := range incoming {
+				calls = append(calls, hierarchyCall{item: call.From, ranges: call.FromRanges, rangesURI: call.From.URI})
+			}
+		} else {
+			outgoing, err := srv.OutgoingCalls(ctx, item)
+			if err != nil {
+				return llm.ErrorfToolOut("outgoing calls failed: %s", err)
+			}
+			for _, call := range outgoing {
+				calls = append(calls, hierarchyCall{item: call.To, ranges: call.FromRanges, rangesURI: item.URI})
+			}
+		}
+		sections = append(sections, formatCallHierarchy(item, direction, calls, wd))
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(strings.Join(sections, "\n\n"))}
+}
+
+func (c *CodeIntelTool) runRename(ctx context.Context, srv *Server, uri string, pos Position, newName string) llm.ToolOut {
+	if newName == "" {
+		return llm.ErrorfToolOut("new_name is required for rename operation")
+	}
+	if c.writeFiles == nil {
+		return llm.ErrorfToolOut("rename is not available: files can't be written")
+	}
+	edit, err := srv.Rename(ctx, uri, pos, newName)
+	if err != nil {
+		return llm.ErrorfToolOut("rename failed: %s", err)
+	}
+	if edit == nil {
+		return llm.ToolOut{LLMContent: llm.TextContent("Nothing to rename at that position.")}
+	}
+	out := c.applyEdit(ctx, srv, *edit)
+	if out.Error != nil {
+		return llm.ErrorfToolOut("rename failed: %w", out.Error)
+	}
+	return prependText(out, fmt.Sprintf("Renamed to %s.\n", newName))
+}
+
+func (c *CodeIntelTool) runCodeActions(ctx context.Context, srv *Server, uri string, rng Range, diags []Diagnostic, title string) llm.ToolOut {
+	var inRange []Diagnostic
+	for _, d := range diags {
+		if !positionBefore(d.Range.End, rng.Start) && !positionBefore(rng.End, d.Range.Start) {
+			inRange = append(inRange, d)
+		}
+	}
+	actions, err := srv.CodeActions(ctx, uri, rng, inRange)
+	if err != nil {
+		return llm.ErrorfToolOut("code actions failed: %s", err)
+	}
+	if title == "" {
+		return llm.ToolOut{LLMContent: llm.TextContent(formatCodeActions(actions))}
+	}
+	if c.writeFiles == nil {
+		return llm.ErrorfToolOut("code actions can't be applied: files can't be written")
+	}
+
+	i := slices.IndexFunc(actions, func(a CodeAction) bool { return a.Title == title })
+	if i < 0 {
+		i = slices.IndexFunc(actions, func(a CodeAction) bool { return strings.EqualFold(a.Title, title) })
+	}
+	if i < 0 {
+		return llm.ErrorfToolOut("no code action titled %q here.\n%s", title, formatCodeActions(actions))
+	}
+	action := actions[i]
+	if action.Disabled != nil {
+		return llm.ErrorfToolOut("code action %q is disabled: %s", action.Title, action.Disabled.Reason)
+	}
+	if action.Edit == nil && action.Command == nil {
+		if action, err = srv.ResolveCodeAction(ctx, action); err != nil {
+			return llm.ErrorfToolOut("resolving code action %q failed: %s", title, err)
+		}
+	}
+
+	var (
+		mu   sync.Mutex // outs is appended to by the server's applyEdit requests
+		outs []llm.ToolOut
+	)
+	if action.Edit != nil {
+		out := c.applyEdit(ctx, srv, *action.Edit)
+		if out.Error != nil {
+			return llm.ErrorfToolOut("code action %q failed: %w", action.Title, out.Error)
+		}
+		outs = append(outs, out)
+	}
+	if action.Command != nil {
+		// The server applies the command's changes by sending edits back.
+		err := srv.ExecuteCommand(ctx, *action.Command, func(edit WorkspaceEdit) error {
+			out := c.applyEdit(ctx, srv, edit)
+			if out.Error != nil {
+				return out.Error
+			}
+			mu.Lock()
+			outs = append(outs, out)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			return llm.ErrorfToolOut("code action %q failed: %s", action.Title, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(outs) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Applied code action %q; it changed no files.", action.Title))}
+	}
+	out := outs[len(outs)-1]
+	for _, o := range slices.Backward(outs[:len(outs)-1]) {
+		out = prependText(out, o.LLMContent[0].Text)
+	}
+	return prependText(out, fmt.Sprintf("Applied code action %q.\n", action.Title))
+}
+
+// applyEdit writes a workspace edit's changes and tells the server about them.
+func (c *CodeIntelTool) applyEdit(ctx context.Context, srv *Server, edit WorkspaceEdit) llm.ToolOut {
+	files, err := workspaceEditFiles(edit)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if len(files) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("no files were changed\n")}
+	}
+	out := c.writeFiles(ctx, files)
+	if out.Error == nil {
+		if err := srv.RefreshOpenFiles(ctx); err != nil {
+			slog.WarnContext(ctx, "lsp: failed to refresh files after edit", "error", err)
+		}
+	}
+	return out
+}
+
+// prependText adds text before a tool result's first text content.
+func prependText(out llm.ToolOut, text string) llm.ToolOut {
+	if len(out.LLMContent) > 0 && out.LLMContent[0].Type == llm.ContentTypeText {
+		content := slices.Clone(out.LLMContent)
+		content[0].Text = text + content[0].Text
+		out.LLMContent = content
+		return out
+	}
+	out.LLMContent = append(llm.TextContent(text), out.LLMContent...)
+	return out
+}
+
+func positionBefore(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
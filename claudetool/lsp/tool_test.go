@@ -3,7 +3,14 @@ package lsp
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+	"github.com/tgruben-circuit/percy/llm"
 )
 
 func TestToolInputValidation(t *testing.T) {
@@ -147,3 +154,144 @@ func TestToolSchemaIsValid(t *testing.T) {
 		t.Errorf("schema type = %v, want object", schema["type"])
 	}
 }
+
+// newFakeTool returns a code intelligence tool backed by fakeServer, whose
+// writes are recorded in *written.
+func newFakeTool(t *testing.T, wd string, written *[]checkpoint.File) *CodeIntelTool {
+	manager := NewManager(func() string { return wd }, fakeServerConfig(t))
+	t.Cleanup(manager.Close)
+	return &CodeIntelTool{
+		manager:    manager,
+		workingDir: func() string { return wd },
+		writeFiles: func(ctx context.Context, files []checkpoint.File) llm.ToolOut {
+			for _, f := range files {
+				if err := os.WriteFile(f.Path, f.Content, 0o644); err != nil {
+					return llm.ErrorToolOut(err)
+				}
+			}
+			*written = append(*written, files...)
+			return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("wrote %d file(s)\n", len(files)))}
+		},
+	}
+}
+
+func runTool(t *testing.T, tool *CodeIntelTool, input codeIntelInput) string {
+	t.Helper()
+	data, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := tool.Run(context.Background(), data)
+	if out.Error != nil {
+		t.Fatalf("%s: %v", input.Operation, out.Error)
+	}
+	return out.LLMContent[0].Text
+}
+
+func TestToolRename(t *testing.T) {
+	wd := t.TempDir()
+	a := filepath.Join(wd, "a.fake")
+	b := filepath.Join(wd, "b.fake")
+	os.WriteFile(a, []byte("def count\nuse count\n"), 0o644)
+	os.WriteFile(b, []byte("use count count\n"), 0o644)
+	var written []checkpoint.File
+	tool := newFakeTool(t, wd, &written)
+
+	got := runTool(t, tool, codeIntelInput{Operation: "rename", File: "a.fake", Line: 1, Column: 6, NewName: "total"})
+	if !strings.Contains(got, "Renamed to total.") || !strings.Contains(got, "wrote 2 file(s)") {
+		t.Errorf("rename output = %q", got)
+	}
+	if content, _ := os.ReadFile(a); string(content) != "def total\nuse total\n" {
+		t.Errorf("a.fake = %q", content)
+	}
+	if content, _ := os.ReadFile(b); string(content) != "use total total\n" {
+		t.Errorf("b.fake = %q", content)
+	}
+
+	// Rename without a new name, or without a way to write files, fails.
+	data, _ := json.Marshal(codeIntelInput{Operation: "rename", File: "a.fake", Line: 1, Column: 6})
+	if out := tool.Run(context.Background(), data); out.Error == nil || !strings.Contains(out.Error.Error(), "new_name is required") {
+		t.Errorf("rename without new_name: %v", out.Error)
+	}
+	tool.writeFiles = nil
+	data, _ = json.Marshal(codeIntelInput{Operation: "rename", File: "a.fake", Line: 1, Column: 6, NewName: "x"})
+	if out := tool.Run(context.Background(), data); out.Error == nil {
+		t.Error("rename without writeFiles succeeded")
+	}
+}
+
+func TestToolCodeActions(t *testing.T) {
+	wd := t.TempDir()
+	path := filepath.Join(wd, "a.fake")
+	os.WriteFile(path, []byte("ok\nbad one\n"), 0o644)
+	var written []checkpoint.File
+	tool := newFakeTool(t, wd, &written)
+
+	// No diagnostics on line 1, so no actions.
+	got := runTool(t, tool, codeIntelInput{Operation: "code_actions", File: "a.fake", Line: 1, Column: 1})
+	if got != "No code actions available." {
+		t.Errorf("code_actions on line 1 = %q", got)
+	}
+
+	got = runTool(t, tool, codeIntelInput{Operation: "code_actions", File: "a.fake", Line: 1, Column: 1, EndLine: 2, EndColumn: 8})
+	for _, want := range []string{"1. Fix bad lines [quickfix]", "2. Fix bad lines later", "3. Explain", "disabled: not implemented"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("code_actions output missing %q:\n%s", want, got)
+		}
+	}
+
+	// An action with an edit.
+	got = runTool(t, tool, codeIntelInput{Operation: "code_actions", File: "a.fake", Line: 2, Column: 1, Action: "fix bad lines"})
+	if !strings.Contains(got, `Applied code action "Fix bad lines".`) || !strings.Contains(got, "wrote 1 file(s)") {
+		t.Errorf("apply output = %q", got)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "ok\ngood one\n" {
+		t.Errorf("a.fake = %q", content)
+	}
+
+	// An action with a command, which the server applies with workspace/applyEdit.
+	os.WriteFile(path, []byte("bad\nbad two\n"), 0o644)
+	written = nil
+	got = runTool(t, tool, codeIntelInput{Operation: "code_actions", File: "a.fake", Line: 2, Column: 1, Action: "Fix bad lines later"})
+	if !strings.Contains(got, "wrote 1 file(s)") || len(written) != 1 {
+		t.Errorf("apply output = %q, %d writes", got, len(written))
+	}
+	if content, _ := os.ReadFile(path); string(content) != "good\ngood two\n" {
+		t.Errorf("a.fake = %q", content)
+	}
+
+	// Unknown and disabled actions.
+	os.WriteFile(path, []byte("bad\n"), 0o644)
+	for action, want := range map[string]string{"Nope": "no code action titled", "Explain": "disabled: not implemented"} {
+		data, _ := json.Marshal(codeIntelInput{Operation: "code_actions", File: "a.fake", Line: 1, Column: 1, Action: action})
+		if out := tool.Run(context.Background(), data); out.Error == nil || !strings.Contains(out.Error.Error(), want) {
+			t.Errorf("applying %q: error %v, want %q", action, out.Error, want)
+		}
+	}
+}
+
+func TestToolNavigation(t *testing.T) {
+	wd := t.TempDir()
+	os.WriteFile(filepath.Join(wd, "a.fake"), []byte("func caller\n  target()\nfunc target\n"), 0o644)
+	var written []checkpoint.File
+	tool := newFakeTool(t, wd, &written)
+
+	got := runTool(t, tool, codeIntelInput{Operation: "call_hierarchy", File: "a.fake", Line: 3, Column: 6})
+	for _, want := range []string{"target (Function) — a.fake:3", "Called from 1 function(s)", "- caller (Function) — a.fake:1", "L2: target()"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("call_hierarchy output missing %q:\n%s", want, got)
+		}
+	}
+	got = runTool(t, tool, codeIntelInput{Operation: "call_hierarchy", File: "a.fake", Line: 3, Column: 6, Direction: "outgoing"})
+	if !strings.Contains(got, "No calls found.") {
+		t.Errorf("outgoing call_hierarchy = %q", got)
+	}
+
+	got = runTool(t, tool, codeIntelInput{Operation: "implementations", File: "a.fake", Line: 3, Column: 6})
+	if !strings.Contains(got, "Found 1 implementation(s)") || !strings.Contains(got, "L2: target()") {
+		t.Errorf("implementations = %q", got)
+	}
+	if len(written) != 0 {
+		t.Errorf("navigation wrote %d files", len(written))
+	}
+}
@@ -1,5 +1,7 @@
 package lsp
 
+import "encoding/json"
+
 // LSP protocol types — minimal subset needed for code intelligence operations.
 
 // Position in a text document (0-based line and character).
@@ -20,6 +22,15 @@ type Location struct {
 	Range Range  `json:"range"`
 }
 
+// LocationLink is a link between a source and a target location. Servers
+// may return it instead of a Location.
+type LocationLink struct {
+	OriginSelectionRange *Range `json:"originSelectionRange,omitempty"`
+	TargetURI            string `json:"targetUri"`
+	TargetRange          Range  `json:"targetRange"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
 // TextDocumentIdentifier identifies a text document by its URI.
 type TextDocumentIdentifier struct {
 	URI string `json:"uri"`
@@ -153,12 +164,50 @@ type InitializeParams struct {
 
 // ClientCapabilities define capabilities the editor / tool provides.
 type ClientCapabilities struct {
+	Workspace    *WorkspaceClientCapabilities    `json:"workspace,omitempty"`
 	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
 }
 
+// WorkspaceClientCapabilities define capabilities the editor / tool provides on the workspace.
+type WorkspaceClientCapabilities struct {
+	ApplyEdit     bool                       `json:"applyEdit,omitempty"`
+	WorkspaceEdit *WorkspaceEditCapabilities `json:"workspaceEdit,omitempty"`
+	Configuration bool                       `json:"configuration,omitempty"`
+}
+
+// WorkspaceEditCapabilities describe the workspace edits the client can apply.
+type WorkspaceEditCapabilities struct {
+	DocumentChanges    bool     `json:"documentChanges,omitempty"`
+	ResourceOperations []string `json:"resourceOperations,omitempty"`
+}
+
 // TextDocumentClientCapabilities define capabilities the editor / tool provides on text documents.
 type TextDocumentClientCapabilities struct {
-	Definition *DefinitionClientCapabilities `json:"definition,omitempty"`
+	Definition     *DefinitionClientCapabilities `json:"definition,omitempty"`
+	TypeDefinition *DefinitionClientCapabilities `json:"typeDefinition,omitempty"`
+	Implementation *DefinitionClientCapabilities `json:"implementation,omitempty"`
+	Rename         *DefinitionClientCapabilities `json:"rename,omitempty"`
+	CallHierarchy  *DefinitionClientCapabilities `json:"callHierarchy,omitempty"`
+	CodeAction     *CodeActionClientCapabilities `json:"codeAction,omitempty"`
+}
+
+// CodeActionClientCapabilities describe the code actions the client understands.
+type CodeActionClientCapabilities struct {
+	CodeActionLiteralSupport *CodeActionLiteralSupport `json:"codeActionLiteralSupport,omitempty"`
+	DataSupport              bool                      `json:"dataSupport,omitempty"`
+	ResolveSupport           *CodeActionResolveSupport `json:"resolveSupport,omitempty"`
+}
+
+// CodeActionLiteralSupport says the client accepts CodeAction literals of the given kinds.
+type CodeActionLiteralSupport struct {
+	CodeActionKind struct {
+		ValueSet []string `json:"valueSet"`
+	} `json:"codeActionKind"`
+}
+
+// CodeActionResolveSupport lists the code action properties the server may resolve lazily.
+type CodeActionResolveSupport struct {
+	Properties []string `json:"properties"`
 }
 
 // DefinitionClientCapabilities indicates whether definition supports dynamic registration.
@@ -182,6 +231,12 @@ type ServerCapabilities struct {
 	CompletionProvider         any  `json:"completionProvider,omitempty"`
 	SignatureHelpProvider      any  `json:"signatureHelpProvider,omitempty"`
 	DocumentFormattingProvider bool `json:"documentFormattingProvider,omitempty"`
+	// The providers below are either a boolean or an options object.
+	TypeDefinitionProvider any `json:"typeDefinitionProvider,omitempty"`
+	ImplementationProvider any `json:"implementationProvider,omitempty"`
+	RenameProvider         any `json:"renameProvider,omitempty"`
+	CallHierarchyProvider  any `json:"callHierarchyProvider,omitempty"`
+	CodeActionProvider     any `json:"codeActionProvider,omitempty"`
 }
 
 // DidOpenTextDocumentParams is sent when a text document is opened.
@@ -251,3 +306,129 @@ type PublishDiagnosticsParams struct {
 	URI         string       `json:"uri"`
 	Diagnostics []Diagnostic `json:"diagnostics"`
 }
+
+// TextEdit is a textual edit applicable to a text document.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit represents changes to many resources in the workspace.
+// Servers use Changes or DocumentChanges.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []DocumentChange      `json:"documentChanges,omitempty"`
+}
+
+// DocumentChange is either a TextDocumentEdit, with TextDocument and Edits,
+// or a resource operation, with Kind "create", "rename" or "delete".
+type DocumentChange struct {
+	TextDocument *TextDocumentIdentifier `json:"textDocument,omitempty"`
+	Edits        []TextEdit              `json:"edits,omitempty"`
+
+	Kind    string                    `json:"kind,omitempty"`
+	URI     string                    `json:"uri,omitempty"`    // create and delete
+	OldURI  string                    `json:"oldUri,omitempty"` // rename
+	NewURI  string                    `json:"newUri,omitempty"` // rename
+	Options *ResourceOperationOptions `json:"options,omitempty"`
+}
+
+// ResourceOperationOptions are the options of a create, rename or delete operation.
+type ResourceOperationOptions struct {
+	Overwrite         bool `json:"overwrite,omitempty"`
+	IgnoreIfExists    bool `json:"ignoreIfExists,omitempty"`
+	Recursive         bool `json:"recursive,omitempty"`
+	IgnoreIfNotExists bool `json:"ignoreIfNotExists,omitempty"`
+}
+
+// RenameParams is the params for a textDocument/rename request.
+type RenameParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+	NewName      string                 `json:"newName"`
+}
+
+// CodeActionParams is the params for a textDocument/codeAction request.
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      CodeActionContext      `json:"context"`
+}
+
+// CodeActionContext carries the diagnostics a code action request is for.
+type CodeActionContext struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Command is a command the server can execute with workspace/executeCommand.
+type Command struct {
+	Title     string            `json:"title"`
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+// CodeAction is a change, such as a quick fix or refactoring, that can be
+// applied with its Edit, its Command, or both. Servers may leave Edit out
+// until the action is resolved.
+type CodeAction struct {
+	Title       string       `json:"title"`
+	Kind        string       `json:"kind,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	IsPreferred bool         `json:"isPreferred,omitempty"`
+	Disabled    *struct {
+		Reason string `json:"reason"`
+	} `json:"disabled,omitempty"`
+	Edit    *WorkspaceEdit  `json:"edit,omitempty"`
+	Command *Command        `json:"command,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// ExecuteCommandParams is the params for a workspace/executeCommand request.
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+// ApplyWorkspaceEditParams is the params of a workspace/applyEdit request
+// from the server.
+type ApplyWorkspaceEditParams struct {
+	Label string        `json:"label,omitempty"`
+	Edit  WorkspaceEdit `json:"edit"`
+}
+
+// ApplyWorkspaceEditResult is the client's reply to workspace/applyEdit.
+type ApplyWorkspaceEditResult struct {
+	Applied       bool   `json:"applied"`
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// CallHierarchyItem is a function or method in a call hierarchy.
+type CallHierarchyItem struct {
+	Name           string          `json:"name"`
+	Kind           SymbolKind      `json:"kind"`
+	Detail         string          `json:"detail,omitempty"`
+	URI            string          `json:"uri"`
+	Range          Range           `json:"range"`
+	SelectionRange Range           `json:"selectionRange"`
+	Data           json.RawMessage `json:"data,omitempty"`
+}
+
+// CallHierarchyParams is the params for callHierarchy/incomingCalls and
+// callHierarchy/outgoingCalls requests.
+type CallHierarchyParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCall is a call to an item: From calls it at FromRanges,
+// which are in From's file.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// CallHierarchyOutgoingCall is a call from an item: it calls To at
+// FromRanges, which are in the calling item's file.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"`
+}
@@ -0,0 +1,220 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
+	memdb "github.com/tgruben-circuit/percy/memory"
+	"github.com/tgruben-circuit/percy/memory/muninn"
+)
+
+const (
+	memorySaveName        = "memory_save"
+	memorySaveDescription = "Save a memory so it can be recalled in future conversations.\nUse this when the user asks you to remember something (\"remember that we use pnpm, not npm\"), or when you learn a durable fact, decision or preference worth keeping. Keep content to one or two self-contained sentences.\nIf the new memory replaces existing ones, pass their cell IDs (shown by memory_search) in supersedes."
+	memorySaveInputSchema = `{
+  "type": "object",
+  "required": ["content"],
+  "properties": {
+    "content": {
+      "type": "string",
+      "description": "The memory to save, as a compressed, self-contained statement"
+    },
+    "cell_type": {
+      "type": "string",
+      "enum": ["fact", "decision", "preference", "task", "risk", "code_ref"],
+      "description": "Kind of memory. Defaults to fact."
+    },
+    "topic": {
+      "type": "string",
+      "description": "Short topic label (e.g. \"tooling\", \"deployment\"). Used to group related memories."
+    },
+    "scope": {
+      "type": "string",
+      "enum": ["project", "global"],
+      "description": "project: only recalled in the current repository. global: recalled everywhere (e.g. personal preferences). Defaults to project."
+    },
+    "supersedes": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Cell IDs of existing memories this one replaces"
+    }
+  }
+}`
+
+	memoryForgetName        = "memory_forget"
+	memoryForgetDescription = "Forget memories so they no longer appear in memory_search results.\nUse this when the user asks you to forget something or a memory is wrong or outdated. Find the cell IDs with memory_search first."
+	memoryForgetInputSchema = `{
+  "type": "object",
+  "required": ["cell_ids"],
+  "properties": {
+    "cell_ids": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Cell IDs of the memories to forget, as shown by memory_search"
+    }
+  }
+}`
+)
+
+type saveInput struct {
+	Content    string   `json:"content"`
+	CellType   string   `json:"cell_type"`
+	Topic      string   `json:"topic"`
+	Scope      string   `json:"scope"`
+	Supersedes []string `json:"supersedes"`
+}
+
+type forgetInput struct {
+	CellIDs []string `json:"cell_ids"`
+}
+
+// MemorySaveTool lets the agent explicitly store a memory cell.
+type MemorySaveTool struct {
+	db         *memdb.DB
+	embedder   memdb.Embedder
+	muninnSink *muninn.Sink
+}
+
+// NewMemorySaveTool creates a new memory save tool.
+func NewMemorySaveTool(db *memdb.DB, embedder memdb.Embedder, muninnSink *muninn.Sink) *MemorySaveTool {
+	return &MemorySaveTool{db: db, embedder: embedder, muninnSink: muninnSink}
+}
+
+// Tool returns the llm.Tool definition for memory save.
+func (t *MemorySaveTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        memorySaveName,
+		Description: memorySaveDescription,
+		InputSchema: llm.MustSchema(memorySaveInputSchema),
+		Run:         t.Run,
+	}
+}
+
+// Run executes the memory save tool.
+func (t *MemorySaveTool) Run(ctx context.Context, input json.RawMessage) llm.ToolOut {
+	var in saveInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return llm.ErrorfToolOut("failed to parse input: %w", err)
+	}
+
+	if t.db == nil {
+		return llm.ToolOut{LLMContent: llm.TextContent("No memory index found. Memories cannot be saved in this session.")}
+	}
+
+	conversationProject := memdb.ProjectID(claudetool.WorkingDir(ctx))
+	var project string
+	if in.Scope != "global" {
+		project = conversationProject
+	}
+
+	// Only memories memory_search can show this conversation are replaced.
+	superseded, err := t.db.LiveCells(conversationProject, in.Supersedes)
+	if err != nil {
+		return llm.ErrorfToolOut("memory save failed: %w", err)
+	}
+	supersededIDs := make([]string, len(superseded))
+	for i, c := range superseded {
+		supersededIDs[i] = c.CellID
+	}
+
+	conversationID := llmhttp.ConversationIDFromContext(ctx)
+	cell, err := t.db.SaveManualMemory(ctx, memdb.ManualMemory{
+		CellType:   in.CellType,
+		Content:    in.Content,
+		TopicHint:  in.Topic,
+		SourceID:   conversationID,
+		Project:    project,
+		Supersedes: supersededIDs,
+	}, t.embedder)
+	if err != nil {
+		return llm.ErrorfToolOut("memory save failed: %w", err)
+	}
+
+	if t.muninnSink != nil {
+		concept := in.Topic
+		if concept == "" {
+			concept = cell.CellType
+		}
+		engram := muninn.CellEngram{
+			Concept: concept,
+			Content: cell.Content,
+			Tags:    []string{cell.CellType, "manual"},
+		}
+		if project != "" {
+			engram.Tags = append(engram.Tags, "project:"+project)
+		}
+		go func() {
+			pushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := t.muninnSink.Push(pushCtx, conversationID, "", []muninn.CellEngram{engram}); err != nil {
+				slog.Warn("MuninnDB push failed", "error", err, "cell_id", cell.CellID)
+			}
+		}()
+	}
+
+	msg := fmt.Sprintf("Saved %s memory %s.", cell.CellType, cell.CellID)
+	if len(supersededIDs) > 0 {
+		msg += fmt.Sprintf(" Superseded: %s.", strings.Join(supersededIDs, ", "))
+	}
+	if len(supersededIDs) < len(in.Supersedes) {
+		msg += " Other supersedes IDs were not found in this project's memories and were ignored."
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(msg)}
+}
+
+// MemoryForgetTool lets the agent mark memory cells as superseded.
+type MemoryForgetTool struct {
+	db *memdb.DB
+}
+
+// NewMemoryForgetTool creates a new memory forget tool.
+func NewMemoryForgetTool(db *memdb.DB) *MemoryForgetTool {
+	return &MemoryForgetTool{db: db}
+}
+
+// Tool returns the llm.Tool definition for memory forget.
+func (t *MemoryForgetTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        memoryForgetName,
+		Description: memoryForgetDescription,
+		InputSchema: llm.MustSchema(memoryForgetInputSchema),
+		Run:         t.Run,
+	}
+}
+
+// Run executes the memory forget tool.
+func (t *MemoryForgetTool) Run(ctx context.Context, input json.RawMessage) llm.ToolOut {
+	var in forgetInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return llm.ErrorfToolOut("failed to parse input: %w", err)
+	}
+
+	if t.db == nil {
+		return llm.ToolOut{LLMContent: llm.TextContent("No memory index found. Memories cannot be forgotten in this session.")}
+	}
+	if len(in.CellIDs) == 0 {
+		return llm.ErrorfToolOut("cell_ids is required")
+	}
+
+	forgotten, err := t.db.ForgetCells(memdb.ProjectID(claudetool.WorkingDir(ctx)), in.CellIDs)
+	if err != nil {
+		return llm.ErrorfToolOut("memory forget failed: %w", err)
+	}
+	if len(forgotten) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("No matching memories found; nothing was forgotten.")}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Forgot %d memories:\n", len(forgotten))
+	for _, c := range forgotten {
+		fmt.Fprintf(&b, "- %s [%s] %s\n", c.CellID, c.CellType, c.Content)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(b.String())}
+}
@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
+	memdb "github.com/tgruben-circuit/percy/memory"
+)
+
+func TestMemorySaveAndForgetTools(t *testing.T) {
+	dir := t.TempDir()
+	db, err := memdb.Open(filepath.Join(dir, "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := llmhttp.WithConversationID(context.Background(), "conv-42")
+
+	save := NewMemorySaveTool(db, nil, nil)
+	input, _ := json.Marshal(saveInput{Content: "We use pnpm, not npm", CellType: "preference", Topic: "tooling"})
+	result := save.Run(ctx, input)
+	if result.Error != nil {
+		t.Fatalf("save: unexpected error: %v", result.Error)
+	}
+	text := result.LLMContent[0].Text
+	if !strings.Contains(text, "Saved preference memory manual_") {
+		t.Fatalf("unexpected save output: %s", text)
+	}
+	cellID := strings.TrimSuffix(strings.Fields(text)[3], ".")
+
+	cell, err := db.GetCell(cellID)
+	if err != nil || cell == nil {
+		t.Fatalf("GetCell(%s) = %v, %v", cellID, cell, err)
+	}
+	if cell.SourceID != "conv-42" {
+		t.Errorf("source_id = %q, want conv-42", cell.SourceID)
+	}
+
+	// The saved memory is immediately searchable, and its ID is shown.
+	search := NewMemorySearchTool(db, nil, nil)
+	input, _ = json.Marshal(searchInput{Query: "pnpm"})
+	text = search.Run(ctx, input).LLMContent[0].Text
+	if !strings.Contains(text, cellID) {
+		t.Fatalf("expected search output to include %s, got: %s", cellID, text)
+	}
+
+	forget := NewMemoryForgetTool(db)
+	input, _ = json.Marshal(forgetInput{CellIDs: []string{cellID}})
+	result = forget.Run(ctx, input)
+	if result.Error != nil {
+		t.Fatalf("forget: unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.LLMContent[0].Text, "Forgot 1 memories") {
+		t.Errorf("unexpected forget output: %s", result.LLMContent[0].Text)
+	}
+
+	input, _ = json.Marshal(searchInput{Query: "pnpm"})
+	text = search.Run(ctx, input).LLMContent[0].Text
+	if !strings.Contains(text, "No relevant memories found") {
+		t.Errorf("expected forgotten memory to be hidden, got: %s", text)
+	}
+}
+
+func TestMemorySaveSupersedesProject(t *testing.T) {
+	dir := t.TempDir()
+	db, err := memdb.Open(filepath.Join(dir, "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	project := memdb.ProjectID(dir)
+	for id, p := range map[string]string{"mine": project, "theirs": "/elsewhere"} {
+		err := db.InsertCell(memdb.Cell{
+			CellID: id, SourceType: "manual", SourceID: "user",
+			CellType: "fact", Salience: 0.5, Content: "deploys use " + id, Project: p,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only existing memories of the conversation's project are superseded
+	// and reported.
+	ctx := claudetool.WithWorkingDir(context.Background(), dir)
+	input, _ := json.Marshal(saveInput{Content: "Deploys use Argo", Supersedes: []string{"mine", "theirs", "missing"}})
+	result := NewMemorySaveTool(db, nil, nil).Run(ctx, input)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	text := result.LLMContent[0].Text
+	if !strings.Contains(text, "Superseded: mine.") || !strings.Contains(text, "ignored") {
+		t.Errorf("save output = %s", text)
+	}
+	for id, want := range map[string]bool{"mine": true, "theirs": false} {
+		if c, err := db.GetCell(id); err != nil || c.Superseded != want {
+			t.Errorf("%s: superseded = %v, want %v (%v)", id, c.Superseded, want, err)
+		}
+	}
+
+	// Nor can memory_forget reach another project's memories.
+	input, _ = json.Marshal(forgetInput{CellIDs: []string{"theirs"}})
+	result = NewMemoryForgetTool(db).Run(ctx, input)
+	if !strings.Contains(result.LLMContent[0].Text, "nothing was forgotten") {
+		t.Errorf("forget output = %s", result.LLMContent[0].Text)
+	}
+}
+
+func TestMemorySaveToolErrors(t *testing.T) {
+	dir := t.TempDir()
+	db, err := memdb.Open(filepath.Join(dir, "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	save := NewMemorySaveTool(db, nil, nil)
+	input, _ := json.Marshal(saveInput{Content: "x", CellType: "gossip"})
+	if result := save.Run(context.Background(), input); result.Error == nil {
+		t.Error("expected error for invalid cell_type")
+	}
+
+	forget := NewMemoryForgetTool(db)
+	input, _ = json.Marshal(forgetInput{})
+	if result := forget.Run(context.Background(), input); result.Error == nil {
+		t.Error("expected error for missing cell_ids")
+	}
+
+	nilSave := NewMemorySaveTool(nil, nil, nil)
+	input, _ = json.Marshal(saveInput{Content: "x"})
+	result := nilSave.Run(context.Background(), input)
+	if !strings.Contains(result.LLMContent[0].Text, "No memory index found") {
+		t.Errorf("expected nil-db message, got: %s", result.LLMContent[0].Text)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/tgruben-circuit/percy/claudetool"
 	"github.com/tgruben-circuit/percy/llm"
 	memdb "github.com/tgruben-circuit/percy/memory"
 	"github.com/tgruben-circuit/percy/memory/muninn"
@@ -24,9 +25,14 @@ const (
     },
     "source_type": {
       "type": "string",
-      "enum": ["conversation", "file", "all"],
+      "enum": ["conversation", "file", "manual", "all"],
       "description": "Filter results by source type. Defaults to all."
     },
+    "scope": {
+      "type": "string",
+      "enum": ["project", "global"],
+      "description": "project: memories from the current repository plus global ones. global: memories from all repositories. Defaults to project."
+    },
     "detail_level": {
       "type": "string",
       "enum": ["summary", "full"],
@@ -43,6 +49,7 @@ const (
 type searchInput struct {
 	Query       string `json:"query"`
 	SourceType  string `json:"source_type"`
+	Scope       string `json:"scope"`
 	DetailLevel string `json:"detail_level"`
 	Limit       int    `json:"limit"`
 }
@@ -100,7 +107,12 @@ func (t *MemorySearchTool) Run(ctx context.Context, input json.RawMessage) llm.T
 		}
 	}
 
-	results, err := t.db.TwoTierSearch(in.Query, queryVec, sourceType, in.Limit)
+	var project string
+	if in.Scope != "global" {
+		project = memdb.ProjectID(claudetool.WorkingDir(ctx))
+	}
+
+	results, err := t.db.TwoTierSearch(in.Query, queryVec, sourceType, project, in.Limit)
 	if err != nil {
 		return llm.ErrorfToolOut("memory search failed: %w", err)
 	}
@@ -127,6 +139,15 @@ func (t *MemorySearchTool) Run(ctx context.Context, input json.RawMessage) llm.T
 		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("No relevant memories found for: %s", in.Query))}
 	}
 
+	// Cells that are actually returned gain salience, offsetting retention decay.
+	var usedIDs []string
+	for _, r := range results {
+		if r.ResultType == "cell" && r.CellID != "" {
+			usedIDs = append(usedIDs, r.CellID)
+		}
+	}
+	_ = t.db.RecordCellUse(usedIDs) // best-effort
+
 	return llm.ToolOut{
 		LLMContent: llm.TextContent(formatMemoryResults(results)),
 		Display:    results,
@@ -142,7 +163,7 @@ func formatMemoryResults(results []memdb.MemoryResult) string {
 		case "topic_summary":
 			fmt.Fprintf(&b, "--- Topic Summary: %q (updated %s) ---\n%s\n\n", r.TopicName, r.UpdatedAt, r.Content)
 		case "cell":
-			fmt.Fprintf(&b, "--- Result %d [%s] (score: %.2f, salience: %.1f) ---\n%s\n\n", i+1, r.CellType, r.Score, r.Salience, r.Content)
+			fmt.Fprintf(&b, "--- Result %d [%s] (id: %s, score: %.2f, salience: %.1f) ---\n%s\n\n", i+1, r.CellType, r.CellID, r.Score, r.Salience, r.Content)
 		case "muninn":
 			fmt.Fprintf(&b, "--- Muninn Memory: %q (score: %.2f) ---\n%s\n\n", r.TopicName, r.Score, r.Content)
 		}
@@ -67,6 +67,15 @@ func TestMemorySearchTool(t *testing.T) {
 		t.Errorf("expected 'Topic Summary' in output, got: %s", text)
 	}
 	t.Logf("result: %s", text)
+
+	// Returned cells are boosted.
+	c1, err := db.GetCell("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1.Salience <= 0.9 {
+		t.Errorf("expected c1 salience boosted above 0.9, got %v", c1.Salience)
+	}
 }
 
 func TestMemorySearchToolNoDatabase(t *testing.T) {
@@ -14,6 +14,8 @@ import (
 	"strings"
 
 	"github.com/pkg/diff"
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+	"github.com/tgruben-circuit/percy/claudetool/postedit"
 	"github.com/tgruben-circuit/percy/llm"
 	"sketch.dev/claudetool/editbuf"
 	"sketch.dev/claudetool/patchkit"
@@ -39,6 +41,10 @@ type PatchTool struct {
 	// NB: The actual implementation of the patch tool is unchanged,
 	// this flag merely extends the description and input schema to include the clipboard operations.
 	ClipboardEnabled bool
+	// Checkpoints, if set, saves files before they are patched.
+	Checkpoints checkpoint.Saver
+	// PostEdit, if set, formats patched files and reports new errors in them.
+	PostEdit *postedit.Hook
 	// clipboards stores clipboard name -> text
 	clipboards map[string]string
 }
@@ -50,13 +56,14 @@ func (p *PatchTool) getWorkingDir() string {
 
 // Tool returns an llm.Tool based on p.
 func (p *PatchTool) Tool() *llm.Tool {
-	description := PatchBaseDescription + PatchUsageNotes
+	description := PatchBaseDescription + PatchDiffDescription + PatchUsageNotes
 	schema := PatchStandardInputSchema
 	switch {
 	case p.Simplified:
+		description = PatchBaseDescription + PatchUsageNotes
 		schema = PatchStandardSimplifiedSchema
 	case p.ClipboardEnabled:
-		description = PatchBaseDescription + PatchClipboardDescription + PatchUsageNotes
+		description = PatchBaseDescription + PatchDiffDescription + PatchClipboardDescription + PatchUsageNotes
 		schema = PatchClipboardInputSchema
 	}
 	return &llm.Tool{
@@ -77,6 +84,14 @@ Operations:
 - append_eof: Append new text at the end of the file
 - prepend_bof: Insert new text at the beginning of the file
 - overwrite: Replace the entire file with new content (automatically creates the file)
+`
+
+	PatchDiffDescription = `
+Unified diffs:
+- Instead of path and patches, pass diff: a unified diff, as produced by git diff or diff -u, that may span many files
+- Each hunk's context and removed lines must match the file; matching tolerates whitespace differences, and hunk line numbers are only hints
+- Use /dev/null as the old file to create a file, or as the new file to delete one
+- All files are changed or none are: if any hunk fails, nothing is written and the failing hunks are reported
 `
 
 	PatchClipboardDescription = `
@@ -113,15 +128,18 @@ large overwrite. Prefer incremental replace operations over full file overwrites
 	PatchStandardInputSchema = `
 {
   "type": "object",
-  "required": ["path", "patches"],
   "properties": {
     "path": {
       "type": "string",
-      "description": "Path to the file to patch"
+      "description": "Path to the file to patch (required unless diff is given)"
+    },
+    "diff": {
+      "type": "string",
+      "description": "A unified diff to apply atomically, possibly to several files (instead of path and patches)"
     },
     "patches": {
       "type": "array",
-      "description": "List of patch requests to apply",
+      "description": "List of patch requests to apply (required unless diff is given)",
       "items": {
         "type": "object",
         "required": ["operation", "newText"],
@@ -179,15 +197,18 @@ large overwrite. Prefer incremental replace operations over full file overwrites
 	PatchClipboardInputSchema = `
 {
   "type": "object",
-  "required": ["path", "patches"],
   "properties": {
     "path": {
       "type": "string",
-      "description": "Path to the file to patch"
+      "description": "Path to the file to patch (required unless diff is given)"
+    },
+    "diff": {
+      "type": "string",
+      "description": "A unified diff to apply atomically, possibly to several files (instead of path and patches)"
     },
     "patches": {
       "type": "array",
-      "description": "List of patch requests to apply",
+      "description": "List of patch requests to apply (required unless diff is given)",
       "items": {
         "type": "object",
         "required": ["operation"],
@@ -241,6 +262,7 @@ large overwrite. Prefer incremental replace operations over full file overwrites
 type PatchInput struct {
 	Path    string         `json:"path"`
 	Patches []PatchRequest `json:"patches"`
+	Diff    string         `json:"diff,omitempty"` // a unified diff, used instead of Path and Patches
 }
 
 // PatchInputOne is a simplified version of PatchInput for single patch operations.
@@ -313,7 +335,7 @@ func (p *PatchTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 func (p *PatchTool) patchParse(m json.RawMessage) (PatchInput, error) {
 	var input PatchInput
 	originalErr := json.Unmarshal(m, &input)
-	if originalErr == nil && len(input.Patches) > 0 {
+	if originalErr == nil && (len(input.Patches) > 0 || input.Diff != "") {
 		return input, nil
 	}
 	var inputOne PatchInputOne
@@ -353,6 +375,12 @@ func (p *PatchTool) patchParse(m json.RawMessage) (PatchInput, error) {
 // patchRun implements the guts of the patch tool.
 // It populates input from m.
 func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut {
+	if input.Diff != "" {
+		if len(input.Patches) > 0 {
+			return llm.ErrorfToolOut("pass either diff or patches, not both")
+		}
+		return p.diffRun(ctx, input.Diff)
+	}
 	path := input.Path
 	if !filepath.IsAbs(input.Path) {
 		// Use shared WorkingDir if available, then context, then Pwd fallback
@@ -451,70 +479,17 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 				return llm.ErrorfToolOut("patch %d: oldText cannot be empty for %s operation", i, patch.Operation)
 			}
 
-			// Attempt to apply the patch.
-			spec, count := patchkit.Unique(origStr, patch.OldText, newText)
-			switch count {
-			case 0:
-				// no matches, maybe recoverable, continued below
-			case 1:
-				// exact match, apply
-				slog.DebugContext(ctx, "patch_applied", "method", "unique")
-				spec.ApplyToEditBuf(buf)
-				continue
-			case 2:
-				// multiple matches
-				patchErr = errors.Join(patchErr, fmt.Errorf("old text not unique:\n%s", patch.OldText))
-				continue
-			default:
-				slog.ErrorContext(ctx, "unique returned unexpected count", "count", count)
-				patchErr = errors.Join(patchErr, fmt.Errorf("internal error"))
-				continue
-			}
-
-			// The following recovery mechanisms are heuristic.
-			// They aren't perfect, but they appear safe,
-			// and the cases they cover appear with some regularity.
-
-			// Try adjusting the whitespace prefix.
-			spec, ok := patchkit.UniqueDedent(origStr, patch.OldText, newText)
-			if ok {
-				slog.DebugContext(ctx, "patch_applied", "method", "unique_dedent")
-				spec.ApplyToEditBuf(buf)
-				updateToClipboard(patch, spec)
+			spec, method, err := matchOldText(ctx, origStr, patch.OldText, newText)
+			if err != nil {
+				patchErr = errors.Join(patchErr, err)
 				continue
 			}
-
-			// Try ignoring leading/trailing whitespace in a semantically safe way.
-			spec, ok = patchkit.UniqueInValidGo(origStr, patch.OldText, newText)
-			if ok {
-				slog.DebugContext(ctx, "patch_applied", "method", "unique_in_valid_go")
-				spec.ApplyToEditBuf(buf)
+			spec.ApplyToEditBuf(buf)
+			// The trimmed text may vary significantly from the original text,
+			// so don't put it in the clipboard.
+			if method != "unique" && method != "unique_trim" {
 				updateToClipboard(patch, spec)
-				continue
 			}
-
-			// Try ignoring semantically insignificant whitespace.
-			spec, ok = patchkit.UniqueGoTokens(origStr, patch.OldText, newText)
-			if ok {
-				slog.DebugContext(ctx, "patch_applied", "method", "unique_go_tokens")
-				spec.ApplyToEditBuf(buf)
-				updateToClipboard(patch, spec)
-				continue
-			}
-
-			// Try trimming the first line of the patch, if we can do so safely.
-			spec, ok = patchkit.UniqueTrim(origStr, patch.OldText, newText)
-			if ok {
-				slog.DebugContext(ctx, "patch_applied", "method", "unique_trim")
-				spec.ApplyToEditBuf(buf)
-				// Do NOT call updateToClipboard here,
-				// because the trimmed text may vary significantly from the original text.
-				continue
-			}
-
-			// No dice.
-			patchErr = errors.Join(patchErr, fmt.Errorf("old text not found:\n%s", patch.OldText))
-			continue
 		default:
 			return llm.ErrorfToolOut("unrecognized operation %q", patch.Operation)
 		}
@@ -532,6 +507,11 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	if err != nil {
 		return llm.ErrorToolOut(err)
 	}
+	var baseline postedit.Baseline
+	if p.PostEdit != nil {
+		baseline = p.PostEdit.Before(ctx, []string{input.Path})
+	}
+	p.checkpoint(ctx, input.Path)
 	if err := os.MkdirAll(filepath.Dir(input.Path), 0o700); err != nil {
 		return llm.ErrorfToolOut("failed to create directory %q: %w", filepath.Dir(input.Path), err)
 	}
@@ -548,6 +528,9 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	if autogenerated {
 		fmt.Fprintf(response, "<warning>%q appears to be autogenerated. Patches were applied anyway.</warning>\n", input.Path)
 	}
+	if p.PostEdit != nil {
+		response.WriteString(p.PostEdit.After(ctx, []string{input.Path}, baseline))
+	}
 
 	diff := generateUnifiedDiff(input.Path, string(orig), string(patched))
 
@@ -565,6 +548,67 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	}
 }
 
+// matchOldText finds the unique occurrence of oldText in haystack,
+// falling back to fuzzier matches if there is no exact one.
+// It returns a spec replacing the match with newText and the method that found it.
+func matchOldText(ctx context.Context, haystack, oldText, newText string) (*patchkit.Spec, string, error) {
+	spec, count := patchkit.Unique(haystack, oldText, newText)
+	switch count {
+	case 0:
+		// no matches, maybe recoverable, continued below
+	case 1:
+		// exact match, apply
+		slog.DebugContext(ctx, "patch_applied", "method", "unique")
+		return spec, "unique", nil
+	case 2:
+		// multiple matches
+		return nil, "", fmt.Errorf("old text not unique:\n%s", oldText)
+	default:
+		slog.ErrorContext(ctx, "unique returned unexpected count", "count", count)
+		return nil, "", fmt.Errorf("internal error")
+	}
+
+	// The following recovery mechanisms are heuristic.
+	// They aren't perfect, but they appear safe,
+	// and the cases they cover appear with some regularity.
+	recoveries := []struct {
+		method string
+		match  func(haystack, needle, replace string) (*patchkit.Spec, bool)
+	}{
+		// Try adjusting the whitespace prefix.
+		{"unique_dedent", patchkit.UniqueDedent},
+		// Try ignoring leading/trailing whitespace in a semantically safe way.
+		{"unique_in_valid_go", patchkit.UniqueInValidGo},
+		// Try ignoring semantically insignificant whitespace.
+		{"unique_go_tokens", patchkit.UniqueGoTokens},
+		// Try trimming the first line of the patch, if we can do so safely.
+		{"unique_trim", patchkit.UniqueTrim},
+	}
+	for _, r := range recoveries {
+		if spec, ok := r.match(haystack, oldText, newText); ok {
+			slog.DebugContext(ctx, "patch_applied", "method", r.method)
+			return spec, r.method, nil
+		}
+	}
+
+	// No dice.
+	return nil, "", fmt.Errorf("old text not found:\n%s", oldText)
+}
+
+// checkpoint saves path's contents before it is patched.
+func (p *PatchTool) checkpoint(ctx context.Context, path string) {
+	if p.Checkpoints == nil {
+		return
+	}
+	f, err := checkpoint.Read(path)
+	if err == nil {
+		err = p.Checkpoints.Save(ctx, []checkpoint.File{f})
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "failed to checkpoint file", "path", path, "error", err)
+	}
+}
+
 // IsAutogeneratedGoFile reports whether a Go file has markers indicating it was autogenerated.
 func IsAutogeneratedGoFile(buf []byte) bool {
 	for _, sig := range autogeneratedSignals {
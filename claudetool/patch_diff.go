@@ -0,0 +1,477 @@
+package claudetool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+	"github.com/tgruben-circuit/percy/claudetool/postedit"
+	"github.com/tgruben-circuit/percy/llm"
+	"sketch.dev/claudetool/editbuf"
+	"sketch.dev/claudetool/patchkit"
+)
+
+// PatchDiffDisplayData is the structured data sent to the UI for a diff
+// that changed several files.
+type PatchDiffDisplayData struct {
+	Files []PatchDisplayData `json:"files"`
+}
+
+// A diffFile is one file's part of a unified diff.
+type diffFile struct {
+	OldPath string // "" for /dev/null: the diff creates the file
+	NewPath string // "" for /dev/null: the diff deletes the file
+	Hunks   []diffHunk
+}
+
+// A diffHunk is one hunk of a unified diff.
+type diffHunk struct {
+	Header   string // the @@ line
+	OldStart int    // 1-based line of the hunk in the old file; a hint only
+	Old      string // context and removed lines
+	New      string // context and added lines
+}
+
+var hunkHeaderRE = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// parseUnifiedDiff parses a unified diff.
+// Hunk line counts are ignored, because models often get them wrong:
+// a hunk runs until the next hunk or file header.
+func parseUnifiedDiff(diff string) ([]diffFile, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	var (
+		files []diffFile
+		git   bool      // the current file has a "diff --git" header
+		hunk  *diffHunk // the hunk being read
+		last  byte      // the kind of the hunk's last line: ' ', '-' or '+'
+		blank int       // empty lines not yet known to be part of the hunk
+	)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" && hunk != nil {
+			// Models often strip the space from empty context lines, but an
+			// empty line may also separate the hunk from what follows.
+			blank++
+			continue
+		}
+		// The empty lines were context if the hunk goes on.
+		pending := blank
+		blank = 0
+		flushBlank := func() {
+			if pending > 0 {
+				hunk.Old += strings.Repeat("\n", pending)
+				hunk.New += strings.Repeat("\n", pending)
+				last = ' '
+			}
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			hunk, git = nil, true
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			hunk = nil
+			oldPath, newPath := diffHeaderPath(line[4:]), diffHeaderPath(lines[i+1][4:])
+			if git || (hasPrefixOrEmpty(oldPath, "a/") && hasPrefixOrEmpty(newPath, "b/")) {
+				oldPath, newPath = strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+			}
+			if oldPath == "" && newPath == "" {
+				return nil, fmt.Errorf("line %d: both files are /dev/null", i+1)
+			}
+			files = append(files, diffFile{OldPath: oldPath, NewPath: newPath})
+			git = false
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if len(files) == 0 {
+				return nil, fmt.Errorf("line %d: hunk before any file header (--- and +++ lines)", i+1)
+			}
+			m := hunkHeaderRE.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q", i+1, line)
+			}
+			oldStart, _ := strconv.Atoi(m[1])
+			f := &files[len(files)-1]
+			f.Hunks = append(f.Hunks, diffHunk{Header: m[0], OldStart: oldStart})
+			hunk = &f.Hunks[len(f.Hunks)-1]
+		case hunk == nil:
+			// Commit messages, "index" lines and other metadata.
+		case line[0] == '\\':
+			flushBlank()
+			// "\ No newline at end of file" applies to the previous line.
+			if last == ' ' || last == '-' {
+				hunk.Old = strings.TrimSuffix(hunk.Old, "\n")
+			}
+			if last == ' ' || last == '+' {
+				hunk.New = strings.TrimSuffix(hunk.New, "\n")
+			}
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			flushBlank()
+			text := line[1:] + "\n"
+			if line[0] != '+' {
+				hunk.Old += text
+			}
+			if line[0] != '-' {
+				hunk.New += text
+			}
+			last = line[0]
+		default:
+			// The end of the hunk.
+			hunk = nil
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no file headers (--- and +++ lines) found in diff")
+	}
+	return files, nil
+}
+
+// diffHeaderPath returns the path in a --- or +++ line, or "" for /dev/null.
+func diffHeaderPath(s string) string {
+	// Drop a timestamp, as produced by diff -u.
+	s, _, _ = strings.Cut(s, "\t")
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil && strings.HasPrefix(s, `"`) {
+		return unquoted
+	}
+	return s
+}
+
+func hasPrefixOrEmpty(s, prefix string) bool {
+	return s == "" || strings.HasPrefix(s, prefix)
+}
+
+// A fileChange is a file being changed, with its contents before and after.
+type fileChange struct {
+	orig    checkpoint.File
+	content []byte // nil if the file is removed
+	remove  bool
+}
+
+// diffRun applies a unified diff to any number of files, atomically:
+// either every file is changed or none is.
+func (p *PatchTool) diffRun(ctx context.Context, diff string) llm.ToolOut {
+	files, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to parse diff: %w", err)
+	}
+
+	var (
+		changes  []fileChange
+		display  []PatchDisplayData
+		failures []string
+		seen     = make(map[string]bool)
+	)
+	for _, f := range files {
+		oldPath, newPath := p.resolvePath(f.OldPath), p.resolvePath(f.NewPath)
+		name := cmp.Or(f.NewPath, f.OldPath)
+		for _, path := range []string{oldPath, newPath} {
+			if path != "" && seen[path] {
+				failures = append(failures, fmt.Sprintf("%s: changed more than once in the diff; combine its hunks", name))
+			}
+		}
+
+		var orig []byte
+		var mode fs.FileMode
+		if oldPath != "" {
+			info, err := os.Stat(oldPath)
+			if err == nil {
+				mode = info.Mode().Perm()
+				orig, err = os.ReadFile(oldPath)
+			}
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", f.OldPath, err))
+				continue
+			}
+		}
+		if newPath != "" && newPath != oldPath {
+			if _, err := os.Lstat(newPath); err == nil {
+				failures = append(failures, fmt.Sprintf("%s: the diff creates this file, but it already exists", f.NewPath))
+				continue
+			}
+		}
+
+		patched, errs := applyHunks(ctx, string(orig), f.Hunks)
+		for _, err := range errs {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		}
+		if len(errs) > 0 {
+			continue
+		}
+
+		seen[oldPath], seen[newPath] = true, true
+		if oldPath != "" && oldPath != newPath {
+			// The file is deleted or renamed.
+			changes = append(changes, fileChange{
+				orig:   checkpoint.File{Path: oldPath, Exists: true, Mode: mode, Content: orig},
+				remove: true,
+			})
+		}
+		if newPath != "" {
+			c := fileChange{orig: checkpoint.File{Path: newPath}, content: patched}
+			if newPath == oldPath {
+				c.orig = checkpoint.File{Path: newPath, Exists: true, Mode: mode, Content: orig}
+			} else if mode == 0 {
+				mode = 0o600
+			}
+			c.orig.Mode = mode
+			changes = append(changes, c)
+		}
+		display = append(display, PatchDisplayData{
+			Path:       cmp.Or(newPath, oldPath),
+			OldContent: string(orig),
+			NewContent: string(patched),
+			Diff:       generateUnifiedDiff(cmp.Or(newPath, oldPath), string(orig), string(patched)),
+		})
+	}
+	if len(failures) > 0 {
+		return llm.ErrorfToolOut("no files were changed, because the diff does not apply:\n%s", strings.Join(failures, "\n"))
+	}
+
+	return p.writeChanges(ctx, changes, display)
+}
+
+// writeChanges checkpoints and commits changes, runs the post-edit checks,
+// and reports what changed.
+func (p *PatchTool) writeChanges(ctx context.Context, changes []fileChange, display []PatchDisplayData) llm.ToolOut {
+	var edited []string
+	for _, c := range changes {
+		if !c.remove {
+			edited = append(edited, c.orig.Path)
+		}
+	}
+	var baseline postedit.Baseline
+	if p.PostEdit != nil {
+		baseline = p.PostEdit.Before(ctx, edited)
+	}
+
+	if p.Checkpoints != nil {
+		saved := make([]checkpoint.File, len(changes))
+		for i, c := range changes {
+			saved[i] = c.orig
+		}
+		if err := p.Checkpoints.Save(ctx, saved); err != nil {
+			slog.WarnContext(ctx, "failed to checkpoint files", "error", err)
+		}
+	}
+	if err := commitChanges(changes); err != nil {
+		return llm.ErrorfToolOut("no files were changed: %w", err)
+	}
+
+	response := new(strings.Builder)
+	fmt.Fprintf(response, "<patches_applied>all</patches_applied>\n")
+	for _, c := range changes {
+		switch {
+		case c.remove:
+			fmt.Fprintf(response, "deleted %s\n", c.orig.Path)
+		case !c.orig.Exists:
+			fmt.Fprintf(response, "created %s\n", c.orig.Path)
+		default:
+			fmt.Fprintf(response, "patched %s\n", c.orig.Path)
+		}
+		if !c.remove && strings.HasSuffix(c.orig.Path, ".go") && IsAutogeneratedGoFile(c.orig.Content) {
+			fmt.Fprintf(response, "<warning>%q appears to be autogenerated. Patches were applied anyway.</warning>\n", c.orig.Path)
+		}
+	}
+	if p.PostEdit != nil {
+		response.WriteString(p.PostEdit.After(ctx, edited, baseline))
+	}
+
+	out := llm.ToolOut{LLMContent: llm.TextContent(response.String())}
+	if len(display) == 1 {
+		out.Display = display[0]
+	} else {
+		out.Display = PatchDiffDisplayData{Files: display}
+	}
+	return out
+}
+
+// WriteFiles changes files to the given states, the way a diff is applied:
+// atomically, with checkpoints and post-edit checks. Files that don't exist
+// in files are removed. Other tools that edit code, such as
+// code_intelligence's rename, write through it.
+func (p *PatchTool) WriteFiles(ctx context.Context, files []checkpoint.File) llm.ToolOut {
+	var (
+		changes []fileChange
+		display []PatchDisplayData
+	)
+	for _, f := range files {
+		path := p.resolvePath(f.Path)
+		c := fileChange{orig: checkpoint.File{Path: path, Mode: cmp.Or(f.Mode.Perm(), 0o600)}, remove: !f.Exists}
+		if !c.remove {
+			c.content = f.Content
+		}
+		info, err := os.Stat(path)
+		switch {
+		case err == nil:
+			orig, err := os.ReadFile(path)
+			if err != nil {
+				return llm.ErrorfToolOut("no files were changed: %w", err)
+			}
+			c.orig = checkpoint.File{Path: path, Exists: true, Mode: info.Mode().Perm(), Content: orig}
+		case !os.IsNotExist(err):
+			return llm.ErrorfToolOut("no files were changed: %w", err)
+		case c.remove:
+			continue // already gone
+		}
+		changes = append(changes, c)
+		display = append(display, PatchDisplayData{
+			Path:       path,
+			OldContent: string(c.orig.Content),
+			NewContent: string(c.content),
+			Diff:       generateUnifiedDiff(path, string(c.orig.Content), string(c.content)),
+		})
+	}
+	if len(changes) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("no files were changed")}
+	}
+	return p.writeChanges(ctx, changes, display)
+}
+
+// resolvePath makes a path from a diff absolute, relative to the working directory.
+func (p *PatchTool) resolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.getWorkingDir(), path)
+}
+
+// applyHunks applies hunks to orig, reporting every hunk that fails.
+// Hunks are located in orig, not in the result of earlier hunks.
+func applyHunks(ctx context.Context, orig string, hunks []diffHunk) ([]byte, []error) {
+	buf := editbuf.NewBuffer([]byte(orig))
+	var errs []error
+	for i, h := range hunks {
+		spec, err := locateHunk(ctx, orig, h)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hunk %d (%s): %w", i+1, h.Header, err))
+			continue
+		}
+		spec.ApplyToEditBuf(buf)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	patched, err := buf.Bytes()
+	if err != nil {
+		return nil, []error{fmt.Errorf("hunks overlap: %w", err)}
+	}
+	return patched, nil
+}
+
+// locateHunk finds where h applies in orig. An exact match nearest the
+// hunk's line number wins; otherwise the hunk must match uniquely,
+// with the same whitespace and Go token leniency as replace operations.
+func locateHunk(ctx context.Context, orig string, h diffHunk) (*patchkit.Spec, error) {
+	hint := lineOffset(orig, h.OldStart-1)
+	if h.Old == "" {
+		// A pure insertion goes after line OldStart.
+		off := lineOffset(orig, h.OldStart)
+		return &patchkit.Spec{Off: off, Src: orig, New: h.New}, nil
+	}
+	best := -1
+	for off := 0; ; {
+		i := strings.Index(orig[off:], h.Old)
+		if i < 0 {
+			break
+		}
+		if best < 0 || abs(off+i-hint) < abs(best-hint) {
+			best = off + i
+		}
+		off += i + 1
+	}
+	if best >= 0 {
+		return &patchkit.Spec{Off: best, Len: len(h.Old), Src: orig, Old: h.Old, New: h.New}, nil
+	}
+	spec, _, err := matchOldText(ctx, orig, h.Old, h.New)
+	return spec, err
+}
+
+// lineOffset returns the offset of the start of the 0-based line n in s,
+// clamped to s.
+func lineOffset(s string, n int) int {
+	off := 0
+	for ; n > 0; n-- {
+		i := strings.IndexByte(s[off:], '\n')
+		if i < 0 {
+			return len(s)
+		}
+		off += i + 1
+	}
+	return off
+}
+
+// commitChanges writes and removes files. Each file is written to a
+// temporary file first and renamed into place; if anything fails,
+// the files already changed are restored.
+func commitChanges(changes []fileChange) error {
+	temps := make([]string, len(changes))
+	defer func() {
+		for _, t := range temps {
+			if t != "" {
+				os.Remove(t)
+			}
+		}
+	}()
+	for i, c := range changes {
+		if c.remove {
+			continue
+		}
+		dir := filepath.Dir(c.orig.Path)
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create directory %q: %w", dir, err)
+		}
+		tmp, err := os.CreateTemp(dir, "."+filepath.Base(c.orig.Path)+".patch-*")
+		if err != nil {
+			return fmt.Errorf("failed to write %q: %w", c.orig.Path, err)
+		}
+		temps[i] = tmp.Name()
+		_, err = tmp.Write(c.content)
+		if err == nil {
+			err = tmp.Chmod(c.orig.Mode)
+		}
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %q: %w", c.orig.Path, err)
+		}
+	}
+
+	for i, c := range changes {
+		var err error
+		if c.remove {
+			err = os.Remove(c.orig.Path)
+		} else {
+			err = os.Rename(temps[i], c.orig.Path)
+			temps[i] = ""
+		}
+		if err != nil {
+			originals := make([]checkpoint.File, i)
+			for j := range originals {
+				originals[j] = changes[j].orig
+			}
+			if rollbackErr := checkpoint.Restore(originals); rollbackErr != nil {
+				return fmt.Errorf("failed to change %q: %w; restoring the files already changed also failed: %v", c.orig.Path, err, rollbackErr)
+			}
+			return fmt.Errorf("failed to change %q: %w", c.orig.Path, err)
+		}
+	}
+	return nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,270 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func runPatchDiff(t *testing.T, patch *PatchTool, diff string) llm.ToolOut {
+	t.Helper()
+	msg, err := json.Marshal(PatchInput{Diff: diff})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return patch.Run(context.Background(), msg)
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPatchTool_DiffMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.go":   "package a\n\nfunc A() int {\n\treturn 1\n}\n",
+		"b.txt":  "one\ntwo\nthree\n",
+		"old.md": "gone\n",
+	})
+	saver := &recordingSaver{}
+	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(dir), Checkpoints: saver}
+
+	diff := `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -3,3 +3,3 @@ package a
+ func A() int {
+-	return 1
++	return 2
+ }
+diff --git a/b.txt b/b.txt
+--- a/b.txt
++++ b/b.txt
+@@ -1,3 +1,4 @@
+ one
++one and a half
+ two
+ three
+--- a/old.md
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+--- /dev/null
++++ b/sub/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+\ No newline at end of file
+`
+	result := runPatchDiff(t, patch, diff)
+	if result.Error != nil {
+		t.Fatalf("diff failed: %v", result.Error)
+	}
+
+	if got := readTestFile(t, dir, "a.go"); got != "package a\n\nfunc A() int {\n\treturn 2\n}\n" {
+		t.Errorf("a.go = %q", got)
+	}
+	if got := readTestFile(t, dir, "b.txt"); got != "one\none and a half\ntwo\nthree\n" {
+		t.Errorf("b.txt = %q", got)
+	}
+	if got := readTestFile(t, dir, "sub/new.txt"); got != "hello\nworld" {
+		t.Errorf("sub/new.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.md")); !os.IsNotExist(err) {
+		t.Errorf("old.md was not deleted: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "b.txt")); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("b.txt mode = %v, %v", info.Mode(), err)
+	}
+
+	display, ok := result.Display.(PatchDiffDisplayData)
+	if !ok || len(display.Files) != 4 {
+		t.Errorf("display = %#v", result.Display)
+	}
+	if f := saver.files["b.txt"]; !f.Exists || string(f.Content) != "one\ntwo\nthree\n" {
+		t.Errorf("b.txt checkpoint = %+v", f)
+	}
+	if f, ok := saver.files["new.txt"]; !ok || f.Exists {
+		t.Errorf("new.txt checkpoint = %+v, %v", f, ok)
+	}
+}
+
+func TestPatchTool_DiffAtomic(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.txt": "alpha\nbeta\n",
+		"b.txt": "gamma\ndelta\n",
+	})
+	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(dir)}
+
+	diff := `--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+-alpha
++ALPHA
+ beta
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+ gamma
+-epsilon
++EPSILON
+@@ -2 +2 @@
+-delta
++DELTA
+`
+	result := runPatchDiff(t, patch, diff)
+	if result.Error == nil {
+		t.Fatal("expected the diff to fail")
+	}
+	msg := result.Error.Error()
+	if !strings.Contains(msg, "no files were changed") || !strings.Contains(msg, "b.txt: hunk 1 (@@ -1,2 +1,2 @@)") {
+		t.Errorf("error = %s", msg)
+	}
+	if strings.Contains(msg, "hunk 2") || strings.Contains(msg, "a.txt") {
+		t.Errorf("error reports hunks that apply: %s", msg)
+	}
+	if got := readTestFile(t, dir, "a.txt"); got != "alpha\nbeta\n" {
+		t.Errorf("a.txt was changed: %q", got)
+	}
+}
+
+func TestPatchTool_WriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.txt":   "alpha\n",
+		"old.txt": "gone\n",
+	})
+	saver := &recordingSaver{}
+	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(dir), Checkpoints: saver}
+
+	result := patch.WriteFiles(context.Background(), []checkpoint.File{
+		{Path: "a.txt", Exists: true, Content: []byte("ALPHA\n")},
+		{Path: filepath.Join(dir, "new.txt"), Exists: true, Content: []byte("new\n")},
+		{Path: "old.txt"},
+		{Path: "missing.txt"},
+	})
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	text := result.LLMContent[0].Text
+	for _, want := range []string{"<patches_applied>all</patches_applied>", "a.txt", "new.txt", "old.txt"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "missing.txt") {
+		t.Errorf("result reports removing a file that didn't exist:\n%s", text)
+	}
+	if got := readTestFile(t, dir, "a.txt"); got != "ALPHA\n" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if got := readTestFile(t, dir, "new.txt"); got != "new\n" {
+		t.Errorf("new.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("old.txt was not removed: %v", err)
+	}
+	if display, ok := result.Display.(PatchDiffDisplayData); !ok || len(display.Files) != 3 {
+		t.Errorf("display = %#v", result.Display)
+	}
+	if f := saver.files["a.txt"]; string(f.Content) != "alpha\n" {
+		t.Errorf("checkpoint of a.txt = %q", f.Content)
+	}
+	if f, ok := saver.files["new.txt"]; !ok || f.Exists {
+		t.Errorf("checkpoint of new.txt = %+v, %v", f, ok)
+	}
+
+	if result := patch.WriteFiles(context.Background(), []checkpoint.File{{Path: "missing.txt"}}); result.Error != nil || result.LLMContent[0].Text != "no files were changed" {
+		t.Errorf("removing a missing file: %+v", result)
+	}
+}
+
+func TestPatchTool_DiffFuzzyMatching(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"x.go": "package x\n\nfunc f() {\n\tif true {\n\t\tprintln(\"a\")\n\t}\n}\n\nfunc g() {\n\tif true {\n\t\tprintln(\"a\")\n\t}\n}\n",
+	})
+	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(dir)}
+
+	// The context is indented with spaces, the hunk's line numbers are
+	// wrong, and an empty line trails the hunk.
+	diff := `--- x.go
++++ x.go
+@@ -20,4 +20,4 @@
+ func g() {
+     if true {
+-        println("a")
++        println("b")
+     }
+
+`
+	if result := runPatchDiff(t, patch, diff); result.Error != nil {
+		t.Fatalf("diff failed: %v", result.Error)
+	}
+	got := readTestFile(t, dir, "x.go")
+	if f, g, _ := strings.Cut(got, "func g()"); !strings.Contains(f, `println("a")`) || !strings.Contains(g, `println("b")`) {
+		t.Errorf("x.go = %q", got)
+	}
+
+	// An ambiguous exact match is resolved by the hunk's line number.
+	diff = `--- x.go
++++ x.go
+@@ -4,3 +4,3 @@
+ 	if true {
+-		println("a")
++		println("c")
+ 	}
+`
+	if result := runPatchDiff(t, patch, diff); result.Error != nil {
+		t.Fatalf("diff failed: %v", result.Error)
+	}
+	if got := readTestFile(t, dir, "x.go"); !strings.Contains(got, "func f() {\n\tif true {\n\t\tprintln(\"c\")") {
+		t.Errorf("x.go = %q", got)
+	}
+}
+
+func TestParseUnifiedDiff(t *testing.T) {
+	for _, bad := range []string{
+		"",
+		"just some text\n",
+		"@@ -1 +1 @@\n-a\n+b\n",
+		"--- a\n+++ b\n@@ bogus @@\n",
+	} {
+		if _, err := parseUnifiedDiff(bad); err == nil {
+			t.Errorf("parseUnifiedDiff(%q) succeeded", bad)
+		}
+	}
+
+	files, err := parseUnifiedDiff("--- x.txt\t2024-01-01 00:00:00\n+++ x.txt\t2024-01-02 00:00:00\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\n--- y.txt\n+++ y.txt\n@@ -1 +1 @@\n-d\n+e\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].OldPath != "x.txt" || files[1].NewPath != "y.txt" {
+		t.Fatalf("files = %+v", files)
+	}
+	if h := files[0].Hunks[0]; h.Old != "a\nb\n" || h.New != "a\nc\n" || h.OldStart != 1 {
+		t.Errorf("hunk = %+v", h)
+	}
+}
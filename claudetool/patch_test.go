@@ -4,13 +4,45 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/tgruben-circuit/percy/claudetool/postedit"
 	"github.com/tgruben-circuit/percy/llm"
 )
 
+func TestPatchTool_Checkpoints(t *testing.T) {
+	tempDir := t.TempDir()
+	saver := &recordingSaver{}
+	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(tempDir), Checkpoints: saver}
+	ctx := context.Background()
+
+	run := func(name, text string) {
+		t.Helper()
+		msg, err := json.Marshal(PatchInput{
+			Path:    name,
+			Patches: []PatchRequest{{Operation: "overwrite", NewText: text}},
+		})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if result := patch.Run(ctx, msg); result.Error != nil {
+			t.Fatalf("patch failed: %v", result.Error)
+		}
+	}
+
+	run("new.txt", "first")
+	if f, ok := saver.files["new.txt"]; !ok || f.Exists {
+		t.Errorf("new file checkpoint = %+v, %v", f, ok)
+	}
+	run("new.txt", "second")
+	if f := saver.files["new.txt"]; !f.Exists || string(f.Content) != "first" {
+		t.Errorf("checkpoint before the second patch = %+v", f)
+	}
+}
+
 func TestPatchTool_BasicOperations(t *testing.T) {
 	tempDir := t.TempDir()
 	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(tempDir)}
@@ -427,6 +459,33 @@ func TestPatchTool_FlexibleInputParsing(t *testing.T) {
 	}
 }
 
+func TestPatchTool_PostEdit(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt not installed")
+	}
+	tempDir := t.TempDir()
+	wd := NewMutableWorkingDir(tempDir)
+	patch := &PatchTool{WorkingDir: wd, PostEdit: postedit.New(&postedit.Config{Format: true}, nil, wd.Get)}
+
+	msg, err := json.Marshal(PatchInput{
+		Path:    "a.go",
+		Patches: []PatchRequest{{Operation: "overwrite", NewText: "package a\nfunc  A() {}\n"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := patch.Run(context.Background(), msg)
+	if result.Error != nil {
+		t.Fatalf("patch failed: %v", result.Error)
+	}
+	if text := result.LLMContent[0].Text; !strings.Contains(text, `<formatted path="a.go" formatter="gofmt">`) {
+		t.Errorf("result = %q", text)
+	}
+	if got, _ := os.ReadFile(filepath.Join(tempDir, "a.go")); string(got) != "package a\n\nfunc A() {}\n" {
+		t.Errorf("a.go = %q", got)
+	}
+}
+
 func TestPatchTool_AutogeneratedDetection(t *testing.T) {
 	tempDir := t.TempDir()
 	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(tempDir)}
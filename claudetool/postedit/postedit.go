@@ -0,0 +1,282 @@
+// Package postedit checks files after the agent edits them: it runs the
+// language's formatter and reports the errors the language server finds
+// that weren't there before the edit, so the agent learns about them
+// without running a build.
+package postedit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tgruben-circuit/percy/claudetool/lsp"
+)
+
+const (
+	formatTimeout      = 10 * time.Second
+	diagnosticsTimeout = 3 * time.Second
+	// maxErrors caps the errors reported per file.
+	maxErrors = 20
+)
+
+// DefaultFormatters are the formatter commands used for each file extension
+// when formatting is enabled. The file's path is appended to the command.
+var DefaultFormatters = map[string][]string{
+	".go":   {"gofmt", "-w"},
+	".py":   {"ruff", "format", "--quiet"},
+	".ts":   {"prettier", "--write", "--log-level", "warn"},
+	".tsx":  {"prettier", "--write", "--log-level", "warn"},
+	".js":   {"prettier", "--write", "--log-level", "warn"},
+	".jsx":  {"prettier", "--write", "--log-level", "warn"},
+	".css":  {"prettier", "--write", "--log-level", "warn"},
+	".json": {"prettier", "--write", "--log-level", "warn"},
+}
+
+// Config configures post-edit checks. It is the "post_edit" object in
+// percy.json.
+type Config struct {
+	// Format runs the formatter for each edited file's extension.
+	// Formatters that aren't installed are skipped.
+	Format bool `json:"format,omitempty"`
+	// Formatters overrides DefaultFormatters by file extension, for
+	// example {".go": ["goimports", "-w"]}. An empty command disables
+	// formatting for the extension.
+	Formatters map[string][]string `json:"formatters,omitempty"`
+	// Diagnostics reports new errors from the language server (gopls,
+	// typescript-language-server, pyright or rust-analyzer) in the edited
+	// files.
+	Diagnostics bool `json:"diagnostics,omitempty"`
+}
+
+// Enabled reports whether c checks anything.
+func (c *Config) Enabled() bool {
+	return c != nil && (c.Format || c.Diagnostics)
+}
+
+// Validate checks c.
+func (c *Config) Validate() error {
+	for ext := range c.Formatters {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("post_edit formatter extension %q must start with a dot", ext)
+		}
+	}
+	return nil
+}
+
+// formatter returns the formatter command for path, or nil if there is none.
+func (c *Config) formatter(path string) []string {
+	ext := filepath.Ext(path)
+	if cmd, ok := c.Formatters[ext]; ok {
+		return cmd
+	}
+	return DefaultFormatters[ext]
+}
+
+// Hook runs the post-edit checks. Call Before with the files an edit is
+// about to change, and After once they are written.
+type Hook struct {
+	config     Config
+	workingDir func() string
+	// diagnostics returns the language server's diagnostics for a file,
+	// or an error if no server handles it.
+	diagnostics func(ctx context.Context, path string) ([]lsp.Diagnostic, error)
+
+	mu sync.Mutex
+	// known caches each file's errors by the hash of the content they were
+	// reported for, so consecutive edits don't ask the server twice.
+	known map[string]knownErrors
+}
+
+type knownErrors struct {
+	sum    [sha256.Size]byte
+	errors []lsp.Diagnostic
+}
+
+// New returns a Hook for cfg, or nil if cfg checks nothing. Diagnostics come
+// from manager's servers; manager may be nil if cfg.Diagnostics is false.
+func New(cfg *Config, manager *lsp.Manager, workingDirFn func() string) *Hook {
+	if !cfg.Enabled() {
+		return nil
+	}
+	h := &Hook{
+		config:     *cfg,
+		workingDir: workingDirFn,
+		known:      make(map[string]knownErrors),
+	}
+	if cfg.Diagnostics && manager != nil {
+		h.diagnostics = func(ctx context.Context, path string) ([]lsp.Diagnostic, error) {
+			srv, err := manager.GetServer(ctx, path)
+			if err != nil {
+				return nil, err
+			}
+			return srv.FileDiagnostics(ctx, path, diagnosticsTimeout)
+		}
+	}
+	return h
+}
+
+// Baseline holds the errors files had before an edit.
+type Baseline map[string][]lsp.Diagnostic
+
+// Before records the errors in paths, before they are edited.
+// Files that don't exist yet have none.
+func (h *Hook) Before(ctx context.Context, paths []string) Baseline {
+	baseline := make(Baseline)
+	if h.diagnostics == nil {
+		return baseline
+	}
+	for _, path := range paths {
+		if errs, ok := h.errors(ctx, path); ok {
+			baseline[path] = errs
+		}
+	}
+	return baseline
+}
+
+// After formats the edited files and returns a report for the agent of the
+// files that were reformatted, formatters that failed, and errors that
+// aren't in baseline. The report is empty if there is nothing to tell.
+func (h *Hook) After(ctx context.Context, paths []string, baseline Baseline) string {
+	var report strings.Builder
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue // deleted
+		}
+		rel := h.relativePath(path)
+		if h.config.Format {
+			if name, changed, err := h.format(ctx, path); err != nil {
+				fmt.Fprintf(&report, "<format_failed path=%q formatter=%q>\n%s\n</format_failed>\n", rel, name, strings.TrimSpace(err.Error()))
+			} else if changed {
+				fmt.Fprintf(&report, "<formatted path=%q formatter=%q>the file was reformatted; read it before patching the reformatted lines</formatted>\n", rel, name)
+			}
+		}
+		if h.diagnostics == nil {
+			continue
+		}
+		errs, ok := h.errors(ctx, path)
+		if !ok {
+			continue
+		}
+		if added := newErrors(baseline[path], errs); len(added) > 0 {
+			fmt.Fprintf(&report, "<new_errors path=%q>\n", rel)
+			for i, d := range added {
+				if i == maxErrors {
+					fmt.Fprintf(&report, "  ... and %d more\n", len(added)-maxErrors)
+					break
+				}
+				src := ""
+				if d.Source != "" {
+					src = fmt.Sprintf(" [%s]", d.Source)
+				}
+				fmt.Fprintf(&report, "  L%d:%d: %s%s\n", d.Range.Start.Line+1, d.Range.Start.Character+1, d.Message, src)
+			}
+			fmt.Fprintf(&report, "</new_errors>\n")
+		}
+	}
+	return report.String()
+}
+
+// format runs path's formatter and reports whether it changed the file.
+// It returns an empty name if path has no installed formatter.
+func (h *Hook) format(ctx context.Context, path string) (name string, changed bool, err error) {
+	command := h.config.formatter(path)
+	if len(command) == 0 {
+		return "", false, nil
+	}
+	name = command[0]
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		return "", false, nil
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		return name, false, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, formatTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, append(command[1:], path)...)
+	cmd.Dir = filepath.Dir(path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) == 0 {
+			return name, false, err
+		}
+		return name, false, fmt.Errorf("%s", out)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		return name, false, err
+	}
+	return name, !bytes.Equal(before, after), nil
+}
+
+// errors returns the errors in path's current content, and false if they
+// can't be determined.
+func (h *Hook) errors(ctx context.Context, path string) ([]lsp.Diagnostic, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	sum := sha256.Sum256(content)
+	h.mu.Lock()
+	known, ok := h.known[path]
+	h.mu.Unlock()
+	if ok && known.sum == sum {
+		return known.errors, true
+	}
+
+	diags, err := h.diagnostics(ctx, path)
+	if err != nil {
+		slog.DebugContext(ctx, "post-edit diagnostics unavailable", "path", path, "error", err)
+		return nil, false
+	}
+	var errs []lsp.Diagnostic
+	for _, d := range diags {
+		if d.Severity == lsp.DiagnosticSeverityError {
+			errs = append(errs, d)
+		}
+	}
+	h.mu.Lock()
+	h.known[path] = knownErrors{sum: sum, errors: errs}
+	h.mu.Unlock()
+	return errs, true
+}
+
+// newErrors returns the errors in after that aren't in before. Errors are
+// compared by message and source, not position, because edits move lines.
+func newErrors(before, after []lsp.Diagnostic) []lsp.Diagnostic {
+	seen := make(map[string]int)
+	for _, d := range before {
+		seen[d.Source+"\x00"+d.Message]++
+	}
+	var added []lsp.Diagnostic
+	for _, d := range after {
+		key := d.Source + "\x00" + d.Message
+		if seen[key] > 0 {
+			seen[key]--
+			continue
+		}
+		added = append(added, d)
+	}
+	sort.SliceStable(added, func(i, j int) bool {
+		return added[i].Range.Start.Line < added[j].Range.Start.Line
+	})
+	return added
+}
+
+func (h *Hook) relativePath(path string) string {
+	if h.workingDir != nil {
+		if rel, err := filepath.Rel(h.workingDir(), path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
@@ -0,0 +1,150 @@
+package postedit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool/lsp"
+)
+
+func TestHookFormat(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt not installed")
+	}
+	dir := t.TempDir()
+	h := New(&Config{Format: true}, nil, func() string { return dir })
+	ctx := context.Background()
+
+	path := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(path, []byte("package a\nfunc  A( ) {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report := h.After(ctx, []string{path}, h.Before(ctx, []string{path}))
+	if !strings.Contains(report, `<formatted path="a.go" formatter="gofmt">`) {
+		t.Errorf("report = %q", report)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "package a\n\nfunc A() {}\n" {
+		t.Errorf("a.go = %q", got)
+	}
+
+	// Formatted files aren't reported.
+	if report := h.After(ctx, []string{path}, nil); report != "" {
+		t.Errorf("report = %q, want empty", report)
+	}
+
+	if err := os.WriteFile(path, []byte("package a\nfunc A( {\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if report := h.After(ctx, []string{path}, nil); !strings.Contains(report, `<format_failed path="a.go" formatter="gofmt">`) {
+		t.Errorf("report = %q", report)
+	}
+
+	// Extensions without a formatter, and disabled formatters, are skipped.
+	h = New(&Config{Format: true, Formatters: map[string][]string{".go": nil}}, nil, func() string { return dir })
+	txt := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(txt, []byte("notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if report := h.After(ctx, []string{path, txt}, nil); report != "" {
+		t.Errorf("report = %q, want empty", report)
+	}
+}
+
+func TestHookDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	h := New(&Config{Diagnostics: true}, nil, func() string { return dir })
+	// Every line starting with "bad" is an error; "warn" lines are warnings.
+	calls := 0
+	h.diagnostics = func(ctx context.Context, path string) ([]lsp.Diagnostic, error) {
+		calls++
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var diags []lsp.Diagnostic
+		for i, line := range strings.Split(string(content), "\n") {
+			d := lsp.Diagnostic{Range: lsp.Range{Start: lsp.Position{Line: i}}, Source: "test", Message: line}
+			switch {
+			case strings.HasPrefix(line, "bad"):
+				d.Severity = lsp.DiagnosticSeverityError
+			case strings.HasPrefix(line, "warn"):
+				d.Severity = lsp.DiagnosticSeverityWarning
+			default:
+				continue
+			}
+			diags = append(diags, d)
+		}
+		return diags, nil
+	}
+	ctx := context.Background()
+	path := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(path, []byte("ok\nbad old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline := h.Before(ctx, []string{path})
+	if err := os.WriteFile(path, []byte("new line\nok\nbad old\nwarn new\nbad new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report := h.After(ctx, []string{path}, baseline)
+	if want := "<new_errors path=\"f.txt\">\n  L5:1: bad new [test]\n</new_errors>\n"; report != want {
+		t.Errorf("report = %q, want %q", report, want)
+	}
+
+	// The next edit's baseline comes from the cache.
+	calls = 0
+	baseline = h.Before(ctx, []string{path})
+	if calls != 0 {
+		t.Errorf("Before asked the server %d times, want 0", calls)
+	}
+	if err := os.WriteFile(path, []byte("ok\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if report := h.After(ctx, []string{path}, baseline); report != "" {
+		t.Errorf("report = %q, want empty", report)
+	}
+
+	// New files have no baseline, and files without a server are skipped.
+	created := filepath.Join(dir, "new.txt")
+	baseline = h.Before(ctx, []string{created})
+	if err := os.WriteFile(created, []byte("bad\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if report := h.After(ctx, []string{created}, baseline); !strings.Contains(report, "L1:1: bad [test]") {
+		t.Errorf("report = %q", report)
+	}
+	h.diagnostics = func(ctx context.Context, path string) ([]lsp.Diagnostic, error) {
+		return nil, errors.New("no LSP server")
+	}
+	if err := os.WriteFile(created, []byte("bad again\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if report := h.After(ctx, []string{created}, nil); report != "" {
+		t.Errorf("report = %q, want empty", report)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	var nilConfig *Config
+	if nilConfig.Enabled() || (&Config{}).Enabled() {
+		t.Error("empty config is enabled")
+	}
+	if New(&Config{}, nil, nil) != nil {
+		t.Error("New returned a hook for an empty config")
+	}
+	if err := (&Config{Formatters: map[string][]string{"go": {"gofmt"}}}).Validate(); err == nil {
+		t.Error("Validate accepted an extension without a dot")
+	}
+	c := &Config{Format: true, Formatters: map[string][]string{".go": {"goimports", "-w"}}}
+	if got := c.formatter("x/a.go"); len(got) != 2 || got[0] != "goimports" {
+		t.Errorf("formatter(a.go) = %v", got)
+	}
+	if got := c.formatter("a.py"); len(got) == 0 || got[0] != "ruff" {
+		t.Errorf("formatter(a.py) = %v", got)
+	}
+}
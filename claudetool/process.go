@@ -0,0 +1,725 @@
+package claudetool
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// ProcessManager runs the named background processes of a conversation,
+// such as dev servers and watchers, and keeps their recent output.
+// StopAll kills them; ToolSet.Cleanup calls it when the conversation ends.
+type ProcessManager struct {
+	// WorkingDir is the directory relative cwds are resolved against.
+	WorkingDir *MutableWorkingDir
+	// ConversationID is exposed to processes via PERCY_CONVERSATION_ID.
+	ConversationID string
+	// Sandbox, if enabled, isolates processes (see package sandbox).
+	Sandbox *sandbox.Config
+
+	mu    sync.Mutex
+	procs map[string]*managedProcess
+}
+
+// NewProcessManager returns a ProcessManager that starts processes in wd.
+func NewProcessManager(wd *MutableWorkingDir, conversationID string) *ProcessManager {
+	return &ProcessManager{WorkingDir: wd, ConversationID: conversationID, procs: map[string]*managedProcess{}}
+}
+
+const (
+	// processLogLimit is how much of a process's output is kept in memory;
+	// all of it is written to its log file.
+	processLogLimit = 1 << 20
+	// processStopGrace is how long stop waits after SIGTERM before SIGKILL.
+	processStopGrace = 5 * time.Second
+	// processPollInterval is how often wait checks its condition.
+	processPollInterval = 200 * time.Millisecond
+	defaultProcessWait  = 30 * time.Second
+	maxProcessWait      = 10 * time.Minute
+	defaultLogLines     = 50
+)
+
+type managedProcess struct {
+	name    string
+	command string
+	dir     string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	started time.Time
+	logPath string
+	output  *processOutput
+
+	done     chan struct{} // closed when the process exits
+	ended    time.Time
+	exitCode int
+}
+
+// processOutput keeps the last processLogLimit bytes of a process's
+// combined stdout and stderr, addressed by offsets from the start of the
+// output, and copies everything to a log file.
+type processOutput struct {
+	mu      sync.Mutex
+	buf     []byte
+	dropped int64 // bytes before buf[0]
+	file    *os.File
+}
+
+func (o *processOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file != nil {
+		o.file.Write(p)
+	}
+	o.buf = append(o.buf, p...)
+	if over := len(o.buf) - processLogLimit; over > 0 {
+		o.buf = slices.Clone(o.buf[over:])
+		o.dropped += int64(over)
+	}
+	return len(p), nil
+}
+
+// since returns the output from offset on, the offset it starts at (later
+// than offset if that was dropped), and the end offset.
+func (o *processOutput) since(offset int64) (data []byte, start, end int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	end = o.dropped + int64(len(o.buf))
+	start = min(max(offset, o.dropped), end)
+	return slices.Clone(o.buf[start-o.dropped:]), start, end
+}
+
+// tail returns the last n lines of output and the offset they start at.
+func (o *processOutput) tail(n int) ([]byte, int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	start, end := 0, len(o.buf)
+	if end > 0 && o.buf[end-1] == '\n' {
+		end--
+	}
+	for ; n > 0; n-- {
+		j := bytes.LastIndexByte(o.buf[:end], '\n')
+		if j < 0 {
+			start = 0
+			break
+		}
+		start, end = j+1, j
+	}
+	return slices.Clone(o.buf[start:]), o.dropped + int64(start)
+}
+
+func (p *managedProcess) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// ProcessInfo describes a managed process for display.
+type ProcessInfo struct {
+	Name     string `json:"name"`
+	Command  string `json:"command"`
+	Dir      string `json:"dir"`
+	PID      int    `json:"pid"`
+	Status   string `json:"status"` // "running" or "exited"
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Started  string `json:"started"`
+	Uptime   string `json:"uptime"`
+	Output   int64  `json:"output_bytes"`
+	LogFile  string `json:"log_file"`
+}
+
+func (p *managedProcess) info() ProcessInfo {
+	_, _, end := p.output.since(0)
+	info := ProcessInfo{
+		Name:    p.name,
+		Command: p.command,
+		Dir:     p.dir,
+		PID:     p.cmd.Process.Pid,
+		Status:  "running",
+		Started: p.started.Format(time.RFC3339),
+		Output:  end,
+		LogFile: p.logPath,
+	}
+	ended := time.Now()
+	if !p.running() {
+		info.Status = "exited"
+		code := p.exitCode
+		info.ExitCode = &code
+		ended = p.ended
+	}
+	info.Uptime = ended.Sub(p.started).Round(time.Second).String()
+	return info
+}
+
+func (i ProcessInfo) String() string {
+	var status string
+	if i.ExitCode != nil {
+		status = fmt.Sprintf("exited with code %d after %s", *i.ExitCode, i.Uptime)
+	} else {
+		status = fmt.Sprintf("running for %s", i.Uptime)
+	}
+	return fmt.Sprintf("%s (pid %d, %s): %s [in %s]", i.Name, i.PID, status, i.Command, i.Dir)
+}
+
+// processWaitDelay bounds how long the output of an exited process is read
+// while a child that left its process group keeps the pipe open.
+var processWaitDelay = 15 * time.Second
+
+// processNamePattern limits names to ones that are safe in log file names.
+var processNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// Start starts a named process running command with bash. A process that
+// has exited may be replaced; a running one may not.
+func (m *ProcessManager) Start(name, command, cwd string, env map[string]string) (*managedProcess, error) {
+	if !processNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid process name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	if strings.TrimSpace(command) == "" {
+		return nil, errors.New("command is required")
+	}
+	dir := m.WorkingDir.Get()
+	if cwd != "" {
+		if filepath.IsAbs(cwd) {
+			dir = cwd
+		} else {
+			dir = filepath.Join(dir, cwd)
+		}
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("working directory %s does not exist", dir)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.procs[name]; ok && old.running() {
+		return nil, fmt.Errorf("process %s is already running (pid %d); stop it first or use another name", name, old.cmd.Process.Pid)
+	}
+
+	logFile, err := os.CreateTemp("", "percy-process-"+name+"-*.log")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+	out := &processOutput{file: logFile}
+	cmd := exec.Command("bash", "--login", "-c", command)
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.WaitDelay = processWaitDelay
+	cmd.Env = commandEnv(m.ConversationID)
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		cmd.Env = append(cmd.Env, k+"="+env[k])
+	}
+	release, err := m.Sandbox.Wrap(cmd, sandbox.Workspace(dir))
+	if err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("failed to sandbox process: %w", err)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		logFile.Close()
+		release()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		release()
+		return nil, fmt.Errorf("failed to start: %w", err)
+	}
+
+	p := &managedProcess{
+		name:    name,
+		command: command,
+		dir:     dir,
+		cmd:     cmd,
+		stdin:   stdin,
+		started: time.Now(),
+		logPath: logFile.Name(),
+		output:  out,
+		done:    make(chan struct{}),
+	}
+	if old, ok := m.procs[name]; ok {
+		os.Remove(old.logPath)
+	}
+	go func() {
+		cmd.Wait()
+		release()
+		p.ended = time.Now()
+		p.exitCode = cmd.ProcessState.ExitCode()
+		out.mu.Lock()
+		logFile.Close()
+		out.file = nil
+		out.mu.Unlock()
+		close(p.done)
+	}()
+	m.procs[name] = p
+	return p, nil
+}
+
+// get returns the named process.
+func (m *ProcessManager) get(name string) (*managedProcess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.procs[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(m.procs))
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no process named %q; no processes have been started", name)
+		}
+		return nil, fmt.Errorf("no process named %q; processes: %s", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+// List returns the processes, running ones first.
+func (m *ProcessManager) List() []ProcessInfo {
+	m.mu.Lock()
+	procs := slices.Collect(maps.Values(m.procs))
+	m.mu.Unlock()
+	infos := make([]ProcessInfo, len(procs))
+	for i, p := range procs {
+		infos[i] = p.info()
+	}
+	slices.SortFunc(infos, func(a, b ProcessInfo) int {
+		return cmp.Or(cmp.Compare(b.Status, a.Status), cmp.Compare(a.Started, b.Started), cmp.Compare(a.Name, b.Name))
+	})
+	return infos
+}
+
+// stop signals the process group with SIGTERM, then SIGKILL after grace.
+func (p *managedProcess) stop(grace time.Duration) {
+	if !p.running() {
+		return
+	}
+	pgid := -p.cmd.Process.Pid
+	syscall.Kill(pgid, syscall.SIGTERM)
+	select {
+	case <-p.done:
+	case <-time.After(grace):
+		syscall.Kill(pgid, syscall.SIGKILL)
+		<-p.done
+	}
+}
+
+// StopAll kills all running processes and removes their log files.
+func (m *ProcessManager) StopAll() {
+	m.mu.Lock()
+	procs := slices.Collect(maps.Values(m.procs))
+	clear(m.procs)
+	m.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		wg.Go(func() {
+			p.stop(2 * time.Second)
+			os.Remove(p.logPath)
+		})
+	}
+	wg.Wait()
+}
+
+// ProcessTool exposes a ProcessManager to the agent.
+type ProcessTool struct {
+	Manager *ProcessManager
+}
+
+const (
+	processName        = "process"
+	processDescription = `Runs and manages long-running background processes such as dev servers, watchers and REPLs.
+Use this instead of bash for anything that keeps running; processes are killed when the conversation ends.
+
+Actions:
+- start: start a named process (bash command, optional cwd and env)
+- list: show processes and their status
+- logs: show output; by default the last 50 lines. Pass the returned next_offset as offset to get only new output, and grep to filter lines
+- send: write input to the process's stdin (a newline is added unless newline is false)
+- wait: wait until the process listens on a TCP port, or prints a line matching a regex
+- stop: stop the process (SIGTERM, then SIGKILL after 5s)
+`
+	processInputSchema = `{
+  "type": "object",
+  "required": ["action"],
+  "properties": {
+    "action": {
+      "type": "string",
+      "enum": ["start", "list", "logs", "send", "wait", "stop"]
+    },
+    "name": {
+      "type": "string",
+      "description": "Process name, e.g. \"server\"; required except for list"
+    },
+    "command": {
+      "type": "string",
+      "description": "Shell command to run (start)"
+    },
+    "cwd": {
+      "type": "string",
+      "description": "Directory to run in, absolute or relative to the working directory (start)"
+    },
+    "env": {
+      "type": "object",
+      "additionalProperties": {"type": "string"},
+      "description": "Extra environment variables (start)"
+    },
+    "offset": {
+      "type": "integer",
+      "description": "Byte offset to read output from, as returned by a previous call (logs)"
+    },
+    "lines": {
+      "type": "integer",
+      "description": "Number of trailing lines to show when no offset is given (logs, default 50)"
+    },
+    "grep": {
+      "type": "string",
+      "description": "Regular expression; only show matching lines (logs)"
+    },
+    "input": {
+      "type": "string",
+      "description": "Text to write to stdin (send)"
+    },
+    "newline": {
+      "type": "boolean",
+      "description": "Append a newline to input (send, default true)"
+    },
+    "port": {
+      "type": "integer",
+      "description": "TCP port to wait for on localhost (wait)"
+    },
+    "log": {
+      "type": "string",
+      "description": "Regular expression to wait for in the output (wait)"
+    },
+    "timeout": {
+      "type": "string",
+      "description": "How long to wait, as a Go duration (wait, default 30s, max 10m)"
+    }
+  }
+}`
+)
+
+type processInput struct {
+	Action  string            `json:"action"`
+	Name    string            `json:"name"`
+	Command string            `json:"command"`
+	Cwd     string            `json:"cwd"`
+	Env     map[string]string `json:"env"`
+	Offset  *int64            `json:"offset"`
+	Lines   int               `json:"lines"`
+	Grep    string            `json:"grep"`
+	Input   string            `json:"input"`
+	Newline *bool             `json:"newline"`
+	Port    int               `json:"port"`
+	Log     string            `json:"log"`
+	Timeout string            `json:"timeout"`
+}
+
+// ProcessDisplayData is the display data sent to the UIs for process tool
+// results.
+type ProcessDisplayData struct {
+	Action    string        `json:"action"`
+	Processes []ProcessInfo `json:"processes"`
+	// Output is the process output shown to the agent, if any.
+	Output string `json:"output,omitempty"`
+}
+
+// Tool returns an llm.Tool for managing background processes.
+func (t *ProcessTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        processName,
+		Description: strings.TrimSpace(processDescription),
+		InputSchema: llm.MustSchema(processInputSchema),
+		Run:         t.Run,
+	}
+}
+
+// Run executes the process tool.
+func (t *ProcessTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req processInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse process input: %w", err)
+	}
+	if req.Action == "list" {
+		return t.list()
+	}
+	if req.Name == "" {
+		return llm.ErrorfToolOut("name is required for %s", req.Action)
+	}
+
+	switch req.Action {
+	case "start":
+		return t.start(ctx, req)
+	case "logs":
+		return t.logs(req)
+	case "send":
+		return t.send(req)
+	case "wait":
+		return t.wait(ctx, req)
+	case "stop":
+		return t.stop(req)
+	default:
+		return llm.ErrorfToolOut("unknown action %q (use start, list, logs, send, wait or stop)", req.Action)
+	}
+}
+
+func processOut(action, text string, output []byte, procs ...ProcessInfo) llm.ToolOut {
+	if len(output) > 0 {
+		text += "\n\n" + string(output)
+	}
+	return llm.ToolOut{
+		LLMContent: llm.TextContent(text),
+		Display:    ProcessDisplayData{Action: action, Processes: procs, Output: string(output)},
+	}
+}
+
+func (t *ProcessTool) list() llm.ToolOut {
+	procs := t.Manager.List()
+	if len(procs) == 0 {
+		return processOut("list", "No processes.", nil)
+	}
+	lines := make([]string, len(procs))
+	for i, p := range procs {
+		lines[i] = p.String()
+	}
+	return processOut("list", strings.Join(lines, "\n"), nil, procs...)
+}
+
+func (t *ProcessTool) start(ctx context.Context, req processInput) llm.ToolOut {
+	p, err := t.Manager.Start(req.Name, req.Command, req.Cwd, req.Env)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	// Give quick failures, such as a bad command, a chance to show.
+	select {
+	case <-p.done:
+	case <-time.After(500 * time.Millisecond):
+	case <-ctx.Done():
+	}
+	info := p.info()
+	data, _, end := p.output.since(0)
+	text := fmt.Sprintf("Started %s\nLog file: %s\nnext_offset: %d", info, p.logPath, end)
+	if !p.running() && p.exitCode != 0 {
+		return llm.ErrorfToolOut("%s exited immediately with code %d:\n%s", req.Name, p.exitCode, tailLines(data, defaultLogLines))
+	}
+	return processOut("start", text, tailLines(data, defaultLogLines), info)
+}
+
+// tailLines returns the last n lines of data.
+func tailLines(data []byte, n int) []byte {
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return []byte(strings.Join(lines, ""))
+}
+
+func (t *ProcessTool) logs(req processInput) llm.ToolOut {
+	p, err := t.Manager.get(req.Name)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	var grep *regexp.Regexp
+	if req.Grep != "" {
+		if grep, err = regexp.Compile(req.Grep); err != nil {
+			return llm.ErrorfToolOut("invalid grep pattern: %w", err)
+		}
+	}
+
+	var data []byte
+	var start, end int64
+	var notes []string
+	if req.Offset != nil {
+		data, start, end = p.output.since(*req.Offset)
+		if start > *req.Offset {
+			notes = append(notes, fmt.Sprintf("output before offset %d is no longer in memory; see the log file %s", start, p.logPath))
+		}
+	} else {
+		n := cmp.Or(req.Lines, defaultLogLines)
+		if grep != nil {
+			// Filter all of the output, then take the last lines.
+			data, start, end = p.output.since(0)
+		} else {
+			data, start = p.output.tail(n)
+			end = start + int64(len(data))
+		}
+	}
+	if grep != nil {
+		var matched []string
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if line != "" && grep.MatchString(line) {
+				matched = append(matched, line)
+			}
+		}
+		if req.Offset == nil && len(matched) > cmp.Or(req.Lines, defaultLogLines) {
+			matched = matched[len(matched)-cmp.Or(req.Lines, defaultLogLines):]
+		}
+		data = []byte(strings.Join(matched, ""))
+	}
+	const maxLogBytes = 30 * 1024
+	if len(data) > maxLogBytes {
+		notes = append(notes, fmt.Sprintf("showing the last %d of %d bytes", maxLogBytes, len(data)))
+		data = data[len(data)-maxLogBytes:]
+	}
+
+	info := p.info()
+	header := fmt.Sprintf("%s\nBytes %d-%d; next_offset: %d", info, start, end, end)
+	for _, note := range notes {
+		header += "\nNote: " + note
+	}
+	if len(data) == 0 {
+		header += "\n(no output)"
+	}
+	return processOut("logs", header, data, info)
+}
+
+func (t *ProcessTool) send(req processInput) llm.ToolOut {
+	p, err := t.Manager.get(req.Name)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if !p.running() {
+		return llm.ErrorfToolOut("process %s has exited", req.Name)
+	}
+	input := req.Input
+	if req.Newline == nil || *req.Newline {
+		input += "\n"
+	}
+	if _, err := io.WriteString(p.stdin, input); err != nil {
+		return llm.ErrorfToolOut("failed to write to %s: %w", req.Name, err)
+	}
+	return processOut("send", fmt.Sprintf("Sent %d bytes to %s.", len(input), req.Name), nil, p.info())
+}
+
+func (t *ProcessTool) wait(ctx context.Context, req processInput) llm.ToolOut {
+	p, err := t.Manager.get(req.Name)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if (req.Port == 0) == (req.Log == "") {
+		return llm.ErrorfToolOut("give exactly one of port or log")
+	}
+	if req.Port != 0 && t.Manager.isolated() && t.Manager.Sandbox.Network == nil {
+		return llm.ErrorfToolOut("the sandboxed process has a network of its own, so its port can't be reached; wait for a log line instead")
+	}
+	timeout := defaultProcessWait
+	if req.Timeout != "" {
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil || timeout <= 0 {
+			return llm.ErrorfToolOut("invalid timeout %q", req.Timeout)
+		}
+		timeout = min(timeout, maxProcessWait)
+	}
+	var logRE *regexp.Regexp
+	if req.Log != "" {
+		if logRE, err = regexp.Compile(req.Log); err != nil {
+			return llm.ErrorfToolOut("invalid log pattern: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	var offset int64
+	for {
+		var found string
+		if req.Port != 0 {
+			if t.Manager.portOpen(ctx, req.Port) {
+				found = fmt.Sprintf("port %d is accepting connections", req.Port)
+			}
+		} else {
+			data, _, end := p.output.since(offset)
+			// Keep the last partial line to match once it is complete.
+			complete := strings.LastIndexByte(string(data), '\n') + 1
+			if !p.running() {
+				complete = len(data)
+			}
+			for _, line := range strings.SplitAfter(string(data[:complete]), "\n") {
+				if logRE.MatchString(line) {
+					found = "matched: " + strings.TrimSpace(line)
+					break
+				}
+			}
+			offset = end - int64(len(data)-complete)
+		}
+		if found != "" {
+			return processOut("wait", fmt.Sprintf("%s after %s", found, time.Since(start).Round(time.Millisecond)), nil, p.info())
+		}
+
+		if !p.running() {
+			data, _ := p.output.tail(20)
+			return llm.ErrorfToolOut("%s exited with code %d while waiting; last output:\n%s", req.Name, p.exitCode, data)
+		}
+		select {
+		case <-ctx.Done():
+			data, _ := p.output.tail(20)
+			return llm.ErrorfToolOut("timed out after %s waiting for %s; last output:\n%s", timeout, req.Name, data)
+		case <-p.done:
+		case <-time.After(processPollInterval):
+		}
+	}
+}
+
+// portOpen reports whether something accepts TCP connections on port on
+// the loopback interface the processes use.
+func (m *ProcessManager) portOpen(ctx context.Context, port int) bool {
+	if m.isolated() {
+		return m.Sandbox.Network.PortOpen(ctx, port)
+	}
+	return localPortOpen(ctx, port)
+}
+
+// isolated reports whether processes are sandboxed without network access,
+// so that they don't share Percy's loopback interface.
+func (m *ProcessManager) isolated() bool {
+	return m.Sandbox.Enabled() && m.Sandbox.Mode == sandbox.ModeIsolated
+}
+
+// localPortOpen reports whether something accepts TCP connections on port
+// on Percy's own loopback interface.
+func localPortOpen(ctx context.Context, port int) bool {
+	var d net.Dialer
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		dialCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		conn, err := d.DialContext(dialCtx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		cancel()
+		if err == nil {
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+func (t *ProcessTool) stop(req processInput) llm.ToolOut {
+	p, err := t.Manager.get(req.Name)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	wasRunning := p.running()
+	p.stop(processStopGrace)
+	data, _ := p.output.tail(20)
+	info := p.info()
+	text := fmt.Sprintf("Stopped %s", info)
+	if !wasRunning {
+		text = fmt.Sprintf("%s had already exited: %s", req.Name, info)
+	}
+	return processOut("stop", text, data, info)
+}
@@ -0,0 +1,260 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestMain(m *testing.M) {
+	sandbox.Init()
+	os.Exit(m.Run())
+}
+
+func TestProcessOutput(t *testing.T) {
+	out := &processOutput{}
+	for i := range 5 {
+		fmt.Fprintf(out, "line %d\n", i)
+	}
+	data, start := out.tail(2)
+	if string(data) != "line 3\nline 4\n" || start != 21 {
+		t.Errorf("tail(2) = %q at %d", data, start)
+	}
+	if data, _ := out.tail(10); !strings.HasPrefix(string(data), "line 0\n") {
+		t.Errorf("tail(10) = %q", data)
+	}
+	data, start, end := out.since(28)
+	if string(data) != "line 4\n" || start != 28 || end != 35 {
+		t.Errorf("since(28) = %q, %d, %d", data, start, end)
+	}
+
+	// Offsets stay valid once early output is dropped.
+	out.Write(make([]byte, processLogLimit))
+	if _, start, end := out.since(0); start != 35 || end != 35+processLogLimit {
+		t.Errorf("since(0) after overflow = %d, %d", start, end)
+	}
+}
+
+func TestProcessTool(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manager := NewProcessManager(NewMutableWorkingDir(dir), "conv-1")
+	t.Cleanup(manager.StopAll)
+	tool := (&ProcessTool{Manager: manager}).Tool()
+	ctx := context.Background()
+
+	run := func(input string) llm.ToolOut {
+		t.Helper()
+		return tool.Run(ctx, json.RawMessage(input))
+	}
+	mustRun := func(input string) string {
+		t.Helper()
+		out := run(input)
+		if out.Error != nil {
+			t.Fatalf("%s: %v", input, out.Error)
+		}
+		return out.LLMContent[0].Text
+	}
+
+	mustRun(`{"action": "start", "name": "echo", "cwd": "sub", "env": {"GREETING": "hello"},
+		"command": "echo \"$GREETING from $(basename $PWD) in $PERCY_CONVERSATION_ID\"; while read line; do echo \"got $line\"; done"}`)
+	if out := run(`{"action": "start", "name": "echo", "command": "true"}`); out.Error == nil || !strings.Contains(out.Error.Error(), "already running") {
+		t.Errorf("starting a duplicate: %v", out.Error)
+	}
+
+	mustRun(`{"action": "wait", "name": "echo", "log": "hello from sub in conv-1", "timeout": "10s"}`)
+	mustRun(`{"action": "send", "name": "echo", "input": "ping"}`)
+	mustRun(`{"action": "wait", "name": "echo", "log": "^got ping", "timeout": "10s"}`)
+
+	logs := run(`{"action": "logs", "name": "echo", "grep": "^got"}`)
+	display, ok := logs.Display.(ProcessDisplayData)
+	if logs.Error != nil || !ok || display.Output != "got ping\n" || display.Processes[0].Status != "running" {
+		t.Fatalf("logs = %+v", logs)
+	}
+	_, _, end := manager.procs["echo"].output.since(0)
+	mustRun(`{"action": "send", "name": "echo", "input": "pong"}`)
+	mustRun(`{"action": "wait", "name": "echo", "log": "pong", "timeout": "10s"}`)
+	if got := mustRun(fmt.Sprintf(`{"action": "logs", "name": "echo", "offset": %d}`, end)); !strings.HasSuffix(got, "\n\ngot pong\n") {
+		t.Errorf("logs from offset = %q", got)
+	}
+
+	if got := mustRun(`{"action": "stop", "name": "echo"}`); !strings.Contains(got, "exited") {
+		t.Errorf("stop = %q", got)
+	}
+	if out := run(`{"action": "send", "name": "echo", "input": "late"}`); out.Error == nil {
+		t.Error("sending to a stopped process succeeded")
+	}
+
+	// A stopped process can be replaced.
+	mustRun(`{"action": "start", "name": "echo", "command": "echo again; sleep 30"}`)
+	if got := mustRun(`{"action": "list"}`); !strings.Contains(got, "echo (pid") || !strings.Contains(got, "running") {
+		t.Errorf("list = %q", got)
+	}
+}
+
+func TestProcessToolWait(t *testing.T) {
+	manager := NewProcessManager(NewMutableWorkingDir(t.TempDir()), "")
+	t.Cleanup(manager.StopAll)
+	tool := (&ProcessTool{Manager: manager}).Tool()
+	ctx := context.Background()
+	run := func(input string) llm.ToolOut {
+		return tool.Run(ctx, json.RawMessage(input))
+	}
+
+	if out := run(`{"action": "start", "name": "sleeper", "command": "sleep 30"}`); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	if out := run(fmt.Sprintf(`{"action": "wait", "name": "sleeper", "port": %d, "timeout": "5s"}`, port)); out.Error != nil {
+		t.Errorf("waiting for an open port: %v", out.Error)
+	}
+	ln.Close()
+	start := time.Now()
+	out := run(fmt.Sprintf(`{"action": "wait", "name": "sleeper", "port": %d, "timeout": "300ms"}`, port))
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "timed out") || time.Since(start) > 5*time.Second {
+		t.Errorf("waiting for a closed port: %v", out.Error)
+	}
+
+	// Waiting fails as soon as the process exits.
+	if out := run(`{"action": "start", "name": "crash", "command": "sleep 1; echo boom; exit 3"}`); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	out = run(`{"action": "wait", "name": "crash", "log": "ready", "timeout": "20s"}`)
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "exited with code 3") || !strings.Contains(out.Error.Error(), "boom") {
+		t.Errorf("waiting on a crashing process: %v", out.Error)
+	}
+
+	for input, want := range map[string]string{
+		`{"action": "logs", "name": "nope"}`:                                    "no process named",
+		`{"action": "wait", "name": "sleeper"}`:                                 "exactly one",
+		`{"action": "start", "name": "../x", "command": "true"}`:                "invalid process name",
+		`{"action": "start", "name": "x", "command": "true", "cwd": "missing"}`: "does not exist",
+		`{"action": "frobnicate", "name": "x"}`:                                 "unknown action",
+	} {
+		if out := run(input); out.Error == nil || !strings.Contains(out.Error.Error(), want) {
+			t.Errorf("%s: error = %v, want one containing %q", input, out.Error, want)
+		}
+	}
+}
+
+func TestToolSetCleanupStopsProcesses(t *testing.T) {
+	ts := NewToolSet(context.Background(), ToolSetConfig{WorkingDir: t.TempDir()})
+	var tool *llm.Tool
+	for _, tl := range ts.AllTools() {
+		if tl.Name == processName {
+			tool = tl
+		}
+	}
+	if tool == nil || tool.Deferred {
+		t.Fatal("process tool should be a core tool")
+	}
+	out := tool.Run(context.Background(), json.RawMessage(`{"action": "start", "name": "sleeper", "command": "sleep 60"}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	pid := out.Display.(ProcessDisplayData).Processes[0].PID
+
+	ts.Cleanup()
+	if err := syscall.Kill(pid, 0); err == nil {
+		t.Errorf("process %d still running after Cleanup", pid)
+	}
+}
+
+func TestProcessSandboxedNetwork(t *testing.T) {
+	if err := exec.Command("unshare", "--user", "--map-root-user", "--mount", "true").Run(); err != nil {
+		t.Skipf("user namespaces not available: %v", err)
+	}
+	for _, prog := range []string{"nsenter", "python3"} {
+		if _, err := exec.LookPath(prog); err != nil {
+			t.Skipf("%s not installed", prog)
+		}
+	}
+	ts := NewToolSet(context.Background(), ToolSetConfig{
+		WorkingDir: t.TempDir(),
+		Sandbox:    &sandbox.Config{Mode: sandbox.ModeIsolated, Backend: sandbox.BackendNamespaces},
+	})
+	t.Cleanup(ts.Cleanup)
+	var tool *llm.Tool
+	for _, tl := range ts.AllTools() {
+		if tl.Name == processName {
+			tool = tl
+		}
+	}
+	mustRun := func(input string) {
+		t.Helper()
+		if out := tool.Run(context.Background(), json.RawMessage(input)); out.Error != nil {
+			t.Fatalf("%s: %v", input, out.Error)
+		}
+	}
+
+	// A server one sandboxed process starts is reachable from the next.
+	mustRun(`{"action": "start", "name": "server", "command": "python3 -m http.server --bind 127.0.0.1 8766"}`)
+	mustRun(`{"action": "wait", "name": "server", "port": 8766, "timeout": "10s"}`)
+	mustRun(`{"action": "start", "name": "client", "command": "(exec 3<>/dev/tcp/127.0.0.1/8766) && echo reachable; sleep 30"}`)
+	mustRun(`{"action": "wait", "name": "client", "log": "^reachable", "timeout": "10s"}`)
+}
+
+func TestProcessStopWithLeakedPipe(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not available")
+	}
+	defer func(d time.Duration) { processWaitDelay = d }(processWaitDelay)
+	processWaitDelay = 100 * time.Millisecond
+
+	manager := NewProcessManager(NewMutableWorkingDir(t.TempDir()), "conv-1")
+	// The child leaves the process group, so stop cannot kill it, and it
+	// holds the output pipe open after its parent exits.
+	p, err := manager.Start("leaky", "setsid sleep 30 & echo $! > child.pid; echo started; sleep 30", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if data, err := os.ReadFile(filepath.Join(p.dir, "child.pid")); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+				syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+	})
+	logPath := p.logPath
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if out, _, _ := p.output.since(0); strings.Contains(string(out), "started") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("process did not start")
+		}
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		manager.StopAll()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("StopAll did not return")
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Errorf("log file %s not removed: %v", logPath, err)
+	}
+}
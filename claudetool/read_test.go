@@ -165,6 +165,25 @@ func TestReadFileTool(t *testing.T) {
 		}
 	})
 
+	t.Run("pdf document", func(t *testing.T) {
+		path := writeFile("report.pdf", "%PDF-1.4\n1 0 obj << /Length 30 >>\nstream\nBT (Quarterly numbers) Tj ET\nendstream\nendobj\n%%EOF\n")
+		data, _ := json.Marshal(readFileInput{Path: path})
+		result := tool.Run(ctx, data)
+		if result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+		if len(result.LLMContent) != 2 {
+			t.Fatalf("expected description and document, got %d contents", len(result.LLMContent))
+		}
+		doc := result.LLMContent[1]
+		if !doc.IsDocument() || doc.Filename != "report.pdf" {
+			t.Errorf("expected PDF document content, got %+v", doc)
+		}
+		if !strings.Contains(doc.Text, "Quarterly numbers") {
+			t.Errorf("expected extracted text fallback, got %q", doc.Text)
+		}
+	})
+
 	t.Run("large offset near end", func(t *testing.T) {
 		var lines []string
 		for i := 1; i <= 50; i++ {
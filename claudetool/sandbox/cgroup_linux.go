@@ -0,0 +1,98 @@
+package sandbox
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroup is a cgroup v2 created for one sandboxed command.
+type cgroup struct {
+	dir string
+	fd  int
+}
+
+// newCgroup creates a cgroup with c's limits under c.CgroupParent.
+func (c *Config) newCgroup() (*cgroup, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("sandbox resource limits need cgroup v2 mounted at %s", cgroupRoot)
+	}
+	parent := c.CgroupParent
+	if parent == "" {
+		var err error
+		if parent, err = ownCgroup(); err != nil {
+			return nil, err
+		}
+	}
+	dir, err := os.MkdirTemp(parent, "percy-sandbox-")
+	if err != nil {
+		return nil, fmt.Errorf("creating sandbox cgroup: %w", err)
+	}
+	cg := &cgroup{dir: dir, fd: -1}
+
+	limits := map[string]string{}
+	if c.MemoryMax != "" {
+		n, _ := parseBytes(c.MemoryMax)
+		limits["memory.max"] = strconv.FormatInt(n, 10)
+		limits["memory.swap.max"] = "0"
+	}
+	if c.CPUs > 0 {
+		const period = 100000
+		limits["cpu.max"] = fmt.Sprintf("%d %d", int(c.CPUs*period), period)
+	}
+	if c.Pids > 0 {
+		limits["pids.max"] = strconv.Itoa(c.Pids)
+	}
+	for file, value := range limits {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0o644); err != nil {
+			if file == "memory.swap.max" && os.IsNotExist(err) {
+				continue // no swap accounting
+			}
+			cg.release()
+			return nil, fmt.Errorf("setting %s (is the controller enabled in %s/cgroup.subtree_control?): %w", file, parent, err)
+		}
+	}
+	if cg.fd, err = syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0); err != nil {
+		cg.release()
+		return nil, err
+	}
+	return cg, nil
+}
+
+// release kills anything left in the cgroup and removes it.
+func (cg *cgroup) release() {
+	if cg.fd >= 0 {
+		syscall.Close(cg.fd)
+	}
+	os.WriteFile(filepath.Join(cg.dir, "cgroup.kill"), []byte("1"), 0o644)
+	// Removal fails until the killed processes are gone.
+	for range 50 {
+		if err := os.Remove(cg.dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// ownCgroup returns the directory of the current process's cgroup.
+func ownCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return filepath.Join(cgroupRoot, path), nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
@@ -0,0 +1,251 @@
+// Package sandbox runs agent commands in Linux namespaces: the workspace
+// is writable except for its git directory, the rest of the filesystem is
+// read-only, processes cannot
+// see or signal anything outside the sandbox, network access is optional,
+// and cgroup v2 limits can cap memory, CPU and process count.
+//
+// Commands run under bubblewrap (bwrap) when it is installed. Otherwise the
+// current executable is re-executed to set up the namespaces itself, as it
+// is to create shared networks, so programs that sandbox commands must call
+// Init at the start of main.
+package sandbox
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether and how commands are sandboxed.
+type Mode string
+
+const (
+	// ModeOff runs commands directly.
+	ModeOff Mode = "off"
+	// ModeIsolated sandboxes commands without network access.
+	ModeIsolated Mode = "isolated"
+	// ModeNetwork sandboxes commands but allows network access.
+	ModeNetwork Mode = "network"
+)
+
+// Modes lists the valid modes.
+var Modes = []Mode{ModeOff, ModeIsolated, ModeNetwork}
+
+// ParseMode parses a mode name. The empty string is ModeOff.
+func ParseMode(s string) (Mode, error) {
+	if s == "" {
+		return ModeOff, nil
+	}
+	for _, m := range Modes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown sandbox mode %q (use off, isolated or network)", s)
+}
+
+// Supported reports whether commands can be sandboxed on this platform.
+func Supported() bool {
+	return runtime.GOOS == "linux"
+}
+
+// Backends.
+const (
+	BackendAuto       = ""           // bwrap if installed, else namespaces
+	BackendBwrap      = "bwrap"      // bubblewrap
+	BackendNamespaces = "namespaces" // re-execute the current binary
+)
+
+// Config configures the sandbox. It is the "sandbox" object in percy.json.
+type Config struct {
+	// Mode is the mode of conversations that don't choose one.
+	Mode Mode `json:"mode,omitempty"`
+	// Backend is "bwrap", "namespaces", or empty to use bwrap when installed.
+	Backend string `json:"backend,omitempty"`
+	// WritablePaths are writable in addition to the workspace, for example
+	// build and package caches. Paths that don't exist are skipped.
+	WritablePaths []string `json:"writable_paths,omitempty"`
+
+	// MemoryMax limits memory use, in bytes or with a K, M or G suffix.
+	MemoryMax string `json:"memory_max,omitempty"`
+	// CPUs limits CPU time, in CPUs (1.5 is one and a half CPUs).
+	CPUs float64 `json:"cpus,omitempty"`
+	// Pids limits the number of processes and threads.
+	Pids int `json:"pids,omitempty"`
+	// CgroupParent is the cgroup v2 directory commands' cgroups are created
+	// in. It must have the memory, cpu and pids controllers enabled for its
+	// children. Defaults to the cgroup Percy runs in.
+	CgroupParent string `json:"cgroup_parent,omitempty"`
+
+	// Network, if set, is the network that commands share when network
+	// access is off, so that a server one command starts is reachable from
+	// the others. Otherwise each command gets a network of its own.
+	Network *Network `json:"-"`
+}
+
+// Network is a private network, with only a loopback interface, that
+// sandboxed commands can share. It is created when a command first uses
+// it. The zero value is ready to use; call Close when done with it.
+type Network struct {
+	mu     sync.Mutex
+	holder *exec.Cmd // keeps the network's namespaces alive
+	stdin  io.Closer // closing it stops the holder
+	closed bool
+}
+
+// Enabled reports whether c sandboxes commands.
+func (c *Config) Enabled() bool {
+	return c != nil && c.Mode != "" && c.Mode != ModeOff
+}
+
+// WithMode returns a copy of c with the given mode.
+func (c *Config) WithMode(mode Mode) *Config {
+	var cfg Config
+	if c != nil {
+		cfg = *c
+	}
+	cfg.Mode = mode
+	return &cfg
+}
+
+// Validate checks c.
+func (c *Config) Validate() error {
+	if _, err := ParseMode(string(c.Mode)); err != nil {
+		return err
+	}
+	switch c.Backend {
+	case BackendAuto, BackendBwrap, BackendNamespaces:
+	default:
+		return fmt.Errorf("unknown sandbox backend %q (use bwrap or namespaces)", c.Backend)
+	}
+	if c.MemoryMax != "" {
+		if _, err := parseBytes(c.MemoryMax); err != nil {
+			return err
+		}
+	}
+	if c.CPUs < 0 || c.Pids < 0 {
+		return fmt.Errorf("sandbox limits must not be negative")
+	}
+	return nil
+}
+
+// Description tells the agent what the sandbox allows.
+func (c *Config) Description() string {
+	network := "Network access is off; each command has a localhost of its own."
+	if c.Network != nil {
+		network = "Network access is off, but commands share a private localhost: a server started by one command or background process is reachable from later commands, though not from the browser tools."
+	}
+	if c.Mode == ModeNetwork {
+		network = "Network access is on."
+	}
+	return "Commands run in a sandbox: only the workspace (the git repository root, or the working directory outside a repository) and $TMPDIR are writable, except for the repository's .git directory; the rest of the filesystem is read-only. " + network
+}
+
+// MemoryLimit returns MemoryMax in bytes, or 0 if memory isn't limited.
+func (c *Config) MemoryLimit() int64 {
+	if c == nil || c.MemoryMax == "" {
+		return 0
+	}
+	n, _ := parseBytes(c.MemoryMax)
+	return n
+}
+
+func (c *Config) hasLimits() bool {
+	return c.MemoryMax != "" || c.CPUs > 0 || c.Pids > 0
+}
+
+// parseBytes parses a size like "512M" into bytes.
+func parseBytes(s string) (int64, error) {
+	mult := int64(1)
+	num := strings.ToUpper(strings.TrimSpace(s))
+	for i, suffix := range []string{"K", "M", "G", "T"} {
+		if strings.HasSuffix(num, suffix) {
+			mult = 1 << (10 * (i + 1))
+			num = strings.TrimSuffix(num, suffix)
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid memory_max %q", s)
+	}
+	return n * mult, nil
+}
+
+// Workspace returns the directory a command run in dir may write to: the
+// root of the git repository containing dir, or dir itself.
+func Workspace(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// Wrap changes cmd, which must not have been started, to run in the
+// sandbox with workspace writable. Commands get a private writable TMPDIR.
+// The returned function releases the sandbox's resources; call it once cmd
+// has exited.
+func (c *Config) Wrap(cmd *exec.Cmd, workspace string) (release func(), err error) {
+	if !c.Enabled() {
+		return func() {}, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c.wrap(cmd, workspace)
+}
+
+// writablePaths returns the existing writable paths, without duplicates.
+func (c *Config) writablePaths(workspace, tmp string) []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, p := range append([]string{workspace, tmp}, c.WritablePaths...) {
+		if strings.HasPrefix(p, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				continue
+			}
+			p = filepath.Join(home, p[2:])
+		}
+		p, err := filepath.EvalSymlinks(p)
+		if err != nil || seen[p] || !filepath.IsAbs(p) {
+			continue
+		}
+		seen[p] = true
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// readOnlyPaths returns the existing paths in the workspace that stay
+// read-only: the git directory, whose configuration and hooks Percy's own
+// git commands, which run outside the sandbox, would obey.
+func readOnlyPaths(workspace string) []string {
+	var paths []string
+	for _, name := range []string{".git"} {
+		if p, err := filepath.EvalSymlinks(filepath.Join(workspace, name)); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// setEnv sets an environment variable in cmd's environment.
+func setEnv(cmd *exec.Cmd, key, value string) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, key+"="+value)
+}
@@ -0,0 +1,531 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// initEnv carries the initSpec to the re-executed binary.
+const initEnv = "PERCY_SANDBOX_INIT"
+
+// initSpec tells Init how to set up the sandbox and what to run in it.
+type initSpec struct {
+	Writable []string `json:"writable"`
+	ReadOnly []string `json:"read_only"`
+	Network  bool     `json:"network"`
+	Dir      string   `json:"dir"`
+	Path     string   `json:"path"`
+	Args     []string `json:"args"`
+
+	// Hold makes Init create a shared network and keep it alive (see
+	// Network.start).
+	Hold bool `json:"hold,omitempty"`
+	// Spawn makes Init start the sandbox in new namespaces itself, from
+	// within a shared network's namespaces that nsenter joined.
+	Spawn bool `json:"spawn,omitempty"`
+}
+
+func (c *Config) wrap(cmd *exec.Cmd, workspace string) (func(), error) {
+	backend := c.Backend
+	if backend == BackendAuto {
+		backend = BackendNamespaces
+		if _, err := exec.LookPath("bwrap"); err == nil {
+			backend = BackendBwrap
+		}
+	}
+
+	tmp, err := os.MkdirTemp("", "percy-sandbox-")
+	if err != nil {
+		return nil, err
+	}
+	releases := []func(){func() { os.RemoveAll(tmp) }}
+	release := func() {
+		for _, fn := range slices.Backward(releases) {
+			fn()
+		}
+	}
+	setEnv(cmd, "TMPDIR", tmp)
+	writable := c.writablePaths(workspace, tmp)
+	readOnly := readOnlyPaths(workspace)
+	network := c.Mode == ModeNetwork
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	// Commands sharing a network run in its namespaces, which they enter
+	// through nsenter before creating their own.
+	var join []string
+	if !network && c.Network != nil {
+		join, err = c.Network.enter()
+	}
+	if err == nil {
+		switch backend {
+		case BackendBwrap:
+			err = wrapBwrap(cmd, writable, readOnly, network || join != nil)
+		case BackendNamespaces:
+			err = wrapNamespaces(cmd, writable, readOnly, network || join != nil, join != nil)
+		default:
+			err = fmt.Errorf("unknown sandbox backend %q", backend)
+		}
+	}
+	if err == nil && join != nil {
+		cmd.Args = append(append(join, cmd.Path), cmd.Args[1:]...)
+		cmd.Path = join[0]
+	}
+	if err == nil && c.hasLimits() {
+		var cg *cgroup
+		if cg, err = c.newCgroup(); err == nil {
+			releases = append(releases, cg.release)
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = cg.fd
+		}
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// wrapBwrap runs cmd under bubblewrap.
+func wrapBwrap(cmd *exec.Cmd, writable, readOnly []string, network bool) error {
+	bwrap, err := exec.LookPath("bwrap")
+	if err != nil {
+		return fmt.Errorf("bwrap not found: %w", err)
+	}
+	args := []string{
+		bwrap,
+		"--die-with-parent",
+		"--unshare-user", "--unshare-pid", "--unshare-ipc", "--unshare-uts", "--unshare-cgroup-try",
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+	}
+	if !network {
+		args = append(args, "--unshare-net")
+	}
+	for _, p := range writable {
+		args = append(args, "--bind", p, p)
+	}
+	for _, p := range readOnly {
+		args = append(args, "--ro-bind", p, p)
+	}
+	if cmd.Dir != "" {
+		args = append(args, "--chdir", cmd.Dir)
+	}
+	args = append(args, "--", cmd.Path)
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = bwrap
+	return nil
+}
+
+// wrapNamespaces runs cmd under a re-executed copy of the current binary,
+// whose Init sets up the sandbox in new namespaces and then runs cmd. With
+// spawn, the namespaces are created by Init rather than when it starts,
+// so that nsenter can first join a shared network's.
+func wrapNamespaces(cmd *exec.Cmd, writable, readOnly []string, network, spawn bool) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	spec, err := json.Marshal(initSpec{Writable: writable, ReadOnly: readOnly, Network: network, Dir: cmd.Dir, Path: cmd.Path, Args: cmd.Args, Spawn: spawn})
+	if err != nil {
+		return err
+	}
+	setEnv(cmd, initEnv, string(spec))
+	cmd.Path = exe
+	cmd.Args = []string{"percy-sandbox"}
+	if !spawn {
+		newNamespaces(cmd.SysProcAttr, network)
+	}
+	return nil
+}
+
+// newNamespaces makes a process start in the sandbox's new namespaces.
+func newNamespaces(attr *syscall.SysProcAttr, network bool) {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	// Keep the same IDs inside the sandbox, and give Init the capabilities
+	// it needs to set it up; it drops them before running the command.
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	attr.AmbientCaps = []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_NET_ADMIN, unix.CAP_SETPCAP}
+}
+
+// enter returns the nsenter command that joins the network's namespaces,
+// creating them first if needed.
+func (n *Network) enter() ([]string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return nil, errors.New("sandbox network is closed")
+	}
+	nsenter, err := exec.LookPath("nsenter")
+	if err != nil {
+		return nil, fmt.Errorf("nsenter not found: %w", err)
+	}
+	if n.holder == nil {
+		if err := n.start(); err != nil {
+			return nil, fmt.Errorf("creating sandbox network: %w", err)
+		}
+	}
+	// The holder is never reaped before Close, so its pid can't be reused.
+	ns := fmt.Sprintf("/proc/%d/ns/", n.holder.Process.Pid)
+	return []string{nsenter, "--preserve-credentials", "--user=" + ns + "user", "--net=" + ns + "net", "--"}, nil
+}
+
+// start starts the holder: a re-executed copy of the current binary whose
+// Init creates a user and network namespace, brings up loopback, and waits
+// for its stdin to close.
+func (n *Network) start() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	spec, err := json.Marshal(initSpec{Hold: true})
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe)
+	cmd.Args = []string{"percy-sandbox-network"}
+	cmd.Env = append(os.Environ(), initEnv+"="+string(spec))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		AmbientCaps:                []uintptr{unix.CAP_NET_ADMIN},
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "ready\n" {
+		stdin.Close()
+		cmd.Wait()
+		return fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+	}
+	n.holder, n.stdin = cmd, stdin
+	return nil
+}
+
+// Close releases the network. Commands still running in it keep it.
+func (n *Network) Close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	if n.holder != nil {
+		n.stdin.Close()
+		n.holder.Wait()
+		n.holder = nil
+	}
+}
+
+// PortOpen reports whether something accepts TCP connections on port on
+// the network's loopback interface.
+func (n *Network) PortOpen(ctx context.Context, port int) bool {
+	join, err := n.enter()
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	p := strconv.Itoa(port)
+	probe := "(exec 3<>/dev/tcp/127.0.0.1/" + p + ") 2>/dev/null || (exec 3<>/dev/tcp/::1/" + p + ") 2>/dev/null"
+	return exec.CommandContext(ctx, join[0], append(join[1:], "bash", "-c", probe)...).Run() == nil
+}
+
+// Init sets up the sandbox and runs the sandboxed command when the process
+// is a sandbox started by the namespaces backend, and exits with the
+// command's status. Otherwise it returns immediately.
+func Init() {
+	spec := os.Getenv(initEnv)
+	if spec == "" {
+		return
+	}
+	os.Unsetenv(initEnv)
+	code, err := runInit(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	os.Exit(code)
+}
+
+// runInit runs as pid 1 of the sandbox's pid namespace. When it exits, the
+// kernel kills everything left in the sandbox.
+func runInit(data string) (int, error) {
+	// Capabilities and no_new_privs are per thread, and the command is
+	// forked from this one.
+	runtime.LockOSThread()
+
+	var spec initSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		return 0, err
+	}
+	switch {
+	case spec.Hold:
+		return holdNetwork()
+	case spec.Spawn:
+		return spawnInit(spec)
+	}
+	if err := setupMounts(spec.Writable, spec.ReadOnly); err != nil {
+		return 0, err
+	}
+	if !spec.Network {
+		if err := loopbackUp(); err != nil {
+			return 0, fmt.Errorf("bringing up loopback: %w", err)
+		}
+	}
+	if err := dropPrivileges(); err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command(spec.Path)
+	cmd.Args = spec.Args
+	cmd.Dir = spec.Dir
+	return run(cmd)
+}
+
+// run runs cmd with Init's standard files, forwarding signals to it, and
+// returns its exit code.
+func run(cmd *exec.Cmd) (int, error) {
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	go func() {
+		for sig := range signals {
+			syscall.Kill(pid, sig.(syscall.Signal))
+		}
+	}()
+
+	// Reap orphans too, as init must, until the command exits.
+	for {
+		var status syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &status, 0, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if wpid != pid {
+			continue
+		}
+		if status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return status.ExitStatus(), nil
+	}
+}
+
+// spawnInit starts Init again in new namespaces, from within a shared
+// network's namespaces, which the sandbox then keeps.
+func spawnInit(spec initSpec) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	spec.Spawn, spec.Network = false, true
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return 0, err
+	}
+	cmd := exec.Command(exe)
+	cmd.Args = []string{"percy-sandbox"}
+	cmd.Env = append(os.Environ(), initEnv+"="+string(data))
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	newNamespaces(cmd.SysProcAttr, true)
+	return run(cmd)
+}
+
+// holdNetwork runs in the new namespaces of a shared network. It brings up
+// loopback, reports that the network is ready, and keeps the namespaces
+// alive until its stdin is closed.
+func holdNetwork() (int, error) {
+	if err := loopbackUp(); err != nil {
+		return 0, fmt.Errorf("bringing up loopback: %w", err)
+	}
+	if _, err := os.Stdout.WriteString("ready\n"); err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, os.Stdin)
+	return 0, nil
+}
+
+// setupMounts makes every mount read-only except the writable paths, then
+// the read-only paths within them, and mounts a /proc for the new pid
+// namespace.
+func setupMounts(writable, readOnly []string) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+	mounts, err := mountPoints()
+	if err != nil {
+		return err
+	}
+	for _, mp := range mounts {
+		// Some special mounts can't be remounted; the root must be.
+		if err := remount(mp, true); err != nil && mp == "/" {
+			return fmt.Errorf("making / read-only: %w", err)
+		}
+	}
+	for _, p := range writable {
+		if err := unix.Mount(p, p, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind-mounting %s: %w", p, err)
+		}
+		if err := remount(p, false); err != nil {
+			return fmt.Errorf("making %s writable: %w", p, err)
+		}
+	}
+	for _, p := range readOnly {
+		if err := unix.Mount(p, p, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind-mounting %s: %w", p, err)
+		}
+		if err := remount(p, true); err != nil {
+			return fmt.Errorf("making %s read-only: %w", p, err)
+		}
+	}
+	// Container runtimes may forbid mounting proc; then the host's stays.
+	unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+	return nil
+}
+
+// remount changes a bind mount to read-only or read-write, keeping the
+// flags the kernel doesn't let a user namespace change.
+func remount(path string, readOnly bool) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND)
+	for _, f := range []struct{ st, ms uintptr }{
+		{unix.ST_NOSUID, unix.MS_NOSUID},
+		{unix.ST_NODEV, unix.MS_NODEV},
+		{unix.ST_NOEXEC, unix.MS_NOEXEC},
+		{unix.ST_NOATIME, unix.MS_NOATIME},
+		{unix.ST_NODIRATIME, unix.MS_NODIRATIME},
+		{unix.ST_RELATIME, unix.MS_RELATIME},
+	} {
+		if uintptr(st.Flags)&f.st != 0 {
+			flags |= f.ms
+		}
+	}
+	if readOnly {
+		flags |= unix.MS_RDONLY
+	}
+	return unix.Mount("", path, "", flags, "")
+}
+
+// mountPoints returns the mount points in /proc/self/mountinfo.
+func mountPoints() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 4 {
+			mounts = append(mounts, unescapeMountPoint(fields[4]))
+		}
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPoint decodes the octal escapes (\040 for space) in a
+// mountinfo path.
+func unescapeMountPoint(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// loopbackUp brings up lo in a new network namespace, so that servers and
+// tests can still talk to each other on localhost.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// Securebits (linux/securebits.h).
+const (
+	secbitNoRoot                  = 1 << 0
+	secbitNoRootLocked            = 1 << 1
+	secbitNoSetuidFixup           = 1 << 2
+	secbitNoSetuidFixupLocked     = 1 << 3
+	secbitKeepCapsLocked          = 1 << 5
+	secbitNoCapAmbientRaise       = 1 << 6
+	secbitNoCapAmbientRaiseLocked = 1 << 7
+)
+
+// dropPrivileges makes sure the command gets no capabilities, even though
+// it may run as root in the sandbox's user namespace; with them it could
+// undo the read-only mounts.
+func dropPrivileges() error {
+	bits := secbitNoRoot | secbitNoRootLocked | secbitNoSetuidFixup | secbitNoSetuidFixupLocked |
+		secbitKeepCapsLocked | secbitNoCapAmbientRaise | secbitNoCapAmbientRaiseLocked
+	if err := unix.Prctl(unix.PR_SET_SECUREBITS, uintptr(bits), 0, 0, 0); err != nil {
+		return fmt.Errorf("setting securebits: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("clearing ambient capabilities: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("setting no_new_privs: %w", err)
+	}
+	return nil
+}
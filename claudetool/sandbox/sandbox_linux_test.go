@@ -0,0 +1,9 @@
+package sandbox
+
+import "testing"
+
+func TestUnescapeMountPoint(t *testing.T) {
+	if got := unescapeMountPoint(`/mnt/my\040disk`); got != "/mnt/my disk" {
+		t.Errorf("unescapeMountPoint = %q", got)
+	}
+}
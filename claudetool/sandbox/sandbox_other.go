@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+func (c *Config) wrap(cmd *exec.Cmd, workspace string) (func(), error) {
+	return nil, errors.New("sandboxing commands requires Linux")
+}
+
+// Init does nothing on this platform.
+func Init() {}
+
+// Close does nothing on this platform.
+func (n *Network) Close() {}
+
+// PortOpen reports false on this platform.
+func (n *Network) PortOpen(ctx context.Context, port int) bool { return false }
@@ -0,0 +1,258 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": ModeOff, "off": ModeOff, "isolated": ModeIsolated, "network": ModeNetwork} {
+		if got, err := ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMode("docker"); err == nil {
+		t.Error("ParseMode accepted an unknown mode")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []Config{
+		{Mode: "bogus"},
+		{Backend: "docker"},
+		{MemoryMax: "lots"},
+		{Pids: -1},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v is valid", c)
+		}
+	}
+	if n, err := parseBytes("512M"); err != nil || n != 512<<20 {
+		t.Errorf("parseBytes(512M) = %d, %v", n, err)
+	}
+	var none *Config
+	if n := none.MemoryLimit(); n != 0 {
+		t.Errorf("nil MemoryLimit() = %d", n)
+	}
+	if n := (&Config{MemoryMax: "2G"}).MemoryLimit(); n != 2<<30 {
+		t.Errorf("MemoryLimit() = %d", n)
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := Workspace(sub); got != sub {
+		t.Errorf("Workspace outside a repository = %s", got)
+	}
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := Workspace(sub); got != root {
+		t.Errorf("Workspace = %s, want the repository root %s", got, root)
+	}
+}
+
+// runSandboxed runs a bash script in the sandbox and returns its output.
+func runSandboxed(t *testing.T, cfg *Config, workspace, script string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("bash", "-c", script)
+	cmd.Dir = workspace
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	release, err := cfg.Wrap(cmd, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	err = cmd.Run()
+	return out.String(), err
+}
+
+func skipUnlessSandboxable(t *testing.T, backend string) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxing requires Linux")
+	}
+	if backend == BackendBwrap {
+		if _, err := exec.LookPath("bwrap"); err != nil {
+			t.Skip("bwrap not installed")
+		}
+		return
+	}
+	if err := exec.Command("unshare", "--user", "--map-root-user", "--mount", "true").Run(); err != nil {
+		t.Skipf("user namespaces not available: %v", err)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	for _, backend := range []string{BackendNamespaces, BackendBwrap} {
+		t.Run(backend, func(t *testing.T) {
+			skipUnlessSandboxable(t, backend)
+			workspace := t.TempDir()
+			outside := t.TempDir()
+			cfg := &Config{Mode: ModeIsolated, Backend: backend}
+
+			out, err := runSandboxed(t, cfg, workspace, `
+				set -e
+				echo ok > inside.txt
+				echo tmp > "$TMPDIR/scratch"
+				if echo no > `+outside+`/outside.txt; then echo "wrote outside"; fi
+			`)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, out)
+			}
+			if data, err := os.ReadFile(filepath.Join(workspace, "inside.txt")); err != nil || string(data) != "ok\n" {
+				t.Errorf("writing the workspace: %q, %v\n%s", data, err, out)
+			}
+			if _, err := os.Stat(filepath.Join(outside, "outside.txt")); err == nil || strings.Contains(out, "wrote outside") {
+				t.Errorf("wrote outside the workspace\n%s", out)
+			}
+
+			// The git directory stays read-only: Percy's own git commands
+			// would run its configuration outside the sandbox.
+			if out, err := exec.Command("git", "init", "-q", workspace).CombinedOutput(); err != nil {
+				t.Fatalf("git init: %v\n%s", err, out)
+			}
+			out, err = runSandboxed(t, cfg, workspace, "git config core.fsmonitor 'touch pwned'")
+			if err == nil {
+				t.Errorf("git config in the sandbox succeeded\n%s", out)
+			}
+			if data, _ := os.ReadFile(filepath.Join(workspace, ".git", "config")); strings.Contains(string(data), "fsmonitor") {
+				t.Errorf("the sandbox changed .git/config:\n%s", data)
+			}
+			if _, err := runSandboxed(t, cfg, workspace, "echo ok > still-writable.txt"); err != nil {
+				t.Errorf("the workspace isn't writable next to .git: %v", err)
+			}
+
+			// Commands can't signal processes outside the sandbox.
+			out, _ = runSandboxed(t, cfg, workspace, "kill -0 "+strconv.Itoa(os.Getpid())+" && echo visible")
+			if strings.Contains(out, "visible") {
+				t.Error("the host process is visible in the sandbox")
+			}
+		})
+	}
+}
+
+func TestSandboxNetwork(t *testing.T) {
+	skipUnlessSandboxable(t, BackendNamespaces)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	probe := "(exec 3<>/dev/tcp/127.0.0.1/" + port + ") 2>/dev/null && echo reachable || echo unreachable"
+
+	workspace := t.TempDir()
+	out, err := runSandboxed(t, &Config{Mode: ModeIsolated, Backend: BackendNamespaces}, workspace, probe)
+	if err != nil || !strings.Contains(out, "unreachable") {
+		t.Errorf("isolated sandbox reached the host: %v\n%s", err, out)
+	}
+	out, err = runSandboxed(t, &Config{Mode: ModeNetwork, Backend: BackendNamespaces}, workspace, probe)
+	if err != nil || !strings.Contains(out, "reachable") || strings.Contains(out, "unreachable") {
+		t.Errorf("network sandbox could not reach the host: %v\n%s", err, out)
+	}
+}
+
+func TestSandboxSharedNetwork(t *testing.T) {
+	skipUnlessSandboxable(t, BackendNamespaces)
+	if _, err := exec.LookPath("nsenter"); err != nil {
+		t.Skip("nsenter not installed")
+	}
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+	network := new(Network)
+	defer network.Close()
+	cfg := &Config{Mode: ModeIsolated, Backend: BackendNamespaces, Network: network}
+	workspace := t.TempDir()
+
+	// A server started by one command is reachable from another.
+	server := exec.Command("python3", "-m", "http.server", "--bind", "127.0.0.1", "8765")
+	server.Dir = workspace
+	release, err := cfg.Wrap(server, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Wait()
+	defer server.Process.Kill()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !network.PortOpen(context.Background(), 8765) {
+		if time.Now().After(deadline) {
+			t.Fatal("the server's port never opened in the network")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	out, err := runSandboxed(t, cfg, workspace, "(exec 3<>/dev/tcp/127.0.0.1/8765) 2>/dev/null && echo reachable || echo unreachable")
+	if err != nil || strings.TrimSpace(out) != "reachable" {
+		t.Errorf("a second command could not reach the server: %v\n%s", err, out)
+	}
+
+	// Commands with networks of their own can't.
+	out, err = runSandboxed(t, &Config{Mode: ModeIsolated, Backend: BackendNamespaces}, workspace, "(exec 3<>/dev/tcp/127.0.0.1/8765) 2>/dev/null && echo reachable || echo unreachable")
+	if err != nil || strings.TrimSpace(out) != "unreachable" {
+		t.Errorf("a command outside the network reached the server: %v\n%s", err, out)
+	}
+}
+
+func TestSandboxOff(t *testing.T) {
+	cmd := exec.Command("true")
+	release, err := (&Config{Mode: ModeOff}).Wrap(cmd, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if cmd.Path == "" || len(cmd.Args) != 1 {
+		t.Errorf("Wrap changed the command: %v", cmd.Args)
+	}
+}
+
+func TestSandboxLimits(t *testing.T) {
+	skipUnlessSandboxable(t, BackendNamespaces)
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+		t.Skip("cgroup v2 not mounted")
+	}
+	cmd := exec.Command("bash", "-c", `cat /sys/fs/cgroup$(cut -d: -f3 /proc/self/cgroup)/pids.max`)
+	cmd.Dir = t.TempDir()
+	release, err := (&Config{Mode: ModeIsolated, Backend: BackendNamespaces, Pids: 64}).Wrap(cmd, cmd.Dir)
+	if err != nil {
+		t.Skipf("cgroup limits not available here: %v", err)
+	}
+	defer release()
+	out, err := cmd.CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "64" {
+		t.Errorf("pids.max in the sandbox = %q, %v", out, err)
+	}
+}
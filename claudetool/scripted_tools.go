@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/llm"
 )
 
@@ -26,13 +27,23 @@ const (
   "properties": {
     "script": {
       "type": "string",
-      "description": "Python script to execute. Runs inside async def _main(). Can call tool functions and use print()."
+      "description": %q
     }
   }
 }
 `
 )
 
+// Script runtimes for scripted_tools.
+const (
+	ScriptRuntimeAuto       = ""           // python when uv is installed, else javascript
+	ScriptRuntimePython     = "python"     // Python, run by uv in a subprocess
+	ScriptRuntimeJavaScript = "javascript" // JavaScript, run by an interpreter embedded in Percy
+)
+
+// ScriptRuntimes lists the valid script runtimes.
+var ScriptRuntimes = []string{ScriptRuntimeAuto, ScriptRuntimePython, ScriptRuntimeJavaScript}
+
 // excludedFromScripting lists tools that should not be exposed to scripted tools.
 var excludedFromScripting = map[string]bool{
 	scriptedToolsName: true,
@@ -94,11 +105,21 @@ def _call_tool(name, input_dict):
 	return b.String()
 }
 
-// ScriptedToolsTool runs Python scripts that can call other tools via IPC.
+// ScriptedToolsTool runs scripts that can call other tools: Python scripts
+// in a subprocess that calls tools via IPC, or JavaScript scripts in process.
 type ScriptedToolsTool struct {
 	Tools      []*llm.Tool
 	WorkingDir *MutableWorkingDir
 	Timeout    time.Duration
+	// Runtime is one of ScriptRuntimes. The empty string uses Python when
+	// uv is installed, and JavaScript otherwise.
+	Runtime string
+	// Sandbox, if enabled, isolates the Python process. Tools scripts call
+	// run as usual.
+	Sandbox *sandbox.Config
+	// MemoryLimit is the number of bytes a JavaScript script may keep live;
+	// 0 means 512 MiB.
+	MemoryLimit int64
 }
 
 type scriptedToolsInput struct {
@@ -122,6 +143,17 @@ func (s *ScriptedToolsTool) timeout() time.Duration {
 	return s.Timeout
 }
 
+// runtime returns the runtime scripts run in.
+func (s *ScriptedToolsTool) runtime() string {
+	if s.Runtime != ScriptRuntimeAuto {
+		return s.Runtime
+	}
+	if _, err := exec.LookPath("uv"); err != nil {
+		return ScriptRuntimeJavaScript
+	}
+	return ScriptRuntimePython
+}
+
 // description generates a dynamic description listing available tool names.
 func (s *ScriptedToolsTool) description() string {
 	scriptable := filterScriptableTools(s.Tools)
@@ -129,6 +161,21 @@ func (s *ScriptedToolsTool) description() string {
 	for i, t := range scriptable {
 		names[i] = t.Name
 	}
+	if s.runtime() == ScriptRuntimeJavaScript {
+		return fmt.Sprintf(`Execute a JavaScript script that can call other tools programmatically.
+Tool results stay in the script — only your print() output enters the conversation.
+Use this when you need to call multiple tools and process/filter/aggregate results before reporting.
+
+Available tools: %s
+
+Each tool is an async function. Call it with an object of arguments matching the tool's input schema:
+  const content = await read_file({path: "foo.go"})
+  const result = await bash({command: "go test ./..."})
+The script is the body of an async function, so await works at the top level. There is no require(),
+filesystem or network access; use the tools for those.
+
+Use print() or console.log() for output — only printed text is returned.`, strings.Join(names, ", "))
+	}
 	return fmt.Sprintf(`Execute a Python script that can call other tools programmatically.
 Tool results stay in the script — only your print() output enters the conversation.
 Use this when you need to call multiple tools and process/filter/aggregate results before reporting.
@@ -147,12 +194,20 @@ func (s *ScriptedToolsTool) Tool() *llm.Tool {
 	return &llm.Tool{
 		Name:        scriptedToolsName,
 		Description: s.description(),
-		InputSchema: llm.MustSchema(scriptedToolsInputSchema),
+		InputSchema: llm.MustSchema(fmt.Sprintf(scriptedToolsInputSchema, s.scriptDescription())),
 		Run:         s.Run,
 	}
 }
 
-// Run executes a Python script with IPC-based tool calling.
+// scriptDescription describes the script input.
+func (s *ScriptedToolsTool) scriptDescription() string {
+	if s.runtime() == ScriptRuntimeJavaScript {
+		return "JavaScript script to execute. Runs inside an async function. Can call tool functions and use print()."
+	}
+	return "Python script to execute. Runs inside async def _main(). Can call tool functions and use print()."
+}
+
+// Run executes a script in the tool's runtime.
 func (s *ScriptedToolsTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 	var req scriptedToolsInput
 	if err := json.Unmarshal(m, &req); err != nil {
@@ -161,7 +216,14 @@ func (s *ScriptedToolsTool) Run(ctx context.Context, m json.RawMessage) llm.Tool
 	if strings.TrimSpace(req.Script) == "" {
 		return llm.ErrorfToolOut("script must not be empty")
 	}
+	if s.runtime() == ScriptRuntimeJavaScript {
+		return s.runJavaScript(ctx, req.Script)
+	}
+	return s.runPython(ctx, req.Script)
+}
 
+// runPython executes a Python script with IPC-based tool calling.
+func (s *ScriptedToolsTool) runPython(ctx context.Context, script string) llm.ToolOut {
 	// Check that uv is available
 	if _, err := exec.LookPath("uv"); err != nil {
 		return llm.ErrorfToolOut("uv not found in PATH: install it from https://docs.astral.sh/uv/")
@@ -169,7 +231,7 @@ func (s *ScriptedToolsTool) Run(ctx context.Context, m json.RawMessage) llm.Tool
 
 	// Filter and generate harness
 	scriptableTools := filterScriptableTools(s.Tools)
-	harness := generateHarness(scriptableTools, req.Script)
+	harness := generateHarness(scriptableTools, script)
 
 	// Write harness to temp file
 	tmpFile, err := os.CreateTemp("", "percy-script-*.py")
@@ -202,6 +264,12 @@ func (s *ScriptedToolsTool) Run(ctx context.Context, m json.RawMessage) llm.Tool
 	}
 	cmd.WaitDelay = 5 * time.Second
 
+	release, err := s.Sandbox.Wrap(cmd, sandbox.Workspace(cmd.Dir))
+	if err != nil {
+		return llm.ErrorfToolOut("failed to sandbox script: %w", err)
+	}
+	defer release()
+
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return llm.ErrorfToolOut("failed to create stdin pipe: %w", err)
@@ -0,0 +1,229 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+const (
+	// defaultScriptMemory caps the memory a JavaScript script may keep live
+	// when MemoryLimit isn't set. Scripts run inside Percy, so without a
+	// cap a runaway one could take Percy down with it.
+	defaultScriptMemory = 512 << 20
+	// scriptMemoryChecks is how many checks in a row must find the live
+	// heap over the limit before a script is stopped.
+	scriptMemoryChecks = 3
+	// maxScriptCallDepth bounds recursion in JavaScript scripts.
+	maxScriptCallDepth = 10000
+)
+
+var errScriptMemory = errors.New("script exceeded its memory limit")
+
+// memoryLimit is the number of bytes a JavaScript script may keep live.
+// The sandbox's memory limit is for processes, and the interpreter runs
+// inside Percy, so it doesn't apply.
+func (s *ScriptedToolsTool) memoryLimit() int64 {
+	if s.MemoryLimit > 0 {
+		return s.MemoryLimit
+	}
+	return defaultScriptMemory
+}
+
+// runJavaScript runs script in an embedded JavaScript interpreter. Tools are
+// async functions that take an object of arguments; only what the script
+// prints is returned.
+func (s *ScriptedToolsTool) runJavaScript(ctx context.Context, script string) llm.ToolOut {
+	execCtx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	vm := goja.New()
+	vm.SetMaxCallStackSize(maxScriptCallDepth)
+
+	var output strings.Builder
+	printValues := func(call goja.FunctionCall) goja.Value {
+		for i, arg := range call.Arguments {
+			if i > 0 {
+				output.WriteByte(' ')
+			}
+			output.WriteString(formatScriptValue(vm, arg))
+		}
+		output.WriteByte('\n')
+		return goja.Undefined()
+	}
+	console := vm.NewObject()
+	for _, name := range []string{"log", "info", "warn", "error", "debug"} {
+		console.Set(name, printValues)
+	}
+	vm.Set("print", printValues)
+	vm.Set("console", console)
+
+	for _, t := range filterScriptableTools(s.Tools) {
+		vm.Set(t.Name, s.scriptToolFunc(execCtx, vm, t.Name))
+	}
+
+	// Interrupt the script when it times out or uses too much memory.
+	stopInterrupt := context.AfterFunc(execCtx, func() { vm.Interrupt(execCtx.Err()) })
+	defer stopInterrupt()
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	go watchScriptMemory(vm, s.memoryLimit(), stopWatching)
+
+	// The script is the body of an async function, so that it can await
+	// tools at the top level. It starts on the first line, so that error
+	// positions match the script's lines.
+	result, err := vm.RunScript("script.js", "(async () => {"+script+"\n})()")
+	var (
+		interrupted *goja.InterruptedError
+		overflow    *goja.StackOverflowError
+	)
+	switch {
+	case errors.As(err, &interrupted):
+		switch {
+		case errors.Is(interrupted, errScriptMemory):
+			return llm.ErrorfToolOut("script exceeded its memory limit of %d MiB\n%s", s.memoryLimit()>>20, output.String())
+		case errors.Is(execCtx.Err(), context.DeadlineExceeded):
+			return llm.ErrorfToolOut("script timed out after %s\n%s", s.timeout(), output.String())
+		}
+		return llm.ErrorfToolOut("script canceled: %w", execCtx.Err())
+	case errors.As(err, &overflow):
+		return llm.ToolOut{LLMContent: llm.TextContent(output.String() + "RangeError: Maximum call stack size exceeded" + err.Error())}
+	case err != nil:
+		// Like a Python traceback, the error is content the agent can act on.
+		return llm.ToolOut{LLMContent: llm.TextContent(output.String() + err.Error())}
+	}
+
+	promise, ok := result.Export().(*goja.Promise)
+	if !ok {
+		return llm.ErrorfToolOut("script did not run")
+	}
+	switch promise.State() {
+	case goja.PromiseStateRejected:
+		return llm.ToolOut{LLMContent: llm.TextContent(output.String() + "Uncaught " + formatScriptError(promise.Result()))}
+	case goja.PromiseStatePending:
+		return llm.ToolOut{LLMContent: llm.TextContent(output.String() + "Script did not finish: it awaits a promise that never settles.")}
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(output.String())}
+}
+
+// scriptToolFunc returns the JavaScript function that calls a tool. It
+// returns a promise of the tool's text output, rejected with the tool's
+// error if it fails.
+func (s *ScriptedToolsTool) scriptToolFunc(ctx context.Context, vm *goja.Runtime, name string) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		arg := call.Argument(0)
+		input := json.RawMessage("{}")
+		if !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+			if _, ok := arg.(*goja.Object); !ok {
+				panic(vm.NewTypeError("%s takes an object of arguments, like %s({...})", name, name))
+			}
+			data, err := json.Marshal(arg.Export())
+			if err != nil {
+				panic(vm.NewTypeError("%s: arguments can't be converted to JSON: %s", name, err))
+			}
+			input = data
+		}
+
+		promise, resolve, reject := vm.NewPromise()
+		resp := s.executeTool(ctx, ipcRequest{Tool: name, Input: input})
+		if resp.Error != "" {
+			errValue, err := vm.New(vm.Get("Error"), vm.ToValue(resp.Error))
+			if err != nil {
+				panic(err)
+			}
+			reject(errValue)
+		} else {
+			resolve(resp.Result)
+		}
+		return vm.ToValue(promise)
+	}
+}
+
+// formatScriptValue formats a printed value: strings as they are, other
+// values as JSON where possible.
+func formatScriptValue(vm *goja.Runtime, v goja.Value) string {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return v.String()
+	}
+	if _, isFunc := goja.AssertFunction(v); isFunc {
+		return v.String()
+	}
+	if obj.ClassName() == "Error" {
+		return formatScriptError(v)
+	}
+	stringify, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("stringify"))
+	s, err := stringify(goja.Undefined(), v)
+	if err != nil || goja.IsUndefined(s) {
+		return v.String()
+	}
+	return s.String()
+}
+
+// formatScriptError formats a thrown value, with its stack if it has one.
+// Frames in Go, such as the tool function that threw, are left out.
+func formatScriptError(v goja.Value) string {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return v.String()
+	}
+	stack := obj.Get("stack")
+	if stack == nil || goja.IsUndefined(stack) {
+		return v.String()
+	}
+	var lines []string
+	for line := range strings.Lines(stack.String()) {
+		if !strings.HasSuffix(strings.TrimSpace(line), "(native)") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// watchScriptMemory interrupts vm with errScriptMemory if the live heap
+// stays more than limit bytes above where it started, until stop is
+// closed. The heap is shared with the rest of Percy, so the limit is
+// approximate: garbage, wherever it comes from, is collected before the
+// heap is counted, but live memory of other goroutines counts too.
+func watchScriptMemory(vm *goja.Runtime, limit int64, stop <-chan struct{}) {
+	heap := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	live := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	read := func(s []metrics.Sample) int64 {
+		metrics.Read(s)
+		return int64(s[0].Value.Uint64())
+	}
+	runtime.GC()
+	start := read(live)
+	over := 0
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// The heap's objects include garbage; collect it, which
+			// is expensive, only when they alone exceed the limit.
+			if read(heap)-start <= limit {
+				over = 0
+				continue
+			}
+			runtime.GC()
+			if read(live)-start <= limit {
+				over = 0
+				continue
+			}
+			if over++; over >= scriptMemoryChecks {
+				vm.Interrupt(errScriptMemory)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// runJS runs a JavaScript script with read_file, which returns the path it
+// was given, and fail, which always fails.
+func runJS(t *testing.T, st *ScriptedToolsTool, script string) llm.ToolOut {
+	t.Helper()
+	st.Runtime = ScriptRuntimeJavaScript
+	if st.WorkingDir == nil {
+		st.WorkingDir = NewMutableWorkingDir(t.TempDir())
+	}
+	st.Tools = append(st.Tools,
+		&llm.Tool{
+			Name: "read_file",
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				var args struct {
+					Path string `json:"path"`
+				}
+				json.Unmarshal(input, &args)
+				return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("content of %s", args.Path))}
+			},
+		},
+		&llm.Tool{
+			Name: "fail",
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				return llm.ErrorfToolOut("file not found: missing.go")
+			},
+		},
+	)
+	input, _ := json.Marshal(scriptedToolsInput{Script: script})
+	return st.Run(context.Background(), input)
+}
+
+func TestScriptedToolsJavaScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"print", `print("hello", 1, [1, 2], {a: true})`, "hello 1 [1,2] {\"a\":true}\n"},
+		{"console", `console.log("log"); console.error("error")`, "log\nerror\n"},
+		{"tool call", `const c = await read_file({path: "a.go"}); print(c)`, "content of a.go\n"},
+		{
+			"parallel tool calls",
+			`const cs = await Promise.all(["a.go", "b.go"].map(p => read_file({path: p})))
+print(cs.length, cs[1])`,
+			"2 content of b.go\n",
+		},
+		{"return", "print(1)\nreturn\nprint(2)", "1\n"},
+		{"caught tool error", `try { await fail() } catch (e) { print("caught:", e.message) }`, "caught: file not found: missing.go\n"},
+		{"tool error", "print(\"before\")\nawait fail({})", "before\nUncaught Error: file not found: missing.go\n\tat script.js:2:"},
+		{"syntax error", "print(1\n", "SyntaxError"},
+		{"reference error", "\nundefinedFunction()", "ReferenceError: undefinedFunction is not defined\n\tat script.js:2:"},
+		{"bad arguments", `await read_file("a.go")`, "TypeError: read_file takes an object of arguments"},
+		{"never settles", `await new Promise(() => {})`, "it awaits a promise that never settles"},
+		{"no filesystem", `print(typeof require, typeof process)`, "undefined undefined\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := runJS(t, &ScriptedToolsTool{}, tt.script)
+			if out.Error != nil {
+				t.Fatalf("unexpected error: %v", out.Error)
+			}
+			if got := out.LLMContent[0].Text; !strings.Contains(got, tt.want) {
+				t.Errorf("output = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptedToolsJavaScript_Limits(t *testing.T) {
+	start := time.Now()
+	out := runJS(t, &ScriptedToolsTool{Timeout: 200 * time.Millisecond}, "print(\"started\")\nwhile (true) {}")
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "timed out after 200ms\nstarted") {
+		t.Errorf("infinite loop: error = %v", out.Error)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout took %s", elapsed)
+	}
+
+	st := &ScriptedToolsTool{MemoryLimit: 32 << 20}
+	out = runJS(t, st, `const a = []; while (true) { a.push("x".repeat(1024) + a.length) }`)
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "memory limit of 32 MiB") {
+		t.Errorf("allocating forever: error = %v", out.Error)
+	}
+
+	out = runJS(t, &ScriptedToolsTool{}, `function f(n) { return f(n + 1) } f(0)`)
+	if out.Error != nil || !strings.Contains(out.LLMContent[0].Text, "call stack size exceeded") {
+		t.Errorf("infinite recursion: %+v", out)
+	}
+}
+
+// scriptMemorySink keeps allocations in TestScriptedToolsJavaScript_OtherAllocations
+// from being optimized away.
+var scriptMemorySink []byte
+
+func TestScriptedToolsJavaScript_OtherAllocations(t *testing.T) {
+	// Without collections, garbage from the rest of the process piles up
+	// well past the script's limit while the script runs.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				scriptMemorySink = make([]byte, 1<<20)
+			}
+		}
+	})
+	defer wg.Wait()
+	defer close(stop)
+
+	st := &ScriptedToolsTool{MemoryLimit: 16 << 20}
+	out := runJS(t, st, `const end = Date.now() + 500; let n = 0; while (Date.now() < end) { n++ } print("done")`)
+	if out.Error != nil || !strings.Contains(out.LLMContent[0].Text, "done") {
+		t.Errorf("script stopped by other goroutines' allocations: %+v", out)
+	}
+}
+
+func TestScriptedTools_Runtime(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // no uv
+	st := &ScriptedToolsTool{Tools: []*llm.Tool{{Name: "bash"}}, WorkingDir: NewMutableWorkingDir(t.TempDir())}
+	tool := st.Tool()
+	if !strings.Contains(tool.Description, "JavaScript") || !strings.Contains(string(tool.InputSchema), "JavaScript") {
+		t.Errorf("without uv, the tool isn't for JavaScript:\n%s\n%s", tool.Description, tool.InputSchema)
+	}
+	input, _ := json.Marshal(scriptedToolsInput{Script: `print("hi")`})
+	if out := st.Run(context.Background(), input); out.Error != nil || out.LLMContent[0].Text != "hi\n" {
+		t.Errorf("without uv: %+v", out)
+	}
+
+	st.Runtime = ScriptRuntimePython
+	if tool := st.Tool(); !strings.Contains(tool.Description, "Python") {
+		t.Errorf("python runtime description:\n%s", tool.Description)
+	}
+	if out := st.Run(context.Background(), input); out.Error == nil || !strings.Contains(out.Error.Error(), "uv not found") {
+		t.Errorf("python without uv: error = %v", out.Error)
+	}
+}
@@ -0,0 +1,192 @@
+package claudetool
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// SecondOpinionTool asks another model to critique the agent's plan or
+// changes, showing it the recent conversation and, optionally, the diff.
+type SecondOpinionTool struct {
+	LLMProvider LLMServiceProvider
+	// Model is the model selector to ask by default. If empty, the first
+	// available model other than ConversationModel is asked.
+	Model string
+	// ConversationModel is the model of the conversation.
+	ConversationModel string
+	WorkingDir        *MutableWorkingDir
+	// RecentMessages returns the conversation's history. May be nil.
+	RecentMessages func() []llm.Message
+}
+
+const (
+	secondOpinionName        = "second_opinion"
+	secondOpinionDescription = `Ask a different model for a second opinion on your plan, a decision, or your changes.
+
+The reviewer sees the recent conversation, your question and, if requested, the uncommitted git diff; it cannot run tools or read other files, so include any details it needs in the question.
+Use it before committing to a risky or hard-to-reverse approach, or to review a non-trivial diff before reporting it as done. It is slow and costs a model call; don't use it for routine steps.
+`
+	secondOpinionInputSchema = `{
+  "type": "object",
+  "required": ["question"],
+  "properties": {
+    "question": {
+      "type": "string",
+      "description": "What to review and what you want to know, e.g. the plan to critique or the concern about the diff"
+    },
+    "include_diff": {
+      "type": "boolean",
+      "description": "Include the uncommitted changes and untracked files of the working directory"
+    },
+    "model": {
+      "type": "string",
+      "description": "Model ID to ask instead of the default reviewer"
+    }
+  }
+}`
+
+	secondOpinionSystemPrompt = `You are a senior software engineer giving a second opinion to an AI coding agent.
+Critique the agent's plan or changes: point out bugs, risks, missed requirements and simpler alternatives, most important first.
+Be specific and concise. If the approach is sound, say so plainly rather than inventing problems.
+You cannot run tools or see anything beyond what is shown.`
+
+	// secondOpinionTranscriptBytes and secondOpinionDiffBytes limit what the
+	// reviewer is shown.
+	secondOpinionTranscriptBytes = 30000
+	secondOpinionDiffBytes       = 40000
+)
+
+type secondOpinionInput struct {
+	Question    string `json:"question"`
+	IncludeDiff bool   `json:"include_diff"`
+	Model       string `json:"model"`
+}
+
+// Tool returns an llm.Tool for asking for a second opinion.
+func (s *SecondOpinionTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        secondOpinionName,
+		Description: secondOpinionDescription,
+		InputSchema: llm.MustSchema(secondOpinionInputSchema),
+		Run:         s.Run,
+	}
+}
+
+// Run executes the second_opinion tool.
+func (s *SecondOpinionTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req secondOpinionInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse second_opinion input: %w", err)
+	}
+	if strings.TrimSpace(req.Question) == "" {
+		return llm.ErrorfToolOut("question is required")
+	}
+
+	modelID, svc, err := s.reviewer(req.Model)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	var prompt strings.Builder
+	if s.RecentMessages != nil {
+		if transcript := llm.FormatTranscript(s.RecentMessages(), secondOpinionTranscriptBytes); transcript != "" {
+			fmt.Fprintf(&prompt, "<conversation>\n%s\n</conversation>\n\n", transcript)
+		}
+	}
+	if req.IncludeDiff {
+		diff, err := s.diff(ctx)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to get the diff: %w", err)
+		}
+		fmt.Fprintf(&prompt, "<diff>\n%s\n</diff>\n\n", cmp.Or(diff, "(no uncommitted changes)"))
+	}
+	fmt.Fprintf(&prompt, "The agent asks:\n%s", req.Question)
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+	defer cancel()
+	resp, err := svc.Do(ctx, &llm.Request{
+		System:   []llm.SystemContent{{Text: secondOpinionSystemPrompt}},
+		Messages: []llm.Message{llm.UserStringMessage(prompt.String())},
+	})
+	if err != nil {
+		return llm.ErrorfToolOut("%s failed to respond: %w", modelID, err)
+	}
+	opinion := strings.TrimSpace(llm.ResponseText(resp))
+	if opinion == "" {
+		return llm.ErrorfToolOut("%s returned an empty response", modelID)
+	}
+	return llm.ToolOut{
+		LLMContent: llm.TextContent(fmt.Sprintf("Second opinion from %s:\n\n%s", modelID, opinion)),
+		Display:    map[string]string{"model": modelID, "opinion": opinion},
+	}
+}
+
+// reviewer returns the model to ask: the requested one, the configured
+// one, or the first available model other than the conversation's.
+func (s *SecondOpinionTool) reviewer(requested string) (string, llm.Service, error) {
+	if s.LLMProvider == nil {
+		return "", nil, fmt.Errorf("no models are available")
+	}
+	if id := cmp.Or(requested, s.Model); id != "" {
+		svc, err := s.LLMProvider.GetService(id)
+		if err != nil {
+			return "", nil, fmt.Errorf("model %s is not available: %w", id, err)
+		}
+		return id, svc, nil
+	}
+	for _, id := range s.LLMProvider.GetAvailableModels() {
+		if id == s.ConversationModel || id == "predictable" {
+			continue
+		}
+		if svc, err := s.LLMProvider.GetService(id); err == nil {
+			return id, svc, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no model other than %s is available; pass a model to ask", s.ConversationModel)
+}
+
+// diff returns the uncommitted changes of the working directory and the
+// names of untracked files.
+func (s *SecondOpinionTool) diff(ctx context.Context) (string, error) {
+	dir := s.WorkingDir.Get()
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		return string(out), err
+	}
+	var diff string
+	if _, err := git("rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		if diff, err = git("diff", "HEAD"); err != nil {
+			return "", err
+		}
+	} else {
+		// No commits yet: everything staged is new, plus any changes
+		// made since it was staged.
+		staged, err := git("diff", "--cached")
+		if err != nil {
+			return "", err
+		}
+		unstaged, err := git("diff")
+		if err != nil {
+			return "", err
+		}
+		diff = staged + unstaged
+	}
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "--others", "--exclude-standard")
+	cmd.Dir = dir
+	if untracked, err := cmd.Output(); err == nil && len(untracked) > 0 {
+		diff += "\nUntracked files:\n" + string(untracked)
+	}
+	if len(diff) > secondOpinionDiffBytes {
+		diff = diff[:secondOpinionDiffBytes] + fmt.Sprintf("\n[... %d more bytes of diff omitted]", len(diff)-secondOpinionDiffBytes)
+	}
+	return strings.TrimSpace(diff), nil
+}
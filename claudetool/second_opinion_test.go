@@ -0,0 +1,158 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// reviewerService records the prompt it was sent.
+type reviewerService struct {
+	mockService
+	prompt string
+}
+
+func (r *reviewerService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	r.prompt = req.Messages[0].Content[0].Text
+	return &llm.Response{Content: llm.TextContent("Looks risky: the cache is never invalidated.")}, nil
+}
+
+// reviewerProvider serves a reviewerService for each of its models.
+type reviewerProvider struct {
+	models   []string
+	services map[string]*reviewerService
+}
+
+func (p *reviewerProvider) GetService(modelID string) (llm.Service, error) {
+	svc, ok := p.services[modelID]
+	if !ok {
+		return nil, fmt.Errorf("unsupported model: %s", modelID)
+	}
+	return svc, nil
+}
+
+func (p *reviewerProvider) GetAvailableModels() []string {
+	return p.models
+}
+
+func newReviewerProvider(models ...string) *reviewerProvider {
+	p := &reviewerProvider{models: models, services: map[string]*reviewerService{}}
+	for _, id := range models {
+		p.services[id] = &reviewerService{}
+	}
+	return p
+}
+
+func TestSecondOpinion(t *testing.T) {
+	provider := newReviewerProvider("predictable", "claude-opus-4-6", "gpt-5.2-codex")
+	tool := &SecondOpinionTool{
+		LLMProvider:       provider,
+		ConversationModel: "claude-opus-4-6",
+		WorkingDir:        NewMutableWorkingDir(t.TempDir()),
+		RecentMessages: func() []llm.Message {
+			return []llm.Message{llm.UserStringMessage("Add caching to the lookup service.")}
+		},
+	}
+
+	out := tool.Run(context.Background(), json.RawMessage(`{"question": "Is caching lookups in a map safe?"}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if text := out.LLMContent[0].Text; !strings.HasPrefix(text, "Second opinion from gpt-5.2-codex:") || !strings.Contains(text, "never invalidated") {
+		t.Errorf("unexpected output: %q", text)
+	}
+	prompt := provider.services["gpt-5.2-codex"].prompt
+	for _, want := range []string{"Add caching to the lookup service.", "Is caching lookups in a map safe?"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+
+	// An explicit model is asked even if it is the conversation's.
+	out = tool.Run(context.Background(), json.RawMessage(`{"question": "?", "model": "claude-opus-4-6"}`))
+	if out.Error != nil || provider.services["claude-opus-4-6"].prompt == "" {
+		t.Errorf("requested model not asked: %v", out.Error)
+	}
+	out = tool.Run(context.Background(), json.RawMessage(`{"question": "?", "model": "missing"}`))
+	if out.Error == nil {
+		t.Error("expected an error for an unavailable model")
+	}
+
+	// No other model to ask.
+	tool.LLMProvider = newReviewerProvider("claude-opus-4-6")
+	if out := tool.Run(context.Background(), json.RawMessage(`{"question": "?"}`)); out.Error == nil {
+		t.Error("expected an error without another model")
+	}
+}
+
+func TestSecondOpinionDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.email=t@example.com", "-c", "user.name=t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cache.go"), []byte("package cache\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := newReviewerProvider("gpt-5.2-codex")
+	tool := &SecondOpinionTool{
+		LLMProvider: provider,
+		Model:       "gpt-5.2-codex",
+		WorkingDir:  NewMutableWorkingDir(dir),
+	}
+	out := tool.Run(context.Background(), json.RawMessage(`{"question": "Review my change.", "include_diff": true}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if prompt := provider.services["gpt-5.2-codex"].prompt; !strings.Contains(prompt, "<diff>") || !strings.Contains(prompt, "Untracked files:\ncache.go") {
+		t.Errorf("diff not included:\n%s", prompt)
+	}
+}
+
+func TestSecondOpinionDiffNoCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cache.go"), []byte("package cache\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("todo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "cache.go"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	provider := newReviewerProvider("gpt-5.2-codex")
+	tool := &SecondOpinionTool{
+		LLMProvider: provider,
+		Model:       "gpt-5.2-codex",
+		WorkingDir:  NewMutableWorkingDir(dir),
+	}
+	out := tool.Run(context.Background(), json.RawMessage(`{"question": "Review my change.", "include_diff": true}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	prompt := provider.services["gpt-5.2-codex"].prompt
+	if !strings.Contains(prompt, "+package cache") || !strings.Contains(prompt, "Untracked files:\nnotes.txt") {
+		t.Errorf("diff not included:\n%s", prompt)
+	}
+}
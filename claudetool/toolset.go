@@ -6,7 +6,10 @@ import (
 	"sync"
 
 	"github.com/tgruben-circuit/percy/claudetool/browse"
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
 	"github.com/tgruben-circuit/percy/claudetool/lsp"
+	"github.com/tgruben-circuit/percy/claudetool/postedit"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/cluster"
 	"github.com/tgruben-circuit/percy/llm"
 	"github.com/tgruben-circuit/percy/skills"
@@ -76,10 +79,37 @@ type ToolSetConfig struct {
 	// TodoVerifierModel is the model selector used to verify todo completion.
 	// Empty disables todo verification.
 	TodoVerifierModel string
-	// MemorySearchTool is the pre-built memory search tool. If set, it's added to the tool set.
-	MemorySearchTool *llm.Tool
+	// SecondOpinionModel is the model selector the second_opinion tool asks
+	// by default. Empty picks another available model.
+	SecondOpinionModel string
+	// RecentMessages returns the conversation's history, for tools that
+	// show it to another model (second_opinion). May be nil.
+	RecentMessages func() []llm.Message
+	// MemoryTools are the pre-built memory tools (memory_search, memory_save, memory_forget).
+	// If set, they're added to the tool set.
+	MemoryTools []*llm.Tool
 	// AvailableSkills is the list of discovered skills. If non-empty, the skill_load tool is registered.
 	AvailableSkills []skills.Skill
+	// Sandbox, if enabled, isolates the commands run by bash, process and
+	// scripted_tools.
+	Sandbox *sandbox.Config
+	// ScriptRuntime is the language scripted_tools runs, one of
+	// ScriptRuntimes. Empty uses Python when uv is installed, and
+	// JavaScript otherwise.
+	ScriptRuntime string
+	// Checkpoints, if set, saves files before patch changes them, so that
+	// they can be rewound.
+	Checkpoints checkpoint.Saver
+	// CheckpointScanner, if set with Checkpoints, also finds and saves the
+	// files bash commands change in git workspaces. It scans the workspace
+	// before and after each command, so it is off unless configured.
+	CheckpointScanner *checkpoint.Scanner
+	// PostEdit, if enabled, formats the files patch changes and reports new
+	// errors in them.
+	PostEdit *postedit.Config
+	// LanguageServers are language servers to use besides the built-in
+	// ones. One with a built-in server's name replaces it.
+	LanguageServers []lsp.ServerConfig
 	// ClusterNode is the cluster node for multi-agent coordination.
 	// Typed as any to avoid import cycles; must be *cluster.Node.
 	ClusterNode any
@@ -108,7 +138,8 @@ func (ts *ToolSet) ActiveTools() []*llm.Tool {
 	return ts.tools
 }
 
-// Cleanup releases resources held by the tools (e.g., browser).
+// Cleanup releases resources held by the tools (e.g., browser) and kills
+// processes started with the process tool.
 func (ts *ToolSet) Cleanup() {
 	if ts.cleanup != nil {
 		ts.cleanup()
@@ -134,11 +165,31 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 	}
 	wd := NewMutableWorkingDir(workingDir)
 
+	// Sandboxed commands without network access share a private network,
+	// so that servers started by one are reachable from the others.
+	var network *sandbox.Network
+	if cfg.Sandbox.Enabled() && cfg.Sandbox.Mode == sandbox.ModeIsolated {
+		network = new(sandbox.Network)
+		sb := *cfg.Sandbox
+		sb.Network = network
+		cfg.Sandbox = &sb
+	}
+
+	// JIT installs run outside the sandbox, so they are off in it.
 	bashTool := &BashTool{
 		WorkingDir:       wd,
 		LLMProvider:      cfg.LLMProvider,
-		EnableJITInstall: cfg.EnableJITInstall,
+		EnableJITInstall: cfg.EnableJITInstall && !cfg.Sandbox.Enabled(),
 		ConversationID:   cfg.ConversationID,
+		Sandbox:          cfg.Sandbox,
+		Checkpoints:      cfg.Checkpoints,
+		Scanner:          cfg.CheckpointScanner,
+	}
+
+	// The code_intelligence tool and post-edit diagnostics share LSP servers.
+	var lspManager *lsp.Manager
+	if cfg.EnableCodeIntelligence || (cfg.PostEdit.Enabled() && cfg.PostEdit.Diagnostics) {
+		lspManager = lsp.NewManager(wd.Get, cfg.LanguageServers...)
 	}
 
 	// Use simplified patch schema for weaker models, full schema for sonnet/opus
@@ -147,6 +198,8 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		Simplified:       simplified,
 		WorkingDir:       wd,
 		ClipboardEnabled: true,
+		Checkpoints:      cfg.Checkpoints,
+		PostEdit:         postedit.New(cfg.PostEdit, lspManager, wd.Get),
 	}
 
 	keywordTool := NewKeywordToolWithWorkingDir(cfg.LLMProvider, wd)
@@ -169,6 +222,10 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 	kwTool := keywordTool.Tool()
 	kwTool.Concurrent = true
 
+	processManager := NewProcessManager(wd, cfg.ConversationID)
+	processManager.Sandbox = cfg.Sandbox
+	processTool := &ProcessTool{Manager: processManager}
+
 	tools := []*llm.Tool{
 		bashTool.Tool(),
 		patchTool.Tool(),
@@ -176,6 +233,7 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		changeDirTool.Tool(),
 		iframeTool,
 		readTool,
+		processTool.Tool(),
 	}
 
 	// Add subagent tool if configured and depth limit not reached.
@@ -211,10 +269,23 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		tools = append(tools, skillLoadTool.Tool())
 	}
 
-	if cfg.MemorySearchTool != nil {
-		tools = append(tools, cfg.MemorySearchTool)
+	if cfg.LLMProvider != nil {
+		secondOpinionTool := &SecondOpinionTool{
+			LLMProvider:       cfg.LLMProvider,
+			Model:             cfg.SecondOpinionModel,
+			ConversationModel: cfg.ModelID,
+			WorkingDir:        wd,
+			RecentMessages:    cfg.RecentMessages,
+		}
+		soTool := secondOpinionTool.Tool()
+		soTool.Deferred = true
+		soTool.Category = "review"
+		soTool.Concurrent = true
+		tools = append(tools, soTool)
 	}
 
+	tools = append(tools, cfg.MemoryTools...)
+
 	if cfg.ClusterNode != nil {
 		if node, ok := cfg.ClusterNode.(*cluster.Node); ok {
 			dispatchTool := NewDispatchTool(node).Tool()
@@ -225,7 +296,11 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		}
 	}
 
-	var cleanups []func()
+	// Background processes must not outlive the conversation.
+	cleanups := []func(){processManager.StopAll}
+	if network != nil {
+		cleanups = append(cleanups, network.Close)
+	}
 
 	if cfg.EnableBrowser {
 		// Get max image dimension from the LLM service
@@ -248,22 +323,22 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 	}
 
 	if cfg.EnableCodeIntelligence {
-		lspTools, lspCleanup := lsp.RegisterLSPTools(wd.Get)
+		// Renames and code actions write files through patch, so they
+		// are checkpointed and checked like its edits.
+		lspTools := lsp.RegisterLSPTools(lspManager, wd.Get, patchTool.WriteFiles)
 		for _, lt := range lspTools {
 			lt.Deferred = true
 			lt.Category = "lsp"
-			lt.Concurrent = true
 		}
 		tools = append(tools, lspTools...)
-		cleanups = append(cleanups, lspCleanup)
+	}
+	if lspManager != nil {
+		cleanups = append(cleanups, lspManager.Close)
 	}
 
-	var cleanup func()
-	if len(cleanups) > 0 {
-		cleanup = func() {
-			for _, fn := range cleanups {
-				fn()
-			}
+	cleanup := func() {
+		for _, fn := range cleanups {
+			fn()
 		}
 	}
 
@@ -271,6 +346,8 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 	scriptedTool := &ScriptedToolsTool{
 		Tools:      tools, // filtered at execution time via filterScriptableTools
 		WorkingDir: wd,
+		Runtime:    cfg.ScriptRuntime,
+		Sandbox:    cfg.Sandbox,
 	}
 	tools = append(tools, scriptedTool.Tool())
 
@@ -2,7 +2,10 @@ package claudetool
 
 import (
 	"context"
+	"strings"
 	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 )
 
 func TestIsStrongModel(t *testing.T) {
@@ -97,6 +100,23 @@ func TestToolSet_WorkingDir(t *testing.T) {
 	}
 }
 
+func TestToolSet_Sandbox(t *testing.T) {
+	cfg := ToolSetConfig{
+		LLMProvider: &mockLLMProvider{},
+		ModelID:     "test-model",
+		WorkingDir:  "/test",
+		Sandbox:     &sandbox.Config{Mode: sandbox.ModeIsolated},
+	}
+	ts := NewToolSet(context.Background(), cfg)
+	defer ts.Cleanup()
+
+	for _, tool := range ts.AllTools() {
+		if tool.Name == "bash" && !strings.Contains(tool.Description, "sandbox") {
+			t.Error("bash description doesn't mention the sandbox")
+		}
+	}
+}
+
 func TestToolSet_Cleanup(t *testing.T) {
 	provider := &mockLLMProvider{}
 
@@ -8,6 +8,17 @@ import (
 	"github.com/tgruben-circuit/percy/llm"
 )
 
+// resolutionFormat is the structured output format of conflict resolution.
+var resolutionFormat = &llm.ResponseFormat{
+	Name:        "conflict_resolution",
+	Description: "Record the resolved file content.",
+	Schema: llm.MustSchema(`{
+		"type": "object",
+		"properties": {"resolved_content": {"type": "string"}},
+		"required": ["resolved_content"]
+	}`),
+}
+
 // NewLLMConflictResolver returns a ConflictResolver that uses an LLM to resolve
 // merge conflicts by providing the base, ours, and theirs versions.
 func NewLLMConflictResolver(service llm.Service) ConflictResolver {
@@ -28,27 +39,24 @@ OURS version (working branch):
 THEIRS version (worker's change):
 %s
 
-Output ONLY the resolved file content. No explanation, no markdown fences, no line numbers. Just the raw file content.`,
+Respond with JSON: {"resolved_content": "..."}, where resolved_content is the complete resolved file content, with no line numbers.`,
 			path, taskTitle, taskDesc, base, ours, theirs)
 
-		resp, err := service.Do(ctx, &llm.Request{
+		var result struct {
+			ResolvedContent string `json:"resolved_content"`
+		}
+		err := llm.DoJSON(ctx, service, &llm.Request{
 			Messages: []llm.Message{
 				{
 					Role:    llm.MessageRoleUser,
 					Content: []llm.Content{{Type: llm.ContentTypeText, Text: prompt}},
 				},
 			},
-		})
+			ResponseFormat: resolutionFormat,
+		}, &result)
 		if err != nil {
 			return "", fmt.Errorf("llm resolve %q: %w", path, err)
 		}
-
-		for _, c := range resp.Content {
-			if c.Type == llm.ContentTypeText {
-				return c.Text, nil
-			}
-		}
-
-		return "", fmt.Errorf("llm resolve %q: no text in response", path)
+		return result.ResolvedContent, nil
 	}
 }
@@ -19,7 +19,7 @@ func (m *mockLLMService) TokenContextWindow() int { return 100000 }
 func (m *mockLLMService) MaxImageDimension() int  { return 0 }
 
 func TestLLMConflictResolver(t *testing.T) {
-	svc := &mockLLMService{response: "resolved content here"}
+	svc := &mockLLMService{response: `{"resolved_content": "resolved content here"}`}
 	resolver := NewLLMConflictResolver(svc)
 
 	ctx := context.Background()
@@ -10,13 +10,20 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+	"github.com/tgruben-circuit/percy/claudetool/lsp"
 	memtool "github.com/tgruben-circuit/percy/claudetool/memory"
+	"github.com/tgruben-circuit/percy/claudetool/postedit"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/cluster"
 	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/llm"
 	"github.com/tgruben-circuit/percy/memory"
 	"github.com/tgruben-circuit/percy/memory/muninn"
 	"github.com/tgruben-circuit/percy/models"
@@ -39,12 +46,15 @@ type GlobalConfig struct {
 }
 
 func main() {
+	// Sandboxed commands re-execute this binary to set up their namespaces.
+	sandbox.Init()
+
 	// Define global flags
 	var global GlobalConfig
 	defaultModelID := models.Default().ID
 	flag.StringVar(&global.DBPath, "db", "percy.db", "Path to SQLite database file")
 	flag.BoolVar(&global.Debug, "debug", false, "Enable debug logging")
-	flag.StringVar(&global.Model, "model", defaultModelID, "LLM model to use (use 'predictable' for testing)")
+	flag.StringVar(&global.Model, "model", defaultModelID, "LLM model to use (use 'predictable' or 'replay:<fixture>' for testing)")
 	flag.BoolVar(&global.PredictableOnly, "predictable-only", false, "Use only the predictable service, ignoring all other models")
 	flag.StringVar(&global.ConfigPath, "config", "", "Path to percy.json configuration file (optional)")
 	flag.StringVar(&global.DefaultModel, "default-model", defaultModelID, "Default model for web UI")
@@ -128,8 +138,23 @@ func runServe(global GlobalConfig, args []string) {
 	// Build LLM configuration
 	llmConfig := buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, global.DefaultModelSet, database)
 
+	// -model replay:<fixture> plays back a recorded conversation and becomes
+	// the default model unless -default-model says otherwise.
+	if strings.HasPrefix(global.Model, models.ReplayPrefix) {
+		llmConfig.ReplayModels = append(llmConfig.ReplayModels, global.Model)
+		if !global.DefaultModelSet {
+			llmConfig.DefaultModel = global.Model
+		}
+	}
+
 	// Initialize LLM service manager (includes custom model support via database)
 	llmManager := server.NewLLMServiceManager(llmConfig)
+	for _, id := range llmConfig.ReplayModels {
+		if !llmManager.HasModel(id) {
+			fmt.Fprintf(os.Stderr, "Error: could not load replay fixture for %s (see log)\n", id)
+			os.Exit(1)
+		}
+	}
 
 	// Log available models
 	availableModels := llmManager.GetAvailableModels()
@@ -137,6 +162,19 @@ func runServe(global GlobalConfig, args []string) {
 
 	toolSetConfig := setupToolSetConfig(llmManager)
 	toolSetConfig.TodoVerifierModel = llmConfig.TodoVerifierModel
+	toolSetConfig.SecondOpinionModel = llmConfig.SecondOpinionModel
+	toolSetConfig.Sandbox = llmConfig.Sandbox
+	toolSetConfig.ScriptRuntime = llmConfig.ScriptRuntime
+	toolSetConfig.PostEdit = llmConfig.PostEdit
+	if llmConfig.BashCheckpoints {
+		// One store for all conversations, kept outside any workspace.
+		if cacheDir, err := os.UserCacheDir(); err != nil {
+			logger.Warn("Bash checkpoints disabled: no cache directory", "error", err)
+		} else {
+			toolSetConfig.CheckpointScanner = checkpoint.NewScanner(filepath.Join(cacheDir, "percy", "checkpoints"))
+		}
+	}
+	toolSetConfig.LanguageServers = llmConfig.LanguageServers
 
 	// Create embedder if configured
 	var embedder memory.Embedder
@@ -185,13 +223,22 @@ func runServe(global GlobalConfig, args []string) {
 		}
 	}
 
-	// Wire up memory search tool if memory DB is available
+	var muninnSink *muninn.Sink
+	if muninnClient != nil {
+		muninnSink = muninn.NewSink(muninnClient)
+	}
+
+	// Wire up memory tools if memory DB is available
 	if memoryDB != nil {
 		var src *muninn.Source
 		if muninnClient != nil {
 			src = muninn.NewSource(muninnClient)
 		}
-		toolSetConfig.MemorySearchTool = memtool.NewMemorySearchTool(memoryDB, embedder, src).Tool()
+		toolSetConfig.MemoryTools = []*llm.Tool{
+			memtool.NewMemorySearchTool(memoryDB, embedder, src).Tool(),
+			memtool.NewMemorySaveTool(memoryDB, embedder, muninnSink).Tool(),
+			memtool.NewMemoryForgetTool(memoryDB).Tool(),
+		}
 	}
 
 	// Create server
@@ -200,10 +247,12 @@ func runServe(global GlobalConfig, args []string) {
 	// Pass memory DB and embedder to server for post-conversation indexing
 	svr.SetMemoryDB(memoryDB)
 	svr.SetEmbedder(embedder)
+	svr.SetMemoryRetention(memoryRetentionFromEnv(logger))
+	svr.SetWorkspaceIndexing(workspaceIndexFromEnv(logger))
 
 	// Set MuninnDB sink if client is available
-	if muninnClient != nil {
-		svr.SetMuninnSink(muninn.NewSink(muninnClient))
+	if muninnSink != nil {
+		svr.SetMuninnSink(muninnSink)
 	}
 
 	// Seed notification channels from config file if DB is empty (one-time migration)
@@ -403,6 +452,7 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		OllamaURL:       ollamaURL,
 		TerminalURL:     terminalURL,
 		DefaultModel:    defaultModel,
+		RecordPath:      os.Getenv("PERCY_LLM_RECORD"),
 		DB:              database,
 		Logger:          logger,
 	}
@@ -417,12 +467,21 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		}
 
 		var cfg struct {
-			LLMGateway           string           `json:"llm_gateway"`
-			TerminalURL          string           `json:"terminal_url"`
-			DefaultModel         string           `json:"default_model"`
-			TodoVerifierModel    string           `json:"todo_verifier_model"`
-			Links                []server.Link    `json:"links"`
-			NotificationChannels []map[string]any `json:"notification_channels"`
+			LLMGateway           string                  `json:"llm_gateway"`
+			TerminalURL          string                  `json:"terminal_url"`
+			DefaultModel         string                  `json:"default_model"`
+			TodoVerifierModel    string                  `json:"todo_verifier_model"`
+			Links                []server.Link           `json:"links"`
+			NotificationChannels []map[string]any        `json:"notification_channels"`
+			ModelCatalog         []llm.ModelSpec         `json:"model_catalog"`
+			OpenAIEndpoints      []models.OpenAIEndpoint `json:"openai_endpoints"`
+			Ensembles            []models.EnsembleConfig `json:"ensembles"`
+			SecondOpinionModel   string                  `json:"second_opinion_model"`
+			Sandbox              *sandbox.Config         `json:"sandbox"`
+			ScriptRuntime        string                  `json:"script_runtime"`
+			PostEdit             *postedit.Config        `json:"post_edit"`
+			BashCheckpoints      bool                    `json:"bash_checkpoints"`
+			LanguageServers      []lsp.ServerConfig      `json:"language_servers"`
 		}
 		if err := json.Unmarshal(data, &cfg); err != nil {
 			logger.Warn("Failed to parse config file", "path", configPath, "error", err)
@@ -476,11 +535,152 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 			llmCfg.NotificationChannels = cfg.NotificationChannels
 			logger.Info("Notification channels configured", "count", len(cfg.NotificationChannels))
 		}
+
+		for _, spec := range cfg.ModelCatalog {
+			if spec.Name == "" {
+				logger.Warn("Ignoring model_catalog entry without a name", "path", configPath)
+				continue
+			}
+			llmCfg.Catalog = append(llmCfg.Catalog, spec)
+		}
+		if len(llmCfg.Catalog) > 0 {
+			logger.Info("Loaded model catalog overrides from config", "count", len(llmCfg.Catalog))
+		}
+
+		for _, ep := range cfg.OpenAIEndpoints {
+			if ep.Name == "" || ep.URL == "" {
+				logger.Warn("Ignoring openai_endpoints entry without a name and url", "path", configPath)
+				continue
+			}
+			llmCfg.OpenAIEndpoints = append(llmCfg.OpenAIEndpoints, ep)
+		}
+		if len(llmCfg.OpenAIEndpoints) > 0 {
+			logger.Info("OpenAI-compatible endpoints configured", "count", len(llmCfg.OpenAIEndpoints))
+		}
+
+		if len(cfg.Ensembles) > 0 {
+			llmCfg.Ensembles = cfg.Ensembles
+			logger.Info("Ensembles configured", "count", len(cfg.Ensembles))
+		}
+
+		if cfg.SecondOpinionModel != "" {
+			llmCfg.SecondOpinionModel = cfg.SecondOpinionModel
+			logger.Info("Using second opinion model from config", "model", cfg.SecondOpinionModel)
+		}
+
+		if cfg.Sandbox != nil {
+			if err := cfg.Sandbox.Validate(); err != nil {
+				logger.Warn("Ignoring invalid sandbox config", "path", configPath, "error", err)
+			} else {
+				llmCfg.Sandbox = cfg.Sandbox
+				logger.Info("Sandbox configured", "mode", cfg.Sandbox.Mode)
+			}
+		}
+
+		if cfg.ScriptRuntime != "" {
+			if !slices.Contains(claudetool.ScriptRuntimes, cfg.ScriptRuntime) {
+				logger.Warn("Ignoring unknown script_runtime (use python or javascript)", "path", configPath, "script_runtime", cfg.ScriptRuntime)
+			} else {
+				llmCfg.ScriptRuntime = cfg.ScriptRuntime
+				logger.Info("Script runtime configured", "runtime", cfg.ScriptRuntime)
+			}
+		}
+
+		if cfg.BashCheckpoints {
+			llmCfg.BashCheckpoints = true
+			logger.Info("Bash checkpoints enabled")
+		}
+
+		if cfg.PostEdit.Enabled() {
+			if err := cfg.PostEdit.Validate(); err != nil {
+				logger.Warn("Ignoring invalid post_edit config", "path", configPath, "error", err)
+			} else {
+				llmCfg.PostEdit = cfg.PostEdit
+				logger.Info("Post-edit checks configured", "format", cfg.PostEdit.Format, "diagnostics", cfg.PostEdit.Diagnostics)
+			}
+		}
+
+		for _, sc := range cfg.LanguageServers {
+			if err := sc.Validate(); err != nil {
+				logger.Warn("Ignoring invalid language server config", "path", configPath, "error", err)
+				continue
+			}
+			llmCfg.LanguageServers = append(llmCfg.LanguageServers, sc)
+			logger.Info("Language server configured", "name", sc.Name, "command", sc.Command, "extensions", sc.Extensions)
+		}
 	}
 
 	return llmCfg
 }
 
+// memoryRetentionFromEnv builds the memory retention policy and job interval
+// from PERCY_MEMORY_* environment variables, falling back to the defaults.
+// Day counts of 0 disable the corresponding rule; an interval of 0 disables the job.
+func memoryRetentionFromEnv(logger *slog.Logger) (memory.RetentionPolicy, time.Duration) {
+	policy := memory.DefaultRetentionPolicy()
+	interval := 24 * time.Hour
+
+	days := func(name string, dst *time.Duration) {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				logger.Warn("Ignoring invalid memory retention setting", "name", name, "value", v)
+				return
+			}
+			*dst = time.Duration(n) * 24 * time.Hour
+		}
+	}
+	days("PERCY_MEMORY_SUPERSEDED_DAYS", &policy.SupersededMaxAge)
+	days("PERCY_MEMORY_DECAY_HALF_LIFE_DAYS", &policy.DecayHalfLife)
+
+	if v := os.Getenv("PERCY_MEMORY_MAX_CELLS_PER_TOPIC"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			policy.MaxCellsPerTopic = n
+		} else {
+			logger.Warn("Ignoring invalid memory retention setting", "name", "PERCY_MEMORY_MAX_CELLS_PER_TOPIC", "value", v)
+		}
+	}
+	if v := os.Getenv("PERCY_MEMORY_RETENTION_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			interval = d
+		} else {
+			logger.Warn("Ignoring invalid memory retention setting", "name", "PERCY_MEMORY_RETENTION_INTERVAL", "value", v)
+		}
+	}
+	return policy, interval
+}
+
+// workspaceIndexFromEnv builds the workspace document indexing config from
+// PERCY_MEMORY_WORKSPACE_* environment variables, falling back to the defaults.
+func workspaceIndexFromEnv(logger *slog.Logger) memory.WorkspaceConfig {
+	cfg := memory.DefaultWorkspaceConfig()
+	if v := os.Getenv("PERCY_MEMORY_WORKSPACE_INDEX"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			logger.Warn("Ignoring invalid workspace index setting", "name", "PERCY_MEMORY_WORKSPACE_INDEX", "value", v)
+		} else {
+			cfg.Enabled = enabled
+		}
+	}
+	if v := os.Getenv("PERCY_MEMORY_WORKSPACE_INCLUDE"); v != "" {
+		var include []string
+		for p := range strings.SplitSeq(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				include = append(include, p)
+			}
+		}
+		cfg.Include = include
+	}
+	if v := os.Getenv("PERCY_MEMORY_WORKSPACE_MAX_FILES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxFiles = n
+		} else {
+			logger.Warn("Ignoring invalid workspace index setting", "name", "PERCY_MEMORY_WORKSPACE_MAX_FILES", "value", v)
+		}
+	}
+	return cfg
+}
+
 func generateAgentID() string {
 	b := make([]byte, 6)
 	crypto_rand.Read(b)
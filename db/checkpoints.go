@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// FileCheckpoint is a file's contents saved before the agent changed it.
+type FileCheckpoint struct {
+	SequenceID int64 // the conversation's last message when the file changed
+	Path       string
+	Existed    bool // false if the agent created the file
+	Mode       int64
+	Content    []byte
+	Size       int64
+	CreatedAt  time.Time
+}
+
+// SaveFileCheckpoints stores files' contents, keyed by the conversation's
+// latest message. A file that already has a checkpoint at that message keeps
+// it, because it holds the file as it was at the message.
+func (db *DB) SaveFileCheckpoints(ctx context.Context, conversationID string, files []FileCheckpoint) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		var seq int64
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(sequence_id), 0) FROM messages WHERE conversation_id = ?`, conversationID).Scan(&seq); err != nil {
+			return err
+		}
+		for _, f := range files {
+			if _, err := tx.Exec(
+				`INSERT OR IGNORE INTO file_checkpoints (conversation_id, sequence_id, path, existed, mode, content)
+				 VALUES (?, ?, ?, ?, ?, ?)`,
+				conversationID, seq, f.Path, f.Existed, f.Mode, f.Content,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListFileCheckpoints returns a conversation's checkpoints in order,
+// without their contents.
+func (db *DB) ListFileCheckpoints(ctx context.Context, conversationID string) ([]FileCheckpoint, error) {
+	return db.queryFileCheckpoints(ctx,
+		`SELECT sequence_id, path, existed, mode, NULL, COALESCE(LENGTH(content), 0), created_at FROM file_checkpoints
+		 WHERE conversation_id = ? ORDER BY sequence_id, id`,
+		conversationID)
+}
+
+// FileCheckpointsAt returns the files changed at or after the message with
+// sequenceID, as they were at that message: the earliest checkpoint of
+// each file from the message on.
+func (db *DB) FileCheckpointsAt(ctx context.Context, conversationID string, sequenceID int64) ([]FileCheckpoint, error) {
+	return db.queryFileCheckpoints(ctx,
+		`SELECT sequence_id, path, existed, mode, content, COALESCE(LENGTH(content), 0), created_at FROM file_checkpoints c
+		 WHERE conversation_id = ? AND sequence_id = (
+		   SELECT MIN(sequence_id) FROM file_checkpoints
+		   WHERE conversation_id = c.conversation_id AND path = c.path AND sequence_id >= ?
+		 )
+		 ORDER BY path`,
+		conversationID, sequenceID)
+}
+
+// DeleteFileCheckpointsFrom deletes the checkpoints taken at or after the
+// message with sequenceID.
+func (db *DB) DeleteFileCheckpointsFrom(ctx context.Context, conversationID string, sequenceID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := tx.Exec(`DELETE FROM file_checkpoints WHERE conversation_id = ? AND sequence_id >= ?`, conversationID, sequenceID)
+		return err
+	})
+}
+
+func (db *DB) queryFileCheckpoints(ctx context.Context, query string, args ...any) ([]FileCheckpoint, error) {
+	var checkpoints []FileCheckpoint
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		rows, err := rx.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var c FileCheckpoint
+			if err := rows.Scan(&c.SequenceID, &c.Path, &c.Existed, &c.Mode, &c.Content, &c.Size, &c.CreatedAt); err != nil {
+				return err
+			}
+			checkpoints = append(checkpoints, c)
+		}
+		return rows.Err()
+	})
+	return checkpoints, err
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFileCheckpoints(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("test-checkpoints"), true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	addMessage := func() int64 {
+		t.Helper()
+		msg, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: conv.ConversationID,
+			Type:           MessageTypeUser,
+			UserData:       map[string]string{"text": "hi"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		return msg.SequenceID
+	}
+	save := func(path, content string) {
+		t.Helper()
+		if err := db.SaveFileCheckpoints(ctx, conv.ConversationID, []FileCheckpoint{{Path: path, Existed: true, Content: []byte(content)}}); err != nil {
+			t.Fatalf("SaveFileCheckpoints() error = %v", err)
+		}
+	}
+
+	first := addMessage()
+	save("/a", "a1")
+	save("/a", "a1-later") // ignored: /a already has a checkpoint at this message
+	second := addMessage()
+	save("/a", "a2")
+	save("/b", "b2")
+
+	all, err := db.ListFileCheckpoints(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("ListFileCheckpoints() error = %v", err)
+	}
+	if len(all) != 3 || all[0].SequenceID != first || all[0].Content != nil || all[0].Size != 2 {
+		t.Errorf("ListFileCheckpoints() = %+v", all)
+	}
+
+	at, err := db.FileCheckpointsAt(ctx, conv.ConversationID, first)
+	if err != nil {
+		t.Fatalf("FileCheckpointsAt() error = %v", err)
+	}
+	if len(at) != 2 || at[0].Path != "/a" || string(at[0].Content) != "a1" || string(at[1].Content) != "b2" {
+		t.Errorf("FileCheckpointsAt(first) = %+v", at)
+	}
+	at, err = db.FileCheckpointsAt(ctx, conv.ConversationID, second)
+	if err != nil {
+		t.Fatalf("FileCheckpointsAt() error = %v", err)
+	}
+	if len(at) != 2 || string(at[0].Content) != "a2" {
+		t.Errorf("FileCheckpointsAt(second) = %+v", at)
+	}
+
+	if err := db.DeleteFileCheckpointsFrom(ctx, conv.ConversationID, second); err != nil {
+		t.Fatalf("DeleteFileCheckpointsFrom() error = %v", err)
+	}
+	all, err = db.ListFileCheckpoints(ctx, conv.ConversationID)
+	if err != nil || len(all) != 1 {
+		t.Errorf("after delete: %+v, %v", all, err)
+	}
+}
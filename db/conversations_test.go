@@ -604,3 +604,31 @@ func TestConversationService_UpdateConversationCwd(t *testing.T) {
 		t.Errorf("Expected cwd %s, got %s", newCwd, *updatedConv.Cwd)
 	}
 }
+
+func TestConversationService_SetConversationSandbox(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("test-conversation-sandbox"), true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+	if conv.Sandbox != nil {
+		t.Errorf("Expected no sandbox mode, got %s", *conv.Sandbox)
+	}
+
+	if err := db.SetConversationSandbox(ctx, conv.ConversationID, "isolated"); err != nil {
+		t.Fatalf("SetConversationSandbox() error = %v", err)
+	}
+
+	updatedConv, err := db.GetConversationByID(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("Failed to get updated conversation: %v", err)
+	}
+	if updatedConv.Sandbox == nil || *updatedConv.Sandbox != "isolated" {
+		t.Errorf("Expected sandbox isolated, got %v", updatedConv.Sandbox)
+	}
+}
@@ -383,6 +383,14 @@ func (db *DB) SetConversationModel(ctx context.Context, conversationID, model st
 	})
 }
 
+// SetConversationSandbox sets the sandbox mode for a conversation.
+func (db *DB) SetConversationSandbox(ctx context.Context, conversationID, mode string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := tx.Conn().ExecContext(ctx, "UPDATE conversations SET sandbox = ? WHERE conversation_id = ?", mode, conversationID)
+		return err
+	})
+}
+
 // Message methods (moved from MessageService)
 
 // MessageType represents the type of message
@@ -18,6 +18,18 @@ type Conversation struct {
 	Archived             bool      `json:"archived"`
 	ParentConversationID *string   `json:"parent_conversation_id"`
 	Model                *string   `json:"model"`
+	Sandbox              *string   `json:"sandbox"`
+}
+
+type FileCheckpoint struct {
+	ID             int64     `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	SequenceID     int64     `json:"sequence_id"`
+	Path           string    `json:"path"`
+	Existed        bool      `json:"existed"`
+	Mode           int64     `json:"mode"`
+	Content        []byte    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 type LlmRequest struct {
@@ -56,17 +68,22 @@ type Migration struct {
 }
 
 type Model struct {
-	ModelID       string    `json:"model_id"`
-	DisplayName   string    `json:"display_name"`
-	ProviderType  string    `json:"provider_type"`
-	Endpoint      string    `json:"endpoint"`
-	ApiKey        string    `json:"api_key"`
-	ModelName     string    `json:"model_name"`
-	MaxTokens     int64     `json:"max_tokens"`
-	Tags          string    `json:"tags"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	ThinkingLevel string    `json:"thinking_level"`
+	ModelID         string    `json:"model_id"`
+	DisplayName     string    `json:"display_name"`
+	ProviderType    string    `json:"provider_type"`
+	Endpoint        string    `json:"endpoint"`
+	ApiKey          string    `json:"api_key"`
+	ModelName       string    `json:"model_name"`
+	MaxTokens       int64     `json:"max_tokens"`
+	Tags            string    `json:"tags"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	ThinkingLevel   string    `json:"thinking_level"`
+	ContextWindow   int64     `json:"context_window"`
+	InputPrice      float64   `json:"input_price"`
+	OutputPrice     float64   `json:"output_price"`
+	CacheReadPrice  float64   `json:"cache_read_price"`
+	CacheWritePrice float64   `json:"cache_write_price"`
 }
 
 type NotificationChannel struct {
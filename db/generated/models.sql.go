@@ -10,21 +10,27 @@ import (
 )
 
 const createModel = `-- name: CreateModel :one
-INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, thinking_level)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level
+INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, thinking_level,
+                    context_window, input_price, output_price, cache_read_price, cache_write_price)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level, context_window, input_price, output_price, cache_read_price, cache_write_price
 `
 
 type CreateModelParams struct {
-	ModelID       string `json:"model_id"`
-	DisplayName   string `json:"display_name"`
-	ProviderType  string `json:"provider_type"`
-	Endpoint      string `json:"endpoint"`
-	ApiKey        string `json:"api_key"`
-	ModelName     string `json:"model_name"`
-	MaxTokens     int64  `json:"max_tokens"`
-	Tags          string `json:"tags"`
-	ThinkingLevel string `json:"thinking_level"`
+	ModelID         string  `json:"model_id"`
+	DisplayName     string  `json:"display_name"`
+	ProviderType    string  `json:"provider_type"`
+	Endpoint        string  `json:"endpoint"`
+	ApiKey          string  `json:"api_key"`
+	ModelName       string  `json:"model_name"`
+	MaxTokens       int64   `json:"max_tokens"`
+	Tags            string  `json:"tags"`
+	ThinkingLevel   string  `json:"thinking_level"`
+	ContextWindow   int64   `json:"context_window"`
+	InputPrice      float64 `json:"input_price"`
+	OutputPrice     float64 `json:"output_price"`
+	CacheReadPrice  float64 `json:"cache_read_price"`
+	CacheWritePrice float64 `json:"cache_write_price"`
 }
 
 func (q *Queries) CreateModel(ctx context.Context, arg CreateModelParams) (Model, error) {
@@ -38,6 +44,11 @@ func (q *Queries) CreateModel(ctx context.Context, arg CreateModelParams) (Model
 		arg.MaxTokens,
 		arg.Tags,
 		arg.ThinkingLevel,
+		arg.ContextWindow,
+		arg.InputPrice,
+		arg.OutputPrice,
+		arg.CacheReadPrice,
+		arg.CacheWritePrice,
 	)
 	var i Model
 	err := row.Scan(
@@ -52,6 +63,11 @@ func (q *Queries) CreateModel(ctx context.Context, arg CreateModelParams) (Model
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.ThinkingLevel,
+		&i.ContextWindow,
+		&i.InputPrice,
+		&i.OutputPrice,
+		&i.CacheReadPrice,
+		&i.CacheWritePrice,
 	)
 	return i, err
 }
@@ -66,7 +82,7 @@ func (q *Queries) DeleteModel(ctx context.Context, modelID string) error {
 }
 
 const getModel = `-- name: GetModel :one
-SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level FROM models WHERE model_id = ?
+SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level, context_window, input_price, output_price, cache_read_price, cache_write_price FROM models WHERE model_id = ?
 `
 
 func (q *Queries) GetModel(ctx context.Context, modelID string) (Model, error) {
@@ -84,12 +100,17 @@ func (q *Queries) GetModel(ctx context.Context, modelID string) (Model, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.ThinkingLevel,
+		&i.ContextWindow,
+		&i.InputPrice,
+		&i.OutputPrice,
+		&i.CacheReadPrice,
+		&i.CacheWritePrice,
 	)
 	return i, err
 }
 
 const getModels = `-- name: GetModels :many
-SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level FROM models ORDER BY created_at ASC
+SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level, context_window, input_price, output_price, cache_read_price, cache_write_price FROM models ORDER BY created_at ASC
 `
 
 func (q *Queries) GetModels(ctx context.Context) ([]Model, error) {
@@ -113,6 +134,11 @@ func (q *Queries) GetModels(ctx context.Context) ([]Model, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.ThinkingLevel,
+			&i.ContextWindow,
+			&i.InputPrice,
+			&i.OutputPrice,
+			&i.CacheReadPrice,
+			&i.CacheWritePrice,
 		); err != nil {
 			return nil, err
 		}
@@ -137,21 +163,31 @@ SET display_name = ?,
     max_tokens = ?,
     tags = ?,
     thinking_level = ?,
+    context_window = ?,
+    input_price = ?,
+    output_price = ?,
+    cache_read_price = ?,
+    cache_write_price = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE model_id = ?
-RETURNING model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level
+RETURNING model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level, context_window, input_price, output_price, cache_read_price, cache_write_price
 `
 
 type UpdateModelParams struct {
-	DisplayName   string `json:"display_name"`
-	ProviderType  string `json:"provider_type"`
-	Endpoint      string `json:"endpoint"`
-	ApiKey        string `json:"api_key"`
-	ModelName     string `json:"model_name"`
-	MaxTokens     int64  `json:"max_tokens"`
-	Tags          string `json:"tags"`
-	ThinkingLevel string `json:"thinking_level"`
-	ModelID       string `json:"model_id"`
+	DisplayName     string  `json:"display_name"`
+	ProviderType    string  `json:"provider_type"`
+	Endpoint        string  `json:"endpoint"`
+	ApiKey          string  `json:"api_key"`
+	ModelName       string  `json:"model_name"`
+	MaxTokens       int64   `json:"max_tokens"`
+	Tags            string  `json:"tags"`
+	ThinkingLevel   string  `json:"thinking_level"`
+	ContextWindow   int64   `json:"context_window"`
+	InputPrice      float64 `json:"input_price"`
+	OutputPrice     float64 `json:"output_price"`
+	CacheReadPrice  float64 `json:"cache_read_price"`
+	CacheWritePrice float64 `json:"cache_write_price"`
+	ModelID         string  `json:"model_id"`
 }
 
 func (q *Queries) UpdateModel(ctx context.Context, arg UpdateModelParams) (Model, error) {
@@ -164,6 +200,11 @@ func (q *Queries) UpdateModel(ctx context.Context, arg UpdateModelParams) (Model
 		arg.MaxTokens,
 		arg.Tags,
 		arg.ThinkingLevel,
+		arg.ContextWindow,
+		arg.InputPrice,
+		arg.OutputPrice,
+		arg.CacheReadPrice,
+		arg.CacheWritePrice,
 		arg.ModelID,
 	)
 	var i Model
@@ -179,6 +220,11 @@ func (q *Queries) UpdateModel(ctx context.Context, arg UpdateModelParams) (Model
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.ThinkingLevel,
+		&i.ContextWindow,
+		&i.InputPrice,
+		&i.OutputPrice,
+		&i.CacheReadPrice,
+		&i.CacheWritePrice,
 	)
 	return i, err
 }
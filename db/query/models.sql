@@ -5,8 +5,9 @@ SELECT * FROM models ORDER BY created_at ASC;
 SELECT * FROM models WHERE model_id = ?;
 
 -- name: CreateModel :one
-INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, thinking_level)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, thinking_level,
+                    context_window, input_price, output_price, cache_read_price, cache_write_price)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: UpdateModel :one
@@ -19,6 +20,11 @@ SET display_name = ?,
     max_tokens = ?,
     tags = ?,
     thinking_level = ?,
+    context_window = ?,
+    input_price = ?,
+    output_price = ?,
+    cache_read_price = ?,
+    cache_write_price = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE model_id = ?
 RETURNING *;
@@ -0,0 +1,8 @@
+-- Model catalog overrides for custom models
+-- context_window is in tokens; prices are USD per million tokens.
+-- Zero means "use the built-in model catalog entry for model_name".
+ALTER TABLE models ADD COLUMN context_window INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN input_price REAL NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN output_price REAL NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN cache_read_price REAL NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN cache_write_price REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,34 @@
+-- Add 'ollama', 'bedrock' and 'vertex' to the models provider_type check constraint
+-- SQLite doesn't support ALTER TABLE to modify CHECK constraints, so the table is rebuilt
+
+-- Step 1: Create a new models table with the updated constraint
+CREATE TABLE models_new (
+    model_id TEXT PRIMARY KEY,
+    display_name TEXT NOT NULL,
+    provider_type TEXT NOT NULL CHECK (provider_type IN ('anthropic', 'openai', 'openai-responses', 'gemini', 'ollama', 'bedrock', 'vertex')),
+    endpoint TEXT NOT NULL,
+    api_key TEXT NOT NULL,
+    model_name TEXT NOT NULL,  -- The actual model name sent to the API (e.g., "claude-sonnet-4-5-20250514")
+    max_tokens INTEGER NOT NULL DEFAULT 200000,
+    tags TEXT NOT NULL DEFAULT '',  -- Comma-separated tags (e.g., "slug" for slug generation)
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    thinking_level TEXT NOT NULL DEFAULT 'medium',
+    context_window INTEGER NOT NULL DEFAULT 0,
+    input_price REAL NOT NULL DEFAULT 0,
+    output_price REAL NOT NULL DEFAULT 0,
+    cache_read_price REAL NOT NULL DEFAULT 0,
+    cache_write_price REAL NOT NULL DEFAULT 0
+);
+
+-- Step 2: Copy data from old table to new table
+INSERT INTO models_new (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level,
+                        context_window, input_price, output_price, cache_read_price, cache_write_price)
+SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, thinking_level,
+       context_window, input_price, output_price, cache_read_price, cache_write_price FROM models;
+
+-- Step 3: Drop the old table
+DROP TABLE models;
+
+-- Step 4: Rename the new table
+ALTER TABLE models_new RENAME TO models;
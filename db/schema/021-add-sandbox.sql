@@ -0,0 +1,5 @@
+-- Add sandbox column to conversations table
+-- This stores the sandbox mode chosen for the conversation ("off", "isolated"
+-- or "network"); NULL uses the server's default
+
+ALTER TABLE conversations ADD COLUMN sandbox TEXT;
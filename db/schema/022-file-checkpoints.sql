@@ -0,0 +1,17 @@
+-- File checkpoints: a file's contents saved before the agent changed it, so
+-- the change can be rewound. sequence_id is the conversation's last message
+-- when the file changed, so the earliest checkpoint of a file at or after a
+-- message holds the file as it was at that message.
+
+CREATE TABLE file_checkpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id TEXT NOT NULL,
+    sequence_id INTEGER NOT NULL,
+    path TEXT NOT NULL,
+    existed BOOLEAN NOT NULL,  -- false if the agent created the file
+    mode INTEGER NOT NULL DEFAULT 0,
+    content BLOB,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (conversation_id, sequence_id, path),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,362 @@
+// Package hooks runs user-defined commands at points in a conversation's
+// lifecycle: around tool calls, when the user submits a prompt, at the end of
+// a turn and when a conversation starts.
+//
+// Hooks are configured in hooks.json files, which map event names to lists of
+// commands:
+//
+//	{
+//	  "pre_tool_use": [
+//	    {"matcher": "patch", "command": "./scripts/check-vendor.sh"}
+//	  ],
+//	  "post_tool_use": [
+//	    {"matcher": "patch", "command": "golangci-lint run ./... >&2 || exit 2", "timeout": 120}
+//	  ]
+//	}
+//
+// Each command runs with sh in the conversation's working directory and
+// receives an Input as JSON on stdin. A command that exits 2 blocks the call,
+// with its stderr as the reason. A command that exits 0 may print an output
+// object as JSON to block, rewrite the tool input or add context; any other
+// output is added as context. Other exit statuses are logged and ignored.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Event is a point in a conversation at which hooks run.
+type Event string
+
+const (
+	// PreToolUse runs before a tool is called. Hooks can block the call or
+	// rewrite its input.
+	PreToolUse Event = "pre_tool_use"
+	// PostToolUse runs after a tool is called. Hooks can mark the result as
+	// an error or add context to it.
+	PostToolUse Event = "post_tool_use"
+	// UserPromptSubmit runs when the user sends a message. Hooks can reject
+	// the message or add context to it.
+	UserPromptSubmit Event = "user_prompt_submit"
+	// TurnEnd runs when the agent finishes its turn.
+	TurnEnd Event = "turn_end"
+	// ConversationStart runs before the first message of a conversation.
+	// Hooks can reject the message or add context to it.
+	ConversationStart Event = "conversation_start"
+)
+
+// Events lists the events hooks can be configured for.
+var Events = []Event{PreToolUse, PostToolUse, UserPromptSubmit, TurnEnd, ConversationStart}
+
+// DefaultTimeout is how long a hook may run when it doesn't set a timeout.
+const DefaultTimeout = 60 * time.Second
+
+// blockExitCode is the exit status with which a command blocks the call.
+const blockExitCode = 2
+
+// Hook is a command run for an event.
+type Hook struct {
+	// Matcher is a regular expression that must match the whole tool name
+	// for the hook to run. Empty matches every tool. It only applies to
+	// tool events.
+	Matcher string `json:"matcher,omitempty"`
+	// Command is run with sh -c.
+	Command string `json:"command"`
+	// Timeout is the number of seconds the command may run; 0 means
+	// DefaultTimeout.
+	Timeout int `json:"timeout,omitempty"`
+
+	matcher *regexp.Regexp
+}
+
+// Config maps events to the hooks that run for them, in order.
+type Config map[Event][]Hook
+
+// Parse parses and validates a hooks.json file.
+func Parse(data []byte) (Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	for event, hooks := range config {
+		if !isEvent(event) {
+			return nil, fmt.Errorf("unknown event %q", event)
+		}
+		for i := range hooks {
+			h := &hooks[i]
+			if strings.TrimSpace(h.Command) == "" {
+				return nil, fmt.Errorf("%s hook %d: command is required", event, i)
+			}
+			if h.Timeout < 0 {
+				return nil, fmt.Errorf("%s hook %d: timeout must not be negative", event, i)
+			}
+			if h.Matcher != "" {
+				re, err := regexp.Compile("^(?:" + h.Matcher + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("%s hook %d: invalid matcher: %w", event, i, err)
+				}
+				h.matcher = re
+			}
+		}
+	}
+	return config, nil
+}
+
+func isEvent(event Event) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Files returns the hooks.json files that apply to a project, in the order
+// their hooks run: the user's files in ~/.config/percy and ~/.percy, then
+// .percy/hooks.json at the project root. The project root is the git root,
+// or the working directory outside a repository. Files that don't exist are
+// included; Load skips them.
+func Files(workingDir, gitRoot string) []string {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files,
+			filepath.Join(home, ".config", "percy", "hooks.json"),
+			filepath.Join(home, ".percy", "hooks.json"),
+		)
+	}
+	root := gitRoot
+	if root == "" {
+		root = workingDir
+	}
+	if root != "" {
+		files = append(files, filepath.Join(root, ".percy", "hooks.json"))
+	}
+	return files
+}
+
+// Load reads the hooks in files and combines them. Missing files are
+// skipped. Invalid files are skipped too and reported in the returned error,
+// alongside the hooks from the valid files.
+func Load(files []string) (Config, error) {
+	config := make(Config)
+	var errs []error
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c, err := Parse(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		for _, event := range Events {
+			config[event] = append(config[event], c[event]...)
+		}
+	}
+	for event, hooks := range config {
+		if len(hooks) == 0 {
+			delete(config, event)
+		}
+	}
+	return config, errors.Join(errs...)
+}
+
+// Input is the JSON sent to a hook command on stdin. Fields that don't apply
+// to the event are omitted.
+type Input struct {
+	Event          Event  `json:"event"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	Cwd            string `json:"cwd,omitempty"`
+	// ToolName and ToolInput are set for tool events.
+	ToolName  string          `json:"tool_name,omitempty"`
+	ToolInput json.RawMessage `json:"tool_input,omitempty"`
+	// ToolResult is the text of the tool's result, for post_tool_use.
+	ToolResult string `json:"tool_result,omitempty"`
+	ToolError  bool   `json:"tool_error,omitempty"`
+	// Prompt is the text of the user's message, for user_prompt_submit and
+	// conversation_start.
+	Prompt string `json:"prompt,omitempty"`
+	// Response is the text of the agent's last message, for turn_end.
+	Response string `json:"response,omitempty"`
+}
+
+// output is the JSON a hook command may print on stdout.
+type output struct {
+	// Decision is "block" to block the call.
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+	// ToolInput replaces the tool's input, for pre_tool_use.
+	ToolInput         json.RawMessage `json:"tool_input"`
+	AdditionalContext string          `json:"additional_context"`
+}
+
+// Result is the combined outcome of the hooks run for an event.
+type Result struct {
+	// Blocked reports whether a hook blocked the call, for the given Reason.
+	// Hooks after the blocking one don't run.
+	Blocked bool
+	Reason  string
+	// ToolInput is the rewritten tool input, or nil if no hook rewrote it.
+	ToolInput json.RawMessage
+	// Context is the context added by hooks, one hook's per line.
+	Context string
+}
+
+// Runner runs the hooks of a conversation.
+type Runner struct {
+	Config         Config
+	ConversationID string
+	// WorkingDir returns the directory hooks run in.
+	WorkingDir func() string
+	// Wrap, if set, changes each hook's command before it runs, for
+	// example to run it in a sandbox. The returned function is called once
+	// the command has exited.
+	Wrap   func(cmd *exec.Cmd) (release func(), err error)
+	Logger *slog.Logger
+}
+
+// Has reports whether any hooks are configured for event. It is safe to
+// call on a nil Runner.
+func (r *Runner) Has(event Event) bool {
+	return r != nil && len(r.Config[event]) > 0
+}
+
+// Run runs the hooks for in.Event that match in.ToolName, in order. Each
+// pre_tool_use hook sees the input as rewritten by the hooks before it. It is
+// safe to call on a nil Runner, which runs nothing.
+func (r *Runner) Run(ctx context.Context, in Input) Result {
+	var result Result
+	if !r.Has(in.Event) {
+		return result
+	}
+	in.ConversationID = r.ConversationID
+	if in.Cwd == "" && r.WorkingDir != nil {
+		in.Cwd = r.WorkingDir()
+	}
+	var contexts []string
+	for _, h := range r.Config[in.Event] {
+		if h.matcher != nil && !h.matcher.MatchString(in.ToolName) {
+			continue
+		}
+		out, err := r.run(ctx, h, in)
+		if err != nil {
+			r.logger().Warn("hook failed", "event", in.Event, "command", h.Command, "error", err)
+			continue
+		}
+		if out.AdditionalContext != "" {
+			contexts = append(contexts, out.AdditionalContext)
+		}
+		if out.Decision == "block" {
+			result.Blocked = true
+			result.Reason = out.Reason
+			if result.Reason == "" {
+				result.Reason = fmt.Sprintf("blocked by %s hook %q", in.Event, h.Command)
+			}
+			break
+		}
+		if in.Event == PreToolUse && len(out.ToolInput) > 0 {
+			in.ToolInput = out.ToolInput
+			result.ToolInput = out.ToolInput
+		}
+	}
+	result.Context = strings.Join(contexts, "\n")
+	return result
+}
+
+// run runs one hook command and interprets its exit status and output.
+func (r *Runner) run(ctx context.Context, h Hook, in Input) (output, error) {
+	var out output
+	stdin, err := json.Marshal(in)
+	if err != nil {
+		return out, err
+	}
+	timeout := DefaultTimeout
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Dir = in.Cwd
+	cmd.Env = append(os.Environ(), "PERCY_HOOK_EVENT="+string(in.Event))
+	if in.ConversationID != "" {
+		cmd.Env = append(cmd.Env, "PERCY_CONVERSATION_ID="+in.ConversationID)
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait forever for background processes holding the pipes open.
+	cmd.WaitDelay = time.Second
+	if r.Wrap != nil {
+		release, err := r.Wrap(cmd)
+		if err != nil {
+			return out, err
+		}
+		defer release()
+	}
+
+	start := time.Now()
+	err = cmd.Run()
+	r.logger().Debug("ran hook", "event", in.Event, "command", h.Command, "duration", time.Since(start), "error", err)
+	if ctx.Err() == context.DeadlineExceeded {
+		return out, fmt.Errorf("timed out after %s", timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == blockExitCode {
+		out.Decision = "block"
+		out.Reason = strings.TrimSpace(stderr.String())
+		if out.Reason == "" {
+			out.Reason = strings.TrimSpace(stdout.String())
+		}
+		return out, nil
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("%w: %s", err, msg)
+		}
+		return out, err
+	}
+
+	text := strings.TrimSpace(stdout.String())
+	if strings.HasPrefix(text, "{") {
+		if err := json.Unmarshal([]byte(text), &out); err != nil {
+			return out, fmt.Errorf("invalid output: %w", err)
+		}
+		if out.Decision != "" && out.Decision != "block" {
+			return output{}, fmt.Errorf("invalid decision %q", out.Decision)
+		}
+		return out, nil
+	}
+	out.AdditionalContext = text
+	return out, nil
+}
+
+func (r *Runner) logger() *slog.Logger {
+	if r.Logger != nil {
+		return r.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,143 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"valid", `{"pre_tool_use": [{"matcher": "patch|bash", "command": "true", "timeout": 5}], "turn_end": [{"command": "true"}]}`, ""},
+		{"unknown event", `{"pre_tool": [{"command": "true"}]}`, `unknown event "pre_tool"`},
+		{"no command", `{"turn_end": [{"command": " "}]}`, "command is required"},
+		{"bad matcher", `{"pre_tool_use": [{"matcher": "(", "command": "true"}]}`, "invalid matcher"},
+		{"negative timeout", `{"pre_tool_use": [{"command": "true", "timeout": -1}]}`, "timeout must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.json))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(home, ".config", "percy", "hooks.json"), `{"pre_tool_use": [{"command": "user"}]}`)
+	write(filepath.Join(home, ".percy", "hooks.json"), `{"pre_tool_use": [{"command": "broken"`)
+	write(filepath.Join(project, ".percy", "hooks.json"), `{"pre_tool_use": [{"command": "project"}], "turn_end": [{"command": "done"}]}`)
+
+	config, err := Load(Files(filepath.Join(project, "sub"), project))
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(".percy", "hooks.json")) {
+		t.Errorf("error = %v, want the broken file reported", err)
+	}
+	var commands []string
+	for _, h := range config[PreToolUse] {
+		commands = append(commands, h.Command)
+	}
+	if strings.Join(commands, ",") != "user,project" {
+		t.Errorf("pre_tool_use commands = %v, want user hooks before project hooks", commands)
+	}
+	if len(config[TurnEnd]) != 1 || len(config[PostToolUse]) != 0 {
+		t.Errorf("config = %+v", config)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	runner := func(t *testing.T, config string) *Runner {
+		t.Helper()
+		c, err := Parse([]byte(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Runner{Config: c, ConversationID: "c1", WorkingDir: func() string { return dir }}
+	}
+	input := json.RawMessage(`{"path":"vendor/x.go"}`)
+
+	t.Run("stdin", func(t *testing.T) {
+		r := runner(t, `{"pre_tool_use": [{"command": "cat > input.json; echo $PERCY_HOOK_EVENT $(pwd)"}]}`)
+		res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "patch", ToolInput: input})
+		if res.Context != "pre_tool_use "+dir {
+			t.Errorf("hook environment: %q", res.Context)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "input.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Input
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("hook got invalid JSON: %v\n%s", err, data)
+		}
+		if got.Event != PreToolUse || got.ConversationID != "c1" || got.Cwd != dir || got.ToolName != "patch" || string(got.ToolInput) != string(input) {
+			t.Errorf("hook got %+v", got)
+		}
+	})
+
+	t.Run("exit 2 blocks", func(t *testing.T) {
+		r := runner(t, `{"pre_tool_use": [
+			{"command": "echo checked"},
+			{"matcher": "patch", "command": "echo 'no writes to vendor/' >&2; exit 2"},
+			{"command": "echo unreachable"}]}`)
+		res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "patch", ToolInput: input})
+		if !res.Blocked || res.Reason != "no writes to vendor/" || res.Context != "checked" {
+			t.Errorf("result = %+v", res)
+		}
+		if res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "bash"}); res.Blocked {
+			t.Errorf("hook ran for a tool its matcher doesn't match: %+v", res)
+		}
+	})
+
+	t.Run("json output", func(t *testing.T) {
+		r := runner(t, `{"pre_tool_use": [
+			{"command": "echo '{\"tool_input\": {\"path\": \"x.go\"}, \"additional_context\": \"rewrote path\"}'"},
+			{"command": "grep -q '\"path\":\"x.go\"' && echo '{\"decision\": \"block\", \"reason\": \"saw rewrite\"}'"}]}`)
+		res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "patch", ToolInput: input})
+		if string(res.ToolInput) != `{"path": "x.go"}` || !res.Blocked || res.Reason != "saw rewrite" || res.Context != "rewrote path" {
+			t.Errorf("result = %+v", res)
+		}
+	})
+
+	t.Run("failures are ignored", func(t *testing.T) {
+		r := runner(t, `{"post_tool_use": [
+			{"command": "exit 1"},
+			{"command": "echo '{\"decision\": \"maybe\"}'"},
+			{"command": "sleep 5", "timeout": 1},
+			{"command": "echo ok"}]}`)
+		res := r.Run(context.Background(), Input{Event: PostToolUse, ToolName: "bash"})
+		if res.Blocked || res.Context != "ok" {
+			t.Errorf("result = %+v", res)
+		}
+	})
+
+	t.Run("nil runner", func(t *testing.T) {
+		var r *Runner
+		if r.Has(TurnEnd) || r.Run(context.Background(), Input{Event: TurnEnd}).Blocked {
+			t.Error("nil runner ran hooks")
+		}
+	})
+}
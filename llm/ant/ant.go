@@ -67,31 +67,25 @@ func ClaudeModelName(userName string) string {
 	}
 }
 
+// ModelName returns the Anthropic model name this service requests.
+func (s *Service) ModelName() string {
+	return cmp.Or(s.Model, DefaultModel)
+}
+
 // TokenContextWindow returns the maximum token context window size for this service
 func (s *Service) TokenContextWindow() int {
-	model := s.Model
-	if model == "" {
-		model = DefaultModel
-	}
-
-	switch model {
-	case Claude47Opus, Claude46Opus, Claude45Sonnet, Claude4Sonnet:
-		return 1000000
-	case Claude37Sonnet:
-		return 200000
-	case Claude45Haiku:
-		return 200000
-	case Claude45Opus:
-		return 200000
-	default:
-		// Default for unknown models
-		return 200000
+	if spec, ok := llm.LookupModel(s.ModelName()); ok && spec.ContextWindow > 0 {
+		return spec.ContextWindow
 	}
+	return 200000 // default for unknown models
 }
 
 // MaxImageDimension returns the maximum allowed image dimension for multi-image requests.
 // Anthropic enforces a 2000 pixel limit when multiple images are in a conversation.
 func (s *Service) MaxImageDimension() int {
+	if spec, ok := llm.LookupModel(s.ModelName()); ok && spec.MaxImageDimension > 0 {
+		return spec.MaxImageDimension
+	}
 	return 2000
 }
 
@@ -124,7 +118,8 @@ type content struct {
 	// is somewhat acceptable but hard to read.
 	Text      *string         `json:"text,omitempty"`
 	MediaType string          `json:"media_type,omitempty"` // for image
-	Source    json.RawMessage `json:"source,omitempty"`     // for image
+	Source    json.RawMessage `json:"source,omitempty"`     // for image or document
+	Title     string          `json:"title,omitempty"`      // for document
 
 	// for thinking
 	Thinking  *string `json:"thinking,omitempty"`
@@ -349,8 +344,13 @@ func fromLLMContent(c llm.Content) content {
 	// Set fields based on content type to avoid sending invalid fields
 	switch c.Type {
 	case llm.ContentTypeText:
-		// Images are represented as text with MediaType and Data
-		if c.MediaType != "" {
+		// Images and documents are represented as text with MediaType and Data
+		if c.IsDocument() {
+			d.Type = "document"
+			d.Source = json.RawMessage(fmt.Sprintf(`{"type":"base64","media_type":"%s","data":"%s"}`,
+				c.MediaType, c.Data))
+			d.Title = c.Filename
+		} else if c.MediaType != "" {
 			d.Type = "image"
 			d.Source = json.RawMessage(fmt.Sprintf(`{"type":"base64","media_type":"%s","data":"%s"}`,
 				c.MediaType, c.Data))
@@ -423,19 +423,37 @@ func fromLLMSystem(s llm.SystemContent) systemContent {
 }
 
 func (s *Service) fromLLMRequest(r *llm.Request) *request {
-	maxTokens := cmp.Or(s.MaxTokens, DefaultMaxTokens)
+	spec, _ := llm.LookupModel(s.ModelName())
+	maxTokens := spec.CapMaxTokens(cmp.Or(s.MaxTokens, DefaultMaxTokens))
 
+	messages := r.Messages
+	if !spec.SupportsDocuments(true) {
+		messages = llm.DocumentsAsText(messages)
+	}
 	req := &request{
-		Model:      cmp.Or(s.Model, DefaultModel),
-		Messages:   mapped(r.Messages, fromLLMMessage),
+		Model:      s.ModelName(),
+		Messages:   mapped(messages, fromLLMMessage),
 		MaxTokens:  maxTokens,
 		ToolChoice: fromLLMToolChoice(r.ToolChoice),
 		Tools:      mapped(r.Tools, fromLLMTool),
 		System:     mapped(r.System, fromLLMSystem),
 	}
 
-	// Enable extended thinking if a thinking level is set
-	if s.ThinkingLevel != llm.ThinkingLevelOff {
+	// Structured output is a forced call to a tool taking the response as
+	// its input; see toStructuredResponse. Thinking cannot be combined with
+	// a forced tool choice.
+	if rf := r.ResponseFormat; rf != nil {
+		req.Tools = append(req.Tools, &tool{
+			Name:        rf.Name,
+			Description: cmp.Or(rf.Description, "Respond with the result."),
+			InputSchema: rf.Schema,
+		})
+		req.ToolChoice = &toolChoice{Type: "tool", Name: rf.Name}
+		return req
+	}
+
+	// Enable extended thinking if a thinking level is set and the model supports it
+	if s.ThinkingLevel != llm.ThinkingLevelOff && spec.SupportsThinking() {
 		if useAdaptiveThinking(req.Model) {
 			req.Thinking = &thinking{Type: "adaptive"}
 			req.OutputConfig = &outputConfig{Effort: s.ThinkingLevel.ThinkingEffort()}
@@ -503,6 +521,18 @@ func toLLMResponse(r *response) *llm.Response {
 	}
 }
 
+// toStructuredResponse replaces the response format tool call in resp with
+// a text content holding its input, the structured response.
+func toStructuredResponse(resp *llm.Response, name string) {
+	for _, c := range resp.Content {
+		if c.Type == llm.ContentTypeToolUse && c.ToolName == name {
+			resp.Content = []llm.Content{{Type: llm.ContentTypeText, Text: string(c.ToolInput)}}
+			resp.StopReason = llm.StopReasonEndTurn
+			return
+		}
+	}
+}
+
 // Do sends a request to Anthropic.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
 	startTime := time.Now()
@@ -567,6 +597,9 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 
 			endTime := time.Now()
 			result := toLLMResponse(&apiResp)
+			if ir.ResponseFormat != nil {
+				toStructuredResponse(result, ir.ResponseFormat.Name)
+			}
 			result.StartTime = &startTime
 			result.EndTime = &endTime
 			return result, nil
@@ -593,6 +626,70 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	}
 }
 
+// countTokensRequest is the body of a count_tokens request: a Messages API
+// request without the sampling and output options.
+type countTokensRequest struct {
+	Model      string          `json:"model"`
+	System     []systemContent `json:"system,omitempty"`
+	Tools      []*tool         `json:"tools,omitempty"`
+	ToolChoice *toolChoice     `json:"tool_choice,omitempty"`
+	Thinking   *thinking       `json:"thinking,omitempty"`
+	Messages   []message       `json:"messages"`
+}
+
+var _ llm.TokenCounter = (*Service)(nil)
+
+// CountTokens counts the input tokens of ir with the count_tokens endpoint,
+// which is free and not rate limited with Messages requests.
+// See https://docs.anthropic.com/en/docs/build-with-claude/token-counting
+func (s *Service) CountTokens(ctx context.Context, ir *llm.Request) (int, error) {
+	url, ok := strings.CutSuffix(cmp.Or(s.URL, DefaultURL), "/messages")
+	if !ok {
+		return 0, fmt.Errorf("no count_tokens endpoint for %s", cmp.Or(s.URL, DefaultURL))
+	}
+	url += "/messages/count_tokens"
+	r := s.fromLLMRequest(ir)
+	payload, err := json.Marshal(countTokensRequest{
+		Model:      r.Model,
+		System:     r.System,
+		Tools:      r.Tools,
+		ToolChoice: r.ToolChoice,
+		Thinking:   r.Thinking,
+		Messages:   r.Messages,
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", s.APIKey)
+	req.Header.Set("Anthropic-Version", "2023-06-01")
+	req.Header.Set("anthropic-beta", "context-1m-2025-08-07")
+
+	resp, err := cmp.Or(s.HTTPC, http.DefaultClient).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("count_tokens: status %v: %s", resp.Status, buf)
+	}
+	var counted struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(buf, &counted); err != nil {
+		return 0, fmt.Errorf("count_tokens: %w", err)
+	}
+	return counted.InputTokens, nil
+}
+
 // For debugging only, Claude can definitely handle the full patch tool.
 // func (s *Service) UseSimplifiedPatch() bool {
 // 	return true
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/replay/replaytest"
 )
 
 func ptr(s string) *string { return &s }
@@ -657,77 +659,27 @@ func TestConfigDetails(t *testing.T) {
 }
 
 func TestDo(t *testing.T) {
-	// Create a mock HTTP client that returns a predefined response
-	mockResponse := `{
-		"id": "msg_123",
-		"type": "message",
-		"role": "assistant",
-		"model": "claude-sonnet-4-5-20250929",
-		"content": [
-			{
-				"type": "text",
-				"text": "Hello, world!"
-			}
-		],
-		"stop_reason": "end_turn",
-		"usage": {
-			"input_tokens": 100,
-			"output_tokens": 50,
-			"cost_usd": 0.01
-		}
-	}`
-
-	// Create a service with a mock HTTP client
-	client := &http.Client{
-		Transport: &mockHTTPTransport{responseBody: mockResponse, statusCode: 200},
-	}
-
+	rt, key := replaytest.Open(t, "testdata/tool_roundtrip.json", "ANTHROPIC_API_KEY")
 	s := &Service{
-		APIKey: "test-key",
-		HTTPC:  client,
+		APIKey: key,
+		HTTPC:  rt.Client(),
 	}
 
-	// Create a request
-	req := &llm.Request{
-		Messages: []llm.Message{
-			{
-				Role: llm.MessageRoleUser,
-				Content: []llm.Content{
-					{
-						Type: llm.ContentTypeText,
-						Text: "Hello, Claude!",
-					},
-				},
-			},
-		},
+	call, answer := replaytest.ToolRoundTrip(t, s)
+	if rt.Recording() {
+		return
 	}
-
-	// Call Do
-	resp, err := s.Do(context.Background(), req)
-	if err != nil {
-		t.Fatalf("Do() error = %v, want nil", err)
-	}
-
-	// Check the response
-	if resp == nil {
-		t.Fatalf("Do() response = nil, want not nil")
+	if call.ID != "msg_01QmdYNkSw8EXAMPLEd3bWq" {
+		t.Errorf("Do() response ID = %v, want %v", call.ID, "msg_01QmdYNkSw8EXAMPLEd3bWq")
 	}
-	if resp.ID != "msg_123" {
-		t.Errorf("Do() response ID = %v, want %v", resp.ID, "msg_123")
+	if call.Role != llm.MessageRoleAssistant {
+		t.Errorf("Do() response Role = %v, want %v", call.Role, llm.MessageRoleAssistant)
 	}
-	if resp.Role != llm.MessageRoleAssistant {
-		t.Errorf("Do() response Role = %v, want %v", resp.Role, llm.MessageRoleAssistant)
+	if call.Usage.InputTokens != 612 || call.Usage.OutputTokens != 54 {
+		t.Errorf("Do() first turn usage = %+v, want 612 in, 54 out", call.Usage)
 	}
-	if len(resp.Content) != 1 {
-		t.Errorf("Do() response Content length = %v, want %v", len(resp.Content), 1)
-	} else if resp.Content[0].Text != "Hello, world!" {
-		t.Errorf("Do() response Content[0].Text = %v, want %v", resp.Content[0].Text, "Hello, world!")
-	}
-	if resp.Usage.InputTokens != 100 {
-		t.Errorf("Do() response Usage.InputTokens = %v, want %v", resp.Usage.InputTokens, 100)
-	}
-	if resp.Usage.OutputTokens != 50 {
-		t.Errorf("Do() response Usage.OutputTokens = %v, want %v", resp.Usage.OutputTokens, 50)
+	if answer.Usage.CacheReadInputTokens != 0 || answer.Usage.OutputTokens != 17 {
+		t.Errorf("Do() second turn usage = %+v, want 17 out", answer.Usage)
 	}
 }
 
@@ -968,6 +920,34 @@ func TestFromLLMContent(t *testing.T) {
 	}
 }
 
+func TestFromLLMDocument(t *testing.T) {
+	doc := llm.Content{Type: llm.ContentTypeText, Text: "Document: report.pdf\n\nextracted", MediaType: llm.MediaTypePDF, Filename: "report.pdf", Data: "JVBERi0xLjQ="}
+	wantSource := `{"type":"base64","media_type":"application/pdf","data":"JVBERi0xLjQ="}`
+
+	got := fromLLMContent(doc)
+	if got.Type != "document" || string(got.Source) != wantSource || got.Title != "report.pdf" || got.Text != nil {
+		t.Errorf("fromLLMContent(document) = %+v", got)
+	}
+
+	result := fromLLMContent(llm.Content{
+		Type:       llm.ContentTypeToolResult,
+		ToolUseID:  "toolu_1",
+		ToolResult: []llm.Content{llm.StringContent("File: report.pdf"), doc},
+	})
+	if len(result.ToolResult) != 2 || result.ToolResult[1].Type != "document" || string(result.ToolResult[1].Source) != wantSource {
+		t.Errorf("tool result document = %+v", result.ToolResult)
+	}
+
+	// Models the catalog marks as lacking document support get the text.
+	off := false
+	llm.RegisterModels(llm.ModelSpec{Name: "claude-test-no-documents", Documents: &off})
+	s := &Service{Model: "claude-test-no-documents"}
+	req := s.fromLLMRequest(&llm.Request{Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{doc}}}})
+	if c := req.Messages[0].Content[0]; c.Type != "text" || c.Text == nil || *c.Text != doc.Text {
+		t.Errorf("fallback content = %+v, want the extracted text", c)
+	}
+}
+
 func TestInverted(t *testing.T) {
 	// Test normal case
 	m := map[string]int{
@@ -1260,3 +1240,79 @@ func TestAdaptiveThinking(t *testing.T) {
 		t.Error("output_config should be nil for enabled mode")
 	}
 }
+
+func TestCountTokens(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		io.WriteString(w, `{"input_tokens": 1234}`)
+	}))
+	defer server.Close()
+
+	s := &Service{APIKey: "test-key", URL: server.URL + "/v1/messages", Model: Claude45Sonnet}
+	n, err := s.CountTokens(context.Background(), &llm.Request{
+		System:   []llm.SystemContent{{Text: "Be brief."}},
+		Messages: []llm.Message{llm.UserStringMessage("Hello, Claude!")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1234 {
+		t.Errorf("CountTokens() = %d, want 1234", n)
+	}
+	if gotPath != "/v1/messages/count_tokens" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if _, ok := gotBody["max_tokens"]; ok || gotBody["model"] != Claude45Sonnet || gotBody["messages"] == nil {
+		t.Errorf("unexpected count_tokens body: %v", gotBody)
+	}
+
+	// Services are TokenCounters; llm.CountTokens falls back to an estimate
+	// when counting fails.
+	s.URL = server.URL + "/proxy"
+	if _, exact := llm.CountTokens(context.Background(), s, &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}}); exact {
+		t.Error("CountTokens() exact for a URL without a count_tokens endpoint")
+	}
+}
+
+func TestStructuredOutput(t *testing.T) {
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		io.WriteString(w, `{
+			"id": "msg_123",
+			"type": "message",
+			"role": "assistant",
+			"content": [{"type": "tool_use", "id": "toolu_1", "name": "answer", "input": {"value": 42}}],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 10, "output_tokens": 5}
+		}`)
+	}))
+	defer server.Close()
+
+	s := &Service{APIKey: "test-key", URL: server.URL, Model: Claude45Sonnet, ThinkingLevel: llm.ThinkingLevelHigh}
+	var out struct {
+		Value int `json:"value"`
+	}
+	err := llm.DoJSON(context.Background(), s, &llm.Request{
+		Messages: []llm.Message{llm.UserStringMessage("What is the answer?")},
+		ResponseFormat: &llm.ResponseFormat{
+			Name:   "answer",
+			Schema: llm.MustSchema(`{"type": "object", "properties": {"value": {"type": "integer"}}, "required": ["value"]}`),
+		},
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != 42 {
+		t.Errorf("value = %d, want 42", out.Value)
+	}
+	if choice, _ := gotBody["tool_choice"].(map[string]any); choice["type"] != "tool" || choice["name"] != "answer" {
+		t.Errorf("tool_choice = %v, want the answer tool", gotBody["tool_choice"])
+	}
+	if _, ok := gotBody["thinking"]; ok {
+		t.Error("thinking sent with a forced tool choice")
+	}
+}
@@ -0,0 +1,246 @@
+// Package bedrock provides Claude completions through Amazon Bedrock.
+//
+// Requests are built by the ant package and rewritten into the Bedrock
+// InvokeModel format by an HTTP transport, so Claude behaves the same on
+// Bedrock as it does on the Anthropic API.
+package bedrock
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/ant"
+)
+
+const (
+	// APIKeyEnv holds a Bedrock API key, used instead of IAM credentials if set.
+	APIKeyEnv = "AWS_BEARER_TOKEN_BEDROCK"
+
+	anthropicVersion = "bedrock-2023-05-31"
+	signingService   = "bedrock"
+)
+
+// Service provides Claude completions through Bedrock.
+// Fields should not be altered concurrently with calling any method on Service.
+type Service struct {
+	HTTPC         *http.Client      // defaults to http.DefaultClient if nil
+	Region        string            // defaults to $AWS_REGION or $AWS_DEFAULT_REGION
+	Endpoint      string            // runtime base URL; defaults to https://bedrock-runtime.<region>.amazonaws.com
+	Model         string            // Bedrock model or inference profile ID, e.g. "us.anthropic.claude-sonnet-4-5-20250929-v1:0"
+	APIKey        string            // Bedrock API key; defaults to $AWS_BEARER_TOKEN_BEDROCK
+	Credentials   *Credentials      // IAM credentials, used if there is no API key; defaults to EnvCredentials
+	MaxTokens     int               // defaults to ant.DefaultMaxTokens if zero
+	ThinkingLevel llm.ThinkingLevel // as for ant.Service
+}
+
+var _ llm.Service = (*Service)(nil)
+
+// modelVersionSuffix matches the "-v1:0" style version on Bedrock model IDs.
+var modelVersionSuffix = regexp.MustCompile(`-v\d+(:\d+)?$`)
+
+// AnthropicModelName returns the Anthropic model name for a Bedrock model ID,
+// e.g. "claude-sonnet-4-5-20250929" for "us.anthropic.claude-sonnet-4-5-20250929-v1:0".
+// IDs it does not recognize, such as inference profile ARNs, are returned unchanged.
+func AnthropicModelName(id string) string {
+	_, name, ok := strings.Cut(id, "anthropic.")
+	if !ok || strings.HasPrefix(id, "arn:") {
+		return id
+	}
+	return modelVersionSuffix.ReplaceAllString(name, "")
+}
+
+// ParseEndpoint interprets a custom model endpoint, which is either a region
+// name ("us-east-1") or a runtime URL. The region of a standard runtime URL
+// is taken from its host name.
+func ParseEndpoint(v string) (region, endpoint string) {
+	v = strings.TrimSpace(v)
+	if !strings.Contains(v, "://") {
+		return v, ""
+	}
+	endpoint = strings.TrimSuffix(v, "/")
+	if u, err := url.Parse(endpoint); err == nil {
+		if rest, ok := strings.CutPrefix(u.Hostname(), "bedrock-runtime."); ok {
+			region, _, _ = strings.Cut(rest, ".")
+		}
+	}
+	return region, endpoint
+}
+
+// ParseAPIKey interprets a custom model API key. "ACCESS_KEY_ID:SECRET_ACCESS_KEY"
+// (optionally followed by ":SESSION_TOKEN") is a set of IAM credentials;
+// anything else is a Bedrock API key (these are base64 and never contain ':').
+// An empty key leaves both unset, so credentials come from the environment.
+func ParseAPIKey(v string) (apiKey string, creds *Credentials) {
+	v = strings.TrimSpace(v)
+	parts := strings.SplitN(v, ":", 3)
+	if len(parts) == 1 {
+		return v, nil
+	}
+	creds = &Credentials{AccessKeyID: parts[0], SecretAccessKey: parts[1]}
+	if len(parts) == 3 {
+		creds.SessionToken = parts[2]
+	}
+	return "", creds
+}
+
+// ModelName returns the Anthropic model name, used for catalog lookups.
+func (s *Service) ModelName() string {
+	return AnthropicModelName(s.Model)
+}
+
+// TokenContextWindow returns the context window of the underlying Claude model.
+func (s *Service) TokenContextWindow() int {
+	return (&ant.Service{Model: s.ModelName()}).TokenContextWindow()
+}
+
+// MaxImageDimension returns the image limit of the underlying Claude model.
+func (s *Service) MaxImageDimension() int {
+	return (&ant.Service{Model: s.ModelName()}).MaxImageDimension()
+}
+
+// Do sends a request to Bedrock.
+func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	svc, err := s.anthropicService()
+	if err != nil {
+		return nil, err
+	}
+	return svc.Do(ctx, ir)
+}
+
+func (s *Service) region() string {
+	return cmp.Or(s.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+}
+
+func (s *Service) invokeURL(region string) string {
+	base := cmp.Or(s.Endpoint, "https://bedrock-runtime."+region+".amazonaws.com")
+	// Model IDs contain ':', which must reach Bedrock percent-encoded.
+	return strings.TrimSuffix(base, "/") + "/model/" + uriEncode(s.Model) + "/invoke"
+}
+
+// anthropicService returns an ant.Service that talks to Bedrock.
+func (s *Service) anthropicService() (*ant.Service, error) {
+	if s.Model == "" {
+		return nil, fmt.Errorf("bedrock: no model ID")
+	}
+	region := s.region()
+	if region == "" {
+		return nil, fmt.Errorf("bedrock: no region (set AWS_REGION)")
+	}
+	t := &transport{region: region, apiKey: cmp.Or(s.APIKey, os.Getenv(APIKeyEnv))}
+	if t.apiKey == "" {
+		if s.Credentials != nil {
+			t.creds = *s.Credentials
+		} else {
+			creds, err := EnvCredentials()
+			if err != nil {
+				return nil, err
+			}
+			t.creds = creds
+		}
+	}
+
+	httpc := *cmp.Or(s.HTTPC, http.DefaultClient)
+	t.base = cmp.Or(httpc.Transport, http.DefaultTransport)
+	httpc.Transport = t
+	return &ant.Service{
+		HTTPC:         &httpc,
+		URL:           s.invokeURL(region),
+		APIKey:        "bedrock", // replaced by the transport
+		Model:         s.ModelName(),
+		MaxTokens:     s.MaxTokens,
+		ThinkingLevel: s.ThinkingLevel,
+	}, nil
+}
+
+// ConfigDetails returns configuration information for logging
+func (s *Service) ConfigDetails() map[string]string {
+	region := s.region()
+	return map[string]string{
+		"url":             s.invokeURL(region),
+		"model":           s.Model,
+		"region":          region,
+		"has_api_key_set": fmt.Sprintf("%v", cmp.Or(s.APIKey, os.Getenv(APIKeyEnv)) != ""),
+	}
+}
+
+// transport rewrites Anthropic Messages API requests into Bedrock InvokeModel
+// requests and authenticates them.
+type transport struct {
+	base   http.RoundTripper
+	region string
+	apiKey string
+	creds  Credentials
+	now    func() time.Time // for tests
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	body, err := toInvokePayload(body, req.Header.Get("Anthropic-Beta"))
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	out.Header.Del("X-API-Key")
+	out.Header.Del("Anthropic-Version")
+	out.Header.Del("Anthropic-Beta")
+	out.Header.Set("Accept", "application/json")
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+
+	if t.apiKey != "" {
+		out.Header.Set("Authorization", "Bearer "+t.apiKey)
+	} else {
+		now := time.Now
+		if t.now != nil {
+			now = t.now
+		}
+		if err := signV4(out, body, t.creds, t.region, signingService, now()); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(out)
+}
+
+// toInvokePayload converts a Messages API body to the Bedrock format: the
+// model moves to the URL, the API version moves into the body, and beta
+// flags are sent as anthropic_beta instead of a header.
+func toInvokePayload(body []byte, betaHeader string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("bedrock: unexpected request body: %w", err)
+	}
+	delete(fields, "model")
+	delete(fields, "stream")
+	fields["anthropic_version"], _ = json.Marshal(anthropicVersion)
+	if betaHeader != "" {
+		var betas []string
+		for _, b := range strings.Split(betaHeader, ",") {
+			if b = strings.TrimSpace(b); b != "" {
+				betas = append(betas, b)
+			}
+		}
+		fields["anthropic_beta"], _ = json.Marshal(betas)
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,179 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// TestSignV4 checks the signer against the "get-vanilla" case from the AWS
+// Signature Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	if err := signV4(req, nil, creds, "us-east-1", "service", now); err != nil {
+		t.Fatal(err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAnthropicModelName(t *testing.T) {
+	for id, want := range map[string]string{
+		"us.anthropic.claude-sonnet-4-5-20250929-v1:0":                    "claude-sonnet-4-5-20250929",
+		"anthropic.claude-opus-4-6-v1":                                    "claude-opus-4-6",
+		"global.anthropic.claude-haiku-4-5-20251001-v1:0":                 "claude-haiku-4-5-20251001",
+		"arn:aws:bedrock:us-east-1:123:application-inference-profile/abc": "arn:aws:bedrock:us-east-1:123:application-inference-profile/abc",
+	} {
+		if got := AnthropicModelName(id); got != want {
+			t.Errorf("AnthropicModelName(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	if region, endpoint := ParseEndpoint("eu-west-1"); region != "eu-west-1" || endpoint != "" {
+		t.Errorf("ParseEndpoint(region) = %q, %q", region, endpoint)
+	}
+	if region, endpoint := ParseEndpoint("https://bedrock-runtime.us-west-2.amazonaws.com/"); region != "us-west-2" || endpoint != "https://bedrock-runtime.us-west-2.amazonaws.com" {
+		t.Errorf("ParseEndpoint(url) = %q, %q", region, endpoint)
+	}
+	if key, creds := ParseAPIKey("AKIAEXAMPLE:secret:token"); key != "" || creds == nil || creds.SessionToken != "token" {
+		t.Errorf("ParseAPIKey(iam) = %q, %+v", key, creds)
+	}
+	if key, creds := ParseAPIKey("ABSKQmVkcm9ja0FQSUtleQ=="); key != "ABSKQmVkcm9ja0FQSUtleQ==" || creds != nil {
+		t.Errorf("ParseAPIKey(api key) = %q, %+v", key, creds)
+	}
+}
+
+func TestReadSharedCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	data := "[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = s1\n\n[work]\naws_access_key_id=AKIDWORK\naws_secret_access_key=s2\naws_session_token=tok\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := readSharedCredentials(path, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds != (Credentials{AccessKeyID: "AKIDWORK", SecretAccessKey: "s2", SessionToken: "tok"}) {
+		t.Errorf("got %+v", creds)
+	}
+	if _, err := readSharedCredentials(path, "missing"); err == nil {
+		t.Error("expected an error for a missing profile")
+	}
+}
+
+// bedrockStandIn is a local Bedrock InvokeModel endpoint that records requests.
+type bedrockStandIn struct {
+	t        *testing.T
+	path     string
+	header   http.Header
+	payload  map[string]any
+	response string
+}
+
+func (b *bedrockStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.path = r.URL.EscapedPath()
+	b.header = r.Header.Clone()
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &b.payload); err != nil {
+		b.t.Errorf("bad request body: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, b.response)
+}
+
+const claudeResponse = `{
+	"id": "msg_bdrk_01",
+	"type": "message",
+	"role": "assistant",
+	"model": "claude-sonnet-4-5-20250929",
+	"content": [{"type": "text", "text": "Hello from Bedrock"}],
+	"stop_reason": "end_turn",
+	"usage": {"input_tokens": 12, "output_tokens": 4}
+}`
+
+func TestServiceSigV4(t *testing.T) {
+	standIn := &bedrockStandIn{t: t, response: claudeResponse}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	svc := &Service{
+		Endpoint:    server.URL,
+		Region:      "us-east-1",
+		Model:       "us.anthropic.claude-sonnet-4-5-20250929-v1:0",
+		Credentials: &Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"},
+	}
+	resp, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "Hello from Bedrock" || resp.StopReason != llm.StopReasonEndTurn {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.Usage.InputTokens != 12 || resp.Usage.OutputTokens != 4 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+
+	if want := "/model/us.anthropic.claude-sonnet-4-5-20250929-v1%3A0/invoke"; standIn.path != want {
+		t.Errorf("path = %s, want %s", standIn.path, want)
+	}
+	auth := standIn.header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/us-east-1/bedrock/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token") {
+		t.Errorf("unexpected Authorization header: %s", auth)
+	}
+	if standIn.header.Get("X-Amz-Security-Token") != "session" {
+		t.Error("session token not sent")
+	}
+	if standIn.header.Get("X-Api-Key") != "" || standIn.header.Get("Anthropic-Version") != "" {
+		t.Errorf("Anthropic API headers leaked: %v", standIn.header)
+	}
+
+	if _, ok := standIn.payload["model"]; ok {
+		t.Error("payload still contains model")
+	}
+	if standIn.payload["anthropic_version"] != anthropicVersion {
+		t.Errorf("anthropic_version = %v", standIn.payload["anthropic_version"])
+	}
+	if _, ok := standIn.payload["anthropic_beta"].([]any); !ok {
+		t.Errorf("anthropic_beta = %v, want the beta flags as a list", standIn.payload["anthropic_beta"])
+	}
+	if _, ok := standIn.payload["messages"].([]any); !ok {
+		t.Error("payload has no messages")
+	}
+}
+
+func TestServiceAPIKey(t *testing.T) {
+	standIn := &bedrockStandIn{t: t, response: claudeResponse}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	svc := &Service{Endpoint: server.URL, Region: "us-west-2", Model: "anthropic.claude-opus-4-6-v1", APIKey: "ABSKexample"}
+	if _, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := standIn.header.Get("Authorization"); got != "Bearer ABSKexample" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if svc.TokenContextWindow() != 1000000 {
+		t.Errorf("TokenContextWindow = %d, want the catalog value for claude-opus-4-6", svc.TokenContextWindow())
+	}
+}
@@ -0,0 +1,191 @@
+package llm
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ModelSpec describes a model's limits, capabilities and pricing.
+// Zero values mean unknown; callers fall back to provider defaults.
+type ModelSpec struct {
+	// Name is the model name sent to the provider and reported in usage.
+	Name string `json:"name"`
+	// Aliases are other names the provider reports for the same model,
+	// such as a dated snapshot or a different hosting route.
+	Aliases []string `json:"aliases,omitempty"`
+	// Provider is informational ("anthropic", "openai", "gemini", "fireworks").
+	Provider          string   `json:"provider,omitempty"`
+	ContextWindow     int      `json:"context_window,omitempty"`
+	MaxOutputTokens   int      `json:"max_output_tokens,omitempty"`
+	MaxImageDimension int      `json:"max_image_dimension,omitempty"`
+	Thinking          *bool    `json:"thinking,omitempty"`  // supports extended thinking / reasoning effort
+	Documents         *bool    `json:"documents,omitempty"` // reads PDF documents natively
+	Pricing           *Pricing `json:"pricing,omitempty"`
+}
+
+// Pricing is per-million-token pricing in USD.
+type Pricing struct {
+	InputPerMillion      float64 `json:"input"`
+	OutputPerMillion     float64 `json:"output"`
+	CacheReadPerMillion  float64 `json:"cache_read,omitempty"`  // 0 = same as input
+	CacheWritePerMillion float64 `json:"cache_write,omitempty"` // 0 = same as input
+}
+
+// SupportsThinking reports whether the spec says the model supports thinking.
+// Unknown is treated as supported, so configured thinking levels are kept.
+func (s ModelSpec) SupportsThinking() bool {
+	return s.Thinking == nil || *s.Thinking
+}
+
+// SupportsDocuments reports whether the spec says the model reads PDF
+// documents natively. Unknown uses the provider's default, def.
+func (s ModelSpec) SupportsDocuments(def bool) bool {
+	if s.Documents == nil {
+		return def
+	}
+	return *s.Documents
+}
+
+// CapMaxTokens limits a requested output token count to the model's maximum.
+func (s ModelSpec) CapMaxTokens(n int) int {
+	if s.MaxOutputTokens > 0 && n > s.MaxOutputTokens {
+		return s.MaxOutputTokens
+	}
+	return n
+}
+
+// merge overlays the known fields of o onto s.
+func (s ModelSpec) merge(o ModelSpec) ModelSpec {
+	if o.Provider != "" {
+		s.Provider = o.Provider
+	}
+	if o.ContextWindow > 0 {
+		s.ContextWindow = o.ContextWindow
+	}
+	if o.MaxOutputTokens > 0 {
+		s.MaxOutputTokens = o.MaxOutputTokens
+	}
+	if o.MaxImageDimension > 0 {
+		s.MaxImageDimension = o.MaxImageDimension
+	}
+	if o.Thinking != nil {
+		s.Thinking = o.Thinking
+	}
+	if o.Documents != nil {
+		s.Documents = o.Documents
+	}
+	if o.Pricing != nil {
+		s.Pricing = o.Pricing
+	}
+	for _, a := range o.Aliases {
+		if !slices.Contains(s.Aliases, a) {
+			s.Aliases = append(s.Aliases, a)
+		}
+	}
+	return s
+}
+
+// defaultCatalogJSON is the built-in model catalog. Prices are from public
+// provider pricing pages.
+//
+//go:embed catalog.json
+var defaultCatalogJSON []byte
+
+// catalog is the process-wide model catalog: the built-in entries plus any
+// registered overrides.
+var catalog = struct {
+	sync.RWMutex
+	specs []ModelSpec
+	index map[string]int // name or alias -> specs index
+}{index: map[string]int{}}
+
+func init() {
+	specs, err := ParseCatalog(defaultCatalogJSON)
+	if err != nil {
+		panic(err)
+	}
+	RegisterModels(specs...)
+}
+
+// ParseCatalog parses a catalog file: {"models": [ModelSpec, ...]}.
+func ParseCatalog(data []byte) ([]ModelSpec, error) {
+	var f struct {
+		Models []ModelSpec `json:"models"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse model catalog: %w", err)
+	}
+	for i, s := range f.Models {
+		if s.Name == "" {
+			return nil, fmt.Errorf("parse model catalog: entry %d has no name", i)
+		}
+	}
+	return f.Models, nil
+}
+
+// RegisterModels adds specs to the catalog. A spec whose name or alias is
+// already known is merged into the existing entry: only the fields it sets
+// are overridden.
+func RegisterModels(specs ...ModelSpec) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	for _, s := range specs {
+		i, ok := catalog.index[s.Name]
+		for _, a := range s.Aliases {
+			if ok {
+				break
+			}
+			i, ok = catalog.index[a]
+		}
+		if ok {
+			merged := catalog.specs[i].merge(s)
+			if s.Name != merged.Name && !slices.Contains(merged.Aliases, s.Name) {
+				merged.Aliases = append(merged.Aliases, s.Name)
+			}
+			catalog.specs[i] = merged
+		} else {
+			i = len(catalog.specs)
+			catalog.specs = append(catalog.specs, s)
+		}
+		catalog.index[s.Name] = i
+		for _, a := range catalog.specs[i].Aliases {
+			catalog.index[a] = i
+		}
+	}
+}
+
+// LookupModel returns the catalog entry for a model name or alias.
+func LookupModel(name string) (ModelSpec, bool) {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	i, ok := catalog.index[name]
+	if !ok {
+		return ModelSpec{}, false
+	}
+	return catalog.specs[i], true
+}
+
+// CatalogModels returns all catalog entries.
+func CatalogModels() []ModelSpec {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	return append([]ModelSpec(nil), catalog.specs...)
+}
+
+// ModelNamer is implemented by services that know the provider model name
+// they request, so callers can look up their catalog entry.
+type ModelNamer interface {
+	ModelName() string
+}
+
+// ServiceModelSpec returns the catalog entry for the model svc requests.
+func ServiceModelSpec(svc Service) (ModelSpec, bool) {
+	n, ok := svc.(ModelNamer)
+	if !ok {
+		return ModelSpec{}, false
+	}
+	return LookupModel(n.ModelName())
+}
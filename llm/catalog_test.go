@@ -0,0 +1,75 @@
+package llm
+
+import "testing"
+
+func TestDefaultCatalog(t *testing.T) {
+	for _, spec := range CatalogModels() {
+		if spec.Name == "" {
+			t.Errorf("catalog entry without a name: %+v", spec)
+		}
+	}
+
+	spec, ok := LookupModel("claude-opus-4-6")
+	if !ok {
+		t.Fatal("claude-opus-4-6 not in catalog")
+	}
+	if spec.ContextWindow == 0 || spec.Pricing == nil || !spec.SupportsThinking() {
+		t.Errorf("unexpected claude-opus-4-6 spec: %+v", spec)
+	}
+
+	// Aliases resolve to the same entry.
+	alias, ok := LookupModel("accounts/fireworks/models/qwen3-coder-480b-a35b-fp8")
+	if !ok || alias.Name != "accounts/fireworks/models/qwen3-coder-480b-a35b-instruct" {
+		t.Errorf("alias lookup = %+v, %v", alias, ok)
+	}
+	if alias.SupportsThinking() {
+		t.Error("qwen3 coder should not support thinking")
+	}
+}
+
+func TestRegisterModelsMerges(t *testing.T) {
+	RegisterModels(ModelSpec{
+		Name:            "test-catalog-model",
+		Aliases:         []string{"test-catalog-model-2026"},
+		ContextWindow:   64000,
+		MaxOutputTokens: 8000,
+		Pricing:         &Pricing{InputPerMillion: 1, OutputPerMillion: 2},
+	})
+
+	// An override registered under an alias only replaces the fields it sets
+	// and adds its name as an alias.
+	off := false
+	RegisterModels(ModelSpec{Name: "test-catalog-model-2026", ContextWindow: 128000, Thinking: &off})
+	RegisterModels(ModelSpec{Name: "test-catalog-model-latest", Aliases: []string{"test-catalog-model"}})
+
+	for _, name := range []string{"test-catalog-model", "test-catalog-model-2026", "test-catalog-model-latest"} {
+		spec, ok := LookupModel(name)
+		if !ok {
+			t.Fatalf("%s not found", name)
+		}
+		if spec.Name != "test-catalog-model" || spec.ContextWindow != 128000 || spec.MaxOutputTokens != 8000 || spec.SupportsThinking() {
+			t.Errorf("%s: unexpected spec %+v", name, spec)
+		}
+		if got := spec.CapMaxTokens(32000); got != 8000 {
+			t.Errorf("CapMaxTokens(32000) = %d, want 8000", got)
+		}
+	}
+
+	// Pricing is found through the alias used in usage reports.
+	if got := EstimateCostUSD("test-catalog-model-latest", 1_000_000, 1_000_000, 0, 0); got != 3 {
+		t.Errorf("EstimateCostUSD = %v, want 3", got)
+	}
+}
+
+func TestParseCatalog(t *testing.T) {
+	specs, err := ParseCatalog([]byte(`{"models": [{"name": "a", "context_window": 1000, "pricing": {"input": 1, "output": 2}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 1 || specs[0].ContextWindow != 1000 || specs[0].Pricing.OutputPerMillion != 2 {
+		t.Errorf("unexpected specs: %+v", specs)
+	}
+	if _, err := ParseCatalog([]byte(`{"models": [{"context_window": 1000}]}`)); err == nil {
+		t.Error("expected an error for an entry without a name")
+	}
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/tgruben-circuit/percy/llm/pdftext"
+)
+
+// MediaTypePDF is the media type of PDF document content.
+const MediaTypePDF = "application/pdf"
+
+// DocumentContent returns content for a PDF document. Like images, documents
+// are text content with MediaType and Data (base64). Text holds the text
+// extracted from the document, which is sent instead of the document to
+// models that cannot read PDFs themselves. ctx bounds the text extraction.
+func DocumentContent(ctx context.Context, filename string, data []byte) Content {
+	text, err := pdftext.Extract(ctx, data)
+	switch {
+	case errors.Is(err, pdftext.ErrNoText):
+		text = fmt.Sprintf("Document: %s\n\n(no text could be extracted; the document may be scanned)", filename)
+	case err != nil:
+		text = fmt.Sprintf("Document: %s\n\n(text extraction failed: %v)", filename, err)
+	default:
+		text = fmt.Sprintf("Document: %s\n\n%s", filename, text)
+	}
+	return Content{
+		Type:      ContentTypeText,
+		Text:      text,
+		MediaType: MediaTypePDF,
+		Filename:  filename,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}
+}
+
+// IsDocument reports whether c is document content.
+func (c Content) IsDocument() bool {
+	return c.Type == ContentTypeText && c.MediaType == MediaTypePDF && c.Data != ""
+}
+
+// DocumentsAsText returns msgs with document content, including documents in
+// tool results, replaced by its extracted text. It is used for models without
+// native document support. msgs is not modified.
+func DocumentsAsText(msgs []Message) []Message {
+	out := make([]Message, len(msgs))
+	for i, m := range msgs {
+		m.Content = documentsAsText(m.Content)
+		out[i] = m
+	}
+	return out
+}
+
+func documentsAsText(contents []Content) []Content {
+	out := make([]Content, len(contents))
+	for i, c := range contents {
+		switch {
+		case c.IsDocument():
+			c = Content{ID: c.ID, Type: ContentTypeText, Text: c.Text, Cache: c.Cache}
+		case len(c.ToolResult) > 0:
+			c.ToolResult = documentsAsText(c.ToolResult)
+		}
+		out[i] = c
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+const testPDF = "%PDF-1.4\n1 0 obj << /Length 27 >>\nstream\nBT (Hello from a PDF) Tj ET\nendstream\nendobj\n%%EOF\n"
+
+func TestDocumentContent(t *testing.T) {
+	c := DocumentContent(context.Background(), "hello.pdf", []byte(testPDF))
+	if !c.IsDocument() || c.Type != ContentTypeText || c.MediaType != MediaTypePDF || c.Filename != "hello.pdf" {
+		t.Fatalf("unexpected document content: %+v", c)
+	}
+	if data, _ := base64.StdEncoding.DecodeString(c.Data); string(data) != testPDF {
+		t.Errorf("Data does not round-trip: %q", data)
+	}
+	if !strings.HasPrefix(c.Text, "Document: hello.pdf") || !strings.Contains(c.Text, "Hello from a PDF") {
+		t.Errorf("Text = %q, want the extracted text", c.Text)
+	}
+
+	image := Content{Type: ContentTypeText, MediaType: "image/png", Data: "iVBORw0KGgo="}
+	if image.IsDocument() {
+		t.Error("image content reported as a document")
+	}
+}
+
+func TestDocumentsAsText(t *testing.T) {
+	doc := DocumentContent(context.Background(), "hello.pdf", []byte(testPDF))
+	msgs := []Message{
+		{Role: MessageRoleUser, Content: []Content{StringContent("summarize this"), doc}},
+		{Role: MessageRoleUser, Content: []Content{{
+			Type:       ContentTypeToolResult,
+			ToolUseID:  "toolu_1",
+			ToolResult: []Content{StringContent("File: hello.pdf"), doc},
+		}}},
+	}
+
+	got := DocumentsAsText(msgs)
+	if c := got[0].Content[1]; c.IsDocument() || c.MediaType != "" || c.Data != "" || c.Text != doc.Text {
+		t.Errorf("document not replaced by its text: %+v", c)
+	}
+	if c := got[1].Content[0].ToolResult[1]; c.IsDocument() || c.Text != doc.Text {
+		t.Errorf("tool result document not replaced by its text: %+v", c)
+	}
+	if got[0].Content[0].Text != "summarize this" || got[1].Content[0].ToolUseID != "toolu_1" {
+		t.Errorf("other content changed: %+v", got)
+	}
+
+	// The input is not modified.
+	if !msgs[0].Content[1].IsDocument() || !msgs[1].Content[0].ToolResult[1].IsDocument() {
+		t.Error("DocumentsAsText modified its input")
+	}
+}
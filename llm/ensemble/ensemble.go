@@ -0,0 +1,301 @@
+// Package ensemble combines several models into one llm.Service.
+//
+// An ensemble sends each request to all of its members concurrently, then
+// asks a judge model either to pick the best response or to merge the
+// answers into one. Picking works for any response, including tool calls,
+// so an ensemble can drive the agent loop; merging applies only to text
+// answers, such as plans.
+package ensemble
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// Mode is how an ensemble combines its members' responses.
+type Mode string
+
+const (
+	// ModeBest returns the member response the judge picks.
+	ModeBest Mode = "best"
+	// ModeMerge returns the judge's merge of the members' text answers.
+	// Responses with tool calls cannot be merged; they fall back to ModeBest.
+	ModeMerge Mode = "merge"
+)
+
+// transcriptBytes is how much of the conversation the judge is shown.
+const transcriptBytes = 20000
+
+// Member is a model in an ensemble.
+type Member struct {
+	Name    string // model ID, shown to the judge and logged
+	Service llm.Service
+}
+
+// Service fans requests out to its members and combines the responses.
+// Fields should not be altered concurrently with calling any method on Service.
+type Service struct {
+	Members []Member
+	Judge   llm.Service // defaults to the first member
+	Mode    Mode        // defaults to ModeBest
+
+	// Resolve, if set, returns the members and judge to use in place of
+	// Members and Judge. It is called for each request, so that members
+	// chosen by selector follow the models available at the time.
+	Resolve func() ([]Member, llm.Service, error)
+}
+
+var _ llm.Service = (*Service)(nil)
+
+// current returns the members and judge to use. If Resolve fails, Members
+// and Judge are returned with the error.
+func (s *Service) current() ([]Member, llm.Service, error) {
+	if s.Resolve == nil {
+		return s.Members, s.Judge, nil
+	}
+	members, judge, err := s.Resolve()
+	if err != nil {
+		return s.Members, s.Judge, err
+	}
+	return members, judge, nil
+}
+
+// candidate is a member's response to a request.
+type candidate struct {
+	name string
+	resp *llm.Response
+}
+
+// Do sends req to every member and combines their responses. Members that
+// fail are left out; Do fails only if all of them do. The returned usage
+// is that of the response returned, so that it reflects the context used,
+// but its cost is the total cost of the members and the judge.
+func (s *Service) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	members, judgeSvc, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, errors.New("ensemble has no members")
+	}
+
+	resps := make([]*llm.Response, len(members))
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Go(func() {
+			r := *req // members must not see each other's changes
+			resps[i], errs[i] = m.Service.Do(ctx, &r)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("%s: %w", m.Name, errs[i])
+			}
+		})
+	}
+	wg.Wait()
+
+	var candidates []candidate
+	var cost float64
+	for i, resp := range resps {
+		if resp == nil {
+			slog.WarnContext(ctx, "ensemble member failed", "model", members[i].Name, "error", errs[i])
+			continue
+		}
+		cost += responseCost(resp)
+		candidates = append(candidates, candidate{name: members[i].Name, resp: resp})
+	}
+	if len(candidates) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := candidates[0].resp
+	if len(candidates) > 1 {
+		judge := &meteredService{Service: cmp.Or(judgeSvc, members[0].Service)}
+		var judged *llm.Response
+		var err error
+		if s.Mode == ModeMerge && !slices.ContainsFunc(candidates, hasToolUse) {
+			judged, err = merge(ctx, judge, req, candidates)
+		} else {
+			judged, err = pick(ctx, judge, req, candidates)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "ensemble judge failed; using the first response", "error", err)
+		} else {
+			result = judged
+		}
+		cost += judge.cost
+	}
+
+	out := *result
+	out.Usage.CostUSD = cost
+	return &out, nil
+}
+
+// meteredService adds up the cost of the responses of a service.
+type meteredService struct {
+	llm.Service
+	cost float64
+}
+
+func (m *meteredService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	resp, err := m.Service.Do(ctx, req)
+	if resp != nil {
+		m.cost += responseCost(resp)
+	}
+	return resp, err
+}
+
+// pickFormat is the structured output format of the judge's choice.
+var pickFormat = &llm.ResponseFormat{
+	Name:        "pick_response",
+	Description: "Record which candidate response is best.",
+	Schema: llm.MustSchema(`{
+		"type": "object",
+		"properties": {
+			"best": {"type": "integer", "minimum": 1, "description": "number of the best candidate"},
+			"reason": {"type": "string"}
+		},
+		"required": ["best", "reason"]
+	}`),
+}
+
+// pick asks the judge which candidate is best and returns it.
+func pick(ctx context.Context, judge llm.Service, req *llm.Request, candidates []candidate) (*llm.Response, error) {
+	prompt := judgePrompt(req, candidates) + "\n\nWhich candidate is the best next response? Prefer correct, complete and safe responses; for tool calls, prefer the call most likely to make progress."
+	var choice struct {
+		Best   int    `json:"best"`
+		Reason string `json:"reason"`
+	}
+	err := llm.DoJSON(ctx, judge, &llm.Request{
+		System:         []llm.SystemContent{{Text: "You judge candidate responses that different AI models gave to the same conversation."}},
+		Messages:       []llm.Message{llm.UserStringMessage(prompt)},
+		ResponseFormat: pickFormat,
+	}, &choice)
+	if err != nil {
+		return nil, err
+	}
+	if choice.Best < 1 || choice.Best > len(candidates) {
+		return nil, fmt.Errorf("judge picked candidate %d of %d", choice.Best, len(candidates))
+	}
+	c := candidates[choice.Best-1]
+	slog.InfoContext(ctx, "ensemble picked response", "model", c.name, "reason", choice.Reason)
+	return c.resp, nil
+}
+
+// merge asks the judge to combine the candidates' answers into one.
+func merge(ctx context.Context, judge llm.Service, req *llm.Request, candidates []candidate) (*llm.Response, error) {
+	prompt := judgePrompt(req, candidates) + "\n\nWrite the single best response to the conversation, combining the strengths of the candidates and correcting their mistakes. Respond with only that response, addressed to the user as the candidates were; do not mention the candidates."
+	resp, err := judge.Do(ctx, &llm.Request{
+		System:   []llm.SystemContent{{Text: "You merge candidate responses that different AI models gave to the same conversation."}},
+		Messages: []llm.Message{llm.UserStringMessage(prompt)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(llm.ResponseText(resp)) == "" {
+		return nil, errors.New("judge returned an empty merge")
+	}
+	merged := *resp
+	merged.Content = []llm.Content{llm.StringContent(llm.ResponseText(resp))}
+	merged.StopReason = llm.StopReasonEndTurn
+	return &merged, nil
+}
+
+// judgePrompt shows the judge the conversation and the candidates.
+func judgePrompt(req *llm.Request, candidates []candidate) string {
+	var sb strings.Builder
+	sb.WriteString("<conversation>\n")
+	sb.WriteString(llm.FormatTranscript(req.Messages, transcriptBytes))
+	sb.WriteString("\n</conversation>\n")
+	for i, c := range candidates {
+		fmt.Fprintf(&sb, "\n<candidate number=\"%d\">\n%s\n</candidate>\n", i+1, formatResponse(c.resp))
+	}
+	return sb.String()
+}
+
+// formatResponse renders the text and tool calls of resp.
+func formatResponse(resp *llm.Response) string {
+	var parts []string
+	for _, c := range resp.Content {
+		switch c.Type {
+		case llm.ContentTypeText:
+			if text := strings.TrimSpace(c.Text); text != "" {
+				parts = append(parts, text)
+			}
+		case llm.ContentTypeToolUse:
+			parts = append(parts, fmt.Sprintf("[tool call %s] %s", c.ToolName, c.ToolInput))
+		}
+	}
+	if len(parts) == 0 {
+		return "(empty response)"
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func hasToolUse(c candidate) bool {
+	return slices.ContainsFunc(c.resp.Content, func(c llm.Content) bool { return c.Type == llm.ContentTypeToolUse })
+}
+
+// responseCost returns the cost of resp, estimating it from the model
+// catalog if the provider did not report it.
+func responseCost(resp *llm.Response) float64 {
+	u := resp.Usage
+	u.Model = cmp.Or(u.Model, resp.Model)
+	u.FillCostEstimate()
+	return u.CostUSD
+}
+
+// TokenContextWindow returns the smallest context window of the members.
+func (s *Service) TokenContextWindow() int {
+	members, _, _ := s.current()
+	window := 0
+	for _, m := range members {
+		if n := m.Service.TokenContextWindow(); window == 0 || n < window {
+			window = n
+		}
+	}
+	return window
+}
+
+// MaxImageDimension returns the smallest image dimension limit of the
+// members, or 0 if none of them has one.
+func (s *Service) MaxImageDimension() int {
+	members, _, _ := s.current()
+	dim := 0
+	for _, m := range members {
+		if n := m.Service.MaxImageDimension(); n > 0 && (dim == 0 || n < dim) {
+			dim = n
+		}
+	}
+	return dim
+}
+
+// UseSimplifiedPatch reports whether any member uses the simplified patch
+// schema; all members get the same tools.
+func (s *Service) UseSimplifiedPatch() bool {
+	members, _, _ := s.current()
+	return slices.ContainsFunc(members, func(m Member) bool { return llm.UseSimplifiedPatch(m.Service) })
+}
+
+// ConfigDetails returns configuration information for logging.
+func (s *Service) ConfigDetails() map[string]string {
+	members, _, _ := s.current()
+	names := make([]string, len(members))
+	for i, m := range members {
+		names[i] = m.Name
+	}
+	return map[string]string{
+		"members": strings.Join(names, ","),
+		"mode":    string(cmp.Or(s.Mode, ModeBest)),
+	}
+}
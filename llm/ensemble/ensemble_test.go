@@ -0,0 +1,166 @@
+package ensemble
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// fakeService returns a fixed response, or err, and records its requests.
+type fakeService struct {
+	mu       sync.Mutex
+	resp     *llm.Response
+	err      error
+	window   int
+	requests []*llm.Request
+}
+
+func (f *fakeService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.resp, nil
+}
+
+func (f *fakeService) TokenContextWindow() int { return f.window }
+func (f *fakeService) MaxImageDimension() int  { return 0 }
+
+func textResponse(text string, cost float64) *llm.Response {
+	return &llm.Response{
+		Role:       llm.MessageRoleAssistant,
+		Content:    []llm.Content{llm.StringContent(text)},
+		StopReason: llm.StopReasonEndTurn,
+		Usage:      llm.Usage{InputTokens: 100, OutputTokens: 10, CostUSD: cost},
+	}
+}
+
+var request = &llm.Request{Messages: []llm.Message{llm.UserStringMessage("How should I structure the cache?")}}
+
+func TestPickBest(t *testing.T) {
+	a := &fakeService{resp: textResponse("Use an LRU.", 0.01)}
+	b := &fakeService{resp: &llm.Response{
+		Role:       llm.MessageRoleAssistant,
+		Content:    []llm.Content{{Type: llm.ContentTypeToolUse, ID: "t1", ToolName: "bash", ToolInput: json.RawMessage(`{"command":"ls"}`)}},
+		StopReason: llm.StopReasonToolUse,
+		Usage:      llm.Usage{CostUSD: 0.02},
+	}}
+	judge := &fakeService{resp: textResponse(`{"best": 2, "reason": "look first"}`, 0.005)}
+	s := &Service{Members: []Member{{"a", a}, {"b", b}}, Judge: judge}
+
+	resp, err := s.Do(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StopReason != llm.StopReasonToolUse || resp.Content[0].ToolName != "bash" {
+		t.Errorf("expected b's tool call, got %+v", resp.Content)
+	}
+	if math.Abs(resp.Usage.CostUSD-0.035) > 1e-9 {
+		t.Errorf("cost = %v, want the total 0.035", resp.Usage.CostUSD)
+	}
+	if b.resp.Usage.CostUSD != 0.02 {
+		t.Error("member response modified")
+	}
+	prompt := judge.requests[0].Messages[0].Content[0].Text
+	for _, want := range []string{"How should I structure the cache?", "Use an LRU.", `[tool call bash] {"command":"ls"}`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("judge prompt missing %q", want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := &fakeService{resp: textResponse("Use an LRU.", 0.01)}
+	b := &fakeService{resp: textResponse("Bound it by bytes.", 0.01)}
+	judge := &fakeService{resp: textResponse("Use an LRU bounded by bytes.", 0.01)}
+	s := &Service{Members: []Member{{"a", a}, {"b", b}}, Judge: judge, Mode: ModeMerge}
+
+	resp, err := s.Do(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if llm.ResponseText(resp) != "Use an LRU bounded by bytes." {
+		t.Errorf("merged = %q", llm.ResponseText(resp))
+	}
+	if math.Abs(resp.Usage.CostUSD-0.03) > 1e-9 {
+		t.Errorf("cost = %v, want 0.03", resp.Usage.CostUSD)
+	}
+}
+
+func TestMemberFailures(t *testing.T) {
+	a := &fakeService{err: errors.New("overloaded")}
+	b := &fakeService{resp: textResponse("Use an LRU.", 0.01)}
+	judge := &fakeService{err: errors.New("not needed")}
+	s := &Service{Members: []Member{{"a", a}, {"b", b}}, Judge: judge}
+
+	resp, err := s.Do(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if llm.ResponseText(resp) != "Use an LRU." || len(judge.requests) != 0 {
+		t.Errorf("expected b's response without judging, got %q", llm.ResponseText(resp))
+	}
+
+	b.err = errors.New("timeout")
+	if _, err := s.Do(context.Background(), request); err == nil || !strings.Contains(err.Error(), "a: overloaded") || !strings.Contains(err.Error(), "b: timeout") {
+		t.Errorf("err = %v, want both member errors", err)
+	}
+}
+
+func TestJudgeFailureFallsBack(t *testing.T) {
+	a := &fakeService{resp: textResponse("first", 0)}
+	b := &fakeService{resp: textResponse("second", 0)}
+	judge := &fakeService{resp: textResponse(`{"best": 5, "reason": "?"}`, 0)}
+	s := &Service{Members: []Member{{"a", a}, {"b", b}}, Judge: judge}
+
+	resp, err := s.Do(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if llm.ResponseText(resp) != "first" {
+		t.Errorf("expected the first response, got %q", llm.ResponseText(resp))
+	}
+}
+
+func TestLimits(t *testing.T) {
+	s := &Service{Members: []Member{{"a", &fakeService{window: 200000}}, {"b", &fakeService{window: 128000}}}}
+	if n := s.TokenContextWindow(); n != 128000 {
+		t.Errorf("TokenContextWindow() = %d, want the smallest", n)
+	}
+}
+
+func TestResolvePerRequest(t *testing.T) {
+	a := &fakeService{resp: textResponse("a", 0), window: 100}
+	b := &fakeService{resp: textResponse("b", 0), window: 200}
+	c := &fakeService{resp: textResponse("c", 0), window: 50}
+	members := []Member{{"a", a}, {"b", b}}
+	s := &Service{Resolve: func() ([]Member, llm.Service, error) { return members, a, nil }}
+
+	if _, err := s.Do(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	members = []Member{{"a", a}, {"c", c}}
+	if _, err := s.Do(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.requests) != 1 || len(c.requests) != 1 {
+		t.Errorf("b got %d requests and c %d, want one each", len(b.requests), len(c.requests))
+	}
+	if n := s.TokenContextWindow(); n != 50 {
+		t.Errorf("TokenContextWindow() = %d, want 50", n)
+	}
+
+	unavailable := errors.New("members unavailable")
+	s.Resolve = func() ([]Member, llm.Service, error) { return nil, nil, unavailable }
+	if _, err := s.Do(context.Background(), request); !errors.Is(err, unavailable) {
+		t.Errorf("err = %v, want %v", err, unavailable)
+	}
+}
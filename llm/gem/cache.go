@@ -0,0 +1,137 @@
+package gem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/gem/gemini"
+)
+
+// Gemini context caches store a request prefix server-side, and later
+// requests reference the cache by name instead of resending the prefix.
+// The system prompt and tools stay the same for a whole conversation, so
+// they are cached; messages are sent as usual.
+//
+// Caches are created only when the caller asks for caching (llm.Tool.Cache
+// or llm.SystemContent.Cache, as for Anthropic) and the prefix is large
+// enough for Gemini to accept. Cache storage is billed by the hour, so
+// caches have a short TTL and are recreated when they expire.
+const (
+	// minContextCacheTokens is the estimated prefix size below which no
+	// cache is created. Gemini's minimum is 1024 to 4096 tokens, depending
+	// on the model.
+	minContextCacheTokens = 4096
+	contextCacheTTL       = 10 * time.Minute
+)
+
+type contextCacheEntry struct {
+	name    string // "cachedContents/{name}"; empty if creation failed
+	expires time.Time
+}
+
+// contextCaches is shared by all Services, since Services are cheap and
+// often created per request.
+var contextCaches = struct {
+	sync.Mutex
+	entries map[string]contextCacheEntry
+}{entries: map[string]contextCacheEntry{}}
+
+// cacheRequested reports whether the caller marked any part of the request
+// prefix for caching.
+func cacheRequested(ir *llm.Request) bool {
+	for _, s := range ir.System {
+		if s.Cache {
+			return true
+		}
+	}
+	for _, t := range ir.Tools {
+		if t.Cache {
+			return true
+		}
+	}
+	return false
+}
+
+// applyContextCache moves the system instruction and tools of req into a
+// context cache, creating the cache if needed. It returns the cache key, for
+// forgetContextCache, and the number of tokens written to a newly created
+// cache. If no cache can be used, req is left unchanged.
+func (s *Service) applyContextCache(ctx context.Context, model gemini.Model, ir *llm.Request, req *gemini.Request) (key string, written uint64) {
+	if s.DisableContextCache || !cacheRequested(ir) {
+		return "", 0
+	}
+	prefix, err := json.Marshal(struct {
+		System *gemini.Content `json:"system"`
+		Tools  []gemini.Tool   `json:"tools"`
+	}{req.SystemInstruction, req.Tools})
+	if err != nil || len(prefix)/4 < minContextCacheTokens {
+		return "", 0
+	}
+	// Caches belong to an API key's project, so the key is part of the cache key.
+	sum := sha256.Sum256(append([]byte(model.Endpoint+"\x00"+model.Model+"\x00"+model.APIKey+"\x00"), prefix...))
+	key = hex.EncodeToString(sum[:])
+
+	now := time.Now()
+	contextCaches.Lock()
+	entry, ok := contextCaches.entries[key]
+	contextCaches.Unlock()
+	if ok && entry.expires.After(now.Add(time.Minute)) {
+		if entry.name != "" {
+			useContextCache(req, entry.name)
+		}
+		return key, 0
+	}
+
+	cc, err := model.CreateCachedContent(ctx, &gemini.CachedContent{
+		Model:             model.Model,
+		SystemInstruction: req.SystemInstruction,
+		Tools:             req.Tools,
+		TTL:               fmt.Sprintf("%ds", int(contextCacheTTL.Seconds())),
+	})
+	entry = contextCacheEntry{expires: now.Add(contextCacheTTL)}
+	if err != nil {
+		// Don't retry until the TTL passes; the request goes uncached.
+		slog.WarnContext(ctx, "gemini_context_cache_create_failed", "model", model.Model, "error", err)
+	} else {
+		entry.name = cc.Name
+		if t, err := time.Parse(time.RFC3339, cc.ExpireTime); err == nil {
+			entry.expires = t
+		}
+		if cc.UsageMetadata != nil {
+			written = uint64(cc.UsageMetadata.TotalTokenCount)
+		}
+		useContextCache(req, cc.Name)
+	}
+
+	contextCaches.Lock()
+	for k, e := range contextCaches.entries {
+		if e.expires.Before(now) {
+			delete(contextCaches.entries, k)
+		}
+	}
+	contextCaches.entries[key] = entry
+	contextCaches.Unlock()
+	return key, written
+}
+
+// useContextCache makes req reference a cache holding its system
+// instruction and tools, which Gemini then requires req to omit.
+func useContextCache(req *gemini.Request, name string) {
+	req.CachedContent = name
+	req.SystemInstruction = nil
+	req.Tools = nil
+}
+
+// forgetContextCache drops a cache that the API no longer accepts.
+func forgetContextCache(key string) {
+	contextCaches.Lock()
+	delete(contextCaches.entries, key)
+	contextCaches.Unlock()
+}
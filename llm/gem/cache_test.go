@@ -0,0 +1,143 @@
+package gem
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// cacheStandIn serves the Gemini caching and generateContent endpoints.
+type cacheStandIn struct {
+	t            *testing.T
+	creates      atomic.Int32
+	rejectCached atomic.Bool
+	lastRequest  map[string]any
+}
+
+func (c *cacheStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		c.t.Errorf("bad request body: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/cachedContents":
+		c.creates.Add(1)
+		if payload["model"] != "models/gemini-2.5-flash" || payload["systemInstruction"] == nil || payload["ttl"] != "600s" {
+			c.t.Errorf("unexpected cache request: %s", body)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":          "cachedContents/abc123",
+			"expireTime":    time.Now().Add(10 * time.Minute).Format(time.RFC3339),
+			"usageMetadata": map[string]any{"totalTokenCount": 5000},
+		})
+	case strings.HasSuffix(r.URL.Path, ":generateContent"):
+		c.lastRequest = payload
+		cached := payload["cachedContent"] != nil
+		if cached && c.rejectCached.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"error": {"code": 403, "message": "CachedContent not found (or permission denied)"}}`)
+			return
+		}
+		usage := map[string]any{"promptTokenCount": 5100, "candidatesTokenCount": 7}
+		if cached {
+			usage["cachedContentTokenCount"] = 5000
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates":    []any{map[string]any{"content": map[string]any{"role": "model", "parts": []any{map[string]any{"text": "ok"}}}}},
+			"usageMetadata": usage,
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestContextCache(t *testing.T) {
+	standIn := &cacheStandIn{t: t}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	svc := &Service{URL: server.URL, APIKey: "context-cache-test", Model: "gemini-2.5-flash"}
+	req := &llm.Request{
+		System:   []llm.SystemContent{{Text: strings.Repeat("You are a careful coding agent. ", 1000), Cache: true}},
+		Messages: []llm.Message{llm.UserStringMessage("hi")},
+	}
+
+	resp, err := svc.Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if standIn.lastRequest["cachedContent"] != "cachedContents/abc123" || standIn.lastRequest["systemInstruction"] != nil {
+		t.Errorf("request did not use the cache: %v", standIn.lastRequest)
+	}
+	// The cache was written for this request, so its tokens are not also
+	// counted as read.
+	want := llm.Usage{InputTokens: 100, CacheReadInputTokens: 0, CacheCreationInputTokens: 5000, OutputTokens: 7}
+	if resp.Usage.InputTokens != want.InputTokens || resp.Usage.CacheReadInputTokens != want.CacheReadInputTokens ||
+		resp.Usage.CacheCreationInputTokens != want.CacheCreationInputTokens || resp.Usage.OutputTokens != want.OutputTokens {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+
+	// The cache is reused.
+	resp, err = svc.Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := standIn.creates.Load(); n != 1 || resp.Usage.CacheCreationInputTokens != 0 || resp.Usage.CacheReadInputTokens != 5000 {
+		t.Errorf("created %d caches (usage %+v), want 1 reused cache read for 5000 tokens", n, resp.Usage)
+	}
+
+	// A cache the API no longer accepts is dropped and the full request sent.
+	standIn.rejectCached.Store(true)
+	if _, err := svc.Do(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if standIn.lastRequest["cachedContent"] != nil || standIn.lastRequest["systemInstruction"] == nil {
+		t.Errorf("fallback request still references the cache: %v", standIn.lastRequest)
+	}
+	standIn.rejectCached.Store(false)
+	if _, err := svc.Do(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if n := standIn.creates.Load(); n != 2 {
+		t.Errorf("created %d caches, want a new cache after the rejection", n)
+	}
+}
+
+func TestContextCacheNotRequested(t *testing.T) {
+	standIn := &cacheStandIn{t: t}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	long := strings.Repeat("You are a careful coding agent. ", 1000)
+	for _, tt := range []struct {
+		name string
+		svc  *Service
+		req  *llm.Request
+	}{
+		{"not marked for caching", &Service{URL: server.URL, APIKey: "k", Model: "gemini-2.5-flash"},
+			&llm.Request{System: []llm.SystemContent{{Text: long}}, Messages: []llm.Message{llm.UserStringMessage("hi")}}},
+		{"prefix too small", &Service{URL: server.URL, APIKey: "k", Model: "gemini-2.5-flash"},
+			&llm.Request{System: []llm.SystemContent{{Text: "Be brief.", Cache: true}}, Messages: []llm.Message{llm.UserStringMessage("hi")}}},
+		{"disabled", &Service{URL: server.URL, APIKey: "k", Model: "gemini-2.5-flash", DisableContextCache: true},
+			&llm.Request{System: []llm.SystemContent{{Text: long, Cache: true}}, Messages: []llm.Message{llm.UserStringMessage("hi")}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.svc.Do(context.Background(), tt.req); err != nil {
+				t.Fatal(err)
+			}
+			if standIn.creates.Load() != 0 || standIn.lastRequest["cachedContent"] != nil {
+				t.Errorf("unexpected context cache use: %v", standIn.lastRequest)
+			}
+		})
+	}
+}
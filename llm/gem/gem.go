@@ -27,6 +27,10 @@ type Service struct {
 	URL    string       // Gemini API URL, uses the gemini package default if empty
 	APIKey string       // must be non-empty
 	Model  string       // defaults to DefaultModel if empty
+
+	// DisableContextCache turns off context caches for cache-marked
+	// requests, for endpoints without the Gemini API's caching API.
+	DisableContextCache bool
 }
 
 var _ llm.Service = (*Service)(nil)
@@ -147,6 +151,10 @@ func convertJSONSchemaToGeminiSchema(schemaJSON map[string]any) gemini.Schema {
 // buildGeminiRequest converts Sketch's llm.Request to Gemini's request format
 func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error) {
 	gemReq := &gemini.Request{}
+	// Models without native document support get the extracted text,
+	// which document content carries in Text.
+	spec, _ := llm.LookupModel(s.ModelName())
+	documents := spec.SupportsDocuments(true)
 
 	// Add system instruction if provided
 	if len(req.System) > 0 {
@@ -192,6 +200,12 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		for _, c := range msg.Content {
 			switch c.Type {
 			case llm.ContentTypeText, llm.ContentTypeThinking, llm.ContentTypeRedactedThinking:
+				if c.IsDocument() && documents {
+					content.Parts = append(content.Parts, gemini.Part{
+						InlineData: &gemini.Blob{MimeType: c.MediaType, Data: c.Data},
+					})
+					continue
+				}
 				// Simple text content
 				content.Parts = append(content.Parts, gemini.Part{
 					Text: c.Text,
@@ -233,7 +247,8 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 				}
 
 				// Handle tool results: Gemini only supports string results
-				// Combine all text content into a single string
+				// Combine all text content into a single string; documents
+				// contribute their extracted text
 				var resultText string
 				if len(c.ToolResult) > 0 {
 					// Collect all text from content objects
@@ -301,6 +316,19 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		}
 	}
 
+	// Structured output
+	if rf := req.ResponseFormat; rf != nil {
+		var schemaJSON map[string]any
+		if err := json.Unmarshal(rf.Schema, &schemaJSON); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response format %s schema: %w", rf.Name, err)
+		}
+		schema := convertJSONSchemaToGeminiSchema(schemaJSON)
+		gemReq.GenerationConfig = &gemini.GenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   &schema,
+		}
+	}
+
 	return gemReq, nil
 }
 
@@ -400,6 +428,16 @@ func ensureToolIDs(contents []llm.Content) {
 }
 
 func calculateUsage(req *gemini.Request, res *gemini.Response) llm.Usage {
+	// Use the reported counts when the API provides them.
+	if res != nil && res.UsageMetadata != nil && res.UsageMetadata.PromptTokenCount > 0 {
+		m := res.UsageMetadata
+		return llm.Usage{
+			InputTokens:          uint64(m.PromptTokenCount - m.CachedContentTokenCount),
+			CacheReadInputTokens: uint64(m.CachedContentTokenCount),
+			OutputTokens:         uint64(m.CandidatesTokenCount + m.ThoughtsTokenCount),
+		}
+	}
+
 	// Very rough estimation of token counts
 	var inputTokens uint64
 	var outputTokens uint64
@@ -456,35 +494,24 @@ func calculateUsage(req *gemini.Request, res *gemini.Response) llm.Usage {
 	}
 }
 
+// ModelName returns the Gemini model name this service requests.
+func (s *Service) ModelName() string {
+	return cmp.Or(s.Model, DefaultModel)
+}
+
 // TokenContextWindow returns the maximum token context window size for this service
 func (s *Service) TokenContextWindow() int {
-	model := s.Model
-	if model == "" {
-		model = DefaultModel
-	}
-
-	// Gemini models generally have large context windows
-	switch model {
-	case "gemini-3-pro-preview", "gemini-3-flash-preview":
-		return 1000000 // 1M tokens for Gemini 3
-	case "gemini-2.5-pro", "gemini-2.5-flash":
-		return 1000000 // 1M tokens for Gemini 2.5
-	case "gemini-2.0-flash-exp", "gemini-2.0-flash":
-		return 1000000 // 1M tokens for Gemini 2.0 Flash
-	case "gemini-1.5-pro", "gemini-1.5-pro-latest":
-		return 2000000 // 2M tokens for Gemini 1.5 Pro
-	case "gemini-1.5-flash", "gemini-1.5-flash-latest":
-		return 1000000 // 1M tokens for Gemini 1.5 Flash
-	default:
-		// Default for unknown models
-		return 1000000
+	if spec, ok := llm.LookupModel(s.ModelName()); ok && spec.ContextWindow > 0 {
+		return spec.ContextWindow
 	}
+	return 1000000 // Gemini models generally have large context windows
 }
 
-// MaxImageDimension returns the maximum allowed image dimension.
-// TODO: determine actual Gemini image dimension limits
+// MaxImageDimension returns the maximum allowed image dimension from the
+// model catalog, or 0 if there is no known limit.
 func (s *Service) MaxImageDimension() int {
-	return 0 // No known limit
+	spec, _ := llm.LookupModel(s.ModelName())
+	return spec.MaxImageDimension
 }
 
 // Do sends a request to Gemini.
@@ -550,6 +577,11 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		HTTPC:    cmp.Or(s.HTTPC, http.DefaultClient),
 	}
 
+	// Send the stable prefix through a context cache when the caller asked
+	// for caching. If the API rejects the cache, the full request is sent.
+	full := *gemReq
+	cacheKey, cacheWritten := s.applyContextCache(ctx, model, ir, gemReq)
+
 	// Send the request to Gemini with retry logic
 	startTime := time.Now()
 	endTime := startTime // Initialize endTime
@@ -571,6 +603,14 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			break
 		}
 
+		if gemReq.CachedContent != "" && strings.Contains(strings.ToLower(gemAPIErr.Error()), "cachedcontent") {
+			// The cache was deleted or expired early.
+			slog.WarnContext(ctx, "gemini_context_cache_rejected", "cached_content", gemReq.CachedContent, "error", gemAPIErr.Error())
+			forgetContextCache(cacheKey)
+			gemReq = &full
+			continue
+		}
+
 		if attempts == len(backoff) {
 			// We've exhausted all retry attempts
 			return nil, fmt.Errorf("gemini: API error after %d attempts: %w", attempts, gemAPIErr)
@@ -595,6 +635,10 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	ensureToolIDs(content)
 
 	usage := calculateUsage(gemReq, gemRes)
+	// The tokens of a cache created for this request are also reported as
+	// read from it; count them once, as written.
+	usage.CacheCreationInputTokens = cacheWritten
+	usage.CacheReadInputTokens -= min(usage.CacheReadInputTokens, cacheWritten)
 	usage.CostUSD = llm.CostUSDFromResponse(gemRes.Header())
 
 	stopReason := llm.StopReasonEndTurn
@@ -24,8 +24,32 @@ type Request struct {
 
 // https://ai.google.dev/api/generate-content#response-body
 type Response struct {
-	Candidates []Candidate `json:"candidates"`
-	headers    http.Header // captured HTTP response headers
+	Candidates    []Candidate    `json:"candidates"`
+	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+	headers       http.Header    // captured HTTP response headers
+}
+
+// https://ai.google.dev/api/generate-content#UsageMetadata
+type UsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"` // includes cached tokens
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount,omitempty"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount,omitempty"`
+	TotalTokenCount         int `json:"totalTokenCount,omitempty"`
+}
+
+// CachedContent is a context cache: a stored request prefix that later
+// requests reference by name instead of resending.
+// https://ai.google.dev/api/caching#CachedContent
+type CachedContent struct {
+	Name              string         `json:"name,omitempty"` // format: "cachedContents/{name}", set by the server
+	Model             string         `json:"model"`          // format: "models/{model}"
+	SystemInstruction *Content       `json:"systemInstruction,omitempty"`
+	Tools             []Tool         `json:"tools,omitempty"`
+	Contents          []Content      `json:"contents,omitempty"`
+	TTL               string         `json:"ttl,omitempty"`        // e.g. "3600s"
+	ExpireTime        string         `json:"expireTime,omitempty"` // RFC 3339, set by the server
+	UsageMetadata     *UsageMetadata `json:"usageMetadata,omitempty"`
 }
 
 // Header returns the HTTP response headers.
@@ -53,10 +77,16 @@ type Part struct {
 	// ThoughtSignature is required for Gemini 3 models when using function calling.
 	// It must be passed back exactly as received when sending the conversation history.
 	ThoughtSignature string `json:"thoughtSignature,omitempty"`
-	// TODO inlineData
+	InlineData       *Blob  `json:"inlineData,omitempty"`
 	// TODO fileData
 }
 
+// Blob is inline media data, such as a PDF document.
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64
+}
+
 type FunctionCall struct {
 	Name string         `json:"name"`
 	Args map[string]any `json:"args"`
@@ -148,33 +178,55 @@ type Model struct {
 	Endpoint string       // if empty, DefaultEndpoint is used
 }
 
-func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, error) {
-	reqBytes, err := json.Marshal(req)
+// CreateCachedContent creates a context cache for cc.
+func (m Model) CreateCachedContent(ctx context.Context, cc *CachedContent) (*CachedContent, error) {
+	body, _, err := m.post(ctx, fmt.Sprintf("%s/cachedContents?key=%s", m.endpoint(), m.APIKey), cc)
 	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
+		return nil, fmt.Errorf("CreateCachedContent: %w", err)
+	}
+	var res CachedContent
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("CreateCachedContent: unmarshaling response: %w, %s", err, string(body))
 	}
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s:generateContent?key=%s", m.endpoint(), m.Model, m.APIKey), bytes.NewReader(reqBytes))
+	return &res, nil
+}
+
+// post sends a JSON request and returns the body of a successful response.
+func (m Model) post(ctx context.Context, url string, v any) ([]byte, http.Header, error) {
+	reqBytes, err := json.Marshal(v)
 	if err != nil {
-		return nil, fmt.Errorf("creating HTTP request: %w", err)
+		return nil, nil, fmt.Errorf("marshaling request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating HTTP request: %w", err)
 	}
 	httpReq.Header.Add("Content-Type", "application/json")
 	httpResp, err := m.httpc().Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("GenerateContent: do: %w", err)
+		return nil, nil, fmt.Errorf("do: %w", err)
 	}
 	defer httpResp.Body.Close()
 	body, err := io.ReadAll(httpResp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("GenerateContent: reading response body: %w", err)
+		return nil, nil, fmt.Errorf("reading response body: %w", err)
 	}
 	if httpResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GenerateContent: HTTP status: %d, %s", httpResp.StatusCode, string(body))
+		return nil, nil, fmt.Errorf("HTTP status: %d, %s", httpResp.StatusCode, string(body))
+	}
+	return body, httpResp.Header, nil
+}
+
+func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, error) {
+	body, header, err := m.post(ctx, fmt.Sprintf("%s/%s:generateContent?key=%s", m.endpoint(), m.Model, m.APIKey), req)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateContent: %w", err)
 	}
 	var res Response
 	if err := json.Unmarshal(body, &res); err != nil {
 		return nil, fmt.Errorf("GenerateContent: unmarshaling response: %w, %s", err, string(body))
 	}
-	res.headers = httpResp.Header
+	res.headers = header
 	return &res, nil
 }
 
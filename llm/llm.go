@@ -75,6 +75,8 @@ type Request struct {
 	ToolChoice *ToolChoice
 	Tools      []*Tool
 	System     []SystemContent
+	// ResponseFormat, if set, asks for a JSON response; see DoJSON.
+	ResponseFormat *ResponseFormat
 }
 
 // Message represents a message in the conversation.
@@ -165,8 +167,10 @@ type Content struct {
 	Type ContentType
 	Text string
 
-	// Media type for image content
+	// Media type for image and document content
 	MediaType string
+	// File name for document content
+	Filename string
 
 	// for thinking
 	Thinking  string
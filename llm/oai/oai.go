@@ -39,13 +39,15 @@ const (
 )
 
 type Model struct {
-	UserName           string // provided by the user to identify this model (e.g. "gpt4.1")
-	ModelName          string // provided to the service provide to specify which model to use (e.g. "gpt-4.1-2025-04-14")
-	URL                string
-	APIKeyEnv          string // environment variable name for the API key
-	IsReasoningModel   bool   // whether this model is a reasoning model (e.g. O3, O4-mini)
-	UseSimplifiedPatch bool   // whether to use the simplified patch input schema; defaults to false
-	UseMaxTokens       bool   // use max_tokens instead of max_completion_tokens (for Ollama and other compatible APIs)
+	UserName            string // provided by the user to identify this model (e.g. "gpt4.1")
+	ModelName           string // provided to the service provide to specify which model to use (e.g. "gpt-4.1-2025-04-14")
+	URL                 string
+	APIKeyEnv           string // environment variable name for the API key
+	IsReasoningModel    bool   // whether this model is a reasoning model (e.g. O3, O4-mini)
+	UseSimplifiedPatch  bool   // whether to use the simplified patch input schema; defaults to false
+	UseMaxTokens        bool   // use max_tokens instead of max_completion_tokens (for Ollama and other compatible APIs)
+	NoParallelToolCalls bool   // ask for at most one tool call per response (for servers that cannot parse several)
+	ContextWindow       int    // context window reported by the server; overrides the model catalog if set
 }
 
 var (
@@ -451,6 +453,8 @@ var (
 func fromLLMContent(c llm.Content) (string, []openai.ToolCall) {
 	switch c.Type {
 	case llm.ContentTypeText:
+		// The Chat Completions client has no file parts, so documents are
+		// sent as the text extracted from them, which is in Text.
 		return c.Text, nil
 	case llm.ContentTypeToolUse:
 		// For OpenAI, tool use is sent as a null content with tool_calls in the message
@@ -699,11 +703,12 @@ func (s *Service) toLLMUsage(au openai.Usage, headers http.Header) llm.Usage {
 		inc = uint64(au.PromptTokensDetails.CachedTokens)
 	}
 	out := uint64(au.CompletionTokens)
+	// OpenAI caches prompt prefixes automatically and reports the cached
+	// part of the input; InputTokens counts only the uncached part.
 	u := llm.Usage{
-		InputTokens:              in,
-		CacheReadInputTokens:     inc,
-		CacheCreationInputTokens: in,
-		OutputTokens:             out,
+		InputTokens:          in - min(inc, in),
+		CacheReadInputTokens: inc,
+		OutputTokens:         out,
 	}
 	u.CostUSD = llm.CostUSDFromResponse(headers)
 	return u
@@ -758,43 +763,27 @@ func toStopReason(reason string) llm.StopReason {
 	return llm.StopReasonStopSequence // Default
 }
 
+// ModelName returns the model name this service requests.
+func (s *Service) ModelName() string {
+	return cmp.Or(s.Model, DefaultModel).ModelName
+}
+
 // TokenContextWindow returns the maximum token context window size for this service
 func (s *Service) TokenContextWindow() int {
-	// TODO: move TokenContextWindow information to Model struct
-
-	model := cmp.Or(s.Model, DefaultModel)
-
-	// OpenAI models generally have 128k context windows
-	// Some newer models have larger windows, but 128k is a safe default
-	switch model.ModelName {
-	case "gpt-4.1-2025-04-14", "gpt-4.1-mini-2025-04-14", "gpt-4.1-nano-2025-04-14":
-		return 200000 // 200k for newer GPT-4.1 models
-	case "gpt-4o-2024-08-06", "gpt-4o-mini-2024-07-18":
-		return 128000 // 128k for GPT-4o models
-	case "o3-2025-04-16", "o3-mini-2025-04-16":
-		return 200000 // 200k for O3 models
-	case "accounts/fireworks/models/qwen3-coder-480b-a35b-instruct":
-		return 256000 // 256k native context for Qwen3-Coder
-	case "glm", "zai-glm45-fireworks":
-		return 128000
-	case "qwen", "qwen3-coder-cerebras", "qwen3-coder-fireworks":
-		return 256000 // 256k native context for Qwen3-Coder
-	case "accounts/fireworks/models/kimi-k2-instruct-0905":
-		return 128000
-	case "gpt-oss-20b", "gpt-oss-120b":
-		return 128000
-	case "gpt-5.1", "gpt-5.1-mini", "gpt-5.1-nano":
-		return 256000
-	default:
-		// Default for unknown models
-		return 128000
+	if s.Model.ContextWindow > 0 {
+		return s.Model.ContextWindow
 	}
+	if spec, ok := llm.LookupModel(s.ModelName()); ok && spec.ContextWindow > 0 {
+		return spec.ContextWindow
+	}
+	return 128000 // OpenAI-compatible models generally have at least 128k context windows
 }
 
-// MaxImageDimension returns the maximum allowed image dimension.
-// TODO: determine actual OpenAI image dimension limits
+// MaxImageDimension returns the maximum allowed image dimension from the
+// model catalog, or 0 if there is no known limit.
 func (s *Service) MaxImageDimension() int {
-	return 0 // No known limit
+	spec, _ := llm.LookupModel(s.ModelName())
+	return spec.MaxImageDimension
 }
 
 // Do sends a request to OpenAI using the go-openai package.
@@ -836,18 +825,36 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	}
 
 	// Create the OpenAI request
-	maxTok := cmp.Or(s.MaxTokens, DefaultMaxTokens)
+	spec, _ := llm.LookupModel(model.ModelName)
+	maxTok := spec.CapMaxTokens(cmp.Or(s.MaxTokens, DefaultMaxTokens))
 	req := openai.ChatCompletionRequest{
 		Model:      model.ModelName,
 		Messages:   allMessages,
 		Tools:      tools,
 		ToolChoice: fromLLMToolChoice(ir.ToolChoice), // TODO: make fromLLMToolChoice return an error when a perfect translation is not possible
 	}
+	if model.NoParallelToolCalls && len(tools) > 0 {
+		req.ParallelToolCalls = false
+	}
 	if model.UseMaxTokens {
 		req.MaxTokens = maxTok
 	} else {
 		req.MaxCompletionTokens = maxTok
 	}
+	if rf := ir.ResponseFormat; rf != nil {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:        rf.Name,
+				Description: rf.Description,
+				Schema:      rf.Schema,
+				// Strict mode requires every property to be required and
+				// additional properties to be forbidden; llm.DoJSON
+				// validates the response instead.
+				Strict: false,
+			},
+		}
+	}
 	// Construct the full URL for logging and debugging
 	fullURL := baseURL + "/chat/completions"
 
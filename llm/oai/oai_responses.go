@@ -42,12 +42,26 @@ type responsesRequest struct {
 	ToolChoice      any                  `json:"tool_choice,omitempty"`
 	MaxOutputTokens int                  `json:"max_output_tokens,omitempty"`
 	Reasoning       *responsesReasoning  `json:"reasoning,omitempty"`
+	Text            *responsesText       `json:"text,omitempty"`
 }
 
 type responsesReasoning struct {
 	Effort string `json:"effort,omitempty"` // "low", "medium", "high"
 }
 
+// responsesText configures the text output; it is only set for structured output.
+type responsesText struct {
+	Format responsesTextFormat `json:"format"`
+}
+
+type responsesTextFormat struct {
+	Type        string          `json:"type"` // "json_schema"
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	Strict      bool            `json:"strict"`
+}
+
 type responsesInputItem struct {
 	Type      string             `json:"type"`                // "message", "function_call", "function_call_output"
 	Role      string             `json:"role,omitempty"`      // for messages: "user", "assistant"
@@ -55,12 +69,14 @@ type responsesInputItem struct {
 	CallID    string             `json:"call_id,omitempty"`   // for function_call and function_call_output
 	Name      string             `json:"name,omitempty"`      // for function_call
 	Arguments string             `json:"arguments,omitempty"` // for function_call
-	Output    string             `json:"output,omitempty"`    // for function_call_output
+	Output    any                `json:"output,omitempty"`    // for function_call_output: a string, or []responsesContent with documents
 }
 
 type responsesContent struct {
-	Type string `json:"type"` // "input_text", "output_text"
-	Text string `json:"text"`
+	Type     string `json:"type"` // "input_text", "output_text", "input_file"
+	Text     string `json:"text,omitempty"`
+	Filename string `json:"filename,omitempty"`  // for input_file
+	FileData string `json:"file_data,omitempty"` // for input_file, as a data URL
 }
 
 type responsesTool struct {
@@ -136,8 +152,11 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 	for _, tr := range toolResults {
 		// Collect all text from content objects
 		var texts []string
+		var files []responsesContent
 		for _, result := range tr.ToolResult {
-			if strings.TrimSpace(result.Text) != "" {
+			if result.IsDocument() {
+				files = append(files, fromLLMDocumentResponses(result))
+			} else if strings.TrimSpace(result.Text) != "" {
 				texts = append(texts, result.Text)
 			}
 		}
@@ -152,10 +171,14 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 			}
 		}
 
+		var output any = cmp.Or(toolResultContent, " ")
+		if len(files) > 0 {
+			output = append([]responsesContent{{Type: "input_text", Text: cmp.Or(toolResultContent, " ")}}, files...)
+		}
 		items = append(items, responsesInputItem{
 			Type:   "function_call_output",
 			CallID: tr.ToolUseID,
-			Output: cmp.Or(toolResultContent, " "),
+			Output: output,
 		})
 	}
 
@@ -167,7 +190,9 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 		for _, c := range regularContent {
 			switch c.Type {
 			case llm.ContentTypeText:
-				if c.Text != "" {
+				if c.IsDocument() && msg.Role == llm.MessageRoleUser {
+					messageContent = append(messageContent, fromLLMDocumentResponses(c))
+				} else if c.Text != "" {
 					contentType := "input_text"
 					if msg.Role == llm.MessageRoleAssistant {
 						contentType = "output_text"
@@ -208,6 +233,15 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 	return items
 }
 
+// fromLLMDocumentResponses converts document content to an input_file item.
+func fromLLMDocumentResponses(c llm.Content) responsesContent {
+	return responsesContent{
+		Type:     "input_file",
+		Filename: cmp.Or(c.Filename, "document.pdf"),
+		FileData: "data:" + c.MediaType + ";base64," + c.Data,
+	}
+}
+
 // fromLLMToolResponses converts llm.Tool to Responses API tool format
 func fromLLMToolResponses(t *llm.Tool) responsesTool {
 	return responsesTool{
@@ -325,41 +359,35 @@ func (s *ResponsesService) toLLMUsageFromResponses(usage responsesUsage, headers
 		inc = uint64(usage.InputTokensDetails.CachedTokens)
 	}
 	out := uint64(usage.OutputTokens)
+	// OpenAI caches prompt prefixes automatically and reports the cached
+	// part of the input; InputTokens counts only the uncached part.
 	u := llm.Usage{
-		InputTokens:              in,
-		CacheReadInputTokens:     inc,
-		CacheCreationInputTokens: in,
-		OutputTokens:             out,
+		InputTokens:          in - min(inc, in),
+		CacheReadInputTokens: inc,
+		OutputTokens:         out,
 	}
 	u.CostUSD = llm.CostUSDFromResponse(headers)
 	return u
 }
 
+// ModelName returns the model name this service requests.
+func (s *ResponsesService) ModelName() string {
+	return cmp.Or(s.Model, DefaultModel).ModelName
+}
+
 // TokenContextWindow returns the maximum token context window size for this service
 func (s *ResponsesService) TokenContextWindow() int {
-	model := cmp.Or(s.Model, DefaultModel)
-
-	// Use the same context window logic as the regular service
-	switch model.ModelName {
-	case "gpt-5.3-codex":
-		return 288000 // 288k for gpt-5.3-codex
-	case "gpt-5.2-codex":
-		return 272000 // 272k for gpt-5.2-codex
-	case "gpt-5.1-codex":
-		return 256000 // 256k for gpt-5.1-codex
-	case "gpt-4.1-2025-04-14", "gpt-4.1-mini-2025-04-14", "gpt-4.1-nano-2025-04-14":
-		return 200000
-	case "gpt-4o-2024-08-06", "gpt-4o-mini-2024-07-18":
-		return 128000
-	default:
-		return 128000
+	if spec, ok := llm.LookupModel(s.ModelName()); ok && spec.ContextWindow > 0 {
+		return spec.ContextWindow
 	}
+	return 128000 // OpenAI-compatible models generally have at least 128k context windows
 }
 
-// MaxImageDimension returns the maximum allowed image dimension.
-// TODO: determine actual OpenAI image dimension limits
+// MaxImageDimension returns the maximum allowed image dimension from the
+// model catalog, or 0 if there is no known limit.
 func (s *ResponsesService) MaxImageDimension() int {
-	return 0 // No known limit
+	spec, _ := llm.LookupModel(s.ModelName())
+	return spec.MaxImageDimension
 }
 
 // Do sends a request to OpenAI using the Responses API.
@@ -375,7 +403,12 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 	}
 
 	// Add regular messages
-	for _, msg := range ir.Messages {
+	spec, _ := llm.LookupModel(model.ModelName)
+	messages := ir.Messages
+	if !spec.SupportsDocuments(true) {
+		messages = llm.DocumentsAsText(messages)
+	}
+	for _, msg := range messages {
 		items := fromLLMMessageResponses(msg)
 		allInput = append(allInput, items...)
 	}
@@ -391,11 +424,11 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		Model:           model.ModelName,
 		Input:           allInput,
 		Tools:           tools,
-		MaxOutputTokens: cmp.Or(s.MaxTokens, DefaultMaxTokens),
+		MaxOutputTokens: spec.CapMaxTokens(cmp.Or(s.MaxTokens, DefaultMaxTokens)),
 	}
 
-	// Add reasoning if thinking is enabled
-	if s.ThinkingLevel != llm.ThinkingLevelOff {
+	// Add reasoning if thinking is enabled and the model supports it
+	if s.ThinkingLevel != llm.ThinkingLevelOff && spec.SupportsThinking() {
 		effort := s.ThinkingLevel.ThinkingEffort()
 		if effort != "" {
 			req.Reasoning = &responsesReasoning{Effort: effort}
@@ -407,6 +440,16 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		req.ToolChoice = fromLLMToolChoice(ir.ToolChoice)
 	}
 
+	// Structured output; strict mode is off as for the chat API.
+	if rf := ir.ResponseFormat; rf != nil {
+		req.Text = &responsesText{Format: responsesTextFormat{
+			Type:        "json_schema",
+			Name:        rf.Name,
+			Description: rf.Description,
+			Schema:      rf.Schema,
+		}}
+	}
+
 	// Construct the full URL
 	baseURL := cmp.Or(s.ModelURL, model.URL, OpenAIURL)
 	fullURL := baseURL + "/responses"
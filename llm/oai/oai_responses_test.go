@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/replay/replaytest"
 )
 
 func TestResponsesServiceBasic(t *testing.T) {
@@ -125,6 +126,47 @@ func TestFromLLMMessageResponses(t *testing.T) {
 	}
 }
 
+func TestFromLLMMessageResponsesDocument(t *testing.T) {
+	doc := llm.Content{Type: llm.ContentTypeText, Text: "Document: report.pdf\n\nextracted", MediaType: llm.MediaTypePDF, Filename: "report.pdf", Data: "JVBERi0xLjQ="}
+	wantFile := responsesContent{Type: "input_file", Filename: "report.pdf", FileData: "data:application/pdf;base64,JVBERi0xLjQ="}
+
+	items := fromLLMMessageResponses(llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{llm.StringContent("Summarize this"), doc},
+	})
+	if len(items) != 1 || len(items[0].Content) != 2 || items[0].Content[1] != wantFile {
+		t.Errorf("unexpected items: %+v", items)
+	}
+
+	items = fromLLMMessageResponses(llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{{
+			Type:       llm.ContentTypeToolResult,
+			ToolUseID:  "call_1",
+			ToolResult: []llm.Content{llm.StringContent("File: report.pdf"), doc},
+		}},
+	})
+	output, ok := items[0].Output.([]responsesContent)
+	if !ok || len(output) != 2 || output[0].Text != "File: report.pdf" || output[1] != wantFile {
+		t.Errorf("unexpected tool output: %#v", items[0].Output)
+	}
+}
+
+func TestToLLMUsageFromResponses(t *testing.T) {
+	s := &ResponsesService{}
+	usage := s.toLLMUsageFromResponses(responsesUsage{
+		InputTokens:        1000,
+		InputTokensDetails: &responsesInputTokensDetails{CachedTokens: 800},
+		OutputTokens:       40,
+	}, nil)
+	if usage.InputTokens != 200 || usage.CacheReadInputTokens != 800 || usage.CacheCreationInputTokens != 0 || usage.OutputTokens != 40 {
+		t.Errorf("usage = %+v", usage)
+	}
+	if usage.TotalInputTokens() != 1000 {
+		t.Errorf("TotalInputTokens = %d, want 1000", usage.TotalInputTokens())
+	}
+}
+
 func TestFromLLMToolResponses(t *testing.T) {
 	tool := &llm.Tool{
 		Name:        "test_tool",
@@ -431,90 +473,62 @@ func TestFromLLMSystemResponsesAllEmpty(t *testing.T) {
 }
 
 func TestResponsesServiceDo(t *testing.T) {
-	// Create a mock Responses server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/responses" {
-			t.Errorf("Expected path /responses, got %s", r.URL.Path)
-		}
-		if r.Header.Get("Authorization") != "Bearer test-api-key" {
-			t.Errorf("Expected Authorization header, got %s", r.Header.Get("Authorization"))
-		}
+	rt, key := replaytest.Open(t, "testdata/responses_tool_roundtrip.json", OpenAIAPIKeyEnv)
+	svc := &ResponsesService{
+		APIKey: key,
+		Model:  GPT41,
+		HTTPC:  rt.Client(),
+	}
 
-		// Send a mock response
-		response := responsesResponse{
-			ID:    "responses-test123",
-			Model: "test-model",
-			Output: []responsesOutputItem{
-				{
-					Type: "message",
-					Role: "assistant",
-					Content: []responsesContent{
-						{
-							Type: "text",
-							Text: "Hello! How can I help you today?",
-						},
-					},
-				},
-			},
-			Usage: responsesUsage{
-				InputTokens:  10,
-				OutputTokens: 20,
-			},
-		}
+	call, answer := replaytest.ToolRoundTrip(t, svc)
+	if rt.Recording() {
+		return
+	}
+	if call.ID != "resp_68f3a1c2b7e48190a4d1c5e2f0b9d3e7" {
+		t.Errorf("resp.ID = %q, expected the recorded response ID", call.ID)
+	}
+	if call.Usage.InputTokens != 104 || call.Usage.OutputTokens != 16 {
+		t.Errorf("first turn usage = %+v, expected 104 in, 16 out", call.Usage)
+	}
+	if answer.Usage.InputTokens != 133 || answer.Usage.OutputTokens != 13 {
+		t.Errorf("second turn usage = %+v, expected 133 in, 13 out", answer.Usage)
+	}
+}
 
+func TestResponsesServiceResponseFormat(t *testing.T) {
+	var got responsesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(response)
+		_ = json.NewEncoder(w).Encode(responsesResponse{
+			ID: "responses-test123",
+			Output: []responsesOutputItem{{
+				Type:    "message",
+				Role:    "assistant",
+				Content: []responsesContent{{Type: "text", Text: `{"slug": "fix-login"}`}},
+			}},
+		})
 	}))
 	defer server.Close()
 
-	// Create a service with the mock server
-	ctx := context.Background()
-	svc := &ResponsesService{
-		APIKey:   "test-api-key",
-		Model:    GPT41,
-		ModelURL: server.URL,
+	svc := &ResponsesService{APIKey: "test-api-key", Model: GPT41, ModelURL: server.URL}
+	var out struct {
+		Slug string `json:"slug"`
 	}
-
-	// Create a test request
-	req := &llm.Request{
-		Messages: []llm.Message{
-			{
-				Role: llm.MessageRoleUser,
-				Content: []llm.Content{
-					{Type: llm.ContentTypeText, Text: "Hello!"},
-				},
-			},
+	err := llm.DoJSON(context.Background(), svc, &llm.Request{
+		Messages: []llm.Message{llm.UserStringMessage("Fix the login bug")},
+		ResponseFormat: &llm.ResponseFormat{
+			Name:   "slug",
+			Schema: llm.MustSchema(`{"type": "object", "properties": {"slug": {"type": "string"}}, "required": ["slug"]}`),
 		},
-	}
-
-	// Call the Do method
-	resp, err := svc.Do(ctx, req)
+	}, &out)
 	if err != nil {
-		t.Fatalf("Do() error = %v", err)
-	}
-
-	// Verify the response
-	if resp == nil {
-		t.Fatal("Do() returned nil response")
-	}
-	if resp.Role != llm.MessageRoleAssistant {
-		t.Errorf("resp.Role = %v, expected %v", resp.Role, llm.MessageRoleAssistant)
-	}
-	if len(resp.Content) != 1 {
-		t.Errorf("resp.Content length = %d, expected 1", len(resp.Content))
-	} else {
-		content := resp.Content[0]
-		if content.Type != llm.ContentTypeText {
-			t.Errorf("content.Type = %v, expected %v", content.Type, llm.ContentTypeText)
-		}
-		if content.Text != "Hello! How can I help you today?" {
-			t.Errorf("content.Text = %q, expected %q", content.Text, "Hello! How can I help you today?")
-		}
+		t.Fatal(err)
 	}
-	if resp.Usage.InputTokens != 10 {
-		t.Errorf("resp.Usage.InputTokens = %d, expected 10", resp.Usage.InputTokens)
+	if out.Slug != "fix-login" {
+		t.Errorf("slug = %q", out.Slug)
 	}
-	if resp.Usage.OutputTokens != 20 {
-		t.Errorf("resp.Usage.OutputTokens = %d, expected 20", resp.Usage.OutputTokens)
+	if got.Text == nil || got.Text.Format.Type != "json_schema" || got.Text.Format.Name != "slug" || len(got.Text.Format.Schema) == 0 {
+		t.Errorf("text format = %+v", got.Text)
 	}
 }
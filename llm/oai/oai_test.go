@@ -1,15 +1,12 @@
 package oai
 
 import (
-	"context"
 	"encoding/json"
-	"net/http"
-	"net/http/httptest"
 	"testing"
-	"time"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/replay/replaytest"
 )
 
 func TestToRoleFromString(t *testing.T) {
@@ -828,12 +825,15 @@ func TestToLLMUsage(t *testing.T) {
 		},
 	}
 	usage = service.toLLMUsage(openaiUsageWithDetails, nil)
-	if usage.InputTokens != 100 {
-		t.Errorf("toLLMUsage().InputTokens = %d, expected 100", usage.InputTokens)
+	if usage.InputTokens != 75 {
+		t.Errorf("toLLMUsage().InputTokens = %d, expected 75 (uncached)", usage.InputTokens)
 	}
 	if usage.CacheReadInputTokens != 25 {
 		t.Errorf("toLLMUsage().CacheReadInputTokens = %d, expected 25", usage.CacheReadInputTokens)
 	}
+	if usage.CacheCreationInputTokens != 0 || usage.TotalInputTokens() != 100 {
+		t.Errorf("toLLMUsage() = %+v, expected 100 total input tokens", usage)
+	}
 }
 
 func TestToLLMResponse(t *testing.T) {
@@ -1237,91 +1237,24 @@ func TestTokenContextWindowAdditionalCases(t *testing.T) {
 }
 
 func TestServiceDo(t *testing.T) {
-	// Create a mock OpenAI server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/v1/chat/completions" {
-			t.Errorf("Expected path /v1/chat/completions, got %s", r.URL.Path)
-		}
-		if r.Header.Get("Authorization") != "Bearer test-api-key" {
-			t.Errorf("Expected Authorization header, got %s", r.Header.Get("Authorization"))
-		}
-
-		// Send a mock response
-		response := openai.ChatCompletionResponse{
-			ID:      "chatcmpl-test123",
-			Object:  "chat.completion",
-			Created: time.Now().Unix(),
-			Model:   "gpt-4.1-2025-04-14",
-			Choices: []openai.ChatCompletionChoice{
-				{
-					Index: 0,
-					Message: openai.ChatCompletionMessage{
-						Role:    "assistant",
-						Content: "Hello! How can I help you today?",
-					},
-					FinishReason: "stop",
-				},
-			},
-			Usage: openai.Usage{
-				PromptTokens:     10,
-				CompletionTokens: 20,
-				TotalTokens:      30,
-			},
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(response)
-	}))
-	defer server.Close()
-
-	// Create a service with the mock server
-	ctx := context.Background()
+	rt, key := replaytest.Open(t, "testdata/chat_tool_roundtrip.json", OpenAIAPIKeyEnv)
 	svc := &Service{
-		APIKey:   "test-api-key",
-		Model:    GPT41,
-		ModelURL: server.URL + "/v1",
+		APIKey: key,
+		Model:  GPT41,
+		HTTPC:  rt.Client(),
 	}
 
-	// Create a test request
-	req := &llm.Request{
-		Messages: []llm.Message{
-			{
-				Role: llm.MessageRoleUser,
-				Content: []llm.Content{
-					{Type: llm.ContentTypeText, Text: "Hello!"},
-				},
-			},
-		},
-	}
-
-	// Call the Do method
-	resp, err := svc.Do(ctx, req)
-	if err != nil {
-		t.Fatalf("Do() error = %v", err)
-	}
-
-	// Verify the response
-	if resp == nil {
-		t.Fatal("Do() returned nil response")
-	}
-	if resp.Role != llm.MessageRoleAssistant {
-		t.Errorf("resp.Role = %v, expected %v", resp.Role, llm.MessageRoleAssistant)
+	call, answer := replaytest.ToolRoundTrip(t, svc)
+	if rt.Recording() {
+		return
 	}
-	if len(resp.Content) != 1 {
-		t.Errorf("resp.Content length = %d, expected 1", len(resp.Content))
-	} else {
-		content := resp.Content[0]
-		if content.Type != llm.ContentTypeText {
-			t.Errorf("content.Type = %v, expected %v", content.Type, llm.ContentTypeText)
-		}
-		if content.Text != "Hello! How can I help you today?" {
-			t.Errorf("content.Text = %q, expected %q", content.Text, "Hello! How can I help you today?")
-		}
+	if call.Role != llm.MessageRoleAssistant {
+		t.Errorf("resp.Role = %v, expected %v", call.Role, llm.MessageRoleAssistant)
 	}
-	if resp.Usage.InputTokens != 10 {
-		t.Errorf("resp.Usage.InputTokens = %d, expected 10", resp.Usage.InputTokens)
+	if call.Usage.InputTokens != 98 || call.Usage.OutputTokens != 15 {
+		t.Errorf("first turn usage = %+v, expected 98 in, 15 out", call.Usage)
 	}
-	if resp.Usage.OutputTokens != 20 {
-		t.Errorf("resp.Usage.OutputTokens = %d, expected 20", resp.Usage.OutputTokens)
+	if answer.Usage.InputTokens != 126 || answer.Usage.OutputTokens != 12 {
+		t.Errorf("second turn usage = %+v, expected 126 in, 12 out", answer.Usage)
 	}
 }
@@ -0,0 +1,519 @@
+// Package pdftext extracts plain text from PDF files, for models that cannot
+// read PDF documents themselves.
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrNoText is returned when a PDF contains no extractable text, as with
+// scanned documents.
+var ErrNoText = errors.New("pdftext: no extractable text")
+
+// IsPDF reports whether data starts with the PDF file signature.
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-"))
+}
+
+// Limits on decompressed stream data, so that a small PDF with a
+// decompression bomb in a content stream cannot exhaust memory.
+const (
+	maxStreamSize  = 16 << 20 // per stream
+	maxDecodedSize = 64 << 20 // across all streams of a document
+)
+
+// Extract returns the text of a PDF. It uses poppler's pdftotext when it is
+// installed, and otherwise reads text-showing operators from the page
+// content streams, which works for most PDFs whose fonts use simple
+// encodings. ctx bounds the pdftotext run.
+func Extract(ctx context.Context, data []byte) (string, error) {
+	if !IsPDF(data) {
+		return "", errors.New("pdftext: not a PDF file")
+	}
+	if text, err := extractPdftotext(ctx, data); err == nil {
+		return text, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return extractBuiltin(data)
+}
+
+func extractPdftotext(ctx context.Context, data []byte) (string, error) {
+	path, err := exec.LookPath("pdftotext")
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, "-layout", "-enc", "UTF-8", "-", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(out))
+	if text == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// streamDict matches the dictionary of a stream object; the stream data
+// follows the "stream" keyword and an end-of-line marker.
+var streamDict = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// extractBuiltin extracts text from the content streams of a PDF.
+func extractBuiltin(data []byte) (string, error) {
+	var out strings.Builder
+	budget := int64(maxDecodedSize)
+	for _, loc := range streamDict.FindAllSubmatchIndex(data, -1) {
+		if budget <= 0 {
+			break
+		}
+		dict := string(data[loc[2]:loc[3]])
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		if skipStream(dict) {
+			continue
+		}
+		raw := data[start : start+end]
+		if strings.Contains(dict, "/Filter") {
+			if !strings.Contains(dict, "/FlateDecode") || strings.Contains(dict, "/DCTDecode") {
+				continue
+			}
+			// Truncated or slightly malformed streams are common; keep
+			// whatever decompressed.
+			zr, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			raw, _ = io.ReadAll(io.LimitReader(zr, min(budget, maxStreamSize)))
+			budget -= int64(len(raw))
+		}
+		text, err := contentText(raw)
+		if err != nil {
+			return "", err
+		}
+		if text != "" {
+			out.WriteString(text)
+			out.WriteString("\n")
+		}
+	}
+	text := strings.TrimSpace(out.String())
+	if !readable(text) {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// skipStream reports whether a stream dictionary describes something other
+// than page content: images, fonts, metadata and cross-reference data.
+func skipStream(dict string) bool {
+	for _, s := range []string{"/Image", "/XRef", "/ObjStm", "/Metadata", "/Length1", "/Length2", "/FontFile", "/Type1C", "/CIDFontType0C", "/OpenType", "/EmbeddedFile"} {
+		if strings.Contains(dict, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// readable reports whether text looks like real text rather than glyph
+// indices from fonts we cannot decode.
+func readable(text string) bool {
+	if text == "" {
+		return false
+	}
+	var good, total int
+	for _, r := range text {
+		total++
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			good++
+		}
+	}
+	return good*10 >= total*9
+}
+
+// contentText interprets the text operators of a content stream.
+func contentText(stream []byte) (string, error) {
+	var (
+		out       strings.Builder
+		operands  []token
+		inText    bool
+		y         float64
+		lastY     float64
+		started   bool
+		needSpace bool
+	)
+	// emit writes text, starting a new line when the text position moved
+	// vertically and a space when it moved horizontally.
+	emit := func(s string) {
+		if s == "" {
+			return
+		}
+		if started && y != lastY {
+			out.WriteString("\n")
+		} else if started && needSpace && !strings.HasSuffix(out.String(), " ") && !strings.HasPrefix(s, " ") {
+			out.WriteString(" ")
+		}
+		out.WriteString(s)
+		started, needSpace, lastY = true, false, y
+	}
+	move := func(ty float64) {
+		y += ty
+		needSpace = true
+	}
+	num := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		return operands[i].num
+	}
+	str := func(i int) string {
+		if i < 0 || i >= len(operands) {
+			return ""
+		}
+		return decodeString(operands[i].str)
+	}
+
+	lex := &lexer{data: stream}
+	for {
+		tok, err := lex.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if tok.kind != tokOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		n := len(operands)
+		switch tok.op {
+		case "BT":
+			inText = true
+			y = 0
+			needSpace = true
+		case "ET":
+			inText = false
+		case "ID":
+			lex.skipInlineImage()
+		}
+		if inText {
+			switch tok.op {
+			case "Td", "TD":
+				move(num(n - 1))
+			case "Tm":
+				y = num(n - 1)
+				needSpace = true
+			case "T*":
+				move(-1)
+			case "Tj":
+				emit(str(n - 1))
+			case "'":
+				move(-1)
+				emit(str(n - 1))
+			case "\"":
+				move(-1)
+				emit(str(n - 1))
+			case "TJ":
+				if n > 0 && operands[n-1].kind == tokArray {
+					var b strings.Builder
+					for _, el := range operands[n-1].array {
+						switch el.kind {
+						case tokString:
+							b.WriteString(decodeString(el.str))
+						case tokNumber:
+							// Large negative adjustments separate words.
+							if el.num < -200 && !strings.HasSuffix(b.String(), " ") {
+								b.WriteString(" ")
+							}
+						}
+					}
+					emit(b.String())
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// decodeString converts a PDF string to UTF-8: UTF-16BE with a byte order
+// mark, UTF-8, or otherwise single-byte (PDFDocEncoding, treated as Latin-1).
+func decodeString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+type tokenKind int
+
+const (
+	tokNumber tokenKind = iota
+	tokString
+	tokName
+	tokArray
+	tokDict
+	tokOperator
+)
+
+type token struct {
+	kind  tokenKind
+	num   float64
+	str   []byte
+	op    string
+	array []token
+}
+
+// maxArrayDepth limits the nesting of arrays in a content stream.
+const maxArrayDepth = 256
+
+var errTooDeep = errors.New("pdftext: arrays nested too deeply")
+
+// lexer tokenizes a PDF content stream.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isWhite(c byte) bool {
+	return strings.IndexByte("\x00\t\n\f\r ", c) >= 0
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isWhite(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next token, or io.EOF at the end of the stream. Arrays
+// are read with an explicit stack, so that hostile input cannot exhaust the
+// goroutine stack.
+func (l *lexer) next() (token, error) {
+	var arrays [][]token // arrays being read, innermost last
+	for {
+		l.skipSpace()
+		end := l.pos >= len(l.data)
+		if end && len(arrays) == 0 {
+			return token{}, io.EOF
+		}
+		var tok token
+		switch {
+		case end || l.data[l.pos] == ']' && len(arrays) > 0:
+			// ']', or the end of the stream, closes the innermost array.
+			if !end {
+				l.pos++
+			}
+			tok = token{kind: tokArray, array: arrays[len(arrays)-1]}
+			arrays = arrays[:len(arrays)-1]
+		case l.data[l.pos] == '[':
+			if len(arrays) == maxArrayDepth {
+				return token{}, errTooDeep
+			}
+			l.pos++
+			arrays = append(arrays, nil)
+			continue
+		default:
+			var ok bool
+			if tok, ok = l.scalar(); !ok {
+				continue
+			}
+		}
+		if len(arrays) == 0 {
+			return tok, nil
+		}
+		arrays[len(arrays)-1] = append(arrays[len(arrays)-1], tok)
+	}
+}
+
+// scalar reads a token other than an array. It reports false, having
+// skipped a byte, for stray delimiters.
+func (l *lexer) scalar() (token, bool) {
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokString, str: l.literalString()}, true
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		l.skipDict()
+		return token{kind: tokDict}, true
+	case c == '<':
+		l.pos++
+		return token{kind: tokString, str: l.hexString()}, true
+	case c == '/':
+		l.pos++
+		return token{kind: tokName, str: l.word()}, true
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return token{}, false
+	}
+	w := l.word()
+	if len(w) == 0 {
+		l.pos++
+		return token{}, false
+	}
+	if f, err := strconv.ParseFloat(string(w), 64); err == nil {
+		return token{kind: tokNumber, num: f}, true
+	}
+	return token{kind: tokOperator, op: string(w)}, true
+}
+
+func (l *lexer) word() []byte {
+	start := l.pos
+	for l.pos < len(l.data) && !isWhite(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+func (l *lexer) literalString() []byte {
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+func (l *lexer) hexString() []byte {
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // '>'
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		b[i] = byte(v)
+	}
+	return b
+}
+
+// skipDict skips an inline dictionary, such as marked-content properties.
+func (l *lexer) skipDict() {
+	depth := 1
+	for l.pos+1 < len(l.data) && depth > 0 {
+		switch {
+		case l.data[l.pos] == '<' && l.data[l.pos+1] == '<':
+			depth++
+			l.pos += 2
+		case l.data[l.pos] == '>' && l.data[l.pos+1] == '>':
+			depth--
+			l.pos += 2
+		case l.data[l.pos] == '(':
+			l.pos++
+			l.literalString()
+		default:
+			l.pos++
+		}
+	}
+}
+
+// skipInlineImage skips binary inline image data up to the EI operator.
+func (l *lexer) skipInlineImage() {
+	for l.pos = max(l.pos, 1); l.pos+1 < len(l.data); l.pos++ {
+		if l.data[l.pos] == 'E' && l.data[l.pos+1] == 'I' && isWhite(l.data[l.pos-1]) &&
+			(l.pos+2 == len(l.data) || isWhite(l.data[l.pos+2])) {
+			l.pos += 2
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
@@ -0,0 +1,109 @@
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF returns a one-page PDF whose content stream is compressed with
+// FlateDecode. Cross-reference offsets are not needed by the extractor.
+func buildPDF(content string) []byte {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write([]byte(content))
+	zw.Close()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	b.WriteString("2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n")
+	b.WriteString("3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj\n")
+	fmt.Fprintf(&b, "4 0 obj << /Length %d /Filter /FlateDecode >>\nstream\n", z.Len())
+	b.Write(z.Bytes())
+	b.WriteString("\nendstream\nendobj\n")
+	b.WriteString("5 0 obj << /Length 4 /Subtype /Image >>\nstream\nBT (no) Tj ET\nendstream\nendobj\n")
+	b.WriteString("trailer << /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractBuiltin(t *testing.T) {
+	pdf := buildPDF(`BT /F1 12 Tf 72 720 Td (Quarterly report) Tj
+0 -14 Td [(Rev) 20 (enue) -300 (grew \(a lot\))] TJ
+T* <FEFF00E9007400E9> Tj ET`)
+
+	got, err := extractBuiltin(pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Quarterly report\nRevenue grew (a lot)\nété"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExtractNoText(t *testing.T) {
+	pdf := buildPDF("q 100 0 0 100 0 0 cm /Im1 Do Q")
+	if _, err := extractBuiltin(pdf); !errors.Is(err, ErrNoText) {
+		t.Errorf("err = %v, want ErrNoText", err)
+	}
+	if _, err := Extract(context.Background(), []byte("hello")); err == nil || !strings.Contains(err.Error(), "not a PDF") {
+		t.Errorf("err = %v for a non-PDF", err)
+	}
+}
+
+func TestExtractBoundsStreams(t *testing.T) {
+	// A text operator followed by far more padding than maxStreamSize
+	// compresses to a few kilobytes; only the bounded prefix is decoded.
+	content := "BT (bounded) Tj ET\n" + strings.Repeat(" ", 4*maxStreamSize)
+	got, err := extractBuiltin(buildPDF(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "bounded" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestExtractCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Extract(ctx, buildPDF("BT (x) Tj ET")); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestInlineImageSkipped(t *testing.T) {
+	got, err := contentText([]byte("BT (before) Tj ET BI /W 2 /H 2 ID \x00(\xff) Tj EI BT 0 -10 Td (after) Tj ET"))
+	if err != nil || got != "before\nafter" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestNestedArrays(t *testing.T) {
+	// Nesting within the limit, including arrays left open at the end.
+	got, err := contentText([]byte("BT [[(nested)] (text)] TJ ET [(open) [[("))
+	if err != nil || got != "text" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// Deep nesting is an error rather than a stack overflow.
+	if _, err := extractBuiltin(buildPDF(strings.Repeat("[", 8<<20))); !errors.Is(err, errTooDeep) {
+		t.Errorf("err = %v, want errTooDeep", err)
+	}
+}
+
+func TestStrayDelimiters(t *testing.T) {
+	content := "BT " + strings.Repeat(")}", 4<<20) + " (after) Tj ET"
+	got, err := extractBuiltin(buildPDF(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "after" {
+		t.Errorf("got %q", got)
+	}
+}
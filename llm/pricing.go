@@ -1,50 +1,18 @@
 package llm
 
-// modelPricing holds per-million-token pricing for known models.
-type modelPricing struct {
-	InputPerMillion  float64 // USD per 1M input tokens
-	OutputPerMillion float64 // USD per 1M output tokens
-	CacheReadPerMillion  float64 // USD per 1M cache-read tokens (0 = same as input)
-	CacheWritePerMillion float64 // USD per 1M cache-write tokens (0 = same as input)
-}
-
-// knownPricing maps API model names (as they appear in usage_data) to pricing.
-// Prices are from public provider pricing pages.
-var knownPricing = map[string]modelPricing{
-	// Anthropic Claude
-	"claude-opus-4-6":             {InputPerMillion: 15, OutputPerMillion: 75, CacheReadPerMillion: 1.5, CacheWritePerMillion: 18.75},
-	"claude-opus-4-5-20251101":    {InputPerMillion: 15, OutputPerMillion: 75, CacheReadPerMillion: 1.5, CacheWritePerMillion: 18.75},
-	"claude-sonnet-4-5-20250929":  {InputPerMillion: 3, OutputPerMillion: 15, CacheReadPerMillion: 0.30, CacheWritePerMillion: 3.75},
-	"claude-sonnet-4-20250514":    {InputPerMillion: 3, OutputPerMillion: 15, CacheReadPerMillion: 0.30, CacheWritePerMillion: 3.75},
-	"claude-haiku-4-5-20251001":   {InputPerMillion: 0.80, OutputPerMillion: 4, CacheReadPerMillion: 0.08, CacheWritePerMillion: 1},
-
-	// OpenAI GPT-5 series (Codex / Responses)
-	"gpt-5.3-codex": {InputPerMillion: 2, OutputPerMillion: 8},
-	"gpt-5.2-codex": {InputPerMillion: 2, OutputPerMillion: 8},
-	"gpt-5.1-codex": {InputPerMillion: 2, OutputPerMillion: 8},
-	"gpt-5.1":       {InputPerMillion: 2, OutputPerMillion: 8},
-	"gpt-5.1-mini":  {InputPerMillion: 0.40, OutputPerMillion: 1.60},
-	"gpt-5.1-nano":  {InputPerMillion: 0.10, OutputPerMillion: 0.40},
-
-	// Gemini
-	"gemini-3-pro-preview":   {InputPerMillion: 1.25, OutputPerMillion: 10},
-	"gemini-3-flash-preview": {InputPerMillion: 0.15, OutputPerMillion: 0.60},
-
-	// Fireworks-hosted models (pay-per-token pricing)
-	"accounts/fireworks/models/qwen3-coder-480b-a35b-fp8":  {InputPerMillion: 0.90, OutputPerMillion: 0.90},
-	"accounts/fireworks/models/glm-4-7":                     {InputPerMillion: 0.90, OutputPerMillion: 0.90},
-	"accounts/fireworks/models/glm-4-0414-p6":               {InputPerMillion: 0.90, OutputPerMillion: 0.90},
-	"accounts/fireworks/models/kimi-k2-instruct":            {InputPerMillion: 0.90, OutputPerMillion: 0.90},
-}
-
-// EstimateCostUSD computes estimated cost from token counts and model name.
-// Returns 0 if the model is not in the pricing table.
+// EstimateCostUSD computes estimated cost from token counts and model name,
+// using the pricing in the model catalog.
+// Returns 0 if the model has no catalog pricing.
 func EstimateCostUSD(model string, inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens uint64) float64 {
-	p, ok := knownPricing[model]
-	if !ok {
+	spec, ok := LookupModel(model)
+	if !ok || spec.Pricing == nil {
 		return 0
 	}
+	return spec.Pricing.CostUSD(inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens)
+}
 
+// CostUSD computes the cost of the given token counts at these prices.
+func (p *Pricing) CostUSD(inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens uint64) float64 {
 	inputRate := p.InputPerMillion
 	cacheReadRate := p.CacheReadPerMillion
 	if cacheReadRate == 0 {
@@ -0,0 +1,231 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// RecordHTTPEnv names the environment variable that switches HTTP fixtures
+// from playback to recording. When it is set, OpenHTTP forwards requests to
+// the real provider and rewrites the fixture.
+const RecordHTTPEnv = "PERCY_LLM_RECORD_HTTP"
+
+// HTTPFixture is the on-disk form of an HTTP recording. Unlike Fixture, it
+// holds the provider's wire format, so playing it back exercises the
+// provider's request and response translation.
+type HTTPFixture struct {
+	Version   int            `json:"version"`
+	Exchanges []HTTPExchange `json:"exchanges"`
+}
+
+// HTTPExchange is one recorded HTTP request and its response. Request
+// headers and the query string are not recorded: they carry credentials.
+type HTTPExchange struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Request     json.RawMessage `json:"request,omitempty"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	Response    json.RawMessage `json:"response"`
+}
+
+// HTTPTransport is an http.RoundTripper that serves an HTTPFixture, or
+// records one when created in recording mode.
+//
+// In playback, each request is matched against unserved exchanges with the
+// same method, path and JSON body. A request with no match is answered with
+// a 400 whose body describes the difference; providers treat a 400 as
+// unrecoverable, so a translation regression fails fast instead of retrying.
+type HTTPTransport struct {
+	path     string
+	base     http.RoundTripper // nil in playback
+	scrubber *Scrubber
+
+	mu      sync.Mutex
+	fixture HTTPFixture
+	used    []bool
+}
+
+// OpenHTTP returns a transport for the fixture at path. If RecordHTTPEnv is
+// set, requests go to base and are recorded to path, with secrets redacted
+// by s; otherwise the fixture is loaded and played back.
+func OpenHTTP(path string, base http.RoundTripper, s *Scrubber) (*HTTPTransport, error) {
+	if os.Getenv(RecordHTTPEnv) != "" {
+		return &HTTPTransport{
+			path:     path,
+			base:     base,
+			scrubber: s,
+			fixture:  HTTPFixture{Version: fixtureVersion},
+		}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	var f HTTPFixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("replay: parse %s: %w", path, err)
+	}
+	if f.Version != fixtureVersion {
+		return nil, fmt.Errorf("replay: %s has version %d, want %d", path, f.Version, fixtureVersion)
+	}
+	return &HTTPTransport{path: path, fixture: f, used: make([]bool, len(f.Exchanges))}, nil
+}
+
+// Recording reports whether t records rather than plays back.
+func (t *HTTPTransport) Recording() bool {
+	return t.base != nil
+}
+
+// Client returns an http.Client that uses t.
+func (t *HTTPTransport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// Remaining returns how many recorded exchanges have not been served.
+func (t *HTTPTransport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, u := range t.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *HTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.Recording() {
+		return t.record(req, body)
+	}
+	return t.play(req, body)
+}
+
+func (t *HTTPTransport) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fixture.Exchanges = append(t.fixture.Exchanges, HTTPExchange{
+		Method:      req.Method,
+		Path:        t.scrubber.Scrub(req.URL.Path),
+		Request:     canonicalJSON(t.scrubber.Scrub(string(body))),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Response:    canonicalJSON(t.scrubber.Scrub(string(respBody))),
+	})
+	if err := t.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *HTTPTransport) save() error {
+	data, err := json.MarshalIndent(t.fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	if err := os.WriteFile(t.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	return nil
+}
+
+func (t *HTTPTransport) play(req *http.Request, body []byte) (*http.Response, error) {
+	got := canonicalJSON(string(body))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var nearest *HTTPExchange
+	for i := range t.fixture.Exchanges {
+		e := &t.fixture.Exchanges[i]
+		if t.used[i] || e.Method != req.Method || e.Path != req.URL.Path {
+			continue
+		}
+		if bytes.Equal(canonicalJSON(string(e.Request)), got) {
+			t.used[i] = true
+			return e.httpResponse(req), nil
+		}
+		if nearest == nil {
+			nearest = e
+		}
+	}
+
+	msg := fmt.Sprintf("replay: %s has no recorded response for %s %s", t.path, req.Method, req.URL.Path)
+	if nearest != nil {
+		msg += fmt.Sprintf("; request body differs from the next recorded one:\n got: %s\nwant: %s", got, canonicalJSON(string(nearest.Request)))
+	}
+	errBody, _ := json.Marshal(map[string]any{"error": map[string]string{"type": "replay_miss", "message": msg}})
+	return &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Status:     "400 Bad Request",
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(errBody)),
+		Request:    req,
+	}, nil
+}
+
+func (e *HTTPExchange) httpResponse(req *http.Request) *http.Response {
+	header := make(http.Header)
+	if e.ContentType != "" {
+		header.Set("Content-Type", e.ContentType)
+	}
+	body := []byte(e.Response)
+	var text string
+	if !strings.Contains(e.ContentType, "json") && json.Unmarshal(e.Response, &text) == nil {
+		body = []byte(text) // recorded as a JSON string by canonicalJSON
+	}
+	return &http.Response{
+		StatusCode: e.Status,
+		Status:     fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
+
+// canonicalJSON returns s re-encoded with sorted keys and no insignificant
+// whitespace, so recorded bodies compare equal to freshly marshaled ones.
+// Bodies that are not JSON are returned as a JSON string.
+func canonicalJSON(s string) json.RawMessage {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var v any
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		data, _ := json.Marshal(s)
+		return data
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
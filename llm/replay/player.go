@@ -0,0 +1,110 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// defaultContextWindow is reported when a fixture does not record one.
+const defaultContextWindow = 200000
+
+// Player is an llm.Service that answers requests from a fixture.
+//
+// Each request is matched against the recorded exchanges, first by exact
+// fingerprint and then by shape, preferring exchanges that have not been
+// served yet in recording order. An exact match may be served again, so
+// retries replay the same response. Requests with no match fail.
+type Player struct {
+	name    string
+	fixture *Fixture
+
+	mu   sync.Mutex
+	used []bool
+}
+
+// NewPlayer returns a Player serving f. name identifies it in errors.
+func NewPlayer(name string, f *Fixture) *Player {
+	return &Player{name: name, fixture: f, used: make([]bool, len(f.Exchanges))}
+}
+
+// Open loads the fixture at path and returns a Player for it.
+func Open(path string) (*Player, error) {
+	f, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlayer(path, f), nil
+}
+
+// Do returns the recorded response for req.
+func (p *Player) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	exact, shape := Normalize(req, nil).Fingerprint()
+
+	p.mu.Lock()
+	i := p.match(exact, shape)
+	if i >= 0 {
+		p.used[i] = true
+	}
+	p.mu.Unlock()
+	if i < 0 {
+		return nil, fmt.Errorf("replay: %s has no recorded response for this request (fingerprint %s, %d messages)",
+			p.name, exact[:12], len(req.Messages))
+	}
+
+	resp, err := p.fixture.Exchanges[i].Response.toLLM()
+	if err != nil {
+		return nil, fmt.Errorf("replay: %s: %w", p.name, err)
+	}
+	now := time.Now()
+	resp.StartTime, resp.EndTime = &now, &now
+	return resp, nil
+}
+
+// match returns the index of the exchange to serve, or -1. p.mu must be held.
+func (p *Player) match(exact, shape string) int {
+	for _, pick := range []func(Exchange, bool) bool{
+		func(e Exchange, used bool) bool { return !used && e.Fingerprint == exact },
+		func(e Exchange, used bool) bool { return !used && e.Shape == shape },
+		func(e Exchange, used bool) bool { return e.Fingerprint == exact },
+	} {
+		for i, e := range p.fixture.Exchanges {
+			if pick(e, p.used[i]) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Remaining returns how many recorded exchanges have not been served.
+func (p *Player) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, u := range p.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+// TokenContextWindow returns the context window of the recorded service.
+func (p *Player) TokenContextWindow() int {
+	if p.fixture.ContextWindow > 0 {
+		return p.fixture.ContextWindow
+	}
+	return defaultContextWindow
+}
+
+// MaxImageDimension returns the image limit of the recorded service.
+func (p *Player) MaxImageDimension() int {
+	return p.fixture.MaxImageDimension
+}
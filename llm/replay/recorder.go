@@ -0,0 +1,92 @@
+package replay
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// Recorder captures exchanges from wrapped services into a fixture file.
+// The file is rewritten after every exchange, so a recording is usable even
+// if the process is killed. Failed requests are not recorded.
+type Recorder struct {
+	path     string
+	scrubber *Scrubber
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecorder returns a Recorder that writes to path, replacing any existing
+// fixture there. Secrets are redacted with s, which may be nil.
+func NewRecorder(path string, s *Scrubber) *Recorder {
+	return &Recorder{
+		path:     path,
+		scrubber: s,
+		fixture:  Fixture{Version: fixtureVersion},
+	}
+}
+
+// Path returns the fixture path.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Wrap returns a service that forwards to svc and records each exchange,
+// labelled with model.
+func (r *Recorder) Wrap(svc llm.Service, model string) llm.Service {
+	return &recordingService{Service: svc, recorder: r, model: model}
+}
+
+func (r *Recorder) record(svc llm.Service, model string, req *llm.Request, resp *llm.Response) error {
+	norm := Normalize(req, r.scrubber)
+	exact, shape := norm.Fingerprint()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fixture.ContextWindow == 0 {
+		r.fixture.ContextWindow = svc.TokenContextWindow()
+		r.fixture.MaxImageDimension = svc.MaxImageDimension()
+	}
+	r.fixture.Exchanges = append(r.fixture.Exchanges, Exchange{
+		Model:       model,
+		Fingerprint: exact,
+		Shape:       shape,
+		Request:     norm,
+		Response:    recordResponse(resp, r.scrubber),
+	})
+	return r.fixture.Save(r.path)
+}
+
+// recordingService is the llm.Service returned by Recorder.Wrap.
+type recordingService struct {
+	llm.Service
+	recorder *Recorder
+	model    string
+}
+
+func (s *recordingService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	resp, err := s.Service.Do(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if err := s.recorder.record(s.Service, s.model, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CountTokens delegates to the wrapped service. Counts are not recorded; a
+// Player estimates them.
+func (s *recordingService) CountTokens(ctx context.Context, req *llm.Request) (int, error) {
+	if tc, ok := s.Service.(llm.TokenCounter); ok {
+		return tc.CountTokens(ctx, req)
+	}
+	return 0, llm.ErrNoTokenCounter
+}
+
+// UseSimplifiedPatch delegates to the wrapped service.
+func (s *recordingService) UseSimplifiedPatch() bool {
+	return llm.UseSimplifiedPatch(s.Service)
+}
@@ -0,0 +1,391 @@
+// Package replay records LLM request/response pairs into fixture files and
+// plays them back, so multi-turn conversations can be regression-tested
+// offline and deterministically.
+//
+// A Recorder wraps any llm.Service and appends each exchange to a fixture.
+// A Player serves a fixture as an llm.Service, answering each request with
+// the recorded response whose request fingerprint matches. Because both work
+// on llm.Request and llm.Response rather than HTTP, a fixture recorded
+// against one provider replays through the same code paths as any other.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// fixtureVersion is the current fixture file format.
+const fixtureVersion = 1
+
+// Redacted replaces secrets in recorded fixtures.
+const Redacted = "[REDACTED]"
+
+// Fixture is the on-disk form of a recording.
+type Fixture struct {
+	Version int `json:"version"`
+	// ContextWindow and MaxImageDimension are taken from the first recorded
+	// service and reported by the Player.
+	ContextWindow     int        `json:"context_window,omitempty"`
+	MaxImageDimension int        `json:"max_image_dimension,omitempty"`
+	Exchanges         []Exchange `json:"exchanges"`
+}
+
+// Exchange is one recorded request and its response.
+type Exchange struct {
+	Model string `json:"model,omitempty"`
+	// Fingerprint identifies the exact request; Shape identifies its
+	// structure with tool result text left out, and is used when tool output
+	// differs between recording and playback (timestamps, temp paths).
+	Fingerprint string   `json:"fingerprint"`
+	Shape       string   `json:"shape"`
+	Request     Request  `json:"request"`
+	Response    Response `json:"response"`
+}
+
+// Request is the normalized form of an llm.Request. The system prompt is not
+// part of it: it embeds the working directory, date and git state, which
+// differ between machines.
+type Request struct {
+	Tools      []string        `json:"tools,omitempty"`
+	ToolChoice *llm.ToolChoice `json:"tool_choice,omitempty"`
+	// ResponseFormat is the name of the requested llm.ResponseFormat.
+	ResponseFormat string    `json:"response_format,omitempty"`
+	Messages       []Message `json:"messages"`
+}
+
+// Message is a normalized llm.Message.
+type Message struct {
+	Role    string    `json:"role"`
+	Content []Content `json:"content"`
+}
+
+// Response is the recorded form of an llm.Response.
+type Response struct {
+	ID         string    `json:"id,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	StopReason string    `json:"stop_reason"`
+	Content    []Content `json:"content"`
+	Usage      llm.Usage `json:"usage"`
+}
+
+// Content is a normalized llm.Content. Timing, display data and cache
+// markers are dropped. In requests, thinking signatures are dropped and image
+// data is replaced by its hash.
+type Content struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id,omitempty"`
+	Text       string          `json:"text,omitempty"`
+	MediaType  string          `json:"media_type,omitempty"`
+	Filename   string          `json:"filename,omitempty"`
+	Data       string          `json:"data,omitempty"`
+	Thinking   string          `json:"thinking,omitempty"`
+	Signature  string          `json:"signature,omitempty"`
+	ToolName   string          `json:"tool_name,omitempty"`
+	ToolInput  json.RawMessage `json:"tool_input,omitempty"`
+	ToolUseID  string          `json:"tool_use_id,omitempty"`
+	ToolError  bool            `json:"tool_error,omitempty"`
+	ToolResult []Content       `json:"tool_result,omitempty"`
+}
+
+// Load reads a fixture from path.
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("replay: parse %s: %w", path, err)
+	}
+	if f.Version != fixtureVersion {
+		return nil, fmt.Errorf("replay: %s has version %d, want %d", path, f.Version, fixtureVersion)
+	}
+	for i, e := range f.Exchanges {
+		if _, err := e.Response.toLLM(); err != nil {
+			return nil, fmt.Errorf("replay: %s exchange %d: %w", path, i, err)
+		}
+	}
+	return &f, nil
+}
+
+// Save writes f to path atomically, creating parent directories as needed.
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	return nil
+}
+
+// Normalize converts req to its recorded form, with secrets redacted.
+func Normalize(req *llm.Request, s *Scrubber) Request {
+	var out Request
+	for _, t := range req.Tools {
+		out.Tools = append(out.Tools, t.Name)
+	}
+	slices.Sort(out.Tools)
+	out.ToolChoice = req.ToolChoice
+	if req.ResponseFormat != nil {
+		out.ResponseFormat = req.ResponseFormat.Name
+	}
+	for _, m := range req.Messages {
+		if m.ExcludedFromContext {
+			continue
+		}
+		out.Messages = append(out.Messages, Message{
+			Role:    roleName(m.Role),
+			Content: normalizeContents(m.Content, s, false),
+		})
+	}
+	return out
+}
+
+// normalizeContents converts contents to their recorded form. Opaque data is
+// kept verbatim when keepData is set, and hashed otherwise.
+func normalizeContents(contents []llm.Content, s *Scrubber, keepData bool) []Content {
+	out := make([]Content, 0, len(contents))
+	for _, c := range contents {
+		n := Content{
+			Type:      contentTypeNames[c.Type],
+			ID:        c.ID,
+			Text:      s.Scrub(c.Text),
+			MediaType: c.MediaType,
+			Filename:  c.Filename,
+			Thinking:  s.Scrub(c.Thinking),
+			ToolName:  c.ToolName,
+			ToolUseID: c.ToolUseID,
+			ToolError: c.ToolError,
+		}
+		switch {
+		case keepData:
+			n.Data, n.Signature = c.Data, c.Signature
+		case c.Data != "":
+			n.Data = "sha256:" + hashString(c.Data)
+		}
+		if len(c.ToolInput) > 0 {
+			n.ToolInput = compactJSON(s.Scrub(string(c.ToolInput)))
+		}
+		if len(c.ToolResult) > 0 {
+			n.ToolResult = normalizeContents(c.ToolResult, s, keepData)
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// Fingerprint returns the exact and structural fingerprints of r.
+func (r Request) Fingerprint() (exact, shape string) {
+	data, _ := json.Marshal(r)
+	exact = hashString(string(data))
+
+	// The shape keeps everything the model chose (tool calls and their
+	// inputs) and everything the user typed, but not what tools returned.
+	stripped := r
+	stripped.Messages = make([]Message, len(r.Messages))
+	for i, m := range r.Messages {
+		stripped.Messages[i] = Message{Role: m.Role, Content: stripResults(m.Content)}
+	}
+	data, _ = json.Marshal(stripped)
+	shape = hashString(string(data))
+	return exact, shape
+}
+
+func stripResults(contents []Content) []Content {
+	out := make([]Content, len(contents))
+	for i, c := range contents {
+		if c.Type == contentTypeNames[llm.ContentTypeToolResult] {
+			c = Content{Type: c.Type, ToolUseID: c.ToolUseID}
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// recordResponse converts resp to its recorded form, with secrets redacted.
+func recordResponse(resp *llm.Response, s *Scrubber) Response {
+	usage := resp.Usage
+	usage.StartTime, usage.EndTime = nil, nil
+	return Response{
+		ID:         resp.ID,
+		Model:      resp.Model,
+		StopReason: stopReasonNames[resp.StopReason],
+		Content:    normalizeContents(resp.Content, s, true),
+		Usage:      usage,
+	}
+}
+
+// toLLM converts a recorded response back to an llm.Response.
+func (r Response) toLLM() (*llm.Response, error) {
+	stop, ok := lookupName(stopReasonNames, r.StopReason)
+	if !ok {
+		return nil, fmt.Errorf("unknown stop reason %q", r.StopReason)
+	}
+	content, err := toLLMContents(r.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &llm.Response{
+		ID:         r.ID,
+		Role:       llm.MessageRoleAssistant,
+		Model:      r.Model,
+		Content:    content,
+		StopReason: stop,
+		Usage:      r.Usage,
+	}, nil
+}
+
+func toLLMContents(contents []Content) ([]llm.Content, error) {
+	out := make([]llm.Content, len(contents))
+	for i, c := range contents {
+		typ, ok := lookupName(contentTypeNames, c.Type)
+		if !ok {
+			return nil, fmt.Errorf("unknown content type %q", c.Type)
+		}
+		results, err := toLLMContents(c.ToolResult)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) == 0 {
+			results = nil
+		}
+		var input json.RawMessage
+		if len(c.ToolInput) > 0 {
+			// Saved fixtures are indented; tools expect the compact form.
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, c.ToolInput); err != nil {
+				return nil, fmt.Errorf("tool input for %s: %w", c.ID, err)
+			}
+			input = buf.Bytes()
+		}
+		out[i] = llm.Content{
+			ID:         c.ID,
+			Type:       typ,
+			Text:       c.Text,
+			MediaType:  c.MediaType,
+			Filename:   c.Filename,
+			Thinking:   c.Thinking,
+			Data:       c.Data,
+			Signature:  c.Signature,
+			ToolName:   c.ToolName,
+			ToolInput:  input,
+			ToolUseID:  c.ToolUseID,
+			ToolError:  c.ToolError,
+			ToolResult: results,
+		}
+	}
+	return out, nil
+}
+
+// contentTypeNames and stopReasonNames are the names used in fixtures, so
+// they stay readable and do not depend on the order of the llm constants.
+var (
+	contentTypeNames = map[llm.ContentType]string{
+		llm.ContentTypeText:             "text",
+		llm.ContentTypeThinking:         "thinking",
+		llm.ContentTypeRedactedThinking: "redacted_thinking",
+		llm.ContentTypeToolUse:          "tool_use",
+		llm.ContentTypeToolResult:       "tool_result",
+	}
+	stopReasonNames = map[llm.StopReason]string{
+		llm.StopReasonStopSequence: "stop_sequence",
+		llm.StopReasonMaxTokens:    "max_tokens",
+		llm.StopReasonEndTurn:      "end_turn",
+		llm.StopReasonToolUse:      "tool_use",
+		llm.StopReasonRefusal:      "refusal",
+	}
+)
+
+func lookupName[K comparable](names map[K]string, name string) (K, bool) {
+	for k, v := range names {
+		if v == name {
+			return k, true
+		}
+	}
+	var zero K
+	return zero, false
+}
+
+// secretPatterns match common API key and token formats.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{20,}`),                 // OpenAI, Anthropic
+	regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`),                 // Google
+	regexp.MustCompile(`fw_[A-Za-z0-9]{20,}`),                   // Fireworks
+	regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36,}`),            // GitHub
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/-]{20,}=*`), // Authorization headers
+}
+
+// Scrubber redacts secrets from recorded text: known key formats plus any
+// literal values it was given, such as the configured API keys.
+type Scrubber struct {
+	literals []string
+}
+
+// NewScrubber returns a Scrubber that also redacts the given literal values.
+// Empty and very short values are ignored.
+func NewScrubber(secrets ...string) *Scrubber {
+	s := &Scrubber{}
+	for _, v := range secrets {
+		if len(v) >= 8 && v != "implicit" {
+			s.literals = append(s.literals, v)
+		}
+	}
+	return s
+}
+
+// Scrub returns text with secrets replaced by Redacted. A nil Scrubber only
+// applies the built-in patterns.
+func (s *Scrubber) Scrub(text string) string {
+	if text == "" {
+		return text
+	}
+	if s != nil {
+		for _, v := range s.literals {
+			text = strings.ReplaceAll(text, v, Redacted)
+		}
+	}
+	for _, re := range secretPatterns {
+		text = re.ReplaceAllString(text, Redacted)
+	}
+	return text
+}
+
+func roleName(r llm.MessageRole) string {
+	if r == llm.MessageRoleAssistant {
+		return "assistant"
+	}
+	return "user"
+}
+
+func compactJSON(s string) json.RawMessage {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return json.RawMessage(s)
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func hashString(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,177 @@
+package replay_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/replay"
+	"github.com/tgruben-circuit/percy/loop"
+)
+
+// runConversation runs each prompt through a loop backed by svc, with a bash
+// tool whose output differs on every run, and returns the history.
+func runConversation(t *testing.T, svc llm.Service, prompts ...string) []llm.Message {
+	t.Helper()
+	bash := &llm.Tool{
+		Name:        "bash",
+		InputSchema: llm.MustSchema(`{"type": "object", "properties": {"command": {"type": "string"}}}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("ran %s at %d", input, time.Now().UnixNano()))}
+		},
+	}
+	l := loop.NewLoop(loop.Config{
+		LLM:   svc,
+		Tools: []*llm.Tool{bash},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			return nil
+		},
+	})
+	for _, p := range prompts {
+		l.QueueUserMessage(llm.UserStringMessage(p))
+		if err := l.ProcessOneTurn(context.Background()); err != nil {
+			t.Fatalf("turn %q: %v", p, err)
+		}
+	}
+	return l.GetHistory()
+}
+
+// transcript renders the model's side of a history for comparison.
+func transcript(history []llm.Message) string {
+	var b strings.Builder
+	for _, m := range history {
+		if m.Role != llm.MessageRoleAssistant {
+			continue
+		}
+		for _, c := range m.Content {
+			fmt.Fprintf(&b, "%s %s %s %s\n", c.Type, c.Text, c.ToolName, c.ToolInput)
+		}
+	}
+	return b.String()
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "convo.json")
+	prompts := []string{"hello", "bash: echo hi", "echo: done"}
+
+	rec := replay.NewRecorder(path, nil)
+	recorded := runConversation(t, rec.Wrap(loop.NewPredictableService(), "predictable"), prompts...)
+
+	f, err := replay.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Exchanges) != 4 {
+		t.Fatalf("recorded %d exchanges, want 4 (hello, tool call, tool result, echo)", len(f.Exchanges))
+	}
+
+	// The bash tool output differs on replay, so the requests after it are
+	// matched by shape rather than exact fingerprint.
+	player := replay.NewPlayer("convo", f)
+	replayed := runConversation(t, player, prompts...)
+	if got, want := transcript(replayed), transcript(recorded); got != want {
+		t.Errorf("replayed transcript differs:\n got: %s\nwant: %s", got, want)
+	}
+	if n := player.Remaining(); n != 0 {
+		t.Errorf("%d exchanges were not replayed", n)
+	}
+
+	// A request that was never recorded fails.
+	if _, err := player.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("unrecorded")}}); err == nil {
+		t.Error("expected an error for an unrecorded request")
+	}
+}
+
+func TestRecorderRedactsSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	rec := replay.NewRecorder(path, replay.NewScrubber("hunter2-literal-secret"))
+	svc := rec.Wrap(loop.NewPredictableService(), "predictable")
+
+	prompt := "echo: key sk-ant-REDACTED and hunter2-literal-secret"
+	if _, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage(prompt)}}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := replay.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(f)
+	for _, secret := range []string{"sk-ant-api03", "hunter2"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("fixture contains %q: %s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), replay.Redacted) {
+		t.Errorf("fixture has no redaction marker: %s", data)
+	}
+}
+
+func TestReplayFixture(t *testing.T) {
+	player, err := replay.Open("testdata/bash_roundtrip.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := runConversation(t, player, "list the files")
+	if len(history) != 4 {
+		t.Fatalf("got %d messages, want user, tool call, tool result, answer", len(history))
+	}
+	if c := history[1].Content[len(history[1].Content)-1]; c.Type != llm.ContentTypeToolUse || c.ToolName != "bash" {
+		t.Errorf("expected a bash call, got %+v", c)
+	}
+	if got := history[3].Content[0].Text; got != "The directory contains go.mod and main.go." {
+		t.Errorf("final answer = %q", got)
+	}
+}
+
+func TestHTTPTransportPlayback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http.json")
+	fixture := `{"version": 1, "exchanges": [{
+		"method": "POST", "path": "/v1/messages",
+		"request": {"model": "m", "messages": [{"role": "user", "content": "hi"}]},
+		"status": 200, "content_type": "application/json",
+		"response": {"id": "msg_1"}
+	}]}`
+	if err := os.WriteFile(path, []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt, err := replay.OpenHTTP(path, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rt.Client()
+
+	// Key order and whitespace do not matter.
+	resp, err := client.Post("https://api.example.com/v1/messages", "application/json",
+		strings.NewReader(`{"messages":[{"content":"hi","role":"user"}],"model":"m"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "msg_1") {
+		t.Fatalf("got %d %s, want the recorded response", resp.StatusCode, body)
+	}
+	if n := rt.Remaining(); n != 0 {
+		t.Errorf("%d exchanges were not replayed", n)
+	}
+
+	// A request whose body differs fails with a 400 describing the difference.
+	resp, err = client.Post("https://api.example.com/v1/messages", "application/json",
+		strings.NewReader(`{"model":"other"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "replay_miss") {
+		t.Errorf("got %d %s, want a replay_miss 400", resp.StatusCode, body)
+	}
+}
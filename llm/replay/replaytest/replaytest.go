@@ -0,0 +1,109 @@
+// Package replaytest holds the conversation recorded in each provider's HTTP
+// replay fixture, so provider tests exercise the same exchange.
+package replaytest
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/replay"
+)
+
+// Bash is the tool offered to the model in ToolRoundTrip.
+var Bash = &llm.Tool{
+	Name:        "bash",
+	Description: "Run a shell command and return its output.",
+	InputSchema: llm.MustSchema(`{"type": "object", "properties": {"command": {"type": "string"}}, "required": ["command"]}`),
+}
+
+// Listing is the bash output returned to the model in ToolRoundTrip.
+const Listing = "go.mod\nmain.go"
+
+// Open returns the HTTP transport for the fixture at path, recording against
+// the real provider when replay.RecordHTTPEnv is set. It also returns the API
+// key to use: the value of keyEnv when recording, a placeholder otherwise.
+// The test fails if recorded exchanges are left unserved.
+func Open(t *testing.T, path, keyEnv string) (*replay.HTTPTransport, string) {
+	t.Helper()
+	key := os.Getenv(keyEnv)
+	rt, err := replay.OpenHTTP(path, http.DefaultTransport, replay.NewScrubber(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.Recording() && key == "" {
+		t.Fatalf("%s must be set to record %s", keyEnv, path)
+	}
+	t.Cleanup(func() {
+		if n := rt.Remaining(); n != 0 {
+			t.Errorf("%d recorded exchanges in %s were not requested", n, path)
+		}
+	})
+	if !rt.Recording() {
+		key = "test-key"
+	}
+	return rt, key
+}
+
+// ToolRoundTrip asks svc to list files, checks that it calls Bash, returns
+// Listing as the result and checks the final answer. It returns both
+// responses for provider-specific checks.
+func ToolRoundTrip(t *testing.T, svc llm.Service) (call, answer *llm.Response) {
+	t.Helper()
+	ctx := context.Background()
+	req := &llm.Request{
+		System:   []llm.SystemContent{{Text: "You are a terse assistant. Use the bash tool to inspect the working directory."}},
+		Tools:    []*llm.Tool{Bash},
+		Messages: []llm.Message{llm.UserStringMessage("List the files in the current directory.")},
+	}
+
+	call, err := svc.Do(ctx, req)
+	if err != nil {
+		t.Fatalf("first turn: %v", err)
+	}
+	if call.StopReason != llm.StopReasonToolUse {
+		t.Fatalf("first turn stop reason = %v, want tool use", call.StopReason)
+	}
+	var use *llm.Content
+	for i := range call.Content {
+		if call.Content[i].Type == llm.ContentTypeToolUse {
+			use = &call.Content[i]
+		}
+	}
+	if use == nil || use.ToolName != Bash.Name {
+		t.Fatalf("first turn: expected a bash call, got %+v", call.Content)
+	}
+	if !strings.Contains(string(use.ToolInput), `"command"`) {
+		t.Errorf("bash input = %s, want a command", use.ToolInput)
+	}
+
+	req.Messages = append(req.Messages,
+		llm.Message{Role: llm.MessageRoleAssistant, Content: call.Content},
+		llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{
+			Type:       llm.ContentTypeToolResult,
+			ToolUseID:  use.ID,
+			ToolName:   use.ToolName,
+			ToolResult: []llm.Content{llm.StringContent(Listing)},
+		}}},
+	)
+	answer, err = svc.Do(ctx, req)
+	if err != nil {
+		t.Fatalf("second turn: %v", err)
+	}
+	if answer.StopReason == llm.StopReasonToolUse {
+		t.Errorf("second turn: expected an answer, got another tool call: %+v", answer.Content)
+	}
+	var text strings.Builder
+	for _, c := range answer.Content {
+		if c.Type == llm.ContentTypeText {
+			text.WriteString(c.Text)
+		}
+	}
+	if !strings.Contains(text.String(), "main.go") {
+		t.Errorf("answer %q does not mention main.go", text.String())
+	}
+	return call, answer
+}
@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ResponseFormat asks for a response that is a JSON object matching Schema.
+// Providers enforce it natively where they can: OpenAI with a json_schema
+// response format, Gemini with a response schema, and Anthropic by forcing
+// a call to a tool whose input schema is Schema. The response is a single
+// text content holding the JSON.
+type ResponseFormat struct {
+	// Name identifies the format, such as "memory_cells"; it must be a valid
+	// tool name (letters, digits, '_' and '-').
+	Name        string
+	Description string
+	// Schema is the JSON schema of the response. Its root must be an
+	// object, as MustSchema requires.
+	Schema json.RawMessage
+}
+
+// ErrInvalidOutput is wrapped by DoJSON errors for responses that are not
+// valid JSON matching the schema, even after a repair attempt.
+var ErrInvalidOutput = errors.New("invalid structured output")
+
+// DoJSON sends req, which must have a ResponseFormat, and decodes the JSON
+// response into v. The response is validated against the schema; if it is
+// invalid, the model is shown the problem and asked once to repair it.
+func DoJSON(ctx context.Context, svc Service, req *Request, v any) error {
+	if req.ResponseFormat == nil {
+		return fmt.Errorf("DoJSON: request has no ResponseFormat")
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(req.ResponseFormat.Schema, &schema); err != nil {
+		return fmt.Errorf("DoJSON: bad schema for %s: %w", req.ResponseFormat.Name, err)
+	}
+
+	resp, err := svc.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	text := ResponseText(resp)
+	data, invalid := parseStructured(text, schema)
+	if invalid == nil {
+		return json.Unmarshal(data, v)
+	}
+
+	repair := *req
+	repair.Messages = append(slices.Clone(req.Messages),
+		Message{Role: MessageRoleAssistant, Content: []Content{StringContent(cmp.Or(text, "(empty response)"))}},
+		UserStringMessage(fmt.Sprintf("That response is not valid: %v. Respond again with only a JSON object matching the schema.", invalid)),
+	)
+	resp, err = svc.Do(ctx, &repair)
+	if err != nil {
+		return err
+	}
+	data, invalid = parseStructured(ResponseText(resp), schema)
+	if invalid != nil {
+		return fmt.Errorf("%w for %s: %v", ErrInvalidOutput, req.ResponseFormat.Name, invalid)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ResponseText returns the text content of resp.
+func ResponseText(resp *Response) string {
+	var text strings.Builder
+	for _, c := range resp.Content {
+		if c.Type == ContentTypeText {
+			text.WriteString(c.Text)
+		}
+	}
+	return text.String()
+}
+
+// parseStructured extracts the JSON object in text and validates it against
+// schema. Models without native structured output sometimes wrap the JSON in
+// a code fence or add a sentence around it, so those are removed.
+func parseStructured(text string, schema map[string]any) ([]byte, error) {
+	text = StripCodeFences(text)
+	if text == "" {
+		return nil, errors.New("the response is empty")
+	}
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+		if start < 0 || end < start || json.Unmarshal([]byte(text[start:end+1]), &value) != nil {
+			return nil, fmt.Errorf("the response is not JSON: %v", err)
+		}
+		text = text[start : end+1]
+	}
+	if err := ValidateJSON(schema, value); err != nil {
+		return nil, err
+	}
+	return []byte(text), nil
+}
+
+// StripCodeFences removes a ```json ... ``` fence around s.
+func StripCodeFences(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		// Remove opening fence line.
+		if idx := strings.Index(s, "\n"); idx != -1 {
+			s = s[idx+1:]
+		}
+		// Remove closing fence.
+		if idx := strings.LastIndex(s, "```"); idx != -1 {
+			s = s[:idx]
+		}
+		s = strings.TrimSpace(s)
+	}
+	return s
+}
+
+// ValidateJSON checks a decoded JSON value against a JSON schema. It
+// supports the keywords used by structured output schemas: type,
+// properties, required, additionalProperties (false only), items, enum,
+// minimum and maximum.
+func ValidateJSON(schema map[string]any, value any) error {
+	return validateJSON(schema, value, "$")
+}
+
+func validateJSON(schema map[string]any, value any, path string) error {
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, jsonTypeName(value))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				if name, _ := r.(string); name != "" {
+					if _, ok := v[name]; !ok {
+						return fmt.Errorf("%s: missing required property %q", path, name)
+					}
+				}
+			}
+		}
+		for name, pv := range v {
+			ps, ok := props[name].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := validateJSON(ps, pv, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, iv := range v {
+				if err := validateJSON(items, iv, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case float64:
+		if lo, ok := schema["minimum"].(float64); ok && v < lo {
+			return fmt.Errorf("%s: %v is less than %v", path, v, lo)
+		}
+		if hi, ok := schema["maximum"].(float64); ok && v > hi {
+			return fmt.Errorf("%s: %v is greater than %v", path, v, hi)
+		}
+	}
+	return nil
+}
+
+func matchesType(t, value any) bool {
+	switch t := t.(type) {
+	case string:
+		got := jsonTypeName(value)
+		if t == "number" && got == "integer" {
+			return true
+		}
+		return got == t
+	case []any:
+		return slices.ContainsFunc(t, func(t any) bool { return matchesType(t, value) })
+	}
+	return true
+}
+
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// scriptedService returns its responses in order and records the requests.
+type scriptedService struct {
+	responses []string
+	requests  []*Request
+}
+
+func (s *scriptedService) Do(ctx context.Context, req *Request) (*Response, error) {
+	s.requests = append(s.requests, req)
+	text := s.responses[0]
+	s.responses = s.responses[1:]
+	return &Response{Role: MessageRoleAssistant, Content: []Content{StringContent(text)}}, nil
+}
+
+func (s *scriptedService) TokenContextWindow() int { return 100000 }
+func (s *scriptedService) MaxImageDimension() int  { return 0 }
+
+var testFormat = &ResponseFormat{
+	Name: "answer",
+	Schema: MustSchema(`{
+		"type": "object",
+		"properties": {
+			"value": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		},
+		"required": ["value"]
+	}`),
+}
+
+type testAnswer struct {
+	Value int      `json:"value"`
+	Tags  []string `json:"tags"`
+}
+
+func TestDoJSON(t *testing.T) {
+	for _, tt := range []struct {
+		name, response string
+	}{
+		{"plain", `{"value": 7, "tags": ["a"]}`},
+		{"fenced", "```json\n{\"value\": 7, \"tags\": [\"a\"]}\n```"},
+		{"prose", `Here is the result: {"value": 7, "tags": ["a"]} Hope that helps!`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &scriptedService{responses: []string{tt.response}}
+			var out testAnswer
+			err := DoJSON(context.Background(), svc, &Request{Messages: []Message{UserStringMessage("?")}, ResponseFormat: testFormat}, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Value != 7 || len(out.Tags) != 1 || len(svc.requests) != 1 {
+				t.Errorf("out = %+v after %d requests", out, len(svc.requests))
+			}
+		})
+	}
+}
+
+func TestDoJSONRepair(t *testing.T) {
+	svc := &scriptedService{responses: []string{`{"value": "seven"}`, `{"value": 7}`}}
+	var out testAnswer
+	err := DoJSON(context.Background(), svc, &Request{Messages: []Message{UserStringMessage("?")}, ResponseFormat: testFormat}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != 7 {
+		t.Errorf("value = %d, want 7", out.Value)
+	}
+	if len(svc.requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(svc.requests))
+	}
+	repair := svc.requests[1].Messages
+	if len(repair) != 3 || repair[1].Content[0].Text != `{"value": "seven"}` ||
+		!strings.Contains(repair[2].Content[0].Text, "$.value: expected integer, got string") {
+		t.Errorf("unexpected repair messages: %+v", repair)
+	}
+	if len(svc.requests[0].Messages) != 1 {
+		t.Error("original request modified")
+	}
+}
+
+func TestDoJSONInvalid(t *testing.T) {
+	svc := &scriptedService{responses: []string{"no idea", ""}}
+	var out testAnswer
+	err := DoJSON(context.Background(), svc, &Request{Messages: []Message{UserStringMessage("?")}, ResponseFormat: testFormat}, &out)
+	if !errors.Is(err, ErrInvalidOutput) {
+		t.Fatalf("err = %v, want ErrInvalidOutput", err)
+	}
+	if len(svc.requests) != 2 {
+		t.Errorf("%d requests, want 2", len(svc.requests))
+	}
+
+	if err := DoJSON(context.Background(), svc, &Request{}, &out); err == nil {
+		t.Error("DoJSON without a ResponseFormat succeeded")
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	schema := map[string]any{}
+	json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"kind": {"type": "string", "enum": ["a", "b"]},
+			"score": {"type": "number", "minimum": 0, "maximum": 1},
+			"note": {"type": ["string", "null"]},
+			"items": {"type": "array", "items": {"type": "integer"}}
+		},
+		"required": ["kind"],
+		"additionalProperties": false
+	}`), &schema)
+
+	for _, tt := range []struct {
+		value string
+		want  string // substring of the error, or "" for valid
+	}{
+		{`{"kind": "a"}`, ""},
+		{`{"kind": "b", "score": 0.5, "note": null, "items": [1, 2]}`, ""},
+		{`{"kind": "b", "note": "hi"}`, ""},
+		{`[]`, "expected object, got array"},
+		{`{}`, `missing required property "kind"`},
+		{`{"kind": "c"}`, "is not one of"},
+		{`{"kind": "a", "score": 2}`, "greater than"},
+		{`{"kind": "a", "score": -1}`, "less than"},
+		{`{"kind": "a", "note": 1}`, "$.note: expected"},
+		{`{"kind": "a", "items": [1, 2.5]}`, "$.items[1]: expected integer, got number"},
+		{`{"kind": "a", "extra": true}`, `unexpected property "extra"`},
+	} {
+		var value any
+		json.Unmarshal([]byte(tt.value), &value)
+		err := ValidateJSON(schema, value)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("ValidateJSON(%s) = %v, want nil", tt.value, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("ValidateJSON(%s) = %v, want error containing %q", tt.value, err, tt.want)
+		}
+	}
+}
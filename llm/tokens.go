@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenCounter is implemented by services that can count the input tokens
+// of a request before sending it.
+type TokenCounter interface {
+	// CountTokens returns the number of input tokens req would use.
+	CountTokens(context.Context, *Request) (int, error)
+}
+
+// ErrNoTokenCounter is returned by the CountTokens method of service
+// wrappers whose underlying service is not a TokenCounter.
+var ErrNoTokenCounter = errors.New("service cannot count tokens")
+
+// CountTokens returns the number of input tokens req would use with svc.
+// exact reports whether svc counted the tokens; otherwise (svc is not a
+// TokenCounter, or counting failed) n is EstimateTokens(req).
+func CountTokens(ctx context.Context, svc Service, req *Request) (n int, exact bool) {
+	if tc, ok := svc.(TokenCounter); ok {
+		n, err := tc.CountTokens(ctx, req)
+		if err == nil {
+			return n, true
+		}
+		if !errors.Is(err, ErrNoTokenCounter) {
+			slog.WarnContext(ctx, "token_count_failed", "error", err)
+		}
+	}
+	return EstimateTokens(req), false
+}
+
+const (
+	// imageTokenEstimate is the approximate cost of an image. Providers
+	// charge by pixel count; images are resized to about 1.15 megapixels,
+	// which Anthropic bills at around 1600 tokens.
+	imageTokenEstimate = 1600
+	// messageTokenOverhead approximates role markers and content framing.
+	messageTokenOverhead = 4
+)
+
+// EstimateTokens approximates the number of input tokens req would use.
+// It stands in for a tokenizer, for models whose providers have no token
+// counting endpoint: BPE tokenizers average about four bytes of English or
+// code per token, and a token per character of other scripts.
+func EstimateTokens(req *Request) int {
+	n := 0
+	for _, s := range req.System {
+		n += EstimateTextTokens(s.Text)
+	}
+	for _, t := range req.Tools {
+		n += messageTokenOverhead + EstimateTextTokens(t.Name) + EstimateTextTokens(t.Description) + EstimateTextTokens(string(t.InputSchema))
+	}
+	for _, m := range req.Messages {
+		n += EstimateMessageTokens(m)
+	}
+	return n
+}
+
+// EstimateMessageTokens approximates the number of input tokens m uses.
+func EstimateMessageTokens(m Message) int {
+	return messageTokenOverhead + estimateContentTokens(m.Content)
+}
+
+func estimateContentTokens(contents []Content) int {
+	n := 0
+	for _, c := range contents {
+		switch {
+		case c.MediaType != "" && strings.HasPrefix(c.MediaType, "image/"):
+			n += imageTokenEstimate
+		case c.Type == ContentTypeToolUse:
+			n += EstimateTextTokens(c.ToolName) + EstimateTextTokens(string(c.ToolInput))
+		case c.Type == ContentTypeToolResult:
+			n += estimateContentTokens(c.ToolResult)
+		case c.Type == ContentTypeThinking:
+			n += EstimateTextTokens(c.Thinking)
+		default:
+			// Documents are counted by their extracted text.
+			n += EstimateTextTokens(c.Text)
+		}
+	}
+	return n
+}
+
+// EstimateTextTokens approximates the number of tokens in s.
+func EstimateTextTokens(s string) int {
+	ascii, other := 0, 0
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			ascii++
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		other++
+		i += size
+	}
+	return (ascii+3)/4 + other
+}
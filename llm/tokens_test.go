@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEstimateTextTokens(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"hello world", 3},
+		{"日本語", 3},
+		{"été", 3},
+	} {
+		if got := EstimateTextTokens(tt.s); got != tt.want {
+			t.Errorf("EstimateTextTokens(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	req := &Request{
+		System: []SystemContent{{Text: "12345678"}},
+		Tools:  []*Tool{{Name: "bash", Description: "runs", InputSchema: json.RawMessage(`{}`)}},
+		Messages: []Message{
+			{Role: MessageRoleUser, Content: []Content{
+				StringContent("abcd"),
+				{Type: ContentTypeText, MediaType: "image/png", Data: "iVBORw0KGgo="},
+			}},
+			{Role: MessageRoleAssistant, Content: []Content{
+				{Type: ContentTypeToolUse, ToolName: "bash", ToolInput: json.RawMessage(`{"c":1}`)},
+			}},
+			{Role: MessageRoleUser, Content: []Content{
+				{Type: ContentTypeToolResult, ToolResult: []Content{StringContent("abcdefgh")}},
+			}},
+		},
+	}
+	// system 2; tool 4+1+1+1; messages 4+1+1600, 4+1+2, 4+2
+	if got, want := EstimateTokens(req), 2+7+1605+7+6; got != want {
+		t.Errorf("EstimateTokens() = %d, want %d", got, want)
+	}
+}
+
+type countingStub struct {
+	Service
+	n   int
+	err error
+}
+
+func (s countingStub) CountTokens(context.Context, *Request) (int, error) { return s.n, s.err }
+
+func TestCountTokens(t *testing.T) {
+	req := &Request{Messages: []Message{UserStringMessage("hello world")}}
+	if n, exact := CountTokens(context.Background(), countingStub{n: 42}, req); n != 42 || !exact {
+		t.Errorf("CountTokens() = %d, %v; want the service's count", n, exact)
+	}
+	for _, err := range []error{ErrNoTokenCounter, errors.New("status 500")} {
+		if n, exact := CountTokens(context.Background(), countingStub{err: err}, req); n != EstimateTokens(req) || exact {
+			t.Errorf("CountTokens() = %d, %v; want the estimate after %v", n, exact, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// transcriptPartLimit caps each text, tool input and tool result in a
+// transcript, so that one large tool output does not crowd out the rest.
+const transcriptPartLimit = 2000
+
+// FormatTranscript renders messages as plain text, for prompts that show a
+// conversation to another model without its tool definitions. Tool calls
+// and results are summarized, thinking is omitted, and only the most
+// recent messages that fit in maxBytes are kept (all of them if maxBytes
+// is 0).
+func FormatTranscript(messages []Message, maxBytes int) string {
+	var parts []string
+	size := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		part := formatTranscriptMessage(messages[i])
+		if part == "" {
+			continue
+		}
+		if maxBytes > 0 && size+len(part) > maxBytes && len(parts) > 0 {
+			parts = append(parts, fmt.Sprintf("[%d earlier messages omitted]\n\n", i+1))
+			break
+		}
+		parts = append(parts, part)
+		size += len(part)
+	}
+	var sb strings.Builder
+	for i := len(parts) - 1; i >= 0; i-- {
+		sb.WriteString(parts[i])
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func formatTranscriptMessage(m Message) string {
+	role := "User"
+	if m.Role == MessageRoleAssistant {
+		role = "Assistant"
+	}
+	var sb strings.Builder
+	for _, c := range m.Content {
+		switch c.Type {
+		case ContentTypeText:
+			if text := strings.TrimSpace(c.Text); text != "" && c.MediaType == "" {
+				fmt.Fprintf(&sb, "%s: %s\n\n", role, truncateTranscriptPart(text))
+			}
+		case ContentTypeToolUse:
+			fmt.Fprintf(&sb, "%s: [tool call %s] %s\n\n", role, c.ToolName, truncateTranscriptPart(string(c.ToolInput)))
+		case ContentTypeToolResult:
+			var result []string
+			for _, r := range c.ToolResult {
+				if r.Type == ContentTypeText && r.MediaType == "" {
+					result = append(result, r.Text)
+				}
+			}
+			label := "tool result"
+			if c.ToolError {
+				label = "tool error"
+			}
+			fmt.Fprintf(&sb, "%s: [%s] %s\n\n", role, label, truncateTranscriptPart(strings.Join(result, "\n")))
+		}
+	}
+	return sb.String()
+}
+
+func truncateTranscriptPart(s string) string {
+	if len(s) <= transcriptPartLimit {
+		return s
+	}
+	cut := transcriptPartLimit
+	for cut > 0 && s[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf(" [... %d more bytes]", len(s)-cut)
+}
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFormatTranscript(t *testing.T) {
+	messages := []Message{
+		UserStringMessage("List the files."),
+		{Role: MessageRoleAssistant, Content: []Content{
+			{Type: ContentTypeThinking, Thinking: "I should run ls."},
+			{Type: ContentTypeToolUse, ID: "t1", ToolName: "bash", ToolInput: json.RawMessage(`{"command":"ls"}`)},
+		}},
+		{Role: MessageRoleUser, Content: []Content{
+			{Type: ContentTypeToolResult, ToolUseID: "t1", ToolResult: []Content{StringContent("main.go\n" + strings.Repeat("x", 3000))}},
+		}},
+		{Role: MessageRoleAssistant, Content: []Content{StringContent("There is one file.")}},
+	}
+
+	got := FormatTranscript(messages, 0)
+	for _, want := range []string{
+		"User: List the files.",
+		`Assistant: [tool call bash] {"command":"ls"}`,
+		"User: [tool result] main.go",
+		"[... 1008 more bytes]",
+		"Assistant: There is one file.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("transcript missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "I should run ls.") {
+		t.Error("transcript includes thinking")
+	}
+
+	got = FormatTranscript(messages, 2100)
+	if !strings.HasPrefix(got, "[2 earlier messages omitted]") || !strings.HasSuffix(got, "Assistant: There is one file.") {
+		t.Errorf("truncated transcript:\n%s", got)
+	}
+}
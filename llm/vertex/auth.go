@@ -0,0 +1,274 @@
+package vertex
+
+import (
+	"cmp"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	defaultTokenURI    = "https://oauth2.googleapis.com/token"
+	defaultMetadata    = "169.254.169.254"
+)
+
+// TokenSource supplies OAuth2 access tokens for Vertex AI requests.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// credentialsFile is a Google credentials JSON file: a service account key
+// or the authorized_user file written by "gcloud auth application-default login".
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// service_account
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+
+	// authorized_user
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+	QuotaProjectID string `json:"quota_project_id"`
+}
+
+// CredentialsFromJSON returns a token source for a service account key or
+// authorized_user credentials file, and the project it names, if any.
+func CredentialsFromJSON(data []byte) (TokenSource, string, error) {
+	var f credentialsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, "", fmt.Errorf("vertex: parse credentials: %w", err)
+	}
+	switch f.Type {
+	case "service_account":
+		key, err := parsePrivateKey(f.PrivateKey)
+		if err != nil {
+			return nil, "", err
+		}
+		if f.ClientEmail == "" {
+			return nil, "", errors.New("vertex: service account credentials have no client_email")
+		}
+		sa := &serviceAccount{email: f.ClientEmail, keyID: f.PrivateKeyID, key: key, tokenURI: cmp.Or(f.TokenURI, defaultTokenURI)}
+		return &cachedToken{fetch: sa.fetch}, f.ProjectID, nil
+	case "authorized_user":
+		if f.RefreshToken == "" {
+			return nil, "", errors.New("vertex: authorized_user credentials have no refresh_token")
+		}
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {f.ClientID},
+			"client_secret": {f.ClientSecret},
+			"refresh_token": {f.RefreshToken},
+		}
+		tokenURI := cmp.Or(f.TokenURI, defaultTokenURI)
+		return &cachedToken{fetch: func(ctx context.Context) (tokenResponse, error) {
+			return postToken(ctx, tokenURI, form)
+		}}, f.QuotaProjectID, nil
+	default:
+		return nil, "", fmt.Errorf("vertex: unsupported credentials type %q", f.Type)
+	}
+}
+
+// DefaultCredentials finds Application Default Credentials: the file named by
+// GOOGLE_APPLICATION_CREDENTIALS, then gcloud's well-known file, then the
+// metadata server of the Google Cloud machine we are running on.
+func DefaultCredentials() (TokenSource, string, error) {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("vertex: GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+		}
+		return CredentialsFromJSON(data)
+	}
+	if path := wellKnownCredentialsFile(); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			return CredentialsFromJSON(data)
+		}
+	}
+	host := cmp.Or(os.Getenv("GCE_METADATA_HOST"), defaultMetadata)
+	return &cachedToken{fetch: func(ctx context.Context) (tokenResponse, error) {
+		return metadataToken(ctx, host)
+	}}, "", nil
+}
+
+// wellKnownCredentialsFile returns where gcloud writes application default credentials.
+func wellKnownCredentialsFile() string {
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("APPDATA"); dir != "" {
+			return filepath.Join(dir, "gcloud", "application_default_credentials.json")
+		}
+		return ""
+	}
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+	return filepath.Join(dir, "application_default_credentials.json")
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"` // seconds
+}
+
+// cachedToken reuses a token until shortly before it expires.
+type cachedToken struct {
+	fetch func(ctx context.Context) (tokenResponse, error)
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (c *cachedToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Until(c.expiry) > time.Minute {
+		return c.token, nil
+	}
+	tok, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("vertex: token response has no access_token")
+	}
+	c.token = tok.AccessToken
+	c.expiry = time.Now().Add(time.Duration(cmp.Or(tok.ExpiresIn, 3600)) * time.Second)
+	return c.token, nil
+}
+
+// serviceAccount exchanges a signed JWT for an access token
+// (https://developers.google.com/identity/protocols/oauth2/service-account).
+type serviceAccount struct {
+	email    string
+	keyID    string
+	key      *rsa.PrivateKey
+	tokenURI string
+}
+
+func (sa *serviceAccount) fetch(ctx context.Context) (tokenResponse, error) {
+	assertion, err := sa.assertion(time.Now())
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	return postToken(ctx, sa.tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// assertion returns the RS256-signed JWT that identifies the service account.
+func (sa *serviceAccount) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": sa.keyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   sa.email,
+		"scope": cloudPlatformScope,
+		"aud":   sa.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, sa.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("vertex: sign token request: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+func parsePrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("vertex: service account private_key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("vertex: parse service account private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("vertex: service account private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+// postToken requests a token from an OAuth2 token endpoint. Token requests
+// deliberately use http.DefaultClient rather than the service's client, so
+// credentials are never written to the LLM request log.
+func postToken(ctx context.Context, tokenURI string, form url.Values) (tokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doTokenRequest(req)
+}
+
+// metadataToken fetches the default service account's token from the GCE
+// metadata server.
+func metadataToken(ctx context.Context, host string) (tokenResponse, error) {
+	// Off Google Cloud the metadata address is unreachable; fail fast.
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	tok, err := doTokenRequest(req)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("%w (no Google credentials found; set GOOGLE_APPLICATION_CREDENTIALS or run \"gcloud auth application-default login\")", err)
+	}
+	return tok, nil
+}
+
+func doTokenRequest(req *http.Request) (tokenResponse, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("vertex: token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("vertex: token request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return tokenResponse{}, fmt.Errorf("vertex: token request: %s: %s", resp.Status, body)
+	}
+	var tok tokenResponse
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return tokenResponse{}, fmt.Errorf("vertex: token response: %w", err)
+	}
+	return tok, nil
+}
@@ -0,0 +1,247 @@
+// Package vertex provides Claude and Gemini completions through Google Cloud
+// Vertex AI.
+//
+// Requests are built by the ant and gem packages and adapted to Vertex by an
+// HTTP transport, which also adds OAuth2 credentials from a service account
+// key or Application Default Credentials.
+package vertex
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/ant"
+	"github.com/tgruben-circuit/percy/llm/gem"
+)
+
+const anthropicVersion = "vertex-2023-10-16"
+
+// Service provides Claude or Gemini completions through Vertex AI; Claude is
+// used for model names starting with "claude".
+// Fields should not be altered concurrently with calling any method on Service.
+type Service struct {
+	HTTPC         *http.Client      // defaults to http.DefaultClient if nil
+	Project       string            // defaults to $GOOGLE_CLOUD_PROJECT, $ANTHROPIC_VERTEX_PROJECT_ID or the credentials' project
+	Region        string            // defaults to $GOOGLE_CLOUD_LOCATION, $CLOUD_ML_REGION or "global"
+	Endpoint      string            // API base URL; defaults to https://<region>-aiplatform.googleapis.com
+	Model         string            // e.g. "claude-sonnet-4-5@20250929" or "gemini-2.5-pro"
+	Credentials   string            // service account or authorized_user JSON, or a path to it; defaults to DefaultCredentials
+	MaxTokens     int               // Claude only; defaults to ant.DefaultMaxTokens if zero
+	ThinkingLevel llm.ThinkingLevel // Claude only; as for ant.Service
+
+	mu          sync.Mutex
+	tokens      TokenSource // TokenSource to use; loaded from Credentials if nil
+	credProject string
+}
+
+var _ llm.Service = (*Service)(nil)
+
+// ParseEndpoint interprets a custom model endpoint: "PROJECT/REGION", or a
+// URL, optionally ending in /projects/PROJECT/locations/REGION. The standard
+// Vertex AI hosts are derived from the region, so for those endpoint is empty.
+func ParseEndpoint(v string) (project, region, endpoint string) {
+	v = strings.TrimSuffix(strings.TrimSpace(v), "/")
+	if !strings.Contains(v, "://") {
+		project, region, _ = strings.Cut(v, "/")
+		return project, region, ""
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return "", "", v
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		switch parts[i] {
+		case "projects":
+			project = parts[i+1]
+		case "locations":
+			region = parts[i+1]
+		}
+	}
+	if host, ok := strings.CutSuffix(u.Hostname(), "aiplatform.googleapis.com"); ok {
+		if region == "" {
+			region = strings.TrimSuffix(host, "-")
+		}
+		return project, region, ""
+	}
+	return project, region, u.Scheme + "://" + u.Host
+}
+
+// IsClaude reports whether the service's model is a Claude model.
+func (s *Service) IsClaude() bool {
+	return strings.HasPrefix(s.Model, "claude")
+}
+
+// ModelName returns the provider model name, used for catalog lookups.
+// Vertex names Claude versions "claude-sonnet-4-5@20250929"; the Anthropic
+// name is "claude-sonnet-4-5-20250929".
+func (s *Service) ModelName() string {
+	return strings.Replace(s.Model, "@", "-", 1)
+}
+
+// TokenContextWindow returns the context window of the underlying model.
+func (s *Service) TokenContextWindow() int {
+	if s.IsClaude() {
+		return (&ant.Service{Model: s.ModelName()}).TokenContextWindow()
+	}
+	return (&gem.Service{Model: s.ModelName()}).TokenContextWindow()
+}
+
+// MaxImageDimension returns the image limit of the underlying model.
+func (s *Service) MaxImageDimension() int {
+	if s.IsClaude() {
+		return (&ant.Service{Model: s.ModelName()}).MaxImageDimension()
+	}
+	return (&gem.Service{Model: s.ModelName()}).MaxImageDimension()
+}
+
+// Do sends a request to Vertex AI.
+func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	if s.Model == "" {
+		return nil, fmt.Errorf("vertex: no model")
+	}
+	tokens, credProject, err := s.tokenSource()
+	if err != nil {
+		return nil, err
+	}
+	project := cmp.Or(s.Project, os.Getenv("GOOGLE_CLOUD_PROJECT"), os.Getenv("ANTHROPIC_VERTEX_PROJECT_ID"), credProject)
+	if project == "" {
+		return nil, fmt.Errorf("vertex: no project (set GOOGLE_CLOUD_PROJECT)")
+	}
+
+	httpc := *cmp.Or(s.HTTPC, http.DefaultClient)
+	httpc.Transport = &transport{
+		base:      cmp.Or(httpc.Transport, http.DefaultTransport),
+		tokens:    tokens,
+		anthropic: s.IsClaude(),
+	}
+	publishers := s.baseURL() + "/v1/projects/" + project + "/locations/" + s.region() + "/publishers/"
+	if s.IsClaude() {
+		svc := &ant.Service{
+			HTTPC:         &httpc,
+			URL:           publishers + "anthropic/models/" + s.Model + ":rawPredict",
+			APIKey:        "vertex", // replaced by the transport
+			Model:         s.ModelName(),
+			MaxTokens:     s.MaxTokens,
+			ThinkingLevel: s.ThinkingLevel,
+		}
+		return svc.Do(ctx, ir)
+	}
+	// gem requests <URL>/models/<model>:generateContent.
+	// Vertex AI context caches are managed through a different API.
+	svc := &gem.Service{HTTPC: &httpc, URL: publishers + "google", Model: s.Model, DisableContextCache: true}
+	return svc.Do(ctx, ir)
+}
+
+func (s *Service) region() string {
+	return cmp.Or(s.Region, os.Getenv("GOOGLE_CLOUD_LOCATION"), os.Getenv("CLOUD_ML_REGION"), "global")
+}
+
+func (s *Service) baseURL() string {
+	if s.Endpoint != "" {
+		return strings.TrimSuffix(s.Endpoint, "/")
+	}
+	if region := s.region(); region != "global" {
+		return "https://" + region + "-aiplatform.googleapis.com"
+	}
+	return "https://aiplatform.googleapis.com"
+}
+
+// tokenSource loads credentials on first use.
+func (s *Service) tokenSource() (TokenSource, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens != nil {
+		return s.tokens, s.credProject, nil
+	}
+	var err error
+	switch creds := strings.TrimSpace(s.Credentials); {
+	case creds == "":
+		s.tokens, s.credProject, err = DefaultCredentials()
+	case strings.HasPrefix(creds, "{"):
+		s.tokens, s.credProject, err = CredentialsFromJSON([]byte(creds))
+	default:
+		var data []byte
+		if data, err = os.ReadFile(creds); err == nil {
+			s.tokens, s.credProject, err = CredentialsFromJSON(data)
+		}
+	}
+	if err != nil {
+		s.tokens = nil
+		return nil, "", fmt.Errorf("vertex: load credentials: %w", err)
+	}
+	return s.tokens, s.credProject, nil
+}
+
+// ConfigDetails returns configuration information for logging
+func (s *Service) ConfigDetails() map[string]string {
+	return map[string]string{
+		"url":     s.baseURL(),
+		"model":   s.Model,
+		"project": cmp.Or(s.Project, os.Getenv("GOOGLE_CLOUD_PROJECT"), os.Getenv("ANTHROPIC_VERTEX_PROJECT_ID")),
+		"region":  s.region(),
+	}
+}
+
+// transport adapts Anthropic and Gemini API requests to Vertex AI and adds
+// an OAuth2 access token.
+type transport struct {
+	base      http.RoundTripper
+	tokens    TokenSource
+	anthropic bool
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	if t.anthropic {
+		var body []byte
+		if req.Body != nil {
+			body, err = io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if body, err = toRawPredictPayload(body); err != nil {
+			return nil, err
+		}
+		out.Header.Del("X-API-Key")
+		out.Header.Del("Anthropic-Version")
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+		out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	} else {
+		// The Gemini API key goes in the query string; Vertex uses the token.
+		q := out.URL.Query()
+		q.Del("key")
+		out.URL.RawQuery = q.Encode()
+	}
+	out.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(out)
+}
+
+// toRawPredictPayload converts a Messages API body to the Vertex format: the
+// model moves to the URL and the API version moves into the body.
+func toRawPredictPayload(body []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("vertex: unexpected request body: %w", err)
+	}
+	delete(fields, "model")
+	fields["anthropic_version"], _ = json.Marshal(anthropicVersion)
+	return json.Marshal(fields)
+}
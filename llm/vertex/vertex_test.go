@@ -0,0 +1,200 @@
+package vertex
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// vertexStandIn serves an OAuth2 token endpoint and the Vertex AI
+// prediction endpoints.
+type vertexStandIn struct {
+	t         *testing.T
+	publicKey *rsa.PublicKey
+
+	tokenRequests atomic.Int32
+	path          string
+	rawQuery      string
+	auth          string
+	header        http.Header
+	payload       map[string]any
+}
+
+func (v *vertexStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/token" {
+		v.tokenRequests.Add(1)
+		if err := r.ParseForm(); err != nil {
+			v.t.Errorf("bad token request: %v", err)
+		}
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			v.t.Errorf("grant_type = %q", r.Form.Get("grant_type"))
+		}
+		v.verifyJWT(r.Form.Get("assertion"))
+		io.WriteString(w, `{"access_token": "ya29.test-token", "expires_in": 3600, "token_type": "Bearer"}`)
+		return
+	}
+
+	v.path, v.rawQuery, v.auth, v.header = r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), r.Header.Clone()
+	body, _ := io.ReadAll(r.Body)
+	v.payload = nil
+	if err := json.Unmarshal(body, &v.payload); err != nil {
+		v.t.Errorf("bad request body: %v", err)
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, ":rawPredict"):
+		io.WriteString(w, `{"id": "msg_vrtx_01", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+			"content": [{"type": "text", "text": "Hello from Vertex Claude"}], "stop_reason": "end_turn",
+			"usage": {"input_tokens": 10, "output_tokens": 5}}`)
+	case strings.HasSuffix(r.URL.Path, ":generateContent"):
+		io.WriteString(w, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "Hello from Vertex Gemini"}]}}]}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (v *vertexStandIn) verifyJWT(jwt string) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		v.t.Errorf("malformed JWT %q", jwt)
+		return
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		v.t.Errorf("bad JWT signature encoding: %v", err)
+		return
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(v.publicKey, crypto.SHA256, sum[:], sig); err != nil {
+		v.t.Errorf("JWT signature does not verify: %v", err)
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var c map[string]any
+	json.Unmarshal(claims, &c)
+	if c["iss"] != "percy@test-project.iam.gserviceaccount.com" || c["scope"] != cloudPlatformScope {
+		v.t.Errorf("unexpected JWT claims: %s", claims)
+	}
+}
+
+// newStandIn starts a stand-in and returns it with service account
+// credentials whose token_uri points at it.
+func newStandIn(t *testing.T) (*vertexStandIn, *httptest.Server, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standIn := &vertexStandIn{t: t, publicKey: &key.PublicKey}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "test-project",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "percy@test-project.iam.gserviceaccount.com",
+		"token_uri":      server.URL + "/token",
+	})
+	return standIn, server, string(creds)
+}
+
+func TestClaudeOnVertex(t *testing.T) {
+	standIn, server, creds := newStandIn(t)
+	svc := &Service{Endpoint: server.URL, Region: "us-east5", Model: "claude-sonnet-4-5@20250929", Credentials: creds}
+
+	for range 2 {
+		resp, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Content) != 1 || resp.Content[0].Text != "Hello from Vertex Claude" || resp.Usage.OutputTokens != 5 {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+	}
+	if n := standIn.tokenRequests.Load(); n != 1 {
+		t.Errorf("fetched %d tokens, want 1 (cached)", n)
+	}
+
+	// The project comes from the service account key.
+	if want := "/v1/projects/test-project/locations/us-east5/publishers/anthropic/models/claude-sonnet-4-5@20250929:rawPredict"; standIn.path != want {
+		t.Errorf("path = %s, want %s", standIn.path, want)
+	}
+	if standIn.auth != "Bearer ya29.test-token" {
+		t.Errorf("Authorization = %q", standIn.auth)
+	}
+	if standIn.header.Get("X-Api-Key") != "" {
+		t.Error("Anthropic API key header leaked")
+	}
+	if _, ok := standIn.payload["model"]; ok {
+		t.Error("payload still contains model")
+	}
+	if standIn.payload["anthropic_version"] != anthropicVersion {
+		t.Errorf("anthropic_version = %v", standIn.payload["anthropic_version"])
+	}
+	if svc.ModelName() != "claude-sonnet-4-5-20250929" || svc.TokenContextWindow() != 1000000 {
+		t.Errorf("catalog lookup failed: %s, %d", svc.ModelName(), svc.TokenContextWindow())
+	}
+}
+
+func TestGeminiOnVertex(t *testing.T) {
+	standIn, server, creds := newStandIn(t)
+	svc := &Service{Endpoint: server.URL, Project: "other-project", Region: "global", Model: "gemini-2.5-pro", Credentials: creds}
+
+	resp, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "Hello from Vertex Gemini" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if want := "/v1/projects/other-project/locations/global/publishers/google/models/gemini-2.5-pro:generateContent"; standIn.path != want {
+		t.Errorf("path = %s, want %s", standIn.path, want)
+	}
+	if standIn.rawQuery != "" {
+		t.Errorf("query = %q, want no API key", standIn.rawQuery)
+	}
+	if standIn.auth != "Bearer ya29.test-token" {
+		t.Errorf("Authorization = %q", standIn.auth)
+	}
+	if _, ok := standIn.payload["contents"].([]any); !ok {
+		t.Errorf("payload has no contents: %v", standIn.payload)
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	for _, tt := range []struct {
+		in                        string
+		project, region, endpoint string
+	}{
+		{"my-project/us-east5", "my-project", "us-east5", ""},
+		{"my-project", "my-project", "", ""},
+		{"https://us-east5-aiplatform.googleapis.com/v1/projects/p1/locations/us-east5", "p1", "us-east5", ""},
+		{"https://europe-west1-aiplatform.googleapis.com", "", "europe-west1", ""},
+		{"https://aiplatform.googleapis.com", "", "", ""},
+		{"http://127.0.0.1:8080/", "", "", "http://127.0.0.1:8080"},
+	} {
+		project, region, endpoint := ParseEndpoint(tt.in)
+		if project != tt.project || region != tt.region || endpoint != tt.endpoint {
+			t.Errorf("ParseEndpoint(%q) = %q, %q, %q", tt.in, project, region, endpoint)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/hooks"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestExecuteToolHooks(t *testing.T) {
+	dir := t.TempDir()
+	config, err := hooks.Parse([]byte(`{
+		"pre_tool_use": [
+			{"command": "grep -q vendor/ && { echo 'vendor/ is read-only' >&2; exit 2; }; true"},
+			{"matcher": "echo", "command": "echo '{\"tool_input\": {\"msg\": \"rewritten\"}}'"}
+		],
+		"post_tool_use": [
+			{"command": "grep -q rewritten && echo 'lint: ok'"}
+		],
+		"turn_end": [
+			{"command": "cat > turn_end.json"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran []string
+	echoTool := &llm.Tool{
+		Name: "echo",
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			var args struct {
+				Msg string `json:"msg"`
+			}
+			json.Unmarshal(input, &args)
+			ran = append(ran, args.Msg)
+			return llm.ToolOut{LLMContent: llm.TextContent("echoed " + args.Msg)}
+		},
+	}
+	l := NewLoop(Config{
+		LLM:   NewPredictableService(),
+		Tools: []*llm.Tool{echoTool},
+		Hooks: &hooks.Runner{Config: config, WorkingDir: func() string { return dir }},
+	})
+
+	call := func(input string) llm.Content {
+		return l.executeTool(context.Background(), llm.Content{
+			ID: "tool_1", Type: llm.ContentTypeToolUse, ToolName: "echo", ToolInput: json.RawMessage(input),
+		}, echoTool)
+	}
+	resultText := func(c llm.Content) string {
+		var texts []string
+		for _, r := range c.ToolResult {
+			texts = append(texts, r.Text)
+		}
+		return strings.Join(texts, "\n")
+	}
+
+	result := call(`{"msg": "vendor/x.go"}`)
+	if !result.ToolError || !strings.Contains(resultText(result), "echo call blocked by pre_tool_use hook: vendor/ is read-only") {
+		t.Errorf("blocked call: error=%v %q", result.ToolError, resultText(result))
+	}
+	if len(ran) != 0 {
+		t.Fatalf("blocked tool ran with %v", ran)
+	}
+
+	result = call(`{"msg": "hi"}`)
+	if result.ToolError || resultText(result) != "echoed rewritten\nlint: ok" {
+		t.Errorf("rewritten call: error=%v %q", result.ToolError, resultText(result))
+	}
+
+	l.runTurnEndHooks(context.Background(), llm.Message{
+		Role:    llm.MessageRoleAssistant,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: "All done."}},
+	})
+	data, err := os.ReadFile(filepath.Join(dir, "turn_end.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var in hooks.Input
+	if err := json.Unmarshal(data, &in); err != nil || in.Event != hooks.TurnEnd || in.Response != "All done." {
+		t.Errorf("turn_end input = %s (%v)", data, err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/tgruben-circuit/percy/claudetool"
 	"github.com/tgruben-circuit/percy/gitstate"
+	"github.com/tgruben-circuit/percy/hooks"
 	"github.com/tgruben-circuit/percy/llm"
 )
 
@@ -40,6 +41,9 @@ type Config struct {
 	// If set, this is called at end of turn to check for git state changes.
 	// If nil, Config.WorkingDir is used as a static value.
 	GetWorkingDir func() string
+	// Hooks runs the user's pre_tool_use and post_tool_use hooks around each
+	// tool call, and turn_end hooks at the end of each turn. May be nil.
+	Hooks *hooks.Runner
 }
 
 // Loop manages a conversation turn with an LLM including tool execution and message recording.
@@ -58,8 +62,13 @@ type Loop struct {
 	onGitStateChange GitStateChangeFunc
 	getWorkingDir    func() string
 	activeToolsFn    func() []*llm.Tool
+	hooks            *hooks.Runner
 	lastGitState      *gitstate.GitState
 	truncationRetries int
+	// contextUsed is the context window usage reported for the last
+	// response, when the history had contextMessages messages.
+	contextUsed     int
+	contextMessages int
 }
 
 // NewLoop creates a new Loop instance with the provided configuration
@@ -94,6 +103,7 @@ func NewLoop(config Config) *Loop {
 		workingDir:       config.WorkingDir,
 		onGitStateChange: config.OnGitStateChange,
 		getWorkingDir:    config.GetWorkingDir,
+		hooks:            config.Hooks,
 		lastGitState:     initialGitState,
 	}
 }
@@ -246,6 +256,12 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	// is cancelled or fails after the LLM responds but before tools execute.
 	l.insertMissingToolResults(req)
 
+	// Make sure the request fits in the context window before sending it.
+	shrunk, err := l.fitContextWindow(ctx, llmService, req)
+	if err != nil {
+		return err
+	}
+
 	systemLen := 0
 	for _, sys := range system {
 		systemLen += len(sys.Text)
@@ -259,7 +275,6 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	// Retry LLM requests that fail with retryable errors (EOF, connection reset)
 	const maxRetries = 2
 	var resp *llm.Response
-	var err error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		resp, err = llmService.Do(llmCtx, req)
 		if err == nil {
@@ -312,6 +327,13 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	assistantMessage := resp.ToMessage()
 	l.mu.Lock()
 	l.history = append(l.history, assistantMessage)
+	if shrunk {
+		// Usage reflects the shrunk request, not the history.
+		l.contextUsed = 0
+	} else {
+		l.contextUsed = int(resp.Usage.ContextWindowUsed())
+		l.contextMessages = len(l.history)
+	}
 	l.mu.Unlock()
 
 	// Record assistant message with model and timing metadata
@@ -335,6 +357,7 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 
 	// End of turn - check for git state changes
 	l.checkGitStateChange(ctx)
+	l.runTurnEndHooks(ctx, assistantMessage)
 
 	return nil
 }
@@ -508,17 +531,52 @@ func (l *Loop) handleMaxTokensTruncation(ctx context.Context, resp *llm.Response
 	}
 
 	l.checkGitStateChange(ctx)
+	l.runTurnEndHooks(ctx, errorMessage)
 	return nil
 }
 
+// runTurnEndHooks runs the turn_end hooks with the text of the turn's last message.
+func (l *Loop) runTurnEndHooks(ctx context.Context, last llm.Message) {
+	if !l.hooks.Has(hooks.TurnEnd) {
+		return
+	}
+	l.hooks.Run(ctx, hooks.Input{Event: hooks.TurnEnd, Response: contentText(last.Content)})
+}
+
+// contentText returns the text blocks in content, one per line.
+func contentText(content []llm.Content) string {
+	var texts []string
+	for _, c := range content {
+		if c.Type == llm.ContentTypeText && c.Text != "" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
 // executeTool runs a single tool and returns the tool result content.
+// The pre_tool_use hooks may block the call or rewrite its input, and the
+// post_tool_use hooks may mark the result as an error; context from either
+// is appended to the result.
 func (l *Loop) executeTool(ctx context.Context, c llm.Content, tool *llm.Tool) llm.Content {
 	toolCtx := ctx
 	if l.workingDir != "" {
 		toolCtx = claudetool.WithWorkingDir(ctx, l.workingDir)
 	}
 	startTime := time.Now()
-	result := tool.Run(toolCtx, c.ToolInput)
+	input := c.ToolInput
+	pre := l.hooks.Run(ctx, hooks.Input{Event: hooks.PreToolUse, ToolName: c.ToolName, ToolInput: input})
+	var result llm.ToolOut
+	if pre.Blocked {
+		l.logger.Info("tool call blocked by hook", "name", c.ToolName, "reason", pre.Reason)
+		result = llm.ErrorfToolOut("%s call blocked by pre_tool_use hook: %s", c.ToolName, pre.Reason)
+	} else {
+		if pre.ToolInput != nil {
+			l.logger.Info("tool input rewritten by hook", "name", c.ToolName)
+			input = pre.ToolInput
+		}
+		result = tool.Run(toolCtx, input)
+	}
 	endTime := time.Now()
 
 	var toolResultContent []llm.Content
@@ -531,11 +589,35 @@ func (l *Loop) executeTool(ctx context.Context, c llm.Content, tool *llm.Tool) l
 		toolResultContent = result.LLMContent
 		l.logger.Debug("tool executed successfully", "name", c.ToolName, "duration", endTime.Sub(startTime))
 	}
+	toolError := result.Error != nil
+
+	var post hooks.Result
+	if !pre.Blocked && l.hooks.Has(hooks.PostToolUse) {
+		post = l.hooks.Run(ctx, hooks.Input{
+			Event:      hooks.PostToolUse,
+			ToolName:   c.ToolName,
+			ToolInput:  input,
+			ToolResult: contentText(toolResultContent),
+			ToolError:  toolError,
+		})
+		if post.Blocked {
+			toolError = true
+			toolResultContent = append(toolResultContent, llm.Content{
+				Type: llm.ContentTypeText,
+				Text: "post_tool_use hook reported a problem: " + post.Reason,
+			})
+		}
+	}
+	for _, extra := range []string{pre.Context, post.Context} {
+		if extra != "" {
+			toolResultContent = append(toolResultContent, llm.Content{Type: llm.ContentTypeText, Text: extra})
+		}
+	}
 
 	return llm.Content{
 		Type:             llm.ContentTypeToolResult,
 		ToolUseID:        c.ID,
-		ToolError:        result.Error != nil,
+		ToolError:        toolError,
 		ToolResult:       toolResultContent,
 		ToolUseStartTime: &startTime,
 		ToolUseEndTime:   &endTime,
@@ -89,6 +89,10 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 		return s.makeMaxTokensResponse("This response was truncated due to max tokens limit.", inputTokens), nil
 	}
 
+	if req.ResponseFormat != nil {
+		return s.makeStructuredResponse(req.ResponseFormat, inputTokens), nil
+	}
+
 	// Extract the text content from the last user message
 	var inputText string
 	var hasToolResult bool
@@ -443,6 +447,45 @@ func (s *PredictableService) makeMalformedPatchToolResponse(inputTokens uint64)
 	}
 }
 
+// makeStructuredResponse creates a response with a sample JSON value
+// matching the schema of format.
+func (s *PredictableService) makeStructuredResponse(format *llm.ResponseFormat, inputTokens uint64) *llm.Response {
+	var schema map[string]any
+	json.Unmarshal(format.Schema, &schema)
+	data, _ := json.Marshal(sampleJSON(schema))
+	return s.makeResponse(string(data), inputTokens)
+}
+
+// sampleJSON returns a value matching schema: the first enum value, the
+// minimum of numbers, "predictable" for strings, and empty arrays.
+func sampleJSON(schema map[string]any) any {
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	switch schema["type"] {
+	case "object":
+		obj := map[string]any{}
+		props, _ := schema["properties"].(map[string]any)
+		for name, ps := range props {
+			ps, _ := ps.(map[string]any)
+			obj[name] = sampleJSON(ps)
+		}
+		return obj
+	case "array":
+		return []any{}
+	case "number", "integer":
+		if lo, ok := schema["minimum"].(float64); ok {
+			return lo
+		}
+		return 0
+	case "boolean":
+		return false
+	case "null":
+		return nil
+	}
+	return "predictable"
+}
+
 // GetRecentRequests returns the recent requests made to this service
 func (s *PredictableService) GetRecentRequests() []*llm.Request {
 	s.mu.Lock()
@@ -0,0 +1,235 @@
+package loop
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// Before each request the loop checks that the request fits in the model's
+// context window, so that an oversized request fails with an explanation
+// instead of a provider error. Requests that are clearly small enough are
+// sent without counting. Otherwise the tokens are counted (exactly, if the
+// service is an llm.TokenCounter) and, if there are too many, the request is
+// shrunk: first the largest tool results are shortened, then the oldest
+// turns are omitted. Only the request is changed; the history is kept, so
+// the UI and later distillation still see everything.
+const (
+	// contextOutputReservePercent is the part of the context window left
+	// for the response.
+	contextOutputReservePercent = 10
+	// preflightCountPercent is the estimated size, as a percentage of the
+	// input limit, above which tokens are counted before sending.
+	preflightCountPercent = 75
+	// minToolResultTokens is the smallest size tool results are shortened to.
+	minToolResultTokens = 2000
+)
+
+// contextInputLimit returns the most input tokens a request to svc may use,
+// or 0 if the context window is unknown.
+func contextInputLimit(svc llm.Service) int {
+	window := svc.TokenContextWindow()
+	return window - window*contextOutputReservePercent/100
+}
+
+// fitContextWindow shrinks req to fit in the context window of svc if
+// needed, and reports whether it did. If req cannot be made to fit, it
+// records an error message and returns an error.
+func (l *Loop) fitContextWindow(ctx context.Context, svc llm.Service, req *llm.Request) (shrunk bool, err error) {
+	limit := contextInputLimit(svc)
+	if limit <= 0 || l.approxRequestTokens(req) < limit*preflightCountPercent/100 {
+		return false, nil
+	}
+	n, exact := llm.CountTokens(ctx, svc, req)
+	if n <= limit {
+		return false, nil
+	}
+
+	counted := n
+	var changes []string
+	if trimmed := trimToolResults(req, n-limit, max(limit/20, minToolResultTokens)); trimmed > 0 {
+		changes = append(changes, fmt.Sprintf("shortened %d large tool results", trimmed))
+		n, exact = llm.CountTokens(ctx, svc, req)
+	}
+	if n > limit {
+		if elided := elideOldTurns(req, n-limit); elided > 0 {
+			changes = append(changes, fmt.Sprintf("omitted the %d oldest messages", elided))
+			n, exact = llm.CountTokens(ctx, svc, req)
+		}
+	}
+	l.logger.Info("context window preflight", "tokens", counted, "fitted_tokens", n, "limit", limit, "exact", exact, "changes", changes)
+
+	// An estimate may be well off, so only refuse requests that are sure
+	// to fail; the provider has the final say on the others.
+	if n > limit && (exact || n > svc.TokenContextWindow()) {
+		refusal := llm.Message{
+			Role: llm.MessageRoleAssistant,
+			Content: []llm.Content{{
+				Type: llm.ContentTypeText,
+				Text: fmt.Sprintf(
+					"This conversation no longer fits in the model's context window (%d tokens; the limit is %d), even after shortening tool results and omitting older messages. Distill this conversation to continue with a fresh context, or switch to a model with a larger context window.",
+					n, limit,
+				),
+			}},
+			EndOfTurn: true,
+			ErrorType: llm.ErrorTypeContextWindow,
+		}
+		if err := l.recordMessage(ctx, refusal, llm.Usage{}); err != nil {
+			l.logger.Error("failed to record context window error", "error", err)
+		}
+		return false, fmt.Errorf("request exceeds the context window: %d tokens > %d", n, limit)
+	}
+
+	if len(changes) > 0 {
+		notice := llm.Message{
+			Role: llm.MessageRoleAssistant,
+			Content: []llm.Content{{
+				Type: llm.ContentTypeText,
+				Text: fmt.Sprintf(
+					"Context window is full (%d / %d tokens), so this request %s. Consider distilling this conversation to continue with a fresh context.",
+					counted, limit, strings.Join(changes, " and "),
+				),
+			}},
+			ErrorType: llm.ErrorTypeContextWindow,
+		}
+		if err := l.recordMessage(ctx, notice, llm.Usage{}); err != nil {
+			l.logger.Error("failed to record context window notice", "error", err)
+		}
+	}
+	return len(changes) > 0, nil
+}
+
+// approxRequestTokens cheaply approximates the input tokens of req: the
+// context used by the last response plus an estimate for newer messages.
+func (l *Loop) approxRequestTokens(req *llm.Request) int {
+	l.mu.Lock()
+	used, at := l.contextUsed, l.contextMessages
+	l.mu.Unlock()
+	if used == 0 || at > len(req.Messages) {
+		return llm.EstimateTokens(req)
+	}
+	n := used
+	for _, m := range req.Messages[at:] {
+		n += llm.EstimateMessageTokens(m)
+	}
+	return n
+}
+
+// CountContextTokens returns the number of input tokens the next request
+// would use if messages were queued, and whether the count is exact.
+func (l *Loop) CountContextTokens(ctx context.Context, messages ...llm.Message) (n int, exact bool) {
+	l.mu.Lock()
+	history := slices.Concat(l.history, l.messageQueue, messages)
+	tools := l.activeToolsFn()
+	system := l.system
+	svc := l.llm
+	l.mu.Unlock()
+
+	req := &llm.Request{Messages: history, Tools: tools, System: system}
+	l.insertMissingToolResults(req)
+	return llm.CountTokens(ctx, svc, req)
+}
+
+// CountHistoryTokens is like CountContextTokens for a conversation whose loop
+// hasn't started: it counts a request made of system and history, without
+// tool definitions.
+func CountHistoryTokens(ctx context.Context, svc llm.Service, system []llm.SystemContent, history ...llm.Message) (n int, exact bool) {
+	req := &llm.Request{Messages: slices.Clone(history), System: system}
+	(&Loop{logger: slog.Default()}).insertMissingToolResults(req)
+	return llm.CountTokens(ctx, svc, req)
+}
+
+// trimToolResults shortens the largest text tool results in req to maxTokens
+// until about excess tokens are removed. It returns the number of results
+// shortened.
+func trimToolResults(req *llm.Request, excess, maxTokens int) int {
+	type result struct {
+		msg, content, part int
+		tokens             int
+	}
+	var large []result
+	for mi, m := range req.Messages {
+		for ci, c := range m.Content {
+			if c.Type != llm.ContentTypeToolResult {
+				continue
+			}
+			for ri, r := range c.ToolResult {
+				if r.Type != llm.ContentTypeText || r.MediaType != "" {
+					continue
+				}
+				if tokens := llm.EstimateTextTokens(r.Text); tokens > maxTokens {
+					large = append(large, result{mi, ci, ri, tokens})
+				}
+			}
+		}
+	}
+	slices.SortFunc(large, func(a, b result) int { return b.tokens - a.tokens })
+
+	removed, trimmed := 0, 0
+	for _, r := range large {
+		if removed >= excess {
+			break
+		}
+		// Copy on write: the contents are shared with the loop's history.
+		m := &req.Messages[r.msg]
+		m.Content = slices.Clone(m.Content)
+		c := &m.Content[r.content]
+		c.ToolResult = slices.Clone(c.ToolResult)
+		part := &c.ToolResult[r.part]
+		part.Text = truncateMiddle(part.Text, maxTokens*4)
+		removed += r.tokens - llm.EstimateTextTokens(part.Text)
+		trimmed++
+	}
+	return trimmed
+}
+
+// truncateMiddle shortens s to about keep bytes, keeping its start and end,
+// which usually hold a command's context and its outcome.
+func truncateMiddle(s string, keep int) string {
+	if len(s) <= keep {
+		return s
+	}
+	head, tail := keep*2/3, len(s)-(keep-keep*2/3)
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+	return fmt.Sprintf("%s\n\n[... %d bytes omitted to fit the context window ...]\n\n%s", s[:head], tail-head, s[tail:])
+}
+
+// elideOldTurns removes about excess tokens of the oldest messages from req,
+// keeping the first message, which usually states the task. Messages are
+// removed up to an assistant message so that tool results stay with their
+// tool uses. It returns the number of messages removed.
+func elideOldTurns(req *llm.Request, excess int) int {
+	msgs := req.Messages
+	if len(msgs) < 3 || msgs[0].Role != llm.MessageRoleUser {
+		return 0
+	}
+	cut, removed := 0, 0
+	for k := 1; k < len(msgs)-1; k++ {
+		if msgs[k].Role == llm.MessageRoleAssistant && k > 1 {
+			cut = k
+			if removed >= excess {
+				break
+			}
+		}
+		removed += llm.EstimateMessageTokens(msgs[k])
+	}
+	if cut == 0 {
+		return 0
+	}
+
+	first := msgs[0]
+	first.Content = append(slices.Clone(first.Content), llm.StringContent(
+		fmt.Sprintf("[%d earlier messages were omitted here to fit the context window.]", cut-1)))
+	req.Messages = append([]llm.Message{first}, msgs[cut:]...)
+	return cut - 1
+}
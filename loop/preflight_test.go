@@ -0,0 +1,164 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// countingService is a mock LLM service that counts tokens exactly, using
+// the estimate, and remembers the last request it was sent.
+type countingService struct {
+	contextWindowTestService
+	mu      sync.Mutex
+	lastReq *llm.Request
+	counted int
+}
+
+func (s *countingService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	s.mu.Lock()
+	s.lastReq = req
+	s.mu.Unlock()
+	return s.contextWindowTestService.Do(ctx, req)
+}
+
+func (s *countingService) CountTokens(ctx context.Context, req *llm.Request) (int, error) {
+	s.mu.Lock()
+	s.counted++
+	s.mu.Unlock()
+	return llm.EstimateTokens(req), nil
+}
+
+func preflightLoop(svc llm.Service, history []llm.Message) (*Loop, *[]llm.Message) {
+	var mu sync.Mutex
+	var recorded []llm.Message
+	loop := NewLoop(Config{
+		LLM:     svc,
+		History: history,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			mu.Lock()
+			recorded = append(recorded, message)
+			mu.Unlock()
+			return nil
+		},
+	})
+	return loop, &recorded
+}
+
+func TestPreflightSmallRequestNotCounted(t *testing.T) {
+	svc := &countingService{contextWindowTestService: contextWindowTestService{contextWindow: 200000, inputTokens: 100}}
+	loop, _ := preflightLoop(svc, nil)
+	loop.QueueUserMessage(llm.UserStringMessage("hello"))
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if svc.counted != 0 {
+		t.Errorf("counted tokens %d times for a small request", svc.counted)
+	}
+}
+
+func TestPreflightTrimsToolResults(t *testing.T) {
+	svc := &countingService{contextWindowTestService: contextWindowTestService{contextWindow: 20000, inputTokens: 100}}
+	output := "START\n" + strings.Repeat("log line\n", 10000) + "DONE"
+	history := []llm.Message{
+		llm.UserStringMessage("run the build"),
+		{Role: llm.MessageRoleAssistant, Content: []llm.Content{{
+			ID: "toolu_1", Type: llm.ContentTypeToolUse, ToolName: "bash", ToolInput: json.RawMessage(`{"command":"make"}`),
+		}}},
+		{Role: llm.MessageRoleUser, Content: []llm.Content{{
+			Type: llm.ContentTypeToolResult, ToolUseID: "toolu_1", ToolResult: []llm.Content{llm.StringContent(output)},
+		}}},
+	}
+	loop, recorded := preflightLoop(svc, history)
+	loop.QueueUserMessage(llm.UserStringMessage("what happened?"))
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := svc.lastReq.Messages[2].Content[0].ToolResult[0].Text
+	if len(sent) >= len(output) || !strings.HasPrefix(sent, "START") || !strings.HasSuffix(sent, "DONE") ||
+		!strings.Contains(sent, "omitted to fit the context window") {
+		t.Errorf("tool result not shortened (%d bytes): %.80q", len(sent), sent)
+	}
+	if n := llm.EstimateTokens(svc.lastReq); n > contextInputLimit(svc) {
+		t.Errorf("request still has %d tokens", n)
+	}
+	if history[2].Content[0].ToolResult[0].Text != output {
+		t.Error("history modified")
+	}
+	if len(*recorded) != 2 || (*recorded)[0].ErrorType != llm.ErrorTypeContextWindow ||
+		!strings.Contains((*recorded)[0].Content[0].Text, "shortened 1 large tool results") {
+		t.Errorf("expected a context window notice, got %+v", *recorded)
+	}
+}
+
+func TestPreflightElidesOldTurns(t *testing.T) {
+	svc := &countingService{contextWindowTestService: contextWindowTestService{contextWindow: 20000, inputTokens: 100}}
+	paragraph := strings.Repeat("word ", 1000) // about 1250 tokens
+	history := []llm.Message{llm.UserStringMessage("the task")}
+	for range 10 {
+		history = append(history,
+			llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{llm.StringContent(paragraph)}},
+			llm.UserStringMessage(paragraph))
+	}
+	loop, recorded := preflightLoop(svc, history)
+	loop.QueueUserMessage(llm.UserStringMessage("continue"))
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := svc.lastReq.Messages
+	if len(msgs) >= len(history)+1 || msgs[0].Content[0].Text != "the task" || msgs[1].Role != llm.MessageRoleAssistant {
+		t.Fatalf("old turns not elided: %d messages", len(msgs))
+	}
+	if note := msgs[0].Content[len(msgs[0].Content)-1].Text; !strings.Contains(note, "earlier messages were omitted") {
+		t.Errorf("missing omission note: %q", note)
+	}
+	if msgs[len(msgs)-1].Content[0].Text != "continue" {
+		t.Error("latest message not sent")
+	}
+	if n := llm.EstimateTokens(svc.lastReq); n > contextInputLimit(svc) {
+		t.Errorf("request still has %d tokens", n)
+	}
+	if len(*recorded) != 2 || !strings.Contains((*recorded)[0].Content[0].Text, "oldest messages") {
+		t.Errorf("expected a context window notice, got %+v", *recorded)
+	}
+}
+
+func TestPreflightRefusesOversizedRequest(t *testing.T) {
+	svc := &countingService{contextWindowTestService: contextWindowTestService{contextWindow: 20000, inputTokens: 100}}
+	loop, recorded := preflightLoop(svc, nil)
+	loop.QueueUserMessage(llm.UserStringMessage(strings.Repeat("word ", 20000)))
+	err := loop.ProcessOneTurn(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "context window") {
+		t.Fatalf("err = %v, want a context window error", err)
+	}
+	if svc.lastReq != nil {
+		t.Error("oversized request was sent")
+	}
+	if len(*recorded) != 1 || !(*recorded)[0].EndOfTurn || (*recorded)[0].ErrorType != llm.ErrorTypeContextWindow {
+		t.Errorf("expected a context window error message, got %+v", *recorded)
+	}
+}
+
+func TestCountContextTokens(t *testing.T) {
+	svc := &countingService{contextWindowTestService: contextWindowTestService{contextWindow: 200000}}
+	loop, _ := preflightLoop(svc, []llm.Message{llm.UserStringMessage("hello")})
+	draft := llm.UserStringMessage(strings.Repeat("word ", 100))
+
+	base, exact := loop.CountContextTokens(context.Background())
+	withDraft, _ := loop.CountContextTokens(context.Background(), draft)
+	if !exact || withDraft-base != llm.EstimateMessageTokens(draft) {
+		t.Errorf("counts = %d, %d (exact %v)", base, withDraft, exact)
+	}
+
+	// Services without a counter get an estimate.
+	loop, _ = preflightLoop(&contextWindowTestService{contextWindow: 200000}, []llm.Message{llm.UserStringMessage("hello")})
+	if n, exact := loop.CountContextTokens(context.Background(), draft); exact || n != base+llm.EstimateMessageTokens(draft) {
+		t.Errorf("estimated count = %d (exact %v)", n, exact)
+	}
+}
@@ -17,6 +17,9 @@ type Cell struct {
 	Salience   float64
 	Content    string
 	Embedding  []byte
+	Superseded bool
+	Project    string // project identity (see ProjectID); empty for global cells
+	CreatedAt  string
 }
 
 // CellResult is a search result for a cell query.
@@ -29,6 +32,7 @@ type CellResult struct {
 	CellType   string
 	Salience   float64
 	Content    string
+	Project    string
 	Score      float64
 }
 
@@ -39,6 +43,8 @@ type Topic struct {
 	Summary   string
 	Embedding []byte
 	CellCount int
+	Project   string
+	UpdatedAt string
 }
 
 // TopicResult is a search result for a topic query.
@@ -46,6 +52,7 @@ type TopicResult struct {
 	TopicID   string
 	Name      string
 	Summary   string
+	Project   string
 	Score     float64
 	UpdatedAt string
 }
@@ -53,9 +60,9 @@ type TopicResult struct {
 // InsertCell inserts or replaces a cell and updates the parent topic's cell_count.
 func (d *DB) InsertCell(c Cell) error {
 	_, err := d.db.Exec(
-		`INSERT OR REPLACE INTO cells (cell_id, topic_id, source_type, source_id, source_name, cell_type, salience, content, embedding)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		c.CellID, c.TopicID, c.SourceType, c.SourceID, c.SourceName, c.CellType, c.Salience, c.Content, c.Embedding,
+		`INSERT OR REPLACE INTO cells (cell_id, topic_id, source_type, source_id, source_name, cell_type, salience, content, embedding, project)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.CellID, c.TopicID, c.SourceType, c.SourceID, c.SourceName, c.CellType, c.Salience, c.Content, c.Embedding, c.Project,
 	)
 	if err != nil {
 		return fmt.Errorf("memory: insert cell: %w", err)
@@ -84,11 +91,12 @@ func (d *DB) SupersedeCells(cellIDs []string) error {
 		placeholders[i] = "?"
 		args[i] = id
 	}
-	q := fmt.Sprintf(`UPDATE cells SET superseded = TRUE WHERE cell_id IN (%s)`, strings.Join(placeholders, ","))
+	q := fmt.Sprintf(`UPDATE cells SET superseded = TRUE, superseded_at = COALESCE(superseded_at, CURRENT_TIMESTAMP) WHERE cell_id IN (%s)`, strings.Join(placeholders, ","))
 	_, err := d.db.Exec(q, args...)
 	if err != nil {
 		return fmt.Errorf("memory: supersede cells: %w", err)
 	}
+	d.vec.markDirty(cellIDs...)
 	return nil
 }
 
@@ -98,13 +106,14 @@ func (d *DB) DeleteCellsBySource(sourceType, sourceID string) error {
 	if err != nil {
 		return fmt.Errorf("memory: delete cells by source: %w", err)
 	}
+	d.vec.removeSource(sourceType, sourceID)
 	return nil
 }
 
 // GetCellsByTopic returns cells for a topic, ordered by salience DESC.
 // If includeSuperseded is false, superseded cells are excluded.
 func (d *DB) GetCellsByTopic(topicID string, includeSuperseded bool) ([]Cell, error) {
-	q := `SELECT cell_id, topic_id, source_type, source_id, source_name, cell_type, salience, content, embedding
+	q := `SELECT cell_id, topic_id, source_type, source_id, source_name, cell_type, salience, content, embedding, superseded, project, created_at
 		  FROM cells WHERE topic_id = ?`
 	if !includeSuperseded {
 		q += ` AND superseded = FALSE`
@@ -120,7 +129,7 @@ func (d *DB) GetCellsByTopic(topicID string, includeSuperseded bool) ([]Cell, er
 	var cells []Cell
 	for rows.Next() {
 		var c Cell
-		if err := rows.Scan(&c.CellID, &c.TopicID, &c.SourceType, &c.SourceID, &c.SourceName, &c.CellType, &c.Salience, &c.Content, &c.Embedding); err != nil {
+		if err := rows.Scan(&c.CellID, &c.TopicID, &c.SourceType, &c.SourceID, &c.SourceName, &c.CellType, &c.Salience, &c.Content, &c.Embedding, &c.Superseded, &c.Project, &c.CreatedAt); err != nil {
 			return nil, fmt.Errorf("memory: scan cell: %w", err)
 		}
 		cells = append(cells, c)
@@ -147,15 +156,16 @@ func (d *DB) UnsummarizedCellCount(topicID string) (int, error) {
 // UpsertTopic inserts or updates a topic.
 func (d *DB) UpsertTopic(t Topic) error {
 	_, err := d.db.Exec(
-		`INSERT INTO topics (topic_id, name, summary, embedding, cell_count)
-		 VALUES (?, ?, ?, ?, ?)
+		`INSERT INTO topics (topic_id, name, summary, embedding, cell_count, project)
+		 VALUES (?, ?, ?, ?, ?, ?)
 		 ON CONFLICT(topic_id) DO UPDATE SET
 		   name = excluded.name,
 		   summary = excluded.summary,
 		   embedding = excluded.embedding,
 		   cell_count = excluded.cell_count,
+		   project = excluded.project,
 		   updated_at = CURRENT_TIMESTAMP`,
-		t.TopicID, t.Name, t.Summary, t.Embedding, t.CellCount,
+		t.TopicID, t.Name, t.Summary, t.Embedding, t.CellCount, t.Project,
 	)
 	if err != nil {
 		return fmt.Errorf("memory: upsert topic: %w", err)
@@ -167,9 +177,9 @@ func (d *DB) UpsertTopic(t Topic) error {
 func (d *DB) GetTopic(topicID string) (*Topic, error) {
 	var t Topic
 	err := d.db.QueryRow(
-		`SELECT topic_id, name, COALESCE(summary, ''), embedding, cell_count FROM topics WHERE topic_id = ?`,
+		`SELECT topic_id, name, COALESCE(summary, ''), embedding, cell_count, project, updated_at FROM topics WHERE topic_id = ?`,
 		topicID,
-	).Scan(&t.TopicID, &t.Name, &t.Summary, &t.Embedding, &t.CellCount)
+	).Scan(&t.TopicID, &t.Name, &t.Summary, &t.Embedding, &t.CellCount, &t.Project, &t.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -179,18 +189,32 @@ func (d *DB) GetTopic(topicID string) (*Topic, error) {
 	return &t, nil
 }
 
-// AllTopics returns all topics with their embeddings.
+// AllTopics returns all topics across all projects with their embeddings.
 func (d *DB) AllTopics() ([]Topic, error) {
-	rows, err := d.db.Query(`SELECT topic_id, name, COALESCE(summary, ''), embedding, cell_count FROM topics`)
+	rows, err := d.db.Query(`SELECT topic_id, name, COALESCE(summary, ''), embedding, cell_count, project, updated_at FROM topics`)
 	if err != nil {
 		return nil, fmt.Errorf("memory: all topics: %w", err)
 	}
+	return scanTopics(rows)
+}
+
+// ProjectTopics returns the topics belonging to a single project.
+// An empty project returns only global topics.
+func (d *DB) ProjectTopics(project string) ([]Topic, error) {
+	rows, err := d.db.Query(`SELECT topic_id, name, COALESCE(summary, ''), embedding, cell_count, project, updated_at FROM topics WHERE project = ?`, project)
+	if err != nil {
+		return nil, fmt.Errorf("memory: project topics: %w", err)
+	}
+	return scanTopics(rows)
+}
+
+func scanTopics(rows *sql.Rows) ([]Topic, error) {
 	defer rows.Close()
 
 	var topics []Topic
 	for rows.Next() {
 		var t Topic
-		if err := rows.Scan(&t.TopicID, &t.Name, &t.Summary, &t.Embedding, &t.CellCount); err != nil {
+		if err := rows.Scan(&t.TopicID, &t.Name, &t.Summary, &t.Embedding, &t.CellCount, &t.Project, &t.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("memory: scan topic: %w", err)
 		}
 		topics = append(topics, t)
@@ -200,8 +224,9 @@ func (d *DB) AllTopics() ([]Topic, error) {
 
 // SearchCellsFTS performs FTS5 search on non-superseded cells.
 // If sourceType is non-empty, results are further filtered by source_type.
-func (d *DB) SearchCellsFTS(query, sourceType string, limit int) ([]CellResult, error) {
-	q := `SELECT c.cell_id, c.topic_id, c.source_type, c.source_id, c.source_name, c.cell_type, c.salience, c.content, f.rank
+// If project is non-empty, results are limited to that project and global cells.
+func (d *DB) SearchCellsFTS(query, sourceType, project string, limit int) ([]CellResult, error) {
+	q := `SELECT c.cell_id, c.topic_id, c.source_type, c.source_id, c.source_name, c.cell_type, c.salience, c.content, c.project, f.rank
 		  FROM cells_fts f
 		  JOIN cells c ON c.rowid = f.rowid
 		  WHERE cells_fts MATCH ? AND c.superseded = FALSE`
@@ -211,6 +236,10 @@ func (d *DB) SearchCellsFTS(query, sourceType string, limit int) ([]CellResult,
 		q += ` AND c.source_type = ?`
 		args = append(args, sourceType)
 	}
+	if project != "" {
+		q += ` AND c.project IN (?, '')`
+		args = append(args, project)
+	}
 	q += ` ORDER BY f.rank LIMIT ?`
 	args = append(args, limit)
 
@@ -223,7 +252,7 @@ func (d *DB) SearchCellsFTS(query, sourceType string, limit int) ([]CellResult,
 	var results []CellResult
 	for rows.Next() {
 		var cr CellResult
-		if err := rows.Scan(&cr.CellID, &cr.TopicID, &cr.SourceType, &cr.SourceID, &cr.SourceName, &cr.CellType, &cr.Salience, &cr.Content, &cr.Score); err != nil {
+		if err := rows.Scan(&cr.CellID, &cr.TopicID, &cr.SourceType, &cr.SourceID, &cr.SourceName, &cr.CellType, &cr.Salience, &cr.Content, &cr.Project, &cr.Score); err != nil {
 			return nil, fmt.Errorf("memory: scan cell result: %w", err)
 		}
 		results = append(results, cr)
@@ -232,14 +261,22 @@ func (d *DB) SearchCellsFTS(query, sourceType string, limit int) ([]CellResult,
 }
 
 // SearchTopicsFTS performs FTS5 search on topic summaries.
-func (d *DB) SearchTopicsFTS(query string, limit int) ([]TopicResult, error) {
-	q := `SELECT t.topic_id, t.name, COALESCE(t.summary, ''), f.rank, t.updated_at
+// If project is non-empty, results are limited to that project and global topics.
+func (d *DB) SearchTopicsFTS(query, project string, limit int) ([]TopicResult, error) {
+	q := `SELECT t.topic_id, t.name, COALESCE(t.summary, ''), t.project, f.rank, t.updated_at
 		  FROM topics_fts f
 		  JOIN topics t ON t.rowid = f.rowid
-		  WHERE topics_fts MATCH ?
-		  ORDER BY f.rank LIMIT ?`
+		  WHERE topics_fts MATCH ?`
 
-	rows, err := d.db.Query(q, query, limit)
+	args := []any{query}
+	if project != "" {
+		q += ` AND t.project IN (?, '')`
+		args = append(args, project)
+	}
+	q += ` ORDER BY f.rank LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("memory: search topics fts: %w", err)
 	}
@@ -248,7 +285,7 @@ func (d *DB) SearchTopicsFTS(query string, limit int) ([]TopicResult, error) {
 	var results []TopicResult
 	for rows.Next() {
 		var tr TopicResult
-		if err := rows.Scan(&tr.TopicID, &tr.Name, &tr.Summary, &tr.Score, &tr.UpdatedAt); err != nil {
+		if err := rows.Scan(&tr.TopicID, &tr.Name, &tr.Summary, &tr.Project, &tr.Score, &tr.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("memory: scan topic result: %w", err)
 		}
 		results = append(results, tr)
@@ -47,7 +47,7 @@ func TestInsertAndSearchCells(t *testing.T) {
 	}
 
 	// Search for the cell via FTS.
-	results, err := mdb.SearchCellsFTS("JWT authentication", "", 10)
+	results, err := mdb.SearchCellsFTS("JWT authentication", "", "", 10)
 	if err != nil {
 		t.Fatalf("SearchCellsFTS: %v", err)
 	}
@@ -119,7 +119,7 @@ func TestSupersedeCells(t *testing.T) {
 	}
 
 	// FTS search should exclude superseded cells.
-	results, err := mdb.SearchCellsFTS("connection pool", "", 10)
+	results, err := mdb.SearchCellsFTS("connection pool", "", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -130,7 +130,7 @@ func TestSupersedeCells(t *testing.T) {
 	}
 
 	// Non-superseded cell should still be found.
-	results, err = mdb.SearchCellsFTS("PostgreSQL", "", 10)
+	results, err = mdb.SearchCellsFTS("PostgreSQL", "", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -177,7 +177,7 @@ func TestUpsertAndSearchTopic(t *testing.T) {
 	}
 
 	// Search for the topic.
-	results, err := mdb.SearchTopicsFTS("GitHub Actions", 10)
+	results, err := mdb.SearchTopicsFTS("GitHub Actions", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -200,7 +200,7 @@ func TestUpsertAndSearchTopic(t *testing.T) {
 	}
 
 	// Search for old summary should not match.
-	oldResults, err := mdb.SearchTopicsFTS("GitHub Actions", 10)
+	oldResults, err := mdb.SearchTopicsFTS("GitHub Actions", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -209,7 +209,7 @@ func TestUpsertAndSearchTopic(t *testing.T) {
 	}
 
 	// Search for new summary should match.
-	newResults, err := mdb.SearchTopicsFTS("Kubernetes ArgoCD", 10)
+	newResults, err := mdb.SearchTopicsFTS("Kubernetes ArgoCD", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -311,7 +311,7 @@ func TestGetCellsByTopic(t *testing.T) {
 	}
 
 	// SearchCellsFTS with sourceType filter.
-	results, err := mdb.SearchCellsFTS("TypeScript", "conversation", 10)
+	results, err := mdb.SearchCellsFTS("TypeScript", "conversation", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
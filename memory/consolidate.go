@@ -40,8 +40,21 @@ Existing summary: %s
 Cells (newest first):
 %s
 
-Return ONLY valid JSON with no code fences:
-{"summary": "...", "superseded_cell_ids": ["cell_id_1", ...]}`
+Return JSON: {"summary": "...", "superseded_cell_ids": ["cell_id_1", ...]}`
+
+// consolidationFormat is the structured output format of ConsolidateTopic.
+var consolidationFormat = &llm.ResponseFormat{
+	Name:        "topic_summary",
+	Description: "Record the updated topic summary and the superseded cells.",
+	Schema: llm.MustSchema(`{
+		"type": "object",
+		"properties": {
+			"summary": {"type": "string"},
+			"superseded_cell_ids": {"type": "array", "items": {"type": "string"}}
+		},
+		"required": ["summary", "superseded_cell_ids"]
+	}`),
+}
 
 // ConsolidateTopic uses an LLM to consolidate a topic's cells into an updated summary.
 func ConsolidateTopic(ctx context.Context, db *DB, svc llm.Service, embedder Embedder, topicID string) error {
@@ -70,22 +83,13 @@ func ConsolidateTopic(ctx context.Context, db *DB, svc llm.Service, embedder Emb
 				Content: []llm.Content{{Type: llm.ContentTypeText, Text: prompt}},
 			},
 		},
+		ResponseFormat: consolidationFormat,
 	}
 
-	resp, err := svc.Do(ctx, req)
-	if err != nil {
+	var result ConsolidationResult
+	if err := llm.DoJSON(ctx, svc, req, &result); err != nil {
 		return fmt.Errorf("memory: consolidate llm: %w", err)
 	}
-
-	var text string
-	for _, c := range resp.Content {
-		if c.Type == llm.ContentTypeText {
-			text = c.Text
-			break
-		}
-	}
-
-	result := parseConsolidationResult(text)
 	if result.Summary == "" {
 		return fmt.Errorf("memory: consolidate: empty summary from LLM")
 	}
@@ -138,14 +142,3 @@ func formatCellsForPrompt(cells []Cell) string {
 	}
 	return string(buf)
 }
-
-// parseConsolidationResult parses the LLM response into a ConsolidationResult.
-// Strips code fences and returns zero-value on parse failure.
-func parseConsolidationResult(text string) ConsolidationResult {
-	text = stripCodeFences(text)
-	var result ConsolidationResult
-	if err := json.Unmarshal([]byte(text), &result); err != nil {
-		return ConsolidationResult{}
-	}
-	return result
-}
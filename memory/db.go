@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -16,6 +18,7 @@ var schemaSQL string
 type DB struct {
 	db   *sql.DB
 	path string
+	vec  *vectorIndex
 }
 
 func Open(path string) (*DB, error) {
@@ -41,12 +44,34 @@ func Open(path string) (*DB, error) {
 		}
 	}
 
+	if err := addMissingColumns(sqldb); err != nil {
+		sqldb.Close()
+		return nil, fmt.Errorf("memory: migrate columns: %w", err)
+	}
+
+	// Rebuild the cells table if its CHECK constraints predate the current schema.
+	if cellsNeedRebuild(sqldb) {
+		if err := rebuildCellsTable(sqldb); err != nil {
+			sqldb.Close()
+			return nil, fmt.Errorf("memory: migrate cells: %w", err)
+		}
+	}
+
+	// Recreate cells_au if it predates firing only on content changes, so that
+	// salience and embedding updates don't rewrite the FTS index.
+	if triggerOutdated(sqldb, "cells_au", "UPDATE OF content") {
+		if _, err := sqldb.Exec("DROP TRIGGER cells_au"); err != nil {
+			sqldb.Close()
+			return nil, fmt.Errorf("memory: migrate triggers: %w", err)
+		}
+	}
+
 	if _, err := sqldb.Exec(schemaSQL); err != nil {
 		sqldb.Close()
 		return nil, fmt.Errorf("memory: schema: %w", err)
 	}
 
-	return &DB{db: sqldb, path: path}, nil
+	return &DB{db: sqldb, path: path, vec: newVectorIndex()}, nil
 }
 
 func (d *DB) Close() error {
@@ -84,3 +109,139 @@ func migrateFromOldSchema(db *sql.DB) error {
 	}
 	return nil
 }
+
+// cellsNeedRebuild returns true if the cells table exists but does not accept
+// the 'manual' source type. SQLite cannot alter CHECK constraints in place.
+func cellsNeedRebuild(db *sql.DB) bool {
+	var ddl string
+	err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='cells'").Scan(&ddl)
+	if err != nil {
+		return false
+	}
+	return !strings.Contains(ddl, "'manual'")
+}
+
+// rebuildCellsTable recreates the cells table (and its FTS index, triggers and
+// indexes) from the current schema, copying over all existing rows.
+func rebuildCellsTable(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		"DROP TRIGGER IF EXISTS cells_ai",
+		"DROP TRIGGER IF EXISTS cells_ad",
+		"DROP TRIGGER IF EXISTS cells_au",
+		"DROP TABLE IF EXISTS cells_fts",
+		"DROP INDEX IF EXISTS idx_cells_source",
+		"DROP INDEX IF EXISTS idx_cells_topic",
+		"DROP INDEX IF EXISTS idx_cells_project",
+		"ALTER TABLE cells RENAME TO cells_old",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+
+	if _, err := tx.Exec(schemaSQL); err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+
+	oldCols, err := tableColumns(tx, "cells_old")
+	if err != nil {
+		return err
+	}
+	newCols, err := tableColumns(tx, "cells")
+	if err != nil {
+		return err
+	}
+	var shared []string
+	for _, c := range oldCols {
+		if slices.Contains(newCols, c) {
+			shared = append(shared, c)
+		}
+	}
+	cols := strings.Join(shared, ", ")
+	if _, err := tx.Exec(fmt.Sprintf("INSERT INTO cells (%s) SELECT %s FROM cells_old", cols, cols)); err != nil {
+		return fmt.Errorf("copy cells: %w", err)
+	}
+	if _, err := tx.Exec("DROP TABLE cells_old"); err != nil {
+		return fmt.Errorf("drop cells_old: %w", err)
+	}
+	return tx.Commit()
+}
+
+// triggerOutdated returns true if the named trigger exists but its definition
+// does not contain want.
+func triggerOutdated(db *sql.DB, name, want string) bool {
+	var ddl string
+	err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type='trigger' AND name=?", name).Scan(&ddl)
+	if err != nil {
+		return false
+	}
+	return !strings.Contains(ddl, want)
+}
+
+// addedColumns lists columns added to existing tables after their first release.
+// existing, if set, is the value given to rows that predate the column.
+var addedColumns = []struct{ table, column, ddl, existing string }{
+	{"cells", "project", "project TEXT NOT NULL DEFAULT ''", LegacyProject},
+	{"topics", "project", "project TEXT NOT NULL DEFAULT ''", LegacyProject},
+	{"cells", "superseded_at", "superseded_at DATETIME", ""},
+	{"cells", "last_used_at", "last_used_at DATETIME", ""},
+	{"cells", "decayed_at", "decayed_at DATETIME", ""},
+}
+
+// addMissingColumns adds any of addedColumns missing from existing tables.
+// It must run before the schema is applied, since the schema indexes them.
+func addMissingColumns(db *sql.DB) error {
+	for _, ac := range addedColumns {
+		cols, err := tableColumns(db, ac.table)
+		if err != nil {
+			return err
+		}
+		if len(cols) == 0 || slices.Contains(cols, ac.column) {
+			continue // table not created yet, or already migrated
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", ac.table, ac.ddl)); err != nil {
+			return fmt.Errorf("add %s.%s: %w", ac.table, ac.column, err)
+		}
+		if ac.existing != "" {
+			if _, err := db.Exec(fmt.Sprintf("UPDATE %s SET %s = ?", ac.table, ac.column), ac.existing); err != nil {
+				return fmt.Errorf("fill %s.%s: %w", ac.table, ac.column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// tableColumns returns the column names of a table in declaration order.
+func tableColumns(q interface {
+	Query(string, ...any) (*sql.Rows, error)
+}, table string) ([]string, error) {
+	rows, err := q.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("table info %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			typ     string
+			notNull bool
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, fmt.Errorf("scan table info %s: %w", table, err)
+		}
+		cols = append(cols, name)
+	}
+	return cols, rows.Err()
+}
@@ -163,3 +163,50 @@ func TestMigrateFromOldSchema(t *testing.T) {
 		t.Error("index_state should be cleared during migration")
 	}
 }
+
+func TestRebuildCellsTableAllowsManual(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "memory.db")
+
+	// Create a cells table with the original CHECK constraint.
+	sqldb, err := sql.Open("sqlite", dbPath+"?_journal_mode=WAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sqldb.Exec(`CREATE TABLE cells (
+		cell_id TEXT PRIMARY KEY, topic_id TEXT,
+		source_type TEXT NOT NULL CHECK(source_type IN ('conversation', 'file')),
+		source_id TEXT NOT NULL, source_name TEXT,
+		cell_type TEXT NOT NULL, salience REAL NOT NULL DEFAULT 0.5, content TEXT NOT NULL,
+		embedding BLOB, created_at DATETIME DEFAULT CURRENT_TIMESTAMP, superseded BOOLEAN DEFAULT FALSE
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sqldb.Exec(`INSERT INTO cells (cell_id, topic_id, source_type, source_id, source_name, cell_type, content)
+		VALUES ('old1', '', 'conversation', 'conv_1', 'legacy', 'fact', 'legacy cell about widgets')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.Close()
+
+	mdb, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+
+	_, err = mdb.db.Exec(`INSERT INTO cells (cell_id, source_type, source_id, cell_type, content)
+		VALUES ('m1', 'manual', 'user', 'fact', 'manual cell')`)
+	if err != nil {
+		t.Fatalf("manual source_type should be accepted after rebuild: %v", err)
+	}
+
+	results, err := mdb.SearchCellsFTS("widgets", "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].CellID != "old1" {
+		t.Errorf("expected legacy cell to survive rebuild and be searchable, got %+v", results)
+	}
+}
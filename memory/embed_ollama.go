@@ -65,3 +65,6 @@ func (o *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 // Dimension returns 0 because the dimension is model-dependent and unknown
 // until the first embedding call.
 func (o *OllamaEmbedder) Dimension() int { return 0 }
+
+// Model returns the embedding model name.
+func (o *OllamaEmbedder) Model() string { return o.model }
@@ -79,3 +79,6 @@ func (o *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 
 // Dimension returns 1536, the output dimension of text-embedding-3-small.
 func (o *OpenAIEmbedder) Dimension() int { return 1536 }
+
+// Model returns the embedding model name.
+func (o *OpenAIEmbedder) Model() string { return o.model }
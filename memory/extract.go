@@ -2,7 +2,7 @@ package memory
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -28,7 +28,7 @@ var validCellTypes = map[string]bool{
 
 const extractionPrompt = `You are a memory extraction engine for a coding assistant called Percy. Convert the conversation below into structured memory cells — atomic units of knowledge useful for FUTURE conversations.
 
-Return a JSON array. Each object must have:
+Return a JSON object with a "cells" array. Each cell must have:
 - cell_type: one of (fact, decision, preference, task, risk, code_ref)
 - salience: 0.0-1.0 (how important for future work?)
 - content: compressed, factual statement (1-2 sentences max)
@@ -44,11 +44,38 @@ Cell types:
 
 Focus on information useful in FUTURE conversations. Discard: greetings, debugging dead-ends (keep only final fix), intermediate states that were overwritten, routine acknowledgments, verbose tool output (keep only findings).
 
-If the conversation is trivial (greetings, simple Q&A with no lasting value), return an empty array: []
+If the conversation is trivial (greetings, simple Q&A with no lasting value), return no cells: {"cells": []}
 
 Conversation:
 %s`
 
+// extractionFormat is the structured output format of ExtractCells. Cell
+// types and salience are not constrained by the schema; ExtractCells fixes
+// them up instead of asking for a repair.
+var extractionFormat = &llm.ResponseFormat{
+	Name:        "memory_cells",
+	Description: "Record the memory cells extracted from the conversation.",
+	Schema: llm.MustSchema(`{
+		"type": "object",
+		"properties": {
+			"cells": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"properties": {
+						"cell_type": {"type": "string", "description": "fact, decision, preference, task, risk or code_ref"},
+						"salience": {"type": "number", "description": "0.0-1.0"},
+						"content": {"type": "string"},
+						"topic_hint": {"type": "string"}
+					},
+					"required": ["cell_type", "salience", "content", "topic_hint"]
+				}
+			}
+		},
+		"required": ["cells"]
+	}`),
+}
+
 // ExtractCells calls the LLM to extract structured cells from a conversation.
 // Returns nil, nil on unparseable output (graceful degradation).
 func ExtractCells(ctx context.Context, svc llm.Service, messages []MessageText) ([]ExtractedCell, error) {
@@ -56,41 +83,27 @@ func ExtractCells(ctx context.Context, svc llm.Service, messages []MessageText)
 	prompt := fmt.Sprintf(extractionPrompt, transcript)
 
 	req := &llm.Request{
-		System: []llm.SystemContent{{Text: "You extract structured memory cells from conversations. Respond only with a JSON array."}},
+		System: []llm.SystemContent{{Text: "You extract structured memory cells from conversations. Respond only with JSON."}},
 		Messages: []llm.Message{
 			{
 				Role:    llm.MessageRoleUser,
 				Content: []llm.Content{{Type: llm.ContentTypeText, Text: prompt}},
 			},
 		},
+		ResponseFormat: extractionFormat,
 	}
 
-	resp, err := svc.Do(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("memory: extract cells: %w", err)
+	var out struct {
+		Cells []ExtractedCell `json:"cells"`
 	}
-
-	// Find the text content in the response.
-	var text string
-	for _, c := range resp.Content {
-		if c.Type == llm.ContentTypeText {
-			text = c.Text
-			break
+	if err := llm.DoJSON(ctx, svc, req, &out); err != nil {
+		if errors.Is(err, llm.ErrInvalidOutput) {
+			// Graceful degradation: return nil, nil on bad JSON.
+			return nil, nil
 		}
+		return nil, fmt.Errorf("memory: extract cells: %w", err)
 	}
-
-	if text == "" {
-		return nil, nil
-	}
-
-	// Strip code fences if present.
-	text = stripCodeFences(text)
-
-	var raw []ExtractedCell
-	if err := json.Unmarshal([]byte(text), &raw); err != nil {
-		// Graceful degradation: return nil, nil on bad JSON.
-		return nil, nil
-	}
+	raw := out.Cells
 
 	var cells []ExtractedCell
 	for _, c := range raw {
@@ -145,20 +158,3 @@ func formatTranscript(messages []MessageText) string {
 	}
 	return buf.String()
 }
-
-// stripCodeFences removes ```json ... ``` wrapping from LLM output.
-func stripCodeFences(s string) string {
-	s = strings.TrimSpace(s)
-	if strings.HasPrefix(s, "```") {
-		// Remove opening fence line.
-		if idx := strings.Index(s, "\n"); idx != -1 {
-			s = s[idx+1:]
-		}
-		// Remove closing fence.
-		if idx := strings.LastIndex(s, "```"); idx != -1 {
-			s = s[:idx]
-		}
-		s = strings.TrimSpace(s)
-	}
-	return s
-}
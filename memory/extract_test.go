@@ -22,11 +22,11 @@ func (m *mockLLMService) MaxImageDimension() int  { return 0 }
 
 func TestExtractCells(t *testing.T) {
 	mock := &mockLLMService{
-		response: `[
+		response: `{"cells": [
 			{"cell_type": "fact", "salience": 0.9, "content": "Percy uses SQLite with sqlc codegen", "topic_hint": "database"},
 			{"cell_type": "decision", "salience": 0.7, "content": "Switched to argon2id because bcrypt was too slow", "topic_hint": "authentication"},
 			{"cell_type": "preference", "salience": 0.5, "content": "User prefers tabs over spaces", "topic_hint": "code style"}
-		]`,
+		]}`,
 	}
 
 	msgs := []memory.MessageText{
@@ -108,7 +108,7 @@ func TestExtractCellsFallbackChunking(t *testing.T) {
 
 func TestExtractCellsStripsCodeFences(t *testing.T) {
 	mock := &mockLLMService{
-		response: "```json\n" + `[{"cell_type": "fact", "salience": 0.8, "content": "Uses esbuild for bundling", "topic_hint": "build"}]` + "\n```",
+		response: "```json\n" + `{"cells": [{"cell_type": "fact", "salience": 0.8, "content": "Uses esbuild for bundling", "topic_hint": "build"}]}` + "\n```",
 	}
 
 	msgs := []memory.MessageText{
@@ -129,12 +129,12 @@ func TestExtractCellsStripsCodeFences(t *testing.T) {
 
 func TestExtractCellsValidation(t *testing.T) {
 	mock := &mockLLMService{
-		response: `[
+		response: `{"cells": [
 			{"cell_type": "invalid_type", "salience": 1.5, "content": "some content", "topic_hint": "test"},
 			{"cell_type": "fact", "salience": -0.3, "content": "negative salience", "topic_hint": "test"},
 			{"cell_type": "", "salience": 0.5, "content": "empty type", "topic_hint": "test"},
 			{"cell_type": "fact", "salience": 0.5, "content": "", "topic_hint": "test"}
-		]`,
+		]}`,
 	}
 
 	msgs := []memory.MessageText{
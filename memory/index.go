@@ -57,7 +57,8 @@ func (d *DB) SetIndexState(sourceType, sourceID, hash string) error {
 
 // IndexConversation indexes a conversation using LLM-powered extraction.
 // Falls back to chunk-based indexing if svc is nil.
-func (d *DB) IndexConversation(ctx context.Context, conversationID, slug string, messages []MessageText, embedder Embedder, svc llm.Service) error {
+// Cells and topics are scoped to project (see ProjectID); empty means global.
+func (d *DB) IndexConversation(ctx context.Context, conversationID, slug, project string, messages []MessageText, embedder Embedder, svc llm.Service) error {
 	hash := hashMessages(messages)
 
 	indexed, err := d.IsIndexed("conversation", conversationID, hash)
@@ -91,7 +92,7 @@ func (d *DB) IndexConversation(ctx context.Context, conversationID, slug string,
 	}
 
 	// Assign cells to topics.
-	assigned, err := AssignCellsToTopics(ctx, d, extracted, embedder)
+	assigned, err := AssignCellsToTopics(ctx, d, project, extracted, embedder)
 	if err != nil {
 		return fmt.Errorf("memory: index assign topics: %w", err)
 	}
@@ -119,6 +120,7 @@ func (d *DB) IndexConversation(ctx context.Context, conversationID, slug string,
 			Salience:   ac.Salience,
 			Content:    ac.Content,
 			Embedding:  embBlob,
+			Project:    project,
 		}
 		if err := d.InsertCell(cell); err != nil {
 			return err
@@ -148,9 +150,9 @@ func (d *DB) IndexConversation(ctx context.Context, conversationID, slug string,
 	return d.SetIndexState("conversation", conversationID, hash)
 }
 
-// IndexFile indexes a file's content into the cells table.
+// IndexFile indexes a file's content into the cells table, scoped to project.
 // It skips re-indexing when the content hash has not changed.
-func (d *DB) IndexFile(ctx context.Context, filePath, fileName, content string, embedder Embedder) error {
+func (d *DB) IndexFile(ctx context.Context, filePath, fileName, project, content string, embedder Embedder) error {
 	hash := hashString(content)
 
 	indexed, err := d.IsIndexed("file", filePath, hash)
@@ -176,11 +178,8 @@ func (d *DB) IndexFile(ctx context.Context, filePath, fileName, content string,
 		embeddings, _ = embedder.Embed(ctx, texts)
 	}
 
-	// Use first 8 chars of the hash for cell IDs.
-	short := hash
-	if len(short) > 8 {
-		short = short[:8]
-	}
+	// Key cell IDs by path so files with identical content don't collide.
+	short := hashString(filePath)[:8]
 
 	for i, c := range chunks {
 		cellID := fmt.Sprintf("file_%s_%d", short, i)
@@ -197,6 +196,7 @@ func (d *DB) IndexFile(ctx context.Context, filePath, fileName, content string,
 			Salience:   0.5,
 			Content:    c.Text,
 			Embedding:  embBlob,
+			Project:    project,
 		}
 		if err := d.InsertCell(cell); err != nil {
 			return err
@@ -25,13 +25,13 @@ func TestIndexConversation(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	err = mdb.IndexConversation(ctx, "conv-abc", "Auth Discussion", messages, nil, nil)
+	err = mdb.IndexConversation(ctx, "conv-abc", "Auth Discussion", "", messages, nil, nil)
 	if err != nil {
 		t.Fatalf("IndexConversation: %v", err)
 	}
 
 	// Verify TwoTierSearch finds the indexed content.
-	results, err := mdb.TwoTierSearch("authentication", nil, "conversation", 10)
+	results, err := mdb.TwoTierSearch("authentication", nil, "conversation", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch: %v", err)
 	}
@@ -68,17 +68,17 @@ func TestIndexConversationSkipsIfUnchanged(t *testing.T) {
 	ctx := context.Background()
 
 	// First index.
-	if err := mdb.IndexConversation(ctx, "conv-1", "K8s Chat", messages, nil, nil); err != nil {
+	if err := mdb.IndexConversation(ctx, "conv-1", "K8s Chat", "", messages, nil, nil); err != nil {
 		t.Fatalf("first IndexConversation: %v", err)
 	}
 
 	// Second index with same content should succeed (skip).
-	if err := mdb.IndexConversation(ctx, "conv-1", "K8s Chat", messages, nil, nil); err != nil {
+	if err := mdb.IndexConversation(ctx, "conv-1", "K8s Chat", "", messages, nil, nil); err != nil {
 		t.Fatalf("second IndexConversation: %v", err)
 	}
 
 	// Verify content is still searchable after the skip.
-	results, err := mdb.TwoTierSearch("Kubernetes", nil, "conversation", 10)
+	results, err := mdb.TwoTierSearch("Kubernetes", nil, "conversation", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch: %v", err)
 	}
@@ -109,13 +109,13 @@ The HTTP middleware validates incoming bearer tokens and rejects expired ones.
 `
 
 	ctx := context.Background()
-	err = mdb.IndexFile(ctx, "/src/auth.go", "auth.go", content, nil)
+	err = mdb.IndexFile(ctx, "/src/auth.go", "auth.go", "", content, nil)
 	if err != nil {
 		t.Fatalf("IndexFile: %v", err)
 	}
 
 	// Verify SearchCellsFTS finds the indexed file content.
-	results, err := mdb.SearchCellsFTS("authentication", "file", 10)
+	results, err := mdb.SearchCellsFTS("authentication", "file", "", 10)
 	if err != nil {
 		t.Fatalf("SearchCellsFTS: %v", err)
 	}
@@ -177,7 +177,7 @@ func TestIndexConversationWithExtraction(t *testing.T) {
 			TopicHint: "session management",
 		},
 	}
-	cellsJSON, err := json.Marshal(cells)
+	cellsJSON, err := json.Marshal(map[string]any{"cells": cells})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -185,13 +185,13 @@ func TestIndexConversationWithExtraction(t *testing.T) {
 	mockSvc := &mockLLMForIndex{response: string(cellsJSON)}
 	ctx := context.Background()
 
-	err = mdb.IndexConversation(ctx, "conv-v2-1", "Auth Chat", messages, nil, mockSvc)
+	err = mdb.IndexConversation(ctx, "conv-v2-1", "Auth Chat", "", messages, nil, mockSvc)
 	if err != nil {
 		t.Fatalf("IndexConversation: %v", err)
 	}
 
 	// Verify cells are searchable via TwoTierSearch.
-	results, err := mdb.TwoTierSearch("authentication", nil, "", 10)
+	results, err := mdb.TwoTierSearch("authentication", nil, "", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch: %v", err)
 	}
@@ -209,13 +209,13 @@ func TestIndexConversationWithExtraction(t *testing.T) {
 	}
 
 	// Verify re-indexing with same content is a no-op (hash match).
-	err = mdb.IndexConversation(ctx, "conv-v2-1", "Auth Chat", messages, nil, mockSvc)
+	err = mdb.IndexConversation(ctx, "conv-v2-1", "Auth Chat", "", messages, nil, mockSvc)
 	if err != nil {
 		t.Fatalf("re-index IndexConversation: %v", err)
 	}
 
 	// Verify still searchable after no-op re-index.
-	results2, err := mdb.TwoTierSearch("authentication", nil, "", 10)
+	results2, err := mdb.TwoTierSearch("authentication", nil, "", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch after re-index: %v", err)
 	}
@@ -240,13 +240,13 @@ func TestIndexConversationFallbackWithoutLLM(t *testing.T) {
 	ctx := context.Background()
 
 	// Pass nil for svc — should fall back to chunk-based cell creation.
-	err = mdb.IndexConversation(ctx, "conv-v2-fallback", "K8s Scheduling", messages, nil, nil)
+	err = mdb.IndexConversation(ctx, "conv-v2-fallback", "K8s Scheduling", "", messages, nil, nil)
 	if err != nil {
 		t.Fatalf("IndexConversation fallback: %v", err)
 	}
 
 	// Verify cells are searchable via TwoTierSearch.
-	results, err := mdb.TwoTierSearch("Kubernetes", nil, "", 10)
+	results, err := mdb.TwoTierSearch("Kubernetes", nil, "", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch: %v", err)
 	}
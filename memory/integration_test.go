@@ -27,7 +27,7 @@ func TestIntegrationFullFlow(t *testing.T) {
 		{Role: "user", Text: "Can you show me middleware for HTTP handlers?"},
 		{Role: "assistant", Text: "Here is an authentication middleware that extracts and validates the bearer token."},
 	}
-	if err := mdb.IndexConversation(ctx, "conv-auth", "Auth Discussion", authMessages, embedder, nil); err != nil {
+	if err := mdb.IndexConversation(ctx, "conv-auth", "Auth Discussion", "", authMessages, embedder, nil); err != nil {
 		t.Fatalf("IndexConversation (auth): %v", err)
 	}
 
@@ -50,12 +50,12 @@ The middleware validates tokens on every protected API route.
 
 We use PostgreSQL for persistent storage with connection pooling.
 `
-	if err := mdb.IndexFile(ctx, "/docs/README.md", "README.md", fileContent, embedder); err != nil {
+	if err := mdb.IndexFile(ctx, "/docs/README.md", "README.md", "", fileContent, embedder); err != nil {
 		t.Fatalf("IndexFile: %v", err)
 	}
 
 	// Step 3: Search "authentication JWT" — verify results found.
-	results, err := mdb.TwoTierSearch("authentication JWT", nil, "", 10)
+	results, err := mdb.TwoTierSearch("authentication JWT", nil, "", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch (authentication JWT): %v", err)
 	}
@@ -68,7 +68,7 @@ We use PostgreSQL for persistent storage with connection pooling.
 	}
 
 	// Step 4: Search "JWT" with sourceType "file" — verify results found and all cells have SourceType "file".
-	fileResults, err := mdb.TwoTierSearch("JWT", nil, "file", 10)
+	fileResults, err := mdb.TwoTierSearch("JWT", nil, "file", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch (JWT, file): %v", err)
 	}
@@ -83,12 +83,12 @@ We use PostgreSQL for persistent storage with connection pooling.
 	t.Logf("search 'JWT' (file only) returned %d results", len(fileResults))
 
 	// Step 5: Re-index same conversation — verify no error (hash skip).
-	if err := mdb.IndexConversation(ctx, "conv-auth", "Auth Discussion", authMessages, embedder, nil); err != nil {
+	if err := mdb.IndexConversation(ctx, "conv-auth", "Auth Discussion", "", authMessages, embedder, nil); err != nil {
 		t.Fatalf("re-index same conversation: %v", err)
 	}
 
 	// Verify content is still searchable after the skip.
-	afterSkip, err := mdb.TwoTierSearch("authentication", nil, "", 10)
+	afterSkip, err := mdb.TwoTierSearch("authentication", nil, "", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch after re-index: %v", err)
 	}
@@ -103,12 +103,12 @@ We use PostgreSQL for persistent storage with connection pooling.
 		{Role: "user", Text: "What about schema migrations?"},
 		{Role: "assistant", Text: "Use a migration tool like golang-migrate to version your database schema changes."},
 	}
-	if err := mdb.IndexConversation(ctx, "conv-db", "Database Design", dbMessages, embedder, nil); err != nil {
+	if err := mdb.IndexConversation(ctx, "conv-db", "Database Design", "", dbMessages, embedder, nil); err != nil {
 		t.Fatalf("IndexConversation (db): %v", err)
 	}
 
 	// Step 7: Search "database schema" — verify results found.
-	dbResults, err := mdb.TwoTierSearch("database schema", nil, "", 10)
+	dbResults, err := mdb.TwoTierSearch("database schema", nil, "", "", 10)
 	if err != nil {
 		t.Fatalf("TwoTierSearch (database schema): %v", err)
 	}
@@ -147,11 +147,11 @@ func TestFullMemoryPipeline(t *testing.T) {
 	ctx := context.Background()
 
 	// Mock LLM returns extraction results.
-	extractResp, _ := json.Marshal([]ExtractedCell{
+	extractResp, _ := json.Marshal(map[string][]ExtractedCell{"cells": {
 		{CellType: "decision", Salience: 0.9, Content: "Auth uses JWT with RS256", TopicHint: "authentication"},
 		{CellType: "code_ref", Salience: 0.7, Content: "server/auth.go handles JWT middleware", TopicHint: "authentication"},
 		{CellType: "fact", Salience: 0.6, Content: "UI built with React and TypeScript", TopicHint: "frontend"},
-	})
+	}})
 	svc := &mockLLMForIntegration{responses: []string{string(extractResp)}}
 
 	// Step 1: Index a conversation with LLM extraction.
@@ -159,13 +159,13 @@ func TestFullMemoryPipeline(t *testing.T) {
 		{Role: "user", Text: "Implement JWT auth"},
 		{Role: "assistant", Text: "Done — JWT with RS256 in server/auth.go. UI updated with React."},
 	}
-	err = mdb.IndexConversation(ctx, "conv_1", "auth-impl", messages, nil, svc)
+	err = mdb.IndexConversation(ctx, "conv_1", "auth-impl", "", messages, nil, svc)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Step 2: Verify cells are searchable.
-	results, err := mdb.TwoTierSearch("JWT", nil, "", 10)
+	results, err := mdb.TwoTierSearch("JWT", nil, "", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -192,7 +192,7 @@ func TestFullMemoryPipeline(t *testing.T) {
 
 	// Step 4: Re-indexing with same content should be a no-op.
 	callsBefore := svc.callCount
-	err = mdb.IndexConversation(ctx, "conv_1", "auth-impl", messages, nil, svc)
+	err = mdb.IndexConversation(ctx, "conv_1", "auth-impl", "", messages, nil, svc)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -201,11 +201,11 @@ func TestFullMemoryPipeline(t *testing.T) {
 	}
 
 	// Step 5: Index a file and verify it's searchable.
-	err = mdb.IndexFile(ctx, "/docs/README.md", "README.md", "# Auth\n\nJWT tokens for API access.\n", nil)
+	err = mdb.IndexFile(ctx, "/docs/README.md", "README.md", "", "# Auth\n\nJWT tokens for API access.\n", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fileResults, err := mdb.TwoTierSearch("JWT tokens", nil, "file", 10)
+	fileResults, err := mdb.TwoTierSearch("JWT tokens", nil, "file", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -214,7 +214,7 @@ func TestFullMemoryPipeline(t *testing.T) {
 	}
 
 	// Step 6: Verify cross-type search returns both sources.
-	allResults, err := mdb.TwoTierSearch("JWT", nil, "", 10)
+	allResults, err := mdb.TwoTierSearch("JWT", nil, "", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCellUpdate is returned by UpdateCell when the update itself is
+// invalid, as opposed to a storage failure.
+var ErrInvalidCellUpdate = errors.New("invalid cell update")
+
+// CellUpdate describes an edit to a cell. Nil fields are left unchanged.
+type CellUpdate struct {
+	Content  *string
+	CellType *string
+	Salience *float64
+}
+
+// UpdateCell applies upd to a cell and returns the updated cell.
+// When the content changes and an embedder is provided, the cell is re-embedded.
+// Returns nil, nil if the cell does not exist.
+func (d *DB) UpdateCell(ctx context.Context, cellID string, upd CellUpdate, embedder Embedder) (*Cell, error) {
+	c, err := d.GetCell(cellID)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	if upd.CellType != nil {
+		if !validCellTypes[*upd.CellType] {
+			return nil, fmt.Errorf("memory: update cell: %w: invalid cell type %q", ErrInvalidCellUpdate, *upd.CellType)
+		}
+		c.CellType = *upd.CellType
+	}
+	if upd.Salience != nil {
+		if *upd.Salience < 0 || *upd.Salience > 1 {
+			return nil, fmt.Errorf("memory: update cell: %w: salience must be between 0 and 1", ErrInvalidCellUpdate)
+		}
+		c.Salience = *upd.Salience
+	}
+	if upd.Content != nil {
+		content := strings.TrimSpace(*upd.Content)
+		if content == "" {
+			return nil, fmt.Errorf("memory: update cell: %w: content is required", ErrInvalidCellUpdate)
+		}
+		if content != c.Content {
+			c.Content = content
+			c.Embedding = nil
+			if embedder != nil {
+				vecs, embedErr := embedder.Embed(ctx, []string{content})
+				if embedErr == nil && len(vecs) > 0 && vecs[0] != nil {
+					c.Embedding = SerializeEmbedding(vecs[0])
+				}
+			}
+		}
+	}
+
+	_, err = d.db.Exec(
+		`UPDATE cells SET cell_type = ?, salience = ?, content = ?, embedding = ? WHERE cell_id = ?`,
+		c.CellType, c.Salience, c.Content, c.Embedding, c.CellID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("memory: update cell: %w", err)
+	}
+	d.vec.markDirty(cellID)
+	return c, nil
+}
+
+// DeleteCell permanently removes a cell. Returns false if it did not exist.
+func (d *DB) DeleteCell(cellID string) (bool, error) {
+	c, err := d.GetCell(cellID)
+	if err != nil || c == nil {
+		return false, err
+	}
+	if _, err := d.db.Exec(`DELETE FROM cells WHERE cell_id = ?`, cellID); err != nil {
+		return false, fmt.Errorf("memory: delete cell: %w", err)
+	}
+	d.vec.markDirty(cellID)
+	if c.TopicID != "" {
+		if err := d.refreshTopicCellCount(c.TopicID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ClearIndexState forgets the stored content hash for a source so that the
+// next IndexConversation or IndexFile call re-indexes it.
+func (d *DB) ClearIndexState(sourceType, sourceID string) error {
+	_, err := d.db.Exec(`DELETE FROM index_state WHERE source_type = ? AND source_id = ?`, sourceType, sourceID)
+	if err != nil {
+		return fmt.Errorf("memory: clear index state: %w", err)
+	}
+	return nil
+}
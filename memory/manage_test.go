@@ -0,0 +1,106 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func TestUpdateCell(t *testing.T) {
+	mdb := openTestDB(t)
+	ctx := context.Background()
+
+	err := mdb.InsertCell(memory.Cell{
+		CellID: "c1", SourceType: "conversation", SourceID: "conv-1",
+		CellType: "fact", Salience: 0.5, Content: "the build uses make",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := "the build uses mage"
+	salience := 0.8
+	cell, err := mdb.UpdateCell(ctx, "c1", memory.CellUpdate{Content: &content, Salience: &salience}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cell.Content != content || cell.Salience != 0.8 || cell.CellType != "fact" {
+		t.Errorf("unexpected updated cell: %+v", cell)
+	}
+
+	// The FTS index follows the new content.
+	results, err := mdb.SearchCellsFTS("mage", "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected updated content to be searchable, got %d results", len(results))
+	}
+
+	bad := "gossip"
+	if _, err := mdb.UpdateCell(ctx, "c1", memory.CellUpdate{CellType: &bad}, nil); err == nil {
+		t.Error("expected error for invalid cell type")
+	}
+	tooHigh := 1.5
+	if _, err := mdb.UpdateCell(ctx, "c1", memory.CellUpdate{Salience: &tooHigh}, nil); err == nil {
+		t.Error("expected error for out-of-range salience")
+	}
+
+	missing, err := mdb.UpdateCell(ctx, "missing", memory.CellUpdate{Salience: &salience}, nil)
+	if err != nil || missing != nil {
+		t.Errorf("expected nil, nil for missing cell, got %+v, %v", missing, err)
+	}
+}
+
+func TestDeleteCell(t *testing.T) {
+	mdb := openTestDB(t)
+
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "t1", Name: "build"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"c1", "c2"} {
+		err := mdb.InsertCell(memory.Cell{
+			CellID: id, TopicID: "t1", SourceType: "conversation", SourceID: "conv-1",
+			CellType: "fact", Salience: 0.5, Content: "the build uses make " + id,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := mdb.DeleteCell("c1")
+	if err != nil || !deleted {
+		t.Fatalf("expected c1 deleted, got %v, %v", deleted, err)
+	}
+	deleted, err = mdb.DeleteCell("c1")
+	if err != nil || deleted {
+		t.Errorf("expected second delete to report false, got %v, %v", deleted, err)
+	}
+
+	topic, err := mdb.GetTopic("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.CellCount != 1 {
+		t.Errorf("cell_count = %d, want 1", topic.CellCount)
+	}
+}
+
+func TestClearIndexState(t *testing.T) {
+	mdb := openTestDB(t)
+
+	if err := mdb.SetIndexState("conversation", "conv-1", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mdb.ClearIndexState("conversation", "conv-1"); err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := mdb.IsIndexed("conversation", "conv-1", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexed {
+		t.Error("expected index state to be cleared")
+	}
+}
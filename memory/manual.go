@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// manualSalience is the default salience for explicitly saved memories.
+// They rank above extracted cells because the user or agent asked for them.
+const manualSalience = 0.9
+
+// ManualMemory is a memory explicitly saved by the user or the agent,
+// as opposed to one extracted after a conversation ends.
+type ManualMemory struct {
+	CellType   string
+	Content    string
+	TopicHint  string
+	Salience   float64  // 0 means manualSalience
+	SourceID   string   // conversation that saved the memory, if any
+	SourceName string   // conversation slug, if any
+	Project    string   // project identity; empty saves a global memory
+	Supersedes []string // cell IDs replaced by this memory
+}
+
+// SaveManualMemory stores m as a new cell with source_type "manual", assigns it
+// to a topic, and supersedes any cells listed in m.Supersedes.
+// The cell is searchable immediately.
+func (d *DB) SaveManualMemory(ctx context.Context, m ManualMemory, embedder Embedder) (Cell, error) {
+	content := strings.TrimSpace(m.Content)
+	if content == "" {
+		return Cell{}, fmt.Errorf("memory: save: content is required")
+	}
+	cellType := m.CellType
+	if cellType == "" {
+		cellType = "fact"
+	}
+	if !validCellTypes[cellType] {
+		return Cell{}, fmt.Errorf("memory: save: invalid cell type %q", cellType)
+	}
+	salience := m.Salience
+	if salience <= 0 {
+		salience = manualSalience
+	}
+	if salience > 1 {
+		salience = 1
+	}
+	hint := strings.TrimSpace(m.TopicHint)
+	if hint == "" {
+		hint = cellType
+	}
+
+	// Embed once for both topic assignment and the cell. A failed embedding
+	// is not fatal: the cell is saved without one and found by keyword search.
+	var embeddings [][]float32
+	var embBlob []byte
+	if embedder != nil {
+		vecs, embedErr := embedder.Embed(ctx, []string{content})
+		if embedErr == nil && len(vecs) > 0 && vecs[0] != nil {
+			embeddings = vecs[:1]
+			embBlob = SerializeEmbedding(vecs[0])
+		}
+	}
+
+	assigned, err := assignEmbeddedCells(d, m.Project, []ExtractedCell{{
+		CellType:  cellType,
+		Salience:  salience,
+		Content:   content,
+		TopicHint: hint,
+	}}, embeddings)
+	if err != nil {
+		return Cell{}, fmt.Errorf("memory: save: %w", err)
+	}
+
+	sourceID := m.SourceID
+	if sourceID == "" {
+		sourceID = "user"
+	}
+	cell := Cell{
+		CellID:     "manual_" + uuid.New().String()[:8],
+		TopicID:    assigned[0].TopicID,
+		SourceType: "manual",
+		SourceID:   sourceID,
+		SourceName: m.SourceName,
+		CellType:   cellType,
+		Salience:   salience,
+		Content:    content,
+		Embedding:  embBlob,
+		Project:    m.Project,
+	}
+	if err := d.InsertCell(cell); err != nil {
+		return Cell{}, err
+	}
+
+	if len(m.Supersedes) > 0 {
+		if _, err := d.ForgetCells("", m.Supersedes); err != nil {
+			return Cell{}, err
+		}
+	}
+	return cell, nil
+}
+
+// ForgetCells marks the given cells as superseded so they no longer appear in
+// search results. It returns the cells that were actually forgotten; IDs
+// LiveCells doesn't return for project are ignored.
+func (d *DB) ForgetCells(project string, cellIDs []string) ([]Cell, error) {
+	forgotten, err := d.LiveCells(project, cellIDs)
+	if err != nil || len(forgotten) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, len(forgotten))
+	topics := make(map[string]bool)
+	for i, c := range forgotten {
+		ids[i] = c.CellID
+		if c.TopicID != "" {
+			topics[c.TopicID] = true
+		}
+	}
+	if err := d.SupersedeCells(ids); err != nil {
+		return nil, err
+	}
+	for topicID := range topics {
+		if err := d.refreshTopicCellCount(topicID); err != nil {
+			return nil, err
+		}
+	}
+	return forgotten, nil
+}
+
+// LiveCells returns the cells among cellIDs that exist, aren't superseded,
+// and are visible from project as in TwoTierSearch: the project's own cells
+// and global ones. An empty project sees every cell.
+func (d *DB) LiveCells(project string, cellIDs []string) ([]Cell, error) {
+	var cells []Cell
+	seen := make(map[string]bool)
+	for _, id := range cellIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		c, err := d.GetCell(id)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil || c.Superseded || (project != "" && c.Project != "" && c.Project != project) {
+			continue
+		}
+		cells = append(cells, *c)
+	}
+	return cells, nil
+}
+
+// GetCell returns a cell by ID, including superseded cells. Returns nil, nil if not found.
+func (d *DB) GetCell(cellID string) (*Cell, error) {
+	var c Cell
+	var topicID, sourceName sql.NullString
+	err := d.db.QueryRow(
+		`SELECT cell_id, topic_id, source_type, source_id, source_name, cell_type, salience, content, embedding, superseded, project, created_at
+		 FROM cells WHERE cell_id = ?`,
+		cellID,
+	).Scan(&c.CellID, &topicID, &c.SourceType, &c.SourceID, &sourceName, &c.CellType, &c.Salience, &c.Content, &c.Embedding, &c.Superseded, &c.Project, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memory: get cell: %w", err)
+	}
+	c.TopicID = topicID.String
+	c.SourceName = sourceName.String
+	return &c, nil
+}
+
+// refreshTopicCellCount recomputes a topic's cell_count from its non-superseded cells.
+func (d *DB) refreshTopicCellCount(topicID string) error {
+	_, err := d.db.Exec(
+		`UPDATE topics SET cell_count = (SELECT COUNT(*) FROM cells WHERE topic_id = ? AND superseded = FALSE) WHERE topic_id = ?`,
+		topicID, topicID,
+	)
+	if err != nil {
+		return fmt.Errorf("memory: refresh topic cell_count: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func TestSaveManualMemory(t *testing.T) {
+	mdb := openTestDB(t)
+	ctx := context.Background()
+
+	cell, err := mdb.SaveManualMemory(ctx, memory.ManualMemory{
+		CellType:  "preference",
+		Content:   "We use pnpm, not npm, for the UI",
+		TopicHint: "tooling",
+		SourceID:  "conv-1",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cell.SourceType != "manual" {
+		t.Errorf("source_type = %q, want manual", cell.SourceType)
+	}
+	if cell.Salience != 0.9 {
+		t.Errorf("salience = %v, want 0.9", cell.Salience)
+	}
+	if cell.TopicID == "" {
+		t.Error("expected cell to be assigned to a topic")
+	}
+
+	results, err := mdb.SearchCellsFTS("pnpm", "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].CellID != cell.CellID {
+		t.Fatalf("expected saved cell in search results, got %+v", results)
+	}
+
+	// Re-indexing the conversation must not delete manual memories.
+	if err := mdb.DeleteCellsBySource("conversation", "conv-1"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := mdb.GetCell(cell.CellID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("manual cell was deleted with conversation cells")
+	}
+}
+
+func TestSaveManualMemorySupersedes(t *testing.T) {
+	mdb := openTestDB(t)
+	ctx := context.Background()
+
+	old, err := mdb.SaveManualMemory(ctx, memory.ManualMemory{Content: "Deploys go through Jenkins"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mdb.SaveManualMemory(ctx, memory.ManualMemory{
+		CellType:   "decision",
+		Content:    "Deploys go through GitHub Actions",
+		Supersedes: []string{old.CellID},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := mdb.GetCell(old.CellID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Superseded {
+		t.Error("expected old cell to be superseded")
+	}
+}
+
+func TestSaveManualMemoryInvalid(t *testing.T) {
+	mdb := openTestDB(t)
+	ctx := context.Background()
+
+	if _, err := mdb.SaveManualMemory(ctx, memory.ManualMemory{Content: "  "}, nil); err == nil {
+		t.Error("expected error for empty content")
+	}
+	if _, err := mdb.SaveManualMemory(ctx, memory.ManualMemory{CellType: "gossip", Content: "x"}, nil); err == nil {
+		t.Error("expected error for invalid cell type")
+	}
+}
+
+// countingEmbedder counts Embed calls and optionally fails them.
+type countingEmbedder struct {
+	calls int
+	err   error
+}
+
+func (e *countingEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	if e.err != nil {
+		return nil, e.err
+	}
+	vecs := make([][]float32, len(texts))
+	for i := range texts {
+		vecs[i] = []float32{1, 0, 0}
+	}
+	return vecs, nil
+}
+
+func (e *countingEmbedder) Dimension() int { return 3 }
+
+func TestSaveManualMemoryEmbedsOnce(t *testing.T) {
+	mdb := openTestDB(t)
+	emb := &countingEmbedder{}
+
+	cell, err := mdb.SaveManualMemory(context.Background(), memory.ManualMemory{Content: "The API listens on port 9000"}, emb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emb.calls != 1 {
+		t.Errorf("Embed called %d times, want 1", emb.calls)
+	}
+	if len(cell.Embedding) == 0 {
+		t.Error("expected cell to carry an embedding")
+	}
+}
+
+func TestSaveManualMemoryEmbedFailure(t *testing.T) {
+	mdb := openTestDB(t)
+	emb := &countingEmbedder{err: errors.New("embedder down")}
+
+	cell, err := mdb.SaveManualMemory(context.Background(), memory.ManualMemory{Content: "The API listens on port 9000"}, emb)
+	if err != nil {
+		t.Fatalf("save should succeed without an embedding: %v", err)
+	}
+	if len(cell.Embedding) != 0 {
+		t.Error("expected no embedding after embedder failure")
+	}
+	if cell.TopicID == "" {
+		t.Error("expected cell to be assigned to a topic")
+	}
+}
+
+func TestForgetCells(t *testing.T) {
+	mdb := openTestDB(t)
+
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "t1", Name: "build"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"c1", "c2"} {
+		err := mdb.InsertCell(memory.Cell{
+			CellID: id, TopicID: "t1", SourceType: "conversation", SourceID: "conv-1",
+			CellType: "fact", Salience: 0.5, Content: "the build uses make " + id,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	forgotten, err := mdb.ForgetCells("", []string{"c1", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forgotten) != 1 || forgotten[0].CellID != "c1" {
+		t.Fatalf("expected only c1 forgotten, got %+v", forgotten)
+	}
+
+	// Forgetting again is a no-op.
+	forgotten, err = mdb.ForgetCells("", []string{"c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forgotten) != 0 {
+		t.Errorf("expected nothing forgotten the second time, got %d", len(forgotten))
+	}
+
+	results, err := mdb.SearchCellsFTS("build", "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].CellID != "c2" {
+		t.Errorf("expected only c2 in results, got %+v", results)
+	}
+
+	topic, err := mdb.GetTopic("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.CellCount != 1 {
+		t.Errorf("cell_count = %d, want 1", topic.CellCount)
+	}
+}
+
+func TestForgetCellsProject(t *testing.T) {
+	mdb := openTestDB(t)
+	for id, project := range map[string]string{"mine": "/work/a", "theirs": "/work/b", "global": ""} {
+		err := mdb.InsertCell(memory.Cell{
+			CellID: id, SourceType: "manual", SourceID: "user",
+			CellType: "fact", Salience: 0.5, Content: "memory " + id, Project: project,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only cells visible from the project, its own and global ones, are
+	// forgotten.
+	forgotten, err := mdb.ForgetCells("/work/a", []string{"mine", "theirs", "global"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range forgotten {
+		ids = append(ids, c.CellID)
+	}
+	if strings.Join(ids, ",") != "mine,global" {
+		t.Errorf("forgotten = %v, want mine and global", ids)
+	}
+	if c, err := mdb.GetCell("theirs"); err != nil || c.Superseded {
+		t.Errorf("another project's cell was forgotten: %+v, %v", c, err)
+	}
+}
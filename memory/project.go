@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// LegacyProject marks cells and topics that predate project scoping. They are
+// left out of project-scoped searches until BackfillProjects assigns them a
+// project, and still appear when searching across all projects.
+const LegacyProject = "legacy"
+
+// ProjectID returns the project identity for a working directory, used to
+// scope memories to a repository. It prefers the normalized origin remote URL
+// (e.g. "github.com/org/repo") so clones of the same repository share memories,
+// and falls back to the git root path. Outside a git repository the cleaned
+// absolute path of dir is used. Returns "" only for an empty dir; cells with
+// an empty project are global.
+func ProjectID(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	root := GitRoot(dir)
+	if root == "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			return abs
+		}
+		return filepath.Clean(dir)
+	}
+
+	out, err := exec.Command("git", "-C", root, "config", "--get", "remote.origin.url").Output()
+	if err == nil {
+		if remote := normalizeRemoteURL(strings.TrimSpace(string(out))); remote != "" {
+			return remote
+		}
+	}
+	return filepath.Clean(root)
+}
+
+// BackfillProjects assigns a project to conversation cells that have none or
+// are quarantined as LegacyProject. projectFor maps a conversation ID to its
+// project; cells whose conversation has no project stay quarantined. It
+// returns the number of cells that were assigned a project.
+func (d *DB) BackfillProjects(projectFor func(conversationID string) string) (int, error) {
+	rows, err := d.db.Query(
+		`SELECT DISTINCT source_id FROM cells WHERE source_type = 'conversation' AND project IN ('', ?)`,
+		LegacyProject,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("memory: backfill projects: %w", err)
+	}
+	var convIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("memory: backfill projects: %w", err)
+		}
+		convIDs = append(convIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("memory: backfill projects: %w", err)
+	}
+
+	assigned := 0
+	for _, id := range convIDs {
+		project := projectFor(id)
+		if project == "" {
+			project = LegacyProject
+		}
+		res, err := d.db.Exec(
+			`UPDATE cells SET project = ? WHERE source_type = 'conversation' AND source_id = ? AND project IN ('', ?)`,
+			project, id, LegacyProject,
+		)
+		if err != nil {
+			return assigned, fmt.Errorf("memory: backfill projects: %w", err)
+		}
+		if project != LegacyProject {
+			n, _ := res.RowsAffected()
+			assigned += int(n)
+		}
+	}
+	return assigned, nil
+}
+
+// GitRoot returns the top-level directory of the git repository containing
+// dir, or "" when dir is not inside a git repository.
+func GitRoot(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// normalizeRemoteURL reduces a git remote URL to host/path form so that the
+// https and ssh spellings of the same repository compare equal.
+//
+//	https://github.com/org/repo.git -> github.com/org/repo
+//	git@github.com:org/repo.git     -> github.com/org/repo
+//	ssh://git@host:2222/org/repo    -> host/org/repo
+func normalizeRemoteURL(u string) string {
+	if u == "" {
+		return ""
+	}
+	// Local path remotes are not a stable identity; use the git root instead.
+	if strings.HasPrefix(u, "/") || strings.HasPrefix(u, "file://") || strings.HasPrefix(u, ".") {
+		return ""
+	}
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	} else if i := strings.Index(u, ":"); i >= 0 {
+		// scp-like syntax: [user@]host:path
+		u = u[:i] + "/" + u[i+1:]
+	}
+	if i := strings.Index(u, "@"); i >= 0 && i < strings.Index(u+"/", "/") {
+		u = u[i+1:]
+	}
+	host, path, _ := strings.Cut(u, "/")
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i] // drop port
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return ""
+	}
+	return strings.ToLower(host) + "/" + path
+}
@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// reembedBatchSize is the number of texts sent to the embedder per request.
+const reembedBatchSize = 64
+
+// ReembedStats summarizes a Reembed run.
+type ReembedStats struct {
+	Full   bool // the embedder changed, so everything was re-embedded
+	Cells  int
+	Topics int
+}
+
+// EmbedderID identifies the vector space e produces, from its type, model
+// (when it has a Model method) and dimension. Embedders whose Dimension is 0
+// are probed with a short text. Vectors from different IDs are not comparable.
+func EmbedderID(ctx context.Context, e Embedder) (string, int, error) {
+	name := fmt.Sprintf("%T", e)
+	if m, ok := e.(interface{ Model() string }); ok {
+		name += ":" + m.Model()
+	}
+	dim := e.Dimension()
+	if dim == 0 {
+		vecs, err := e.Embed(ctx, []string{"dimension probe"})
+		if err != nil {
+			return "", 0, fmt.Errorf("memory: probe embedder: %w", err)
+		}
+		if len(vecs) == 0 || len(vecs[0]) == 0 {
+			return "", 0, fmt.Errorf("memory: probe embedder: no vector returned")
+		}
+		dim = len(vecs[0])
+	}
+	return fmt.Sprintf("%s/%d", name, dim), dim, nil
+}
+
+// Reembed brings stored embeddings in line with e. If e differs from the
+// embedder recorded by the last run (a new model or dimension), every active
+// cell and every topic is re-embedded; otherwise only those whose embedding is
+// missing or has the wrong dimension are. The embedder is recorded once the
+// run completes, so an interrupted full run starts over next time.
+func (d *DB) Reembed(ctx context.Context, e Embedder) (ReembedStats, error) {
+	var stats ReembedStats
+	if e == nil {
+		return stats, nil
+	}
+	id, dim, err := EmbedderID(ctx, e)
+	if err != nil {
+		return stats, err
+	}
+	stored, err := d.getMeta("embedder")
+	if err != nil {
+		return stats, err
+	}
+	stats.Full = stored != "" && stored != id
+
+	stats.Cells, err = d.reembedTable(ctx, e,
+		`SELECT rowid, cell_id, content FROM cells
+		 WHERE rowid > ? AND superseded = FALSE AND (? OR embedding IS NULL OR length(embedding) != ?)
+		 ORDER BY rowid LIMIT ?`,
+		`UPDATE cells SET embedding = ? WHERE cell_id = ?`,
+		stats.Full, dim*4,
+	)
+	if stats.Cells > 0 {
+		d.vec.invalidate()
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Topics, err = d.reembedTable(ctx, e,
+		`SELECT rowid, topic_id, COALESCE(NULLIF(summary, ''), name) FROM topics
+		 WHERE rowid > ? AND (? OR embedding IS NULL OR length(embedding) != ?)
+		 ORDER BY rowid LIMIT ?`,
+		`UPDATE topics SET embedding = ? WHERE topic_id = ?`,
+		stats.Full, dim*4,
+	)
+	if err != nil {
+		return stats, err
+	}
+
+	if err := d.setMeta("embedder", id); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// reembedTable pages through the (rowid, id, text) rows selected by query,
+// embeds the text and stores the vectors with update. It returns the number of
+// rows updated.
+func (d *DB) reembedTable(ctx context.Context, e Embedder, query, update string, full bool, blobLen int) (int, error) {
+	var (
+		lastRowID int64
+		n         int
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		rows, err := d.db.Query(query, lastRowID, full, blobLen, reembedBatchSize)
+		if err != nil {
+			return n, fmt.Errorf("memory: reembed: %w", err)
+		}
+		var ids, texts []string
+		for rows.Next() {
+			var id, text string
+			if err := rows.Scan(&lastRowID, &id, &text); err != nil {
+				rows.Close()
+				return n, fmt.Errorf("memory: reembed scan: %w", err)
+			}
+			ids = append(ids, id)
+			texts = append(texts, text)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return n, fmt.Errorf("memory: reembed: %w", err)
+		}
+		if len(ids) == 0 {
+			return n, nil
+		}
+
+		vecs, err := e.Embed(ctx, texts)
+		if err != nil {
+			return n, fmt.Errorf("memory: reembed: %w", err)
+		}
+		if len(vecs) != len(ids) {
+			return n, fmt.Errorf("memory: reembed: embedder returned %d vectors for %d texts", len(vecs), len(ids))
+		}
+		for i, id := range ids {
+			if _, err := d.db.Exec(update, SerializeEmbedding(vecs[i]), id); err != nil {
+				return n, fmt.Errorf("memory: reembed update: %w", err)
+			}
+			n++
+		}
+	}
+}
+
+// getMeta returns a value from the meta table, or "" if unset.
+func (d *DB) getMeta(key string) (string, error) {
+	var value string
+	err := d.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("memory: get meta %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// setMeta stores a value in the meta table.
+func (d *DB) setMeta(key, value string) error {
+	_, err := d.db.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, key, value)
+	if err != nil {
+		return fmt.Errorf("memory: set meta %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// CellUseBoost is the salience added to a cell each time memory_search returns it.
+const CellUseBoost = 0.05
+
+// RetentionPolicy configures ApplyRetention. A zero field disables its rule.
+type RetentionPolicy struct {
+	SupersededMaxAge time.Duration // delete superseded cells older than this
+	MaxCellsPerTopic int           // supersede the lowest-salience cells beyond this many per topic
+	DecayHalfLife    time.Duration // halve the salience of unused cells over this period
+	MinSalience      float64       // decay never lowers salience below this
+}
+
+// DefaultRetentionPolicy returns the policy used when none is configured.
+func DefaultRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		SupersededMaxAge: 90 * 24 * time.Hour,
+		MaxCellsPerTopic: 200,
+		DecayHalfLife:    180 * 24 * time.Hour,
+		MinSalience:      0.1,
+	}
+}
+
+// RetentionReport describes what ApplyRetention changed, or would change in a dry run.
+type RetentionReport struct {
+	DryRun  bool
+	Expired []string // superseded cells deleted for age
+	Capped  []string // cells superseded to enforce MaxCellsPerTopic
+	Decayed int      // cells whose salience was lowered
+}
+
+// sqliteTime formats t like SQLite's CURRENT_TIMESTAMP so the two compare correctly.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// ApplyRetention prunes and decays cells according to p, as of now.
+// Expired superseded cells are deleted, topics over the cap have their weakest
+// cells superseded (manual cells are never capped), and salience decays with
+// time since the cell was created, last decayed, or last returned by search.
+// With dryRun set, nothing is written and the report shows what would change.
+func (d *DB) ApplyRetention(p RetentionPolicy, now time.Time, dryRun bool) (RetentionReport, error) {
+	report := RetentionReport{DryRun: dryRun}
+	ts := sqliteTime(now)
+	topics := make(map[string]bool)
+
+	if p.SupersededMaxAge > 0 {
+		rows, err := d.db.Query(
+			`SELECT cell_id, COALESCE(topic_id, '') FROM cells
+			 WHERE superseded = TRUE AND julianday(?) - julianday(COALESCE(superseded_at, created_at)) > ?`,
+			ts, p.SupersededMaxAge.Hours()/24,
+		)
+		if err != nil {
+			return report, fmt.Errorf("memory: retention: find expired cells: %w", err)
+		}
+		for rows.Next() {
+			var id, topicID string
+			if err := rows.Scan(&id, &topicID); err != nil {
+				rows.Close()
+				return report, fmt.Errorf("memory: retention: scan expired cell: %w", err)
+			}
+			report.Expired = append(report.Expired, id)
+			topics[topicID] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return report, fmt.Errorf("memory: retention: find expired cells: %w", err)
+		}
+		if !dryRun {
+			if err := d.deleteCells(report.Expired); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if p.MaxCellsPerTopic > 0 {
+		capped, err := d.overCapCells(p.MaxCellsPerTopic)
+		if err != nil {
+			return report, err
+		}
+		for id, topicID := range capped {
+			report.Capped = append(report.Capped, id)
+			topics[topicID] = true
+		}
+		slices.Sort(report.Capped)
+		if !dryRun {
+			if err := d.SupersedeCells(report.Capped); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if p.DecayHalfLife > 0 {
+		n, err := d.decayCells(p, ts, dryRun)
+		if err != nil {
+			return report, err
+		}
+		report.Decayed = n
+	}
+
+	if !dryRun {
+		for topicID := range topics {
+			if topicID == "" {
+				continue
+			}
+			if err := d.refreshTopicCellCount(topicID); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// overCapCells returns, for every topic with more than limit active cells, the
+// excess cells ranked lowest by salience and recency, mapped to their topic.
+func (d *DB) overCapCells(limit int) (map[string]string, error) {
+	rows, err := d.db.Query(
+		`SELECT topic_id FROM cells WHERE superseded = FALSE AND COALESCE(topic_id, '') != ''
+		 GROUP BY topic_id HAVING COUNT(*) > ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("memory: retention: find full topics: %w", err)
+	}
+	var topicIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("memory: retention: scan topic: %w", err)
+		}
+		topicIDs = append(topicIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("memory: retention: find full topics: %w", err)
+	}
+
+	capped := make(map[string]string)
+	for _, topicID := range topicIDs {
+		// Manual cells sort first so they are only capped by other manual cells,
+		// and are then skipped below.
+		rows, err := d.db.Query(
+			`SELECT cell_id, source_type FROM cells WHERE topic_id = ? AND superseded = FALSE
+			 ORDER BY source_type = 'manual' DESC, salience DESC, COALESCE(last_used_at, created_at) DESC, created_at DESC
+			 LIMIT -1 OFFSET ?`,
+			topicID, limit,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("memory: retention: rank topic cells: %w", err)
+		}
+		for rows.Next() {
+			var id, sourceType string
+			if err := rows.Scan(&id, &sourceType); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("memory: retention: scan topic cell: %w", err)
+			}
+			if sourceType != "manual" {
+				capped[id] = topicID
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("memory: retention: rank topic cells: %w", err)
+		}
+	}
+	return capped, nil
+}
+
+// decayCells lowers the salience of active cells by half per p.DecayHalfLife
+// elapsed since their decay clock (creation, last decay, or last use).
+// Cells whose salience would drop by less than 0.005 are left alone so their
+// clock keeps running. It returns the number of cells decayed.
+func (d *DB) decayCells(p RetentionPolicy, ts string, dryRun bool) (int, error) {
+	rows, err := d.db.Query(
+		`SELECT cell_id, salience,
+		        COALESCE(julianday(?) - julianday(MAX(COALESCE(decayed_at, created_at), COALESCE(last_used_at, created_at))), 0)
+		 FROM cells WHERE superseded = FALSE AND salience > ?`,
+		ts, p.MinSalience,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("memory: retention: find cells to decay: %w", err)
+	}
+	type decay struct {
+		id       string
+		salience float64
+	}
+	var decays []decay
+	halfLifeDays := p.DecayHalfLife.Hours() / 24
+	for rows.Next() {
+		var (
+			id       string
+			salience float64
+			days     float64
+		)
+		if err := rows.Scan(&id, &salience, &days); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("memory: retention: scan cell: %w", err)
+		}
+		if days <= 0 {
+			continue
+		}
+		decayed := math.Max(p.MinSalience, salience*math.Pow(0.5, days/halfLifeDays))
+		if salience-decayed < 0.005 {
+			continue
+		}
+		decays = append(decays, decay{id, decayed})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("memory: retention: find cells to decay: %w", err)
+	}
+
+	if dryRun || len(decays) == 0 {
+		return len(decays), nil
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("memory: retention: decay: %w", err)
+	}
+	defer tx.Rollback()
+	for _, dc := range decays {
+		if _, err := tx.Exec(`UPDATE cells SET salience = ?, decayed_at = ? WHERE cell_id = ?`, dc.salience, ts, dc.id); err != nil {
+			return 0, fmt.Errorf("memory: retention: decay cell: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("memory: retention: decay: %w", err)
+	}
+	return len(decays), nil
+}
+
+// deleteCells permanently removes the given cells.
+func (d *DB) deleteCells(cellIDs []string) error {
+	if len(cellIDs) == 0 {
+		return nil
+	}
+	args := make([]any, len(cellIDs))
+	for i, id := range cellIDs {
+		args[i] = id
+	}
+	q := fmt.Sprintf(`DELETE FROM cells WHERE cell_id IN (%s)`, placeholders(len(cellIDs)))
+	if _, err := d.db.Exec(q, args...); err != nil {
+		return fmt.Errorf("memory: delete cells: %w", err)
+	}
+	d.vec.markDirty(cellIDs...)
+	return nil
+}
+
+// RecordCellUse boosts the salience of cells returned to the agent by
+// CellUseBoost (capped at 1) and restarts their decay clock.
+func (d *DB) RecordCellUse(cellIDs []string) error {
+	if len(cellIDs) == 0 {
+		return nil
+	}
+	args := []any{CellUseBoost}
+	for _, id := range cellIDs {
+		args = append(args, id)
+	}
+	q := fmt.Sprintf(
+		`UPDATE cells SET salience = MIN(1.0, salience + ?), last_used_at = CURRENT_TIMESTAMP
+		 WHERE superseded = FALSE AND cell_id IN (%s)`,
+		placeholders(len(cellIDs)),
+	)
+	if _, err := d.db.Exec(q, args...); err != nil {
+		return fmt.Errorf("memory: record cell use: %w", err)
+	}
+	return nil
+}
+
+// Optimize merges the FTS index segments and reclaims free pages with VACUUM.
+// It is meant to run after ApplyRetention has deleted cells.
+func (d *DB) Optimize() error {
+	stmts := []string{
+		`INSERT INTO cells_fts(cells_fts) VALUES('optimize')`,
+		`INSERT INTO topics_fts(topics_fts) VALUES('optimize')`,
+		`VACUUM`,
+	}
+	for _, stmt := range stmts {
+		if _, err := d.db.Exec(stmt); err != nil {
+			return fmt.Errorf("memory: optimize: %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package memory_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func insertTestCells(t *testing.T, mdb *memory.DB, topicID string, cells ...memory.Cell) {
+	t.Helper()
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: topicID, Name: topicID}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cells {
+		c.TopicID = topicID
+		if c.SourceType == "" {
+			c.SourceType = "conversation"
+		}
+		c.SourceID = "conv-1"
+		c.CellType = "fact"
+		if err := mdb.InsertCell(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestApplyRetentionExpiresSuperseded(t *testing.T) {
+	mdb := openTestDB(t)
+	insertTestCells(t, mdb, "t1",
+		memory.Cell{CellID: "old", Salience: 0.5, Content: "old fact"},
+		memory.Cell{CellID: "live", Salience: 0.5, Content: "live fact"},
+	)
+	if err := mdb.SupersedeCells([]string{"old"}); err != nil {
+		t.Fatal(err)
+	}
+	policy := memory.RetentionPolicy{SupersededMaxAge: 30 * 24 * time.Hour}
+
+	// Not old enough yet.
+	report, err := mdb.ApplyRetention(policy, time.Now().Add(24*time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Expired) != 0 {
+		t.Errorf("expected nothing expired after a day, got %v", report.Expired)
+	}
+
+	later := time.Now().Add(31 * 24 * time.Hour)
+	report, err = mdb.ApplyRetention(policy, later, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Expired) != 1 || report.Expired[0] != "old" {
+		t.Fatalf("dry run: expected old to expire, got %v", report.Expired)
+	}
+	if c, _ := mdb.GetCell("old"); c == nil {
+		t.Fatal("dry run must not delete cells")
+	}
+
+	if _, err := mdb.ApplyRetention(policy, later, false); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := mdb.GetCell("old"); c != nil {
+		t.Error("expected superseded cell to be deleted")
+	}
+	if c, _ := mdb.GetCell("live"); c == nil {
+		t.Error("live cell must be kept")
+	}
+}
+
+func TestApplyRetentionCapsTopics(t *testing.T) {
+	mdb := openTestDB(t)
+	insertTestCells(t, mdb, "t1",
+		memory.Cell{CellID: "high", Salience: 0.9, Content: "a"},
+		memory.Cell{CellID: "mid", Salience: 0.5, Content: "b"},
+		memory.Cell{CellID: "low", Salience: 0.2, Content: "c"},
+		memory.Cell{CellID: "pinned", SourceType: "manual", Salience: 0.1, Content: "d"},
+	)
+
+	report, err := mdb.ApplyRetention(memory.RetentionPolicy{MaxCellsPerTopic: 2}, time.Now(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The manual cell ranks first, so only one extracted cell fits.
+	if len(report.Capped) != 2 || report.Capped[0] != "low" || report.Capped[1] != "mid" {
+		t.Fatalf("expected low and mid capped, got %v", report.Capped)
+	}
+	for id, want := range map[string]bool{"high": false, "mid": true, "low": true, "pinned": false} {
+		c, err := mdb.GetCell(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Superseded != want {
+			t.Errorf("%s superseded = %v, want %v", id, c.Superseded, want)
+		}
+	}
+	topic, err := mdb.GetTopic("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.CellCount != 2 {
+		t.Errorf("cell_count = %d, want 2", topic.CellCount)
+	}
+}
+
+func TestApplyRetentionDecayAndUse(t *testing.T) {
+	mdb := openTestDB(t)
+	insertTestCells(t, mdb, "t1",
+		memory.Cell{CellID: "unused", Salience: 0.8, Content: "a"},
+		memory.Cell{CellID: "floor", Salience: 0.1, Content: "b"},
+	)
+	policy := memory.RetentionPolicy{DecayHalfLife: 10 * 24 * time.Hour, MinSalience: 0.1}
+	later := time.Now().Add(10 * 24 * time.Hour)
+
+	report, err := mdb.ApplyRetention(policy, later, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Decayed != 1 {
+		t.Errorf("decayed = %d, want 1", report.Decayed)
+	}
+	c, _ := mdb.GetCell("unused")
+	if c.Salience < 0.39 || c.Salience > 0.41 {
+		t.Errorf("salience after one half-life = %v, want ~0.4", c.Salience)
+	}
+
+	// Running again at the same instant must not decay twice.
+	report, err = mdb.ApplyRetention(policy, later, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Decayed != 0 {
+		t.Errorf("second run decayed %d cells, want 0", report.Decayed)
+	}
+
+	if err := mdb.RecordCellUse([]string{"unused"}); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = mdb.GetCell("unused")
+	if c.Salience < 0.44 || c.Salience > 0.46 {
+		t.Errorf("salience after use = %v, want ~0.45", c.Salience)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	mdb := openTestDB(t)
+	insertTestCells(t, mdb, "t1", memory.Cell{CellID: "c1", Salience: 0.5, Content: "the build uses make"})
+	if err := mdb.Optimize(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := mdb.SearchCellsFTS("make", "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected search to work after optimize, got %d results", len(results))
+	}
+}
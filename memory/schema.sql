@@ -1,7 +1,7 @@
 CREATE TABLE IF NOT EXISTS cells (
     cell_id     TEXT PRIMARY KEY,
     topic_id    TEXT,
-    source_type TEXT NOT NULL CHECK(source_type IN ('conversation', 'file')),
+    source_type TEXT NOT NULL CHECK(source_type IN ('conversation', 'file', 'manual')),
     source_id   TEXT NOT NULL,
     source_name TEXT,
     cell_type   TEXT NOT NULL CHECK(cell_type IN ('fact', 'decision', 'preference', 'task', 'risk', 'code_ref')),
@@ -9,10 +9,15 @@ CREATE TABLE IF NOT EXISTS cells (
     content     TEXT NOT NULL,
     embedding   BLOB,
     created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
-    superseded  BOOLEAN DEFAULT FALSE
+    superseded  BOOLEAN DEFAULT FALSE,
+    project     TEXT NOT NULL DEFAULT '',
+    superseded_at DATETIME,
+    last_used_at  DATETIME,
+    decayed_at    DATETIME
 );
 CREATE INDEX IF NOT EXISTS idx_cells_source ON cells(source_type, source_id);
 CREATE INDEX IF NOT EXISTS idx_cells_topic ON cells(topic_id);
+CREATE INDEX IF NOT EXISTS idx_cells_project ON cells(project);
 
 CREATE VIRTUAL TABLE IF NOT EXISTS cells_fts USING fts5(
     content,
@@ -26,7 +31,7 @@ END;
 CREATE TRIGGER IF NOT EXISTS cells_ad AFTER DELETE ON cells BEGIN
     INSERT INTO cells_fts(cells_fts, rowid, content) VALUES('delete', old.rowid, old.content);
 END;
-CREATE TRIGGER IF NOT EXISTS cells_au AFTER UPDATE ON cells BEGIN
+CREATE TRIGGER IF NOT EXISTS cells_au AFTER UPDATE OF content ON cells BEGIN
     INSERT INTO cells_fts(cells_fts, rowid, content) VALUES('delete', old.rowid, old.content);
     INSERT INTO cells_fts(rowid, content) VALUES (new.rowid, new.content);
 END;
@@ -37,8 +42,10 @@ CREATE TABLE IF NOT EXISTS topics (
     summary     TEXT,
     embedding   BLOB,
     cell_count  INTEGER DEFAULT 0,
-    updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+    updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+    project     TEXT NOT NULL DEFAULT ''
 );
+CREATE INDEX IF NOT EXISTS idx_topics_project ON topics(project);
 
 CREATE VIRTUAL TABLE IF NOT EXISTS topics_fts USING fts5(
     summary,
@@ -57,6 +64,11 @@ CREATE TRIGGER IF NOT EXISTS topics_ad AFTER DELETE ON topics BEGIN
     INSERT INTO topics_fts(topics_fts, rowid, summary) VALUES('delete', old.rowid, COALESCE(old.summary, ''));
 END;
 
+CREATE TABLE IF NOT EXISTS meta (
+    key   TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
 CREATE TABLE IF NOT EXISTS index_state (
     source_type TEXT NOT NULL,
     source_id   TEXT NOT NULL,
@@ -1,8 +1,18 @@
 package memory
 
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// rrfK is the reciprocal-rank fusion constant from the original RRF paper.
+// Larger values flatten the advantage of top-ranked results.
+const rrfK = 60
+
 // MemoryResult is a unified search result from the two-tier search.
 type MemoryResult struct {
-	ResultType string  // "topic_summary" or "cell"
+	ResultType string // "topic_summary" or "cell"
 	TopicID    string
 	TopicName  string
 	CellID     string
@@ -10,6 +20,7 @@ type MemoryResult struct {
 	SourceType string
 	SourceID   string
 	SourceName string
+	Project    string
 	Salience   float64
 	Content    string // summary text for topics, cell content for cells
 	Score      float64
@@ -17,8 +28,10 @@ type MemoryResult struct {
 }
 
 // TwoTierSearch performs a two-tier search: topic summaries first, then individual cells.
-// queryVec can be nil for FTS-only search.
-func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType string, limit int) ([]MemoryResult, error) {
+// queryVec can be nil for FTS-only search; otherwise cells are ranked by fusing
+// FTS5 BM25 and embedding similarity (see hybridSearchCells). If project is non-empty, results are
+// limited to that project plus global memories; an empty project searches everything.
+func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType, project string, limit int) ([]MemoryResult, error) {
 	topicLimit := 3
 	cellLimit := limit - topicLimit
 	if cellLimit < 1 {
@@ -28,13 +41,14 @@ func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType string,
 	var results []MemoryResult
 
 	// Tier 1: Topic summaries via FTS.
-	topicResults, topicErr := d.SearchTopicsFTS(query, topicLimit)
+	topicResults, topicErr := d.SearchTopicsFTS(query, project, topicLimit)
 	if topicErr == nil {
 		for _, tr := range topicResults {
 			results = append(results, MemoryResult{
 				ResultType: "topic_summary",
 				TopicID:    tr.TopicID,
 				TopicName:  tr.Name,
+				Project:    tr.Project,
 				Content:    tr.Summary,
 				Score:      tr.Score,
 				UpdatedAt:  tr.UpdatedAt,
@@ -42,8 +56,14 @@ func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType string,
 		}
 	}
 
-	// Tier 2: Individual cells via FTS.
-	cellResults, cellErr := d.SearchCellsFTS(query, sourceType, cellLimit)
+	// Tier 2: Individual cells via FTS, fused with vector similarity when available.
+	var cellResults []CellResult
+	var cellErr error
+	if len(queryVec) > 0 {
+		cellResults, cellErr = d.hybridSearchCells(query, queryVec, sourceType, project, cellLimit)
+	} else {
+		cellResults, cellErr = d.SearchCellsFTS(query, sourceType, project, cellLimit)
+	}
 	if cellErr == nil {
 		for _, cr := range cellResults {
 			results = append(results, MemoryResult{
@@ -54,6 +74,7 @@ func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType string,
 				SourceType: cr.SourceType,
 				SourceID:   cr.SourceID,
 				SourceName: cr.SourceName,
+				Project:    cr.Project,
 				Salience:   cr.Salience,
 				Content:    cr.Content,
 				Score:      cr.Score,
@@ -71,3 +92,83 @@ func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType string,
 	}
 	return results, nil
 }
+
+// hybridSearchCells ranks cells by reciprocal-rank fusion of the FTS5 BM25
+// ranking and the embedding-similarity ranking, each over limit*4 candidates.
+// A result's Score is its fused score; higher is better. Either ranking alone
+// is used if the other fails (e.g. a query that is not valid FTS5 syntax).
+func (d *DB) hybridSearchCells(query string, queryVec []float32, sourceType, project string, limit int) ([]CellResult, error) {
+	candidates := limit * 4
+	ftsResults, ftsErr := d.SearchCellsFTS(query, sourceType, project, candidates)
+	hits, vecErr := d.searchCellVectors(queryVec, sourceType, project, candidates)
+	if ftsErr != nil && vecErr != nil {
+		return nil, ftsErr
+	}
+
+	scores := make(map[string]float64)
+	byID := make(map[string]CellResult)
+	for i, r := range ftsResults {
+		scores[r.CellID] += 1 / float64(rrfK+i+1)
+		byID[r.CellID] = r
+	}
+	var missing []string
+	for i, h := range hits {
+		scores[h.cellID] += 1 / float64(rrfK+i+1)
+		if _, ok := byID[h.cellID]; !ok {
+			missing = append(missing, h.cellID)
+		}
+	}
+	if len(missing) > 0 {
+		loaded, err := d.cellResultsByID(missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range loaded {
+			byID[r.CellID] = r
+		}
+	}
+
+	results := make([]CellResult, 0, len(byID))
+	for id, r := range byID {
+		r.Score = scores[id]
+		results = append(results, r)
+	}
+	slices.SortFunc(results, func(a, b CellResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Salience, a.Salience); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.CellID, b.CellID)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// cellResultsByID loads active cells by ID as search results with zero score.
+func (d *DB) cellResultsByID(cellIDs []string) ([]CellResult, error) {
+	args := make([]any, len(cellIDs))
+	for i, id := range cellIDs {
+		args[i] = id
+	}
+	rows, err := d.db.Query(fmt.Sprintf(
+		`SELECT cell_id, COALESCE(topic_id, ''), source_type, source_id, COALESCE(source_name, ''), cell_type, salience, content, project
+		 FROM cells WHERE superseded = FALSE AND cell_id IN (%s)`, placeholders(len(cellIDs))), args...)
+	if err != nil {
+		return nil, fmt.Errorf("memory: load cells: %w", err)
+	}
+	defer rows.Close()
+
+	var results []CellResult
+	for rows.Next() {
+		var cr CellResult
+		if err := rows.Scan(&cr.CellID, &cr.TopicID, &cr.SourceType, &cr.SourceID, &cr.SourceName, &cr.CellType, &cr.Salience, &cr.Content, &cr.Project); err != nil {
+			return nil, fmt.Errorf("memory: scan cell: %w", err)
+		}
+		results = append(results, cr)
+	}
+	return results, rows.Err()
+}
@@ -31,7 +31,7 @@ func TestTwoTierSearch(t *testing.T) {
 		Content: "server/auth.go handles JWT authentication validation middleware",
 	})
 
-	results, err := mdb.TwoTierSearch("JWT authentication", nil, "", 10)
+	results, err := mdb.TwoTierSearch("JWT authentication", nil, "", "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
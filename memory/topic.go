@@ -19,18 +19,9 @@ const similarityThreshold = 0.7
 //  1. Name match -- normalize the cell's TopicHint and compare against existing topic names.
 //  2. Embedding similarity -- if an embedder is provided and best similarity >= 0.7, use that topic.
 //  3. Create new topic -- generate a topic ID from the hint, persist via db.UpsertTopic.
-func AssignCellsToTopics(ctx context.Context, db *DB, cells []ExtractedCell, embedder Embedder) ([]AssignedCell, error) {
-	existingTopics, err := db.AllTopics()
-	if err != nil {
-		return nil, fmt.Errorf("memory: assign cells: %w", err)
-	}
-
-	// Build name index: normalized name -> topic_id.
-	nameIndex := make(map[string]string, len(existingTopics))
-	for _, t := range existingTopics {
-		nameIndex[normalizeName(t.Name)] = t.TopicID
-	}
-
+//
+// Only topics belonging to project are considered, so each project consolidates separately.
+func AssignCellsToTopics(ctx context.Context, db *DB, project string, cells []ExtractedCell, embedder Embedder) ([]AssignedCell, error) {
 	// Batch-embed all cell contents if embedder is non-nil.
 	var embeddings [][]float32
 	if embedder != nil {
@@ -38,11 +29,28 @@ func AssignCellsToTopics(ctx context.Context, db *DB, cells []ExtractedCell, emb
 		for i, c := range cells {
 			texts[i] = c.Content
 		}
+		var err error
 		embeddings, err = embedder.Embed(ctx, texts)
 		if err != nil {
 			return nil, fmt.Errorf("memory: embed cells: %w", err)
 		}
 	}
+	return assignEmbeddedCells(db, project, cells, embeddings)
+}
+
+// assignEmbeddedCells is AssignCellsToTopics with precomputed embeddings.
+// embeddings is either nil or parallel to cells.
+func assignEmbeddedCells(db *DB, project string, cells []ExtractedCell, embeddings [][]float32) ([]AssignedCell, error) {
+	existingTopics, err := db.ProjectTopics(project)
+	if err != nil {
+		return nil, fmt.Errorf("memory: assign cells: %w", err)
+	}
+
+	// Build name index: normalized name -> topic_id.
+	nameIndex := make(map[string]string, len(existingTopics))
+	for _, t := range existingTopics {
+		nameIndex[normalizeName(t.Name)] = t.TopicID
+	}
 
 	result := make([]AssignedCell, len(cells))
 	for i, cell := range cells {
@@ -67,10 +75,11 @@ func AssignCellsToTopics(ctx context.Context, db *DB, cells []ExtractedCell, emb
 		}
 
 		// Priority 3: Create new topic.
-		topicID := generateTopicID(cell.TopicHint)
+		topicID := generateTopicID(project, cell.TopicHint)
 		newTopic := Topic{
 			TopicID: topicID,
 			Name:    strings.TrimSpace(cell.TopicHint),
+			Project: project,
 		}
 		if embeddings != nil {
 			newTopic.Embedding = SerializeEmbedding(embeddings[i])
@@ -97,8 +106,8 @@ func findBestTopic(queryVec []float32, topics []Topic) (string, float32) {
 	var bestSim float32
 	for _, t := range topics {
 		topicVec := DeserializeEmbedding(t.Embedding)
-		if topicVec == nil {
-			continue
+		if topicVec == nil || len(topicVec) != len(queryVec) {
+			continue // missing, or from a different embedding model
 		}
 		sim := CosineSimilarity(queryVec, topicVec)
 		if sim > bestSim {
@@ -114,9 +123,14 @@ func normalizeName(s string) string {
 	return strings.ToLower(strings.TrimSpace(s))
 }
 
-// generateTopicID produces a deterministic topic ID from a hint string
-// using the first 8 bytes of its SHA-256 hash.
-func generateTopicID(hint string) string {
-	h := sha256.Sum256([]byte(hint))
+// generateTopicID produces a deterministic topic ID from a project and hint
+// string using the first 8 bytes of their SHA-256 hash. Global topics (empty
+// project) hash the hint alone.
+func generateTopicID(project, hint string) string {
+	key := hint
+	if project != "" {
+		key = project + "\x00" + hint
+	}
+	h := sha256.Sum256([]byte(key))
 	return fmt.Sprintf("topic_%x", h[:8])
 }
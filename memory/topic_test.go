@@ -39,7 +39,7 @@ func TestAssignCellsToTopics(t *testing.T) {
 		{CellType: "decision", Salience: 0.9, Content: "Use React Router for navigation", TopicHint: "frontend"},
 	}
 
-	assigned, err := memory.AssignCellsToTopics(context.Background(), mdb, cells, nil)
+	assigned, err := memory.AssignCellsToTopics(context.Background(), mdb, "", cells, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -95,7 +95,7 @@ func TestAssignToTopicByEmbeddingSimilarity(t *testing.T) {
 		{CellType: "fact", Salience: 0.7, Content: "Connection pool uses 20 connections", TopicHint: "data layer"},
 	}
 
-	assigned, err := memory.AssignCellsToTopics(context.Background(), mdb, cells, emb)
+	assigned, err := memory.AssignCellsToTopics(context.Background(), mdb, "", cells, emb)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -119,7 +119,7 @@ func TestAssignCreatesNewTopicWhenNoMatch(t *testing.T) {
 		{CellType: "preference", Salience: 0.6, Content: "User likes dark mode", TopicHint: "ui preferences"},
 	}
 
-	assigned, err := memory.AssignCellsToTopics(context.Background(), mdb, cells, nil)
+	assigned, err := memory.AssignCellsToTopics(context.Background(), mdb, "", cells, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
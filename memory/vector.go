@@ -0,0 +1,426 @@
+package memory
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+)
+
+// ivfMinEntries is the index size below which vector search stays exact.
+// Above it, an IVF (inverted file) partitioning is trained in the background
+// and queries only scan the partitions nearest the query.
+const ivfMinEntries = 2048
+
+// vecEntry is one cell embedding held in memory.
+type vecEntry struct {
+	cellID     string
+	sourceType string
+	sourceID   string
+	project    string
+	vec        []float32 // unit length
+	deleted    bool
+}
+
+// vecHit is a vector search result.
+type vecHit struct {
+	cellID string
+	score  float32 // cosine similarity
+}
+
+// vectorIndex is an in-memory approximate nearest-neighbour index over the
+// embeddings of active cells. It is filled lazily from memory.db and kept in
+// sync incrementally: new rows are picked up by rowid, and cells that are
+// updated, superseded or deleted through DB are marked dirty and reloaded.
+type vectorIndex struct {
+	mu       sync.Mutex
+	dim      int
+	entries  []vecEntry
+	byID     map[string]int // cell ID -> live entry position
+	live     int            // entries not deleted
+	maxRowID int64
+	stale    bool            // reload everything on next sync
+	dirty    map[string]bool // cell IDs to reload on next sync
+	gen      int             // bumped on full reload; invalidates in-flight training
+
+	// IVF partitioning; nil until trained.
+	centroids   [][]float32
+	lists       [][]int // entry positions per centroid
+	assigned    int     // entries[:assigned] have been placed in lists
+	trainedSize int
+	training    bool
+}
+
+func newVectorIndex() *vectorIndex {
+	return &vectorIndex{stale: true, dirty: make(map[string]bool)}
+}
+
+// invalidate forces a full reload on the next search.
+func (v *vectorIndex) invalidate() {
+	v.mu.Lock()
+	v.stale = true
+	v.mu.Unlock()
+}
+
+// markDirty schedules cells to be reloaded from the database on the next search.
+func (v *vectorIndex) markDirty(cellIDs ...string) {
+	v.mu.Lock()
+	for _, id := range cellIDs {
+		v.dirty[id] = true
+	}
+	v.mu.Unlock()
+}
+
+// removeSource drops all entries for a source.
+func (v *vectorIndex) removeSource(sourceType, sourceID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.entries {
+		e := &v.entries[i]
+		if !e.deleted && e.sourceType == sourceType && e.sourceID == sourceID {
+			v.remove(i)
+		}
+	}
+}
+
+// remove tombstones the entry at pos. Callers hold mu.
+func (v *vectorIndex) remove(pos int) {
+	e := &v.entries[pos]
+	if e.deleted {
+		return
+	}
+	e.deleted = true
+	delete(v.byID, e.cellID)
+	v.live--
+}
+
+// add appends an entry, placing it in its IVF list if the index is trained.
+// Callers hold mu.
+func (v *vectorIndex) add(e vecEntry) {
+	if pos, ok := v.byID[e.cellID]; ok {
+		v.remove(pos)
+	}
+	v.entries = append(v.entries, e)
+	v.byID[e.cellID] = len(v.entries) - 1
+	v.live++
+	v.assignPending()
+}
+
+// assignPending places entries added since training into their IVF lists.
+// Callers hold mu.
+func (v *vectorIndex) assignPending() {
+	if v.centroids == nil {
+		return
+	}
+	for ; v.assigned < len(v.entries); v.assigned++ {
+		c := nearestCentroid(v.centroids, v.entries[v.assigned].vec)
+		v.lists[c] = append(v.lists[c], v.assigned)
+	}
+}
+
+// reset clears the index for a full reload. Callers hold mu.
+func (v *vectorIndex) reset(dim int) {
+	v.dim = dim
+	v.entries = nil
+	v.byID = make(map[string]int)
+	v.live = 0
+	v.maxRowID = 0
+	v.stale = false
+	clear(v.dirty)
+	v.gen++
+	v.centroids, v.lists, v.assigned, v.trainedSize = nil, nil, 0, 0
+}
+
+// syncVectors brings the vector index up to date with the cells table for
+// embeddings of dimension dim.
+func (d *DB) syncVectors(dim int) error {
+	v := d.vec
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Tombstones only leave the index on a full reload, so reload once they dominate.
+	if v.stale || v.dim != dim || len(v.entries)-v.live > v.live {
+		v.reset(dim)
+	}
+
+	if len(v.dirty) > 0 {
+		ids := make([]string, 0, len(v.dirty))
+		for id := range v.dirty {
+			if pos, ok := v.byID[id]; ok {
+				v.remove(pos)
+			}
+			ids = append(ids, id)
+		}
+		clear(v.dirty)
+		for batch := range slices.Chunk(ids, 500) {
+			args := make([]any, 0, len(batch)+1)
+			args = append(args, dim*4)
+			for _, id := range batch {
+				args = append(args, id)
+			}
+			q := fmt.Sprintf(`SELECT rowid, cell_id, source_type, source_id, project, embedding FROM cells
+				WHERE superseded = FALSE AND length(embedding) = ? AND cell_id IN (%s)`, placeholders(len(batch)))
+			if err := d.loadVectors(q, args, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	err := d.loadVectors(
+		`SELECT rowid, cell_id, source_type, source_id, project, embedding FROM cells
+		 WHERE rowid > ? AND superseded = FALSE AND length(embedding) = ? ORDER BY rowid`,
+		[]any{v.maxRowID, dim * 4}, true,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Catch changes made outside this DB handle (another process, or raw SQL).
+	var count int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM cells WHERE superseded = FALSE AND length(embedding) = ?`, dim*4).Scan(&count); err != nil {
+		return fmt.Errorf("memory: count cell embeddings: %w", err)
+	}
+	if count != v.live {
+		v.reset(dim)
+		err := d.loadVectors(
+			`SELECT rowid, cell_id, source_type, source_id, project, embedding FROM cells
+			 WHERE superseded = FALSE AND length(embedding) = ? ORDER BY rowid`,
+			[]any{dim * 4}, true,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	v.maybeTrain()
+	return nil
+}
+
+// loadVectors adds the rows returned by q to the index. If trackRowID is set,
+// the index's rowid watermark advances past them. Callers hold d.vec.mu.
+func (d *DB) loadVectors(q string, args []any, trackRowID bool) error {
+	v := d.vec
+	rows, err := d.db.Query(q, args...)
+	if err != nil {
+		return fmt.Errorf("memory: load cell embeddings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			rowID int64
+			e     vecEntry
+			blob  []byte
+		)
+		if err := rows.Scan(&rowID, &e.cellID, &e.sourceType, &e.sourceID, &e.project, &blob); err != nil {
+			return fmt.Errorf("memory: scan cell embedding: %w", err)
+		}
+		if e.vec = normalize(DeserializeEmbedding(blob)); e.vec == nil {
+			e.vec = make([]float32, len(blob)/4) // zero vector; matches nothing
+		}
+		v.add(e)
+		if trackRowID && rowID > v.maxRowID {
+			v.maxRowID = rowID
+		}
+	}
+	return rows.Err()
+}
+
+// searchCellVectors returns the active cells most similar to queryVec,
+// filtered like SearchCellsFTS.
+func (d *DB) searchCellVectors(queryVec []float32, sourceType, project string, limit int) ([]vecHit, error) {
+	if len(queryVec) == 0 || limit <= 0 {
+		return nil, nil
+	}
+	if err := d.syncVectors(len(queryVec)); err != nil {
+		return nil, err
+	}
+
+	v := d.vec
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	q := normalize(queryVec)
+	var hits []vecHit
+	consider := func(pos int) {
+		e := &v.entries[pos]
+		if e.deleted || (sourceType != "" && e.sourceType != sourceType) {
+			return
+		}
+		if project != "" && e.project != project && e.project != "" {
+			return
+		}
+		hits = append(hits, vecHit{cellID: e.cellID, score: dot(q, e.vec)})
+	}
+
+	if v.centroids == nil {
+		for i := range v.entries {
+			consider(i)
+		}
+	} else {
+		for _, c := range nearestCentroids(v.centroids, q, ivfProbes(len(v.centroids))) {
+			for _, pos := range v.lists[c] {
+				consider(pos)
+			}
+		}
+	}
+
+	slices.SortFunc(hits, func(a, b vecHit) int { return cmp.Compare(b.score, a.score) })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// maybeTrain starts background IVF training when the index is large enough
+// and has grown (or been pruned) substantially since it was last trained.
+// Searches stay exact until training finishes. Callers hold mu.
+func (v *vectorIndex) maybeTrain() {
+	if v.training || v.live < ivfMinEntries {
+		return
+	}
+	if v.centroids != nil && v.live < 2*v.trainedSize && len(v.entries)-v.live < v.live/4 {
+		return
+	}
+
+	positions := make([]int, 0, v.live)
+	vecs := make([][]float32, 0, v.live)
+	for i, e := range v.entries {
+		if !e.deleted {
+			positions = append(positions, i)
+			vecs = append(vecs, e.vec)
+		}
+	}
+	snapshot, gen := len(v.entries), v.gen
+	v.training = true
+
+	go func() {
+		centroids := trainCentroids(vecs, int(math.Min(256, math.Sqrt(float64(len(vecs))))))
+		lists := make([][]int, len(centroids))
+		for i, vec := range vecs {
+			c := nearestCentroid(centroids, vec)
+			lists[c] = append(lists[c], positions[i])
+		}
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.training = false
+		if v.gen != gen {
+			return // reloaded while training; positions are meaningless
+		}
+		v.centroids, v.lists, v.assigned, v.trainedSize = centroids, lists, snapshot, len(vecs)
+		v.assignPending()
+	}()
+}
+
+// ivfProbes returns how many partitions a query scans.
+func ivfProbes(k int) int {
+	return max(8, k/8)
+}
+
+// trainCentroids runs a few rounds of spherical k-means on a sample of vecs.
+func trainCentroids(vecs [][]float32, k int) [][]float32 {
+	if k < 1 {
+		k = 1
+	}
+	stride := max(1, len(vecs)/(k*32))
+	var sample [][]float32
+	for i := 0; i < len(vecs); i += stride {
+		sample = append(sample, vecs[i])
+	}
+
+	centroids := make([][]float32, k)
+	for i := range centroids {
+		centroids[i] = slices.Clone(sample[i*len(sample)/k])
+	}
+	dim := len(sample[0])
+	for range 4 {
+		sums := make([][]float32, k)
+		for i := range sums {
+			sums[i] = make([]float32, dim)
+		}
+		for _, vec := range sample {
+			s := sums[nearestCentroid(centroids, vec)]
+			for j, x := range vec {
+				s[j] += x
+			}
+		}
+		for i, s := range sums {
+			if n := normalize(s); n != nil {
+				centroids[i] = n
+			}
+		}
+	}
+	return centroids
+}
+
+// nearestCentroid returns the index of the centroid most similar to vec.
+func nearestCentroid(centroids [][]float32, vec []float32) int {
+	best, bestScore := 0, float32(math.Inf(-1))
+	for i, c := range centroids {
+		if s := dot(c, vec); s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	return best
+}
+
+// nearestCentroids returns the indexes of the n centroids most similar to vec.
+func nearestCentroids(centroids [][]float32, vec []float32, n int) []int {
+	idx := make([]int, len(centroids))
+	scores := make([]float32, len(centroids))
+	for i, c := range centroids {
+		idx[i] = i
+		scores[i] = dot(c, vec)
+	}
+	slices.SortFunc(idx, func(a, b int) int { return cmp.Compare(scores[b], scores[a]) })
+	return idx[:min(n, len(idx))]
+}
+
+// normalize returns v scaled to unit length, or nil if v has zero magnitude.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return nil
+	}
+	inv := float32(1 / math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
+
+// dot returns the dot product of two equal-length vectors.
+func dot(a, b []float32) float32 {
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// placeholders returns n comma-separated SQL parameter markers.
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	b := make([]byte, 0, 2*n-1)
+	for i := range n {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, '?')
+	}
+	return string(b)
+}
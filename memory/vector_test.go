@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keywordEmbedder embeds text as a bag of concept dimensions, so texts that
+// share a concept are similar even when they share no words.
+type keywordEmbedder struct {
+	model    string
+	concepts [][]string // dimension i fires for any word in concepts[i]
+}
+
+func (k *keywordEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, len(k.concepts)+1)
+		v[len(k.concepts)] = 0.01 // keep every vector non-zero
+		lower := strings.ToLower(text)
+		for d, words := range k.concepts {
+			for _, w := range words {
+				if strings.Contains(lower, w) {
+					v[d]++
+				}
+			}
+		}
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func (k *keywordEmbedder) Dimension() int { return len(k.concepts) + 1 }
+func (k *keywordEmbedder) Model() string  { return k.model }
+
+var testConcepts = [][]string{
+	{"auth", "login", "password", "jwt", "credential"},
+	{"deploy", "kubernetes", "release", "rollout"},
+	{"database", "postgres", "sql", "migration"},
+}
+
+func openVectorTestDB(t *testing.T) *DB {
+	t.Helper()
+	mdb, err := Open(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mdb.Close() })
+	return mdb
+}
+
+func insertEmbeddedCell(t *testing.T, mdb *DB, e Embedder, c Cell) {
+	t.Helper()
+	vecs, _ := e.Embed(context.Background(), []string{c.Content})
+	c.Embedding = SerializeEmbedding(vecs[0])
+	if c.SourceType == "" {
+		c.SourceType = "conversation"
+	}
+	if c.SourceID == "" {
+		c.SourceID = "conv-1"
+	}
+	c.CellType = "fact"
+	c.Salience = 0.5
+	if err := mdb.InsertCell(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHybridSearchFindsSemanticMatches(t *testing.T) {
+	mdb := openVectorTestDB(t)
+	e := &keywordEmbedder{model: "kw", concepts: testConcepts}
+	insertEmbeddedCell(t, mdb, e, Cell{CellID: "auth", Content: "Passwords are hashed with argon2id"})
+	insertEmbeddedCell(t, mdb, e, Cell{CellID: "deploy", Content: "Releases roll out through Kubernetes"})
+	insertEmbeddedCell(t, mdb, e, Cell{CellID: "db", Content: "Postgres migrations run on startup"})
+
+	// "login" shares no words with the auth cell, so FTS alone finds nothing.
+	query := "login"
+	if results, _ := mdb.TwoTierSearch(query, nil, "", "", 10); len(results) != 0 {
+		t.Fatalf("expected no FTS-only results, got %+v", results)
+	}
+	qv, _ := e.Embed(context.Background(), []string{query})
+	results, err := mdb.TwoTierSearch(query, qv[0], "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].CellID != "auth" {
+		t.Fatalf("expected auth cell first, got %+v", results)
+	}
+
+	// A cell matching both rankings outranks one matching only by vector.
+	insertEmbeddedCell(t, mdb, e, Cell{CellID: "auth2", Content: "The login page rate-limits attempts"})
+	results, err = mdb.TwoTierSearch(query, qv[0], "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) < 2 || results[0].CellID != "auth2" || results[1].CellID != "auth" {
+		t.Fatalf("expected auth2 then auth, got %+v", results)
+	}
+}
+
+func TestVectorIndexTracksChanges(t *testing.T) {
+	mdb := openVectorTestDB(t)
+	e := &keywordEmbedder{model: "kw", concepts: testConcepts}
+	qv, _ := e.Embed(context.Background(), []string{"database"})
+	search := func() []string {
+		t.Helper()
+		hits, err := mdb.searchCellVectors(qv[0], "", "github.com/org/a", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, h := range hits {
+			if h.score > 0.5 {
+				ids = append(ids, h.cellID)
+			}
+		}
+		return ids
+	}
+
+	insertEmbeddedCell(t, mdb, e, Cell{CellID: "a", Content: "sql schema", Project: "github.com/org/a"})
+	if got := search(); len(got) != 1 {
+		t.Fatalf("after first insert: %v", got)
+	}
+
+	// New rows are picked up incrementally; other projects are filtered out.
+	insertEmbeddedCell(t, mdb, e, Cell{CellID: "b", SourceID: "conv-2", Content: "postgres tuning", Project: "github.com/org/a"})
+	insertEmbeddedCell(t, mdb, e, Cell{CellID: "c", Content: "database for b", Project: "github.com/org/b"})
+	if got := search(); len(got) != 2 {
+		t.Fatalf("after more inserts: %v", got)
+	}
+
+	if err := mdb.SupersedeCells([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(); len(got) != 1 || got[0] != "b" {
+		t.Fatalf("after supersede: %v", got)
+	}
+
+	if err := mdb.DeleteCellsBySource("conversation", "conv-2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(); len(got) != 0 {
+		t.Fatalf("after delete: %v", got)
+	}
+
+	// Content edits are re-embedded and reloaded.
+	content := "migration plan"
+	if _, err := mdb.UpdateCell(context.Background(), "c", CellUpdate{Content: &content}, e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mdb.db.Exec(`UPDATE cells SET project = 'github.com/org/a' WHERE cell_id = 'c'`); err != nil {
+		t.Fatal(err)
+	}
+	mdb.vec.markDirty("c")
+	if got := search(); len(got) != 1 || got[0] != "c" {
+		t.Fatalf("after update: %v", got)
+	}
+}
+
+func TestVectorIndexIVF(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a large index")
+	}
+	mdb := openVectorTestDB(t)
+	const dim = 32
+	rng := rand.New(rand.NewPCG(1, 2))
+	vecs := make([][]float32, ivfMinEntries+500)
+
+	tx, err := mdb.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range vecs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(rng.NormFloat64())
+		}
+		vecs[i] = v
+		_, err := tx.Exec(`INSERT INTO cells (cell_id, source_type, source_id, cell_type, content, embedding) VALUES (?, 'file', 'f', 'fact', 'x', ?)`,
+			fmt.Sprintf("cell_%d", i), SerializeEmbedding(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mdb.searchCellVectors(vecs[0], "", "", 1); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		mdb.vec.mu.Lock()
+		trained := mdb.vec.centroids != nil
+		mdb.vec.mu.Unlock()
+		if trained {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("IVF index was not trained")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Each stored vector is its own nearest neighbour: the query always
+	// probes the partition it was assigned to.
+	for _, i := range []int{0, 17, 1000, len(vecs) - 1} {
+		hits, err := mdb.searchCellVectors(vecs[i], "", "", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) != 1 || hits[0].score < 0.999 {
+			t.Errorf("vector %d: expected exact match, got %+v", i, hits)
+		}
+	}
+}
+
+func TestReembed(t *testing.T) {
+	mdb := openVectorTestDB(t)
+	ctx := context.Background()
+	e1 := &keywordEmbedder{model: "kw1", concepts: testConcepts}
+
+	insertEmbeddedCell(t, mdb, e1, Cell{CellID: "a", Content: "sql schema"})
+	if err := mdb.InsertCell(Cell{CellID: "b", SourceType: "conversation", SourceID: "conv-1", CellType: "fact", Salience: 0.5, Content: "jwt auth"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mdb.UpsertTopic(Topic{TopicID: "t1", Name: "auth", Summary: "login flow"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// First run fills in what is missing.
+	stats, err := mdb.Reembed(ctx, e1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Full || stats.Cells != 1 || stats.Topics != 1 {
+		t.Fatalf("first run stats = %+v, want 1 cell and 1 topic filled in", stats)
+	}
+
+	// Nothing to do with the same embedder.
+	stats, err = mdb.Reembed(ctx, e1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Cells != 0 || stats.Topics != 0 {
+		t.Fatalf("second run stats = %+v, want no work", stats)
+	}
+
+	// A new model with a different dimension re-embeds everything.
+	e2 := &keywordEmbedder{model: "kw2", concepts: append(testConcepts, []string{"cache"})}
+	stats, err = mdb.Reembed(ctx, e2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Full || stats.Cells != 2 || stats.Topics != 1 {
+		t.Fatalf("model change stats = %+v, want full re-embed", stats)
+	}
+	var n int
+	if err := mdb.db.QueryRow(`SELECT COUNT(*) FROM cells WHERE length(embedding) = ?`, e2.Dimension()*4).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("%d cells have the new dimension, want 2", n)
+	}
+
+	// Searches with the new embedder see the re-embedded cells.
+	qv, _ := e2.Embed(ctx, []string{"login"})
+	results, err := mdb.TwoTierSearch("login", qv[0], "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, r := range results {
+		if r.CellID == "b" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected re-embedded cell b in results, got %+v", results)
+	}
+}
@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// WorkspaceConfig controls which documents IndexWorkspace ingests.
+type WorkspaceConfig struct {
+	Enabled bool
+	// Include lists patterns matched against slash-separated paths relative
+	// to the git root. A pattern without a slash matches the base name
+	// anywhere ("README*"); "dir/**" matches everything under dir, and
+	// "**/dir/**" matches everything under any directory named dir.
+	// Other patterns are matched against the whole path with path.Match.
+	Include     []string
+	MaxFiles    int   // maximum documents indexed per workspace
+	MaxFileSize int64 // larger files are skipped
+}
+
+// DefaultWorkspaceConfig indexes READMEs, agent guidance files, docs/ trees
+// and architecture decision records.
+func DefaultWorkspaceConfig() WorkspaceConfig {
+	return WorkspaceConfig{
+		Enabled: true,
+		Include: []string{
+			"README*", "AGENTS.md", "AGENT.md", "CLAUDE.md", "CONTRIBUTING.md", "ARCHITECTURE.md", "DESIGN.md",
+			"docs/**", "doc/**", "adr/**", "**/adr/**", "**/adrs/**", "**/decisions/**",
+		},
+		MaxFiles:    500,
+		MaxFileSize: 256 * 1024,
+	}
+}
+
+// docExtensions are the file extensions treated as documents.
+var docExtensions = []string{".md", ".markdown", ".mdx", ".rst", ".txt"}
+
+// WorkspaceIndexStats summarizes an IndexWorkspace run.
+type WorkspaceIndexStats struct {
+	Files   int // documents matched
+	Indexed int // documents (re-)indexed because their content changed
+	Removed int // previously indexed documents that no longer match
+}
+
+// IndexWorkspace indexes the documents under the git repository root that
+// match cfg into file cells scoped to project. Files are listed with git, so
+// .gitignore is respected. Unchanged files are skipped by content hash, and
+// files that were indexed before but no longer exist or match are removed.
+func (d *DB) IndexWorkspace(ctx context.Context, root, project string, cfg WorkspaceConfig, embedder Embedder) (WorkspaceIndexStats, error) {
+	var stats WorkspaceIndexStats
+	files, err := listWorkspaceDocs(root, cfg)
+	if err != nil {
+		return stats, err
+	}
+	stats.Files = len(files)
+
+	seen := make(map[string]bool, len(files))
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		abs := filepath.Join(root, filepath.FromSlash(rel))
+		info, err := os.Stat(abs)
+		if err != nil || !info.Mode().IsRegular() || (cfg.MaxFileSize > 0 && info.Size() > cfg.MaxFileSize) {
+			continue
+		}
+		data, err := os.ReadFile(abs)
+		if err != nil || !utf8.Valid(data) || len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		seen[abs] = true
+
+		content := string(data)
+		indexed, err := d.IsIndexed("file", abs, hashString(content))
+		if err != nil {
+			return stats, err
+		}
+		if indexed {
+			continue
+		}
+		if err := d.IndexFile(ctx, abs, rel, project, content, embedder); err != nil {
+			return stats, err
+		}
+		stats.Indexed++
+	}
+
+	indexedFiles, err := d.indexedFilesUnder(root)
+	if err != nil {
+		return stats, err
+	}
+	for _, abs := range indexedFiles {
+		if seen[abs] {
+			continue
+		}
+		if err := d.DeleteCellsBySource("file", abs); err != nil {
+			return stats, err
+		}
+		if err := d.ClearIndexState("file", abs); err != nil {
+			return stats, err
+		}
+		stats.Removed++
+	}
+	return stats, nil
+}
+
+// listWorkspaceDocs returns the tracked and untracked-but-not-ignored files
+// under root that match cfg, shallowest first, capped at cfg.MaxFiles.
+func listWorkspaceDocs(root string, cfg WorkspaceConfig) ([]string, error) {
+	out, err := exec.Command("git", "-C", root, "ls-files", "-z", "--cached", "--others", "--exclude-standard").Output()
+	if err != nil {
+		return nil, fmt.Errorf("memory: list workspace files: %w", err)
+	}
+
+	var files []string
+	for rel := range strings.SplitSeq(string(out), "\x00") {
+		if rel == "" || !isWorkspaceDoc(rel, cfg.Include) {
+			continue
+		}
+		files = append(files, rel)
+	}
+	files = slices.Compact(files) // ls-files lists files with unmerged stages more than once
+	slices.SortStableFunc(files, func(a, b string) int {
+		return strings.Count(a, "/") - strings.Count(b, "/")
+	})
+	if cfg.MaxFiles > 0 && len(files) > cfg.MaxFiles {
+		files = files[:cfg.MaxFiles]
+	}
+	return files, nil
+}
+
+// isWorkspaceDoc reports whether rel is a document matching one of include.
+func isWorkspaceDoc(rel string, include []string) bool {
+	base := path.Base(rel)
+	ext := strings.ToLower(path.Ext(base))
+	if !slices.Contains(docExtensions, ext) && !(ext == "" && strings.HasPrefix(strings.ToUpper(base), "README")) {
+		return false
+	}
+	for _, p := range include {
+		if matchWorkspacePattern(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWorkspacePattern matches rel against one WorkspaceConfig.Include pattern.
+func matchWorkspacePattern(pattern, rel string) bool {
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		if name, anywhere := strings.CutPrefix(dir, "**/"); anywhere {
+			return strings.HasPrefix(rel, name+"/") || strings.Contains(rel, "/"+name+"/")
+		}
+		return strings.HasPrefix(rel, dir+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	ok, _ := path.Match(pattern, rel)
+	return ok
+}
+
+// indexedFilesUnder returns the file sources in index_state below root.
+func (d *DB) indexedFilesUnder(root string) ([]string, error) {
+	prefix := filepath.Clean(root) + string(filepath.Separator)
+	rows, err := d.db.Query(`SELECT source_id FROM index_state WHERE source_type = 'file'`)
+	if err != nil {
+		return nil, fmt.Errorf("memory: list indexed files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("memory: scan indexed file: %w", err)
+		}
+		if strings.HasPrefix(id, prefix) {
+			files = append(files, id)
+		}
+	}
+	return files, rows.Err()
+}
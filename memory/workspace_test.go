@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchWorkspacePattern(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"README*", "README.md", true},
+		{"README*", "pkg/README.md", true},
+		{"docs/**", "docs/guide.md", true},
+		{"docs/**", "docs/a/b.md", true},
+		{"docs/**", "pkg/docs/guide.md", false},
+		{"**/adr/**", "adr/0001.md", true},
+		{"**/adr/**", "design/adr/0001.md", true},
+		{"**/adr/**", "madr/0001.md", false},
+		{"design/*.md", "design/overview.md", true},
+		{"design/*.md", "design/x/overview.md", false},
+	}
+	for _, tt := range tests {
+		if got := matchWorkspacePattern(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchWorkspacePattern(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestIndexWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	root := t.TempDir()
+	write := func(rel, content string) {
+		t.Helper()
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out, err := exec.Command("git", "-C", root, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	write(".gitignore", "build/\n")
+	write("README.md", "# Widget\n\nWidgets are assembled by the zorblax pipeline.\n")
+	write("docs/deploy.md", "# Deploy\n\nDeploys run through the quuxify workflow.\n")
+	write("main.go", "package main // zorblax\n")
+	write("build/docs/generated.md", "# Generated\n\nzorblax generated docs\n")
+	write("notes.md", "# Notes\n\nzorblax scratch notes\n")
+
+	mdb, err := Open(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+	ctx := context.Background()
+	cfg := DefaultWorkspaceConfig()
+
+	stats, err := mdb.IndexWorkspace(ctx, root, "github.com/org/widget", cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 2 || stats.Indexed != 2 {
+		t.Fatalf("first run stats = %+v, want 2 files indexed", stats)
+	}
+
+	results, err := mdb.SearchCellsFTS("zorblax", "file", "github.com/org/widget", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].SourceName != "README.md" {
+		t.Fatalf("expected only README.md to match (ignored and non-doc files skipped), got %+v", results)
+	}
+
+	// Unchanged files are skipped.
+	stats, err = mdb.IndexWorkspace(ctx, root, "github.com/org/widget", cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Indexed != 0 {
+		t.Errorf("second run indexed %d files, want 0", stats.Indexed)
+	}
+
+	// Changed files are re-indexed and removed files are dropped.
+	write("README.md", "# Widget\n\nWidgets are assembled by the frobnicate pipeline.\n")
+	if err := os.Remove(filepath.Join(root, "docs/deploy.md")); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = mdb.IndexWorkspace(ctx, root, "github.com/org/widget", cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Indexed != 1 || stats.Removed != 1 {
+		t.Errorf("third run stats = %+v, want 1 indexed and 1 removed", stats)
+	}
+	if results, _ := mdb.SearchCellsFTS("quuxify", "file", "", 10); len(results) != 0 {
+		t.Errorf("expected removed file to be unsearchable, got %+v", results)
+	}
+	if results, _ := mdb.SearchCellsFTS("frobnicate", "file", "", 10); len(results) != 1 {
+		t.Errorf("expected updated README to be searchable, got %+v", results)
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/ensemble"
+)
+
+// ProviderEnsemble is the provider of ensemble models.
+const ProviderEnsemble Provider = "ensemble"
+
+// EnsembleConfig defines a model ID that combines several models; see the
+// ensemble package. It is configured in percy.json under "ensembles".
+type EnsembleConfig struct {
+	// ID is the model ID of the ensemble, such as "ensemble/plan".
+	ID string `json:"id"`
+	// Models are the member model IDs or selectors, such as "latest:planner".
+	Models []string `json:"models"`
+	// Judge picks or merges the responses; defaults to the first member.
+	Judge string `json:"judge,omitempty"`
+	// Mode is "best" (default) or "merge".
+	Mode ensemble.Mode `json:"mode,omitempty"`
+}
+
+// addEnsemble registers an ensemble of already registered models. Members
+// that are not available are left out.
+func (m *Manager) addEnsemble(cfg EnsembleConfig) error {
+	if cfg.ID == "" {
+		return fmt.Errorf("ensemble has no id")
+	}
+	switch cfg.Mode {
+	case "", ensemble.ModeBest, ensemble.ModeMerge:
+	default:
+		return fmt.Errorf("ensemble %s: unknown mode %q", cfg.ID, cfg.Mode)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.services[cfg.ID]; exists {
+		return fmt.Errorf("ensemble %s: model ID already in use", cfg.ID)
+	}
+	members, judge, missing, err := m.resolveEnsemble(cfg)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 && m.logger != nil {
+		m.logger.Warn("Ensemble members not available", "ensemble", cfg.ID, "models", missing)
+	}
+	svc := &ensemble.Service{
+		Members: members,
+		Judge:   judge,
+		Mode:    cfg.Mode,
+		// Selectors such as "latest:planner" and models of refreshed
+		// endpoints can change; resolve the members for each request.
+		Resolve: func() ([]ensemble.Member, llm.Service, error) {
+			m.mu.RLock()
+			defer m.mu.RUnlock()
+			members, judge, _, err := m.resolveEnsemble(cfg)
+			return members, judge, err
+		},
+	}
+
+	names := make([]string, len(members))
+	for i, member := range members {
+		names[i] = member.Name
+	}
+	m.services[cfg.ID] = serviceEntry{
+		service:     svc,
+		provider:    ProviderEnsemble,
+		modelID:     cfg.ID,
+		source:      string(ProviderEnsemble),
+		displayName: fmt.Sprintf("Ensemble: %s", strings.Join(names, " + ")),
+	}
+	m.modelOrder = append(m.modelOrder, cfg.ID)
+	return nil
+}
+
+// resolveEnsemble returns the available members and the judge of an
+// ensemble, and the members that are not available. m.mu must be held.
+func (m *Manager) resolveEnsemble(cfg EnsembleConfig) (members []ensemble.Member, judge llm.Service, missing []string, err error) {
+	for _, id := range cfg.Models {
+		entry, ok := m.resolveEntry(id)
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		members = append(members, ensemble.Member{Name: entry.modelID, Service: entry.service})
+	}
+	if len(members) < 2 {
+		return nil, nil, missing, fmt.Errorf("ensemble %s: needs at least two available models (unavailable: %s)", cfg.ID, strings.Join(missing, ", "))
+	}
+	if cfg.Judge != "" {
+		entry, ok := m.resolveEntry(cfg.Judge)
+		if !ok {
+			return nil, nil, missing, fmt.Errorf("ensemble %s: judge %s is not available", cfg.ID, cfg.Judge)
+		}
+		judge = entry.service
+	}
+	return members, judge, missing, nil
+}
+
+// resolveEntry returns the entry for a model ID or selector. m.mu must be held.
+func (m *Manager) resolveEntry(id string) (serviceEntry, bool) {
+	resolved, err := m.resolveModelID(id)
+	if err != nil {
+		return serviceEntry{}, false
+	}
+	entry, ok := m.services[resolved]
+	return entry, ok
+}
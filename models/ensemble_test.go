@@ -0,0 +1,65 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/ensemble"
+)
+
+func TestEnsembles(t *testing.T) {
+	manager, err := NewManager(&Config{
+		AnthropicAPIKey: "test-key",
+		OpenAIAPIKey:    "test-key",
+		Ensembles: []EnsembleConfig{
+			{ID: "ensemble/plan", Models: []string{"latest:planner", "latest:verifier", "no-such-model"}, Judge: "predictable", Mode: ensemble.ModeMerge},
+			{ID: "ensemble/lonely", Models: []string{"latest:planner", "no-such-model"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := manager.GetService("ensemble/plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := svc.(ConfigInfo).ConfigDetails()
+	if details["members"] != "claude-opus-4.7,gpt-5.3-codex" || details["mode"] != "merge" {
+		t.Errorf("ConfigDetails() = %v", details)
+	}
+	info := manager.GetModelInfo("ensemble/plan")
+	if info == nil || info.DisplayName != "Ensemble: claude-opus-4.7 + gpt-5.3-codex" {
+		t.Errorf("GetModelInfo() = %+v", info)
+	}
+	if manager.HasModel("ensemble/lonely") {
+		t.Error("ensemble with one available member registered")
+	}
+
+	// Members are resolved for each request: without a codex model the
+	// verifier selector no longer resolves.
+	manager.mu.Lock()
+	for _, id := range manager.modelOrder {
+		if strings.Contains(id, "-codex") {
+			delete(manager.services, id)
+		}
+	}
+	manager.mu.Unlock()
+	if _, err := svc.Do(context.Background(), &llm.Request{}); err == nil || !strings.Contains(err.Error(), "needs at least two") {
+		t.Errorf("Do() error = %v after a member became unavailable", err)
+	}
+
+	for _, cfg := range []EnsembleConfig{
+		{ID: "ensemble/plan", Models: []string{"latest:planner", "latest:verifier"}},
+		{ID: "predictable", Models: []string{"latest:planner", "latest:verifier"}},
+		{ID: "ensemble/odd", Models: []string{"latest:planner", "latest:verifier"}, Mode: "vote"},
+		{ID: "ensemble/judged", Models: []string{"latest:planner", "latest:verifier"}, Judge: "no-such-model"},
+		{Models: []string{"latest:planner", "latest:verifier"}},
+	} {
+		if err := manager.addEnsemble(cfg); err == nil {
+			t.Errorf("addEnsemble(%+v) succeeded", cfg)
+		}
+	}
+}
@@ -6,15 +6,19 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tgruben-circuit/percy/db"
 	"github.com/tgruben-circuit/percy/db/generated"
 	"github.com/tgruben-circuit/percy/llm"
 	"github.com/tgruben-circuit/percy/llm/ant"
+	"github.com/tgruben-circuit/percy/llm/bedrock"
 	"github.com/tgruben-circuit/percy/llm/gem"
 	"github.com/tgruben-circuit/percy/llm/llmhttp"
 	"github.com/tgruben-circuit/percy/llm/oai"
+	"github.com/tgruben-circuit/percy/llm/replay"
+	"github.com/tgruben-circuit/percy/llm/vertex"
 	"github.com/tgruben-circuit/percy/loop"
 )
 
@@ -28,6 +32,8 @@ const (
 	ProviderGemini    Provider = "gemini"
 	ProviderBuiltIn   Provider = "builtin"
 	ProviderOllama    Provider = "ollama"
+	ProviderBedrock   Provider = "bedrock"
+	ProviderVertex    Provider = "vertex"
 )
 
 // ModelSource describes where a model's configuration comes from
@@ -37,8 +43,14 @@ const (
 	SourceGateway ModelSource = "exe.dev gateway"
 	SourceEnvVar  ModelSource = "env"    // Will be combined with env var name
 	SourceCustom  ModelSource = "custom" // User-configured custom model
+	SourceReplay  ModelSource = "replay" // Recorded fixture played back
 )
 
+// ReplayPrefix marks model IDs that play back a recorded fixture, as in
+// "replay:testdata/replay/bash_roundtrip.json". The ".json" suffix may be
+// omitted.
+const ReplayPrefix = "replay:"
+
 // Model represents a configured LLM model in Percy
 type Model struct {
 	// ID is the user-facing identifier for this model
@@ -123,10 +135,28 @@ type Config struct {
 	// Default: "http://localhost:11434". Set to "" to disable.
 	OllamaURL string
 
+	// OpenAIEndpoints are OpenAI-compatible servers whose models are
+	// discovered and periodically refreshed.
+	OpenAIEndpoints []OpenAIEndpoint
+
+	// Ensembles define model IDs that combine several of the other models.
+	Ensembles []EnsembleConfig
+
 	Logger *slog.Logger
 
 	// Database for recording LLM requests (optional)
 	DB *db.DB
+
+	// RecordPath, if set, is a replay fixture that every service returned by
+	// GetService records its requests and responses into.
+	RecordPath string
+
+	// ReplayModels lists "replay:<fixture>" model IDs to register.
+	ReplayModels []string
+
+	// Catalog adds or overrides model catalog entries (limits, capabilities
+	// and pricing). Entries are merged into the built-in catalog by name or alias.
+	Catalog []llm.ModelSpec
 }
 
 // getAnthropicURL returns the Anthropic API URL, with gateway suffix if gateway is set
@@ -441,12 +471,16 @@ func Default() Model {
 
 // Manager manages LLM services for all configured models
 type Manager struct {
+	mu         sync.RWMutex // guards services and modelOrder, which endpoint discovery updates
 	services   map[string]serviceEntry
 	modelOrder []string // ordered list of model IDs (built-in first, then custom)
 	logger     *slog.Logger
 	db         *db.DB       // for custom models and LLM request recording
 	httpc      *http.Client // HTTP client with recording middleware
 	cfg        *Config      // retained for refreshing custom models
+	recorder   *replay.Recorder
+
+	stopDiscovery context.CancelFunc // stops refreshing OpenAI-compatible endpoints
 }
 
 type serviceEntry struct {
@@ -456,6 +490,7 @@ type serviceEntry struct {
 	source      string // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY")
 	displayName string // For custom models, the user-provided display name
 	tags        string // For custom models, user-provided tags
+	endpoint    string // For discovered OpenAI-compatible models, the endpoint name
 }
 
 // ConfigInfo is an optional interface that services can implement to provide configuration details for logging
@@ -541,6 +576,14 @@ func (l *loggingService) MaxImageDimension() int {
 	return l.service.MaxImageDimension()
 }
 
+// CountTokens delegates to the underlying service if it supports it
+func (l *loggingService) CountTokens(ctx context.Context, req *llm.Request) (int, error) {
+	if tc, ok := l.service.(llm.TokenCounter); ok {
+		return tc.CountTokens(ctx, req)
+	}
+	return 0, llm.ErrNoTokenCounter
+}
+
 // UseSimplifiedPatch delegates to the underlying service if it supports it
 func (l *loggingService) UseSimplifiedPatch() bool {
 	if sp, ok := l.service.(llm.SimplifiedPatcher); ok {
@@ -551,6 +594,8 @@ func (l *loggingService) UseSimplifiedPatch() bool {
 
 // NewManager creates a new Manager with all models configured
 func NewManager(cfg *Config) (*Manager, error) {
+	llm.RegisterModels(cfg.Catalog...)
+
 	manager := &Manager{
 		services: make(map[string]serviceEntry),
 		logger:   cfg.Logger,
@@ -647,6 +692,20 @@ func NewManager(cfg *Config) (*Manager, error) {
 		manager.modelOrder = append(manager.modelOrder, model.ID)
 	}
 
+	for _, id := range cfg.ReplayModels {
+		if err := manager.addReplayModel(id); err != nil && cfg.Logger != nil {
+			cfg.Logger.Warn("Failed to load replay fixture", "model", id, "error", err)
+		}
+	}
+
+	if cfg.RecordPath != "" {
+		manager.recorder = replay.NewRecorder(cfg.RecordPath, replay.NewScrubber(
+			cfg.AnthropicAPIKey, cfg.OpenAIAPIKey, cfg.GeminiAPIKey, cfg.FireworksAPIKey))
+		if cfg.Logger != nil {
+			cfg.Logger.Info("Recording LLM exchanges", "fixture", cfg.RecordPath)
+		}
+	}
+
 	// Load custom models from database
 	if err := manager.loadCustomModels(); err != nil && cfg.Logger != nil {
 		cfg.Logger.Warn("Failed to load custom models", "error", err)
@@ -683,9 +742,60 @@ func NewManager(cfg *Config) (*Manager, error) {
 		}
 	}
 
+	if len(cfg.OpenAIEndpoints) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		manager.stopDiscovery = cancel
+		for _, ep := range cfg.OpenAIEndpoints {
+			manager.refreshEndpoint(ep)
+			if interval := ep.refreshInterval(); interval > 0 {
+				go manager.watchEndpoint(ctx, ep, interval)
+			}
+		}
+	}
+
+	for _, ec := range cfg.Ensembles {
+		if err := manager.addEnsemble(ec); err != nil && cfg.Logger != nil {
+			cfg.Logger.Warn("Failed to add ensemble", "ensemble", ec.ID, "error", err)
+		}
+	}
+
 	return manager, nil
 }
 
+// Close stops refreshing the models of OpenAI-compatible endpoints.
+func (m *Manager) Close() {
+	if m.stopDiscovery != nil {
+		m.stopDiscovery()
+	}
+}
+
+// addReplayModel registers a model that plays back the fixture named by id,
+// which must start with ReplayPrefix.
+func (m *Manager) addReplayModel(id string) error {
+	path, ok := strings.CutPrefix(id, ReplayPrefix)
+	if !ok || path == "" {
+		return fmt.Errorf("replay model %q must be of the form %s<fixture>", id, ReplayPrefix)
+	}
+	if !strings.HasSuffix(path, ".json") {
+		path += ".json"
+	}
+	player, err := replay.Open(path)
+	if err != nil {
+		return err
+	}
+	if _, exists := m.services[id]; !exists {
+		m.modelOrder = append(m.modelOrder, id)
+	}
+	m.services[id] = serviceEntry{
+		service:     player,
+		provider:    ProviderBuiltIn,
+		modelID:     id,
+		source:      string(SourceReplay),
+		displayName: id,
+	}
+	return nil
+}
+
 // loadCustomModels loads custom models from the database into the manager.
 // It adds them after built-in models in the order.
 func (m *Manager) loadCustomModels() error {
@@ -708,6 +818,9 @@ func (m *Manager) loadCustomModels() error {
 		if svc == nil {
 			continue
 		}
+		if spec, ok := customModelSpec(&model); ok {
+			svc = &customSpecService{Service: svc, spec: spec}
+		}
 
 		m.services[model.ModelID] = serviceEntry{
 			service:     svc,
@@ -730,6 +843,9 @@ func (m *Manager) RefreshCustomModels() error {
 		return nil
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	// Remove existing custom models from services and modelOrder
 	newOrder := make([]string, 0, len(m.modelOrder))
 	for _, id := range m.modelOrder {
@@ -754,10 +870,15 @@ func (m *Manager) GetService(modelID string) (llm.Service, error) {
 	}
 	modelID = resolved
 
+	m.mu.RLock()
 	entry, ok := m.services[modelID]
+	m.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("unsupported model: %s", modelID)
 	}
+	if m.recorder != nil && entry.source != string(SourceReplay) {
+		entry.service = m.recorder.Wrap(entry.service, entry.modelID)
+	}
 
 	// Wrap with logging if we have a logger
 	if m.logger != nil {
@@ -774,13 +895,20 @@ func (m *Manager) GetService(modelID string) (llm.Service, error) {
 
 // ResolveModelID resolves symbolic model selectors to concrete available model IDs.
 func (m *Manager) ResolveModelID(modelID string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.resolveModelID(modelID)
+}
+
+// resolveModelID is ResolveModelID for callers that hold m.mu.
+func (m *Manager) resolveModelID(modelID string) (string, error) {
 	switch modelID {
 	case "latest:claude-opus", "latest:planner":
 		return m.firstAvailablePrefix("claude-opus-")
 	case "latest:gpt-codex", "latest:verifier":
 		return m.firstAvailableContains("-codex")
 	default:
-		if m.HasModel(modelID) {
+		if _, ok := m.services[modelID]; ok {
 			return modelID, nil
 		}
 		return "", fmt.Errorf("unsupported model: %s", modelID)
@@ -808,6 +936,8 @@ func (m *Manager) firstAvailableContains(substr string) (string, error) {
 // GetAvailableModels returns a list of available model IDs.
 // Returns union of built-in models (in order) followed by custom models.
 func (m *Manager) GetAvailableModels() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	// Return a copy to prevent external modification
 	result := make([]string, len(m.modelOrder))
 	copy(result, m.modelOrder)
@@ -816,6 +946,8 @@ func (m *Manager) GetAvailableModels() []string {
 
 // HasModel reports whether the manager has a service for the given model ID
 func (m *Manager) HasModel(modelID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	_, ok := m.services[modelID]
 	return ok
 }
@@ -829,6 +961,8 @@ type ModelInfo struct {
 
 // GetModelInfo returns the display name, tags, and source for a model
 func (m *Manager) GetModelInfo(modelID string) *ModelInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	entry, ok := m.services[modelID]
 	if !ok {
 		return nil
@@ -840,9 +974,108 @@ func (m *Manager) GetModelInfo(modelID string) *ModelInfo {
 	}
 }
 
+// ModelSpec returns the catalog entry for the model a service requests.
+func (m *Manager) ModelSpec(modelID string) (llm.ModelSpec, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.services[modelID]
+	if !ok {
+		return llm.ModelSpec{}, false
+	}
+	if cs, ok := entry.service.(*customSpecService); ok {
+		return cs.modelSpec(), true
+	}
+	return llm.ServiceModelSpec(entry.service)
+}
+
+// customSpecService applies a custom model's context window and pricing
+// overrides. They are kept on the service rather than registered in the
+// process-wide catalog, which is keyed by model name and shared by every
+// model with that name, built-ins included.
+type customSpecService struct {
+	llm.Service
+	spec llm.ModelSpec
+}
+
+// modelSpec returns the catalog entry for the model with the overrides applied.
+func (s *customSpecService) modelSpec() llm.ModelSpec {
+	spec, ok := llm.ServiceModelSpec(s.Service)
+	if !ok {
+		spec = llm.ModelSpec{Name: s.spec.Name}
+	}
+	if s.spec.ContextWindow > 0 {
+		spec.ContextWindow = s.spec.ContextWindow
+	}
+	if s.spec.Pricing != nil {
+		spec.Pricing = s.spec.Pricing
+	}
+	return spec
+}
+
+// Do forwards to the wrapped service and prices the response with the
+// custom pricing when the provider did not report a cost.
+func (s *customSpecService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	resp, err := s.Service.Do(ctx, req)
+	if err != nil || resp == nil || s.spec.Pricing == nil || resp.Usage.CostUSD != 0 {
+		return resp, err
+	}
+	u := &resp.Usage
+	u.CostUSD = s.spec.Pricing.CostUSD(u.InputTokens, u.OutputTokens, u.CacheReadInputTokens, u.CacheCreationInputTokens)
+	return resp, nil
+}
+
+// TokenContextWindow returns the custom context window, if set.
+func (s *customSpecService) TokenContextWindow() int {
+	if s.spec.ContextWindow > 0 {
+		return s.spec.ContextWindow
+	}
+	return s.Service.TokenContextWindow()
+}
+
+// ModelName delegates to the wrapped service.
+func (s *customSpecService) ModelName() string {
+	if n, ok := s.Service.(llm.ModelNamer); ok {
+		return n.ModelName()
+	}
+	return s.spec.Name
+}
+
+// CountTokens delegates to the wrapped service if it supports it.
+func (s *customSpecService) CountTokens(ctx context.Context, req *llm.Request) (int, error) {
+	if tc, ok := s.Service.(llm.TokenCounter); ok {
+		return tc.CountTokens(ctx, req)
+	}
+	return 0, llm.ErrNoTokenCounter
+}
+
+// UseSimplifiedPatch delegates to the wrapped service.
+func (s *customSpecService) UseSimplifiedPatch() bool {
+	return llm.UseSimplifiedPatch(s.Service)
+}
+
+// customModelSpec returns the catalog overrides set on a custom model, if any.
+func customModelSpec(model *generated.Model) (llm.ModelSpec, bool) {
+	spec := llm.ModelSpec{Name: model.ModelName, ContextWindow: int(model.ContextWindow)}
+	if model.InputPrice > 0 || model.OutputPrice > 0 {
+		spec.Pricing = &llm.Pricing{
+			InputPerMillion:      model.InputPrice,
+			OutputPerMillion:     model.OutputPrice,
+			CacheReadPerMillion:  model.CacheReadPrice,
+			CacheWritePerMillion: model.CacheWritePrice,
+		}
+	}
+	if spec.Name == "" || (spec.ContextWindow == 0 && spec.Pricing == nil) {
+		return llm.ModelSpec{}, false
+	}
+	return spec, true
+}
+
 // createServiceFromModel creates an LLM service from a database model configuration
 func (m *Manager) createServiceFromModel(model *generated.Model) llm.Service {
 	thinkingLevel := llm.ParseThinkingLevel(model.ThinkingLevel)
+	if spec, ok := llm.LookupModel(model.ModelName); ok && !spec.SupportsThinking() {
+		thinkingLevel = llm.ThinkingLevelOff
+	}
 	switch model.ProviderType {
 	case "anthropic":
 		return &ant.Service{
@@ -902,6 +1135,33 @@ func (m *Manager) createServiceFromModel(model *generated.Model) llm.Service {
 			MaxTokens: int(model.MaxTokens),
 			HTTPC:     m.httpc,
 		}
+	case "bedrock":
+		// endpoint is a region or runtime URL; api_key is a Bedrock API key,
+		// "ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]", or empty for the AWS environment.
+		region, endpoint := bedrock.ParseEndpoint(model.Endpoint)
+		apiKey, creds := bedrock.ParseAPIKey(model.ApiKey)
+		return &bedrock.Service{
+			HTTPC:         m.httpc,
+			Region:        region,
+			Endpoint:      endpoint,
+			Model:         model.ModelName,
+			APIKey:        apiKey,
+			Credentials:   creds,
+			ThinkingLevel: thinkingLevel,
+		}
+	case "vertex":
+		// endpoint is "PROJECT/REGION" or a URL; api_key is credentials JSON,
+		// a path to it, or empty for Application Default Credentials.
+		project, region, endpoint := vertex.ParseEndpoint(model.Endpoint)
+		return &vertex.Service{
+			HTTPC:         m.httpc,
+			Project:       project,
+			Region:        region,
+			Endpoint:      endpoint,
+			Model:         model.ModelName,
+			Credentials:   model.ApiKey,
+			ThinkingLevel: thinkingLevel,
+		}
 	default:
 		if m.logger != nil {
 			m.logger.Error("Unknown provider type for model", "model_id", model.ModelID, "provider_type", model.ProviderType)
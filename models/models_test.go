@@ -4,9 +4,14 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"testing"
 
+	"github.com/tgruben-circuit/percy/db/generated"
 	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/bedrock"
+	"github.com/tgruben-circuit/percy/llm/replay"
+	"github.com/tgruben-circuit/percy/llm/vertex"
 )
 
 func TestAll(t *testing.T) {
@@ -322,6 +327,55 @@ func TestManagerResolveModelID(t *testing.T) {
 	}
 }
 
+func TestManagerReplayAndRecord(t *testing.T) {
+	replayID := ReplayPrefix + "../llm/replay/testdata/bash_roundtrip"
+	recordPath := filepath.Join(t.TempDir(), "recorded.json")
+	manager, err := NewManager(&Config{
+		ReplayModels: []string{replayID, ReplayPrefix + "missing"},
+		RecordPath:   recordPath,
+	})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if manager.HasModel(ReplayPrefix + "missing") {
+		t.Error("a missing fixture should not be registered")
+	}
+	if info := manager.GetModelInfo(replayID); info == nil || info.Source != string(SourceReplay) {
+		t.Fatalf("GetModelInfo(%q) = %+v", replayID, info)
+	}
+
+	svc, err := manager.GetService(replayID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := svc.Do(context.Background(), &llm.Request{
+		Tools:    []*llm.Tool{{Name: "bash"}},
+		Messages: []llm.Message{llm.UserStringMessage("list the files")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StopReason != llm.StopReasonToolUse {
+		t.Errorf("replayed stop reason = %v, want tool use", resp.StopReason)
+	}
+
+	// Other models are recorded; the replay model is not.
+	svc, err = manager.GetService("predictable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hello")}}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := replay.Load(recordPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Exchanges) != 1 || f.Exchanges[0].Model != "predictable" {
+		t.Errorf("recorded exchanges = %+v, want one from predictable", f.Exchanges)
+	}
+}
+
 func TestManagerResolveModelIDUnavailable(t *testing.T) {
 	manager, err := NewManager(&Config{})
 	if err != nil {
@@ -559,3 +613,82 @@ func TestGetAvailableModelsUnion(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateCloudProviderServices(t *testing.T) {
+	m := &Manager{}
+
+	svc := m.createServiceFromModel(&generated.Model{
+		ProviderType:  "bedrock",
+		Endpoint:      "eu-central-1",
+		ApiKey:        "AKIDEXAMPLE:secret",
+		ModelName:     "eu.anthropic.claude-sonnet-4-5-20250929-v1:0",
+		ThinkingLevel: "high",
+	})
+	b, ok := svc.(*bedrock.Service)
+	if !ok {
+		t.Fatalf("bedrock model created %T", svc)
+	}
+	if b.Region != "eu-central-1" || b.Endpoint != "" || b.APIKey != "" || b.Credentials == nil || b.Credentials.AccessKeyID != "AKIDEXAMPLE" {
+		t.Errorf("unexpected bedrock service: %+v", b)
+	}
+	if b.ThinkingLevel != llm.ThinkingLevelHigh {
+		t.Errorf("bedrock thinking level = %v", b.ThinkingLevel)
+	}
+
+	svc = m.createServiceFromModel(&generated.Model{
+		ProviderType: "vertex",
+		Endpoint:     "my-project/us-east5",
+		ModelName:    "gemini-2.5-pro",
+	})
+	v, ok := svc.(*vertex.Service)
+	if !ok {
+		t.Fatalf("vertex model created %T", svc)
+	}
+	if v.Project != "my-project" || v.Region != "us-east5" || v.Credentials != "" || v.IsClaude() {
+		t.Errorf("unexpected vertex service: %+v", v)
+	}
+}
+
+func TestCustomModelSpecIsPerModel(t *testing.T) {
+	name := "claude-sonnet-4-5-20250929"
+	builtin, ok := llm.LookupModel(name)
+	if !ok {
+		t.Fatalf("%s is not in the catalog", name)
+	}
+
+	m := &Manager{services: map[string]serviceEntry{}}
+	m.services["plain"] = serviceEntry{
+		service: m.createServiceFromModel(&generated.Model{ProviderType: "anthropic", ModelName: name}),
+		source:  string(SourceCustom),
+	}
+	custom := &generated.Model{ProviderType: "anthropic", ModelName: name, ContextWindow: 50000, InputPrice: 1, OutputPrice: 2}
+	spec, ok := customModelSpec(custom)
+	if !ok {
+		t.Fatal("expected overrides on the custom model")
+	}
+	m.services["tuned"] = serviceEntry{
+		service: &customSpecService{Service: m.createServiceFromModel(custom), spec: spec},
+		source:  string(SourceCustom),
+	}
+
+	if got := m.services["tuned"].service.TokenContextWindow(); got != 50000 {
+		t.Errorf("tuned context window = %d, want 50000", got)
+	}
+	if got := m.services["plain"].service.TokenContextWindow(); got != builtin.ContextWindow {
+		t.Errorf("plain context window = %d, want catalog %d", got, builtin.ContextWindow)
+	}
+	if got, _ := llm.LookupModel(name); got.ContextWindow != builtin.ContextWindow || got.Pricing != builtin.Pricing {
+		t.Errorf("custom overrides leaked into the catalog: %+v", got)
+	}
+
+	tuned, ok := m.ModelSpec("tuned")
+	if !ok || tuned.ContextWindow != 50000 || tuned.Pricing == nil || tuned.Pricing.InputPerMillion != 1 {
+		t.Errorf("tuned spec = %+v", tuned)
+	}
+	if tuned.MaxOutputTokens != builtin.MaxOutputTokens {
+		t.Errorf("tuned spec lost catalog max output tokens: %+v", tuned)
+	}
+	if cost := tuned.Pricing.CostUSD(1_000_000, 1_000_000, 0, 0); cost != 3 {
+		t.Errorf("tuned cost = %v, want 3", cost)
+	}
+}
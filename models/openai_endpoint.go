@@ -0,0 +1,291 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/oai"
+)
+
+// ProviderOpenAICompatible is the provider of models discovered on an
+// OpenAIEndpoint.
+const ProviderOpenAICompatible Provider = "openai-compatible"
+
+// defaultEndpointRefresh is how often an endpoint's model list is refreshed
+// unless its config says otherwise.
+const defaultEndpointRefresh = time.Minute
+
+// ToolSupport describes how well an OpenAI-compatible server handles tool calls.
+type ToolSupport string
+
+const (
+	// ToolSupportFull is for servers that parse several tool calls per
+	// response and handle Percy's full patch schema.
+	ToolSupportFull ToolSupport = "full"
+	// ToolSupportBasic is for servers whose tool calling is limited: their
+	// models get the simplified patch schema and make one tool call at a time.
+	ToolSupportBasic ToolSupport = "basic"
+)
+
+// OpenAIEndpoint is an OpenAI-compatible server, such as llama.cpp, vLLM or
+// LM Studio, whose models are discovered by listing /v1/models. It is
+// configured in percy.json under "openai_endpoints".
+type OpenAIEndpoint struct {
+	// Name prefixes the IDs of the endpoint's models, as in "lmstudio/qwen3-8b".
+	Name string `json:"name"`
+	// URL is the base URL of the API, such as "http://localhost:1234/v1".
+	URL string `json:"url"`
+	// APIKey is sent as a bearer token; most local servers ignore it.
+	APIKey string `json:"api_key,omitempty"`
+	// Refresh is how often the model list is refreshed, as a Go duration
+	// such as "30s". Default: "1m". "0" disables refreshing.
+	Refresh string `json:"refresh,omitempty"`
+	// Tools overrides the probed tool support of the endpoint's models.
+	Tools ToolSupport `json:"tools,omitempty"`
+}
+
+// refreshInterval returns how often the endpoint's model list is refreshed,
+// or 0 if it is not.
+func (e OpenAIEndpoint) refreshInterval() time.Duration {
+	if e.Refresh == "" {
+		return defaultEndpointRefresh
+	}
+	d, err := time.ParseDuration(e.Refresh)
+	if err != nil || d < 0 {
+		return defaultEndpointRefresh
+	}
+	return d
+}
+
+// root returns the server URL without the API version path, where llama.cpp
+// and LM Studio serve their own endpoints.
+func (e OpenAIEndpoint) root() string {
+	base := strings.TrimSuffix(e.URL, "/")
+	return strings.TrimSuffix(base, "/v1")
+}
+
+// endpointModel is what discovery learns about a model on an endpoint.
+type endpointModel struct {
+	name          string
+	contextWindow int
+	tools         ToolSupport
+}
+
+// DiscoverOpenAIModels lists the models of an OpenAI-compatible endpoint.
+// Context windows and tool support are probed from the server-specific
+// endpoints of llama.cpp (/props) and LM Studio (/api/v0/models), or from
+// fields vLLM and llama.cpp add to /v1/models; models whose tool support is
+// unknown are assumed to have full support.
+func DiscoverOpenAIModels(ep OpenAIEndpoint, httpc *http.Client) ([]Model, error) {
+	if httpc == nil {
+		httpc = &http.Client{Timeout: 2 * time.Second}
+	}
+	found, err := probeEndpoint(ep, httpc)
+	if err != nil {
+		return nil, fmt.Errorf("%s discovery: %w", ep.Name, err)
+	}
+
+	models := make([]Model, 0, len(found))
+	for _, em := range found {
+		em.tools = cmp.Or(ep.Tools, em.tools, ToolSupportFull)
+		endpoint := strings.TrimSuffix(ep.URL, "/")
+		description := fmt.Sprintf("%s: %s", ep.Name, em.name)
+		if em.contextWindow > 0 {
+			description += fmt.Sprintf(" (%dk context)", em.contextWindow/1000)
+		}
+		models = append(models, Model{
+			ID:          ep.Name + "/" + em.name,
+			Provider:    ProviderOpenAICompatible,
+			Description: description,
+			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
+				maxTokens := oai.DefaultMaxTokens
+				if em.contextWindow > 0 {
+					// Leave most of a small local context for the input.
+					maxTokens = min(maxTokens, em.contextWindow/4)
+				}
+				return &oai.Service{
+					Model: oai.Model{
+						ModelName:           em.name,
+						URL:                 endpoint,
+						UseMaxTokens:        true,
+						UseSimplifiedPatch:  em.tools == ToolSupportBasic,
+						NoParallelToolCalls: em.tools == ToolSupportBasic,
+						ContextWindow:       em.contextWindow,
+					},
+					APIKey:    cmp.Or(ep.APIKey, "none"),
+					ModelURL:  endpoint,
+					MaxTokens: maxTokens,
+					HTTPC:     httpc,
+				}, nil
+			},
+		})
+	}
+	return models, nil
+}
+
+// probeEndpoint lists the models of ep and what the server reports about them.
+func probeEndpoint(ep OpenAIEndpoint, httpc *http.Client) ([]endpointModel, error) {
+	var list struct {
+		Data []struct {
+			ID            string `json:"id"`
+			MaxModelLen   int    `json:"max_model_len"`  // vLLM
+			ContextLength int    `json:"context_length"` // various
+			Meta          struct {
+				NCtxTrain int `json:"n_ctx_train"` // llama.cpp
+			} `json:"meta"`
+		} `json:"data"`
+	}
+	if err := getJSON(httpc, ep, strings.TrimSuffix(ep.URL, "/")+"/models", &list); err != nil {
+		return nil, err
+	}
+
+	// llama.cpp serves a single model; /props has its actual context size
+	// and what its chat template supports.
+	var props struct {
+		DefaultGenerationSettings struct {
+			NCtx int `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+		ChatTemplateCaps *struct {
+			SupportsTools             bool `json:"supports_tools"`
+			SupportsParallelToolCalls bool `json:"supports_parallel_tool_calls"`
+		} `json:"chat_template_caps"`
+	}
+	hasProps := len(list.Data) == 1 && getJSON(httpc, ep, ep.root()+"/props", &props) == nil
+
+	// LM Studio reports loaded context lengths and capabilities.
+	var lmstudio struct {
+		Data []struct {
+			ID                  string   `json:"id"`
+			LoadedContextLength int      `json:"loaded_context_length"`
+			MaxContextLength    int      `json:"max_context_length"`
+			Capabilities        []string `json:"capabilities"`
+		} `json:"data"`
+	}
+	_ = getJSON(httpc, ep, ep.root()+"/api/v0/models", &lmstudio)
+
+	models := make([]endpointModel, 0, len(list.Data))
+	for _, d := range list.Data {
+		if d.ID == "" {
+			continue
+		}
+		em := endpointModel{
+			name:          d.ID,
+			contextWindow: cmp.Or(d.MaxModelLen, d.ContextLength),
+		}
+		if hasProps {
+			em.contextWindow = cmp.Or(props.DefaultGenerationSettings.NCtx, em.contextWindow)
+			if caps := props.ChatTemplateCaps; caps != nil {
+				em.tools = ToolSupportBasic
+				if caps.SupportsTools && caps.SupportsParallelToolCalls {
+					em.tools = ToolSupportFull
+				}
+			}
+		}
+		for _, lm := range lmstudio.Data {
+			if lm.ID != d.ID {
+				continue
+			}
+			em.contextWindow = cmp.Or(lm.LoadedContextLength, em.contextWindow, lm.MaxContextLength)
+			em.tools = ToolSupportBasic
+			if slices.Contains(lm.Capabilities, "tool_use") {
+				em.tools = ToolSupportFull
+			}
+		}
+		em.contextWindow = cmp.Or(em.contextWindow, d.Meta.NCtxTrain)
+		models = append(models, em)
+	}
+	return models, nil
+}
+
+// getJSON fetches url from ep and decodes the JSON response into v.
+func getJSON(httpc *http.Client, ep OpenAIEndpoint, url string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if ep.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+ep.APIKey)
+	}
+	resp, err := httpc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// refreshEndpoint rediscovers the models of ep and updates the manager:
+// new models are added and models the server no longer lists are removed.
+// If the endpoint is unreachable, its last known models are kept.
+func (m *Manager) refreshEndpoint(ep OpenAIEndpoint) {
+	discovered, err := DiscoverOpenAIModels(ep, nil)
+	if err != nil {
+		if m.logger != nil {
+			m.logger.Debug("OpenAI-compatible endpoint discovery failed", "endpoint", ep.Name, "url", ep.URL, "error", err)
+		}
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]bool, len(discovered))
+	var added, removed []string
+	for _, model := range discovered {
+		existing, exists := m.services[model.ID]
+		if exists && existing.endpoint != ep.Name {
+			continue // Another source registered this ID first
+		}
+		svc, err := model.Factory(m.cfg, m.httpc)
+		if err != nil {
+			continue
+		}
+		seen[model.ID] = true
+		if !exists {
+			m.modelOrder = append(m.modelOrder, model.ID)
+			added = append(added, model.ID)
+		}
+		m.services[model.ID] = serviceEntry{
+			service:     svc,
+			provider:    ProviderOpenAICompatible,
+			modelID:     model.ID,
+			source:      ep.Name,
+			displayName: model.Description,
+			endpoint:    ep.Name,
+		}
+	}
+	m.modelOrder = slices.DeleteFunc(m.modelOrder, func(id string) bool {
+		if entry := m.services[id]; entry.endpoint == ep.Name && !seen[id] {
+			delete(m.services, id)
+			removed = append(removed, id)
+			return true
+		}
+		return false
+	})
+	if m.logger != nil && len(added)+len(removed) > 0 {
+		m.logger.Info("Updated OpenAI-compatible endpoint models", "endpoint", ep.Name, "added", added, "removed", removed)
+	}
+}
+
+// watchEndpoint refreshes the models of ep every interval until ctx is done.
+func (m *Manager) watchEndpoint(ctx context.Context, ep OpenAIEndpoint, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.refreshEndpoint(ep)
+		}
+	}
+}
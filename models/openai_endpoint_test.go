@@ -0,0 +1,174 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestDiscoverOpenAIModels_LlamaCpp(t *testing.T) {
+	var chatBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{{"id": "qwen2.5-coder-7b", "meta": map[string]any{"n_ctx_train": 32768}}},
+			})
+		case "/props":
+			json.NewEncoder(w).Encode(map[string]any{
+				"default_generation_settings": map[string]any{"n_ctx": 8192},
+				"chat_template_caps":          map[string]any{"supports_tools": true, "supports_parallel_tool_calls": false},
+			})
+		case "/v1/chat/completions":
+			json.NewDecoder(r.Body).Decode(&chatBody)
+			json.NewEncoder(w).Encode(map[string]any{
+				"id":      "chatcmpl-1",
+				"choices": []map[string]any{{"message": map[string]any{"role": "assistant", "content": "hi"}, "finish_reason": "stop"}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	models, err := DiscoverOpenAIModels(OpenAIEndpoint{Name: "llamacpp", URL: server.URL + "/v1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "llamacpp/qwen2.5-coder-7b" || models[0].Provider != ProviderOpenAICompatible {
+		t.Fatalf("unexpected models: %+v", models)
+	}
+	svc, err := models[0].Factory(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := svc.TokenContextWindow(); n != 8192 {
+		t.Errorf("context window = %d, want the server's n_ctx 8192", n)
+	}
+	if !llm.UseSimplifiedPatch(svc) {
+		t.Error("expected the simplified patch schema without parallel tool call support")
+	}
+
+	_, err = svc.Do(context.Background(), &llm.Request{
+		Messages: []llm.Message{llm.UserStringMessage("hello")},
+		Tools:    []*llm.Tool{{Name: "bash", Description: "run", InputSchema: llm.EmptySchema()}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chatBody["parallel_tool_calls"] != false || chatBody["max_tokens"] != float64(2048) {
+		t.Errorf("parallel_tool_calls = %v, max_tokens = %v", chatBody["parallel_tool_calls"], chatBody["max_tokens"])
+	}
+}
+
+func TestDiscoverOpenAIModels_VLLMAndLMStudio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{
+					{"id": "big-model", "max_model_len": 131072},
+					{"id": "small-model"},
+				},
+			})
+		case "/api/v0/models":
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{
+					{"id": "small-model", "loaded_context_length": 4096, "max_context_length": 32768},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	models, err := DiscoverOpenAIModels(OpenAIEndpoint{Name: "local", URL: server.URL + "/v1/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(models))
+	}
+	big, _ := models[0].Factory(nil, nil)
+	small, _ := models[1].Factory(nil, nil)
+	if big.TokenContextWindow() != 131072 || llm.UseSimplifiedPatch(big) {
+		t.Errorf("big-model: context window %d, simplified patch %v", big.TokenContextWindow(), llm.UseSimplifiedPatch(big))
+	}
+	// LM Studio does not list tool_use for small-model.
+	if small.TokenContextWindow() != 4096 || !llm.UseSimplifiedPatch(small) {
+		t.Errorf("small-model: context window %d, simplified patch %v", small.TokenContextWindow(), llm.UseSimplifiedPatch(small))
+	}
+
+	// Configuration overrides the probed tool support.
+	models, err = DiscoverOpenAIModels(OpenAIEndpoint{Name: "local", URL: server.URL + "/v1", Tools: ToolSupportFull}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small, _ := models[1].Factory(nil, nil); llm.UseSimplifiedPatch(small) {
+		t.Error("tools: full did not override the probe")
+	}
+}
+
+func TestManagerRefreshEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	ids := []string{"model-a", "model-b"}
+	up := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !up || r.URL.Path != "/v1/models" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var data []map[string]any
+		for _, id := range ids {
+			data = append(data, map[string]any{"id": id})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer server.Close()
+
+	ep := OpenAIEndpoint{Name: "vllm", URL: server.URL + "/v1", Refresh: "0"}
+	manager, err := NewManager(&Config{OpenAIEndpoints: []OpenAIEndpoint{ep}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	hasEndpointModels := func(want ...string) {
+		t.Helper()
+		var got []string
+		for _, id := range manager.GetAvailableModels() {
+			if info := manager.GetModelInfo(id); info != nil && info.Source == "vllm" {
+				got = append(got, id)
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("endpoint models = %v, want %v", got, want)
+		}
+	}
+	hasEndpointModels("vllm/model-a", "vllm/model-b")
+	if _, err := manager.GetService("vllm/model-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	ids = []string{"model-b", "model-c"}
+	mu.Unlock()
+	manager.refreshEndpoint(ep)
+	hasEndpointModels("vllm/model-b", "vllm/model-c")
+
+	// An unreachable endpoint keeps its last known models.
+	mu.Lock()
+	up = false
+	mu.Unlock()
+	manager.refreshEndpoint(ep)
+	hasEndpointModels("vllm/model-b", "vllm/model-c")
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+// checkpointSaver stores the files a conversation's tools change as the
+// conversation's file checkpoints.
+type checkpointSaver struct {
+	db             *db.DB
+	conversationID string
+}
+
+func (c *checkpointSaver) Save(ctx context.Context, files []checkpoint.File) error {
+	checkpoints := make([]db.FileCheckpoint, len(files))
+	for i, f := range files {
+		checkpoints[i] = db.FileCheckpoint{
+			Path:    f.Path,
+			Existed: f.Exists,
+			Mode:    int64(f.Mode),
+			Content: f.Content,
+		}
+	}
+	return c.db.SaveFileCheckpoints(ctx, c.conversationID, checkpoints)
+}
+
+// FileCheckpointInfo describes a file checkpoint: the file as it was at
+// message SequenceID, before the agent changed it.
+type FileCheckpointInfo struct {
+	SequenceID int64  `json:"sequence_id"`
+	Path       string `json:"path"`
+	Existed    bool   `json:"existed"`
+	Size       int64  `json:"size"`
+}
+
+// RewindRequest is the body of POST /api/conversation/<id>/rewind.
+type RewindRequest struct {
+	// SequenceID is the message to rewind to. Files are restored to how they
+	// were before the message; the conversation drops the message and
+	// everything after it.
+	SequenceID   int64 `json:"sequence_id"`
+	Files        bool  `json:"files"`
+	Conversation bool  `json:"conversation"`
+}
+
+// RewindResponse reports what a rewind did.
+type RewindResponse struct {
+	// Restored lists the files that were restored or removed.
+	Restored []string `json:"restored"`
+	// Message is the text of the user message dropped from the
+	// conversation, to edit and resend.
+	Message string `json:"message,omitempty"`
+}
+
+// handleListCheckpoints handles GET /api/conversation/<id>/checkpoints.
+func (s *Server) handleListCheckpoints(w http.ResponseWriter, r *http.Request, conversationID string) {
+	checkpoints, err := s.db.ListFileCheckpoints(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list checkpoints", "conversationID", conversationID, "error", err)
+		http.Error(w, "Failed to list checkpoints", http.StatusInternalServerError)
+		return
+	}
+	infos := make([]FileCheckpointInfo, len(checkpoints))
+	for i, c := range checkpoints {
+		infos[i] = FileCheckpointInfo{SequenceID: c.SequenceID, Path: c.Path, Existed: c.Existed, Size: c.Size}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(infos) //nolint:errchkjson
+}
+
+// handleRewind handles POST /api/conversation/<id>/rewind.
+// It restores the files the agent changed since a message, and/or drops the
+// message and everything after it from the conversation.
+func (s *Server) handleRewind(w http.ResponseWriter, r *http.Request, conversationID string) {
+	var req RewindRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.SequenceID <= 0 || (!req.Files && !req.Conversation) {
+		http.Error(w, "sequence_id and files and/or conversation are required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	messages, err := s.db.ListMessages(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+	var target *generated.Message
+	for i := range messages {
+		if messages[i].SequenceID == req.SequenceID {
+			target = &messages[i]
+			break
+		}
+	}
+	if target == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if req.Conversation && target.Type != string(db.MessageTypeUser) {
+		http.Error(w, "The conversation can only be rewound to a user message", http.StatusBadRequest)
+		return
+	}
+
+	// Stop the agent before touching its files or history.
+	s.mu.Lock()
+	manager := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if manager != nil && manager.IsAgentWorking() {
+		if err := manager.CancelConversation(ctx); err != nil {
+			s.logger.Error("Failed to cancel conversation for rewind", "conversationID", conversationID, "error", err)
+		}
+	}
+
+	resp := RewindResponse{Restored: []string{}}
+	if req.Files {
+		checkpoints, err := s.db.FileCheckpointsAt(ctx, conversationID, req.SequenceID)
+		if err != nil {
+			s.logger.Error("Failed to get checkpoints", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		files := make([]checkpoint.File, len(checkpoints))
+		for i, c := range checkpoints {
+			files[i] = checkpoint.File{Path: c.Path, Exists: c.Existed, Mode: fs.FileMode(c.Mode), Content: c.Content}
+			resp.Restored = append(resp.Restored, c.Path)
+		}
+		if err := checkpoint.Restore(files); err != nil {
+			s.logger.Error("Failed to restore files", "conversationID", conversationID, "error", err)
+			http.Error(w, "Failed to restore files: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.Conversation {
+		if manager != nil {
+			manager.ResetLoop()
+		}
+		err := s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+			return q.DeleteMessagesFromSequence(ctx, generated.DeleteMessagesFromSequenceParams{
+				ConversationID: conversationID,
+				SequenceID:     req.SequenceID,
+			})
+		})
+		if err == nil {
+			err = s.db.DeleteFileCheckpointsFrom(ctx, conversationID, req.SequenceID)
+		}
+		if err != nil {
+			s.logger.Error("Failed to rewind conversation", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		resp.Message = userMessageText(*target)
+		go s.notifySubscribers(context.WithoutCancel(ctx), conversationID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp) //nolint:errchkjson
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool/checkpoint"
+	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestHandleRewind(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.cleanup()
+
+	ctx := context.Background()
+	conv, err := h.db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addMessage := func(msgType db.MessageType, text string) {
+		t.Helper()
+		_, err := h.db.CreateMessage(ctx, db.CreateMessageParams{
+			ConversationID: conv.ConversationID,
+			Type:           msgType,
+			LLMData:        llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: text}}},
+			UserData:       map[string]string{"text": text},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "edited.txt")
+	created := filepath.Join(dir, "created.txt")
+	if err := os.WriteFile(edited, []byte("before"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	addMessage(db.MessageTypeUser, "first")
+	addMessage(db.MessageTypeAgent, "done")
+	addMessage(db.MessageTypeUser, "second")
+	addMessage(db.MessageTypeAgent, "editing")
+	// The agent's tools change the files after message 4.
+	saver := &checkpointSaver{db: h.db, conversationID: conv.ConversationID}
+	err = saver.Save(ctx, []checkpoint.File{
+		{Path: edited, Exists: true, Mode: 0o644, Content: []byte("before")},
+		{Path: created},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(edited, []byte("after"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(created, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := h.server.conversationMux()
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/"+conv.ConversationID+"/rewind", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Only user messages can be rewound to in the conversation.
+	if rec := post(`{"sequence_id": 4, "conversation": true}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("rewinding the conversation to an agent message: expected 400, got %d", rec.Code)
+	}
+	if rec := post(`{"sequence_id": 3}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("rewinding nothing: expected 400, got %d", rec.Code)
+	}
+
+	rec := post(`{"sequence_id": 3, "files": true, "conversation": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp RewindResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "second" || len(resp.Restored) != 2 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	if data, err := os.ReadFile(edited); err != nil || string(data) != "before" {
+		t.Errorf("edited.txt = %q, %v", data, err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("created.txt was not removed: %v", err)
+	}
+	msgs, err := h.db.ListMessages(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Errorf("expected 2 messages after rewind, got %d", len(msgs))
+	}
+	checkpoints, err := h.db.ListFileCheckpoints(ctx, conv.ConversationID)
+	if err != nil || len(checkpoints) != 0 {
+		t.Errorf("checkpoints after rewind = %+v, %v", checkpoints, err)
+	}
+}
@@ -5,15 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/cluster"
 	"github.com/tgruben-circuit/percy/db"
 	"github.com/tgruben-circuit/percy/db/generated"
 	"github.com/tgruben-circuit/percy/gitstate"
+	"github.com/tgruben-circuit/percy/hooks"
 	"github.com/tgruben-circuit/percy/llm"
 	"github.com/tgruben-circuit/percy/llm/llmhttp"
 	"github.com/tgruben-circuit/percy/loop"
@@ -23,6 +26,7 @@ import (
 
 var errConversationModelMismatch = errors.New("conversation model mismatch")
 var errSwitchModelConflict = errors.New("cannot switch models while conversation is active without cancel_current_turn=true")
+var errPromptBlocked = errors.New("message blocked by hook")
 
 // ConversationManager manages a single active conversation
 type ConversationManager struct {
@@ -40,12 +44,14 @@ type ConversationManager struct {
 	logger         *slog.Logger
 	toolSetConfig  claudetool.ToolSetConfig
 	toolSet        *claudetool.ToolSet // created per-conversation when loop starts
+	hooks          *hooks.Runner       // loaded from hooks.json files when loop starts
 
 	subpub *subpub.SubPub[StreamResponse]
 
 	hydrated              bool
 	hasConversationEvents bool
 	cwd                   string // working directory for tools
+	sandbox               string // sandbox mode for tools; empty uses the server's default
 
 	// agentWorking tracks whether the agent is currently working.
 	// This is explicitly managed and broadcast to subscribers when it changes.
@@ -146,6 +152,16 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 	}
 	cm.cwd = cwd
 
+	// Load the sandbox mode. Subagents run in their parent's sandbox.
+	switch {
+	case conversation.Sandbox != nil:
+		cm.sandbox = *conversation.Sandbox
+	case conversation.ParentConversationID != nil:
+		if parent, err := cm.db.GetConversationByID(ctx, *conversation.ParentConversationID); err == nil && parent.Sandbox != nil {
+			cm.sandbox = *parent.Sandbox
+		}
+	}
+
 	// Load model from conversation if available
 	var modelID string
 	if conversation.Model != nil {
@@ -195,11 +211,122 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 
 // AcceptUserMessage enqueues a user message, ensuring the loop is ready first.
 // The message is recorded to the database immediately so it appears in the UI,
-// even if the loop is busy processing a previous request.
+// even if the loop is busy processing a previous request. The conversation_start
+// hooks (for the first message) and user_prompt_submit hooks run first; they may
+// reject the message with errPromptBlocked or add context to it.
 func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service llm.Service, modelID string, message llm.Message) (bool, error) {
 	if service == nil {
 		return false, fmt.Errorf("llm service is required")
 	}
+	if err := cm.Hydrate(ctx); err != nil {
+		return false, err
+	}
+	if err := cm.ensureLoop(service, modelID); err != nil {
+		return false, err
+	}
+	cm.mu.Lock()
+	isFirst := !cm.hasConversationEvents
+	cm.mu.Unlock()
+
+	hookContext, err := cm.CheckPrompt(ctx, message, isFirst)
+	if err != nil {
+		return false, err
+	}
+	return cm.AcceptCheckedMessage(ctx, service, modelID, message, hookContext)
+}
+
+// CheckPrompt runs the hooks for a user message before it is accepted:
+// conversation_start if the message starts the conversation, then
+// user_prompt_submit. It returns errPromptBlocked if a hook rejects the
+// message, and otherwise the context the hooks add, for
+// AcceptCheckedMessage. Callers that change the conversation before
+// sending a message, such as edits, check it first.
+func (cm *ConversationManager) CheckPrompt(ctx context.Context, message llm.Message, first bool) (string, error) {
+	if err := cm.Hydrate(ctx); err != nil {
+		return "", err
+	}
+	runner := cm.promptHooks()
+
+	events := []hooks.Event{hooks.UserPromptSubmit}
+	if first {
+		events = []hooks.Event{hooks.ConversationStart, hooks.UserPromptSubmit}
+	}
+	var contexts []string
+	for _, event := range events {
+		if !runner.Has(event) {
+			continue
+		}
+		result := runner.Run(ctx, hooks.Input{Event: event, Prompt: messageText(message)})
+		if result.Blocked {
+			cm.logger.Info("user message blocked by hook", "event", event, "reason", result.Reason)
+			return "", fmt.Errorf("%w: %s", errPromptBlocked, result.Reason)
+		}
+		if result.Context != "" {
+			contexts = append(contexts, result.Context)
+		}
+	}
+	return strings.Join(contexts, "\n\n"), nil
+}
+
+// promptHooks returns the conversation's hooks: the loop's, or, before the
+// loop starts, the ones configured for the conversation's directory.
+func (cm *ConversationManager) promptHooks() *hooks.Runner {
+	cm.mu.Lock()
+	running, runner, cwd := cm.loop != nil, cm.hooks, cm.cwd
+	sb := conversationSandbox(cm.toolSetConfig.Sandbox, cm.sandbox)
+	cm.mu.Unlock()
+	if running {
+		return runner
+	}
+	return loadHooks(cwd, cm.conversationID, func() string { return cwd }, sb, cm.logger)
+}
+
+// conversationSandbox returns the sandbox of a conversation's commands:
+// the configured one, in the conversation's mode if it chose one.
+func conversationSandbox(cfg *sandbox.Config, mode string) *sandbox.Config {
+	if mode == "" {
+		return cfg
+	}
+	return cfg.WithMode(sandbox.Mode(mode))
+}
+
+// loadHooks returns a runner for the hooks configured for cwd, or nil if
+// there are none. In a sandboxed conversation the hooks run in its sandbox,
+// since its commands can write the project's hooks.
+func loadHooks(cwd, conversationID string, workingDir func() string, sb *sandbox.Config, logger *slog.Logger) *hooks.Runner {
+	gitRoot := ""
+	if gi, err := collectGitInfo(cwd); err == nil && gi != nil {
+		gitRoot = gi.Root
+	}
+	config, err := hooks.Load(hooks.Files(cwd, gitRoot))
+	if err != nil {
+		logger.Warn("Failed to load some hooks", "error", err)
+	}
+	if len(config) == 0 {
+		return nil
+	}
+	runner := &hooks.Runner{
+		Config:         config,
+		ConversationID: conversationID,
+		WorkingDir:     workingDir,
+		Logger:         logger,
+	}
+	if sb.Enabled() {
+		runner.Wrap = func(cmd *exec.Cmd) (func(), error) {
+			return sb.Wrap(cmd, sandbox.Workspace(cmd.Dir))
+		}
+	}
+	return runner
+}
+
+// AcceptCheckedMessage is AcceptUserMessage for a message CheckPrompt has
+// accepted. hookContext, the context the hooks added, is sent to the model
+// ahead of the message but is not recorded with it, so the conversation
+// shows, edits and regenerates only what the user wrote.
+func (cm *ConversationManager) AcceptCheckedMessage(ctx context.Context, service llm.Service, modelID string, message llm.Message, hookContext string) (bool, error) {
+	if service == nil {
+		return false, fmt.Errorf("llm service is required")
+	}
 
 	if err := cm.Hydrate(ctx); err != nil {
 		return false, err
@@ -212,8 +339,8 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 	cm.mu.Lock()
 	isFirst := !cm.hasConversationEvents
 	cm.hasConversationEvents = true
-	loopInstance := cm.loop
 	cm.lastActivity = time.Now()
+	loopInstance := cm.loop
 	recordMessage := cm.recordMessage
 	cm.mu.Unlock()
 
@@ -230,6 +357,9 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 		}
 	}
 
+	if hookContext != "" {
+		message.Content = append([]llm.Content{{Type: llm.ContentTypeText, Text: hookContext}}, message.Content...)
+	}
 	loopInstance.QueueUserMessage(message)
 
 	// Mark agent as working - we just queued work for the loop
@@ -238,6 +368,45 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 	return isFirst, nil
 }
 
+// CountTokens returns the number of input tokens the conversation's next
+// request would use with draft added, and whether the count is exact. It
+// doesn't start the conversation loop: until the loop starts, it counts the
+// stored history, without tool definitions.
+func (cm *ConversationManager) CountTokens(ctx context.Context, service llm.Service, modelID string, draft llm.Message) (int, bool, error) {
+	if err := cm.Hydrate(ctx); err != nil {
+		return 0, false, err
+	}
+	var messages []llm.Message
+	if len(draft.Content) > 0 {
+		messages = append(messages, draft)
+	}
+
+	cm.mu.Lock()
+	loopInstance := cm.loop
+	existingModel := cm.modelID
+	cm.mu.Unlock()
+	if loopInstance != nil {
+		if existingModel != "" && modelID != "" && existingModel != modelID {
+			return 0, false, fmt.Errorf("%w: conversation already uses model %s; requested %s", errConversationModelMismatch, existingModel, modelID)
+		}
+		n, exact := loopInstance.CountContextTokens(ctx, messages...)
+		return n, exact, nil
+	}
+
+	var dbMessages []generated.Message
+	err := cm.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		dbMessages, err = q.ListMessagesForContext(ctx, cm.conversationID)
+		return err
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to load conversation history: %w", err)
+	}
+	history, system := cm.partitionMessages(dbMessages)
+	n, exact := loop.CountHistoryTokens(ctx, service, system, append(history, messages...)...)
+	return n, exact, nil
+}
+
 // Touch updates last activity timestamp.
 func (cm *ConversationManager) Touch() {
 	cm.mu.Lock()
@@ -245,6 +414,17 @@ func (cm *ConversationManager) Touch() {
 	cm.mu.Unlock()
 }
 
+// messageText returns the text blocks of a message, one per line.
+func messageText(message llm.Message) string {
+	var texts []string
+	for _, c := range message.Content {
+		if c.Type == llm.ContentTypeText && c.Text != "" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
 func hasSystemMessage(messages []generated.Message) bool {
 	for _, msg := range messages {
 		if msg.Type == string(db.MessageTypeSystem) {
@@ -414,6 +594,7 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	recordMessage := cm.recordMessage
 	logger := cm.logger
 	cwd := cm.cwd
+	sandboxMode := cm.sandbox
 	toolSetConfig := cm.toolSetConfig
 	conversationID := cm.conversationID
 	db := cm.db
@@ -440,6 +621,17 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	toolSetConfig.ModelID = modelID
 	toolSetConfig.ConversationID = conversationID
 	toolSetConfig.ParentConversationID = conversationID // For subagent tool
+	toolSetConfig.Sandbox = conversationSandbox(toolSetConfig.Sandbox, sandboxMode)
+	toolSetConfig.Checkpoints = &checkpointSaver{db: db, conversationID: conversationID}
+	toolSetConfig.RecentMessages = func() []llm.Message {
+		cm.mu.Lock()
+		l := cm.loop
+		cm.mu.Unlock()
+		if l == nil {
+			return nil
+		}
+		return l.GetHistory()
+	}
 	toolSetConfig.OnWorkingDirChange = func(newDir string) {
 		// Persist working directory change to database
 		if err := db.UpdateConversationCwd(context.Background(), conversationID, newDir); err != nil {
@@ -479,6 +671,7 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	baseCtx := llmhttp.WithConversationID(context.Background(), conversationID)
 	processCtx, cancel := context.WithTimeout(baseCtx, 12*time.Hour)
 	toolSet := claudetool.NewToolSet(processCtx, toolSetConfig)
+	hookRunner := loadHooks(cwd, conversationID, toolSet.WorkingDir().Get, toolSetConfig.Sandbox, logger)
 
 	loopInstance := loop.NewLoop(loop.Config{
 		LLM:           service,
@@ -493,6 +686,7 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 		OnGitStateChange: func(ctx context.Context, state *gitstate.GitState) {
 			cm.recordGitStateChange(ctx, state)
 		},
+		Hooks: hookRunner,
 	})
 
 	cm.mu.Lock()
@@ -513,6 +707,7 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	cm.loopCtx = processCtx
 	cm.modelID = modelID
 	cm.toolSet = toolSet
+	cm.hooks = hookRunner
 	cm.mu.Unlock()
 
 	// Persist model for legacy conversations
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCountTokensEndpoint(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: hello", "")
+	h.WaitResponse()
+
+	count := func(message string) CountTokensResponse {
+		t.Helper()
+		body, _ := json.Marshal(CountTokensRequest{Message: message})
+		req := httptest.NewRequest("POST", "/"+h.ConversationID()+"/count-tokens", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, req)
+		if w.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp CountTokensResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	empty := count("")
+	if empty.InputTokens <= 0 || empty.Exact || empty.ContextWindowSize != h.llm.TokenContextWindow() {
+		t.Errorf("unexpected count without a draft: %+v", empty)
+	}
+	// About 1000 tokens of draft.
+	draft := count(strings.Repeat("word ", 800))
+	if diff := draft.InputTokens - empty.InputTokens; diff < 900 || diff > 1100 {
+		t.Errorf("draft added %d tokens, want about 1000", diff)
+	}
+
+	// Counting a conversation whose loop isn't running doesn't start one.
+	id := h.ConversationID()
+	h.server.mu.Lock()
+	h.server.activeConversations[id].stopLoop()
+	delete(h.server.activeConversations, id)
+	h.server.mu.Unlock()
+	stored := count("")
+	if stored.InputTokens <= 0 || stored.InputTokens > empty.InputTokens {
+		t.Errorf("unexpected count of the stored history: %+v, with the loop %+v", stored, empty)
+	}
+	if diff := count(strings.Repeat("word ", 800)).InputTokens - stored.InputTokens; diff < 900 || diff > 1100 {
+		t.Errorf("draft added %d tokens to the stored history, want about 1000", diff)
+	}
+	h.server.mu.Lock()
+	manager := h.server.activeConversations[id]
+	h.server.mu.Unlock()
+	manager.mu.Lock()
+	started := manager.loop != nil
+	manager.mu.Unlock()
+	if started {
+		t.Error("counting tokens started the conversation loop")
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,8 +13,10 @@ import (
 	"github.com/tgruben-circuit/percy/db/generated"
 	"github.com/tgruben-circuit/percy/llm"
 	"github.com/tgruben-circuit/percy/llm/ant"
+	"github.com/tgruben-circuit/percy/llm/bedrock"
 	"github.com/tgruben-circuit/percy/llm/gem"
 	"github.com/tgruben-circuit/percy/llm/oai"
+	"github.com/tgruben-circuit/percy/llm/vertex"
 )
 
 // ModelAPI is the API representation of a model
@@ -27,6 +30,17 @@ type ModelAPI struct {
 	MaxTokens     int64  `json:"max_tokens"`
 	Tags          string `json:"tags"`           // Comma-separated tags (e.g., "slug" for slug generation)
 	ThinkingLevel string `json:"thinking_level"` // off, minimal, low, medium, high
+	ModelCatalogFields
+}
+
+// ModelCatalogFields override the model catalog for a custom model.
+// Zero means "use the built-in catalog entry for model_name".
+type ModelCatalogFields struct {
+	ContextWindow   int64   `json:"context_window"`
+	InputPrice      float64 `json:"input_price"`       // USD per million tokens
+	OutputPrice     float64 `json:"output_price"`      // USD per million tokens
+	CacheReadPrice  float64 `json:"cache_read_price"`  // USD per million tokens
+	CacheWritePrice float64 `json:"cache_write_price"` // USD per million tokens
 }
 
 // CreateModelRequest is the request body for creating a model
@@ -39,6 +53,7 @@ type CreateModelRequest struct {
 	MaxTokens     int64  `json:"max_tokens"`
 	Tags          string `json:"tags"`           // Comma-separated tags
 	ThinkingLevel string `json:"thinking_level"` // off, minimal, low, medium, high
+	ModelCatalogFields
 }
 
 // UpdateModelRequest is the request body for updating a model
@@ -51,6 +66,20 @@ type UpdateModelRequest struct {
 	MaxTokens     int64  `json:"max_tokens"`
 	Tags          string `json:"tags"`           // Comma-separated tags
 	ThinkingLevel string `json:"thinking_level"` // off, minimal, low, medium, high
+
+	// Catalog overrides; omitted fields keep their existing values.
+	ContextWindow   *int64   `json:"context_window,omitempty"`
+	InputPrice      *float64 `json:"input_price,omitempty"`
+	OutputPrice     *float64 `json:"output_price,omitempty"`
+	CacheReadPrice  *float64 `json:"cache_read_price,omitempty"`
+	CacheWritePrice *float64 `json:"cache_write_price,omitempty"`
+}
+
+// usesCloudCredentials reports whether a provider can authenticate from the
+// environment (AWS credentials or Google Application Default Credentials),
+// making the API key optional.
+func usesCloudCredentials(providerType string) bool {
+	return providerType == "bedrock" || providerType == "vertex"
 }
 
 // TestModelRequest is the request body for testing a model
@@ -73,6 +102,13 @@ func toModelAPI(m generated.Model) ModelAPI {
 		MaxTokens:     m.MaxTokens,
 		Tags:          m.Tags,
 		ThinkingLevel: m.ThinkingLevel,
+		ModelCatalogFields: ModelCatalogFields{
+			ContextWindow:   m.ContextWindow,
+			InputPrice:      m.InputPrice,
+			OutputPrice:     m.OutputPrice,
+			CacheReadPrice:  m.CacheReadPrice,
+			CacheWritePrice: m.CacheWritePrice,
+		},
 	}
 }
 
@@ -111,14 +147,16 @@ func (s *Server) handleCreateModel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate required fields
-	if req.DisplayName == "" || req.ProviderType == "" || req.Endpoint == "" || req.APIKey == "" || req.ModelName == "" {
+	if req.DisplayName == "" || req.ProviderType == "" || req.Endpoint == "" || (req.APIKey == "" && !usesCloudCredentials(req.ProviderType)) || req.ModelName == "" {
 		http.Error(w, "display_name, provider_type, endpoint, api_key, and model_name are required", http.StatusBadRequest)
 		return
 	}
 
 	// Validate provider type
-	if req.ProviderType != "anthropic" && req.ProviderType != "openai" && req.ProviderType != "openai-responses" && req.ProviderType != "gemini" && req.ProviderType != "ollama" {
-		http.Error(w, "provider_type must be 'anthropic', 'openai', 'openai-responses', 'gemini', or 'ollama'", http.StatusBadRequest)
+	switch req.ProviderType {
+	case "anthropic", "openai", "openai-responses", "gemini", "ollama", "bedrock", "vertex":
+	default:
+		http.Error(w, "provider_type must be 'anthropic', 'openai', 'openai-responses', 'gemini', 'ollama', 'bedrock', or 'vertex'", http.StatusBadRequest)
 		return
 	}
 
@@ -136,15 +174,20 @@ func (s *Server) handleCreateModel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	model, err := s.db.CreateModel(r.Context(), generated.CreateModelParams{
-		ModelID:       modelID,
-		DisplayName:   req.DisplayName,
-		ProviderType:  req.ProviderType,
-		Endpoint:      req.Endpoint,
-		ApiKey:        req.APIKey,
-		ModelName:     req.ModelName,
-		MaxTokens:     req.MaxTokens,
-		Tags:          req.Tags,
-		ThinkingLevel: req.ThinkingLevel,
+		ModelID:         modelID,
+		DisplayName:     req.DisplayName,
+		ProviderType:    req.ProviderType,
+		Endpoint:        req.Endpoint,
+		ApiKey:          req.APIKey,
+		ModelName:       req.ModelName,
+		MaxTokens:       req.MaxTokens,
+		Tags:            req.Tags,
+		ThinkingLevel:   req.ThinkingLevel,
+		ContextWindow:   req.ContextWindow,
+		InputPrice:      req.InputPrice,
+		OutputPrice:     req.OutputPrice,
+		CacheReadPrice:  req.CacheReadPrice,
+		CacheWritePrice: req.CacheWritePrice,
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create model: %v", err), http.StatusInternalServerError)
@@ -240,15 +283,20 @@ func (s *Server) handleUpdateModel(w http.ResponseWriter, r *http.Request, model
 	}
 
 	model, err := s.db.UpdateModel(r.Context(), generated.UpdateModelParams{
-		DisplayName:   req.DisplayName,
-		ProviderType:  req.ProviderType,
-		Endpoint:      req.Endpoint,
-		ApiKey:        apiKey,
-		ModelName:     req.ModelName,
-		MaxTokens:     req.MaxTokens,
-		Tags:          req.Tags,
-		ThinkingLevel: req.ThinkingLevel,
-		ModelID:       modelID,
+		DisplayName:     req.DisplayName,
+		ProviderType:    req.ProviderType,
+		Endpoint:        req.Endpoint,
+		ApiKey:          apiKey,
+		ModelName:       req.ModelName,
+		MaxTokens:       req.MaxTokens,
+		Tags:            req.Tags,
+		ThinkingLevel:   req.ThinkingLevel,
+		ContextWindow:   *cmp.Or(req.ContextWindow, &existing.ContextWindow),
+		InputPrice:      *cmp.Or(req.InputPrice, &existing.InputPrice),
+		OutputPrice:     *cmp.Or(req.OutputPrice, &existing.OutputPrice),
+		CacheReadPrice:  *cmp.Or(req.CacheReadPrice, &existing.CacheReadPrice),
+		CacheWritePrice: *cmp.Or(req.CacheWritePrice, &existing.CacheWritePrice),
+		ModelID:         modelID,
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update model: %v", err), http.StatusInternalServerError)
@@ -309,15 +357,20 @@ func (s *Server) handleDuplicateModel(w http.ResponseWriter, r *http.Request, mo
 
 	// Create the duplicate with the same API key
 	model, err := s.db.CreateModel(r.Context(), generated.CreateModelParams{
-		ModelID:       newModelID,
-		DisplayName:   displayName,
-		ProviderType:  source.ProviderType,
-		Endpoint:      source.Endpoint,
-		ApiKey:        source.ApiKey, // Copy the API key!
-		ModelName:     source.ModelName,
-		MaxTokens:     source.MaxTokens,
-		Tags:          "",                    // Don't copy tags
-		ThinkingLevel: source.ThinkingLevel,
+		ModelID:         newModelID,
+		DisplayName:     displayName,
+		ProviderType:    source.ProviderType,
+		Endpoint:        source.Endpoint,
+		ApiKey:          source.ApiKey, // Copy the API key!
+		ModelName:       source.ModelName,
+		MaxTokens:       source.MaxTokens,
+		Tags:            "", // Don't copy tags
+		ThinkingLevel:   source.ThinkingLevel,
+		ContextWindow:   source.ContextWindow,
+		InputPrice:      source.InputPrice,
+		OutputPrice:     source.OutputPrice,
+		CacheReadPrice:  source.CacheReadPrice,
+		CacheWritePrice: source.CacheWritePrice,
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to duplicate model: %v", err), http.StatusInternalServerError)
@@ -356,7 +409,7 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 		req.APIKey = model.ApiKey
 	}
 
-	if req.ProviderType == "" || req.Endpoint == "" || req.APIKey == "" || req.ModelName == "" {
+	if req.ProviderType == "" || req.Endpoint == "" || (req.APIKey == "" && !usesCloudCredentials(req.ProviderType)) || req.ModelName == "" {
 		http.Error(w, "provider_type, endpoint, api_key, and model_name are required", http.StatusBadRequest)
 		return
 	}
@@ -407,6 +460,25 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 				URL:       req.Endpoint,
 			},
 		}
+	case "bedrock":
+		region, endpoint := bedrock.ParseEndpoint(req.Endpoint)
+		apiKey, creds := bedrock.ParseAPIKey(req.APIKey)
+		service = &bedrock.Service{
+			Region:      region,
+			Endpoint:    endpoint,
+			Model:       req.ModelName,
+			APIKey:      apiKey,
+			Credentials: creds,
+		}
+	case "vertex":
+		project, region, endpoint := vertex.ParseEndpoint(req.Endpoint)
+		service = &vertex.Service{
+			Project:     project,
+			Region:      region,
+			Endpoint:    endpoint,
+			Model:       req.ModelName,
+			Credentials: req.APIKey,
+		}
 	default:
 		http.Error(w, "Invalid provider_type", http.StatusBadRequest)
 		return
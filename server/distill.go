@@ -26,21 +26,12 @@ Write the distillation AS IF you are the user briefing a fresh Percy instance. U
 
 ## Output Format
 
-Produce exactly this structure (no markdown code fences around the whole output, no meta-commentary):
+Respond with a JSON object with two fields:
 
-This is a continuation of conversation "SLUG_HERE".
+- "briefing": 2-6 sentences describing what was being worked on, what state things are in, and what the immediate next steps or open tasks are. Be concrete — name files, describe the current approach, note where things left off. This is a situational briefing, not a history.
+- "retained_facts": an array of facts, one string per fact, with no leading bullet.
 
-WRITE 2-6 SENTENCES HERE describing what was being worked on, what state things are in, and what the immediate next steps or open tasks are. Be concrete — name files, describe the current approach, note where things left off. This is a situational briefing, not a history. Write the sentences directly with no wrapper tags.
-
-## Retained Facts
-
-- fact 1
-- fact 2
-- ...
-
-The "## Retained Facts" section IS part of the output. The instructions below are NOT part of the output.
-
-Each fact bullet should be a single concrete, referenceable fact. Aim for 10-40 bullets depending on conversation length. Include:
+Each fact should be a single concrete, referenceable fact. Aim for 10-40 facts depending on conversation length. Include:
 
 - File paths and roles (full paths, what each file does)
 - Decisions and rationale ("X because Y")
@@ -55,7 +46,42 @@ Each fact bullet should be a single concrete, referenceable fact. Aim for 10-40
 
 EXCISE: dead-end debugging (keep only final fix), verbose tool output (keep only findings), abandoned tangents (unless the reason matters), greetings/filler, already-resolved questions (keep only conclusions), redundant info, thinking blocks, intermediate file states that were later overwritten.
 
-Compression: recent activity (~last 20%) gets more detail; older activity compresses to conclusions. Short conversations (< 20 messages) preserve more. Long conversations (> 100 messages) aggressively compress old activity. Total output: 500-2000 words. When in doubt, keep it.`
+Compression: recent activity (~last 20%) gets more detail; older activity compresses to conclusions. Short conversations (< 20 messages) preserve more. Long conversations (> 100 messages) aggressively compress old activity. Total: 500-2000 words. When in doubt, keep it.`
+
+// distillFormat is the structured output format of distillation.
+var distillFormat = &llm.ResponseFormat{
+	Name:        "distillation",
+	Description: "Record the distillation of the conversation.",
+	Schema: llm.MustSchema(`{
+		"type": "object",
+		"properties": {
+			"briefing": {"type": "string"},
+			"retained_facts": {"type": "array", "items": {"type": "string"}}
+		},
+		"required": ["briefing", "retained_facts"]
+	}`),
+}
+
+// distillation is the LLM's distillation of a conversation.
+type distillation struct {
+	Briefing      string   `json:"briefing"`
+	RetainedFacts []string `json:"retained_facts"`
+}
+
+// render formats d as the opening message of the continuation conversation.
+func (d distillation) render(sourceSlug string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "This is a continuation of conversation %q.\n\n%s\n", sourceSlug, strings.TrimSpace(d.Briefing))
+	if len(d.RetainedFacts) > 0 {
+		sb.WriteString("\n## Retained Facts\n\n")
+		for _, fact := range d.RetainedFacts {
+			if fact = strings.TrimSpace(fact); fact != "" {
+				fmt.Fprintf(&sb, "- %s\n", fact)
+			}
+		}
+	}
+	return sb.String()
+}
 
 // handleDistillConversation handles POST /api/conversations/distill
 // Creates a new conversation and uses an LLM to distill the source conversation
@@ -126,6 +152,11 @@ func (s *Server) handleDistillConversation(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if err := s.setConversationSandbox(ctx, conversation, sourceConv.Sandbox); err != nil {
+		s.logger.Error("Failed to set conversation sandbox", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 	conversationID := conversation.ConversationID
 
 	// Notify conversation list subscribers
@@ -191,7 +222,8 @@ func (s *Server) runDistillation(ctx context.Context, conversationID, sourceSlug
 	distillCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
 	defer cancel()
 
-	resp, err := svc.Do(distillCtx, &llm.Request{
+	var result distillation
+	err = llm.DoJSON(distillCtx, svc, &llm.Request{
 		System: []llm.SystemContent{
 			{Text: distillSystemPrompt, Type: "text"},
 		},
@@ -203,26 +235,20 @@ func (s *Server) runDistillation(ctx context.Context, conversationID, sourceSlug
 				},
 			},
 		},
-	})
+		ResponseFormat: distillFormat,
+	}, &result)
 	if err != nil {
 		logger.Error("LLM distillation failed", "error", err)
 		s.insertDistillError(ctx, conversationID, fmt.Sprintf("Distillation failed: %v", err))
 		return
 	}
 
-	// Extract text from response
-	var distilledText string
-	for _, content := range resp.Content {
-		if content.Type == llm.ContentTypeText {
-			distilledText += content.Text
-		}
-	}
-
-	if distilledText == "" {
+	if strings.TrimSpace(result.Briefing) == "" && len(result.RetainedFacts) == 0 {
 		logger.Error("LLM returned empty distillation")
 		s.insertDistillError(ctx, conversationID, "Distillation returned empty result")
 		return
 	}
+	distilledText := result.render(sourceSlug)
 
 	logger.Info("Distillation complete", "output_length", len(distilledText))
 
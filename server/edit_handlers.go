@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -43,6 +44,22 @@ func (s *Server) handleEditMessage(w http.ResponseWriter, r *http.Request, conve
 		return
 	}
 
+	service, modelID, ok := s.conversationService(w, r, conversationID)
+	if !ok {
+		return
+	}
+
+	// Run the prompt hooks before changing the history, so a blocked edit
+	// leaves the conversation as it was
+	userMessage := llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: req.Message}},
+	}
+	hookContext, ok := s.checkReplayedPrompt(w, r, manager, conversationID, req.SequenceID, userMessage)
+	if !ok {
+		return
+	}
+
 	// Cancel any running loop
 	if err := manager.CancelConversation(ctx); err != nil {
 		s.logger.Error("Failed to cancel conversation", "error", err)
@@ -66,35 +83,8 @@ func (s *Server) handleEditMessage(w http.ResponseWriter, r *http.Request, conve
 	// Notify subscribers about the changes (clients should refetch)
 	go s.notifySubscribers(ctx, conversationID)
 
-	// Get the model for this conversation
-	conv, err := s.db.GetConversationByID(ctx, conversationID)
-	if err != nil {
-		http.Error(w, "Conversation not found", http.StatusNotFound)
-		return
-	}
-
-	modelID := s.defaultModel
-	if conv.Model != nil {
-		modelID = *conv.Model
-	}
-	modelID, err = s.resolveModelID(modelID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Model unavailable: %s", modelID), http.StatusBadRequest)
-		return
-	}
-
-	service, err := s.llmManager.GetService(modelID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Model unavailable: %s", modelID), http.StatusBadRequest)
-		return
-	}
-
 	// Send the edited message which will record it and start the loop
-	userMessage := llm.Message{
-		Role:    llm.MessageRoleUser,
-		Content: []llm.Content{{Type: llm.ContentTypeText, Text: req.Message}},
-	}
-	if _, err := manager.AcceptUserMessage(ctx, service, modelID, userMessage); err != nil {
+	if _, err := manager.AcceptCheckedMessage(ctx, service, modelID, userMessage, hookContext); err != nil {
 		s.logger.Error("Failed to accept edited message", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -126,27 +116,7 @@ func (s *Server) handleRegenerateMessage(w http.ResponseWriter, r *http.Request,
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Type == "user" {
 			lastUserSeqID = messages[i].SequenceID
-			// Extract text from user_data
-			if messages[i].UserData != nil {
-				var userData map[string]interface{}
-				if err := json.Unmarshal([]byte(*messages[i].UserData), &userData); err == nil {
-					if text, ok := userData["text"].(string); ok {
-						lastUserText = text
-					}
-				}
-			}
-			// Fallback to llm_data
-			if lastUserText == "" && messages[i].LlmData != nil {
-				var llmMsg llm.Message
-				if err := json.Unmarshal([]byte(*messages[i].LlmData), &llmMsg); err == nil {
-					for _, c := range llmMsg.Content {
-						if c.Type == llm.ContentTypeText && c.Text != "" {
-							lastUserText = c.Text
-							break
-						}
-					}
-				}
-			}
+			lastUserText = userMessageText(messages[i])
 			break
 		}
 	}
@@ -156,13 +126,28 @@ func (s *Server) handleRegenerateMessage(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	// Get conversation manager and cancel/reset
+	// Get conversation manager
 	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	service, modelID, ok := s.conversationService(w, r, conversationID)
+	if !ok {
+		return
+	}
+
+	// Run the prompt hooks before changing the history
+	userMessage := llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: lastUserText}},
+	}
+	hookContext, ok := s.checkReplayedPrompt(w, r, manager, conversationID, lastUserSeqID, userMessage)
+	if !ok {
+		return
+	}
+
 	if err := manager.CancelConversation(ctx); err != nil {
 		s.logger.Error("Failed to cancel for regenerate", "error", err)
 	}
@@ -183,11 +168,23 @@ func (s *Server) handleRegenerateMessage(w http.ResponseWriter, r *http.Request,
 	// Notify subscribers
 	go s.notifySubscribers(ctx, conversationID)
 
-	// Get model and re-send the same user message
-	conv, err := s.db.GetConversationByID(ctx, conversationID)
+	// Re-send the same user message
+	if _, err := manager.AcceptCheckedMessage(ctx, service, modelID, userMessage, hookContext); err != nil {
+		s.logger.Error("Failed to re-send for regenerate", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// conversationService returns the LLM service and model ID for a
+// conversation. On failure it writes the error response and returns false.
+func (s *Server) conversationService(w http.ResponseWriter, r *http.Request, conversationID string) (llm.Service, string, bool) {
+	conv, err := s.db.GetConversationByID(r.Context(), conversationID)
 	if err != nil {
 		http.Error(w, "Conversation not found", http.StatusNotFound)
-		return
+		return nil, "", false
 	}
 
 	modelID := s.defaultModel
@@ -197,24 +194,69 @@ func (s *Server) handleRegenerateMessage(w http.ResponseWriter, r *http.Request,
 	modelID, err = s.resolveModelID(modelID)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Model unavailable: %s", modelID), http.StatusBadRequest)
-		return
+		return nil, "", false
 	}
 
 	service, err := s.llmManager.GetService(modelID)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Model unavailable: %s", modelID), http.StatusBadRequest)
-		return
+		return nil, "", false
 	}
+	return service, modelID, true
+}
 
-	userMessage := llm.Message{
-		Role:    llm.MessageRoleUser,
-		Content: []llm.Content{{Type: llm.ContentTypeText, Text: lastUserText}},
+// checkReplayedPrompt runs the prompt hooks for message, which will replace
+// the conversation from sequenceID onwards. It returns the context the hooks
+// add. On failure, including a blocked prompt, it writes the error response
+// and returns false.
+func (s *Server) checkReplayedPrompt(w http.ResponseWriter, r *http.Request, manager *ConversationManager, conversationID string, sequenceID int64, message llm.Message) (string, bool) {
+	ctx := r.Context()
+	messages, err := s.db.ListMessages(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return "", false
 	}
-	if _, err := manager.AcceptUserMessage(ctx, service, modelID, userMessage); err != nil {
-		s.logger.Error("Failed to re-send for regenerate", "error", err)
+	var earlier []generated.Message
+	for _, msg := range messages {
+		if msg.SequenceID < sequenceID {
+			earlier = append(earlier, msg)
+		}
+	}
+
+	hookContext, err := manager.CheckPrompt(ctx, message, !hasNonSystemMessages(earlier))
+	if errors.Is(err, errPromptBlocked) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return "", false
+	}
+	if err != nil {
+		s.logger.Error("Failed to check message", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return "", false
 	}
+	return hookContext, true
+}
 
-	w.WriteHeader(http.StatusAccepted)
+// userMessageText returns the text the user typed in a user message.
+func userMessageText(msg generated.Message) string {
+	// Extract text from user_data
+	if msg.UserData != nil {
+		var userData map[string]interface{}
+		if err := json.Unmarshal([]byte(*msg.UserData), &userData); err == nil {
+			if text, ok := userData["text"].(string); ok && text != "" {
+				return text
+			}
+		}
+	}
+	// Fallback to llm_data
+	if msg.LlmData != nil {
+		var llmMsg llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err == nil {
+			for _, c := range llmMsg.Content {
+				if c.Type == llm.ContentTypeText && c.Text != "" {
+					return c.Text
+				}
+			}
+		}
+	}
+	return ""
 }
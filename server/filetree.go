@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sort"
 
+	"github.com/tgruben-circuit/percy/db"
 	"github.com/tgruben-circuit/percy/db/generated"
 	"github.com/tgruben-circuit/percy/llm"
 )
@@ -17,7 +18,10 @@ type TouchedFile struct {
 	Count     int    `json:"count"`     // number of interactions
 }
 
-func extractTouchedFiles(messages []generated.Message) []TouchedFile {
+// extractTouchedFiles collects the files named by the conversation's tool
+// calls, plus the files its checkpoints show were changed some other way,
+// such as by bash commands.
+func extractTouchedFiles(messages []generated.Message, checkpoints []db.FileCheckpoint) []TouchedFile {
 	type fileInfo struct {
 		operation string
 		count     int
@@ -81,6 +85,13 @@ func extractTouchedFiles(messages []generated.Message) []TouchedFile {
 		}
 	}
 
+	for _, c := range checkpoints {
+		if existing, ok := files[filepath.Clean(c.Path)]; ok && existing.operation == "patch" {
+			continue
+		}
+		track(c.Path, "write")
+	}
+
 	result := make([]TouchedFile, 0, len(files))
 	for path, info := range files {
 		result = append(result, TouchedFile{
@@ -104,7 +115,13 @@ func (s *Server) handleTouchedFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files := extractTouchedFiles(messages)
+	checkpoints, err := s.db.ListFileCheckpoints(r.Context(), conversationID)
+	if err != nil {
+		http.Error(w, "Failed to get checkpoints", http.StatusInternalServerError)
+		return
+	}
+
+	files := extractTouchedFiles(messages, checkpoints)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(files)
 }
@@ -58,6 +58,11 @@ func (s *Server) handleForkConversation(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if err := s.setConversationSandbox(ctx, conv, source.Sandbox); err != nil {
+		s.logger.Error("Failed to set forked conversation sandbox", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
 	// Get messages up to the specified sequence
 	var msgs []generated.Message
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"bytes"
+	"cmp"
 	"compress/gzip"
 	"context"
 	"crypto/rand"
@@ -21,6 +23,7 @@ import (
 
 	bundledskills "github.com/tgruben-circuit/percy/bundled_skills"
 	"github.com/tgruben-circuit/percy/claudetool/browse"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/db"
 	"github.com/tgruben-circuit/percy/db/generated"
 	"github.com/tgruben-circuit/percy/llm"
@@ -122,6 +125,15 @@ func (s *Server) handleWriteFile(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"}) //nolint:errchkjson // best-effort HTTP response
 }
 
+// uploadExtensions names uploads that arrive without a file extension.
+var uploadExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+}
+
 // handleUpload handles file uploads via POST /api/upload
 // Files are saved to the ScreenshotDir with a random filename
 func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
@@ -130,8 +142,8 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Limit to 10MB file size
-	r.Body = http.MaxBytesReader(w, r.Body, 10*1024*1024)
+	// Limit to 32MB file size, the largest PDF that providers accept
+	r.Body = http.MaxBytesReader(w, r.Body, 32*1024*1024)
 
 	// Parse the multipart form
 	if err := r.ParseMultipartForm(10 * 1024 * 1024); err != nil {
@@ -154,8 +166,18 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sniff the content type, so a pasted PDF or image without a file name
+	// still gets an extension that tools recognize.
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+
 	// Get file extension from the original filename
 	ext := filepath.Ext(handler.Filename)
+	if ext == "" {
+		ext = uploadExtensions[mediaType]
+	}
 
 	// Create a unique filename in the ScreenshotDir
 	filename := filepath.Join(browse.ScreenshotDir, fmt.Sprintf("upload_%s%s", hex.EncodeToString(randBytes), ext))
@@ -175,14 +197,14 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	defer destFile.Close()
 
 	// Copy the file contents to the destination file
-	if _, err := io.Copy(destFile, file); err != nil {
+	if _, err := io.Copy(destFile, io.MultiReader(bytes.NewReader(head), file)); err != nil {
 		http.Error(w, "failed to save file: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Return the path to the saved file
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"path": filename}) //nolint:errchkjson // best-effort HTTP response
+	_ = json.NewEncoder(w).Encode(map[string]string{"path": filename, "media_type": mediaType}) //nolint:errchkjson // best-effort HTTP response
 }
 
 // staticHandler serves files from the provided filesystem.
@@ -417,6 +439,13 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 		"default_cwd":   defaultCwd,
 		"home_dir":      homeDir,
 	}
+	if sandbox.Supported() {
+		defaultSandbox := sandbox.ModeOff
+		if s.toolSetConfig.Sandbox.Enabled() {
+			defaultSandbox = s.toolSetConfig.Sandbox.Mode
+		}
+		initData["default_sandbox"] = defaultSandbox
+	}
 	if s.terminalURL != "" {
 		initData["terminal_url"] = s.terminalURL
 	}
@@ -533,6 +562,9 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("POST /{id}/switch-model", func(w http.ResponseWriter, r *http.Request) {
 		s.handleSwitchModelConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/count-tokens", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCountTokens(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("POST /{id}/archive", func(w http.ResponseWriter, r *http.Request) {
 		s.handleArchiveConversation(w, r, r.PathValue("id"))
 	})
@@ -554,6 +586,12 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("GET /{id}/files", func(w http.ResponseWriter, r *http.Request) {
 		s.handleTouchedFiles(w, r)
 	})
+	mux.HandleFunc("GET /{id}/checkpoints", func(w http.ResponseWriter, r *http.Request) {
+		s.handleListCheckpoints(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/rewind", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRewind(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("POST /{id}/edit", func(w http.ResponseWriter, r *http.Request) {
 		s.handleEditMessage(w, r, r.PathValue("id"))
 	})
@@ -609,6 +647,48 @@ type ChatRequest struct {
 	Message string `json:"message"`
 	Model   string `json:"model,omitempty"`
 	Cwd     string `json:"cwd,omitempty"`
+	// Sandbox is the sandbox mode of a new conversation: "off", "isolated"
+	// or "network". Empty uses the server's default.
+	Sandbox string `json:"sandbox,omitempty"`
+}
+
+// validateSandboxMode checks a requested sandbox mode.
+func validateSandboxMode(mode string) error {
+	m, err := sandbox.ParseMode(mode)
+	if err != nil {
+		return err
+	}
+	if m != sandbox.ModeOff && !sandbox.Supported() {
+		return errors.New("sandboxing commands requires Linux")
+	}
+	return nil
+}
+
+// setConversationSandbox records the sandbox mode of a new conversation.
+// A nil or empty mode leaves the server's default in effect.
+func (s *Server) setConversationSandbox(ctx context.Context, conversation *generated.Conversation, mode *string) error {
+	if mode == nil || *mode == "" {
+		return nil
+	}
+	if err := s.db.SetConversationSandbox(ctx, conversation.ConversationID, *mode); err != nil {
+		return err
+	}
+	conversation.Sandbox = mode
+	return nil
+}
+
+// CountTokensRequest is the body of POST /api/conversation/<id>/count-tokens.
+type CountTokensRequest struct {
+	Message string `json:"message"`
+	Model   string `json:"model,omitempty"`
+}
+
+// CountTokensResponse reports the context size of a conversation's next
+// request, including the draft message.
+type CountTokensResponse struct {
+	InputTokens       int  `json:"input_tokens"`
+	Exact             bool `json:"exact"` // false if estimated locally
+	ContextWindowSize int  `json:"context_window_size"`
 }
 
 type SwitchModelRequest struct {
@@ -681,6 +761,10 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if errors.Is(err, errPromptBlocked) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -705,6 +789,54 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "accepted"}) //nolint:errchkjson // best-effort HTTP response
 }
 
+// handleCountTokens handles POST /api/conversation/<id>/count-tokens
+func (s *Server) handleCountTokens(w http.ResponseWriter, r *http.Request, conversationID string) {
+	var req CountTokensRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	manager, err := s.getOrCreateConversationManager(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	modelID, err := s.resolveModelID(cmp.Or(req.Model, manager.GetModel(), s.defaultModel))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", req.Model), http.StatusBadRequest)
+		return
+	}
+	service, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
+
+	var draft llm.Message
+	if req.Message != "" {
+		draft = llm.UserStringMessage(req.Message)
+	}
+	n, exact, err := manager.CountTokens(r.Context(), service, modelID, draft)
+	if errors.Is(err, errConversationModelMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to count tokens", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(CountTokensResponse{ //nolint:errchkjson // best-effort HTTP response
+		InputTokens:       n,
+		Exact:             exact,
+		ContextWindowSize: service.TokenContextWindow(),
+	})
+}
+
 // handleNewConversation handles POST /api/conversations/new - creates conversation implicitly on first message
 func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -725,6 +857,10 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Message is required", http.StatusBadRequest)
 		return
 	}
+	if err := validateSandboxMode(req.Sandbox); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get LLM service for the requested model
 	modelID := req.Model
@@ -759,14 +895,13 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if err := s.setConversationSandbox(ctx, conversation, &req.Sandbox); err != nil {
+		s.logger.Error("Failed to set conversation sandbox", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 	conversationID := conversation.ConversationID
 
-	// Notify conversation list subscribers about the new conversation
-	go s.publishConversationListUpdate(ConversationListUpdate{
-		Type:         "update",
-		Conversation: conversation,
-	})
-
 	// Get or create conversation manager
 	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
 	if errors.Is(err, errConversationModelMismatch) {
@@ -787,7 +922,27 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	// Run the prompt hooks before announcing the conversation; a blocked
+	// first message discards it
+	hookContext, err := manager.CheckPrompt(ctx, userMessage, true)
+	if err != nil {
+		s.discardConversation(context.WithoutCancel(ctx), conversationID)
+		if errors.Is(err, errPromptBlocked) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		s.logger.Error("Failed to check user message", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Notify conversation list subscribers about the new conversation
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+
+	firstMessage, err := manager.AcceptCheckedMessage(ctx, llmService, modelID, userMessage, hookContext)
 	if errors.Is(err, errConversationModelMismatch) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -820,6 +975,21 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// discardConversation deletes a conversation that was never announced to
+// conversation list subscribers, along with its manager.
+func (s *Server) discardConversation(ctx context.Context, conversationID string) {
+	s.mu.Lock()
+	manager := s.activeConversations[conversationID]
+	delete(s.activeConversations, conversationID)
+	s.mu.Unlock()
+	if manager != nil {
+		manager.stopLoop()
+	}
+	if err := s.db.DeleteConversation(ctx, conversationID); err != nil {
+		s.logger.Error("Failed to discard conversation", "conversationID", conversationID, "error", err)
+	}
+}
+
 // ContinueConversationRequest represents the request to continue a conversation in a new one
 type ContinueConversationRequest struct {
 	SourceConversationID string `json:"source_conversation_id"`
@@ -904,6 +1074,11 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if err := s.setConversationSandbox(ctx, conversation, sourceConv.Sandbox); err != nil {
+		s.logger.Error("Failed to set conversation sandbox", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 	conversationID := conversation.ConversationID
 
 	// Notify conversation list subscribers about the new conversation
@@ -1297,6 +1472,11 @@ type ModelInfo struct {
 	Source           string `json:"source,omitempty"` // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY")
 	Ready            bool   `json:"ready"`
 	MaxContextTokens int    `json:"max_context_tokens,omitempty"`
+	MaxOutputTokens  int    `json:"max_output_tokens,omitempty"`
+	// SupportsThinking and Pricing come from the model catalog and are
+	// omitted for models it does not know.
+	SupportsThinking *bool        `json:"supports_thinking,omitempty"`
+	Pricing          *llm.Pricing `json:"pricing,omitempty"`
 }
 
 // getModelList returns the list of available models
@@ -1322,6 +1502,14 @@ func (s *Server) getModelList() []ModelInfo {
 				info.DisplayName = modelInfo.DisplayName
 				info.Source = modelInfo.Source
 			}
+			if specs, ok := s.llmManager.(modelSpecer); ok {
+				if spec, ok := specs.ModelSpec(id); ok {
+					thinking := spec.SupportsThinking()
+					info.MaxOutputTokens = spec.MaxOutputTokens
+					info.SupportsThinking = &thinking
+					info.Pricing = spec.Pricing
+				}
+			}
 			modelList = append(modelList, info)
 		}
 	}
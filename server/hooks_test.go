@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
+	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/db/generated"
+	"github.com/tgruben-circuit/percy/hooks"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestMain(m *testing.M) {
+	sandbox.Init()
+	os.Exit(m.Run())
+}
+
+// TestHooks tests that project hooks run around the messages and tool calls
+// of a conversation.
+func TestHooks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // no user hooks
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".percy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{
+		"conversation_start": [{"command": "echo 'Team rule: never edit vendor/.'"}],
+		"user_prompt_submit": [{"command": "grep -q forbidden && { echo 'prompt not allowed' >&2; exit 2; }; true"}],
+		"pre_tool_use": [{"matcher": "bash", "command": "cat >> audit.jsonl; echo >> audit.jsonl"}]
+	}`
+	if err := os.WriteFile(filepath.Join(dir, ".percy", "hooks.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("bash: echo audited", dir)
+	if result := strings.TrimSpace(h.WaitToolResult()); result != "audited" {
+		t.Errorf("tool result = %q", result)
+	}
+
+	audit, err := os.ReadFile(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(audit), `"tool_name":"bash"`) || !strings.Contains(string(audit), "echo audited") {
+		t.Errorf("audit log = %s", audit)
+	}
+
+	// The context is sent to the model, but not recorded as the user's words.
+	if got := userTexts(t, h); !slices.Equal(got, []string{"bash: echo audited"}) {
+		t.Errorf("recorded user messages = %q", got)
+	}
+	if sent := sentMessage(h, "bash: echo audited"); len(sent) != 2 || sent[0].Text != "Team rule: never edit vendor/." {
+		t.Errorf("first message sent = %+v, want the conversation_start context added", sent)
+	}
+
+	body, _ := json.Marshal(ChatRequest{Message: "something forbidden", Model: "predictable"})
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat", strings.NewReader(string(body)))
+	w := httptest.NewRecorder()
+	h.server.handleChatConversation(w, req, h.convID)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "prompt not allowed") {
+		t.Errorf("blocked prompt: got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestHooksBlockBeforeChanges tests that a blocked prompt leaves the
+// conversation as it was, and that replayed prompts don't repeat the hook
+// context.
+func TestHooksBlockBeforeChanges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // no user hooks
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".percy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{
+		"conversation_start": [{"command": "echo 'Team rule: never edit vendor/.'"}],
+		"user_prompt_submit": [{"command": "grep -q forbidden && { echo 'prompt not allowed' >&2; exit 2; }; true"}]
+	}`
+	if err := os.WriteFile(filepath.Join(dir, ".percy", "hooks.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	// A blocked first message creates no conversation.
+	body, _ := json.Marshal(ChatRequest{Message: "something forbidden", Model: "predictable", Cwd: dir})
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body))))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("blocked new conversation: got %d: %s", w.Code, w.Body.String())
+	}
+	conversations, err := h.db.ListConversations(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conversations) != 0 {
+		t.Errorf("blocked new conversation created %d conversations", len(conversations))
+	}
+
+	h.NewConversation("hello", dir)
+	h.WaitResponse()
+
+	// A blocked edit keeps the history.
+	seq := userSequence(t, h)
+	body, _ = json.Marshal(EditMessageRequest{SequenceID: seq, Message: "something forbidden"})
+	w = httptest.NewRecorder()
+	h.server.handleEditMessage(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/edit", strings.NewReader(string(body))), h.convID)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("blocked edit: got %d: %s", w.Code, w.Body.String())
+	}
+	if got := userTexts(t, h); !slices.Equal(got, []string{"hello"}) {
+		t.Errorf("after blocked edit, user messages = %q", got)
+	}
+
+	// Regenerating resends the user's words, with the context once.
+	for range 2 {
+		w = httptest.NewRecorder()
+		h.server.handleRegenerateMessage(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/regenerate", nil), h.convID)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("regenerate: got %d: %s", w.Code, w.Body.String())
+		}
+		h.responsesCount = 0
+		h.WaitResponse()
+	}
+	if got := userTexts(t, h); !slices.Equal(got, []string{"hello"}) {
+		t.Errorf("after regenerate, user messages = %q", got)
+	}
+	if sent := sentMessage(h, "hello"); len(sent) != 2 || sent[0].Text != "Team rule: never edit vendor/." {
+		t.Errorf("regenerated message sent = %+v", sent)
+	}
+}
+
+// TestHooksSandboxed tests that a sandboxed conversation's hooks run in its
+// sandbox, since its commands can write the project's hooks.
+func TestHooksSandboxed(t *testing.T) {
+	if err := exec.Command("unshare", "--user", "--map-root-user", "--mount", "true").Run(); err != nil {
+		t.Skipf("user namespaces not available: %v", err)
+	}
+	t.Setenv("HOME", t.TempDir()) // no user hooks
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".percy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"user_prompt_submit": [{"command": "touch ` + outside + `/escaped; echo checked"}]}`
+	if err := os.WriteFile(filepath.Join(dir, ".percy", "hooks.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sb := &sandbox.Config{Mode: sandbox.ModeIsolated, Backend: sandbox.BackendNamespaces}
+	runner := loadHooks(dir, "conv", func() string { return dir }, sb, slog.Default())
+	result := runner.Run(context.Background(), hooks.Input{Event: hooks.UserPromptSubmit, Prompt: "hello"})
+	if result.Context != "checked" {
+		t.Errorf("context = %q", result.Context)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "escaped")); err == nil {
+		t.Error("a sandboxed conversation's hook wrote outside the workspace")
+	}
+
+	runner = loadHooks(dir, "conv", func() string { return dir }, nil, slog.Default())
+	runner.Run(context.Background(), hooks.Input{Event: hooks.UserPromptSubmit, Prompt: "hello"})
+	if _, err := os.Stat(filepath.Join(outside, "escaped")); err != nil {
+		t.Errorf("an unsandboxed conversation's hook didn't run: %v", err)
+	}
+}
+
+// userTexts returns the text of the recorded user messages in h's
+// conversation.
+func userTexts(t *testing.T, h *TestHarness) []string {
+	t.Helper()
+	var texts []string
+	for _, msg := range userMessages(t, h) {
+		var m llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &m); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range m.Content {
+			if c.Type == llm.ContentTypeText {
+				texts = append(texts, c.Text)
+			}
+		}
+	}
+	return texts
+}
+
+// sentMessage returns the content of the last user message the model was
+// sent that ends with text.
+func sentMessage(h *TestHarness, text string) []llm.Content {
+	requests := h.llm.GetRecentRequests()
+	for i := len(requests) - 1; i >= 0; i-- {
+		for _, m := range slices.Backward(requests[i].Messages) {
+			if m.Role == llm.MessageRoleUser && len(m.Content) > 0 && m.Content[len(m.Content)-1].Text == text {
+				return m.Content
+			}
+		}
+	}
+	return nil
+}
+
+// userSequence returns the sequence ID of the first user message in h's
+// conversation.
+func userSequence(t *testing.T, h *TestHarness) int64 {
+	t.Helper()
+	msgs := userMessages(t, h)
+	if len(msgs) == 0 {
+		t.Fatal("no user message")
+	}
+	return msgs[0].SequenceID
+}
+
+func userMessages(t *testing.T, h *TestHarness) []generated.Message {
+	t.Helper()
+	var messages []generated.Message
+	err := h.db.Queries(context.Background(), func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(context.Background(), h.convID)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []generated.Message
+	for _, msg := range messages {
+		if msg.Type == string(db.MessageTypeUser) && msg.LlmData != nil {
+			users = append(users, msg)
+		}
+	}
+	return users
+}
@@ -3,7 +3,12 @@ package server
 import (
 	"log/slog"
 
+	"github.com/tgruben-circuit/percy/claudetool/lsp"
+	"github.com/tgruben-circuit/percy/claudetool/postedit"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/models"
 )
 
 // Link represents a custom link to be displayed in the UI
@@ -34,6 +39,31 @@ type LLMConfig struct {
 	// Empty disables todo verification.
 	TodoVerifierModel string
 
+	// SecondOpinionModel is the model selector the second_opinion tool asks.
+	// Empty picks another available model.
+	SecondOpinionModel string
+
+	// Sandbox configures sandboxing of agent commands. Nil runs them directly
+	// unless a conversation chooses a sandbox mode.
+	Sandbox *sandbox.Config
+
+	// ScriptRuntime is the language scripted_tools runs. Empty chooses
+	// Python when uv is installed, and JavaScript otherwise.
+	ScriptRuntime string
+
+	// BashCheckpoints enables checkpoints of the files bash commands change
+	// in git workspaces, found by scanning the workspace before and after
+	// each command.
+	BashCheckpoints bool
+
+	// PostEdit configures formatting and diagnostics after the patch tool
+	// edits files. Nil disables them.
+	PostEdit *postedit.Config
+
+	// LanguageServers are language servers for code intelligence besides
+	// the built-in ones.
+	LanguageServers []lsp.ServerConfig
+
 	// Links are custom links to be displayed in the UI (optional)
 	Links []Link
 
@@ -45,8 +75,25 @@ type LLMConfig struct {
 	// Default: "http://localhost:11434". Set to "" to disable.
 	OllamaURL string
 
+	// OpenAIEndpoints are OpenAI-compatible servers (llama.cpp, vLLM, LM
+	// Studio) whose models are discovered (from percy.json "openai_endpoints").
+	OpenAIEndpoints []models.OpenAIEndpoint
+
+	// Ensembles define model IDs that combine several models (from
+	// percy.json "ensembles").
+	Ensembles []models.EnsembleConfig
+
 	// DB is the database for recording LLM requests (optional)
 	DB *db.DB
 
+	// RecordPath, if set, records every LLM exchange into this replay fixture.
+	RecordPath string
+
+	// ReplayModels lists "replay:<fixture>" model IDs to register.
+	ReplayModels []string
+
+	// Catalog adds or overrides model catalog entries (from percy.json "model_catalog").
+	Catalog []llm.ModelSpec
+
 	Logger *slog.Logger
 }
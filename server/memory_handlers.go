@@ -0,0 +1,431 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+// memoryTopicAPI is the JSON representation of a memory topic.
+type memoryTopicAPI struct {
+	TopicID   string `json:"topic_id"`
+	Name      string `json:"name"`
+	Summary   string `json:"summary"`
+	CellCount int    `json:"cell_count"`
+	Project   string `json:"project"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// memoryCellAPI is the JSON representation of a memory cell.
+type memoryCellAPI struct {
+	CellID     string  `json:"cell_id"`
+	TopicID    string  `json:"topic_id"`
+	SourceType string  `json:"source_type"`
+	SourceID   string  `json:"source_id"`
+	SourceName string  `json:"source_name"`
+	CellType   string  `json:"cell_type"`
+	Salience   float64 `json:"salience"`
+	Content    string  `json:"content"`
+	Project    string  `json:"project"`
+	Superseded bool    `json:"superseded"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+// memorySearchResultAPI is the JSON representation of a memory search result.
+type memorySearchResultAPI struct {
+	ResultType string  `json:"result_type"`
+	TopicID    string  `json:"topic_id"`
+	TopicName  string  `json:"topic_name,omitempty"`
+	CellID     string  `json:"cell_id,omitempty"`
+	CellType   string  `json:"cell_type,omitempty"`
+	SourceType string  `json:"source_type,omitempty"`
+	SourceID   string  `json:"source_id,omitempty"`
+	SourceName string  `json:"source_name,omitempty"`
+	Project    string  `json:"project"`
+	Salience   float64 `json:"salience"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score"`
+	UpdatedAt  string  `json:"updated_at,omitempty"`
+}
+
+// memoryRetentionReportAPI is the JSON representation of a retention run.
+type memoryRetentionReportAPI struct {
+	DryRun  bool     `json:"dry_run"`
+	Expired []string `json:"expired"`
+	Capped  []string `json:"capped"`
+	Decayed int      `json:"decayed"`
+}
+
+// updateMemoryCellRequest is the body of PATCH /api/memory/cells/{id}.
+type updateMemoryCellRequest struct {
+	Content  *string  `json:"content"`
+	CellType *string  `json:"cell_type"`
+	Salience *float64 `json:"salience"`
+}
+
+func toMemoryTopicAPI(t memory.Topic) memoryTopicAPI {
+	return memoryTopicAPI{
+		TopicID:   t.TopicID,
+		Name:      t.Name,
+		Summary:   t.Summary,
+		CellCount: t.CellCount,
+		Project:   t.Project,
+		UpdatedAt: t.UpdatedAt,
+	}
+}
+
+func toMemoryCellAPI(c memory.Cell) memoryCellAPI {
+	return memoryCellAPI{
+		CellID:     c.CellID,
+		TopicID:    c.TopicID,
+		SourceType: c.SourceType,
+		SourceID:   c.SourceID,
+		SourceName: c.SourceName,
+		CellType:   c.CellType,
+		Salience:   c.Salience,
+		Content:    c.Content,
+		Project:    c.Project,
+		Superseded: c.Superseded,
+		CreatedAt:  c.CreatedAt,
+	}
+}
+
+func toMemoryRetentionReportAPI(r memory.RetentionReport) memoryRetentionReportAPI {
+	out := memoryRetentionReportAPI{
+		DryRun:  r.DryRun,
+		Expired: r.Expired,
+		Capped:  r.Capped,
+		Decayed: r.Decayed,
+	}
+	if out.Expired == nil {
+		out.Expired = []string{}
+	}
+	if out.Capped == nil {
+		out.Capped = []string{}
+	}
+	return out
+}
+
+// requireMemoryDB writes a 503 and returns false if no memory database is configured.
+func (s *Server) requireMemoryDB(w http.ResponseWriter) bool {
+	if s.memoryDB == nil {
+		http.Error(w, "Memory database not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// memoryProject returns the project filter for a request: the explicit
+// "project" parameter, or the project of the "cwd" parameter. Empty means all projects.
+func memoryProject(r *http.Request) string {
+	if p := r.URL.Query().Get("project"); p != "" {
+		return p
+	}
+	if cwd := r.URL.Query().Get("cwd"); cwd != "" {
+		return memory.ProjectID(cwd)
+	}
+	return ""
+}
+
+func writeMemoryJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v) //nolint:errchkjson // best-effort HTTP response
+}
+
+// handleMemoryTopics lists topics, most recently updated first.
+// Optional query params: project or cwd to restrict to one project.
+func (s *Server) handleMemoryTopics(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+
+	var topics []memory.Topic
+	var err error
+	if project := memoryProject(r); project != "" {
+		topics, err = s.memoryDB.ProjectTopics(project)
+	} else {
+		topics, err = s.memoryDB.AllTopics()
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list topics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sort.Slice(topics, func(i, j int) bool { return topics[i].UpdatedAt > topics[j].UpdatedAt })
+	resp := make([]memoryTopicAPI, len(topics))
+	for i, t := range topics {
+		resp[i] = toMemoryTopicAPI(t)
+	}
+	writeMemoryJSON(w, resp)
+}
+
+// handleMemoryTopicCells lists the cells of a topic.
+// Superseded cells are included when include_superseded=true.
+func (s *Server) handleMemoryTopicCells(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	topicID := r.PathValue("id")
+
+	topic, err := s.memoryDB.GetTopic(topicID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get topic: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if topic == nil {
+		http.Error(w, "Topic not found", http.StatusNotFound)
+		return
+	}
+
+	includeSuperseded, _ := strconv.ParseBool(r.URL.Query().Get("include_superseded"))
+	cells, err := s.memoryDB.GetCellsByTopic(topicID, includeSuperseded)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get cells: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]memoryCellAPI, len(cells))
+	for i, c := range cells {
+		resp[i] = toMemoryCellAPI(c)
+	}
+	writeMemoryJSON(w, resp)
+}
+
+// handleMemorySearch runs a two-tier memory search.
+// Query params: q (required), source_type, project or cwd, limit.
+func (s *Server) handleMemorySearch(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := 25
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	var queryVec []float32
+	if s.embedder != nil {
+		vecs, err := s.embedder.Embed(r.Context(), []string{query})
+		if err == nil && len(vecs) > 0 {
+			queryVec = vecs[0]
+		}
+	}
+
+	results, err := s.memoryDB.TwoTierSearch(query, queryVec, r.URL.Query().Get("source_type"), memoryProject(r), limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]memorySearchResultAPI, len(results))
+	for i, mr := range results {
+		resp[i] = memorySearchResultAPI{
+			ResultType: mr.ResultType,
+			TopicID:    mr.TopicID,
+			TopicName:  mr.TopicName,
+			CellID:     mr.CellID,
+			CellType:   mr.CellType,
+			SourceType: mr.SourceType,
+			SourceID:   mr.SourceID,
+			SourceName: mr.SourceName,
+			Project:    mr.Project,
+			Salience:   mr.Salience,
+			Content:    mr.Content,
+			Score:      mr.Score,
+			UpdatedAt:  mr.UpdatedAt,
+		}
+	}
+	writeMemoryJSON(w, resp)
+}
+
+func (s *Server) handleMemoryGetCell(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	cell, err := s.memoryDB.GetCell(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get cell: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if cell == nil {
+		http.Error(w, "Cell not found", http.StatusNotFound)
+		return
+	}
+	writeMemoryJSON(w, toMemoryCellAPI(*cell))
+}
+
+// handleMemoryUpdateCell edits a cell's content, type and/or salience.
+func (s *Server) handleMemoryUpdateCell(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	var req updateMemoryCellRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	cell, err := s.memoryDB.UpdateCell(r.Context(), r.PathValue("id"), memory.CellUpdate{
+		Content:  req.Content,
+		CellType: req.CellType,
+		Salience: req.Salience,
+	}, s.embedder)
+	if errors.Is(err, memory.ErrInvalidCellUpdate) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update cell: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if cell == nil {
+		http.Error(w, "Cell not found", http.StatusNotFound)
+		return
+	}
+	writeMemoryJSON(w, toMemoryCellAPI(*cell))
+}
+
+// handleMemoryDeleteCell permanently deletes a cell.
+func (s *Server) handleMemoryDeleteCell(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	deleted, err := s.memoryDB.DeleteCell(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete cell: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Cell not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMemorySupersedeCell marks a cell as superseded, hiding it from search
+// while keeping it for inspection.
+func (s *Server) handleMemorySupersedeCell(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	cellID := r.PathValue("id")
+	cell, err := s.memoryDB.GetCell(cellID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get cell: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if cell == nil {
+		http.Error(w, "Cell not found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.memoryDB.ForgetCells("", []string{cellID}); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to supersede cell: %v", err), http.StatusInternalServerError)
+		return
+	}
+	cell.Superseded = true
+	writeMemoryJSON(w, toMemoryCellAPI(*cell))
+}
+
+// handleMemoryConsolidateTopic re-summarizes a topic with the LLM.
+// Optional query param: model (defaults to the server's default model).
+func (s *Server) handleMemoryConsolidateTopic(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	topicID := r.PathValue("id")
+	topic, err := s.memoryDB.GetTopic(topicID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get topic: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if topic == nil {
+		http.Error(w, "Topic not found", http.StatusNotFound)
+		return
+	}
+
+	modelID := r.URL.Query().Get("model")
+	if modelID == "" {
+		modelID = s.defaultModel
+	}
+	if modelID == "" {
+		http.Error(w, "No model available for consolidation", http.StatusBadRequest)
+		return
+	}
+	svc, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported model: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+	if err := memory.ConsolidateTopic(ctx, s.memoryDB, svc, s.embedder, topicID); err != nil {
+		http.Error(w, fmt.Sprintf("Consolidation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	topic, err = s.memoryDB.GetTopic(topicID)
+	if err != nil || topic == nil {
+		http.Error(w, "Failed to reload topic", http.StatusInternalServerError)
+		return
+	}
+	writeMemoryJSON(w, toMemoryTopicAPI(*topic))
+}
+
+// handleMemoryReindexConversation forces a conversation to be re-indexed,
+// even if its messages have not changed. Indexing runs asynchronously.
+func (s *Server) handleMemoryReindexConversation(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	conversationID := r.PathValue("id")
+	if _, err := s.db.GetConversationByID(r.Context(), conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err := s.memoryDB.ClearIndexState("conversation", conversationID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reset index state: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.EnqueueIndex(conversationID)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleMemoryRetentionReport reports what the retention policy would change
+// right now, without modifying anything.
+func (s *Server) handleMemoryRetentionReport(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	report, err := s.memoryDB.ApplyRetention(s.memoryRetention, time.Now(), true)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to evaluate retention: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeMemoryJSON(w, toMemoryRetentionReportAPI(report))
+}
+
+// handleMemoryRetentionRun applies the retention policy immediately and
+// compacts the memory database.
+func (s *Server) handleMemoryRetentionRun(w http.ResponseWriter, r *http.Request) {
+	if !s.requireMemoryDB(w) {
+		return
+	}
+	report, err := s.runMemoryRetention()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to apply retention: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeMemoryJSON(w, toMemoryRetentionReportAPI(report))
+}
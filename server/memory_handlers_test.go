@@ -0,0 +1,250 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func newMemoryTestMux(t *testing.T) (*TestHarness, *memory.DB, *http.ServeMux) {
+	t.Helper()
+	h := NewTestHarness(t)
+	t.Cleanup(h.Close)
+
+	mdb, err := memory.Open(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mdb.Close() })
+	h.server.SetMemoryDB(mdb)
+
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "topic_build", Name: "build", Summary: "The build uses make and pnpm.", Project: "github.com/org/a"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []memory.Cell{
+		{CellID: "c1", TopicID: "topic_build", SourceType: "conversation", SourceID: "conv-1", CellType: "fact", Salience: 0.5, Content: "The UI is built with pnpm", Project: "github.com/org/a"},
+		{CellID: "c2", TopicID: "topic_build", SourceType: "conversation", SourceID: "conv-1", CellType: "fact", Salience: 0.7, Content: "Run make to build the server", Project: "github.com/org/a"},
+	} {
+		if err := mdb.InsertCell(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	return h, mdb, mux
+}
+
+func serveMemory(mux *http.ServeMux, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMemoryAPIBrowse(t *testing.T) {
+	_, _, mux := newMemoryTestMux(t)
+
+	rec := serveMemory(mux, "GET", "/api/memory/topics?project=github.com/org/a", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("topics: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var topics []memoryTopicAPI
+	if err := json.Unmarshal(rec.Body.Bytes(), &topics); err != nil {
+		t.Fatal(err)
+	}
+	if len(topics) != 1 || topics[0].TopicID != "topic_build" {
+		t.Fatalf("unexpected topics: %+v", topics)
+	}
+
+	rec = serveMemory(mux, "GET", "/api/memory/topics?project=github.com/org/other", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &topics); err != nil {
+		t.Fatal(err)
+	}
+	if len(topics) != 0 {
+		t.Errorf("expected no topics for other project, got %+v", topics)
+	}
+
+	rec = serveMemory(mux, "GET", "/api/memory/topics/topic_build/cells", "")
+	var cells []memoryCellAPI
+	if err := json.Unmarshal(rec.Body.Bytes(), &cells); err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != 2 || cells[0].CellID != "c2" {
+		t.Fatalf("expected 2 cells ordered by salience, got %+v", cells)
+	}
+
+	rec = serveMemory(mux, "GET", "/api/memory/topics/missing/cells", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing topic, got %d", rec.Code)
+	}
+
+	rec = serveMemory(mux, "GET", "/api/memory/search?q=pnpm", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var results []memorySearchResultAPI
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("expected search results")
+	}
+
+	rec = serveMemory(mux, "GET", "/api/memory/search", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without q, got %d", rec.Code)
+	}
+}
+
+func TestMemoryAPIEdit(t *testing.T) {
+	_, mdb, mux := newMemoryTestMux(t)
+
+	rec := serveMemory(mux, "PATCH", "/api/memory/cells/c1", `{"content": "The UI is built with pnpm 9", "cell_type": "decision", "salience": 0.8}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var cell memoryCellAPI
+	if err := json.Unmarshal(rec.Body.Bytes(), &cell); err != nil {
+		t.Fatal(err)
+	}
+	if cell.Content != "The UI is built with pnpm 9" || cell.CellType != "decision" || cell.Salience != 0.8 {
+		t.Errorf("unexpected updated cell: %+v", cell)
+	}
+
+	rec = serveMemory(mux, "PATCH", "/api/memory/cells/c1", `{"cell_type": "gossip"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid cell_type, got %d", rec.Code)
+	}
+	rec = serveMemory(mux, "PATCH", "/api/memory/cells/missing", `{"salience": 0.1}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing cell, got %d", rec.Code)
+	}
+	rec = serveMemory(mux, "POST", "/api/memory/topics/missing/consolidate", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 consolidating a missing topic, got %d", rec.Code)
+	}
+
+	rec = serveMemory(mux, "POST", "/api/memory/cells/c1/supersede", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("supersede: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	got, _ := mdb.GetCell("c1")
+	if got == nil || !got.Superseded {
+		t.Errorf("expected c1 superseded, got %+v", got)
+	}
+
+	rec = serveMemory(mux, "DELETE", "/api/memory/cells/c2", "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := mdb.GetCell("c2"); got != nil {
+		t.Errorf("expected c2 deleted, got %+v", got)
+	}
+	rec = serveMemory(mux, "DELETE", "/api/memory/cells/c2", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting twice, got %d", rec.Code)
+	}
+}
+
+func TestMemoryAPIReindex(t *testing.T) {
+	h, mdb, mux := newMemoryTestMux(t)
+
+	conv, err := h.db.CreateConversation(t.Context(), nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mdb.SetIndexState("conversation", conv.ConversationID, "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serveMemory(mux, "POST", "/api/memory/conversations/"+conv.ConversationID+"/reindex", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("reindex: expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if indexed, _ := mdb.IsIndexed("conversation", conv.ConversationID, "abc"); indexed {
+		t.Error("expected index state to be cleared")
+	}
+
+	rec = serveMemory(mux, "POST", "/api/memory/conversations/missing/reindex", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing conversation, got %d", rec.Code)
+	}
+}
+
+func TestMemoryAPIUnavailable(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+
+	rec := serveMemory(mux, "GET", "/api/memory/topics", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without memory DB, got %d", rec.Code)
+	}
+}
+
+func TestMemoryAPIRetention(t *testing.T) {
+	h, mdb, mux := newMemoryTestMux(t)
+	h.server.SetMemoryRetention(memory.RetentionPolicy{MaxCellsPerTopic: 1}, 0)
+
+	rec := serveMemory(mux, "GET", "/api/memory/retention", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("report: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var report memoryRetentionReportAPI
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || len(report.Capped) != 1 || report.Capped[0] != "c1" {
+		t.Fatalf("unexpected dry-run report: %+v", report)
+	}
+	if c, _ := mdb.GetCell("c1"); c.Superseded {
+		t.Fatal("dry run must not supersede cells")
+	}
+
+	rec = serveMemory(mux, "POST", "/api/memory/retention", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("run: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if c, _ := mdb.GetCell("c1"); !c.Superseded {
+		t.Error("expected c1 to be capped")
+	}
+}
+
+func TestIndexWorkspaceDebounced(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	h, mdb, _ := newMemoryTestMux(t)
+
+	root := t.TempDir()
+	if out, err := exec.Command("git", "-C", root, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	readme := filepath.Join(root, "README.md")
+	if err := os.WriteFile(readme, []byte("# Gadget\n\nGadgets ship via the plumbus channel.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h.server.indexWorkspace(root)
+	if results, _ := mdb.SearchCellsFTS("plumbus", "file", "", 10); len(results) != 1 {
+		t.Fatalf("expected README to be indexed, got %+v", results)
+	}
+
+	// A second request within the reindex interval is skipped.
+	if err := os.WriteFile(readme, []byte("# Gadget\n\nGadgets ship via the schleem channel.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h.server.indexWorkspace(root)
+	if results, _ := mdb.SearchCellsFTS("schleem", "file", "", 10); len(results) != 0 {
+		t.Errorf("expected debounced reindex to be skipped, got %+v", results)
+	}
+}
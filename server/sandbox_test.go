@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewConversationSandbox(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	post := func(mode string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(ChatRequest{Message: "echo: hi", Model: "predictable", Sandbox: mode})
+		req := httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.server.handleNewConversation(w, req)
+		return w
+	}
+
+	if w := post("docker"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown sandbox mode: expected 400, got %d", w.Code)
+	}
+
+	w := post("off")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := h.db.GetConversationByID(context.Background(), resp.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.Sandbox == nil || *conv.Sandbox != "off" {
+		t.Errorf("expected sandbox off, got %v", conv.Sandbox)
+	}
+
+	// Forks keep the source conversation's sandbox.
+	body, _ := json.Marshal(ForkConversationRequest{SourceConversationID: conv.ConversationID, AtSequenceID: 1})
+	req := httptest.NewRequest("POST", "/api/conversations/fork", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.server.handleForkConversation(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("fork: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	fork, err := h.db.GetConversationByID(context.Background(), resp.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.Sandbox == nil || *fork.Sandbox != "off" {
+		t.Errorf("expected forked sandbox off, got %v", fork.Sandbox)
+	}
+}
@@ -87,6 +87,11 @@ type modelResolver interface {
 	ResolveModelID(modelID string) (string, error)
 }
 
+// modelSpecer is implemented by providers that can report catalog entries.
+type modelSpecer interface {
+	ModelSpec(modelID string) (llm.ModelSpec, bool)
+}
+
 // NewLLMServiceManager creates a new LLM service manager from config
 func NewLLMServiceManager(cfg *LLMConfig) LLMProvider {
 	// Convert LLMConfig to models.Config
@@ -97,8 +102,13 @@ func NewLLMServiceManager(cfg *LLMConfig) LLMProvider {
 		FireworksAPIKey: cfg.FireworksAPIKey,
 		Gateway:         cfg.Gateway,
 		OllamaURL:       cfg.OllamaURL,
+		OpenAIEndpoints: cfg.OpenAIEndpoints,
+		Ensembles:       cfg.Ensembles,
 		Logger:          cfg.Logger,
 		DB:              cfg.DB,
+		RecordPath:      cfg.RecordPath,
+		ReplayModels:    cfg.ReplayModels,
+		Catalog:         cfg.Catalog,
 	}
 
 	manager, err := models.NewManager(modelConfig)
@@ -243,6 +253,11 @@ type Server struct {
 	clusterNode         *cluster.Node
 	shutdownCh          chan struct{} // Signals background routines to stop
 	indexQueue          chan string   // Buffered queue for conversation IDs to index
+	memoryRetention     memory.RetentionPolicy
+	retentionInterval   time.Duration // 0 disables the background retention job
+	workspaceIndex      memory.WorkspaceConfig
+	workspaceQueue      chan string          // Buffered queue for working directories to index
+	workspaceIndexedAt  map[string]time.Time // git root -> last workspace index; guarded by mu
 }
 
 // NewServer creates a new server instance
@@ -262,6 +277,10 @@ func NewServer(database *db.DB, llmManager LLMProvider, toolSetConfig claudetool
 		notifDispatcher:     notifications.NewDispatcher(logger),
 		shutdownCh:          make(chan struct{}),
 		indexQueue:          make(chan string, 64),
+		memoryRetention:     memory.DefaultRetentionPolicy(),
+		workspaceIndex:      memory.DefaultWorkspaceConfig(),
+		workspaceQueue:      make(chan string, 16),
+		workspaceIndexedAt:  make(map[string]time.Time),
 	}
 	go s.indexWorker()
 
@@ -292,6 +311,19 @@ func (s *Server) SetMemoryDB(mdb *memory.DB) {
 	s.memoryDB = mdb
 }
 
+// SetMemoryRetention sets the retention policy for the memory database and how
+// often the background job applies it. An interval of 0 disables the job; the
+// policy is still used by the retention API.
+func (s *Server) SetMemoryRetention(policy memory.RetentionPolicy, interval time.Duration) {
+	s.memoryRetention = policy
+	s.retentionInterval = interval
+}
+
+// SetWorkspaceIndexing configures indexing of workspace documents into memory.
+func (s *Server) SetWorkspaceIndexing(cfg memory.WorkspaceConfig) {
+	s.workspaceIndex = cfg
+}
+
 // SetEmbedder sets the embedding provider for vector search and indexing.
 // If nil, vector search is disabled (FTS-only).
 func (s *Server) SetEmbedder(e memory.Embedder) {
@@ -320,13 +352,29 @@ func (s *Server) EnqueueIndex(conversationID string) {
 	}
 }
 
-// indexWorker processes the indexQueue sequentially until shutdownCh is closed,
-// then drains any remaining items.
+// EnqueueWorkspaceIndex enqueues a working directory whose git repository's
+// documents should be indexed into memory.
+// Non-blocking: drops the request if the queue is full.
+func (s *Server) EnqueueWorkspaceIndex(dir string) {
+	if s.memoryDB == nil || !s.workspaceIndex.Enabled || dir == "" {
+		return
+	}
+	select {
+	case s.workspaceQueue <- dir:
+	default:
+		s.logger.Warn("Workspace index queue full, dropping", "dir", dir)
+	}
+}
+
+// indexWorker processes the indexQueue and workspaceQueue sequentially until
+// shutdownCh is closed, then drains any remaining conversations.
 func (s *Server) indexWorker() {
 	for {
 		select {
 		case convID := <-s.indexQueue:
 			s.indexConversation(convID)
+		case dir := <-s.workspaceQueue:
+			s.indexWorkspace(dir)
 		case <-s.shutdownCh:
 			// Drain remaining items
 			for {
@@ -341,6 +389,43 @@ func (s *Server) indexWorker() {
 	}
 }
 
+// workspaceReindexInterval is the minimum time between workspace index runs
+// for the same git repository.
+const workspaceReindexInterval = 10 * time.Minute
+
+// indexWorkspace indexes the documents of the git repository containing dir.
+func (s *Server) indexWorkspace(dir string) {
+	if s.memoryDB == nil {
+		return
+	}
+	root := memory.GitRoot(dir)
+	if root == "" {
+		return
+	}
+
+	s.mu.Lock()
+	last, ok := s.workspaceIndexedAt[root]
+	if ok && time.Since(last) < workspaceReindexInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.workspaceIndexedAt[root] = time.Now()
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	project := memory.ProjectID(root)
+	stats, err := s.memoryDB.IndexWorkspace(ctx, root, project, s.workspaceIndex, s.embedder)
+	if err != nil {
+		s.logger.Warn("Memory index: failed to index workspace", "root", root, "error", err)
+		return
+	}
+	if stats.Indexed > 0 || stats.Removed > 0 {
+		s.logger.Info("Indexed workspace documents for memory search", "root", root, "project", project, "files", stats.Files, "indexed", stats.Indexed, "removed", stats.Removed)
+	}
+}
+
 // indexConversation indexes a conversation's messages into the memory database.
 func (s *Server) indexConversation(conversationID string) {
 	if s.memoryDB == nil {
@@ -410,21 +495,30 @@ func (s *Server) indexConversation(conversationID string) {
 		llmSvc, _ = s.llmManager.GetService(modelID) // best-effort
 	}
 
-	if err := s.memoryDB.IndexConversation(ctx, conversationID, slug, messages, s.embedder, llmSvc); err != nil {
+	project := ""
+	if conv.Cwd != nil {
+		project = memory.ProjectID(*conv.Cwd)
+	}
+
+	if err := s.memoryDB.IndexConversation(ctx, conversationID, slug, project, messages, s.embedder, llmSvc); err != nil {
 		s.logger.Warn("Memory index: failed to index conversation", "conversationID", conversationID, "error", err)
 		return
 	}
 
-	s.logger.Info("Indexed conversation for memory search", "conversationID", conversationID, "messages", len(messages), "slug", slug)
+	s.logger.Info("Indexed conversation for memory search", "conversationID", conversationID, "messages", len(messages), "slug", slug, "project", project)
 
 	// Push to MuninnDB if configured
 	if s.muninnSink != nil {
 		var cells []muninn.CellEngram
 		for _, msg := range messages {
+			tags := []string{msg.Role}
+			if project != "" {
+				tags = append(tags, "project:"+project)
+			}
 			cells = append(cells, muninn.CellEngram{
 				Concept: slug,
 				Content: msg.Text,
-				Tags:    []string{msg.Role},
+				Tags:    tags,
 			})
 		}
 		go func() {
@@ -489,6 +583,19 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("GET /api/usage", gzipHandler(http.HandlerFunc(s.handleUsage)))
 	mux.Handle("/api/models", http.HandlerFunc(s.handleModels))
 
+	// Memory API
+	mux.Handle("GET /api/memory/topics", gzipHandler(http.HandlerFunc(s.handleMemoryTopics)))
+	mux.Handle("GET /api/memory/topics/{id}/cells", gzipHandler(http.HandlerFunc(s.handleMemoryTopicCells)))
+	mux.Handle("POST /api/memory/topics/{id}/consolidate", http.HandlerFunc(s.handleMemoryConsolidateTopic))
+	mux.Handle("GET /api/memory/search", gzipHandler(http.HandlerFunc(s.handleMemorySearch)))
+	mux.Handle("GET /api/memory/cells/{id}", http.HandlerFunc(s.handleMemoryGetCell))
+	mux.Handle("PATCH /api/memory/cells/{id}", http.HandlerFunc(s.handleMemoryUpdateCell))
+	mux.Handle("DELETE /api/memory/cells/{id}", http.HandlerFunc(s.handleMemoryDeleteCell))
+	mux.Handle("POST /api/memory/cells/{id}/supersede", http.HandlerFunc(s.handleMemorySupersedeCell))
+	mux.Handle("GET /api/memory/retention", http.HandlerFunc(s.handleMemoryRetentionReport))
+	mux.Handle("POST /api/memory/retention", http.HandlerFunc(s.handleMemoryRetentionRun))
+	mux.Handle("POST /api/memory/conversations/{id}/reindex", http.HandlerFunc(s.handleMemoryReindexConversation))
+
 	// Skills API
 	mux.Handle("GET /api/skills", http.HandlerFunc(s.handleSkills))
 	mux.Handle("GET /api/skills/{name}", http.HandlerFunc(s.handleSkillContent))
@@ -907,6 +1014,7 @@ func (s *Server) getOrCreateConversationManager(ctx context.Context, conversatio
 		if err := manager.Hydrate(ctx); err != nil {
 			return nil, err
 		}
+		s.EnqueueWorkspaceIndex(manager.cwd)
 
 		// Store in map (brief lock). Singleflight prevents races.
 		s.mu.Lock()
@@ -1363,6 +1471,15 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 	// Start auto-upgrade routine
 	go s.autoUpgradeRoutine()
 
+	// Start memory retention routine
+	go s.memoryRetentionRoutine()
+
+	// Bring memory embeddings in line with the configured embedder
+	go s.reembedMemory()
+
+	// Scope memory cells that predate project scoping
+	go s.backfillMemoryProjects()
+
 	// Get actual port from listener
 	actualPort := listener.Addr().(*net.TCPAddr).Port
 
@@ -1433,6 +1550,98 @@ func (s *Server) autoUpgradeRoutine() {
 	}
 }
 
+// memoryRetentionRoutine applies the memory retention policy every
+// retentionInterval, starting shortly after startup.
+func (s *Server) memoryRetentionRoutine() {
+	if s.memoryDB == nil || s.retentionInterval <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(5 * time.Minute)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-s.shutdownCh:
+		return
+	}
+
+	s.runMemoryRetention()
+
+	ticker := time.NewTicker(s.retentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.runMemoryRetention()
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// reembedMemory re-embeds memory cells and topics whose embeddings are missing
+// or were produced by a different embedder. It stops early on shutdown.
+func (s *Server) reembedMemory() {
+	if s.memoryDB == nil || s.embedder == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.shutdownCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stats, err := s.memoryDB.Reembed(ctx, s.embedder)
+	if err != nil {
+		s.logger.Warn("Memory re-embed failed", "error", err, "cells", stats.Cells, "topics", stats.Topics)
+		return
+	}
+	if stats.Cells > 0 || stats.Topics > 0 {
+		s.logger.Info("Re-embedded memory", "full", stats.Full, "cells", stats.Cells, "topics", stats.Topics)
+	}
+}
+
+// backfillMemoryProjects assigns projects to conversation memory cells that
+// have none, using each conversation's working directory.
+func (s *Server) backfillMemoryProjects() {
+	if s.memoryDB == nil {
+		return
+	}
+	n, err := s.memoryDB.BackfillProjects(func(conversationID string) string {
+		conv, err := s.db.GetConversationByID(context.Background(), conversationID)
+		if err != nil || conv.Cwd == nil {
+			return ""
+		}
+		return memory.ProjectID(*conv.Cwd)
+	})
+	if err != nil {
+		s.logger.Warn("Memory project backfill failed", "error", err)
+		return
+	}
+	if n > 0 {
+		s.logger.Info("Assigned projects to memory cells", "cells", n)
+	}
+}
+
+// runMemoryRetention applies the retention policy and compacts the memory database.
+func (s *Server) runMemoryRetention() (memory.RetentionReport, error) {
+	report, err := s.memoryDB.ApplyRetention(s.memoryRetention, time.Now(), false)
+	if err != nil {
+		s.logger.Warn("Memory retention failed", "error", err)
+		return report, err
+	}
+	if err := s.memoryDB.Optimize(); err != nil {
+		s.logger.Warn("Memory optimize failed", "error", err)
+		return report, err
+	}
+	s.logger.Info("Applied memory retention", "expired", len(report.Expired), "capped", len(report.Capped), "decayed", report.Decayed)
+	return report, nil
+}
+
 // tryAutoUpgrade attempts to upgrade if auto-upgrade is enabled and server is idle
 func (s *Server) tryAutoUpgrade() {
 	ctx := context.Background()
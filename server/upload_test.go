@@ -88,6 +88,46 @@ func TestUploadEndpoint(t *testing.T) {
 	os.Remove(path)
 }
 
+func TestUploadEndpointPastedPDF(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	predictableService := loop.NewPredictableService()
+	llmManager := &testLLMManager{service: predictableService}
+	server := NewServer(database, llmManager, claudetool.ToolSetConfig{}, slog.Default(), true, "", "predictable", "", nil)
+
+	// Pasted files may arrive without an extension.
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdfData := []byte("%PDF-1.7\n%%EOF\n")
+	part.Write(pdfData)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	server.handleUpload(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(response["path"])
+	if !strings.HasSuffix(response["path"], ".pdf") || response["media_type"] != "application/pdf" {
+		t.Errorf("unexpected response: %v", response)
+	}
+	if data, err := os.ReadFile(response["path"]); err != nil || !bytes.Equal(data, pdfData) {
+		t.Errorf("uploaded file content mismatch: %q, %v", data, err)
+	}
+}
+
 func TestUploadEndpointMethodNotAllowed(t *testing.T) {
 	database, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -20,13 +20,16 @@ type usageDateRow struct {
 }
 
 type usageConversationRow struct {
-	ConversationID    string  `json:"conversation_id"`
-	Slug              *string `json:"slug"`
-	Model             *string `json:"model"`
-	MessageCount      int64   `json:"message_count"`
-	TotalInputTokens  float64 `json:"total_input_tokens"`
-	TotalOutputTokens float64 `json:"total_output_tokens"`
-	TotalCostUSD      float64 `json:"total_cost_usd"`
+	ConversationID        string  `json:"conversation_id"`
+	Slug                  *string `json:"slug"`
+	Model                 *string `json:"model"`
+	MessageCount          int64   `json:"message_count"`
+	TotalInputTokens      float64 `json:"total_input_tokens"`
+	TotalOutputTokens     float64 `json:"total_output_tokens"`
+	TotalCacheReadTokens  float64 `json:"total_cache_read_tokens"`
+	TotalCacheWriteTokens float64 `json:"total_cache_write_tokens"`
+	CacheHitRate          float64 `json:"cache_hit_rate"` // fraction of input tokens read from the prompt cache
+	TotalCostUSD          float64 `json:"total_cost_usd"`
 }
 
 type usageResponse struct {
@@ -82,6 +85,16 @@ func estimateCost(storedCost float64, model *string, inputTokens, outputTokens,
 	return llm.EstimateCostUSD(*model, uint64(inputTokens), uint64(outputTokens), uint64(cacheReadTokens), uint64(cacheWriteTokens))
 }
 
+// cacheHitRate returns the fraction of all input tokens, cached or not,
+// that were read from the provider's prompt cache.
+func cacheHitRate(inputTokens, cacheReadTokens, cacheWriteTokens float64) float64 {
+	total := inputTokens + cacheReadTokens + cacheWriteTokens
+	if total == 0 {
+		return 0
+	}
+	return cacheReadTokens / total
+}
+
 func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -157,13 +170,16 @@ func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
 		cacheWrite := toFloat64(row.TotalCacheWriteTokens)
 		cost := estimateCost(toFloat64(row.TotalCostUsd), model, input, output, cacheRead, cacheWrite)
 		byConv[i] = usageConversationRow{
-			ConversationID:    row.ConversationID,
-			Slug:              row.Slug,
-			Model:             model,
-			MessageCount:      row.MessageCount,
-			TotalInputTokens:  input,
-			TotalOutputTokens: output,
-			TotalCostUSD:      cost,
+			ConversationID:        row.ConversationID,
+			Slug:                  row.Slug,
+			Model:                 model,
+			MessageCount:          row.MessageCount,
+			TotalInputTokens:      input,
+			TotalOutputTokens:     output,
+			TotalCacheReadTokens:  cacheRead,
+			TotalCacheWriteTokens: cacheWrite,
+			CacheHitRate:          cacheHitRate(input, cacheRead, cacheWrite),
+			TotalCostUSD:          cost,
 		}
 	}
 
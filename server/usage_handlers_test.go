@@ -122,6 +122,55 @@ func TestHandleUsageCostEstimation(t *testing.T) {
 	}
 }
 
+func TestHandleUsageCacheHitRate(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.cleanup()
+
+	ctx := context.Background()
+	model := "gemini-2.5-pro"
+	conv, err := h.db.CreateConversation(ctx, nil, true, nil, &model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []map[string]interface{}{
+		{"input_tokens": 100, "cache_creation_input_tokens": 200, "cache_read_input_tokens": 0, "output_tokens": 10, "model": model},
+		{"input_tokens": 100, "cache_creation_input_tokens": 0, "cache_read_input_tokens": 600, "output_tokens": 10, "model": model},
+	} {
+		_, err = h.db.CreateMessage(ctx, db.CreateMessageParams{
+			ConversationID: conv.ConversationID,
+			Type:           db.MessageTypeAgent,
+			LLMData: llm.Message{
+				Role:    llm.MessageRoleAssistant,
+				Content: []llm.Content{{Type: llm.ContentTypeText, Text: "hi"}},
+			},
+			UsageData: u,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/usage?since=2020-01-01", nil)
+	w := httptest.NewRecorder()
+	h.server.handleUsage(w, req)
+
+	var resp usageResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(resp.ByConversation) != 1 {
+		t.Fatalf("expected 1 conversation row, got %d", len(resp.ByConversation))
+	}
+	row := resp.ByConversation[0]
+	if row.TotalCacheReadTokens != 600 || row.TotalCacheWriteTokens != 200 {
+		t.Errorf("cache tokens = %v read, %v written", row.TotalCacheReadTokens, row.TotalCacheWriteTokens)
+	}
+	// 600 of 1000 input tokens came from the cache.
+	if row.CacheHitRate != 0.6 {
+		t.Errorf("cache hit rate = %v, want 0.6", row.CacheHitRate)
+	}
+}
+
 func TestHandleUsageEmpty(t *testing.T) {
 	h := NewTestHarness(t)
 	defer h.cleanup()
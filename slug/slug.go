@@ -55,6 +55,17 @@ func GenerateSlug(ctx context.Context, llmProvider LLMServiceProvider, database
 	return "", fmt.Errorf("failed to generate unique slug after 100 attempts")
 }
 
+// slugFormat is the structured output format of slug generation.
+var slugFormat = &llm.ResponseFormat{
+	Name:        "conversation_slug",
+	Description: "Record the slug for the conversation.",
+	Schema: llm.MustSchema(`{
+		"type": "object",
+		"properties": {"slug": {"type": "string", "description": "2-6 lowercase words separated by hyphens"}},
+		"required": ["slug"]
+	}`),
+}
+
 // generateSlugText generates a human-readable slug for a conversation based on the user message
 // Priority order:
 // 1. If conversationModelID is "predictable", use it
@@ -115,7 +126,7 @@ The slug should:
 - Capture the main topic or intent
 - Be suitable as a filename or URL path
 
-Respond with only the slug, nothing else.`, userMessage)
+Respond with JSON: {"slug": "..."}`, userMessage)
 
 	message := llm.Message{
 		Role: llm.MessageRoleUser,
@@ -125,24 +136,21 @@ Respond with only the slug, nothing else.`, userMessage)
 	}
 
 	request := &llm.Request{
-		Messages: []llm.Message{message},
+		Messages:       []llm.Message{message},
+		ResponseFormat: slugFormat,
 	}
 
 	// Make LLM request with timeout
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	response, err := llmService.Do(ctxWithTimeout, request)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate slug: %w", err)
+	var result struct {
+		Slug string `json:"slug"`
 	}
-
-	// Extract text from response
-	if len(response.Content) == 0 {
-		return "", fmt.Errorf("empty response from LLM")
+	if err := llm.DoJSON(ctxWithTimeout, llmService, request, &result); err != nil {
+		return "", fmt.Errorf("failed to generate slug: %w", err)
 	}
-
-	slug := strings.TrimSpace(response.Content[0].Text)
+	slug := result.Slug
 
 	// Clean and validate the slug
 	slug = Sanitize(slug)
@@ -2,6 +2,7 @@ package slug
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -128,7 +129,7 @@ func TestGenerateSlug_DatabaseIntegration(t *testing.T) {
 	// Create mock LLM provider that always returns the same slug
 	mockLLM := &MockLLMProvider{
 		Service: &MockLLMService{
-			ResponseText: "test-slug", // Always return the same slug to force conflicts
+			ResponseText: `{"slug": "test-slug"}`, // Always return the same slug to force conflicts
 		},
 	}
 
@@ -284,8 +285,8 @@ func TestGenerateSlug_EmptyResponse(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for empty LLM response, got nil")
 	}
-	if err.Error() != "empty response from LLM" {
-		t.Errorf("Expected 'empty response' error, got %q", err.Error())
+	if !errors.Is(err, llm.ErrInvalidOutput) {
+		t.Errorf("Expected an invalid output error, got %q", err.Error())
 	}
 }
 
@@ -326,7 +327,7 @@ func TestGenerateSlug_SanitizationError(t *testing.T) {
 	// Mock LLM that returns only special characters that get sanitized away
 	mockLLM := &MockLLMProvider{
 		Service: &MockLLMService{
-			ResponseText: "@#$%^&*()", // All special characters that will be removed
+			ResponseText: `{"slug": "@#$%^&*()"}`, // All special characters that will be removed
 		},
 	}
 
@@ -372,7 +373,7 @@ func TestGenerateSlug_DatabaseError(t *testing.T) {
 	// Create mock LLM provider
 	mockLLM := &MockLLMProvider{
 		Service: &MockLLMService{
-			ResponseText: "test-slug",
+			ResponseText: `{"slug": "test-slug"}`,
 		},
 	}
 
@@ -401,7 +402,7 @@ func TestGenerateSlug_PredictableModel(t *testing.T) {
 	// Mock LLM that has predictable model available
 	mockLLM := &MockLLMProvider{
 		Service: &MockLLMService{
-			ResponseText: "predictable-slug",
+			ResponseText: `{"slug": "predictable-slug"}`,
 		},
 	}
 
@@ -424,7 +425,7 @@ func TestGenerateSlug_ConversationModelFallback(t *testing.T) {
 	// Mock LLM provider that doesn't have predictable model but has a conversation model
 	mockLLM := &MockLLMProviderPredictableFallback{
 		fallbackService: &MockLLMService{
-			ResponseText: "fallback-slug",
+			ResponseText: `{"slug": "fallback-slug"}`,
 		},
 	}
 
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -14,6 +15,7 @@ import (
 type ChatClient interface {
 	SendMessage(conversationID, message string) error
 	CancelConversation(id string) error
+	RewindConversation(id string, sequenceID int64, files, conversation bool) (RewindResponse, error)
 	GetConversation(id string) (StreamResponse, error)
 	NewConversation(message, model, cwd string) (string, error)
 	ListModels() ([]ModelInfo, error)
@@ -36,6 +38,10 @@ type (
 		conversationID string
 		err            error
 	}
+	rewoundMsg struct {
+		response RewindResponse
+		err      error
+	}
 )
 
 // ChatModel is the Bubble Tea model for the chat view.
@@ -179,6 +185,18 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case rewoundMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		// The rewound messages are gone; start over from the server's history.
+		m.messages = nil
+		m.messageIndex = make(map[string]int)
+		m.input.SetValue(msg.response.Message)
+		m.input.CursorEnd()
+		return m, m.fetchHistory
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -243,6 +261,9 @@ func (m *ChatModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				return newConvoCreatedMsg{conversationID: id, err: err}
 			}
 		}
+		if n, ok := parseRewindCommand(text); ok {
+			return *m, m.rewind(n)
+		}
 		client := m.client
 		id := m.conversationID
 		return *m, func() tea.Msg { return chatActionMsg{err: client.SendMessage(id, text)} }
@@ -326,6 +347,64 @@ func (m *ChatModel) updateViewport() {
 	m.viewport.GotoBottom()
 }
 
+// parseRewindCommand parses "/rewind [n]", returning n (default 1).
+func parseRewindCommand(text string) (int, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || fields[0] != "/rewind" || len(fields) > 2 {
+		return 0, false
+	}
+	if len(fields) == 1 {
+		return 1, true
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// rewind rewinds files and the conversation to before the nth-last prompt.
+func (m *ChatModel) rewind(n int) tea.Cmd {
+	var seq int64
+	for i := len(m.messages) - 1; i >= 0 && n > 0; i-- {
+		if isPrompt(m.messages[i]) {
+			seq = m.messages[i].SequenceID
+			n--
+		}
+	}
+	if n > 0 {
+		m.err = fmt.Errorf("not enough messages to rewind")
+		return nil
+	}
+	client := m.client
+	id := m.conversationID
+	return func() tea.Msg {
+		resp, err := client.RewindConversation(id, seq, true, true)
+		return rewoundMsg{response: resp, err: err}
+	}
+}
+
+// isPrompt reports whether msg is a message the user typed, as opposed to a
+// tool result.
+func isPrompt(msg APIMessage) bool {
+	if msg.Type != "user" {
+		return false
+	}
+	if msg.LlmData == nil {
+		return true
+	}
+	llmMsg, err := ParseLLMData(*msg.LlmData)
+	if err != nil {
+		return false
+	}
+	for _, c := range llmMsg.Content {
+		if c.Type == ContentTypeToolResult {
+			return false
+		}
+	}
+	return true
+}
+
 func (m ChatModel) fetchHistory() tea.Msg {
 	resp, err := m.client.GetConversation(m.conversationID)
 	return chatHistoryMsg{response: resp, err: err}
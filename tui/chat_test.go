@@ -18,6 +18,7 @@ type mockChatClient struct {
 	newConvoMsg    string
 	models         []ModelInfo
 	modelsErr      error
+	rewindSeq      int64
 }
 
 func (m *mockChatClient) SendMessage(id, message string) error {
@@ -30,6 +31,11 @@ func (m *mockChatClient) CancelConversation(id string) error {
 	return nil
 }
 
+func (m *mockChatClient) RewindConversation(id string, sequenceID int64, files, conversation bool) (RewindResponse, error) {
+	m.rewindSeq = sequenceID
+	return RewindResponse{Restored: []string{"/tmp/a.go"}, Message: "second prompt"}, nil
+}
+
 func (m *mockChatClient) GetConversation(id string) (StreamResponse, error) {
 	return StreamResponse{
 		Conversation: Conversation{ConversationID: id, Cwd: "/home/user/project"},
@@ -448,3 +454,68 @@ func TestChatModelCwdFromHistory(t *testing.T) {
 		t.Errorf("expected cwd from history, got %q", cm.cwd)
 	}
 }
+
+func TestChatModelRewind(t *testing.T) {
+	client := &mockChatClient{}
+	m := NewChatModel(client, "conv-1")
+	m.width = 80
+	m.height = 24
+	toolResult := `{"Role":0,"Content":[{"Type":6,"ToolUseID":"t1"}]}`
+	m.mergeMessages([]APIMessage{
+		{MessageID: "m1", SequenceID: 1, Type: "user"},
+		{MessageID: "m2", SequenceID: 2, Type: "agent"},
+		{MessageID: "m3", SequenceID: 3, Type: "user"},
+		{MessageID: "m4", SequenceID: 4, Type: "agent"},
+		{MessageID: "m5", SequenceID: 5, Type: "user", LlmData: &toolResult},
+	})
+
+	m.input.SetValue("/rewind")
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("expected a rewind command")
+	}
+	msg := cmd()
+	if client.rewindSeq != 3 {
+		t.Errorf("rewound to %d, want 3 (the last prompt, not the tool result)", client.rewindSeq)
+	}
+	if client.sentMessage != "" {
+		t.Errorf("/rewind was sent as a message: %q", client.sentMessage)
+	}
+
+	updated, _ = updated.Update(msg)
+	cm := updated.(ChatModel)
+	if len(cm.messages) != 0 {
+		t.Errorf("expected messages to be cleared for refetch, got %d", len(cm.messages))
+	}
+	if cm.input.Value() != "second prompt" {
+		t.Errorf("input = %q, want the rewound prompt", cm.input.Value())
+	}
+
+	m.input.SetValue("/rewind 2")
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	cmd()
+	if client.rewindSeq != 1 {
+		t.Errorf("/rewind 2 rewound to %d, want 1", client.rewindSeq)
+	}
+}
+
+func TestParseRewindCommand(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		ok   bool
+	}{
+		{"/rewind", 1, true},
+		{"/rewind 3", 3, true},
+		{"/rewind 0", 0, false},
+		{"/rewind x", 0, false},
+		{"/rewinder", 0, false},
+		{"please /rewind", 0, false},
+	}
+	for _, tt := range tests {
+		n, ok := parseRewindCommand(tt.text)
+		if n != tt.n || ok != tt.ok {
+			t.Errorf("parseRewindCommand(%q) = %d, %v; want %d, %v", tt.text, n, ok, tt.n, tt.ok)
+		}
+	}
+}
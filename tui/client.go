@@ -58,6 +58,15 @@ func (c *Client) CancelConversation(id string) error {
 	return c.postJSON("/api/conversation/"+id+"/cancel", nil, nil)
 }
 
+// RewindConversation restores the files changed since a message and/or
+// drops the message and everything after it from the conversation.
+func (c *Client) RewindConversation(id string, sequenceID int64, files, conversation bool) (RewindResponse, error) {
+	req := RewindRequest{SequenceID: sequenceID, Files: files, Conversation: conversation}
+	var resp RewindResponse
+	err := c.postJSON("/api/conversation/"+id+"/rewind", req, &resp)
+	return resp, err
+}
+
 // ArchiveConversation archives a conversation.
 func (c *Client) ArchiveConversation(id string) error {
 	return c.postJSON("/api/conversation/"+id+"/archive", nil, nil)
@@ -141,6 +141,30 @@ func TestCancelConversation(t *testing.T) {
 	}
 }
 
+func TestRewindConversation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/conversation/conv-1/rewind" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		var req RewindRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.SequenceID != 3 || !req.Files || !req.Conversation {
+			t.Errorf("got request %+v", req)
+		}
+		json.NewEncoder(w).Encode(RewindResponse{Restored: []string{"/a"}, Message: "hi"})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	resp, err := c.RewindConversation("conv-1", 3, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "hi" || len(resp.Restored) != 1 {
+		t.Errorf("got %+v", resp)
+	}
+}
+
 func TestArchiveConversation(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -65,7 +66,10 @@ func RenderMessage(msg APIMessage, width int) string {
 
 // RenderContent renders a single LLMContent block for the TUI.
 func RenderContent(c LLMContent, width int) string {
-	// Image detection (text content with media data)
+	// Image and document detection (text content with media data)
+	if c.MediaType == "application/pdf" && c.Data != "" {
+		return imagePlStyle.Render(fmt.Sprintf("[Document: %s]", cmp.Or(c.Filename, c.MediaType)))
+	}
 	if c.MediaType != "" && c.Data != "" {
 		return imagePlStyle.Render(fmt.Sprintf("[Image: %s]", c.MediaType))
 	}
@@ -108,6 +112,11 @@ func renderToolResult(c LLMContent) string {
 		}
 	}
 	output := strings.Join(texts, "\n")
+	if !c.ToolError {
+		if process, ok := renderProcessResult(c.Display); ok {
+			return process
+		}
+	}
 	if len(output) > 500 {
 		output = output[:497] + "..."
 	}
@@ -118,6 +127,52 @@ func renderToolResult(c LLMContent) string {
 	return toolStyle.Render(output)
 }
 
+// processDisplay mirrors claudetool.ProcessDisplayData.
+type processDisplay struct {
+	Action    string `json:"action"`
+	Processes []struct {
+		Name     string `json:"name"`
+		PID      int    `json:"pid"`
+		Status   string `json:"status"`
+		ExitCode *int   `json:"exit_code"`
+		Uptime   string `json:"uptime"`
+		Command  string `json:"command"`
+	} `json:"processes"`
+	Output string `json:"output"`
+}
+
+// processOutputLines is how many lines of process output the TUI shows.
+const processOutputLines = 20
+
+// renderProcessResult renders a process tool result as a status line per
+// process followed by the end of the output.
+func renderProcessResult(display json.RawMessage) (string, bool) {
+	var d processDisplay
+	if len(display) == 0 || json.Unmarshal(display, &d) != nil || d.Action == "" {
+		return "", false
+	}
+	var lines []string
+	for _, p := range d.Processes {
+		status := "running " + p.Uptime
+		if p.ExitCode != nil {
+			status = fmt.Sprintf("exited %d", *p.ExitCode)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (pid %d, %s): %s", toolBold.Render("●"), p.Name, p.PID, status, p.Command))
+	}
+	if len(d.Processes) == 0 {
+		lines = append(lines, "no processes")
+	}
+	if out := strings.TrimRight(d.Output, "\n"); out != "" {
+		outLines := strings.Split(out, "\n")
+		if n := len(outLines) - processOutputLines; n > 0 {
+			lines = append(lines, toolStyle.Render(fmt.Sprintf("... %d more lines", n)))
+			outLines = outLines[n:]
+		}
+		lines = append(lines, toolStyle.Render(strings.Join(outLines, "\n")))
+	}
+	return strings.Join(lines, "\n"), true
+}
+
 func renderMarkdown(text string, width int) string {
 	if width < 20 {
 		width = 80
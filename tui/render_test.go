@@ -2,6 +2,7 @@ package tui
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -68,6 +69,35 @@ func TestRenderToolResultError(t *testing.T) {
 	}
 }
 
+func TestRenderProcessResult(t *testing.T) {
+	var output strings.Builder
+	for i := range 30 {
+		fmt.Fprintf(&output, "line %d\n", i)
+	}
+	display, _ := json.Marshal(map[string]any{
+		"action": "logs",
+		"processes": []map[string]any{
+			{"name": "server", "pid": 42, "status": "running", "uptime": "3s", "command": "npm run dev"},
+		},
+		"output": output.String(),
+	})
+	content := LLMContent{
+		Type:       ContentTypeToolResult,
+		ToolUseID:  "tool-1",
+		ToolResult: []LLMContent{{Type: ContentTypeText, Text: "server (pid 42, running for 3s)"}},
+		Display:    display,
+	}
+	result := RenderContent(content, 80)
+	for _, want := range []string{"server (pid 42, running 3s): npm run dev", "10 more lines", "line 29"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in output, got %q", want, result)
+		}
+	}
+	if strings.Contains(result, "line 9\n") {
+		t.Errorf("expected early lines to be elided, got %q", result)
+	}
+}
+
 func TestRenderImageContent(t *testing.T) {
 	content := LLMContent{Type: ContentTypeText, MediaType: "image/png", Data: "base64data"}
 	result := RenderContent(content, 80)
@@ -76,6 +106,14 @@ func TestRenderImageContent(t *testing.T) {
 	}
 }
 
+func TestRenderDocumentContent(t *testing.T) {
+	content := LLMContent{Type: ContentTypeText, Text: "Document: report.pdf", MediaType: "application/pdf", Filename: "report.pdf", Data: "JVBERi0="}
+	result := RenderContent(content, 80)
+	if !strings.Contains(result, "[Document: report.pdf]") {
+		t.Errorf("expected document placeholder in output, got %q", result)
+	}
+}
+
 func TestRenderMessageUser(t *testing.T) {
 	llmData := `{"Role":0,"Content":[{"Type":2,"Text":"What is Go?"}]}`
 	msg := APIMessage{
@@ -101,8 +101,12 @@ type LLMContent struct {
 	ToolError  bool         `json:"ToolError,omitempty"`
 	ToolResult []LLMContent `json:"ToolResult,omitempty"`
 
+	// Display is tool-specific display data (llm.Content.Display).
+	Display json.RawMessage `json:"Display,omitempty"`
+
 	// Media
 	MediaType string `json:"MediaType,omitempty"`
+	Filename  string `json:"Filename,omitempty"`
 	Data      string `json:"Data,omitempty"`
 }
 
@@ -114,6 +118,19 @@ type ChatRequest struct {
 	Cwd     string `json:"cwd,omitempty"`
 }
 
+// RewindRequest mirrors server.RewindRequest.
+type RewindRequest struct {
+	SequenceID   int64 `json:"sequence_id"`
+	Files        bool  `json:"files"`
+	Conversation bool  `json:"conversation"`
+}
+
+// RewindResponse mirrors server.RewindResponse.
+type RewindResponse struct {
+	Restored []string `json:"restored"`
+	Message  string   `json:"message,omitempty"`
+}
+
 // ModelInfo mirrors the response from GET /api/models.
 type ModelInfo struct {
 	ID               string `json:"id"`
@@ -51,6 +51,7 @@ import ChangeDirTool from "./ChangeDirTool";
 import BrowserResizeTool from "./BrowserResizeTool";
 import SubagentTool from "./SubagentTool";
 import OutputIframeTool from "./OutputIframeTool";
+import ProcessTool from "./ProcessTool";
 import DirectoryPickerModal from "./DirectoryPickerModal";
 import { useVersionChecker } from "./VersionChecker";
 import TerminalPanel, { EphemeralTerminal } from "./TerminalPanel";
@@ -61,6 +62,8 @@ import FileTreePanel from "./FileTreePanel";
 interface ContextUsageBarProps {
   contextWindowSize: number;
   maxContextTokens: number;
+  /** Size of the next request including the draft message, if counted */
+  draftTokens?: { input_tokens: number; exact: boolean } | null;
   conversationId?: string | null;
   onContinueConversation?: () => void;
   onDistillConversation?: () => void;
@@ -69,6 +72,7 @@ interface ContextUsageBarProps {
 function ContextUsageBar({
   contextWindowSize,
   maxContextTokens,
+  draftTokens,
   conversationId,
   onContinueConversation,
   onDistillConversation,
@@ -183,6 +187,12 @@ function ContextUsageBar({
         >
           {formatTokens(contextWindowSize)} / {formatTokens(maxContextTokens)} (
           {percentage.toFixed(1)}%) tokens used
+          {draftTokens && (
+            <div style={{ marginTop: "4px" }}>
+              With draft: {draftTokens.exact ? "" : "~"}
+              {formatTokens(draftTokens.input_tokens)} / {formatTokens(maxContextTokens)} tokens
+            </div>
+          )}
           {showLongConversationWarning && (
             <div style={{ marginTop: "6px", color: "var(--warning-text, #f59e0b)" }}>
               This conversation is getting long.
@@ -242,7 +252,7 @@ function ContextUsageBar({
         <div
           className="context-usage-bar"
           onClick={handleClick}
-          title={`Context: ${formatTokens(contextWindowSize)} / ${formatTokens(maxContextTokens)} tokens (${percentage.toFixed(1)}%)`}
+          title={`Context: ${formatTokens(contextWindowSize)} / ${formatTokens(maxContextTokens)} tokens (${percentage.toFixed(1)}%)${draftTokens ? `, ${draftTokens.exact ? "" : "~"}${formatTokens(draftTokens.input_tokens)} with draft` : ""}`}
         >
           <div
             className="context-usage-fill"
@@ -289,6 +299,7 @@ const TOOL_COMPONENTS: Record<string, React.ComponentType<any>> = {
   browser_resize: BrowserResizeTool,
   subagent: SubagentTool,
   output_iframe: OutputIframeTool,
+  process: ProcessTool,
 };
 
 function CoalescedToolCall({
@@ -504,7 +515,12 @@ interface ChatInterfaceProps {
   onConversationUpdate?: (conversation: Conversation) => void;
   onConversationListUpdate?: (update: ConversationListUpdate) => void;
   onConversationStateUpdate?: (state: ConversationStateUpdate) => void;
-  onFirstMessage?: (message: string, model: string, cwd?: string) => Promise<void>;
+  onFirstMessage?: (
+    message: string,
+    model: string,
+    cwd?: string,
+    sandbox?: string,
+  ) => Promise<void>;
   onContinueConversation?: (
     sourceConversationId: string,
     model: string,
@@ -632,6 +648,10 @@ function ChatInterface({
     }
     await switchConversationModel(pendingSwitchModel, true);
   };
+  // Sandbox mode for new conversations; undefined when the server can't sandbox
+  const [selectedSandbox, setSelectedSandbox] = useState<string | undefined>(
+    window.__PERCY_INIT__?.default_sandbox,
+  );
   const [selectedCwd, setSelectedCwdState] = useState<string>("");
   const [cwdInitialized, setCwdInitialized] = useState(false);
   // Wrapper to persist cwd selection to localStorage
@@ -722,6 +742,10 @@ function ChatInterface({
   const [agentWorking, setAgentWorking] = useState(false);
   const [cancelling, setCancelling] = useState(false);
   const [contextWindowSize, setContextWindowSize] = useState(0);
+  const [draftMessage, setDraftMessage] = useState("");
+  const [draftTokens, setDraftTokens] = useState<{ input_tokens: number; exact: boolean } | null>(
+    null,
+  );
   const terminalURL = window.__PERCY_INIT__?.terminal_url || null;
   const links = window.__PERCY_INIT__?.links || [];
   const hostname = window.__PERCY_INIT__?.hostname || "localhost";
@@ -735,6 +759,11 @@ function ChatInterface({
 
   const [showFileTree, setShowFileTree] = useState(false);
   const [editingMessage, setEditingMessage] = useState<{sequenceId: number; text: string} | null>(null);
+  const [rewindTarget, setRewindTarget] = useState<{
+    sequenceId: number;
+    isUserMessage: boolean;
+    files: string[];
+  } | null>(null);
   const messagesEndRef = useRef<HTMLDivElement>(null);
   const messagesContainerRef = useRef<HTMLDivElement>(null);
   const eventSourceRef = useRef<EventSource | null>(null);
@@ -781,6 +810,30 @@ function ChatInterface({
     };
   }, [conversationId]);
 
+  // Count the context size of the next request, including the draft message,
+  // once the user pauses typing.
+  useEffect(() => {
+    if (!conversationId || !draftMessage.trim()) {
+      setDraftTokens(null);
+      return;
+    }
+    let cancelled = false;
+    const timer = window.setTimeout(() => {
+      api
+        .countTokens(conversationId, draftMessage, selectedModel)
+        .then((count) => {
+          if (!cancelled) setDraftTokens(count);
+        })
+        .catch(() => {
+          if (!cancelled) setDraftTokens(null);
+        });
+    }, 800);
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [conversationId, draftMessage, selectedModel]);
+
   // Show working indicator on favicon (UI concern, not a notification)
   useEffect(() => {
     if (agentWorking) {
@@ -1145,7 +1198,12 @@ function ChatInterface({
             throw new Error(`Invalid working directory: ${validation.error}`);
           }
         }
-        await onFirstMessage(message.trim(), selectedModel, selectedCwd || undefined);
+        await onFirstMessage(
+          message.trim(),
+          selectedModel,
+          selectedCwd || undefined,
+          selectedSandbox,
+        );
       } else if (conversationId) {
         await api.sendMessage(conversationId, {
           message: message.trim(),
@@ -1294,6 +1352,42 @@ function ChatInterface({
     }
   };
 
+  // Handler to open the rewind dialog, listing the files that would be restored
+  const handleRewindRequest = async (sequenceId: number, isUserMessage: boolean) => {
+    if (!conversationId) return;
+    let files: string[] = [];
+    try {
+      const checkpoints = await api.getCheckpoints(conversationId);
+      files = [
+        ...new Set(checkpoints.filter((c) => c.sequence_id >= sequenceId).map((c) => c.path)),
+      ];
+    } catch (err) {
+      console.error("Failed to load checkpoints:", err);
+    }
+    setRewindTarget({ sequenceId, isUserMessage, files });
+  };
+
+  // Handler to rewind files and/or the conversation to before a message
+  const handleRewind = async (files: boolean, conversation: boolean) => {
+    if (!conversationId || !rewindTarget) return;
+    const { sequenceId } = rewindTarget;
+    setRewindTarget(null);
+    try {
+      const result = await api.rewindConversation(conversationId, sequenceId, {
+        files,
+        conversation,
+      });
+      if (conversation) {
+        setEditingMessage(null);
+        if (result.message) setTerminalInjectedText(result.message);
+        loadMessages();
+      }
+    } catch (err) {
+      console.error("Rewind failed:", err);
+      setError(err instanceof Error ? err.message : "Rewind failed");
+    }
+  };
+
   const getDisplayTitle = () => {
     return currentConversation?.slug || "Percy";
   };
@@ -1547,6 +1641,7 @@ function ChatInterface({
             onFork={onForkConversation ? handleForkConversation : undefined}
             onEdit={(sequenceId, text) => setEditingMessage({sequenceId, text})}
             onRegenerate={handleRegenerate}
+            onRewind={handleRewindRequest}
           />
         );
       } else if (item.type === "tool") {
@@ -2108,6 +2203,49 @@ function ChatInterface({
         </Modal>
       )}
 
+      {/* Rewind */}
+      {rewindTarget && (
+        <Modal isOpen onClose={() => setRewindTarget(null)} title="Rewind">
+          <p>
+            {rewindTarget.files.length === 0
+              ? "No files were changed from this message on."
+              : `${rewindTarget.files.length} file${rewindTarget.files.length === 1 ? "" : "s"} changed from this message on will be restored:`}
+          </p>
+          {rewindTarget.files.length > 0 && (
+            <ul style={{ maxHeight: "200px", overflowY: "auto", fontFamily: "monospace" }}>
+              {rewindTarget.files.map((path) => (
+                <li key={path}>{path}</li>
+              ))}
+            </ul>
+          )}
+          {rewindTarget.isUserMessage && (
+            <p>Rewinding the conversation removes this message and everything after it.</p>
+          )}
+          <div className="form-actions">
+            <button className="btn btn-secondary" onClick={() => setRewindTarget(null)}>
+              Cancel
+            </button>
+            {rewindTarget.isUserMessage && (
+              <button className="btn btn-secondary" onClick={() => handleRewind(false, true)}>
+                Conversation only
+              </button>
+            )}
+            <button
+              className="btn btn-secondary"
+              onClick={() => handleRewind(true, false)}
+              disabled={rewindTarget.files.length === 0}
+            >
+              Files only
+            </button>
+            {rewindTarget.isUserMessage && (
+              <button className="btn btn-primary" onClick={() => handleRewind(true, true)}>
+                Files and conversation
+              </button>
+            )}
+          </div>
+        </Modal>
+      )}
+
       {/* Color Theme Picker */}
       {showThemePicker && (
         <ThemePickerModal
@@ -2205,6 +2343,7 @@ function ChatInterface({
               </div>
               <ContextUsageBar
                 contextWindowSize={contextWindowSize}
+                draftTokens={draftTokens}
                 maxContextTokens={
                   models.find((m) => m.id === selectedModel)?.max_context_tokens || 200000
                 }
@@ -2250,6 +2389,26 @@ function ChatInterface({
                   {selectedCwd || "(no cwd)"}
                 </button>
               </div>
+
+              {/* Sandbox mode, when the server supports it */}
+              {selectedSandbox && (
+                <div
+                  className="status-field status-field-sandbox"
+                  title="Sandboxed commands can only write the workspace; isolated also blocks the network"
+                >
+                  <span className="status-field-label">Sandbox:</span>
+                  <select
+                    className="status-chip"
+                    value={selectedSandbox}
+                    onChange={(e) => setSelectedSandbox(e.target.value)}
+                    disabled={sending}
+                  >
+                    <option value="off">off</option>
+                    <option value="isolated">isolated</option>
+                    <option value="network">network</option>
+                  </select>
+                </div>
+              )}
             </div>
           ) : (
             // Active conversation - show model selector + ready + context bar
@@ -2270,6 +2429,7 @@ function ChatInterface({
               <span className="status-message status-ready">Ready on {hostname}</span>
               <ContextUsageBar
                 contextWindowSize={contextWindowSize}
+                draftTokens={draftTokens}
                 maxContextTokens={
                   models.find((m) => m.id === selectedModel)?.max_context_tokens || 200000
                 }
@@ -2298,6 +2458,7 @@ function ChatInterface({
           setTerminalInjectedText(null);
         }}
         persistKey={conversationId || "new-conversation"}
+        onDraftChange={setDraftMessage}
         editingMessage={editingMessage}
         onCancelEdit={() => setEditingMessage(null)}
       />
@@ -26,6 +26,9 @@ interface UsageSummary {
     total_input_tokens: number;
     total_output_tokens: number;
     total_cost_usd: number;
+    total_cache_read_tokens: number;
+    total_cache_write_tokens: number;
+    cache_hit_rate: number;
   }>;
   total_cost_usd: number;
 }
@@ -161,6 +164,11 @@ function CostDashboard({ isOpen, onClose }: CostDashboardProps) {
                           <td>{formatDate(row.date)}</td>
                           <td className="text-secondary">{row.model || "—"}</td>
                           <td style={{ textAlign: "right" }}>{row.message_count}</td>
+                          <td style={{ textAlign: "right" }}>
+                            {row.total_cache_read_tokens + row.total_cache_write_tokens > 0
+                              ? `${Math.round(row.cache_hit_rate * 100)}%`
+                              : "—"}
+                          </td>
                           <td style={{ textAlign: "right" }}>{formatTokens(row.total_input_tokens)}</td>
                           <td style={{ textAlign: "right" }}>{formatTokens(row.total_output_tokens)}</td>
                           <td style={{ textAlign: "right" }}>{formatCost(row.total_cost_usd)}</td>
@@ -183,6 +191,7 @@ function CostDashboard({ isOpen, onClose }: CostDashboardProps) {
                         <th>Conversation</th>
                         <th>Model</th>
                         <th style={{ textAlign: "right" }}>Messages</th>
+                        <th style={{ textAlign: "right" }}>Cache Hits</th>
                         <th style={{ textAlign: "right" }}>Cost</th>
                       </tr>
                     </thead>
@@ -202,6 +211,11 @@ function CostDashboard({ isOpen, onClose }: CostDashboardProps) {
                           </td>
                           <td className="text-secondary">{row.model || "—"}</td>
                           <td style={{ textAlign: "right" }}>{row.message_count}</td>
+                          <td style={{ textAlign: "right" }}>
+                            {row.total_cache_read_tokens + row.total_cache_write_tokens > 0
+                              ? `${Math.round(row.cache_hit_rate * 100)}%`
+                              : "—"}
+                          </td>
                           <td style={{ textAlign: "right" }}>{formatCost(row.total_cost_usd)}</td>
                         </tr>
                       ))}
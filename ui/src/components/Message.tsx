@@ -21,6 +21,7 @@ import ChangeDirTool from "./ChangeDirTool";
 import BrowserResizeTool from "./BrowserResizeTool";
 import SubagentTool from "./SubagentTool";
 import OutputIframeTool from "./OutputIframeTool";
+import ProcessTool from "./ProcessTool";
 import ThinkingContent from "./ThinkingContent";
 import UsageDetailModal from "./UsageDetailModal";
 import MessageActionBar from "./MessageActionBar";
@@ -78,6 +79,7 @@ interface MessageProps {
   onFork?: (sequenceId: number) => void;
   onEdit?: (sequenceId: number, currentText: string) => void;
   onRegenerate?: () => void;
+  onRewind?: (sequenceId: number, isUserMessage: boolean) => void;
 }
 
 // Copy icon for the commit hash copy button
@@ -316,7 +318,15 @@ function DistillStatusMessage({ message }: { message: MessageType }) {
   );
 }
 
-function Message({ message, onOpenDiffViewer, onCommentTextChange, onFork, onEdit, onRegenerate }: MessageProps) {
+function Message({
+  message,
+  onOpenDiffViewer,
+  onCommentTextChange,
+  onFork,
+  onEdit,
+  onRegenerate,
+  onRewind,
+}: MessageProps) {
   // Render system messages with distill_status as status indicators
   if (message.type === "system") {
     if (isDistillStatusMessage(message)) {
@@ -613,6 +623,10 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange, onFork, onEdi
         if (content.ToolName === "output_iframe") {
           return <OutputIframeTool toolInput={content.ToolInput} isRunning={true} />;
         }
+        // Use specialized component for process tool
+        if (content.ToolName === "process") {
+          return <ProcessTool toolInput={content.ToolInput} isRunning={true} />;
+        }
         // Use specialized component for browser console logs tools
         if (
           content.ToolName === "browser_recent_console_logs" ||
@@ -826,6 +840,20 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange, onFork, onEdi
           );
         }
 
+        // Use specialized component for process tool
+        if (toolName === "process") {
+          return (
+            <ProcessTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+              display={content.Display}
+            />
+          );
+        }
+
         // Use specialized component for browser console logs tools
         if (
           toolName === "browser_recent_console_logs" ||
@@ -1032,13 +1060,14 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange, onFork, onEdi
           role="alert"
           aria-label="Error message"
         >
-          {actionBarVisible && (hasCopyAction || hasUsageAction || onFork || (isUser && onEdit) || (isAgent && onRegenerate)) && (
+          {actionBarVisible && (hasCopyAction || hasUsageAction || onFork || onRewind || (isUser && onEdit) || (isAgent && onRegenerate)) && (
             <MessageActionBar
               onCopy={hasCopyAction ? handleCopy : undefined}
               onFork={onFork ? () => onFork(message.sequence_id) : undefined}
               onShowUsage={hasUsageAction ? handleShowUsage : undefined}
               onEdit={isUser && onEdit && userMessageText ? () => onEdit(message.sequence_id, userMessageText) : undefined}
               onRegenerate={isAgent && onRegenerate ? onRegenerate : undefined}
+              onRewind={onRewind ? () => onRewind(message.sequence_id, isUser) : undefined}
             />
           )}
           <div className="message-content" data-testid="message-content">
@@ -1070,13 +1099,14 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange, onFork, onEdi
           data-testid="message"
           role="article"
         >
-          {actionBarVisible && (hasCopyAction || hasUsageAction || onFork || (isUser && onEdit) || (isAgent && onRegenerate)) && (
+          {actionBarVisible && (hasCopyAction || hasUsageAction || onFork || onRewind || (isUser && onEdit) || (isAgent && onRegenerate)) && (
             <MessageActionBar
               onCopy={hasCopyAction ? handleCopy : undefined}
               onFork={onFork ? () => onFork(message.sequence_id) : undefined}
               onShowUsage={hasUsageAction ? handleShowUsage : undefined}
               onEdit={isUser && onEdit && userMessageText ? () => onEdit(message.sequence_id, userMessageText) : undefined}
               onRegenerate={isAgent && onRegenerate ? onRegenerate : undefined}
+              onRewind={onRewind ? () => onRewind(message.sequence_id, isUser) : undefined}
             />
           )}
           <div className="message-content" data-testid="message-content">
@@ -1146,13 +1176,14 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange, onFork, onEdi
         data-testid="message"
         role="article"
       >
-        {actionBarVisible && (hasCopyAction || hasUsageAction || onFork || (isUser && onEdit) || (isAgent && onRegenerate)) && (
+        {actionBarVisible && (hasCopyAction || hasUsageAction || onFork || onRewind || (isUser && onEdit) || (isAgent && onRegenerate)) && (
           <MessageActionBar
             onCopy={hasCopyAction ? handleCopy : undefined}
             onFork={onFork ? () => onFork(message.sequence_id) : undefined}
             onShowUsage={hasUsageAction ? handleShowUsage : undefined}
             onEdit={isUser && onEdit && userMessageText ? () => onEdit(message.sequence_id, userMessageText) : undefined}
             onRegenerate={isAgent && onRegenerate ? onRegenerate : undefined}
+            onRewind={onRewind ? () => onRewind(message.sequence_id, isUser) : undefined}
           />
         )}
         {/* Message content */}
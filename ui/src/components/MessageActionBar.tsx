@@ -6,9 +6,17 @@ interface MessageActionBarProps {
   onShowUsage?: () => void;
   onEdit?: () => void;
   onRegenerate?: () => void;
+  onRewind?: () => void;
 }
 
-function MessageActionBar({ onCopy, onFork, onShowUsage, onEdit, onRegenerate }: MessageActionBarProps) {
+function MessageActionBar({
+  onCopy,
+  onFork,
+  onShowUsage,
+  onEdit,
+  onRegenerate,
+  onRewind,
+}: MessageActionBarProps) {
   const [copyFeedback, setCopyFeedback] = useState(false);
 
   const handleCopy = (e: React.MouseEvent) => {
@@ -129,6 +137,46 @@ function MessageActionBar({ onCopy, onFork, onShowUsage, onEdit, onRegenerate }:
           </svg>
         </button>
       )}
+      {onRewind && (
+        <button
+          onClick={(e) => { e.stopPropagation(); onRewind(); }}
+          title="Rewind to here"
+          style={{
+            display: "flex",
+            alignItems: "center",
+            justifyContent: "center",
+            width: "24px",
+            height: "24px",
+            borderRadius: "4px",
+            border: "none",
+            background: "transparent",
+            cursor: "pointer",
+            color: "var(--text-secondary)",
+            transition: "background-color 0.15s",
+          }}
+          onMouseEnter={(e) => {
+            e.currentTarget.style.backgroundColor = "var(--bg-tertiary)";
+          }}
+          onMouseLeave={(e) => {
+            e.currentTarget.style.backgroundColor = "transparent";
+          }}
+        >
+          <svg
+            width="16"
+            height="16"
+            viewBox="0 0 24 24"
+            fill="none"
+            stroke="currentColor"
+            strokeWidth="2"
+            strokeLinecap="round"
+            strokeLinejoin="round"
+          >
+            <polyline points="1 4 1 10 7 10"></polyline>
+            <path d="M3.51 15a9 9 0 1 0 2.13-9.36L1 10"></path>
+            <polyline points="12 7 12 12 15 14"></polyline>
+          </svg>
+        </button>
+      )}
       {onCopy && (
         <button
           onClick={handleCopy}
@@ -54,6 +54,8 @@ interface MessageInputProps {
   persistKey?: string;
   editingMessage?: {sequenceId: number; text: string} | null;
   onCancelEdit?: () => void;
+  /** Called with the draft message whenever it changes */
+  onDraftChange?: (message: string) => void;
 }
 
 const PERSIST_KEY_PREFIX = "percy_draft_";
@@ -68,6 +70,7 @@ function MessageInput({
   persistKey,
   editingMessage,
   onCancelEdit,
+  onDraftChange,
 }: MessageInputProps) {
   const [message, setMessage] = useState(() => {
     // Load persisted draft if persistKey is set
@@ -373,6 +376,10 @@ function MessageInput({
     adjustTextareaHeight();
   }, [message]);
 
+  useEffect(() => {
+    onDraftChange?.(message);
+  }, [message, onDraftChange]);
+
   // Persist draft to localStorage when persistKey is set
   useEffect(() => {
     if (persistKey) {
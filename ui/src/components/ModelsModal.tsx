@@ -14,7 +14,7 @@ interface ModelsModalProps {
   onModelsChanged?: () => void;
 }
 
-type ProviderType = "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama";
+type ProviderType = "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama" | "bedrock" | "vertex";
 
 const DEFAULT_ENDPOINTS: Record<ProviderType, string> = {
   anthropic: "https://api.anthropic.com/v1/messages",
@@ -22,6 +22,8 @@ const DEFAULT_ENDPOINTS: Record<ProviderType, string> = {
   "openai-responses": "https://api.openai.com/v1",
   gemini: "https://generativelanguage.googleapis.com/v1beta",
   ollama: "http://localhost:11434/v1",
+  bedrock: "us-east-1",
+  vertex: "https://aiplatform.googleapis.com",
 };
 
 const PROVIDER_LABELS: Record<ProviderType, string> = {
@@ -30,6 +32,8 @@ const PROVIDER_LABELS: Record<ProviderType, string> = {
   "openai-responses": "OpenAI (Responses API)",
   gemini: "Google Gemini",
   ollama: "Ollama (Local)",
+  bedrock: "Amazon Bedrock",
+  vertex: "Google Vertex AI",
 };
 
 const DEFAULT_MODELS: Record<ProviderType, { name: string; model_name: string }[]> = {
@@ -45,6 +49,14 @@ const DEFAULT_MODELS: Record<ProviderType, { name: string; model_name: string }[
     { name: "Gemini 3 Flash", model_name: "gemini-3-flash-preview" },
   ],
   ollama: [],
+  bedrock: [
+    { name: "Claude Sonnet 4.5 (Bedrock)", model_name: "us.anthropic.claude-sonnet-4-5-20250929-v1:0" },
+    { name: "Claude Opus 4.6 (Bedrock)", model_name: "us.anthropic.claude-opus-4-6-v1" },
+  ],
+  vertex: [
+    { name: "Claude Sonnet 4.5 (Vertex)", model_name: "claude-sonnet-4-5@20250929" },
+    { name: "Gemini 2.5 Pro (Vertex)", model_name: "gemini-2.5-pro" },
+  ],
 };
 
 // Built-in model info from init data
@@ -64,7 +76,17 @@ const THINKING_LEVELS: { value: ThinkingLevel; label: string }[] = [
 ];
 
 // Providers that support thinking/reasoning configuration
-const THINKING_PROVIDERS: Set<ProviderType> = new Set(["anthropic", "openai-responses"]);
+const THINKING_PROVIDERS: Set<ProviderType> = new Set(["anthropic", "openai-responses", "bedrock", "vertex"]);
+
+// Providers that work without an API key: Ollama runs locally, and the cloud
+// providers fall back to AWS / Google credentials from the server environment.
+const KEY_OPTIONAL_PROVIDERS: Set<ProviderType> = new Set(["ollama", "bedrock", "vertex"]);
+
+const API_KEY_PLACEHOLDERS: Partial<Record<ProviderType, string>> = {
+  ollama: "Not required for local Ollama",
+  bedrock: "Bedrock API key or ACCESS_KEY_ID:SECRET_ACCESS_KEY (empty: AWS environment)",
+  vertex: "Service account JSON or path (empty: application default credentials)",
+};
 
 interface FormData {
   display_name: string;
@@ -160,12 +182,12 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
   };
 
   const handleTest = async () => {
-    // Need model_name always, and either api_key or editing an existing model (Ollama doesn't need a key)
+    // Need model_name always, and either api_key or editing an existing model (some providers don't need a key)
     if (!form.model_name) {
       setTestResult({ success: false, message: "Model name is required" });
       return;
     }
-    if (!form.api_key && !editingModelId && form.provider_type !== "ollama") {
+    if (!form.api_key && !editingModelId && !KEY_OPTIONAL_PROVIDERS.has(form.provider_type)) {
       setTestResult({ success: false, message: "API key is required" });
       return;
     }
@@ -194,7 +216,7 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
   };
 
   const handleSave = async () => {
-    const needsApiKey = form.provider_type !== "ollama";
+    const needsApiKey = !KEY_OPTIONAL_PROVIDERS.has(form.provider_type);
     if (!form.display_name || (needsApiKey && !form.api_key) || !form.model_name) {
       setError(needsApiKey ? "Display name, API key, and model name are required" : "Display name and model name are required");
       return;
@@ -321,7 +343,7 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
             <div className="form-group">
               <label>Provider / API Format</label>
               <div className="provider-buttons">
-                {(["anthropic", "openai", "openai-responses", "gemini", "ollama"] as ProviderType[]).map(
+                {(["anthropic", "openai", "openai-responses", "gemini", "ollama", "bedrock", "vertex"] as ProviderType[]).map(
                   (p) => (
                     <button
                       key={p}
@@ -406,12 +428,12 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
 
             {/* API Key */}
             <div className="form-group">
-              <label>API Key{form.provider_type === "ollama" ? " (optional)" : ""}</label>
+              <label>API Key{KEY_OPTIONAL_PROVIDERS.has(form.provider_type) ? " (optional)" : ""}</label>
               <input
                 type="text"
                 value={form.api_key}
                 onChange={(e) => setForm((prev) => ({ ...prev, api_key: e.target.value }))}
-                placeholder={form.provider_type === "ollama" ? "Not required for local Ollama" : "Enter API key"}
+                placeholder={API_KEY_PLACEHOLDERS[form.provider_type] ?? "Enter API key"}
                 className="form-input"
                 autoComplete="off"
               />
@@ -511,11 +533,15 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
                 type="button"
                 className="btn-secondary"
                 onClick={handleTest}
-                disabled={testing || (!form.api_key && !editingModelId && form.provider_type !== "ollama") || !form.model_name}
+                disabled={
+                  testing ||
+                  (!form.api_key && !editingModelId && !KEY_OPTIONAL_PROVIDERS.has(form.provider_type)) ||
+                  !form.model_name
+                }
                 title={
                   !form.model_name
                     ? "Enter model name to test"
-                    : !form.api_key && !editingModelId && form.provider_type !== "ollama"
+                    : !form.api_key && !editingModelId && !KEY_OPTIONAL_PROVIDERS.has(form.provider_type)
                       ? "Enter API key to test"
                       : ""
                 }
@@ -526,7 +552,11 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
                 type="button"
                 className="btn-primary"
                 onClick={handleSave}
-                disabled={!form.display_name || (form.provider_type !== "ollama" && !form.api_key) || !form.model_name}
+                disabled={
+                  !form.display_name ||
+                  (!KEY_OPTIONAL_PROVIDERS.has(form.provider_type) && !form.api_key) ||
+                  !form.model_name
+                }
               >
                 {editingModelId ? "Save" : "Add Model"}
               </button>
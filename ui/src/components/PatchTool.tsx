@@ -37,6 +37,21 @@ interface PatchDisplayData {
   diff: string;
 }
 
+// Display data from the patch tool for a unified diff that changed several files
+interface PatchDiffDisplayData {
+  files: PatchDisplayData[];
+}
+
+function isPatchDisplayData(display: unknown): display is PatchDisplayData {
+  return (
+    !!display &&
+    typeof display === "object" &&
+    "path" in display &&
+    "oldContent" in display &&
+    "newContent" in display
+  );
+}
+
 interface PatchToolProps {
   // For tool_use (pending state)
   toolInput?: unknown;
@@ -271,15 +286,15 @@ function PatchTool({ toolInput, isRunning, toolResult, hasError, display }: Patc
         ? toolInput
         : "";
 
-  // Parse display data (structured format from backend)
-  const displayData: PatchDisplayData | null =
-    display &&
-    typeof display === "object" &&
-    "path" in display &&
-    "oldContent" in display &&
-    "newContent" in display
-      ? (display as PatchDisplayData)
-      : null;
+  // Parse display data (structured format from backend): one file, or several for a diff
+  const displayFiles: PatchDisplayData[] = isPatchDisplayData(display)
+    ? [display]
+    : display &&
+        typeof display === "object" &&
+        "files" in display &&
+        Array.isArray((display as PatchDiffDisplayData).files)
+      ? (display as PatchDiffDisplayData).files.filter(isPatchDisplayData)
+      : [];
 
   // Extract error message from toolResult if present
   const errorMessage =
@@ -288,10 +303,14 @@ function PatchTool({ toolInput, isRunning, toolResult, hasError, display }: Patc
   const isComplete = !isRunning && toolResult !== undefined;
 
   // Extract filename from path or diff headers
-  const filename = displayData?.path || path || "patch";
+  const filename =
+    displayFiles.length > 1
+      ? `${displayFiles.length} files`
+      : displayFiles[0]?.path || path || "patch";
 
   // Show toggle only on desktop when expanded and complete with diff data
-  const showDiffToggle = !isMobile && isExpanded && isComplete && !hasError && displayData;
+  const showDiffToggle =
+    !isMobile && isExpanded && isComplete && !hasError && displayFiles.length > 0;
 
   return (
     <div
@@ -337,11 +356,16 @@ function PatchTool({ toolInput, isRunning, toolResult, hasError, display }: Patc
 
       {isExpanded && (
         <div className="patch-tool-details">
-          {isComplete && !hasError && displayData && (
-            <div className="patch-tool-section">
-              <DiffView displayData={displayData} sideBySide={sideBySide} />
-            </div>
-          )}
+          {isComplete &&
+            !hasError &&
+            displayFiles.map((displayData) => (
+              <div className="patch-tool-section" key={displayData.path}>
+                {displayFiles.length > 1 && (
+                  <div className="patch-tool-label">{displayData.path}</div>
+                )}
+                <DiffView displayData={displayData} sideBySide={sideBySide} />
+              </div>
+            ))}
 
           {isComplete && hasError && (
             <div className="patch-tool-section">
@@ -0,0 +1,150 @@
+import React, { useState } from "react";
+import { LLMContent } from "../types";
+
+interface ProcessInfo {
+  name: string;
+  command: string;
+  dir: string;
+  pid: number;
+  status: string;
+  exit_code?: number;
+  uptime: string;
+  log_file: string;
+}
+
+// ProcessDisplayData from the Go tool
+interface ProcessDisplay {
+  action: string;
+  processes?: ProcessInfo[];
+  output?: string;
+}
+
+interface ProcessToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown; // { action: string, name?: string, command?: string, ... }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown;
+}
+
+function summarize(input: Record<string, unknown>): string {
+  const action = typeof input.action === "string" ? input.action : "";
+  const name = typeof input.name === "string" ? input.name : "";
+  switch (action) {
+    case "start":
+      return `start ${name}: ${typeof input.command === "string" ? input.command : ""}`;
+    case "list":
+      return "list";
+    case "wait":
+      return `wait ${name} for ${input.port ? `port ${input.port}` : `/${input.log ?? ""}/`}`;
+    case "send":
+      return `send ${name}: ${typeof input.input === "string" ? input.input : ""}`;
+    default:
+      return `${action} ${name}`;
+  }
+}
+
+function statusText(p: ProcessInfo): string {
+  if (p.status === "running") {
+    return `running ${p.uptime}`;
+  }
+  return `exited ${p.exit_code ?? "?"}`;
+}
+
+function ProcessTool({
+  toolInput,
+  isRunning,
+  toolResult,
+  hasError,
+  executionTime,
+  display,
+}: ProcessToolProps) {
+  const [isExpanded, setIsExpanded] = useState(false);
+
+  const input =
+    typeof toolInput === "object" && toolInput !== null
+      ? (toolInput as Record<string, unknown>)
+      : {};
+  const data =
+    typeof display === "object" && display !== null ? (display as ProcessDisplay) : undefined;
+  const processes = data?.processes ?? [];
+
+  const resultText =
+    toolResult
+      ?.map((r) => r.Text)
+      .filter(Boolean)
+      .join("") || "";
+
+  const isComplete = !isRunning && toolResult !== undefined;
+
+  return (
+    <div className="tool" data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}>
+      <div className="tool-header" onClick={() => setIsExpanded(!isExpanded)}>
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isRunning ? "running" : ""}`}>⚙️</span>
+          <span className="tool-command">{summarize(input)}</span>
+          {processes.length === 1 && <span className="tool-time">{statusText(processes[0])}</span>}
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+          {isComplete && !hasError && <span className="tool-success">✓</span>}
+        </div>
+        <button
+          className="tool-toggle"
+          aria-label={isExpanded ? "Collapse" : "Expand"}
+          aria-expanded={isExpanded}
+        >
+          <svg
+            width="12"
+            height="12"
+            viewBox="0 0 12 12"
+            fill="none"
+            xmlns="http://www.w3.org/2000/svg"
+            style={{
+              transform: isExpanded ? "rotate(90deg)" : "rotate(0deg)",
+              transition: "transform 0.2s",
+            }}
+          >
+            <path
+              d="M4.5 3L7.5 6L4.5 9"
+              stroke="currentColor"
+              strokeWidth="1.5"
+              strokeLinecap="round"
+              strokeLinejoin="round"
+            />
+          </svg>
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="tool-details">
+          {processes.length > 0 && (
+            <div className="tool-section">
+              <div className="tool-label">
+                Processes:
+                {executionTime && <span className="tool-time">{executionTime}</span>}
+              </div>
+              <div className="tool-code">
+                {processes
+                  .map((p) => `${p.name} (pid ${p.pid}, ${statusText(p)}): ${p.command}`)
+                  .join("\n")}
+              </div>
+            </div>
+          )}
+          {isComplete && (
+            <div className="tool-section">
+              <div className="tool-label">{data?.output ? "Output:" : "Result:"}</div>
+              <div className={`tool-code ${hasError ? "error" : ""}`}>
+                {(hasError ? resultText : data?.output || resultText) || "(no output)"}
+              </div>
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default ProcessTool;
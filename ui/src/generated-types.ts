@@ -13,6 +13,7 @@ export interface Conversation {
   archived: boolean;
   parent_conversation_id: string | null;
   model: string | null;
+  sandbox: string | null;
 }
 
 export interface Usage {
@@ -165,6 +165,22 @@ class ApiService {
     }
   }
 
+  async countTokens(
+    conversationId: string,
+    message: string,
+    model?: string,
+  ): Promise<{ input_tokens: number; exact: boolean; context_window_size: number }> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/count-tokens`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ message, model }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to count tokens: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
   async validateCwd(path: string): Promise<{ valid: boolean; error?: string }> {
     const response = await fetch(`${this.baseUrl}/validate-cwd?path=${encodeURIComponent(path)}`);
     if (!response.ok) {
@@ -407,6 +423,9 @@ class ApiService {
       total_input_tokens: number;
       total_output_tokens: number;
       total_cost_usd: number;
+      total_cache_read_tokens: number;
+      total_cache_write_tokens: number;
+      cache_hit_rate: number;
     }>;
     total_cost_usd: number;
   }> {
@@ -432,6 +451,26 @@ class ApiService {
     return response.json();
   }
 
+  async getCheckpoints(conversationId: string): Promise<FileCheckpoint[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/checkpoints`);
+    if (!response.ok) throw new Error(`Failed to get checkpoints: ${response.statusText}`);
+    return response.json();
+  }
+
+  async rewindConversation(
+    conversationId: string,
+    sequenceId: number,
+    options: { files: boolean; conversation: boolean },
+  ): Promise<RewindResult> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/rewind`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ sequence_id: sequenceId, ...options }),
+    });
+    if (!response.ok) throw new Error(`Failed to rewind: ${await response.text()}`);
+    return response.json();
+  }
+
   async editMessage(conversationId: string, sequenceId: number, message: string): Promise<void> {
     const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/edit`, {
       method: "POST",
@@ -452,6 +491,18 @@ class ApiService {
 
 export const api = new ApiService();
 
+export interface FileCheckpoint {
+  sequence_id: number;
+  path: string;
+  existed: boolean;
+  size: number;
+}
+
+export interface RewindResult {
+  restored: string[];
+  message?: string;
+}
+
 export interface SkillSummary {
   name: string;
   description: string;
@@ -471,7 +522,7 @@ export interface SkillContent {
 export interface CustomModel {
   model_id: string;
   display_name: string;
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama" | "bedrock" | "vertex";
   endpoint: string;
   api_key: string;
   model_name: string;
@@ -484,7 +535,7 @@ export type ThinkingLevel = "off" | "minimal" | "low" | "medium" | "high";
 
 export interface CreateCustomModelRequest {
   display_name: string;
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama" | "bedrock" | "vertex";
   endpoint: string;
   api_key: string;
   model_name: string;
@@ -495,7 +546,7 @@ export interface CreateCustomModelRequest {
 
 export interface TestCustomModelRequest {
   model_id?: string; // If provided with empty api_key, use stored key
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama" | "bedrock" | "vertex";
   endpoint: string;
   api_key: string;
   model_name: string;
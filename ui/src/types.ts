@@ -37,6 +37,7 @@ export interface LLMContent {
   ToolError?: boolean;
   // Other fields from Go struct
   MediaType?: string;
+  Filename?: string;
   Thinking?: string;
   Data?: string;
   Signature?: string;
@@ -60,6 +61,7 @@ export interface ChatRequest {
   message: string;
   model?: string;
   cwd?: string;
+  sandbox?: string;
 }
 // Notification event types
 export type NotificationEventType = "agent_done" | "agent_error";
@@ -89,6 +91,7 @@ export interface InitData {
   models: Model[];
   default_model: string;
   default_cwd?: string;
+  default_sandbox?: string; // set when the server can sandbox commands
   home_dir?: string;
   hostname?: string;
   terminal_url?: string;